@@ -104,7 +104,7 @@ func TestLibrarianCluster(t *testing.T) {
 	state := setUp(params)
 
 	// healthcheck
-	healthy, _ := state.authors[0].Healthcheck()
+	healthy, _, _ := state.authors[0].Healthcheck()
 	assert.True(t, healthy)
 
 	// ensure each peer can respond to an introduce request
@@ -265,7 +265,7 @@ func testUpload(t *testing.T, params *params, state *state) {
 func testDownload(t *testing.T, _ *params, state *state) {
 	for i, envKey := range state.uploadedDocEnvKeys {
 		downloaded := new(bytes.Buffer)
-		err := state.authors[0].Download(downloaded, envKey)
+		_, err := state.authors[0].Download(downloaded, envKey)
 		assert.Nil(t, err)
 		assert.Equal(t, len(state.uploadedDocContents[i]), downloaded.Len())
 		assert.Equal(t, state.uploadedDocContents[i], downloaded.Bytes())
@@ -298,7 +298,7 @@ func testShare(t *testing.T, _ *params, state *state) {
 		assert.Nil(t, err)
 
 		downloaded := new(bytes.Buffer)
-		err = to.Download(downloaded, envKey)
+		_, err = to.Download(downloaded, envKey)
 		assert.Nil(t, err)
 		assert.Equal(t, len(state.uploadedDocContents[i]), downloaded.Len())
 		assert.Equal(t, state.uploadedDocContents[i], downloaded.Bytes())
@@ -533,6 +533,7 @@ func newConfig(
 
 	return server.NewDefaultConfig().
 		WithLocalAddr(localAddr).
+		WithDefaultLocalMetricsAddr().
 		WithDefaultPublicAddr().
 		WithDefaultPublicName().
 		WithDataDir(peerDataDir).
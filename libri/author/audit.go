@@ -0,0 +1,120 @@
+package author
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+)
+
+// auditKeyTimeLength is the length of the big-endian Unix nanosecond timestamp prefixing each
+// audit record key, which orders the records by time.
+const auditKeyTimeLength = 8
+
+// AuditRecord records an envelope uploaded or shared by the author.
+type AuditRecord struct {
+	// Time is when the envelope was shipped.
+	Time time.Time
+
+	// EnvelopeKey is the key of the shipped envelope.
+	EnvelopeKey id.ID
+
+	// EntryKey is the key of the entry the envelope gives access to.
+	EntryKey id.ID
+
+	// AuthorPublicKey is the public key of the author of the envelope.
+	AuthorPublicKey []byte
+
+	// ReaderPublicKey is the public key of the reader of the envelope.
+	ReaderPublicKey []byte
+}
+
+// AuditLog returns the records of the envelopes uploaded or shared by the author from (inclusive)
+// to (exclusive) the given times, in time order.
+func (a *Author) AuditLog(from, to time.Time) ([]AuditRecord, error) {
+	records := make([]AuditRecord, 0)
+	start, end := auditKeyTime(from), auditKeyTime(to)
+	err := a.auditSL.IterateRange(start, end, func(key, value []byte) error {
+		t := time.Unix(0, int64(binary.BigEndian.Uint64(key[:auditKeyTimeLength])))
+		record, err := newAuditRecord(t, value)
+		if err != nil {
+			return err
+		}
+		records = append(records, *record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// recordAudit durably appends the audit records of the shared envelopes. Callers record them
+// before reporting the share as successful, so every successful one has its record.
+func (a *Author) recordAudit(envs ...*api.Document) error {
+	batch := &storage.Batch{}
+	if err := addAuditRecords(batch, time.Now(), envs...); err != nil {
+		return err
+	}
+	return a.batchWriter.WriteBatch(batch)
+}
+
+// addAuditRecords adds storing the audit records of the envelopes shipped at the given time to
+// the batch.
+func addAuditRecords(batch *storage.Batch, t time.Time, envs ...*api.Document) error {
+	for _, env := range envs {
+		envKey, err := api.GetKey(env)
+		if err != nil {
+			return err
+		}
+		envBytes, err := proto.Marshal(env)
+		if err != nil {
+			return err
+		}
+		batch.Store(storage.Audit, auditKey(t, envKey), envBytes)
+	}
+	return nil
+}
+
+// auditKey returns the key of the audit record for the envelope shipped at the given time,
+// consisting of the timestamp followed by the start of the envelope key.
+func auditKey(t time.Time, envKey id.ID) []byte {
+	key := make([]byte, 0, id.Length)
+	key = append(key, auditKeyTime(t)...)
+	return append(key, envKey.Bytes()[:id.Length-auditKeyTimeLength]...)
+}
+
+// auditKeyTime returns the timestamp prefix of the audit record keys for the given time. Times
+// before the Unix epoch, which no record has, map to the zero timestamp.
+func auditKeyTime(t time.Time) []byte {
+	prefix := make([]byte, auditKeyTimeLength)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(prefix, uint64(t.UnixNano()))
+	}
+	return prefix
+}
+
+func newAuditRecord(t time.Time, envBytes []byte) (*AuditRecord, error) {
+	env := &api.Document{}
+	if err := proto.Unmarshal(envBytes, env); err != nil {
+		return nil, err
+	}
+	envKey, err := api.GetKey(env)
+	if err != nil {
+		return nil, err
+	}
+	envelope, ok := env.Contents.(*api.Document_Envelope)
+	if !ok {
+		return nil, api.ErrUnexpectedDocumentType
+	}
+	return &AuditRecord{
+		Time:            t,
+		EnvelopeKey:     envKey,
+		EntryKey:        id.FromBytes(envelope.Envelope.EntryKey),
+		AuthorPublicKey: envelope.Envelope.AuthorPublicKey,
+		ReaderPublicKey: envelope.Envelope.ReaderPublicKey,
+	}, nil
+}
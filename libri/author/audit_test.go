@@ -0,0 +1,108 @@
+package author
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthor_AuditLog_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	env1 := newTestEnvelopeDoc(rng)
+	env2, env3 := newTestEnvelopeDoc(rng), newTestEnvelopeDoc(rng)
+
+	start := time.Now()
+	assert.Nil(t, a.recordAudit(env1))
+	time.Sleep(time.Millisecond)
+	mid := time.Now()
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, a.recordAudit(env2, env3))
+	end := time.Now().Add(time.Millisecond)
+
+	// check all records are returned
+	records, err := a.AuditLog(start, end)
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+	checkAuditRecord(t, env1, records[0])
+	assert.False(t, records[0].Time.Before(start))
+	assert.True(t, records[0].Time.Before(mid))
+	assert.Equal(t, records[1].Time, records[2].Time)
+
+	// check records are limited to the range
+	records, err = a.AuditLog(mid, end)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	records, err = a.AuditLog(start, mid)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	checkAuditRecord(t, env1, records[0])
+	records, err = a.AuditLog(end, end.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+func TestAuthor_AuditLog_err(t *testing.T) {
+	// check Iterate error bubbles up
+	a := &Author{
+		auditSL: &fixedStorerLoader{iterateErr: errors.New("some Iterate error")},
+	}
+	records, err := a.AuditLog(time.Time{}, time.Now())
+	assert.NotNil(t, err)
+	assert.Nil(t, records)
+}
+
+func TestAuthor_recordAudit_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+
+	// check WriteBatch error bubbles up
+	a := &Author{
+		batchWriter: &fixedBatchWriter{err: errors.New("some WriteBatch error")},
+	}
+	assert.NotNil(t, a.recordAudit(newTestEnvelopeDoc(rng)))
+}
+
+func TestNewAuditRecord_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+
+	// check unmarshal error bubbles up
+	record, err := newAuditRecord(time.Now(), []byte("not a document"))
+	assert.NotNil(t, err)
+	assert.Nil(t, record)
+
+	// check non-envelope document errors
+	entry, _ := api.NewTestDocument(rng)
+	entryBytes, err := proto.Marshal(entry)
+	assert.Nil(t, err)
+	record, err = newAuditRecord(time.Now(), entryBytes)
+	assert.Equal(t, api.ErrUnexpectedDocumentType, err)
+	assert.Nil(t, record)
+}
+
+func newTestEnvelopeDoc(rng *rand.Rand) *api.Document {
+	return &api.Document{
+		Contents: &api.Document_Envelope{
+			Envelope: api.NewTestEnvelope(rng),
+		},
+	}
+}
+
+func checkAuditRecord(t *testing.T, env *api.Document, record AuditRecord) {
+	envKey, err := api.GetKey(env)
+	assert.Nil(t, err)
+	envelope := env.Contents.(*api.Document_Envelope).Envelope
+	assert.Equal(t, envKey, record.EnvelopeKey)
+	assert.Equal(t, id.FromBytes(envelope.EntryKey), record.EntryKey)
+	assert.Equal(t, envelope.AuthorPublicKey, record.AuthorPublicKey)
+	assert.Equal(t, envelope.ReaderPublicKey, record.ReaderPublicKey)
+}
@@ -3,12 +3,18 @@ package author
 import (
 	"io"
 	"fmt"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"github.com/drausin/libri/libri/author/io/comp"
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/pack"
 	"github.com/drausin/libri/libri/author/io/page"
 	"github.com/drausin/libri/libri/author/io/publish"
 	"github.com/drausin/libri/libri/author/io/ship"
 	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/clock"
 	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/ecid"
 	"github.com/drausin/libri/libri/common/id"
@@ -21,6 +27,9 @@ import (
 	"golang.org/x/net/context"
 	"github.com/dustin/go-humanize"
 	"crypto/ecdsa"
+	"bytes"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -36,16 +45,87 @@ const (
 	// LoggerReaderPub is the logger key used for a reader public key.
 	LoggerReaderPub = "reader_pub"
 
+	// LoggerIdentity is the logger key used for the name of an identity.
+	LoggerIdentity = "identity"
+
+	// LoggerMediaType is the logger key used for the media type of uploaded content.
+	LoggerMediaType = "media_type"
+
 	// LoggerNPages is the logger key used for the number of pages in a document.
 	LoggerNPages = "n_pages"
-)
 
-var (
-	healthcheckTimeout = 2 * time.Second
+	// LoggerUploadKey is the logger key used for the key of an upload's resume state.
+	LoggerUploadKey = "upload_key"
+
+	// LoggerPublicationKey is the logger key used for the key of a Publication.
+	LoggerPublicationKey = "publication_key"
 )
 
+// DefaultMediaType is the media type of uploaded content given an empty media type when it can't
+// be detected from the content.
+const DefaultMediaType = "application/octet-stream"
+
+// sniffLen is the number of leading content bytes used to detect its media type.
+const sniffLen = 512
+
+// ErrInvalidMediaType indicates that the media type of uploaded content is not a valid
+// type/subtype media type (RFC 6838) with optional parameters.
+var ErrInvalidMediaType = errors.New("invalid media type")
+
+// ErrNegativeContentSize indicates when the size of content uploaded with UploadAt is negative.
+var ErrNegativeContentSize = errors.New("negative content size")
+
+// ErrSearchConcurrencyTooLarge indicates when a download's search concurrency is above the
+// maximum librarians allow.
+var ErrSearchConcurrencyTooLarge = fmt.Errorf("search concurrency is above %d maximum",
+	api.MaxSearchConcurrency)
+
+// healthcheckParallelism is the max number of librarians concurrently health checked
+var healthcheckParallelism = 8
+
+// AuthorClient uploads, downloads, and shares documents in the libri network. *Author implements
+// it, so applications can depend on AuthorClient and inject fakes in their tests.
+type AuthorClient interface {
+	// Upload compresses, encrypts, and splits the content into pages and then stores them in the
+	// libri network. It returns the uploaded envelope for self-storage and its key.
+	Upload(content io.Reader, mediaType string) (*api.Document, id.ID, error)
+
+	// UploadWithOpts is like Upload but with optional parameters.
+	UploadWithOpts(content io.Reader, mediaType string, opts *UploadOpts) (
+		*api.Document, id.ID, error)
+
+	// Download downloads the document with the given envelope key, writing its content to the
+	// content writer and returning its media type.
+	Download(content io.Writer, envKey id.ID) (string, error)
+
+	// DownloadWithContext is like Download but stops once the context is done.
+	DownloadWithContext(ctx context.Context, content io.Writer, envKey id.ID) (string, error)
+
+	// DownloadWithOpts is like DownloadWithContext but with optional parameters.
+	DownloadWithOpts(ctx context.Context, content io.Writer, envKey id.ID, opts *DownloadOpts) (
+		string, error)
+
+	// Share creates and uploads a new envelope for the document with the given envelope key,
+	// giving the reader access to it.
+	Share(envKey id.ID, readerPub *ecdsa.PublicKey) (*api.Document, id.ID, error)
+
+	// ShareWithOpts is like Share but with optional parameters.
+	ShareWithOpts(envKey id.ID, readerPub *ecdsa.PublicKey, opts *ShareOpts) (
+		*api.Document, id.ID, error)
+
+	// Subscribe streams the envelope keys of new publications whose reader public key is in the
+	// filter until the context is done.
+	Subscribe(ctx context.Context, filter *api.BloomFilter) (<-chan id.ID, error)
+
+	// Healthcheck reports the health status and round-trip latency of the connected librarians.
+	Healthcheck() (bool, map[string]healthpb.HealthCheckResponse_ServingStatus,
+		map[string]time.Duration)
+}
+
 // Author is the main client of the libri network. It can upload, download, and share documents with
-// other author clients.
+// other author clients. The KEKs and EEKs it derives are zeroed once each upload, download, or
+// share is done, though only on a best-effort basis (see enc.EEK.Zero). Each uploaded or shared
+// envelope is recorded in its AuditLog.
 type Author struct {
 	// selfID is ID of this author client
 	clientID ecid.ID
@@ -57,11 +137,15 @@ type Author struct {
 	// author or reader keys
 	authorKeys keychain.GetterSampler
 
+	// registry of named author keychains in addition to authorKeys, selected by the Identity of
+	// uploads and shares
+	identities *identities
+
 	// collection of reader keys used with sending Envelope documents to oneself; these are
 	// never used as the author key
 	selfReaderKeys keychain.Getter
 
-	// union of authorKeys and selfReaderKeys
+	// union of authorKeys, identities, and selfReaderKeys
 	allKeys keychain.Getter
 
 	// samples a pair of author and selfReader keys for encrypting an entry
@@ -76,6 +160,16 @@ type Author struct {
 	// SLD for locally stored documents
 	documentSLD storage.DocumentSLD
 
+	// SLD for the resume state of in-progress uploads
+	uploadSLD storage.NamespaceSLD
+
+	// SL for the audit records of uploaded and shared envelopes
+	auditSL storage.NamespaceSL
+
+	// atomically writes batches across the namespaces above, e.g., an audit record together
+	// with removing the resume state of the upload it records
+	batchWriter storage.BatchWriter
+
 	// load balancer for librarian clients
 	librarians api.ClientBalancer
 
@@ -87,6 +181,9 @@ type Author struct {
 
 	entryUnpacker pack.EntryUnpacker
 
+	// decrypts entry metadata with the scheme recorded in each entry
+	schemes enc.Schemes
+
 	// publishes documents to libri
 	shipper ship.Shipper
 
@@ -101,187 +198,880 @@ type Author struct {
 	// logger for this instance
 	logger *zap.Logger
 
+	// receives upload and download lifecycle events, if not nil
+	events chan<- *Event
+
+	// tells the time for timeouts and latencies
+	clock clock.Clock
+
+	// registry of the author's Prometheus metrics
+	metrics *prometheus.Registry
+
 	// receives graceful stop signal
 	stop chan struct{}
 }
 
 // NewAuthor creates a new *Author from the Config, decrypting the keychains with the supplied
-// auth string.
+// auth string. Callers that only upload, download, and share documents can hold the result as an
+// AuthorClient.
 func NewAuthor(
 	config *Config,
 	authorKeys keychain.GetterSampler,
 	selfReaderKeys keychain.GetterSampler,
 	logger *zap.Logger) (*Author, error) {
 
-	rdb, err := db.NewRocksDB(config.DbDir)
+	rdb, err := db.NewKVDB(config.DBBackend, config.DbDir)
 	if err != nil {
-		logger.Error("unable to init RocksDB", zap.Error(err))
+		logger.Error("unable to init DB", zap.String("db_backend", config.DBBackend),
+			zap.String("db_dir", config.DbDir), zap.Error(err))
 		return nil, err
 	}
-	clientSL := storage.NewClientSL(rdb)
-	documentSL := storage.NewDocumentSLD(rdb)
 
 	// get client ID and immediately save it so subsequent restarts have it
-	clientID, err := loadOrCreateClientID(logger, clientSL)
+	clientID, err := loadOrCreateClientID(logger, storage.NewClientSL(rdb))
 	if err != nil {
 		return nil, err
 	}
 
-	allKeys := keychain.NewUnion(authorKeys, selfReaderKeys)
-	envKeys := &envelopeKeySamplerImpl{
-		authorKeys:     authorKeys,
-		selfReaderKeys: selfReaderKeys,
-	}
-	librarians, err := api.NewUniformRandomClientBalancer(config.LibrarianAddrs)
+	dialOpts := config.dialOptions()
+	librarians, err := newClientBalancer(config.LibrarianBalancer, config.LibrarianAddrs,
+		config.TransportCredentials, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
-	librarianHealths, err := getLibrarianHealthClients(config.LibrarianAddrs)
+	librarianHealths, err := getLibrarianHealthClients(config.LibrarianAddrs,
+		config.TransportCredentials, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
-	signer := client.NewSigner(clientID.Key())
+	author := newAuthor(config, clientID, authorKeys, selfReaderKeys, rdb,
+		storage.NewDocumentSLD(rdb), librarians, librarianHealths, logger)
+	return author, nil
+}
+
+// newAuthor creates a new *Author from its client ID, keychains, DB, and librarian clients,
+// constructing the rest of its components from them.
+func newAuthor(
+	config *Config,
+	storedClientID ecid.ID,
+	authorKeys keychain.GetterSampler,
+	selfReaderKeys keychain.GetterSampler,
+	rdb db.KVDB,
+	documentSL storage.DocumentSLD,
+	librarians api.ClientBalancer,
+	librarianHealths map[string]healthpb.HealthClient,
+	logger *zap.Logger) *Author {
+
+	clientSL := storage.NewClientSL(rdb)
+	uploadSLD := storage.NewUploadSLD(rdb)
+	auditSL := storage.NewAuditSL(rdb)
+	batchWriter := storage.NewBatchWriter(rdb)
+	clientID := newRotatingClientID(storedClientID)
+	clk := config.Clock
+	if clk == nil {
+		// hand-built configs may not have a clock
+		clk = clock.New()
+	}
+
+	if config.CountKeyUsage {
+		authorKeys = keychain.NewCounting(authorKeys)
+		selfReaderKeys = keychain.NewCounting(selfReaderKeys)
+	}
+	identities := newIdentities()
+	allKeys := keychain.NewUnion(authorKeys, identities, selfReaderKeys)
+	envKeys := &envelopeKeySamplerImpl{
+		authorKeys:     authorKeys,
+		identities:     identities,
+		selfReaderKeys: selfReaderKeys,
+	}
+	signer := clientID // signs with whichever client ID is current
 
 	publisher := publish.NewPublisher(clientID, signer, config.Publish)
 	acquirer := publish.NewAcquirer(clientID, signer, config.Publish)
-	slPublisher := publish.NewSingleLoadPublisher(publisher, documentSL)
+	slPublisher := newResumingPublisher(
+		publish.NewSingleLoadPublisher(publisher, documentSL),
+		documentSL,
+		uploadSLD,
+	)
 	ssAcquirer := publish.NewSingleStoreAcquirer(acquirer, documentSL)
-	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, config.Publish)
+	mlParams := *config.Publish // copy so the caller's config keeps its zero MaxInFlight
+	if mlParams.MaxInFlight == 0 {
+		mlParams.MaxInFlight = publish.DefaultMaxInFlight(len(config.LibrarianAddrs))
+	}
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, &mlParams)
 	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, config.Publish)
 	shipper := ship.NewShipper(librarians, publisher, mlPublisher)
-	receiver := ship.NewReceiver(librarians, allKeys, acquirer, msAcquirer, documentSL)
+	schemes := enc.NewSchemes(config.EncryptionScheme)
+	entryPacker := pack.NewEntryPacker(config.Print, config.EncryptionScheme, documentSL)
 
-	mdEncDec := enc.NewMetadataEncrypterDecrypter()
-	entryPacker := pack.NewEntryPacker(config.Print, mdEncDec, documentSL)
-	entryUnpacker := pack.NewEntryUnpacker(config.Print, mdEncDec, documentSL)
+	metrics := prometheus.NewRegistry()
+	pageMetrics := page.NewMetrics()
+	pageMetrics.Register(metrics)
+
+	var receiver ship.Receiver
+	var entryUnpacker pack.EntryUnpacker
+	if config.StreamDownloads {
+		receiver = ship.NewStreamingReceiver(librarians, allKeys, acquirer, msAcquirer,
+			documentSL)
+		pageL := page.NewStreamingStorerLoader(documentSL, msAcquirer, librarians,
+			config.Publish.GetParallelism, pageMetrics)
+		entryUnpacker = pack.NewEntryUnpackerWithLoader(config.Print, schemes, pageL)
+	} else {
+		receiver = ship.NewReceiver(librarians, allKeys, acquirer, msAcquirer, documentSL)
+		entryUnpacker = pack.NewRefetchingEntryUnpacker(config.Print, schemes, documentSL,
+			msAcquirer, librarians, pageMetrics)
+	}
 
 	author := &Author{
 		clientID:         clientID,
 		config:           config,
 		authorKeys:       authorKeys,
+		identities:       identities,
 		selfReaderKeys:   selfReaderKeys,
 		allKeys:          allKeys,
-		envKeys:     envKeys,
+		envKeys:          envKeys,
 		db:               rdb,
 		clientSL:         clientSL,
 		documentSLD:      documentSL,
+		uploadSLD:        uploadSLD,
+		auditSL:          auditSL,
+		batchWriter:      batchWriter,
 		librarians:       librarians,
 		librarianHealths: librarianHealths,
 		entryPacker:      entryPacker,
 		entryUnpacker:    entryUnpacker,
+		schemes:          schemes,
 		shipper:          shipper,
 		receiver:         receiver,
 		pageSL:           page.NewStorerLoader(documentSL),
 		signer:           signer,
 		logger:           logger,
+		events:           config.Events,
+		clock:            clk,
+		metrics:          metrics,
 		stop:             make(chan struct{}),
 	}
 
 	// for now, this doesn't really do anything
 	go func() { <-author.stop }()
 
-	return author, nil
+	return author
+}
+
+// ID returns the author's client ID, which is created when the author's DB is first initialized
+// and persisted there across restarts until rotated with RotateID.
+func (a *Author) ID() ecid.ID {
+	if rotating, ok := a.clientID.(*rotatingClientID); ok {
+		return rotating.id()
+	}
+	return a.clientID
+}
+
+// Metrics returns the registry of the author's Prometheus metrics, which callers can expose, e.g.,
+// via promhttp.HandlerFor.
+func (a *Author) Metrics() *prometheus.Registry {
+	return a.metrics
+}
+
+// KeyUsageCounts returns the number of times each author (of every identity) and self reader key
+// has been sampled, indexed by the hex of its public key, or nil if the author wasn't configured
+// with CountKeyUsage.
+func (a *Author) KeyUsageCounts() map[string]int {
+	authorKeys, ok1 := a.authorKeys.(keychain.CountingGetterSampler)
+	selfReaderKeys, ok2 := a.selfReaderKeys.(keychain.CountingGetterSampler)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	counts := authorKeys.Counts()
+	for pub, n := range selfReaderKeys.Counts() {
+		counts[pub] += n
+	}
+	a.identities.addCounts(counts)
+	return counts
+}
+
+// AddIdentity registers an additional author keychain under the given name, which uploads and
+// shares select with their Identity option, e.g., to keep personal and work documents under
+// separate author keys. Envelopes authored by any identity are shared to oneself with the same
+// self reader keys. It returns ErrIdentityExists if the name is DefaultIdentity or already
+// registered.
+func (a *Author) AddIdentity(name string, authorKeys keychain.GetterSampler) error {
+	if a.config.CountKeyUsage {
+		authorKeys = keychain.NewCounting(authorKeys)
+	}
+	if err := a.identities.add(name, authorKeys); err != nil {
+		return err
+	}
+	a.logger.Info("added identity", zap.String(LoggerIdentity, name))
+	return nil
+}
+
+// getAuthorKey returns the author key with the given public key from the keychain of any
+// identity.
+func (a *Author) getAuthorKey(publicKey []byte) (ecid.ID, bool) {
+	if key, in := a.authorKeys.Get(publicKey); in {
+		return key, in
+	}
+	return a.identities.Get(publicKey)
 }
 
-// Healthcheck executes and reports healthcheck status for all connected librarians.
-func (a *Author) Healthcheck() (bool, map[string]healthpb.HealthCheckResponse_ServingStatus) {
+// Healthcheck executes and reports healthcheck status and round-trip latency for all connected
+// librarians. The checks run in parallel, with at most healthcheckParallelism at a time, each
+// with the configured HealthcheckTimeout. Librarians whose checks haven't finished by the
+// configured HealthcheckDeadline are reported as UNKNOWN.
+func (a *Author) Healthcheck() (
+	bool,
+	map[string]healthpb.HealthCheckResponse_ServingStatus,
+	map[string]time.Duration,
+) {
+	ctx, cancel := clock.WithTimeout(context.Background(), a.clock, a.config.HealthcheckDeadline)
+	defer cancel()
+	startTime := a.clock.Now()
+	addrStrs := make(chan string, len(a.librarianHealths))
+	for addrStr := range a.librarianHealths {
+		addrStrs <- addrStr
+	}
+	close(addrStrs)
+
+	// results is buffered for all librarians, so checks finishing after the deadline don't block
+	results := make(chan *healthcheckResult, len(a.librarianHealths))
+	for c := 0; c < healthcheckParallelism; c++ {
+		go func() {
+			for addrStr := range addrStrs {
+				if ctx.Err() != nil {
+					return
+				}
+				results <- checkHealth(ctx, a.clock, addrStr, a.librarianHealths[addrStr],
+					a.config.HealthcheckTimeout)
+			}
+		}()
+	}
+	finished := collectHealthcheckResults(ctx, results, len(a.librarianHealths))
+
+	latencyObs, _ := a.librarians.(api.LatencyObserver)
 	healthStatus := make(map[string]healthpb.HealthCheckResponse_ServingStatus)
+	healthLatency := make(map[string]time.Duration)
 	allHealthy := true
-	for addrStr, healthClient := range a.librarianHealths {
-		ctx, cancel := context.WithTimeout(context.Background(), healthcheckTimeout)
-		rp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
-		cancel()
-		if err != nil {
-			healthStatus[addrStr] = healthpb.HealthCheckResponse_UNKNOWN
+	for addrStr := range a.librarianHealths {
+		result, in := finished[addrStr]
+		if !in {
+			result = &healthcheckResult{
+				addrStr: addrStr,
+				latency: a.clock.Now().Sub(startTime),
+				err:     ctx.Err(),
+			}
+		}
+		healthLatency[result.addrStr] = result.latency
+		if latencyObs != nil {
+			observeHealthLatency(latencyObs, result, a.config.HealthcheckTimeout)
+		}
+		if result.err != nil {
+			healthStatus[result.addrStr] = healthpb.HealthCheckResponse_UNKNOWN
 			allHealthy = false
 			a.logger.Info("librarian peer is not reachable",
-				zap.String("peer_address", addrStr),
+				zap.String("peer_address", result.addrStr),
+				zap.Duration("latency", result.latency),
+				zap.Error(result.err),
 			)
 			continue
 		}
 
-		healthStatus[addrStr] = rp.Status
-		if rp.Status == healthpb.HealthCheckResponse_SERVING {
+		healthStatus[result.addrStr] = result.status
+		if result.status == healthpb.HealthCheckResponse_SERVING {
 			a.logger.Info("librarian peer is healthy",
-				zap.String("peer_address", addrStr),
+				zap.String("peer_address", result.addrStr),
+				zap.Duration("latency", result.latency),
 			)
 			continue
 		}
 
 		allHealthy = false
 		a.logger.Warn("librarian peer is not healthy",
-			zap.String("peer_address", addrStr),
+			zap.String("peer_address", result.addrStr),
+			zap.Duration("latency", result.latency),
 		)
+	}
+	return allHealthy, healthStatus, healthLatency
+}
 
+type healthcheckResult struct {
+	addrStr string
+	status  healthpb.HealthCheckResponse_ServingStatus
+	latency time.Duration
+	err     error
+}
+
+// collectHealthcheckResults receives up to n healthcheck results until the context is done,
+// returning them by librarian address.
+func collectHealthcheckResults(
+	ctx context.Context, results <-chan *healthcheckResult, n int,
+) map[string]*healthcheckResult {
+	finished := make(map[string]*healthcheckResult, n)
+	for len(finished) < n {
+		select {
+		case result := <-results:
+			finished[result.addrStr] = result
+		case <-ctx.Done():
+			return finished
+		}
+	}
+	return finished
+}
+
+// observeHealthLatency records the healthcheck latency, treating unreachable or unhealthy
+// librarians as if they had timed out.
+func observeHealthLatency(obs api.LatencyObserver, result *healthcheckResult,
+	timeout time.Duration) {
+	if result.err != nil || result.status != healthpb.HealthCheckResponse_SERVING {
+		obs.ObserveLatency(result.addrStr, timeout)
+		return
+	}
+	obs.ObserveLatency(result.addrStr, result.latency)
+}
+
+func checkHealth(
+	ctx context.Context,
+	clk clock.Clock,
+	addrStr string,
+	healthClient healthpb.HealthClient,
+	timeout time.Duration,
+) *healthcheckResult {
+	ctx, cancel := clock.WithTimeout(ctx, clk, timeout)
+	defer cancel()
+	startTime := clk.Now()
+	rp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+	result := &healthcheckResult{
+		addrStr: addrStr,
+		latency: clk.Now().Sub(startTime),
+		err:     err,
+	}
+	if err == nil {
+		result.status = rp.Status
 	}
-	return allHealthy, healthStatus
+	return result
 }
 
 // Upload compresses, encrypts, and splits the content into pages and then stores them in the
-// libri network. It returns the uploaded envelope for self-storage and its key.
+// libri network. It returns the uploaded envelope for self-storage and its key. An empty media
+// type is detected from the first 512 bytes of content (see http.DetectContentType), defaulting
+// to DefaultMediaType if detection is inconclusive, and an invalid one gives ErrInvalidMediaType
+// before any network activity.
 func (a *Author) Upload(content io.Reader, mediaType string) (*api.Document, id.ID, error) {
-	startTime := time.Now()
-	authorPub, readerPub, kek, eek, err := a.envKeys.sample()
+	return a.UploadWithOpts(content, mediaType, nil)
+}
+
+// UploadWithOpts is like Upload but with optional parameters. Nil opts are equivalent to Upload.
+func (a *Author) UploadWithOpts(content io.Reader, mediaType string, opts *UploadOpts) (
+	*api.Document, id.ID, error) {
+	env, envKey, _, err := a.UploadResumable(content, mediaType, opts)
+	return env, envKey, err
+}
+
+// UploadResult describes an uploaded document and how durably the libri network stored it.
+type UploadResult struct {
+	// Envelope is the uploaded envelope for self-storage.
+	Envelope *api.Document
+
+	// EnvelopeKey is the key of Envelope.
+	EnvelopeKey id.ID
+
+	// NReplicas is the number of replicas of the entry stored in the libri network.
+	NReplicas uint32
+
+	// ReplicaPeerIDs are the IDs of the peers storing the entry's replicas.
+	ReplicaPeerIDs []id.ID
+}
+
+// UploadWithResult is like UploadWithOpts but returns an UploadResult that also describes how
+// many replicas of the uploaded entry were stored and by which peers.
+func (a *Author) UploadWithResult(content io.Reader, mediaType string, opts *UploadOpts) (
+	*UploadResult, error) {
+	upload, err := a.packUpload(content, mediaType, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.zeroKeys()
+	return a.shipUpload(upload, opts)
+}
+
+// UploadResumable is like UploadWithOpts but also returns the upload key under which its resume
+// state is stored until the upload completes. If shipping fails part way through, passing this
+// key to ResumeUpload finishes the upload without republishing the pages already shipped.
+func (a *Author) UploadResumable(content io.Reader, mediaType string, opts *UploadOpts) (
+	*api.Document, id.ID, id.ID, error) {
+	upload, err := a.packUpload(content, mediaType, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer upload.zeroKeys()
+	result, err := a.shipUpload(upload, opts)
+	if err != nil {
+		return nil, nil, upload.uploadKey, err
+	}
+	return result.Envelope, result.EnvelopeKey, upload.uploadKey, nil
+}
+
+// UploadAt is like Upload but for the first size bytes of content with random access, e.g., an
+// *os.File. If the content isn't compressed (see comp.GetCompressionCodec), its pages are read
+// and encrypted in parallel from their offsets rather than sequentially. Either way, the content
+// is packed into the same pages as by Upload.
+func (a *Author) UploadAt(content io.ReaderAt, size int64, mediaType string) (
+	*api.Document, id.ID, error) {
+	if size < 0 {
+		return nil, nil, ErrNegativeContentSize
+	}
+	upload, err := a.packUploadAt(content, size, mediaType, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer upload.zeroKeys()
+	result, err := a.shipUpload(upload, nil)
 	if err != nil {
 		return nil, nil, err
 	}
+	return result.Envelope, result.EnvelopeKey, nil
+}
+
+// UploadShared is like Upload but also shares the uploaded document with each of the given
+// readers. The entry and its EEK are computed and shipped once, and then an envelope is shipped
+// for each reader with its own KEK, avoiding re-downloading the entry for each Share. It returns
+// the envelope for self-storage, the envelopes shared with the readers (in the same order), and
+// the keys of all the envelopes, starting with the self-storage envelope's key.
+func (a *Author) UploadShared(content io.Reader, mediaType string, readers []*ecdsa.PublicKey) (
+	*api.Document, []*api.Document, []id.ID, error) {
+	upload, err := a.packUpload(content, mediaType, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer upload.zeroKeys()
+	authorKey, in := a.getAuthorKey(upload.authorPub)
+	if !in {
+		return nil, nil, nil, keychain.ErrUnexpectedMissingKey
+	}
+	keks := make([]*enc.KEK, len(readers))
+	defer func() {
+		for _, kek := range keks {
+			kek.Zero()
+		}
+	}()
+	readerPubs := make([][]byte, len(readers))
+	for i, readerPub := range readers {
+		if keks[i], err = enc.NewKEK(authorKey.Key(), readerPub); err != nil {
+			return nil, nil, nil, err
+		}
+		readerPubs[i] = ecid.ToPublicKeyBytes(readerPub)
+	}
+
+	selfResult, err := a.shipUpload(upload, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	selfEnv, selfEnvKey := selfResult.Envelope, selfResult.EnvelopeKey
+	entryKey := id.FromBytes(selfEnv.Contents.(*api.Document_Envelope).Envelope.EntryKey)
+	sharedEnvs, sharedEnvKeys, err := a.shipper.ShipEnvelopes(keks, upload.eek, entryKey,
+		upload.authorPub, readerPubs, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := a.recordAudit(sharedEnvs...); err != nil {
+		return nil, nil, nil, err
+	}
+
+	a.logger.Info("successfully shared uploaded document",
+		zap.Stringer(LoggerEntryKey, entryKey),
+		zap.Stringer(LoggerEnvelopeKey, selfEnvKey),
+		zap.Int("n_readers", len(readers)),
+	)
+	return selfEnv, sharedEnvs, append([]id.ID{selfEnvKey}, sharedEnvKeys...), nil
+}
+
+// packedUpload is an entry that has been packed and saved for shipping.
+type packedUpload struct {
+	startTime time.Time
+	entry     *api.Document
+	metadata  *api.Metadata
+	authorPub []byte
+	readerPub []byte
+	kek       *enc.KEK
+	eek       *enc.EEK
+	uploadKey id.ID
+}
+
+// zeroKeys zeros the upload's KEK and EEK once it has been shipped (or failed to).
+func (u *packedUpload) zeroKeys() {
+	u.kek.Zero()
+	u.eek.Zero()
+}
+
+// packUpload packs a new upload, emitting an UploadStarted event and, if packing fails, an
+// UploadFailed event.
+func (a *Author) packUpload(content io.Reader, mediaType string, opts *UploadOpts) (
+	*packedUpload, error) {
+	a.emit(&Event{Type: UploadStarted})
+	upload, err := a.packNewUpload(content, mediaType, opts)
+	if err != nil {
+		a.emit(&Event{Type: UploadFailed})
+		return nil, err
+	}
+	return upload, nil
+}
+
+// packUploadAt is like packUpload but for the first size bytes of content with random access.
+func (a *Author) packUploadAt(content io.ReaderAt, size int64, mediaType string,
+	opts *UploadOpts) (*packedUpload, error) {
+	a.emit(&Event{Type: UploadStarted})
+	upload, err := a.packNewUploadAt(content, size, mediaType, opts)
+	if err != nil {
+		a.emit(&Event{Type: UploadFailed})
+		return nil, err
+	}
+	return upload, nil
+}
+
+// entryPackFunc packs the content of an upload into an entry with the given media type and keys.
+type entryPackFunc func(mediaType string, keys *enc.EEK, authorPub []byte) (
+	*api.Document, *api.Metadata, error)
+
+// packNewUpload samples the envelope keys for a new upload, packs the content into an entry, and
+// saves the resume state for the upload.
+func (a *Author) packNewUpload(content io.Reader, mediaType string, opts *UploadOpts) (
+	*packedUpload, error) {
+	startTime := time.Now()
+	content, mediaType, err := a.detectMediaType(content, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	return a.packEntry(startTime, mediaType, opts,
+		func(mediaType string, keys *enc.EEK, authorPub []byte) (
+			*api.Document, *api.Metadata, error) {
+			return a.entryPacker.Pack(content, mediaType, opts.codec(), opts.pageSize(),
+				opts.expiry(), keys, authorPub)
+		})
+}
+
+// packNewUploadAt is like packNewUpload but for the first size bytes of content with random
+// access, which it packs in parallel if the entry packer is a pack.EntryAtPacker.
+func (a *Author) packNewUploadAt(content io.ReaderAt, size int64, mediaType string,
+	opts *UploadOpts) (*packedUpload, error) {
+	startTime := time.Now()
+	_, mediaType, err := a.detectMediaType(io.NewSectionReader(content, 0, size), mediaType)
+	if err != nil {
+		return nil, err
+	}
+	return a.packEntry(startTime, mediaType, opts,
+		func(mediaType string, keys *enc.EEK, authorPub []byte) (
+			*api.Document, *api.Metadata, error) {
+			atPacker, ok := a.entryPacker.(pack.EntryAtPacker)
+			if !ok {
+				return a.entryPacker.Pack(io.NewSectionReader(content, 0, size), mediaType,
+					opts.codec(), opts.pageSize(), opts.expiry(), keys, authorPub)
+			}
+			return atPacker.PackAt(content, size, mediaType, opts.codec(), opts.pageSize(),
+				opts.expiry(), keys, authorPub)
+		})
+}
+
+// packEntry samples the envelope keys for a new upload, packs its entry with the given function,
+// and saves the resume state for the upload.
+func (a *Author) packEntry(startTime time.Time, mediaType string, opts *UploadOpts,
+	packFn entryPackFunc) (*packedUpload, error) {
+	normalizedMediaType, err := normalizeMediaType(mediaType)
+	if err != nil {
+		a.logger.Error("invalid media type", zap.String(LoggerMediaType, mediaType))
+		return nil, err
+	}
+	authorPub, readerPub, kek, eek, err := a.envKeys.sample(opts.identity())
+	if err != nil {
+		return nil, err
+	}
+	if optsEEK := opts.eek(); optsEEK != nil {
+		// copy the given EEK since the upload zeros its EEK once done
+		eek.Zero()
+		if eek, err = enc.UnmarshalEEK(enc.MarshalEEK(optsEEK)); err != nil {
+			kek.Zero()
+			return nil, err
+		}
+	}
 
 	a.logger.Debug("packing content",
 		zap.String(LoggerAuthorPub, fmt.Sprintf("%065x", authorPub)),
 	)
-	entry, metadata, err := a.entryPacker.Pack(content, mediaType, eek, authorPub)
+	entry, metadata, err := packFn(normalizedMediaType, eek, authorPub)
 	if err != nil {
-		return nil, nil, err
+		kek.Zero()
+		eek.Zero()
+		return nil, err
+	}
+	uploadKey, err := a.saveUpload(entry, authorPub, readerPub, kek, eek)
+	if err != nil {
+		kek.Zero()
+		eek.Zero()
+		return nil, err
+	}
+	return &packedUpload{
+		startTime: startTime,
+		entry:     entry,
+		metadata:  metadata,
+		authorPub: authorPub,
+		readerPub: readerPub,
+		kek:       kek,
+		eek:       eek,
+		uploadKey: uploadKey,
+	}, nil
+}
+
+// normalizeMediaType validates the media type, returning it with a lower-case type, subtype, and
+// parameter names, or DefaultMediaType if it is empty.
+func normalizeMediaType(mediaType string) (string, error) {
+	if strings.TrimSpace(mediaType) == "" {
+		return DefaultMediaType, nil
+	}
+	mt, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return "", ErrInvalidMediaType
+	}
+	if slash := strings.Index(mt, "/"); slash <= 0 || slash == len(mt)-1 ||
+		strings.Count(mt, "/") != 1 {
+		return "", ErrInvalidMediaType
 	}
+	normalized := mime.FormatMediaType(mt, params)
+	if normalized == "" {
+		return "", ErrInvalidMediaType
+	}
+	return normalized, nil
+}
+
+// detectMediaType returns the given media type if it isn't empty or else the one sniffed from the
+// content, along with a reader of the whole content, including any bytes read for sniffing.
+func (a *Author) detectMediaType(content io.Reader, mediaType string) (io.Reader, string, error) {
+	if strings.TrimSpace(mediaType) != "" {
+		return content, mediaType, nil
+	}
+	content, sniffed, err := sniffMediaType(content)
+	if err != nil {
+		a.logger.Error("error reading content to detect media type", zap.Error(err))
+		return nil, "", err
+	}
+	a.logger.Debug("detected media type", zap.String(LoggerMediaType, sniffed))
+	return content, sniffed, nil
+}
+
+// sniffMediaType detects the media type of the content from its first sniffLen bytes, returning
+// a reader of the whole content that starts with those bytes. The media type is DefaultMediaType
+// if the content is nil or empty or http.DetectContentType is inconclusive.
+func sniffMediaType(content io.Reader) (io.Reader, string, error) {
+	if content == nil {
+		return nil, DefaultMediaType, nil
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	head = head[:n]
+	content = io.MultiReader(bytes.NewReader(head), content)
+	if n == 0 {
+		return content, DefaultMediaType, nil
+	}
+	return content, http.DetectContentType(head), nil
+}
+
+// shipUpload ships a packed upload and removes its resume state once done, emitting an
+// UploadCompleted or UploadFailed event.
+func (a *Author) shipUpload(upload *packedUpload, opts *UploadOpts) (*UploadResult, error) {
+	entryKey, err := api.GetKey(upload.entry)
+	if err != nil {
+		a.emit(&Event{Type: UploadFailed})
+		return nil, err
+	}
+	result, err := a.shipPackedUpload(upload, entryKey, opts)
+	if err != nil {
+		a.emit(&Event{Type: UploadFailed, EntryKey: entryKey})
+		return nil, err
+	}
+	a.emit(&Event{Type: UploadCompleted, EnvelopeKey: result.EnvelopeKey, EntryKey: entryKey})
+	return result, nil
+}
 
+// shipPackedUpload ships a packed upload with the given entry key and removes its resume state
+// once done.
+func (a *Author) shipPackedUpload(upload *packedUpload, entryKey id.ID, opts *UploadOpts) (
+	*UploadResult, error) {
 	a.logger.Debug("shipping entry",
-		zap.String(LoggerAuthorPub, fmt.Sprintf("%065x", authorPub)),
-		zap.String(LoggerReaderPub, fmt.Sprintf("%065x", readerPub)),
+		zap.String(LoggerAuthorPub, fmt.Sprintf("%065x", upload.authorPub)),
+		zap.String(LoggerReaderPub, fmt.Sprintf("%065x", upload.readerPub)),
+		zap.Stringer(LoggerUploadKey, upload.uploadKey),
 	)
-	env, envKey, err := a.shipper.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	if opts.skipExistingPages() {
+		if err := a.markExistingPages(upload.entry, upload.authorPub); err != nil {
+			return nil, err
+		}
+	}
+	progress := a.emitPages(PageShipped, nil, entryKey, opts.progress())
+	env, envKey, entryResult, err := a.shipper.ShipEntry(opts.context(), upload.entry,
+		upload.authorPub, upload.readerPub, upload.kek, upload.eek, opts.replication(), progress)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if err := a.completeUpload(upload.uploadKey, upload.entry, env); err != nil {
+		return nil, err
 	}
 
-	elapsedTime := time.Since(startTime)
-	entryKeyBytes := env.Contents.(*api.Document_Envelope).Envelope.EntryKey
-	uncompressedSize, _ := metadata.GetUncompressedSize()
-	ciphertextSize, _ := metadata.GetCiphertextSize()
+	elapsedTime := time.Since(upload.startTime)
+	uncompressedSize, _ := upload.metadata.GetUncompressedSize()
+	ciphertextSize, _ := upload.metadata.GetCiphertextSize()
 	speedMbps := float32(uncompressedSize) * 8 / float32(2<<20) / float32(elapsedTime.Seconds())
 	a.logger.Info("successfully uploaded document",
 		zap.Stringer(LoggerEnvelopeKey, envKey),
-		zap.Stringer(LoggerEntryKey, id.FromBytes(entryKeyBytes)),
+		zap.Stringer(LoggerEntryKey, entryKey),
 		zap.Uint64("original_size", uncompressedSize),
 		zap.String("original_size_human", humanize.Bytes(uncompressedSize)),
 		zap.Uint64("uploaded_size", ciphertextSize),
 		zap.String("uploaded_size_human", humanize.Bytes(ciphertextSize)),
 		zap.Float32("speed_Mbps", speedMbps),
+		zap.Uint32("n_replicas", entryResult.NReplicas),
+	)
+	return &UploadResult{
+		Envelope:       env,
+		EnvelopeKey:    envKey,
+		NReplicas:      entryResult.NReplicas,
+		ReplicaPeerIDs: entryResult.ReplicaPeerIDs,
+	}, nil
+}
+
+// markExistingPages records the pages of a packed entry that already exist in the libri network
+// as shipped, so shipping the entry skips them (c.f., UploadOpts.SkipExistingPages). Pages whose
+// existence can't be checked are shipped as usual.
+func (a *Author) markExistingPages(entry *api.Document, authorPub []byte) error {
+	pageKeys, err := api.GetEntryPageKeys(entry)
+	if err != nil {
+		return err
+	}
+	nExisting := 0
+	for _, pageKey := range pageKeys {
+		shippedKey := newShippedKey(authorPub, pageKey)
+		shipped, err := a.uploadSLD.Load(shippedKey)
+		if err != nil {
+			return err
+		}
+		if shipped != nil {
+			continue
+		}
+		exists, err := a.Exists(pageKey)
+		if err != nil {
+			a.logger.Info("unable to check whether page exists, shipping it",
+				zap.Stringer("page_key", pageKey),
+				zap.Error(err),
+			)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		if err := a.uploadSLD.Store(shippedKey, shippedValue); err != nil {
+			return err
+		}
+		nExisting++
+	}
+	a.logger.Debug("skipping existing pages", zap.Int("n_existing_pages", nExisting))
+	return nil
+}
+
+// ResumeUpload finishes shipping a previously failed upload with the given upload key, skipping
+// any pages already shipped. It returns the uploaded envelope and its key.
+func (a *Author) ResumeUpload(uploadKey id.ID, opts *UploadOpts) (*api.Document, id.ID, error) {
+	envelope, entry, err := a.loadUpload(uploadKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	kek, eek, err := a.getUploadKeys(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer kek.Zero()
+	defer eek.Zero()
+
+	a.logger.Debug("resuming entry shipment",
+		zap.Stringer(LoggerUploadKey, uploadKey),
+		zap.String(LoggerAuthorPub, fmt.Sprintf("%065x", envelope.AuthorPublicKey)),
+		zap.String(LoggerReaderPub, fmt.Sprintf("%065x", envelope.ReaderPublicKey)),
+	)
+	env, envKey, _, err := a.shipper.ShipEntry(opts.context(), entry, envelope.AuthorPublicKey,
+		envelope.ReaderPublicKey, kek, eek, opts.replication(), opts.progress())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.completeUpload(uploadKey, entry, env); err != nil {
+		return nil, nil, err
+	}
+
+	a.logger.Info("successfully resumed upload",
+		zap.Stringer(LoggerUploadKey, uploadKey),
+		zap.Stringer(LoggerEnvelopeKey, envKey),
+		zap.Stringer(LoggerEntryKey, id.FromBytes(envelope.EntryKey)),
 	)
 	return env, envKey, nil
 }
 
 // Download downloads, join, decrypts, and decompressed the content, writing it to a unified output
-// content writer.
-func (a *Author) Download(content io.Writer, envKey id.ID) error {
+// content writer. It returns the media type the content was uploaded with, so callers can set a
+// file extension or Content-Type.
+func (a *Author) Download(content io.Writer, envKey id.ID) (string, error) {
+	return a.DownloadWithContext(context.Background(), content, envKey)
+}
+
+// DownloadWithContext is like Download but stops requesting pages from librarians once the
+// context is done, in which case it returns ctx.Err().
+func (a *Author) DownloadWithContext(ctx context.Context, content io.Writer, envKey id.ID) (
+	string, error) {
+	return a.DownloadWithOpts(ctx, content, envKey, nil)
+}
+
+// DownloadWithOpts is like DownloadWithContext but with optional parameters. Nil opts are
+// equivalent to DownloadWithContext.
+func (a *Author) DownloadWithOpts(
+	ctx context.Context, content io.Writer, envKey id.ID, opts *DownloadOpts,
+) (string, error) {
+	a.emit(&Event{Type: DownloadStarted, EnvelopeKey: envKey})
+	mediaType, entryKey, err := a.download(ctx, content, envKey, opts)
+	if err != nil {
+		a.emit(&Event{Type: DownloadFailed, EnvelopeKey: envKey, EntryKey: entryKey})
+		return "", err
+	}
+	a.emit(&Event{Type: DownloadCompleted, EnvelopeKey: envKey, EntryKey: entryKey})
+	return mediaType, nil
+}
+
+// download downloads the document with the given envelope key, returning its media type and the
+// key of its entry, if it was received.
+func (a *Author) download(
+	ctx context.Context, content io.Writer, envKey id.ID, opts *DownloadOpts,
+) (string, id.ID, error) {
+	if opts != nil && opts.SearchConcurrency > api.MaxSearchConcurrency {
+		return "", nil, ErrSearchConcurrencyTooLarge
+	}
 	startTime := time.Now()
 	a.logger.Debug("receiving entry", zap.String(LoggerEnvelopeKey, envKey.String()))
-	entry, keys, err := a.receiver.ReceiveEntry(envKey)
+	progress := a.emitPages(PageReceived, envKey, nil, opts.progress())
+	entry, keys, err := a.receiver.ReceiveEntry(ctx, envKey, opts.search(), progress)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
+	defer keys.Zero()
 	entryKey, nPages, err := getEntryInfo(entry)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
 	a.logger.Debug("unpacking content",
 		zap.String(LoggerEntryKey, entryKey.String()),
 		zap.Int(LoggerNPages, nPages),
 	)
-	metadata, err := a.entryUnpacker.Unpack(content, entry, keys)
+	metadata, err := a.entryUnpacker.Unpack(ctx, content, entry, keys)
 	if err != nil {
-		return err
+		return "", entryKey, err
 	}
+	mediaType, _ := metadata.GetMediaType()
 
 	elapsedTime := time.Since(startTime)
 	uncompressedSize, _ := metadata.GetUncompressedSize()
@@ -293,14 +1083,25 @@ func (a *Author) Download(content io.Writer, envKey id.ID) error {
 		zap.String("downloaded_size", humanize.Bytes(ciphertextSize)),
 		zap.String("original_size", humanize.Bytes(uncompressedSize)),
 		zap.Float32("speed_Mbps", speedMbps),
+		zap.String(LoggerMediaType, mediaType),
 	)
-	return nil
+	return mediaType, entryKey, nil
 }
 
 // Share creates and uploads a new envelope with the given reader public key. The new envelope
 // has the same entry and entry encryption key as that of envelopeKey.
 func (a *Author) Share(envKey id.ID, readerPub *ecdsa.PublicKey) (*api.Document, id.ID, error) {
+	return a.ShareWithOpts(envKey, readerPub, nil)
+}
+
+// ShareWithOpts is like Share but with optional parameters. Nil opts are equivalent to Share.
+func (a *Author) ShareWithOpts(envKey id.ID, readerPub *ecdsa.PublicKey, opts *ShareOpts) (
+	*api.Document, id.ID, error) {
 
+	authorKeys, err := selectAuthorKeys(a.authorKeys, a.identities, opts.identity())
+	if err != nil {
+		return nil, nil, err
+	}
 	env, err := a.receiver.ReceiveEnvelope(envKey)
 	if err != nil {
 		return nil, nil, err
@@ -309,7 +1110,8 @@ func (a *Author) Share(envKey id.ID, readerPub *ecdsa.PublicKey) (*api.Document,
 	if err != nil {
 		return nil, nil, err
 	}
-	authorKey, err := a.authorKeys.Sample()
+	defer eek.Zero()
+	authorKey, err := authorKeys.Sample()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -317,12 +1119,17 @@ func (a *Author) Share(envKey id.ID, readerPub *ecdsa.PublicKey) (*api.Document,
 	if err != nil {
 		return nil, nil, err
 	}
+	defer kek.Zero()
 	entryKey := id.FromBytes(env.EntryKey)
 	authKeyBs, readKeyBs := authorKey.PublicKeyBytes(), ecid.ToPublicKeyBytes(readerPub)
-	sharedEnv, sharedEnvKey, err := a.shipper.ShipEnvelope(kek, eek, entryKey, authKeyBs, readKeyBs)
+	sharedEnv, sharedEnvKey, err := a.shipper.ShipEnvelope(kek, eek, entryKey, authKeyBs,
+		readKeyBs, nil)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := a.recordAudit(sharedEnv); err != nil {
+		return nil, nil, err
+	}
 
 	a.logger.Info("successfully shared document",
 		zap.Stringer(LoggerEntryKey, entryKey),
@@ -335,6 +1142,133 @@ func (a *Author) Share(envKey id.ID, readerPub *ecdsa.PublicKey) (*api.Document,
 	return sharedEnv, sharedEnvKey, nil
 }
 
+// Revoke re-encrypts the content of the envelope with the given key under a new, independently
+// sampled entry encryption key and uploads it as a new entry with a new envelope for oneself.
+// Readers of the existing envelope (or any envelope shared from it) cannot decrypt the new entry,
+// so it can be shared with only the readers who should retain access. Existing copies of the
+// original entry and envelopes remain readable by their readers; revoking only applies to the
+// new entry.
+func (a *Author) Revoke(envKey id.ID) (*api.Document, id.ID, error) {
+	a.logger.Debug("receiving entry", zap.String(LoggerEnvelopeKey, envKey.String()))
+	entry, oldEEK, err := a.receiver.ReceiveEntry(context.Background(), envKey, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := a.decryptEntryMetadata(entry, oldEEK)
+	if err != nil {
+		oldEEK.Zero()
+		return nil, nil, err
+	}
+	mediaType, _ := metadata.GetMediaType()
+	codec, _ := metadata.GetCompressionCodec()
+
+	// stream the content decrypted with the old EEK into a new upload with a new EEK
+	pr, pw := io.Pipe()
+	go func() {
+		// propagate any unpack error to the upload reading from pr
+		_, unpackErr := a.entryUnpacker.Unpack(context.Background(), pw, entry, oldEEK)
+		oldEEK.Zero()
+		if closeErr := pw.CloseWithError(unpackErr); closeErr != nil {
+			// should never happen
+			panic(closeErr)
+		}
+	}()
+	env, newEnvKey, err := a.UploadWithOpts(pr, mediaType, &UploadOpts{Codec: comp.Codec(codec)})
+	if closeErr := pr.Close(); closeErr != nil {
+		// should never happen
+		panic(closeErr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.logger.Info("successfully revoked document",
+		zap.Stringer(LoggerEnvelopeKey, envKey),
+		zap.Stringer("new_"+LoggerEnvelopeKey, newEnvKey),
+	)
+	return env, newEnvKey, nil
+}
+
+// decryptEntryMetadata decrypts the metadata of the entry with its EEK using the entry's scheme.
+func (a *Author) decryptEntryMetadata(entry *api.Document, eek *enc.EEK) (*api.Metadata, error) {
+	docEntry, ok := entry.Contents.(*api.Document_Entry)
+	if !ok {
+		return nil, api.ErrUnexpectedDocumentType
+	}
+	scheme, err := a.schemes.Get(enc.SchemeID(docEntry.Entry.EncryptionScheme))
+	if err != nil {
+		return nil, err
+	}
+	encMetadata, err := enc.NewEntryEncryptedMetadata(docEntry.Entry)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.Decrypt(encMetadata, eek)
+}
+
+// Locate returns the peers storing the document with the given key without downloading it. A key
+// stored nowhere gives an empty list of peers rather than an error.
+func (a *Author) Locate(key id.ID) ([]peer.Peer, error) {
+	lc, err := a.librarians.Next()
+	if err != nil {
+		return nil, err
+	}
+	rq := client.NewLocateRequest(a.clientID, key)
+	ctx, cancel, err := client.NewSignedTimeoutContext(a.signer, rq, a.config.Publish.GetTimeout)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := lc.Locate(ctx, rq)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(rq.Metadata.RequestId, rp.Metadata.RequestId) {
+		return nil, client.ErrUnexpectedRequestID
+	}
+	fromer := peer.NewFromer()
+	holders := make([]peer.Peer, len(rp.Peers))
+	for i, pa := range rp.Peers {
+		holders[i] = fromer.FromAPI(pa)
+	}
+	a.logger.Debug("located document",
+		zap.Stringer("key", key),
+		zap.Int("n_peers", len(holders)),
+	)
+	return holders, nil
+}
+
+// Exists returns whether the document with the given key is stored in libri without downloading
+// it. Since documents are content-addressed, this lets callers that already hold a document skip
+// re-downloading it. Librarians that predate exists-only requests respond with the document
+// itself, which also confirms it exists.
+func (a *Author) Exists(key id.ID) (bool, error) {
+	lc, err := a.librarians.Next()
+	if err != nil {
+		return false, err
+	}
+	rq := client.NewGetRequest(a.clientID, key)
+	rq.ExistsOnly = true
+	ctx, cancel, err := client.NewSignedTimeoutContext(a.signer, rq, a.config.Publish.GetTimeout)
+	if err != nil {
+		return false, err
+	}
+	rp, err := lc.Get(ctx, rq)
+	cancel()
+	if err != nil {
+		return false, api.FromRPCError(err)
+	}
+	if !bytes.Equal(rq.Metadata.RequestId, rp.Metadata.RequestId) {
+		return false, client.ErrUnexpectedRequestID
+	}
+	exists := rp.Exists || rp.Value != nil
+	a.logger.Debug("checked document exists",
+		zap.Stringer("key", key),
+		zap.Bool("exists", exists),
+	)
+	return exists, nil
+}
+
 func getEntryInfo(entry *api.Document) (id.ID, int, error) {
 	entryKey, err := api.GetKey(entry)
 	if err != nil {
@@ -10,10 +10,14 @@ import (
 	"testing"
 	"errors"
 	"github.com/drausin/libri/libri/author/io/common"
+	"github.com/drausin/libri/libri/author/io/comp"
 	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/author/io/pack"
 	"github.com/drausin/libri/libri/author/io/page"
 	"github.com/drausin/libri/libri/author/io/publish"
 	"github.com/drausin/libri/libri/author/io/ship"
+	"github.com/drausin/libri/libri/common/clock"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/id"
 	clogging "github.com/drausin/libri/libri/common/logging"
 	"github.com/drausin/libri/libri/librarian/api"
@@ -22,11 +26,14 @@ import (
 	"go.uber.org/zap/zapcore"
 	"net"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"golang.org/x/net/context"
 	"github.com/drausin/libri/libri/author/keychain"
 	"github.com/drausin/libri/libri/common/ecid"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"time"
+	"github.com/drausin/libri/libri/librarian/client"
 )
 
 const (
@@ -36,15 +43,18 @@ const (
 func TestNewAuthor(t *testing.T) {
 	// return empty map of health clients
 	orig := getLibrarianHealthClients
-	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr) (
-		map[string]healthpb.HealthClient, error) {
+	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr,
+		creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+	) (map[string]healthpb.HealthClient, error) {
 		return make(map[string]healthpb.HealthClient), nil
 	}
 	defer func() { getLibrarianHealthClients = orig }()
 
-	a1 := newTestAuthor()
+	// check client ID persists in an on-disk DB across restarts
+	a1 := newTestAuthorWithConfig(newTestConfig().WithDBBackend(db.RocksDBBackend))
 
-	clientID1 := a1.clientID
+	clientID1 := a1.ID()
+	assert.Zero(t, a1.config.Publish.MaxInFlight) // check default isn't written back to config
 	err := a1.Close()
 	assert.Nil(t, err)
 
@@ -56,7 +66,41 @@ func TestNewAuthor(t *testing.T) {
 	)
 
 	assert.Nil(t, err)
-	assert.Equal(t, clientID1, a2.clientID)
+	assert.Equal(t, clientID1, a2.ID())
+
+	// check *Author can be used as an AuthorClient
+	var client AuthorClient = a2
+	assert.NotNil(t, client)
+	assert.NotNil(t, a2.Metrics())
+
+	err = a2.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_KeyUsageCounts(t *testing.T) {
+	// check counts are nil when not configured
+	a1 := newTestAuthor()
+	assert.Nil(t, a1.KeyUsageCounts())
+	err := a1.CloseAndRemove()
+	assert.Nil(t, err)
+
+	config := newTestConfig().WithCountKeyUsage(true)
+	authorKeys, selfReaderKeys := keychain.New(nInitialKeys), keychain.New(nInitialKeys)
+	a2, err := NewAuthor(config, authorKeys, selfReaderKeys, clogging.NewDevInfoLogger())
+	assert.Nil(t, err)
+
+	// check each envelope key sample counts one author and one self reader key
+	nSamples := 8
+	for c := 0; c < nSamples; c++ {
+		_, _, _, _, err = a2.envKeys.sample(DefaultIdentity)
+		assert.Nil(t, err)
+	}
+	total := 0
+	for _, n := range a2.KeyUsageCounts() {
+		total += n
+	}
+	assert.Equal(t, 2*nSamples, total)
+
 	err = a2.CloseAndRemove()
 	assert.Nil(t, err)
 }
@@ -64,8 +108,9 @@ func TestNewAuthor(t *testing.T) {
 func TestAuthor_Healthcheck_ok(t *testing.T) {
 	// return fixed map of health clients
 	orig := getLibrarianHealthClients
-	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr) (
-		map[string]healthpb.HealthClient, error) {
+	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr,
+		creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+	) (map[string]healthpb.HealthClient, error) {
 		return map[string]healthpb.HealthClient{
 			"peerAddr1": &fixedHealthClient{
 				response: &healthpb.HealthCheckResponse{
@@ -82,19 +127,31 @@ func TestAuthor_Healthcheck_ok(t *testing.T) {
 	defer func() { getLibrarianHealthClients = orig }()
 
 	a := newTestAuthor()
+	latencyObs := &fixedLatencyObserver{latencies: make(map[string]time.Duration)}
+	a.librarians = latencyObs
 
-	allHealthy, healthStatus := a.Healthcheck()
+	allHealthy, healthStatus, healthLatency := a.Healthcheck()
 	assert.False(t, allHealthy)
 	assert.Equal(t, 2, len(healthStatus))
 	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, healthStatus["peerAddr1"])
 	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, healthStatus["peerAddr2"])
+	assert.Equal(t, 2, len(healthLatency))
+	_, in := healthLatency["peerAddr1"]
+	assert.True(t, in)
+	_, in = healthLatency["peerAddr2"]
+	assert.True(t, in)
+
+	// check latencies are fed to balancer, with unhealthy peer treated as timed out
+	assert.Equal(t, healthLatency["peerAddr1"], latencyObs.latencies["peerAddr1"])
+	assert.Equal(t, a.config.HealthcheckTimeout, latencyObs.latencies["peerAddr2"])
 }
 
 func TestAuthor_Healthcheck_err(t *testing.T) {
 	// return fixed map of health clients
 	orig := getLibrarianHealthClients
-	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr) (
-		map[string]healthpb.HealthClient, error) {
+	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr,
+		creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+	) (map[string]healthpb.HealthClient, error) {
 		return map[string]healthpb.HealthClient{
 			"peerAddr1": &fixedHealthClient{
 				err: errors.New("some Check error"),
@@ -105,10 +162,119 @@ func TestAuthor_Healthcheck_err(t *testing.T) {
 
 	a := newTestAuthor()
 
-	allHealthy, healthStatus := a.Healthcheck()
+	allHealthy, healthStatus, healthLatency := a.Healthcheck()
 	assert.False(t, allHealthy)
 	assert.Equal(t, 1, len(healthStatus))
 	assert.Equal(t, healthpb.HealthCheckResponse_UNKNOWN, healthStatus["peerAddr1"])
+	assert.Equal(t, 1, len(healthLatency))
+}
+
+func TestAuthor_Healthcheck_parallel(t *testing.T) {
+	nPeers, delay := 4*healthcheckParallelism, 50*time.Millisecond
+	orig := getLibrarianHealthClients
+	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr,
+		creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+	) (map[string]healthpb.HealthClient, error) {
+		healthClients := make(map[string]healthpb.HealthClient)
+		for i := 0; i < nPeers; i++ {
+			healthClients[fmt.Sprintf("peerAddr%d", i)] = &fixedHealthClient{
+				response: &healthpb.HealthCheckResponse{
+					Status: healthpb.HealthCheckResponse_SERVING,
+				},
+				delay: delay,
+			}
+		}
+		return healthClients, nil
+	}
+	defer func() { getLibrarianHealthClients = orig }()
+
+	a := newTestAuthor()
+
+	startTime := time.Now()
+	allHealthy, healthStatus, healthLatency := a.Healthcheck()
+	elapsed := time.Since(startTime)
+	assert.True(t, allHealthy)
+	assert.Equal(t, nPeers, len(healthStatus))
+	assert.Equal(t, nPeers, len(healthLatency))
+	for _, latency := range healthLatency {
+		assert.True(t, latency >= delay)
+	}
+
+	// check checks ran in parallel rather than serially
+	assert.True(t, elapsed < time.Duration(nPeers)*delay)
+
+	err := a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_Healthcheck_deadline(t *testing.T) {
+	slowDelay := 2 * time.Second
+	orig := getLibrarianHealthClients
+	getLibrarianHealthClients = func(librarianAddrs []*net.TCPAddr,
+		creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+	) (map[string]healthpb.HealthClient, error) {
+		return map[string]healthpb.HealthClient{
+			"fastAddr": &fixedHealthClient{
+				response: &healthpb.HealthCheckResponse{
+					Status: healthpb.HealthCheckResponse_SERVING,
+				},
+			},
+			"slowAddr": &fixedHealthClient{ // ignores its context, so only the deadline stops it
+				response: &healthpb.HealthCheckResponse{
+					Status: healthpb.HealthCheckResponse_SERVING,
+				},
+				delay: slowDelay,
+			},
+			"hangingAddr": &hangingHealthClient{},
+			"unreachableAddr": &fixedHealthClient{
+				err: errors.New("some Check error"),
+			},
+		}, nil
+	}
+	defer func() { getLibrarianHealthClients = orig }()
+
+	config := newTestConfig().
+		WithHealthcheckTimeout(50 * time.Millisecond).
+		WithHealthcheckDeadline(200 * time.Millisecond)
+	a := newTestAuthorWithConfig(config)
+
+	startTime := time.Now()
+	allHealthy, healthStatus, healthLatency := a.Healthcheck()
+	elapsed := time.Since(startTime)
+	assert.False(t, allHealthy)
+	assert.Equal(t, 4, len(healthStatus))
+	assert.Equal(t, 4, len(healthLatency))
+
+	// check fast librarian is healthy and others are unknown
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, healthStatus["fastAddr"])
+	assert.Equal(t, healthpb.HealthCheckResponse_UNKNOWN, healthStatus["slowAddr"])
+	assert.Equal(t, healthpb.HealthCheckResponse_UNKNOWN, healthStatus["hangingAddr"])
+	assert.Equal(t, healthpb.HealthCheckResponse_UNKNOWN, healthStatus["unreachableAddr"])
+
+	// check hanging librarian is cut off by the per-librarian timeout and slow one by deadline
+	assert.True(t, healthLatency["hangingAddr"] >= config.HealthcheckTimeout)
+	assert.True(t, healthLatency["hangingAddr"] < config.HealthcheckDeadline)
+	assert.True(t, healthLatency["slowAddr"] >= config.HealthcheckDeadline)
+	assert.True(t, elapsed < slowDelay)
+
+	err := a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestCheckHealth_timeout(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	results := make(chan *healthcheckResult, 1)
+	go func() {
+		results <- checkHealth(context.Background(), fake, "peerAddr1", &hangingHealthClient{},
+			DefaultHealthcheckTimeout)
+	}()
+
+	// check the check times out once the fake clock passes the healthcheck timeout
+	fake.BlockUntil(1)
+	fake.Advance(DefaultHealthcheckTimeout)
+	result := <-results
+	assert.NotNil(t, result.err)
+	assert.Equal(t, DefaultHealthcheckTimeout, result.latency)
 }
 
 func TestAuthor_Upload_ok(t *testing.T) {
@@ -122,11 +288,14 @@ func TestAuthor_Upload_ok(t *testing.T) {
 		api.RandBytes(rng, 32),
 	)
 	assert.Nil(t, err)
-	a.entryPacker = &fixedEntryPacker{
+	entry, _ := api.NewTestDocument(rng)
+	entryPacker := &fixedEntryPacker{
+		entry:    entry,
 		metadata: metadata,
 	}
+	a.entryPacker = entryPacker
 	expectedEnvKey := id.NewPseudoRandom(rng)
-	a.shipper = &fixedShipper{
+	shipper := &fixedShipper{
 		envelope: &api.Document{
 			Contents: &api.Document_Envelope{
 				Envelope: api.NewTestEnvelope(rng),
@@ -134,32 +303,111 @@ func TestAuthor_Upload_ok(t *testing.T) {
 		},
 		envelopeKey: expectedEnvKey,
 	}
+	a.shipper = shipper
 
 	// since everything is mocked, inputs don't really matter
 	actualEnvelope, actualEnvelopeKey, err := a.Upload(nil, "")
 	assert.Nil(t, err)
 	assert.NotNil(t, actualEnvelope)
 	assert.Equal(t, expectedEnvKey, actualEnvelopeKey)
+	assert.Nil(t, shipper.repl)
+
+	// check number of replicas is passed to shipper
+	_, _, err = a.UploadWithOpts(nil, "", &UploadOpts{NReplicas: 6, MinNReplicas: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, &publish.Replication{NReplicas: 6, MinNReplicas: 4}, shipper.repl)
+	assert.True(t, entryPacker.expiry.IsZero())
+
+	// check expiry is passed to packer
+	expiry := time.Now().Add(time.Hour)
+	_, _, err = a.UploadWithOpts(nil, "", &UploadOpts{Expiry: expiry})
+	assert.Nil(t, err)
+	assert.Equal(t, expiry, entryPacker.expiry)
+
+	// check normalized media type is passed to packer
+	_, _, err = a.Upload(nil, "Text/HTML; Charset=UTF-8")
+	assert.Nil(t, err)
+	assert.Equal(t, "text/html; charset=UTF-8", entryPacker.mediaType)
+
+	// check empty media type defaults
+	_, _, err = a.Upload(nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultMediaType, entryPacker.mediaType)
+
+	// check empty media type is detected from content
+	_, _, err = a.Upload(bytes.NewReader([]byte("<html><body>hi</body></html>")), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", entryPacker.mediaType)
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_UploadWithResult_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	metadata, err := api.NewEntryMetadata(
+		"application/x-pdf",
+		1,
+		api.RandBytes(rng, 32),
+		2,
+		api.RandBytes(rng, 32),
+	)
+	assert.Nil(t, err)
+	entry, _ := api.NewTestDocument(rng)
+	a.entryPacker = &fixedEntryPacker{
+		entry:    entry,
+		metadata: metadata,
+	}
+	expectedEnvKey := id.NewPseudoRandom(rng)
+	replicaPeerIDs := []id.ID{id.NewPseudoRandom(rng), id.NewPseudoRandom(rng)}
+	a.shipper = &fixedShipper{
+		envelope: &api.Document{
+			Contents: &api.Document_Envelope{
+				Envelope: api.NewTestEnvelope(rng),
+			},
+		},
+		envelopeKey: expectedEnvKey,
+		result:      &publish.Result{NReplicas: 2, ReplicaPeerIDs: replicaPeerIDs},
+	}
+
+	// check entry store result is returned with the envelope
+	result, err := a.UploadWithResult(nil, "", nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, result.Envelope)
+	assert.Equal(t, expectedEnvKey, result.EnvelopeKey)
+	assert.Equal(t, uint32(2), result.NReplicas)
+	assert.Equal(t, replicaPeerIDs, result.ReplicaPeerIDs)
 
 	err = a.CloseAndRemove()
 	assert.Nil(t, err)
 }
 
 func TestAuthor_Upload_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
 	a := newTestAuthor()
-	a.entryPacker = &fixedEntryPacker{err: errors.New("some Pack error")}
+	entryPacker := &fixedEntryPacker{err: errors.New("some Pack error")}
+	a.entryPacker = entryPacker
 	a.shipper = &fixedShipper{}
 
+	// check invalid media type errors before packing
+	actualEnvelope, actualEnvelopeKey, err := a.Upload(nil, "not a media type")
+	assert.Equal(t, ErrInvalidMediaType, err)
+	assert.Nil(t, actualEnvelope)
+	assert.Nil(t, actualEnvelopeKey)
+	assert.Empty(t, entryPacker.mediaType)
+
 	// check pack error bubbles up
-	actualEnvelope, actualEnvelopeKey, err := a.Upload(nil, "")
+	actualEnvelope, actualEnvelopeKey, err = a.Upload(nil, "")
 	assert.NotNil(t, err)
 	assert.Nil(t, actualEnvelope)
 	assert.Nil(t, actualEnvelopeKey)
 
-	a.entryPacker = &fixedEntryPacker{}
+	entry, _ := api.NewTestDocument(rng)
+	a.entryPacker = &fixedEntryPacker{entry: entry}
 	a.shipper = &fixedShipper{err: errors.New("some Ship error")}
 
-	// check pack error bubbles up
+	// check ship error bubbles up
 	actualEnvelope, actualEnvelopeKey, err = a.Upload(nil, "")
 	assert.NotNil(t, err)
 	assert.Nil(t, actualEnvelope)
@@ -169,6 +417,77 @@ func TestAuthor_Upload_err(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestNormalizeMediaType_ok(t *testing.T) {
+	cases := map[string]string{
+		"":                                DefaultMediaType,
+		"  ":                              DefaultMediaType,
+		"application/x-pdf":               "application/x-pdf",
+		"Application/JSON":                "application/json",
+		"text/plain; Charset=utf-8":       "text/plain; charset=utf-8",
+		"image/svg+xml":                   "image/svg+xml",
+		"application/vnd.api+json; v=1.0": "application/vnd.api+json; v=1.0",
+	}
+	for in, expected := range cases {
+		actual, err := normalizeMediaType(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, expected, actual, in)
+	}
+}
+
+func TestNormalizeMediaType_err(t *testing.T) {
+	cases := []string{
+		"text",                // missing subtype
+		"text/",               // empty subtype
+		"/plain",              // empty type
+		"text/plain/extra",    // too many parts
+		"text/plain; charset", // malformed parameter
+		"text plain",          // space
+		"not a media type",
+	}
+	for _, c := range cases {
+		actual, err := normalizeMediaType(c)
+		assert.Equal(t, ErrInvalidMediaType, err, c)
+		assert.Empty(t, actual, c)
+	}
+}
+
+func TestSniffMediaType_ok(t *testing.T) {
+	binary := bytes.Repeat([]byte{0, 1, 2, 3}, sniffLen/2) // longer than sniffLen
+	cases := []struct {
+		content  []byte
+		expected string
+	}{
+		{[]byte("<html><body>hi</body></html>"), "text/html; charset=utf-8"},
+		{[]byte("\x89PNG\x0D\x0A\x1A\x0A some image"), "image/png"},
+		{[]byte("%PDF-1.4 some pdf"), "application/pdf"},
+		{binary, DefaultMediaType},
+		{[]byte{}, DefaultMediaType},
+	}
+	for _, c := range cases {
+		content, mediaType, err := sniffMediaType(bytes.NewReader(c.content))
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, mediaType)
+
+		// check sniffed bytes are still in content
+		read, err := ioutil.ReadAll(content)
+		assert.Nil(t, err)
+		assert.Equal(t, c.content, read)
+	}
+
+	// check nil content defaults
+	content, mediaType, err := sniffMediaType(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, content)
+	assert.Equal(t, DefaultMediaType, mediaType)
+}
+
+func TestSniffMediaType_err(t *testing.T) {
+	content, mediaType, err := sniffMediaType(&errReader{err: errors.New("some Read error")})
+	assert.NotNil(t, err)
+	assert.Nil(t, content)
+	assert.Empty(t, mediaType)
+}
+
 func TestAuthor_Download_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	doc, docKey := api.NewTestDocument(rng)
@@ -180,13 +499,18 @@ func TestAuthor_Download_ok(t *testing.T) {
 		api.RandBytes(rng, 32),
 	)
 	assert.Nil(t, err)
+	keys := enc.NewPseudoRandomEEK(rng)
 	a := &Author{
 		logger:        clogging.NewDevInfoLogger(),
-		receiver:      &fixedReceiver{entry: doc},
+		receiver:      &fixedReceiver{entry: doc, keys: keys},
 		entryUnpacker: &fixedUnpacker{metadata: metadata},
 	}
-	err = a.Download(nil, docKey)
+	mediaType, err := a.Download(nil, docKey)
 	assert.Nil(t, err)
+	assert.Equal(t, "application/x-pdf", mediaType)
+
+	// check EEK is zeroed after downloading
+	assert.Equal(t, make([]byte, api.EEKLength), enc.MarshalEEK(keys))
 }
 
 func TestAuthor_Download_err(t *testing.T) {
@@ -199,7 +523,7 @@ func TestAuthor_Download_err(t *testing.T) {
 		receiver:      &fixedReceiver{receiveEntryErr: errors.New("some Receive error")},
 		entryUnpacker: &fixedUnpacker{},
 	}
-	err := a1.Download(nil, docKey)
+	_, err := a1.Download(nil, docKey)
 	assert.NotNil(t, err)
 
 	// check Unpack error bubbles up
@@ -208,10 +532,72 @@ func TestAuthor_Download_err(t *testing.T) {
 		receiver:      &fixedReceiver{entry: doc},
 		entryUnpacker: &fixedUnpacker{err: errors.New("some Unpack error")},
 	}
-	err = a2.Download(nil, docKey)
+	_, err = a2.Download(nil, docKey)
 	assert.NotNil(t, err)
 }
 
+func TestAuthor_DownloadWithOpts_searchConcurrency(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	doc, docKey := api.NewTestDocument(rng)
+	metadata, err := api.NewEntryMetadata("application/x-pdf", 1, api.RandBytes(rng, 32), 2,
+		api.RandBytes(rng, 32))
+	assert.Nil(t, err)
+	rec := &fixedReceiver{entry: doc, keys: enc.NewPseudoRandomEEK(rng)}
+	a := &Author{
+		logger:        clogging.NewDevInfoLogger(),
+		receiver:      rec,
+		entryUnpacker: &fixedUnpacker{metadata: metadata},
+	}
+
+	// check zero search concurrency uses librarians' default
+	_, err = a.DownloadWithOpts(context.Background(), nil, docKey, &DownloadOpts{})
+	assert.Nil(t, err)
+	assert.Nil(t, rec.search)
+
+	// check search concurrency is passed to receiver
+	opts := &DownloadOpts{SearchConcurrency: 8}
+	_, err = a.DownloadWithOpts(context.Background(), nil, docKey, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, &publish.Search{Concurrency: 8}, rec.search)
+
+	// check too large search concurrency errors
+	opts = &DownloadOpts{SearchConcurrency: api.MaxSearchConcurrency + 1}
+	_, err = a.DownloadWithOpts(context.Background(), nil, docKey, opts)
+	assert.Equal(t, ErrSearchConcurrencyTooLarge, err)
+}
+
+func TestAuthor_DownloadWithContext_canceled(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	_, envelopeKey, err := a.Upload(common.NewCompressableBytes(rng, 1024), "application/x-gzip")
+	assert.Nil(t, err)
+
+	// check that canceled context stops download
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	content := new(bytes.Buffer)
+	_, err = a.DownloadWithContext(ctx, content, envelopeKey)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, content.Len())
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
 func TestAuthor_UploadDownload(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	a := newTestAuthor()
@@ -221,8 +607,277 @@ func TestAuthor_UploadDownload(t *testing.T) {
 	pubAcq := &memPublisherAcquirer{
 		docs: make(map[string]*api.Document),
 	}
-
-	// but need to re-init shipper & reciever via publishers/acquirers
+
+	// but need to re-init shipper & reciever via publishers/acquirers
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	pageSizes := []uint32{128, 256, 512}
+	uncompressedSizes := []int{128, 192, 256, 384, 512, 768, 1024, 2048}
+	mediaTypes := []string{"application/x-pdf", "application/x-gzip"}
+	codecs := []comp.Codec{comp.AutoCodec, comp.NoneCodec, comp.GZIPCodec}
+	cases := caseCrossProduct(pageSizes, uncompressedSizes, mediaTypes)
+
+	for i, c := range cases {
+		a.config.Print.PageSize = c.pageSize
+
+		content1 := common.NewCompressableBytes(rng, c.uncompressedSize)
+		content1Bytes := content1.Bytes()
+
+		var upPagesDone, upPagesTotal, downPagesDone, downPagesTotal int
+		upOpts := &UploadOpts{
+			Progress: func(pagesDone, pagesTotal int, bytesDone uint64) {
+				upPagesDone, upPagesTotal = pagesDone, pagesTotal
+			},
+			Codec: codecs[i%len(codecs)],
+		}
+		envelope, envelopeKey, err := a.UploadWithOpts(content1, c.mediaType, upOpts)
+		assert.Nil(t, err)
+		assert.NotNil(t, envelope)
+		assert.NotNil(t, envelopeKey)
+
+		content2 := new(bytes.Buffer)
+		downOpts := &DownloadOpts{
+			Progress: func(pagesDone, pagesTotal int, bytesDone uint64) {
+				downPagesDone, downPagesTotal = pagesDone, pagesTotal
+			},
+		}
+		mediaType, err := a.DownloadWithOpts(context.Background(), content2, envelopeKey,
+			downOpts)
+		assert.Nil(t, err)
+		assert.Equal(t, c.mediaType, mediaType)
+
+		// check progress was reported through the last page
+		assert.True(t, upPagesTotal > 0)
+		assert.Equal(t, upPagesTotal, upPagesDone)
+		assert.Equal(t, upPagesTotal, downPagesTotal)
+		assert.Equal(t, downPagesTotal, downPagesDone)
+
+		// check content1 == content1 --> Upload --> Download
+		assert.Equal(t, content1Bytes, content2.Bytes())
+	}
+
+	err := a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_UploadAtDownload(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+
+	// just mock interaction with libri network
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	uncompressedSizes := []int{0, 128, 192, 1024}
+	mediaTypes := []string{"application/x-pdf", "application/x-gzip", ""}
+
+	for _, uncompressedSize := range uncompressedSizes {
+		for _, mediaType := range mediaTypes {
+			content1Bytes := common.NewCompressableBytes(rng, uncompressedSize).Bytes()
+			envelope, envelopeKey, err := a.UploadAt(bytes.NewReader(content1Bytes),
+				int64(uncompressedSize), mediaType)
+			assert.Nil(t, err)
+			assert.NotNil(t, envelope)
+			assert.NotNil(t, envelopeKey)
+
+			// check content1 == content1 --> UploadAt --> Download
+			content2 := new(bytes.Buffer)
+			_, err = a.Download(content2, envelopeKey)
+			assert.Nil(t, err)
+			assert.Equal(t, content1Bytes, content2.Bytes())
+		}
+	}
+
+	// check negative size errors
+	envelope, envelopeKey, err := a.UploadAt(bytes.NewReader(nil), -1, "")
+	assert.Equal(t, ErrNegativeContentSize, err)
+	assert.Nil(t, envelope)
+	assert.Nil(t, envelopeKey)
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_UploadDownload_identity(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+
+	// just mock interaction with libri network
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	workKeys := keychain.New(1)
+	assert.Nil(t, a.AddIdentity("work", workKeys))
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	content1 := common.NewCompressableBytes(rng, 1024)
+	content1Bytes := content1.Bytes()
+	env, envKey, err := a.UploadWithOpts(content1, "application/x-pdf",
+		&UploadOpts{Identity: "work"})
+	assert.Nil(t, err)
+
+	// check envelope is authored by the selected identity's keychain
+	authorPub := env.Contents.(*api.Document_Envelope).Envelope.AuthorPublicKey
+	_, in := workKeys.Get(authorPub)
+	assert.True(t, in)
+	_, in = a.authorKeys.Get(authorPub)
+	assert.False(t, in)
+
+	// check it still downloads with the self reader keys
+	content2 := new(bytes.Buffer)
+	_, err = a.Download(content2, envKey)
+	assert.Nil(t, err)
+	assert.Equal(t, content1Bytes, content2.Bytes())
+
+	// check unknown identity errors
+	env, envKey, err = a.UploadWithOpts(common.NewCompressableBytes(rng, 1024),
+		"application/x-pdf", &UploadOpts{Identity: "unknown"})
+	assert.Equal(t, ErrUnknownIdentity, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envKey)
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_UploadStreamingDownload(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+
+	// just mock interaction with libri network
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+
+	// re-init shipper, receiver, and unpacker to acquire pages while scanning them
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewStreamingReceiver(a.librarians, a.selfReaderKeys, pubAcq,
+		msAcquirer, a.documentSLD)
+	pageL := page.NewStreamingStorerLoader(a.documentSLD, msAcquirer, a.librarians,
+		a.config.Publish.GetParallelism, nil)
+	a.entryUnpacker = pack.NewEntryUnpackerWithLoader(a.config.Print, enc.NewSchemes(), pageL)
+
+	page.MinSize = 64 // just for testing
+	pageSizes := []uint32{128, 512}
+	uncompressedSizes := []int{128, 1024, 2048}
+	mediaTypes := []string{"application/x-pdf", "application/x-gzip"}
+	cases := caseCrossProduct(pageSizes, uncompressedSizes, mediaTypes)
+
+	for _, c := range cases {
+		a.config.Print.PageSize = c.pageSize
+
+		content1 := common.NewCompressableBytes(rng, c.uncompressedSize)
+		content1Bytes := content1.Bytes()
+		envelope, envelopeKey, err := a.Upload(content1, c.mediaType)
+		assert.Nil(t, err)
+		assert.NotNil(t, envelope)
+
+		// check content1 == content1 --> Upload --> streaming Download
+		content2 := new(bytes.Buffer)
+		_, err = a.Download(content2, envelopeKey)
+		assert.Nil(t, err)
+		assert.Equal(t, content1Bytes, content2.Bytes())
+	}
+
+	err := a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_Upload_pageSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 512
+	contentBytes := common.NewCompressableBytes(rng, 2048).Bytes()
+
+	// check same content uploaded at two page sizes downloads to the same content
+	nPages := make([]int, 0, 2)
+	for _, pageSize := range []uint32{128, 256} {
+		opts := &UploadOpts{PageSize: pageSize, Codec: comp.NoneCodec}
+		env, envKey, err := a.UploadWithOpts(bytes.NewReader(contentBytes), "application/x-pdf",
+			opts)
+		assert.Nil(t, err)
+		entryKey := id.FromBytes(env.Contents.(*api.Document_Envelope).Envelope.EntryKey)
+		_, n, err := getEntryInfo(pubAcq.docs[entryKey.String()])
+		assert.Nil(t, err)
+		nPages = append(nPages, n)
+
+		content := new(bytes.Buffer)
+		_, err = a.Download(content, envKey)
+		assert.Nil(t, err)
+		assert.Equal(t, contentBytes, content.Bytes())
+	}
+	assert.True(t, nPages[0] > nPages[1])
+
+	// check invalid page size bubbles up
+	opts := &UploadOpts{PageSize: page.MaxSize + 1}
+	env, envKey, err := a.UploadWithOpts(bytes.NewReader(contentBytes), "application/x-pdf",
+		opts)
+	assert.Equal(t, page.ErrPageSizeTooLarge, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envKey)
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_Upload_encryptionScheme(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	scheme := &fixedScheme{Scheme: enc.NewAESGCMScheme(), id: 7}
+	a := newTestAuthorWithConfig(newTestConfig().WithEncryptionScheme(scheme))
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	a.librarians = &fixedClientBalancer{}
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
 	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
 	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
 	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
@@ -230,34 +885,228 @@ func TestAuthor_UploadDownload(t *testing.T) {
 	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
 	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
 		a.documentSLD)
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	contentBytes := common.NewCompressableBytes(rng, 1024).Bytes()
+
+	// check configured scheme is recorded in the uploaded entry and used to download it
+	env, envKey, err := a.Upload(bytes.NewReader(contentBytes), "application/x-pdf")
+	assert.Nil(t, err)
+	entryKey := id.FromBytes(env.Contents.(*api.Document_Envelope).Envelope.EntryKey)
+	entry := pubAcq.docs[entryKey.String()]
+	assert.Equal(t, uint32(7), entry.Contents.(*api.Document_Entry).Entry.EncryptionScheme)
+	content := new(bytes.Buffer)
+	_, err = a.Download(content, envKey)
+	assert.Nil(t, err)
+	assert.Equal(t, contentBytes, content.Bytes())
+}
 
+func TestAuthor_UploadWithOpts_skipExistingPages(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	a.librarians = &fixedClientBalancer{client: &memExistsClient{pubAcq: pubAcq}}
+	pub := &flakyPublisher{
+		inner:     pubAcq,
+		published: make(map[string]int),
+		nMax:      1024,
+	}
+	slPublisher := newResumingPublisher(
+		publish.NewSingleLoadPublisher(pub, a.documentSLD),
+		a.documentSLD,
+		a.uploadSLD,
+	)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pub, mlPublisher)
 	page.MinSize = 64 // just for testing
-	pageSizes := []uint32{128, 256, 512}
-	uncompressedSizes := []int{128, 192, 256, 384, 512, 768, 1024, 2048}
-	mediaTypes := []string{"application/x-pdf", "application/x-gzip"}
-	cases := caseCrossProduct(pageSizes, uncompressedSizes, mediaTypes)
+	a.config.Print.PageSize = 128
+	contentBytes := common.NewCompressableBytes(rng, 1024).Bytes()
+	eek := enc.NewPseudoRandomEEK(rng)
+	eekBytes := enc.MarshalEEK(eek)
+	opts := &UploadOpts{EEK: eek, SkipExistingPages: true}
+	nPublished := func() int {
+		n := 0
+		for _, c := range pub.published {
+			n += c
+		}
+		return n
+	}
 
-	for _, c := range cases {
-		a.config.Print.PageSize = c.pageSize
+	// check first upload ships all its pages, entry, and envelope
+	_, _, err := a.UploadWithOpts(bytes.NewReader(contentBytes), "application/x-pdf", opts)
+	assert.Nil(t, err)
+	n1 := nPublished()
+	assert.True(t, n1 > 3)
 
-		content1 := common.NewCompressableBytes(rng, c.uncompressedSize)
-		content1Bytes := content1.Bytes()
+	// check given EEK isn't zeroed with the upload's
+	assert.Equal(t, eekBytes, enc.MarshalEEK(eek))
 
-		envelope, envelopeKey, err := a.Upload(content1, c.mediaType)
+	// check second upload of same content with same EEK ships just its entry and envelope
+	_, _, err = a.UploadWithOpts(bytes.NewReader(contentBytes), "application/x-pdf", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, n1+2, nPublished())
+
+	// check pages are shipped again without SkipExistingPages
+	opts.SkipExistingPages = false
+	_, _, err = a.UploadWithOpts(bytes.NewReader(contentBytes), "application/x-pdf", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*n1+2, nPublished())
+}
+
+func TestAuthor_markExistingPages(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
 		assert.Nil(t, err)
-		assert.NotNil(t, envelope)
-		assert.NotNil(t, envelopeKey)
+	}()
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	pageKeys := []id.ID{id.NewPseudoRandom(rng), id.NewPseudoRandom(rng)}
+	entry := &api.Document{
+		Contents: &api.Document_Entry{
+			Entry: &api.Entry{
+				AuthorPublicKey: authorPub,
+				Contents: &api.Entry_PageKeys{
+					PageKeys: &api.PageKeys{
+						Keys: [][]byte{pageKeys[0].Bytes(), pageKeys[1].Bytes()},
+					},
+				},
+			},
+		},
+	}
 
-		content2 := new(bytes.Buffer)
-		err = a.Download(content2, envelopeKey)
+	// check pages whose existence can't be checked aren't marked as shipped
+	a.librarians = &fixedClientBalancer{client: &fixedExistsClient{err: errors.New("some error")}}
+	err := a.markExistingPages(entry, authorPub)
+	assert.Nil(t, err)
+	for _, pageKey := range pageKeys {
+		shipped, err := a.uploadSLD.Load(newShippedKey(authorPub, pageKey))
 		assert.Nil(t, err)
+		assert.Nil(t, shipped)
+	}
 
-		// check content1 == content1 --> Upload --> Download
-		assert.Equal(t, content1Bytes, content2.Bytes())
+	// check existing pages are marked as shipped
+	a.librarians = &fixedClientBalancer{client: &fixedExistsClient{exists: true}}
+	err = a.markExistingPages(entry, authorPub)
+	assert.Nil(t, err)
+	for _, pageKey := range pageKeys {
+		shipped, err := a.uploadSLD.Load(newShippedKey(authorPub, pageKey))
+		assert.Nil(t, err)
+		assert.Equal(t, shippedValue, shipped)
 	}
 
-	err := a.CloseAndRemove()
+	// check non-entry errors
+	pageDoc := &api.Document{Contents: &api.Document_Page{Page: api.NewTestPage(rng)}}
+	err = a.markExistingPages(pageDoc, authorPub)
+	assert.NotNil(t, err)
+}
+
+func TestAuthor_UploadShared_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	metadata, err := api.NewEntryMetadata("application/x-pdf", 1, api.RandBytes(rng, 32), 2,
+		api.RandBytes(rng, 32))
 	assert.Nil(t, err)
+	entry, _ := api.NewTestDocument(rng)
+	entryPacker := &fixedEntryPacker{entry: entry, metadata: metadata}
+	a.entryPacker = entryPacker
+	expectedEnvKey := id.NewPseudoRandom(rng)
+	shipper := &fixedShipper{
+		envelope: &api.Document{
+			Contents: &api.Document_Envelope{
+				Envelope: api.NewTestEnvelope(rng),
+			},
+		},
+		envelopeKey: expectedEnvKey,
+	}
+	a.shipper = shipper
+	readers := []*ecdsa.PublicKey{
+		&ecid.NewPseudoRandom(rng).Key().PublicKey,
+		&ecid.NewPseudoRandom(rng).Key().PublicKey,
+	}
+
+	// since everything is mocked, inputs don't really matter
+	selfEnv, sharedEnvs, envKeys, err := a.UploadShared(nil, "", readers)
+	assert.Nil(t, err)
+	assert.NotNil(t, selfEnv)
+	assert.Len(t, sharedEnvs, len(readers))
+	assert.Len(t, envKeys, len(readers)+1)
+
+	// check envelopes are shipped for each reader with the entry's EEK
+	assert.Equal(t, entryPacker.eek, shipper.eek)
+	for i, readerPub := range readers {
+		assert.Equal(t, ecid.ToPublicKeyBytes(readerPub), shipper.readerPubs[i])
+	}
+}
+
+func TestAuthor_UploadShared_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	entry, _ := api.NewTestDocument(rng)
+	metadata, err := api.NewEntryMetadata("application/x-pdf", 1, api.RandBytes(rng, 32), 2,
+		api.RandBytes(rng, 32))
+	assert.Nil(t, err)
+	envelope := &api.Document{
+		Contents: &api.Document_Envelope{
+			Envelope: api.NewTestEnvelope(rng),
+		},
+	}
+	readers := []*ecdsa.PublicKey{&ecid.NewPseudoRandom(rng).Key().PublicKey}
+
+	// check Pack error bubbles up
+	a := newTestAuthor()
+	a.entryPacker = &fixedEntryPacker{err: errors.New("some Pack error")}
+	selfEnv, sharedEnvs, envKeys, err := a.UploadShared(nil, "", readers)
+	assert.NotNil(t, err)
+	assert.Nil(t, selfEnv)
+	assert.Nil(t, sharedEnvs)
+	assert.Nil(t, envKeys)
+	assert.Nil(t, a.CloseAndRemove())
+
+	// check missing author key gives error
+	a = newTestAuthor()
+	a.entryPacker = &fixedEntryPacker{entry: entry, metadata: metadata}
+	a.authorKeys = keychain.New(1)
+	selfEnv, sharedEnvs, envKeys, err = a.UploadShared(nil, "", readers)
+	assert.Equal(t, keychain.ErrUnexpectedMissingKey, err)
+	assert.Nil(t, selfEnv)
+	assert.Nil(t, sharedEnvs)
+	assert.Nil(t, envKeys)
+	assert.Nil(t, a.CloseAndRemove())
+
+	// check ShipEntry error bubbles up
+	a = newTestAuthor()
+	a.entryPacker = &fixedEntryPacker{entry: entry, metadata: metadata}
+	a.shipper = &fixedShipper{err: errors.New("some ShipEntry error")}
+	selfEnv, sharedEnvs, envKeys, err = a.UploadShared(nil, "", readers)
+	assert.NotNil(t, err)
+	assert.Nil(t, selfEnv)
+	assert.Nil(t, sharedEnvs)
+	assert.Nil(t, envKeys)
+	assert.Nil(t, a.CloseAndRemove())
+
+	// check ShipEnvelopes error bubbles up
+	a = newTestAuthor()
+	a.entryPacker = &fixedEntryPacker{entry: entry, metadata: metadata}
+	a.shipper = &fixedShipper{
+		envelope: envelope,
+		envsErr:  errors.New("some ShipEnvelopes error"),
+	}
+	selfEnv, sharedEnvs, envKeys, err = a.UploadShared(nil, "", readers)
+	assert.NotNil(t, err)
+	assert.Nil(t, selfEnv)
+	assert.Nil(t, sharedEnvs)
+	assert.Nil(t, envKeys)
+	assert.Nil(t, a.CloseAndRemove())
 }
 
 func TestAuthor_Share_ok(t *testing.T) {
@@ -267,9 +1116,10 @@ func TestAuthor_Share_ok(t *testing.T) {
 		err := a.CloseAndRemove()
 		assert.Nil(t, err)
 	}()
+	eek := enc.NewPseudoRandomEEK(rng)
 	a.receiver = &fixedReceiver{
 		envelope: api.NewTestEnvelope(rng),
-		eek: enc.NewPseudoRandomEEK(rng),
+		eek: eek,
 	}
 	expectedSharedEnvKey := id.NewPseudoRandom(rng)
 	a.shipper = &fixedShipper{
@@ -288,6 +1138,56 @@ func TestAuthor_Share_ok(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, actualSharedEnv)
 	assert.Equal(t, expectedSharedEnvKey, actualSharedEnvKey)
+
+	// check shared envelope is in audit log
+	records, err := a.AuditLog(time.Time{}, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	sharedEnvKey, err := api.GetKey(actualSharedEnv)
+	assert.Nil(t, err)
+	assert.Equal(t, sharedEnvKey, records[0].EnvelopeKey)
+
+	// check EEK is zeroed after sharing
+	assert.Equal(t, make([]byte, api.EEKLength), enc.MarshalEEK(eek))
+}
+
+func TestAuthor_ShareWithOpts_identity(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	workKeys := keychain.New(1)
+	shipper := &fixedShipper{
+		envelope: &api.Document{
+			Contents: &api.Document_Envelope{
+				Envelope: api.NewTestEnvelope(rng),
+			},
+		},
+		envelopeKey: id.NewPseudoRandom(rng),
+	}
+	a := &Author{
+		receiver: &fixedReceiver{
+			envelope: api.NewTestEnvelope(rng),
+			eek:      enc.NewPseudoRandomEEK(rng),
+		},
+		authorKeys:  &fixedKeychain{sampleErr: errors.New("some Sample error")},
+		identities:  newIdentities(),
+		shipper:     shipper,
+		batchWriter: &fixedBatchWriter{},
+		logger:      clogging.NewDevInfoLogger(),
+	}
+	assert.Nil(t, a.identities.add("work", workKeys))
+	origEnvKey := id.NewPseudoRandom(rng)
+	readerPub := &ecid.NewPseudoRandom(rng).Key().PublicKey
+
+	// check sharing with the selected identity doesn't use the default keychain
+	env, envKey, err := a.ShareWithOpts(origEnvKey, readerPub, &ShareOpts{Identity: "work"})
+	assert.Nil(t, err)
+	assert.NotNil(t, env)
+	assert.Equal(t, shipper.envelopeKey, envKey)
+
+	// check unknown identity errors
+	env, envKey, err = a.ShareWithOpts(origEnvKey, readerPub, &ShareOpts{Identity: "unknown"})
+	assert.Equal(t, ErrUnknownIdentity, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envKey)
 }
 
 func TestAuthor_Share_err(t *testing.T) {
@@ -356,6 +1256,320 @@ func TestAuthor_Share_err(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, env)
 	assert.Nil(t, envID)
+
+	// check audit record error bubbles up
+	a6 := &Author{
+		receiver: &fixedReceiver{
+			envelope: api.NewTestEnvelope(rng),
+		},
+		authorKeys: keychain.New(1),
+		shipper: &fixedShipper{
+			envelope: &api.Document{
+				Contents: &api.Document_Envelope{
+					Envelope: api.NewTestEnvelope(rng),
+				},
+			},
+		},
+		batchWriter: &fixedBatchWriter{err: errors.New("some WriteBatch error")},
+	}
+	env, envID, err = a6.Share(origEnvKey, readerPub)
+	assert.NotNil(t, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envID)
+}
+
+func TestAuthor_Revoke_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+
+	// just mock interaction with libri network
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	for _, size := range []int{64, 2048} {
+		content1 := common.NewCompressableBytes(rng, size)
+		content1Bytes := content1.Bytes()
+		env1, envKey1, err := a.Upload(content1, "application/x-pdf")
+		assert.Nil(t, err)
+
+		env2, envKey2, err := a.Revoke(envKey1)
+		assert.Nil(t, err)
+		assert.NotNil(t, env2)
+		assert.NotEqual(t, envKey1, envKey2)
+
+		// check new envelope has new entry & EEK
+		envelope1 := env1.Contents.(*api.Document_Envelope).Envelope
+		envelope2 := env2.Contents.(*api.Document_Envelope).Envelope
+		assert.NotEqual(t, envelope1.EntryKey, envelope2.EntryKey)
+		eek1, err := a.receiver.GetEEK(envelope1)
+		assert.Nil(t, err)
+		eek2, err := a.receiver.GetEEK(envelope2)
+		assert.Nil(t, err)
+		assert.NotEqual(t, eek1, eek2)
+
+		// check content1 == content1 --> Upload --> Revoke --> Download
+		content2 := new(bytes.Buffer)
+		_, err = a.Download(content2, envKey2)
+		assert.Nil(t, err)
+		assert.Equal(t, content1Bytes, content2.Bytes())
+	}
+
+	err := a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_Revoke_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	logger := clogging.NewDevLogger(zapcore.DebugLevel)
+	envKey := id.NewPseudoRandom(rng)
+
+	// check ReceiveEntry error bubbles up
+	a1 := &Author{
+		receiver: &fixedReceiver{
+			receiveEntryErr: errors.New("some ReceiveEntry error"),
+		},
+		logger: logger,
+	}
+	env, newEnvKey, err := a1.Revoke(envKey)
+	assert.NotNil(t, err)
+	assert.Nil(t, env)
+	assert.Nil(t, newEnvKey)
+
+	// check non-entry document triggers error
+	envelopeDoc, _ := api.NewTestDocument(rng)
+	envelopeDoc.Contents = &api.Document_Envelope{Envelope: api.NewTestEnvelope(rng)}
+	a2 := &Author{
+		receiver: &fixedReceiver{entry: envelopeDoc},
+		logger:   logger,
+	}
+	env, newEnvKey, err = a2.Revoke(envKey)
+	assert.Equal(t, api.ErrUnexpectedDocumentType, err)
+	assert.Nil(t, env)
+	assert.Nil(t, newEnvKey)
+
+	// check metadata decryption error bubbles up
+	entry, _ := api.NewTestDocument(rng)
+	a3 := &Author{
+		receiver: &fixedReceiver{entry: entry, keys: enc.NewPseudoRandomEEK(rng)},
+		schemes:  enc.NewSchemes(),
+		logger:   logger,
+	}
+	env, newEnvKey, err = a3.Revoke(envKey)
+	assert.Equal(t, enc.ErrUnexpectedMAC, err)
+	assert.Nil(t, env)
+	assert.Nil(t, newEnvKey)
+
+	// check unknown encryption scheme triggers error
+	entry.Contents.(*api.Document_Entry).Entry.EncryptionScheme = 7
+	env, newEnvKey, err = a3.Revoke(envKey)
+	assert.Equal(t, &enc.UnknownSchemeError{ID: 7}, err)
+	assert.Nil(t, env)
+	assert.Nil(t, newEnvKey)
+}
+
+func TestAuthor_Locate_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := id.NewPseudoRandom(rng)
+	holders := make([]*api.PeerAddress, 2)
+	for i := range holders {
+		addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 20100 + i}
+		holders[i] = api.FromAddress(id.NewPseudoRandom(rng), fmt.Sprintf("peer-%d", i), addr)
+	}
+	lc := &fixedLocateClient{peers: holders}
+	a := newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+
+	// check holders from response are returned
+	ps, err := a.Locate(key)
+	assert.Nil(t, err)
+	assert.Len(t, ps, len(holders))
+	for i, p := range ps {
+		assert.Equal(t, id.FromBytes(holders[i].PeerId), p.ID())
+	}
+	assert.Equal(t, key.Bytes(), lc.rq.Key)
+
+	// check key stored nowhere gives empty list
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: &fixedLocateClient{}})
+	ps, err = a.Locate(key)
+	assert.Nil(t, err)
+	assert.NotNil(t, ps)
+	assert.Empty(t, ps)
+}
+
+func TestAuthor_Locate_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := id.NewPseudoRandom(rng)
+
+	// check Next error bubbles up
+	a := newLocateAuthor(rng, &fixedClientBalancer{err: errors.New("some Next error")})
+	ps, err := a.Locate(key)
+	assert.NotNil(t, err)
+	assert.Nil(t, ps)
+
+	// check Locate error bubbles up
+	lc := &fixedLocateClient{err: errors.New("some Locate error")}
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	ps, err = a.Locate(key)
+	assert.NotNil(t, err)
+	assert.Nil(t, ps)
+
+	// check unexpected request ID gives error
+	lc = &fixedLocateClient{badRequestID: true}
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	ps, err = a.Locate(key)
+	assert.Equal(t, client.ErrUnexpectedRequestID, err)
+	assert.Nil(t, ps)
+}
+
+func TestAuthor_Exists_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := id.NewPseudoRandom(rng)
+
+	// check existing document is confirmed with exists-only request
+	lc := &fixedExistsClient{exists: true}
+	a := newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	exists, err := a.Exists(key)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, key.Bytes(), lc.rq.Key)
+	assert.True(t, lc.rq.ExistsOnly)
+
+	// check document returned by librarian ignoring exists-only is confirmed
+	value, _ := api.NewTestDocument(rng)
+	lc = &fixedExistsClient{value: value}
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	exists, err = a.Exists(key)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	// check missing document isn't confirmed
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: &fixedExistsClient{}})
+	exists, err = a.Exists(key)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestAuthor_Exists_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := id.NewPseudoRandom(rng)
+
+	// check Next error bubbles up
+	a := newLocateAuthor(rng, &fixedClientBalancer{err: errors.New("some Next error")})
+	exists, err := a.Exists(key)
+	assert.NotNil(t, err)
+	assert.False(t, exists)
+
+	// check Get error bubbles up
+	lc := &fixedExistsClient{err: errors.New("some Get error")}
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	exists, err = a.Exists(key)
+	assert.NotNil(t, err)
+	assert.False(t, exists)
+
+	// check unexpected request ID gives error
+	lc = &fixedExistsClient{exists: true, badRequestID: true}
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	exists, err = a.Exists(key)
+	assert.Equal(t, client.ErrUnexpectedRequestID, err)
+	assert.False(t, exists)
+
+	// check expired document RPC error maps back to ErrExpired
+	lc = &fixedExistsClient{err: api.ErrExpiredRPC}
+	a = newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	exists, err = a.Exists(key)
+	assert.Equal(t, api.ErrExpired, err)
+	assert.False(t, exists)
+}
+
+func newLocateAuthor(rng *rand.Rand, librarians api.ClientBalancer) *Author {
+	return &Author{
+		clientID:   ecid.NewPseudoRandom(rng),
+		config:     NewDefaultConfig(),
+		librarians: librarians,
+		signer:     &client.TestNoOpSigner{},
+		logger:     clogging.NewDevInfoLogger(),
+	}
+}
+
+type fixedLocateClient struct {
+	api.LibrarianClient
+	peers        []*api.PeerAddress
+	err          error
+	badRequestID bool
+	rq           *api.LocateRequest
+}
+
+func (f *fixedLocateClient) Locate(
+	ctx context.Context, in *api.LocateRequest, opts ...grpc.CallOption,
+) (*api.LocateResponse, error) {
+	f.rq = in
+	if f.err != nil {
+		return nil, f.err
+	}
+	requestID := in.Metadata.RequestId
+	if f.badRequestID {
+		requestID = id.NewPseudoRandom(rand.New(rand.NewSource(0))).Bytes()
+	}
+	return &api.LocateResponse{
+		Metadata: &api.ResponseMetadata{RequestId: requestID},
+		Peers:    f.peers,
+	}, nil
+}
+
+type fixedExistsClient struct {
+	api.LibrarianClient
+	exists       bool
+	value        *api.Document
+	err          error
+	badRequestID bool
+	rq           *api.GetRequest
+}
+
+func (f *fixedExistsClient) Get(
+	ctx context.Context, in *api.GetRequest, opts ...grpc.CallOption,
+) (*api.GetResponse, error) {
+	f.rq = in
+	if f.err != nil {
+		return nil, f.err
+	}
+	requestID := in.Metadata.RequestId
+	if f.badRequestID {
+		requestID = id.NewPseudoRandom(rand.New(rand.NewSource(0))).Bytes()
+	}
+	return &api.GetResponse{
+		Metadata: &api.ResponseMetadata{RequestId: requestID},
+		Exists:   f.exists,
+		Value:    f.value,
+	}, nil
+}
+
+// memExistsClient answers exists-only Get requests with whether the memPublisherAcquirer has
+// published the document.
+type memExistsClient struct {
+	api.LibrarianClient
+	pubAcq *memPublisherAcquirer
+}
+
+func (c *memExistsClient) Get(
+	ctx context.Context, in *api.GetRequest, opts ...grpc.CallOption,
+) (*api.GetResponse, error) {
+	c.pubAcq.mu.Lock()
+	defer c.pubAcq.mu.Unlock()
+	_, exists := c.pubAcq.docs[id.FromBytes(in.Key).String()]
+	return &api.GetResponse{
+		Metadata: &api.ResponseMetadata{RequestId: in.Metadata.RequestId},
+		Exists:   exists,
+	}, nil
 }
 
 type fixedPublisher struct {
@@ -382,46 +1596,103 @@ func (f *fixedMLPublisher) Publish(docKeys []id.ID, cb api.ClientBalancer) error
 }
 
 type fixedEntryPacker struct {
-	entry    *api.Document
-	metadata *api.Metadata
-	err      error
+	entry     *api.Document
+	metadata  *api.Metadata
+	err       error
+	mediaType string
+	expiry    time.Time
+	eek       *enc.EEK
 }
 
 func (f *fixedEntryPacker) Pack(
-	content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte,
+	content io.Reader,
+	mediaType string,
+	codec comp.Codec,
+	pageSize uint32,
+	expiry time.Time,
+	keys *enc.EEK,
+	authorPub []byte,
 ) (*api.Document, *api.Metadata, error) {
+	f.mediaType, f.expiry, f.eek = mediaType, expiry, keys
 	return f.entry, f.metadata, f.err
 }
 
+// fixedScheme is the default encryption scheme but with a fixed ID.
+type fixedScheme struct {
+	enc.Scheme
+	id enc.SchemeID
+}
+
+func (f *fixedScheme) ID() enc.SchemeID {
+	return f.id
+}
+
 type fixedShipper struct {
 	envelope    *api.Document
 	envelopeKey id.ID
+	result      *publish.Result
 	err         error
+	envsErr     error
+	repl        *publish.Replication
+	eek         *enc.EEK
+	readerPubs  [][]byte
+	nEntries    int
+	nEnvelopes  int
 }
 
 func (f *fixedShipper) ShipEntry(
-	entry *api.Document, authorPub []byte, readerPub []byte, kek *enc.KEK, eek *enc.EEK,
-) (*api.Document, id.ID, error) {
-	return f.envelope, f.envelopeKey, f.err
+	ctx context.Context, entry *api.Document, authorPub []byte, readerPub []byte, kek *enc.KEK,
+	eek *enc.EEK,
+	repl *publish.Replication, progress publish.Progress,
+) (*api.Document, id.ID, *publish.Result, error) {
+	f.repl = repl
+	f.nEntries++
+	result := f.result
+	if result == nil {
+		result = &publish.Result{}
+	}
+	return f.envelope, f.envelopeKey, result, f.err
 }
 
 func (f *fixedShipper) ShipEnvelope(
 	kek *enc.KEK, eek *enc.EEK, entryKey id.ID, authorPub, readerPub []byte,
+	repl *publish.Replication,
 ) (*api.Document, id.ID, error) {
+	f.repl = repl
+	f.nEnvelopes++
 	return f.envelope, f.envelopeKey, f.err
 }
 
+func (f *fixedShipper) ShipEnvelopes(
+	keks []*enc.KEK, eek *enc.EEK, entryKey id.ID, authorPub []byte, readerPubs [][]byte,
+	repl *publish.Replication,
+) ([]*api.Document, []id.ID, error) {
+	if f.envsErr != nil {
+		return nil, nil, f.envsErr
+	}
+	f.eek, f.readerPubs = eek, readerPubs
+	envelopes, envelopeKeys := make([]*api.Document, len(keks)), make([]id.ID, len(keks))
+	for i := range keks {
+		envelopes[i], envelopeKeys[i] = f.envelope, f.envelopeKey
+	}
+	return envelopes, envelopeKeys, nil
+}
+
 type fixedReceiver struct {
 	entry              *api.Document
 	keys               *enc.EEK
 	receiveEntryErr    error
+	search             *publish.Search
 	envelope           *api.Envelope
 	receiveEnvelopeErr error
 	eek                *enc.EEK
 	getErrkErr         error
 }
 
-func (f *fixedReceiver) ReceiveEntry(envelopeKey id.ID) (*api.Document, *enc.EEK, error) {
+func (f *fixedReceiver) ReceiveEntry(
+	ctx context.Context, envelopeKey id.ID, search *publish.Search, progress publish.Progress,
+) (*api.Document, *enc.EEK, error) {
+	f.search = search
 	return f.entry, f.keys, f.receiveEntryErr
 }
 
@@ -438,8 +1709,9 @@ type fixedUnpacker struct {
 	err      error
 }
 
-func (f *fixedUnpacker) Unpack(content io.Writer, entry *api.Document, keys *enc.EEK) (
-	*api.Metadata, error) {
+func (f *fixedUnpacker) Unpack(
+	ctx context.Context, content io.Writer, entry *api.Document, keys *enc.EEK,
+) (*api.Metadata, error) {
 	return f.metadata, f.err
 }
 
@@ -448,8 +1720,9 @@ type memPublisherAcquirer struct {
 	mu   sync.Mutex
 }
 
-func (p *memPublisherAcquirer) Publish(doc *api.Document, authorPub []byte, lc api.Putter) (
-	id.ID, error) {
+func (p *memPublisherAcquirer) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *publish.Replication,
+) (id.ID, *publish.Result, error) {
 	docKey, err := api.GetKey(doc)
 	if err != nil {
 		panic(err)
@@ -457,11 +1730,12 @@ func (p *memPublisherAcquirer) Publish(doc *api.Document, authorPub []byte, lc a
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.docs[docKey.String()] = doc
-	return docKey, nil
+	return docKey, &publish.Result{}, nil
 }
 
-func (p *memPublisherAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (
-	*api.Document, error) {
+func (p *memPublisherAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *publish.Search,
+) (*api.Document, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.docs[docKey.String()], nil
@@ -480,17 +1754,38 @@ func (f *fixedClientBalancer) CloseAll() error {
 	return f.err
 }
 
+type fixedLatencyObserver struct {
+	fixedClientBalancer
+	latencies map[string]time.Duration
+}
+
+func (f *fixedLatencyObserver) ObserveLatency(addr string, rtt time.Duration) {
+	f.latencies[addr] = rtt
+}
+
 type fixedHealthClient struct {
 	response *healthpb.HealthCheckResponse
 	err      error
+	delay    time.Duration
 }
 
 func (f *fixedHealthClient) Check(
 	ctx context.Context, in *healthpb.HealthCheckRequest, opts ...grpc.CallOption,
 ) (*healthpb.HealthCheckResponse, error) {
+	time.Sleep(f.delay)
 	return f.response, f.err
 }
 
+// hangingHealthClient never responds to a Check before its context is done.
+type hangingHealthClient struct{}
+
+func (f *hangingHealthClient) Check(
+	ctx context.Context, in *healthpb.HealthCheckRequest, opts ...grpc.CallOption,
+) (*healthpb.HealthCheckResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
 type packTestCase struct {
 	pageSize          uint32
 	uncompressedSize  int
@@ -530,7 +1825,10 @@ func caseCrossProduct(
 	return cases
 }
 func newTestAuthor() *Author {
-	config := newTestConfig()
+	return newTestAuthorWithConfig(newTestConfig())
+}
+
+func newTestAuthorWithConfig(config *Config) *Author {
 	logger := clogging.NewDevLogger(zapcore.DebugLevel)
 
 	// create keychains
@@ -555,10 +1853,11 @@ func newTestConfig() *Config {
 		panic(err)
 	}
 
-	// set data dir and resets DB and Keychain dirs to use it
+	// set data dir and resets DB and Keychain dirs to use it, keeping the DB in memory
 	config.WithDataDir(dir).
 		WithDefaultDBDir().
-		WithDefaultKeychainDir()
+		WithDefaultKeychainDir().
+		WithDBBackend(db.MemoryDBBackend)
 
 	return config
 }
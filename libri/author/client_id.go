@@ -0,0 +1,138 @@
+package author
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+// ErrClientIDNotRotatable indicates when rotating the client ID of an author not created with
+// NewAuthor.
+var ErrClientIDNotRotatable = errors.New("client ID is not rotatable")
+
+// rotatingClientID is both the client ID and the request signer of an author. It delegates to
+// the current client ID and its signer, which RotateID swaps together, so the publisher,
+// acquirer, and other components holding it use the new ID for subsequent requests.
+type rotatingClientID struct {
+	clientID ecid.ID
+	signer   client.Signer
+	mu       sync.RWMutex
+
+	// serializes rotations so each is persisted before the next begins
+	rotateMu sync.Mutex
+}
+
+func newRotatingClientID(clientID ecid.ID) *rotatingClientID {
+	return &rotatingClientID{
+		clientID: clientID,
+		signer:   client.NewSigner(clientID.Key()),
+	}
+}
+
+// current returns the current client ID and its signer.
+func (r *rotatingClientID) current() (ecid.ID, client.Signer) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clientID, r.signer
+}
+
+// set replaces the current client ID and its signer.
+func (r *rotatingClientID) set(clientID ecid.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clientID, r.signer = clientID, client.NewSigner(clientID.Key())
+}
+
+func (r *rotatingClientID) id() ecid.ID {
+	clientID, _ := r.current()
+	return clientID
+}
+
+func (r *rotatingClientID) String() string {
+	return r.id().String()
+}
+
+func (r *rotatingClientID) Bytes() []byte {
+	return r.id().Bytes()
+}
+
+func (r *rotatingClientID) Int() *big.Int {
+	return r.id().Int()
+}
+
+func (r *rotatingClientID) Cmp(other cid.ID) int {
+	return r.id().Cmp(other)
+}
+
+func (r *rotatingClientID) Distance(other cid.ID) *big.Int {
+	return r.id().Distance(other)
+}
+
+func (r *rotatingClientID) Key() *ecdsa.PrivateKey {
+	return r.id().Key()
+}
+
+func (r *rotatingClientID) ID() cid.ID {
+	return r.id().ID()
+}
+
+func (r *rotatingClientID) PublicKeyBytes() []byte {
+	return r.id().PublicKeyBytes()
+}
+
+// Sign signs the message with the current client ID's key.
+func (r *rotatingClientID) Sign(m proto.Message) (string, error) {
+	_, signer := r.current()
+	return signer.Sign(m)
+}
+
+// SignUntil signs the message with the current client ID's key, expiring after notAfter.
+func (r *rotatingClientID) SignUntil(m proto.Message, notAfter time.Time) (string, error) {
+	_, signer := r.current()
+	return signer.SignUntil(m, notAfter)
+}
+
+// RotateID replaces the author's client ID with a new random one, which it persists and then uses
+// to sign all subsequent requests. The old client ID is also persisted and returned by PreviousID,
+// so its key remains available for verifying anything it signed. Requests in flight while
+// rotating may have been created with the old ID but signed with the new one (or vice versa) and
+// fail verification, so callers should let in-flight uploads, downloads, shares, and
+// subscriptions complete before rotating.
+func (a *Author) RotateID() (ecid.ID, ecid.ID, error) {
+	rotating, ok := a.clientID.(*rotatingClientID)
+	if !ok {
+		return nil, nil, ErrClientIDNotRotatable
+	}
+	rotating.rotateMu.Lock()
+	defer rotating.rotateMu.Unlock()
+
+	old, newID := rotating.id(), ecid.NewRandom()
+	if err := savePreviousClientID(a.clientSL, old); err != nil {
+		a.logger.Error("error saving previous client ID", zap.Error(err))
+		return nil, nil, err
+	}
+	if err := saveClientID(a.clientSL, newID); err != nil {
+		a.logger.Error("error saving rotated client ID", zap.Error(err))
+		return nil, nil, err
+	}
+	rotating.set(newID)
+	a.logger.Info("rotated client ID",
+		zap.String(LoggerPreviousClientID, old.String()),
+		zap.String(LoggerClientID, newID.String()),
+	)
+	return old, newID, nil
+}
+
+// PreviousID returns the client ID the author had before it was last rotated with RotateID, or
+// nil if it has never been rotated.
+func (a *Author) PreviousID() (ecid.ID, error) {
+	return loadPreviousClientID(a.clientSL)
+}
@@ -0,0 +1,99 @@
+package author
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingClientID(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	clientID1, clientID2 := ecid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+	verifier := client.NewVerifier()
+
+	r := newRotatingClientID(clientID1)
+	checkRotatingClientID(t, clientID1, r, verifier)
+
+	r.set(clientID2)
+	checkRotatingClientID(t, clientID2, r, verifier)
+}
+
+func checkRotatingClientID(t *testing.T, expected ecid.ID, r *rotatingClientID,
+	verifier client.Verifier) {
+
+	assert.Equal(t, expected, r.id())
+	assert.Equal(t, expected.String(), r.String())
+	assert.Equal(t, expected.Bytes(), r.Bytes())
+	assert.Equal(t, expected.Int(), r.Int())
+	assert.Equal(t, 0, r.Cmp(expected))
+	assert.Equal(t, int64(0), r.Distance(expected).Int64())
+	assert.Equal(t, expected.Key(), r.Key())
+	assert.Equal(t, expected.ID(), r.ID())
+	assert.Equal(t, expected.PublicKeyBytes(), r.PublicKeyBytes())
+
+	rq := client.NewGetRequest(r, expected)
+	token, err := r.Sign(rq)
+	assert.Nil(t, err)
+	assert.Nil(t, verifier.Verify(token, expected.PublicKeyBytes(), rq))
+}
+
+func TestAuthor_RotateID_ok(t *testing.T) {
+	a := newTestAuthor()
+	defer func() { assert.Nil(t, a.CloseAndRemove()) }()
+	clientID0 := a.ID()
+
+	// check not-yet-rotated author has no previous ID
+	prev, err := a.PreviousID()
+	assert.Nil(t, err)
+	assert.Nil(t, prev)
+
+	old, newID, err := a.RotateID()
+	assert.Nil(t, err)
+	assert.Equal(t, clientID0, old)
+	assert.NotEqual(t, clientID0, newID)
+	assert.Equal(t, newID, a.ID())
+
+	// check new ID is used for requests and their signatures
+	rq := client.NewGetRequest(a.clientID, newID)
+	assert.Equal(t, newID.PublicKeyBytes(), rq.Metadata.PubKey)
+	token, err := a.signer.Sign(rq)
+	assert.Nil(t, err)
+	assert.Nil(t, client.NewVerifier().Verify(token, newID.PublicKeyBytes(), rq))
+
+	// check new and previous IDs are persisted
+	stored, err := loadOrCreateClientID(clogging.NewDevInfoLogger(), a.clientSL)
+	assert.Nil(t, err)
+	assert.Equal(t, newID, stored)
+	prev, err = a.PreviousID()
+	assert.Nil(t, err)
+	assert.Equal(t, clientID0, prev)
+}
+
+func TestAuthor_RotateID_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	clientID := ecid.NewPseudoRandom(rng)
+
+	// check author without a rotating client ID errors
+	a := &Author{clientID: clientID}
+	old, newID, err := a.RotateID()
+	assert.Equal(t, ErrClientIDNotRotatable, err)
+	assert.Nil(t, old)
+	assert.Nil(t, newID)
+
+	// check Store error bubbles up and leaves client ID unchanged
+	a = &Author{
+		clientID: newRotatingClientID(clientID),
+		clientSL: &fixedStorerLoader{storeErr: errors.New("some Store error")},
+		logger:   clogging.NewDevInfoLogger(),
+	}
+	old, newID, err = a.RotateID()
+	assert.NotNil(t, err)
+	assert.Nil(t, old)
+	assert.Nil(t, newID)
+	assert.Equal(t, clientID, a.ID())
+}
@@ -0,0 +1,89 @@
+package author
+
+import (
+	"errors"
+
+	"github.com/drausin/libri/libri/author/io/pack"
+	"github.com/drausin/libri/libri/author/io/ship"
+	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/db"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	// ErrMissingClientID indicates when NewAuthorWithCollaborators isn't given a client ID.
+	ErrMissingClientID = errors.New("missing client ID")
+
+	// ErrMissingLibrarians indicates when NewAuthorWithCollaborators isn't given a librarian
+	// client balancer.
+	ErrMissingLibrarians = errors.New("missing librarians client balancer")
+)
+
+// Collaborators are already-constructed components for NewAuthorWithCollaborators to use in place
+// of those NewAuthor would create, e.g., fakes in tests. Only Librarians is required; other nil
+// components are created as NewAuthor would, but from Librarians and DocumentSLD.
+type Collaborators struct {
+	// Librarians balances requests across librarian clients.
+	Librarians api.ClientBalancer
+
+	// DocumentSLD stores, loads, and deletes local documents. If nil, documents are stored in
+	// memory.
+	DocumentSLD storage.DocumentSLD
+
+	// Shipper publishes entries and envelopes for uploads and shares.
+	Shipper ship.Shipper
+
+	// Receiver acquires entries and envelopes for downloads.
+	Receiver ship.Receiver
+
+	// EntryPacker creates entry documents from content for uploads.
+	EntryPacker pack.EntryPacker
+
+	// EntryUnpacker writes the content of entry documents for downloads.
+	EntryUnpacker pack.EntryUnpacker
+}
+
+// NewAuthorWithCollaborators creates a new *Author with the given client ID and collaborators
+// instead of the persisted client ID, DB, and librarian connections NewAuthor uses. It neither
+// dials librarians nor opens a DB on disk, storing all local state in memory, so tests can
+// exercise uploads and downloads without a live network.
+func NewAuthorWithCollaborators(
+	config *Config,
+	clientID ecid.ID,
+	authorKeys keychain.GetterSampler,
+	selfReaderKeys keychain.GetterSampler,
+	c *Collaborators,
+	logger *zap.Logger) (*Author, error) {
+
+	if clientID == nil {
+		return nil, ErrMissingClientID
+	}
+	if c == nil || c.Librarians == nil {
+		return nil, ErrMissingLibrarians
+	}
+	rdb := db.NewMemoryDB()
+	documentSL := c.DocumentSLD
+	if documentSL == nil {
+		documentSL = storage.NewDocumentSLD(rdb)
+	}
+	a := newAuthor(config, clientID, authorKeys, selfReaderKeys, rdb, documentSL,
+		c.Librarians, make(map[string]healthpb.HealthClient), logger)
+
+	if c.Shipper != nil {
+		a.shipper = c.Shipper
+	}
+	if c.Receiver != nil {
+		a.receiver = c.Receiver
+	}
+	if c.EntryPacker != nil {
+		a.entryPacker = c.EntryPacker
+	}
+	if c.EntryUnpacker != nil {
+		a.entryUnpacker = c.EntryUnpacker
+	}
+	return a, nil
+}
@@ -0,0 +1,92 @@
+package author
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuthorWithCollaborators_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	clientID := ecid.NewPseudoRandom(rng)
+	metadata, err := api.NewEntryMetadata(
+		"application/x-pdf",
+		1,
+		api.RandBytes(rng, 32),
+		2,
+		api.RandBytes(rng, 32),
+	)
+	assert.Nil(t, err)
+	entry, entryKey := api.NewTestDocument(rng)
+	envKey := id.NewPseudoRandom(rng)
+	c := &Collaborators{
+		Librarians:  &fixedClientBalancer{},
+		EntryPacker: &fixedEntryPacker{entry: entry, metadata: metadata},
+		Shipper: &fixedShipper{
+			envelope: &api.Document{
+				Contents: &api.Document_Envelope{
+					Envelope: api.NewTestEnvelope(rng),
+				},
+			},
+			envelopeKey: envKey,
+		},
+		Receiver:      &fixedReceiver{entry: entry, keys: enc.NewPseudoRandomEEK(rng)},
+		EntryUnpacker: &fixedUnpacker{metadata: metadata},
+	}
+
+	a, err := NewAuthorWithCollaborators(newTestConfig(), clientID, keychain.New(3),
+		keychain.New(3), c, clogging.NewDevInfoLogger())
+	assert.Nil(t, err)
+	assert.Equal(t, clientID, a.ID())
+
+	// check uploads and downloads use given collaborators
+	_, actualEnvKey, err := a.Upload(nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, envKey, actualEnvKey)
+	assert.Equal(t, 1, c.Shipper.(*fixedShipper).nEntries)
+	mediaType, err := a.Download(new(bytes.Buffer), entryKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-pdf", mediaType)
+	assert.Nil(t, a.CloseAndRemove())
+
+	// check nil collaborators are created
+	a, err = NewAuthorWithCollaborators(newTestConfig(), clientID, keychain.New(3),
+		keychain.New(3), &Collaborators{Librarians: &fixedClientBalancer{}},
+		clogging.NewDevInfoLogger())
+	assert.Nil(t, err)
+	assert.NotNil(t, a.documentSLD)
+	assert.NotNil(t, a.shipper)
+	assert.NotNil(t, a.receiver)
+	assert.NotNil(t, a.entryPacker)
+	assert.NotNil(t, a.entryUnpacker)
+	assert.Nil(t, a.CloseAndRemove())
+}
+
+func TestNewAuthorWithCollaborators_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	clientID := ecid.NewPseudoRandom(rng)
+	logger := clogging.NewDevInfoLogger()
+
+	a, err := NewAuthorWithCollaborators(NewDefaultConfig(), nil, keychain.New(3),
+		keychain.New(3), &Collaborators{Librarians: &fixedClientBalancer{}}, logger)
+	assert.Equal(t, ErrMissingClientID, err)
+	assert.Nil(t, a)
+
+	a, err = NewAuthorWithCollaborators(NewDefaultConfig(), clientID, keychain.New(3),
+		keychain.New(3), &Collaborators{}, logger)
+	assert.Equal(t, ErrMissingLibrarians, err)
+	assert.Nil(t, a)
+
+	a, err = NewAuthorWithCollaborators(NewDefaultConfig(), clientID, keychain.New(3),
+		keychain.New(3), nil, logger)
+	assert.Equal(t, ErrMissingLibrarians, err)
+	assert.Nil(t, a)
+}
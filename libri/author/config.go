@@ -4,12 +4,19 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/print"
 	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/common/clock"
+	"github.com/drausin/libri/libri/common/db"
+	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/server"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const (
@@ -28,8 +35,31 @@ const (
 	// DBSubDir is the default DB subdirectory within the data dir.
 	DBSubDir = "db"
 
+	// DefaultDBBackend is the default KVDB backend.
+	DefaultDBBackend = db.RocksDBBackend
+
 	// KeychainSubDir is the default DB subdirectory within the data dir.
 	KeychainSubDir = "keychain"
+
+	// UniformRandomBalancer selects librarians uniformly at random.
+	UniformRandomBalancer = "uniform-random"
+
+	// LatencyAwareBalancer selects librarians favoring those with lower healthcheck latencies.
+	LatencyAwareBalancer = "latency-aware"
+
+	// RoundRobinBalancer cycles through librarians in order.
+	RoundRobinBalancer = "round-robin"
+
+	// DefaultHealthcheckTimeout is the default timeout for the healthcheck of each librarian.
+	DefaultHealthcheckTimeout = 2 * time.Second
+
+	// DefaultHealthcheckDeadline is the default overall deadline for healthchecking all
+	// librarians.
+	DefaultHealthcheckDeadline = 10 * time.Second
+
+	// DefaultMaxMessageBytes is the default maximum size of gRPC messages sent to and received
+	// from librarians, which matches their own default.
+	DefaultMaxMessageBytes = uint64(server.DefaultMaxMessageBytes)
 )
 
 // Config is used to configure an Author.
@@ -41,6 +71,10 @@ type Config struct {
 	// DbDir is the local directory where this node's DB state is stored.
 	DbDir string
 
+	// DBBackend is the KVDB backend storing the DB state, e.g., db.RocksDBBackend or the pure-Go
+	// db.BadgerDBBackend, which doesn't require cgo.
+	DBBackend string
+
 	// KeychainDir is the local directory where the author keys are stored.
 	KeychainDir string
 
@@ -55,6 +89,63 @@ type Config struct {
 
 	// LogLevel is the log level
 	LogLevel zapcore.Level
+
+	// StreamDownloads indicates whether the pages of multi-page entries should be acquired as
+	// they are written to the downloaded content rather than all before it, bounding the number
+	// held locally at once to the publish GetParallelism. Download progress callbacks are not
+	// called for streamed pages.
+	StreamDownloads bool
+
+	// CountKeyUsage indicates whether the author and self reader keychains should count how many
+	// times each of their keys has been sampled, available via Author.KeyUsageCounts.
+	CountKeyUsage bool
+
+	// LibrarianBalancer is the strategy for balancing requests between librarians, e.g.,
+	// UniformRandomBalancer, LatencyAwareBalancer, or RoundRobinBalancer.
+	LibrarianBalancer string
+
+	// EncryptionScheme is the scheme used to encrypt the metadata and pages of uploaded entries.
+	// Downloaded entries are decrypted with the scheme recorded in them, which must be either
+	// this one or the default.
+	EncryptionScheme enc.Scheme
+
+	// DownloadMultiParallelism is the max number of envelopes concurrently downloaded by
+	// DownloadMulti. Zero uses one per librarian in LibrarianAddrs.
+	DownloadMultiParallelism uint
+
+	// Events, if not nil, receives the upload and download lifecycle events of the Author. Events
+	// are dropped when it is full, so it should be buffered and drained promptly.
+	Events chan<- *Event
+
+	// Clock tells the time for timeouts and latencies, which tests may replace with a fake.
+	Clock clock.Clock
+
+	// HealthcheckTimeout is the timeout for the healthcheck of each librarian.
+	HealthcheckTimeout time.Duration
+
+	// HealthcheckDeadline is the overall deadline for healthchecking all librarians, after which
+	// unfinished checks are reported as UNKNOWN.
+	HealthcheckDeadline time.Duration
+
+	// TransportCredentials secure all librarian client and health client connections, which have
+	// no transport security if nil. For mutual TLS, use
+	//
+	//	credentials.NewTLS(&tls.Config{
+	//		RootCAs:      caPool,           // librarians' CA bundle
+	//		Certificates: []tls.Certificate{authorCert},
+	//	})
+	//
+	// where authorCert is the author's client certificate, signed by a CA the librarians trust.
+	TransportCredentials credentials.TransportCredentials
+
+	// DialOptions are the other gRPC dial options of all librarian client and health client
+	// connections, e.g., keepalive parameters. They should not set transport security, which
+	// TransportCredentials does.
+	DialOptions []grpc.DialOption
+
+	// MaxMessageBytes is the maximum size (in bytes) of gRPC messages sent to and received from
+	// librarians, which should be at least the size of the largest documents they store.
+	MaxMessageBytes uint64
 }
 
 // NewDefaultConfig returns a reasonable default author configuration.
@@ -65,11 +156,18 @@ func NewDefaultConfig() *Config {
 	// should be set before config B
 	config.WithDefaultDataDir()
 	config.WithDefaultDBDir()
+	config.WithDefaultDBBackend()
 	config.WithDefaultKeychainDir()
 	config.WithDefaultLibrarianAddrs()
 	config.WithDefaultPrint()
 	config.WithDefaultPublish()
 	config.WithDefaultLogLevel()
+	config.WithDefaultLibrarianBalancer()
+	config.WithDefaultEncryptionScheme()
+	config.WithDefaultClock()
+	config.WithDefaultHealthcheckTimeout()
+	config.WithDefaultHealthcheckDeadline()
+	config.WithDefaultMaxMessageBytes()
 
 	return config
 }
@@ -108,6 +206,21 @@ func (c *Config) WithDefaultDBDir() *Config {
 	return c
 }
 
+// WithDBBackend sets the DB backend to the given value or the default if the given value is empty.
+func (c *Config) WithDBBackend(backend string) *Config {
+	if backend == "" {
+		return c.WithDefaultDBBackend()
+	}
+	c.DBBackend = backend
+	return c
+}
+
+// WithDefaultDBBackend sets the DB backend to the default.
+func (c *Config) WithDefaultDBBackend() *Config {
+	c.DBBackend = DefaultDBBackend
+	return c
+}
+
 // WithKeychainDir sets the keychain dir to the given value or the default if the given value is
 // empty.
 func (c *Config) WithKeychainDir(keychainDir string) *Config {
@@ -193,3 +306,161 @@ func (c *Config) WithDefaultLogLevel() *Config {
 	c.LogLevel = DefaultLogLevel
 	return c
 }
+
+// WithStreamDownloads sets whether downloads should stream pages as they are acquired.
+func (c *Config) WithStreamDownloads(streamDownloads bool) *Config {
+	c.StreamDownloads = streamDownloads
+	return c
+}
+
+// WithCountKeyUsage sets whether the author should count how many times each key is sampled.
+func (c *Config) WithCountKeyUsage(countKeyUsage bool) *Config {
+	c.CountKeyUsage = countKeyUsage
+	return c
+}
+
+// WithLibrarianBalancer sets the librarian balancer strategy to the given value or the default if
+// it is empty.
+func (c *Config) WithLibrarianBalancer(librarianBalancer string) *Config {
+	if librarianBalancer == "" {
+		return c.WithDefaultLibrarianBalancer()
+	}
+	c.LibrarianBalancer = librarianBalancer
+	return c
+}
+
+// WithDefaultLibrarianBalancer sets the librarian balancer strategy to UniformRandomBalancer.
+func (c *Config) WithDefaultLibrarianBalancer() *Config {
+	c.LibrarianBalancer = UniformRandomBalancer
+	return c
+}
+
+// WithEncryptionScheme sets the encryption scheme to the given value or the default if it is nil.
+func (c *Config) WithEncryptionScheme(scheme enc.Scheme) *Config {
+	if scheme == nil {
+		return c.WithDefaultEncryptionScheme()
+	}
+	c.EncryptionScheme = scheme
+	return c
+}
+
+// WithDefaultEncryptionScheme sets the encryption scheme to the default AES-256-GCM scheme.
+func (c *Config) WithDefaultEncryptionScheme() *Config {
+	c.EncryptionScheme = enc.NewAESGCMScheme()
+	return c
+}
+
+// WithDownloadMultiParallelism sets the max number of envelopes concurrently downloaded by
+// DownloadMulti, where zero uses one per librarian.
+func (c *Config) WithDownloadMultiParallelism(parallelism uint) *Config {
+	c.DownloadMultiParallelism = parallelism
+	return c
+}
+
+// WithEvents sets the channel receiving upload and download lifecycle events, where nil emits no
+// events.
+func (c *Config) WithEvents(events chan<- *Event) *Config {
+	c.Events = events
+	return c
+}
+
+// WithClock sets the clock to the given value or the default if it is nil.
+func (c *Config) WithClock(clk clock.Clock) *Config {
+	if clk == nil {
+		return c.WithDefaultClock()
+	}
+	c.Clock = clk
+	return c
+}
+
+// WithDefaultClock sets the clock to the wall clock.
+func (c *Config) WithDefaultClock() *Config {
+	c.Clock = clock.New()
+	return c
+}
+
+// WithHealthcheckTimeout sets the timeout for the healthcheck of each librarian to the given
+// value or the default if it is zero.
+func (c *Config) WithHealthcheckTimeout(timeout time.Duration) *Config {
+	if timeout == 0 {
+		return c.WithDefaultHealthcheckTimeout()
+	}
+	c.HealthcheckTimeout = timeout
+	return c
+}
+
+// WithDefaultHealthcheckTimeout sets the timeout for the healthcheck of each librarian to
+// DefaultHealthcheckTimeout.
+func (c *Config) WithDefaultHealthcheckTimeout() *Config {
+	c.HealthcheckTimeout = DefaultHealthcheckTimeout
+	return c
+}
+
+// WithHealthcheckDeadline sets the overall deadline for healthchecking all librarians to the
+// given value or the default if it is zero.
+func (c *Config) WithHealthcheckDeadline(deadline time.Duration) *Config {
+	if deadline == 0 {
+		return c.WithDefaultHealthcheckDeadline()
+	}
+	c.HealthcheckDeadline = deadline
+	return c
+}
+
+// WithDefaultHealthcheckDeadline sets the overall deadline for healthchecking all librarians to
+// DefaultHealthcheckDeadline.
+func (c *Config) WithDefaultHealthcheckDeadline() *Config {
+	c.HealthcheckDeadline = DefaultHealthcheckDeadline
+	return c
+}
+
+// WithTransportCredentials sets the transport credentials of librarian connections, where nil
+// dials without transport security.
+func (c *Config) WithTransportCredentials(creds credentials.TransportCredentials) *Config {
+	c.TransportCredentials = creds
+	return c
+}
+
+// WithDialOptions sets the other gRPC dial options of librarian connections.
+func (c *Config) WithDialOptions(dialOpts ...grpc.DialOption) *Config {
+	c.DialOptions = dialOpts
+	return c
+}
+
+// WithMaxMessageBytes sets the maximum size of gRPC messages sent to and received from librarians
+// to the given value or the default if it is zero.
+func (c *Config) WithMaxMessageBytes(maxBytes uint64) *Config {
+	if maxBytes == 0 {
+		return c.WithDefaultMaxMessageBytes()
+	}
+	c.MaxMessageBytes = maxBytes
+	return c
+}
+
+// WithDefaultMaxMessageBytes sets the maximum size of gRPC messages sent to and received from
+// librarians to DefaultMaxMessageBytes.
+func (c *Config) WithDefaultMaxMessageBytes() *Config {
+	c.MaxMessageBytes = DefaultMaxMessageBytes
+	return c
+}
+
+// dialOptions returns the gRPC dial options of librarian connections, which are the DialOptions
+// followed by the max message size.
+func (c *Config) dialOptions() []grpc.DialOption {
+	dialOpts := append([]grpc.DialOption{}, c.DialOptions...)
+	if c.MaxMessageBytes > 0 {
+		dialOpts = append(dialOpts, api.WithMaxMessageBytes(int(c.MaxMessageBytes)))
+	}
+	return dialOpts
+}
+
+// downloadMultiParallelism returns the max number of envelopes concurrently downloaded by
+// DownloadMulti, which is at least one.
+func (c *Config) downloadMultiParallelism() int {
+	if c.DownloadMultiParallelism > 0 {
+		return int(c.DownloadMultiParallelism)
+	}
+	if len(c.LibrarianAddrs) > 0 {
+		return len(c.LibrarianAddrs)
+	}
+	return 1
+}
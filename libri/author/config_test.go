@@ -1,25 +1,39 @@
 package author
 
 import (
+	"crypto/tls"
 	"net"
 	"testing"
+	"time"
 
+	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/print"
 	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/common/clock"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/librarian/server"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 func TestNewDefaultConfig(t *testing.T) {
 	c := NewDefaultConfig()
 	assert.NotEmpty(t, c.DataDir)
 	assert.NotEmpty(t, c.DbDir)
+	assert.NotEmpty(t, c.DBBackend)
 	assert.NotEmpty(t, c.KeychainDir)
 	assert.NotEmpty(t, c.LibrarianAddrs)
 	assert.NotEmpty(t, c.Print)
 	assert.NotEmpty(t, c.Publish)
 	assert.NotEmpty(t, c.LogLevel)
+	assert.NotEmpty(t, c.LibrarianBalancer)
+	assert.NotNil(t, c.EncryptionScheme)
+	assert.NotZero(t, c.HealthcheckTimeout)
+	assert.NotZero(t, c.HealthcheckDeadline)
+	assert.NotZero(t, c.MaxMessageBytes)
 }
 
 func TestConfig_WithDataDir(t *testing.T) {
@@ -36,6 +50,13 @@ func TestConfig_WithDBDir(t *testing.T) {
 	assert.NotEqual(t, c1.DbDir, c3.WithDBDir("/some/other/dir").DbDir)
 }
 
+func TestConfig_WithDBBackend(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultDBBackend()
+	assert.Equal(t, c1.DBBackend, c2.WithDBBackend("").DBBackend)
+	assert.NotEqual(t, c1.DBBackend, c3.WithDBBackend(db.BadgerDBBackend).DBBackend)
+}
+
 func TestConfig_WithKeychainDir(t *testing.T) {
 	c1, c2, c3 := &Config{}, &Config{}, &Config{}
 	c1.WithDefaultKeychainDir()
@@ -84,3 +105,104 @@ func TestConfig_WithLogLevel(t *testing.T) {
 		c3.WithLogLevel(zapcore.DebugLevel).LogLevel,
 	)
 }
+
+func TestConfig_WithLibrarianBalancer(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultLibrarianBalancer()
+	assert.Equal(t, c1.LibrarianBalancer, c2.WithLibrarianBalancer("").LibrarianBalancer)
+	assert.NotEqual(t,
+		c1.LibrarianBalancer,
+		c3.WithLibrarianBalancer(LatencyAwareBalancer).LibrarianBalancer,
+	)
+}
+
+func TestConfig_WithEncryptionScheme(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultEncryptionScheme()
+	assert.Equal(t, c1.EncryptionScheme, c2.WithEncryptionScheme(nil).EncryptionScheme)
+	scheme := &fixedScheme{Scheme: enc.NewAESGCMScheme(), id: 7}
+	assert.Equal(t, scheme, c3.WithEncryptionScheme(scheme).EncryptionScheme)
+}
+
+func TestConfig_WithDownloadMultiParallelism(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	c1.WithDefaultLibrarianAddrs()
+	assert.Equal(t, uint(0), c1.WithDownloadMultiParallelism(0).DownloadMultiParallelism)
+	assert.Equal(t, len(c1.LibrarianAddrs), c1.downloadMultiParallelism())
+
+	c1.LibrarianAddrs = append(c1.LibrarianAddrs, c1.LibrarianAddrs[0])
+	assert.Equal(t, 2, c1.downloadMultiParallelism())
+
+	assert.Equal(t, uint(3), c1.WithDownloadMultiParallelism(3).DownloadMultiParallelism)
+	assert.Equal(t, 3, c1.downloadMultiParallelism())
+
+	// check at least one even without librarians
+	assert.Equal(t, 1, c2.downloadMultiParallelism())
+}
+
+func TestConfig_WithClock(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultClock()
+	assert.Equal(t, c1.Clock, c2.WithClock(nil).Clock)
+	fake := clock.NewFake(time.Unix(0, 0))
+	assert.Equal(t, fake, c3.WithClock(fake).Clock)
+}
+
+func TestConfig_WithHealthcheckTimeout(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultHealthcheckTimeout()
+	assert.Equal(t, c1.HealthcheckTimeout, c2.WithHealthcheckTimeout(0).HealthcheckTimeout)
+	assert.Equal(t, time.Second, c3.WithHealthcheckTimeout(time.Second).HealthcheckTimeout)
+}
+
+func TestConfig_WithHealthcheckDeadline(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultHealthcheckDeadline()
+	assert.Equal(t, c1.HealthcheckDeadline, c2.WithHealthcheckDeadline(0).HealthcheckDeadline)
+	assert.Equal(t, time.Second, c3.WithHealthcheckDeadline(time.Second).HealthcheckDeadline)
+}
+
+func TestConfig_WithTransportCredentials(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	assert.Nil(t, c1.WithTransportCredentials(nil).TransportCredentials)
+	creds := credentials.NewTLS(&tls.Config{})
+	assert.Equal(t, creds, c2.WithTransportCredentials(creds).TransportCredentials)
+}
+
+func TestConfig_WithDialOptions(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	assert.Nil(t, c1.WithDialOptions().DialOptions)
+	dialOpt := grpc.WithUserAgent("some user agent")
+	assert.Len(t, c2.WithDialOptions(dialOpt).DialOptions, 1)
+}
+
+func TestConfig_WithMaxMessageBytes(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultMaxMessageBytes()
+	assert.Equal(t, DefaultMaxMessageBytes, c1.MaxMessageBytes)
+	assert.Equal(t, c1.MaxMessageBytes, c2.WithMaxMessageBytes(0).MaxMessageBytes)
+	assert.Equal(t, uint64(8<<20), c3.WithMaxMessageBytes(8<<20).MaxMessageBytes)
+}
+
+func TestConfig_dialOptions(t *testing.T) {
+	// check only max message size by default
+	c := NewDefaultConfig()
+	assert.Len(t, c.dialOptions(), 1)
+
+	// check given dial options precede max message size
+	c.WithDialOptions(grpc.WithKeepaliveParams(keepalive.ClientParameters{}),
+		grpc.WithUserAgent("some user agent"))
+	assert.Len(t, c.dialOptions(), 3)
+	assert.Len(t, c.DialOptions, 2)
+
+	// check no max message size when zero
+	c.MaxMessageBytes = 0
+	assert.Len(t, c.dialOptions(), 2)
+}
+
+func TestConfig_WithEvents(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	events := make(chan *Event, 1)
+	assert.Nil(t, c1.WithEvents(nil).Events)
+	assert.Equal(t, (chan<- *Event)(events), c2.WithEvents(events).Events)
+}
@@ -0,0 +1,113 @@
+package author
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/drausin/libri/libri/common/id"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const (
+	// downloadFileMode is the permissions mode of files downloaded to a path.
+	downloadFileMode = 0644
+
+	// downloadTempSuffix is the suffix of the temporary file pattern used when downloading to a
+	// path.
+	downloadTempSuffix = ".download-"
+)
+
+// ErrDownloadFileExists indicates when a download would overwrite an existing file but the
+// download options disallow it.
+var ErrDownloadFileExists = errors.New("download file already exists")
+
+// DownloadToFile downloads the content to the file at the given path. The content is written to
+// a temporary file in the same directory, which is then synced and atomically renamed to the
+// path, so a failed download never leaves a partial file at the path.
+func (a *Author) DownloadToFile(path string, envKey id.ID) error {
+	return a.DownloadToFileWithOpts(context.Background(), path, envKey, nil)
+}
+
+// DownloadToFileWithOpts is like DownloadToFile but with a context and optional parameters, like
+// DownloadWithOpts. Nil opts are equivalent to DownloadToFile.
+func (a *Author) DownloadToFileWithOpts(
+	ctx context.Context, path string, envKey id.ID, opts *DownloadOpts,
+) error {
+	if opts.noOverwrite() {
+		if _, err := os.Stat(path); err == nil {
+			return ErrDownloadFileExists
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+downloadTempSuffix)
+	if err != nil {
+		return err
+	}
+	if err := a.downloadToTemp(ctx, tmp, envKey, opts); err != nil {
+		// remove the partial download, keeping the download error over any removal error
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := moveDownload(tmp.Name(), path, opts.noOverwrite()); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	a.logger.Debug("moved download to file", zap.String("path", path))
+	return nil
+}
+
+// downloadToTemp downloads the content to the temporary file, syncing it to disk and closing it.
+func (a *Author) downloadToTemp(
+	ctx context.Context, tmp *os.File, envKey id.ID, opts *DownloadOpts,
+) error {
+	if _, err := a.DownloadWithOpts(ctx, tmp, envKey, opts); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(downloadFileMode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	return tmp.Close()
+}
+
+// moveDownload atomically moves the temporary file to the path. When not overwriting, it links
+// rather than renames the file, which atomically fails if something has been created at the path
+// since it was checked.
+func moveDownload(tmpPath, path string, noOverwrite bool) error {
+	if !noOverwrite {
+		return os.Rename(tmpPath, path)
+	}
+	if err := os.Link(tmpPath, path); err != nil {
+		if os.IsExist(err) {
+			return ErrDownloadFileExists
+		}
+		return err
+	}
+	return os.Remove(tmpPath)
+}
+
+// syncDir syncs the directory so a rename within it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
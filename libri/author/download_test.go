@@ -0,0 +1,154 @@
+package author
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestAuthor_DownloadToFile_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	envKey := id.NewPseudoRandom(rng)
+	dir, err := ioutil.TempDir("", "author-download-test")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	content := api.RandBytes(rng, 1024)
+	a := newDownloadAuthor(rng, content, nil)
+	path := filepath.Join(dir, "downloaded")
+
+	// check content is written to path, leaving no temp file behind
+	err = a.DownloadToFile(path, envKey)
+	assert.Nil(t, err)
+	checkDownloadedFile(t, dir, path, content)
+
+	// check existing file is overwritten by default
+	content = api.RandBytes(rng, 512)
+	a = newDownloadAuthor(rng, content, nil)
+	err = a.DownloadToFile(path, envKey)
+	assert.Nil(t, err)
+	checkDownloadedFile(t, dir, path, content)
+
+	// check new file is written when not overwriting
+	path2 := filepath.Join(dir, "downloaded2")
+	opts := &DownloadOpts{NoOverwrite: true}
+	err = a.DownloadToFileWithOpts(context.Background(), path2, envKey, opts)
+	assert.Nil(t, err)
+	written, err := ioutil.ReadFile(path2)
+	assert.Nil(t, err)
+	assert.Equal(t, content, written)
+}
+
+func TestAuthor_DownloadToFile_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	envKey := id.NewPseudoRandom(rng)
+	dir, err := ioutil.TempDir("", "author-download-test")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	path := filepath.Join(dir, "downloaded")
+	existing := api.RandBytes(rng, 256)
+	err = ioutil.WriteFile(path, existing, downloadFileMode)
+	assert.Nil(t, err)
+
+	// check existing file isn't overwritten when disallowed
+	a := newDownloadAuthor(rng, api.RandBytes(rng, 1024), nil)
+	opts := &DownloadOpts{NoOverwrite: true}
+	err = a.DownloadToFileWithOpts(context.Background(), path, envKey, opts)
+	assert.Equal(t, ErrDownloadFileExists, err)
+	checkDownloadedFile(t, dir, path, existing)
+
+	// check failed download removes temp file and leaves existing file as is
+	a = newDownloadAuthor(rng, api.RandBytes(rng, 1024), errors.New("some Unpack error"))
+	err = a.DownloadToFile(path, envKey)
+	assert.NotNil(t, err)
+	checkDownloadedFile(t, dir, path, existing)
+
+	// check failed download doesn't create a file at the path
+	path2 := filepath.Join(dir, "downloaded2")
+	err = a.DownloadToFile(path2, envKey)
+	assert.NotNil(t, err)
+	_, err = os.Stat(path2)
+	assert.True(t, os.IsNotExist(err))
+
+	// check temp file creation error bubbles up
+	err = a.DownloadToFile(filepath.Join(dir, "missing-dir", "downloaded"), envKey)
+	assert.NotNil(t, err)
+}
+
+func TestMoveDownload_noOverwrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "author-download-test")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	tmpPath, path := filepath.Join(dir, "tmp"), filepath.Join(dir, "downloaded")
+	assert.Nil(t, ioutil.WriteFile(tmpPath, []byte("new"), downloadFileMode))
+	assert.Nil(t, ioutil.WriteFile(path, []byte("existing"), downloadFileMode))
+
+	// check file created at path after the exists check isn't overwritten
+	err = moveDownload(tmpPath, path, true)
+	assert.Equal(t, ErrDownloadFileExists, err)
+	written, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("existing"), written)
+}
+
+func newDownloadAuthor(rng *rand.Rand, content []byte, unpackErr error) *Author {
+	doc, _ := api.NewTestDocument(rng)
+	metadata, err := api.NewEntryMetadata("application/x-pdf", 1, api.RandBytes(rng, 32), 2,
+		api.RandBytes(rng, 32))
+	if err != nil {
+		panic(err)
+	}
+	return &Author{
+		logger:   clogging.NewDevInfoLogger(),
+		receiver: &fixedReceiver{entry: doc},
+		entryUnpacker: &writingUnpacker{
+			content:  content,
+			metadata: metadata,
+			err:      unpackErr,
+		},
+	}
+}
+
+func checkDownloadedFile(t *testing.T, dir, path string, expected []byte) {
+	written, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, written)
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(downloadFileMode), info.Mode().Perm())
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	for _, f := range files {
+		assert.NotContains(t, f.Name(), downloadTempSuffix)
+	}
+}
+
+// writingUnpacker writes (possibly partial) content before returning its metadata and error.
+type writingUnpacker struct {
+	content  []byte
+	metadata *api.Metadata
+	err      error
+}
+
+func (f *writingUnpacker) Unpack(
+	ctx context.Context, content io.Writer, entry *api.Document, keys *enc.EEK,
+) (*api.Metadata, error) {
+	n := len(f.content)
+	if f.err != nil {
+		n /= 2
+	}
+	if _, err := content.Write(f.content[:n]); err != nil {
+		return nil, err
+	}
+	return f.metadata, f.err
+}
@@ -0,0 +1,132 @@
+package author
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/drausin/libri/libri/author/io/comp"
+	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"go.uber.org/zap"
+)
+
+// ErrMissingEntrySize indicates when the metadata of a packed entry lacks its uncompressed or
+// ciphertext size.
+var ErrMissingEntrySize = errors.New("entry metadata missing size")
+
+// UploadEstimate describes what uploading some content would involve without shipping it.
+type UploadEstimate struct {
+	// NPages is the number of pages the content is split into.
+	NPages int
+
+	// UncompressedSize is the size (in bytes) of the original content.
+	UncompressedSize uint64
+
+	// CiphertextSize is the size (in bytes) of the compressed and encrypted content.
+	CiphertextSize uint64
+
+	// NPuts is the number of documents (pages, entry, and envelope) an upload would Put to
+	// librarians, i.e., the number of network operations it would use.
+	NPuts int
+}
+
+// EstimateUpload compresses, encrypts, and splits the content into pages like Upload but stops
+// before shipping anything to the libri network, returning an estimate of the upload. The pages
+// are removed from local storage afterwards, even when estimating fails.
+func (a *Author) EstimateUpload(content io.Reader, mediaType string) (
+	estimate *UploadEstimate, err error) {
+	content, mediaType, err = a.detectMediaType(content, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, err = normalizeMediaType(mediaType)
+	if err != nil {
+		return nil, err
+	}
+	authorKey, err := a.authorKeys.Sample()
+	if err != nil {
+		return nil, err
+	}
+	eek, err := enc.NewEEK()
+	if err != nil {
+		return nil, err
+	}
+	defer eek.Zero()
+	entry, metadata, err := a.entryPacker.Pack(content, mediaType, comp.AutoCodec, 0,
+		time.Time{}, eek, authorKey.PublicKeyBytes())
+	if err != nil {
+		return nil, err
+	}
+	pageKeys, err := packedPageKeys(entry)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err2 := a.deletePages(pageKeys); err2 != nil && err == nil {
+			estimate, err = nil, err2
+		}
+	}()
+
+	uncompressedSize, ok := metadata.GetUncompressedSize()
+	if !ok {
+		return nil, ErrMissingEntrySize
+	}
+	ciphertextSize, ok := metadata.GetCiphertextSize()
+	if !ok {
+		return nil, ErrMissingEntrySize
+	}
+	estimate = &UploadEstimate{
+		NPages:           len(pageKeys),
+		UncompressedSize: uncompressedSize,
+		CiphertextSize:   ciphertextSize,
+	}
+	estimate.NPuts = estimate.NPages + 2 // pages plus entry and envelope
+	if estimate.NPages == 1 {
+		// single page is contained in the entry itself
+		estimate.NPuts = 2
+	}
+	a.logger.Debug("estimated upload",
+		zap.Int(LoggerNPages, estimate.NPages),
+		zap.Uint64("uncompressed_size", estimate.UncompressedSize),
+		zap.Uint64("ciphertext_size", estimate.CiphertextSize),
+		zap.Int("n_puts", estimate.NPuts),
+	)
+	return estimate, nil
+}
+
+// packedPageKeys returns the keys of the pages of a packed entry, including the single page
+// contained in the entry itself.
+func packedPageKeys(entry *api.Document) ([]id.ID, error) {
+	pageKeys, err := api.GetEntryPageKeys(entry)
+	if err != nil {
+		return nil, err
+	}
+	if pageKeys == nil {
+		// single page is contained in the entry itself
+		pageDoc := &api.Document{
+			Contents: &api.Document_Page{
+				Page: entry.Contents.(*api.Document_Entry).Entry.Contents.(*api.Entry_Page).Page,
+			},
+		}
+		pageKey, err := api.GetKey(pageDoc)
+		if err != nil {
+			return nil, err
+		}
+		pageKeys = []id.ID{pageKey}
+	}
+	return pageKeys, nil
+}
+
+// deletePages deletes the pages with the given keys from local storage, trying all of them before
+// returning the first error.
+func (a *Author) deletePages(pageKeys []id.ID) error {
+	var firstErr error
+	for _, pageKey := range pageKeys {
+		if err := a.documentSLD.Delete(pageKey); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,180 @@
+package author
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/author/io/common"
+	"github.com/drausin/libri/libri/author/io/comp"
+	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/author/io/pack"
+	"github.com/drausin/libri/libri/author/io/page"
+	"github.com/drausin/libri/libri/author/io/print"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthor_EstimateUpload_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+
+	for _, uncompressedSize := range []int{64, 1024} {
+		content := common.NewCompressableBytes(rng, uncompressedSize)
+		estimate, err := a.EstimateUpload(content, "application/x-pdf")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(uncompressedSize), estimate.UncompressedSize)
+		assert.True(t, estimate.CiphertextSize > 0)
+		assert.True(t, estimate.NPages > 0)
+		if estimate.NPages == 1 {
+			assert.Equal(t, 2, estimate.NPuts)
+		} else {
+			assert.Equal(t, estimate.NPages+2, estimate.NPuts)
+		}
+
+		// check no pages are left in local storage
+		nDocs := 0
+		err = storage.NewDocumentIterator(a.db).Iterate(
+			func(key id.ID, value *api.Document) error {
+				nDocs++
+				return nil
+			})
+		assert.Nil(t, err)
+		assert.Zero(t, nDocs)
+	}
+}
+
+func TestAuthor_EstimateUpload_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+
+	// check Pack error bubbles up
+	a.entryPacker = &fixedEntryPacker{err: errors.New("some Pack error")}
+	estimate, err := a.EstimateUpload(common.NewCompressableBytes(rng, 64), "application/x-pdf")
+	assert.NotNil(t, err)
+	assert.Nil(t, estimate)
+
+	// check unexpected entry document error bubbles up
+	envelope := &api.Document{
+		Contents: &api.Document_Envelope{
+			Envelope: api.NewTestEnvelope(rng),
+		},
+	}
+	a.entryPacker = &fixedEntryPacker{entry: envelope}
+	estimate, err = a.EstimateUpload(common.NewCompressableBytes(rng, 64), "application/x-pdf")
+	assert.NotNil(t, err)
+	assert.Nil(t, estimate)
+}
+
+func TestAuthor_EstimateUpload_missingSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+
+	// check missing size error bubbles up after the packed pages are removed
+	packer := a.entryPacker
+	for _, missing := range []string{
+		api.MetadataEntryUncompressedSize,
+		api.MetadataEntryCiphertextSize,
+	} {
+		a.entryPacker = &missingSizeEntryPacker{EntryPacker: packer, missing: missing}
+		content := common.NewCompressableBytes(rng, 1024)
+		estimate, err := a.EstimateUpload(content, "application/x-pdf")
+		assert.Equal(t, ErrMissingEntrySize, err)
+		assert.Nil(t, estimate)
+
+		nDocs := 0
+		err = storage.NewDocumentIterator(a.db).Iterate(
+			func(key id.ID, value *api.Document) error {
+				nDocs++
+				return nil
+			})
+		assert.Nil(t, err)
+		assert.Zero(t, nDocs)
+	}
+}
+
+func TestAuthor_EstimateUpload_packErr(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	content := common.NewCompressableBytes(rng, 64).Bytes()
+
+	// get the size of the single page, which is contained in the (larger) entry
+	entry, _, err := a.entryPacker.Pack(bytes.NewReader(content), "application/x-pdf",
+		comp.AutoCodec, 0, time.Time{}, enc.NewPseudoRandomEEK(rng), api.RandBytes(rng, 65))
+	assert.Nil(t, err)
+	pageKeys, err := packedPageKeys(entry)
+	assert.Nil(t, err)
+	assert.Nil(t, a.deletePages(pageKeys))
+	pageDoc := &api.Document{
+		Contents: &api.Document_Page{
+			Page: entry.Contents.(*api.Document_Entry).Entry.Contents.(*api.Entry_Page).Page,
+		},
+	}
+
+	// check entry too large error bubbles up after the stored page is removed
+	a.config.Print.MaxDocumentBytes = uint64(proto.Size(pageDoc))
+	estimate, err := a.EstimateUpload(bytes.NewReader(content), "application/x-pdf")
+	assert.Equal(t, print.ErrDocumentTooLarge, err)
+	assert.Nil(t, estimate)
+
+	nDocs := 0
+	err = storage.NewDocumentIterator(a.db).Iterate(
+		func(key id.ID, value *api.Document) error {
+			nDocs++
+			return nil
+		})
+	assert.Nil(t, err)
+	assert.Zero(t, nDocs)
+}
+
+// missingSizeEntryPacker packs entries whose metadata lacks the given size property.
+type missingSizeEntryPacker struct {
+	pack.EntryPacker
+	missing string
+}
+
+func (p *missingSizeEntryPacker) Pack(
+	content io.Reader,
+	mediaType string,
+	codec comp.Codec,
+	pageSize uint32,
+	expiry time.Time,
+	keys *enc.EEK,
+	authorPub []byte,
+) (*api.Document, *api.Metadata, error) {
+	entry, metadata, err := p.EntryPacker.Pack(content, mediaType, codec, pageSize, expiry,
+		keys, authorPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	delete(metadata.Properties, p.missing)
+	return entry, metadata, nil
+}
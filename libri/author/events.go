@@ -0,0 +1,111 @@
+package author
+
+import (
+	"time"
+
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/common/id"
+)
+
+// EventType is the type of an upload or download lifecycle Event.
+type EventType int
+
+const (
+	// UploadStarted indicates an upload has started packing its content.
+	UploadStarted EventType = iota
+
+	// PageShipped indicates a page of an upload has been shipped to libri.
+	PageShipped
+
+	// UploadCompleted indicates an upload has shipped its entry and envelope.
+	UploadCompleted
+
+	// UploadFailed indicates an upload has failed.
+	UploadFailed
+
+	// DownloadStarted indicates a download has started receiving its envelope.
+	DownloadStarted
+
+	// PageReceived indicates a page of a download has been received from libri.
+	PageReceived
+
+	// DownloadCompleted indicates a download has written all its content.
+	DownloadCompleted
+
+	// DownloadFailed indicates a download has failed.
+	DownloadFailed
+)
+
+var eventTypeNames = map[EventType]string{
+	UploadStarted:     "upload_started",
+	PageShipped:       "page_shipped",
+	UploadCompleted:   "upload_completed",
+	UploadFailed:      "upload_failed",
+	DownloadStarted:   "download_started",
+	PageReceived:      "page_received",
+	DownloadCompleted: "download_completed",
+	DownloadFailed:    "download_failed",
+}
+
+func (t EventType) String() string {
+	if name, in := eventTypeNames[t]; in {
+		return name
+	}
+	return "unknown"
+}
+
+// Event is a structured upload or download lifecycle event, which the Author sends on the
+// Config Events channel for integrating with an application's own telemetry.
+type Event struct {
+	// Type is the type of event.
+	Type EventType
+
+	// EnvelopeKey is the key of the uploaded or downloaded envelope, or nil if it isn't known
+	// yet, e.g., before an upload has shipped its envelope.
+	EnvelopeKey id.ID
+
+	// EntryKey is the key of the uploaded or downloaded entry, or nil if it isn't known yet,
+	// e.g., before an upload has packed its entry or a download has received it.
+	EntryKey id.ID
+
+	// PageIndex is the zero-based index, in the order they're shipped or received, of the page
+	// of PageShipped and PageReceived events.
+	PageIndex int
+
+	// Time is when the event happened.
+	Time time.Time
+}
+
+// emit sends the event on the Events channel, if there is one, without blocking. Events are
+// dropped when the channel is full, so a slow consumer can't stall uploads or downloads.
+func (a *Author) emit(event *Event) {
+	if a.events == nil {
+		return
+	}
+	event.Time = time.Now()
+	select {
+	case a.events <- event:
+	default:
+	}
+}
+
+// emitPages wraps the progress callback to also emit an event of the given type for each page
+// shipped or received.
+func (a *Author) emitPages(
+	eventType EventType, envKey, entryKey id.ID, progress publish.Progress,
+) publish.Progress {
+	if a.events == nil {
+		return progress
+	}
+	return func(pagesDone, pagesTotal int, bytesDone uint64) {
+		a.emit(&Event{
+			Type:        eventType,
+			EnvelopeKey: envKey,
+			EntryKey:    entryKey,
+			PageIndex:   pagesDone - 1,
+		})
+		if progress != nil {
+			progress(pagesDone, pagesTotal, bytesDone)
+		}
+	}
+}
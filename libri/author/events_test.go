@@ -0,0 +1,138 @@
+package author
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/author/io/common"
+	"github.com/drausin/libri/libri/author/io/page"
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/author/io/ship"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestEventType_String(t *testing.T) {
+	assert.Equal(t, "upload_started", UploadStarted.String())
+	assert.Equal(t, "download_failed", DownloadFailed.String())
+	assert.Equal(t, "unknown", EventType(-1).String())
+}
+
+func TestAuthor_emit(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	envKey := id.NewPseudoRandom(rng)
+
+	// check no events channel doesn't emit
+	a1 := &Author{}
+	a1.emit(&Event{Type: UploadStarted})
+
+	// check event is sent with time
+	events := make(chan *Event, 1)
+	a2 := &Author{events: events}
+	a2.emit(&Event{Type: DownloadStarted, EnvelopeKey: envKey})
+	event := <-events
+	assert.Equal(t, DownloadStarted, event.Type)
+	assert.Equal(t, envKey, event.EnvelopeKey)
+	assert.False(t, event.Time.IsZero())
+
+	// check events are dropped rather than blocking when channel is full
+	a2.emit(&Event{Type: UploadStarted})
+	a2.emit(&Event{Type: UploadCompleted})
+	assert.Len(t, events, 1)
+	assert.Equal(t, UploadStarted, (<-events).Type)
+}
+
+func TestAuthor_emitPages(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	envKey := id.NewPseudoRandom(rng)
+
+	// check progress is unchanged without events channel
+	a1 := &Author{}
+	assert.Nil(t, a1.emitPages(PageReceived, envKey, nil, nil))
+
+	// check page events are emitted and inner progress still called
+	events := make(chan *Event, 2)
+	a2 := &Author{events: events}
+	nDone := 0
+	progress := a2.emitPages(PageReceived, envKey, nil, func(pagesDone, _ int, _ uint64) {
+		nDone = pagesDone
+	})
+	progress(1, 2, 10)
+	progress(2, 2, 20)
+	assert.Equal(t, 2, nDone)
+	for i := 0; i < 2; i++ {
+		event := <-events
+		assert.Equal(t, PageReceived, event.Type)
+		assert.Equal(t, envKey, event.EnvelopeKey)
+		assert.Equal(t, i, event.PageIndex)
+	}
+}
+
+func TestAuthor_UploadDownload_events(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	events := make(chan *Event, 64)
+	a := newTestAuthorWithConfig(newTestConfig().WithEvents(events))
+	a.librarians = &fixedClientBalancer{}
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	content := common.NewCompressableBytes(rng, 1024)
+	_, envKey, err := a.Upload(content, "application/x-gzip")
+	assert.Nil(t, err)
+	_, err = a.Download(new(bytes.Buffer), envKey)
+	assert.Nil(t, err)
+	close(events)
+
+	types := make([]EventType, 0)
+	nPages := make(map[EventType]int)
+	var entryKey id.ID
+	for event := range events {
+		if event.Type == PageShipped || event.Type == PageReceived {
+			assert.Equal(t, nPages[event.Type], event.PageIndex)
+			nPages[event.Type]++
+			continue
+		}
+		types = append(types, event.Type)
+		if event.Type == UploadCompleted {
+			entryKey = event.EntryKey
+			assert.Equal(t, envKey, event.EnvelopeKey)
+		}
+		if event.Type == DownloadCompleted {
+			assert.Equal(t, entryKey, event.EntryKey)
+			assert.Equal(t, envKey, event.EnvelopeKey)
+		}
+	}
+
+	// check lifecycle events are in order with an event for each page
+	assert.Equal(t, []EventType{
+		UploadStarted, UploadCompleted, DownloadStarted, DownloadCompleted,
+	}, types)
+	assert.NotNil(t, entryKey)
+	assert.True(t, nPages[PageShipped] > 1)
+	assert.Equal(t, nPages[PageShipped], nPages[PageReceived])
+
+	// check failed download emits event
+	events2 := make(chan *Event, 2)
+	a.events = events2
+	_, err = a.DownloadWithOpts(context.Background(), nil, envKey,
+		&DownloadOpts{SearchConcurrency: api.MaxSearchConcurrency + 1})
+	assert.NotNil(t, err)
+	assert.Equal(t, DownloadStarted, (<-events2).Type)
+	assert.Equal(t, DownloadFailed, (<-events2).Type)
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
@@ -1,27 +1,40 @@
 package author
 
 import (
+	"errors"
+
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/librarian/api"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"net"
 )
 
+// ErrUnknownLibrarianBalancer indicates when the configured librarian balancer strategy is unknown.
+var ErrUnknownLibrarianBalancer = errors.New("unknown librarian balancer")
+
 type envelopeKeySampler interface {
-	sample() ([]byte, []byte, *enc.KEK, *enc.EEK, error)
+	sample(identity string) ([]byte, []byte, *enc.KEK, *enc.EEK, error)
 }
 
 type envelopeKeySamplerImpl struct {
 	authorKeys     keychain.GetterSampler
+	identities     *identities
 	selfReaderKeys keychain.GetterSampler
 }
 
 // sample samples a random pair of keys (author and reader) for the author to use
-// in creating the document *Keys instance. The method returns the author and reader public keys
-// along with the *Keys object.
-func (s *envelopeKeySamplerImpl) sample() ([]byte, []byte, *enc.KEK, *enc.EEK, error) {
-	authorID, err := s.authorKeys.Sample()
+// in creating the document *Keys instance, with the author key from the keychain of the given
+// identity. The method returns the author and reader public keys along with the *Keys object.
+func (s *envelopeKeySamplerImpl) sample(identity string) (
+	[]byte, []byte, *enc.KEK, *enc.EEK, error) {
+	authorKeys, err := selectAuthorKeys(s.authorKeys, s.identities, identity)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	authorID, err := authorKeys.Sample()
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
@@ -43,16 +56,35 @@ func (s *envelopeKeySamplerImpl) sample() ([]byte, []byte, *enc.KEK, *enc.EEK, e
 // use var so it's easy to replace for tests w/o a single-method interface
 var getLibrarianHealthClients = func(
 	librarianAddrs []*net.TCPAddr,
+	creds credentials.TransportCredentials,
+	dialOpts ...grpc.DialOption,
 ) (map[string]healthpb.HealthClient, error) {
 
 	healthClients := make(map[string]healthpb.HealthClient)
 	for _, librarianAddr := range librarianAddrs {
-		addrStr := librarianAddr.String()
-		conn, err := grpc.Dial(addrStr, grpc.WithInsecure())
+		conn, err := api.Dial(librarianAddr, creds, dialOpts...)
 		if err != nil {
 			return nil, err
 		}
-		healthClients[addrStr] = healthpb.NewHealthClient(conn)
+		healthClients[librarianAddr.String()] = healthpb.NewHealthClient(conn)
 	}
 	return healthClients, nil
 }
+
+func newClientBalancer(
+	strategy string,
+	librarianAddrs []*net.TCPAddr,
+	creds credentials.TransportCredentials,
+	dialOpts ...grpc.DialOption,
+) (api.ClientBalancer, error) {
+	switch strategy {
+	case UniformRandomBalancer, "":
+		return api.NewUniformRandomClientBalancer(librarianAddrs, creds, dialOpts...)
+	case LatencyAwareBalancer:
+		return api.NewLatencyAwareClientBalancer(librarianAddrs, creds, dialOpts...)
+	case RoundRobinBalancer:
+		return api.NewRoundRobinClientBalancer(librarianAddrs, creds, dialOpts...)
+	default:
+		return nil, ErrUnknownLibrarianBalancer
+	}
+}
@@ -3,6 +3,7 @@ package author
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/tls"
 	"math/rand"
 	"testing"
 
@@ -11,7 +12,10 @@ import (
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/keychain"
 	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"net"
 )
 
@@ -21,7 +25,7 @@ func TestEnvelopeKeySampler_Sample_ok(t *testing.T) {
 		authorKeys:     authorKeys,
 		selfReaderKeys: selfReaderKeys,
 	}
-	authPubBytes, srPubBytes, kek1, eek, err := s.sample()
+	authPubBytes, srPubBytes, kek1, eek, err := s.sample(DefaultIdentity)
 	assert.Nil(t, err)
 	assert.NotNil(t, authPubBytes)
 	assert.NotNil(t, srPubBytes)
@@ -49,7 +53,7 @@ func TestEnvelopeKeySampler_Sample_err(t *testing.T) {
 		authorKeys:     &fixedKeychain{sampleErr: errors.New("some Sample error")},
 		selfReaderKeys: keychain.New(3),
 	}
-	aPB, srPB, kek, eek, err := s1.sample()
+	aPB, srPB, kek, eek, err := s1.sample(DefaultIdentity)
 	assert.NotNil(t, err)
 	assert.Nil(t, aPB)
 	assert.Nil(t, srPB)
@@ -61,7 +65,7 @@ func TestEnvelopeKeySampler_Sample_err(t *testing.T) {
 		authorKeys:     keychain.New(3),
 		selfReaderKeys: &fixedKeychain{sampleErr: errors.New("some Sample error")},
 	}
-	aPB, srPB, kek, eek, err = s2.sample()
+	aPB, srPB, kek, eek, err = s2.sample(DefaultIdentity)
 	assert.NotNil(t, err)
 	assert.Nil(t, aPB)
 	assert.Nil(t, srPB)
@@ -77,7 +81,7 @@ func TestEnvelopeKeySampler_Sample_err(t *testing.T) {
 		},
 		selfReaderKeys: keychain.New(3),
 	}
-	aPB, srPB, kek, eek, err = s3.sample()
+	aPB, srPB, kek, eek, err = s3.sample(DefaultIdentity)
 	assert.NotNil(t, err)
 	assert.Nil(t, aPB)
 	assert.Nil(t, srPB)
@@ -92,13 +96,59 @@ func TestGetLibrarianHealthClients(t *testing.T) {
 		{IP: net.ParseIP("127.0.0.1"), Port: 20100},
 		{IP: net.ParseIP("127.0.0.1"), Port: 20101},
 	}
-	healthClients, err := getLibrarianHealthClients(librarianAddrs)
+	healthClients, err := getLibrarianHealthClients(librarianAddrs, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(healthClients))
 	_, in := healthClients["127.0.0.1:20100"]
 	assert.True(t, in)
 	_, in = healthClients["127.0.0.1:20101"]
 	assert.True(t, in)
+
+	// check dial options without transport security don't replace insecure default
+	healthClients, err = getLibrarianHealthClients(librarianAddrs, nil,
+		grpc.WithUserAgent("some user agent"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(healthClients))
+
+	// check transport credentials are used
+	healthClients, err = getLibrarianHealthClients(librarianAddrs,
+		credentials.NewTLS(&tls.Config{}))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(healthClients))
+}
+
+func TestNewClientBalancer(t *testing.T) {
+	librarianAddrs := []*net.TCPAddr{
+		{IP: net.ParseIP("127.0.0.1"), Port: 20100},
+		{IP: net.ParseIP("127.0.0.1"), Port: 20101},
+	}
+	strategies := []string{
+		"",
+		UniformRandomBalancer,
+		LatencyAwareBalancer,
+		RoundRobinBalancer,
+	}
+	for _, strategy := range strategies {
+		cb, err := newClientBalancer(strategy, librarianAddrs, nil)
+		assert.Nil(t, err)
+		assert.NotNil(t, cb)
+	}
+	cb, err := newClientBalancer(LatencyAwareBalancer, librarianAddrs, nil)
+	assert.Nil(t, err)
+	_, ok := cb.(api.LatencyObserver)
+	assert.True(t, ok)
+
+	cb, err = newClientBalancer("some other strategy", librarianAddrs, nil)
+	assert.Equal(t, ErrUnknownLibrarianBalancer, err)
+	assert.Nil(t, cb)
+
+	// check credentials and dial options are passed to the balancer's connections
+	cb, err = newClientBalancer(RoundRobinBalancer, librarianAddrs,
+		credentials.NewTLS(&tls.Config{}), grpc.WithUserAgent("some user agent"))
+	assert.Nil(t, err)
+	lc, err := cb.Next()
+	assert.Nil(t, err)
+	assert.NotNil(t, lc)
 }
 
 type fixedKeychain struct {
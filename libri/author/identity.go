@@ -0,0 +1,107 @@
+package author
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/ecid"
+)
+
+// DefaultIdentity is the name of the identity whose author keychain is given to NewAuthor. Uploads
+// and shares that don't select an identity use it.
+const DefaultIdentity = ""
+
+var (
+	// ErrUnknownIdentity indicates when an upload or share selects an identity the author
+	// doesn't have.
+	ErrUnknownIdentity = errors.New("unknown identity")
+
+	// ErrIdentityExists indicates when adding an identity with the name of an existing one.
+	ErrIdentityExists = errors.New("identity already exists")
+)
+
+// identities is a registry of named author keychains in addition to the default one, e.g., for
+// separate personal and work identities within one author.
+type identities struct {
+	keychains map[string]keychain.GetterSampler
+	mu        sync.RWMutex
+}
+
+func newIdentities() *identities {
+	return &identities{
+		keychains: make(map[string]keychain.GetterSampler),
+	}
+}
+
+// add registers the author keychain under the given name, which must not already be taken.
+func (ids *identities) add(name string, authorKeys keychain.GetterSampler) error {
+	if name == DefaultIdentity {
+		return ErrIdentityExists
+	}
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	if _, in := ids.keychains[name]; in {
+		return ErrIdentityExists
+	}
+	ids.keychains[name] = authorKeys
+	return nil
+}
+
+// get returns the author keychain registered under the given name. A nil *identities has no
+// keychains.
+func (ids *identities) get(name string) (keychain.GetterSampler, error) {
+	if ids == nil {
+		return nil, ErrUnknownIdentity
+	}
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	authorKeys, in := ids.keychains[name]
+	if !in {
+		return nil, ErrUnknownIdentity
+	}
+	return authorKeys, nil
+}
+
+// Get returns the key with the given public key from any of the registered author keychains,
+// so the identities can be used as a keychain.Getter.
+func (ids *identities) Get(publicKey []byte) (ecid.ID, bool) {
+	if ids == nil {
+		return nil, false
+	}
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	for _, authorKeys := range ids.keychains {
+		if key, in := authorKeys.Get(publicKey); in {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// addCounts adds the usage counts of each registered author keychain that counts them.
+func (ids *identities) addCounts(counts map[string]int) {
+	if ids == nil {
+		return
+	}
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	for _, authorKeys := range ids.keychains {
+		if counting, ok := authorKeys.(keychain.CountingGetterSampler); ok {
+			for pub, n := range counting.Counts() {
+				counts[pub] += n
+			}
+		}
+	}
+}
+
+// selectAuthorKeys returns the default author keychain for the DefaultIdentity and otherwise the
+// keychain registered under the identity.
+func selectAuthorKeys(
+	defaultKeys keychain.GetterSampler, ids *identities, identity string,
+) (keychain.GetterSampler, error) {
+	if identity == DefaultIdentity {
+		return defaultKeys, nil
+	}
+	return ids.get(identity)
+}
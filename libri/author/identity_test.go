@@ -0,0 +1,81 @@
+package author
+
+import (
+	"testing"
+
+	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentities_addGet(t *testing.T) {
+	ids := newIdentities()
+	workKeys, personalKeys := keychain.New(1), keychain.New(1)
+	assert.Nil(t, ids.add("work", workKeys))
+	assert.Nil(t, ids.add("personal", personalKeys))
+
+	// check can't add existing or default identity
+	assert.Equal(t, ErrIdentityExists, ids.add("work", keychain.New(1)))
+	assert.Equal(t, ErrIdentityExists, ids.add(DefaultIdentity, keychain.New(1)))
+
+	authorKeys, err := ids.get("work")
+	assert.Nil(t, err)
+	assert.Equal(t, workKeys, authorKeys)
+	authorKeys, err = ids.get("unknown")
+	assert.Equal(t, ErrUnknownIdentity, err)
+	assert.Nil(t, authorKeys)
+
+	// check Get finds keys from any identity
+	personalKey, err := personalKeys.Sample()
+	assert.Nil(t, err)
+	key, in := ids.Get(personalKey.PublicKeyBytes())
+	assert.True(t, in)
+	assert.Equal(t, personalKey, key)
+	key, in = ids.Get(ecid.NewRandom().PublicKeyBytes())
+	assert.False(t, in)
+	assert.Nil(t, key)
+}
+
+func TestIdentities_nil(t *testing.T) {
+	var ids *identities
+	authorKeys, err := ids.get("work")
+	assert.Equal(t, ErrUnknownIdentity, err)
+	assert.Nil(t, authorKeys)
+	key, in := ids.Get(ecid.NewRandom().PublicKeyBytes())
+	assert.False(t, in)
+	assert.Nil(t, key)
+	counts := map[string]int{}
+	ids.addCounts(counts)
+	assert.Empty(t, counts)
+}
+
+func TestIdentities_addCounts(t *testing.T) {
+	ids := newIdentities()
+	workKeys := keychain.NewCounting(keychain.New(1))
+	assert.Nil(t, ids.add("work", workKeys))
+	assert.Nil(t, ids.add("personal", keychain.New(1))) // not counted
+	_, err := workKeys.Sample()
+	assert.Nil(t, err)
+
+	counts := map[string]int{}
+	ids.addCounts(counts)
+	assert.Equal(t, workKeys.Counts(), counts)
+}
+
+func TestSelectAuthorKeys(t *testing.T) {
+	defaultKeys, workKeys := keychain.New(1), keychain.New(1)
+	ids := newIdentities()
+	assert.Nil(t, ids.add("work", workKeys))
+
+	authorKeys, err := selectAuthorKeys(defaultKeys, ids, DefaultIdentity)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultKeys, authorKeys)
+
+	authorKeys, err = selectAuthorKeys(defaultKeys, ids, "work")
+	assert.Nil(t, err)
+	assert.Equal(t, workKeys, authorKeys)
+
+	authorKeys, err = selectAuthorKeys(defaultKeys, ids, "unknown")
+	assert.Equal(t, ErrUnknownIdentity, err)
+	assert.Nil(t, authorKeys)
+}
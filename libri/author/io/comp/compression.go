@@ -25,6 +25,10 @@ const (
 	// DefaultCodec defines the default comp.scheme.
 	DefaultCodec = GZIPCodec
 
+	// AutoCodec indicates that the codec should be chosen from the media type via
+	// GetCompressionCodec.
+	AutoCodec Codec = ""
+
 	// MinBufferSize is the minimum size of the uncompressed buffer used by the
 	// compressor and decompressor.
 	MinBufferSize = uint32(64)
@@ -37,6 +41,9 @@ const (
 // ErrBufferSizeTooSmall indicates when the max page size is too small (often because it is zero).
 var ErrBufferSizeTooSmall = fmt.Errorf("buffer size is below %d byte minimum", MinBufferSize)
 
+// ErrUnexpectedCodec indicates when a codec is not one of the known values.
+var ErrUnexpectedCodec = errors.New("unexpected compression codec")
+
 // MediaToCompressionCodec maps MIME media types to what comp.codec should be used with
 // them.
 var MediaToCompressionCodec = map[string]Codec{
@@ -64,6 +71,18 @@ func GetCompressionCodec(mediaType string) (Codec, error) {
 	return DefaultCodec, nil
 }
 
+// ResolveCodec returns the given codec, or the codec to use given a MIME media type if it is
+// AutoCodec.
+func ResolveCodec(codec Codec, mediaType string) (Codec, error) {
+	switch codec {
+	case AutoCodec:
+		return GetCompressionCodec(mediaType)
+	case NoneCodec, GZIPCodec:
+		return codec, nil
+	}
+	return AutoCodec, ErrUnexpectedCodec
+}
+
 // CloseWriter is an io.Writer that requires Close() to be called at the end of writing.
 type CloseWriter interface {
 	io.Writer
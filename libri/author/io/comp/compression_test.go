@@ -38,6 +38,30 @@ func TestGetCompressionCodec(t *testing.T) {
 
 }
 
+func TestResolveCodec(t *testing.T) {
+	// check auto codec is chosen from media type
+	c1, err := ResolveCodec(AutoCodec, "application/x-gzip")
+	assert.Equal(t, NoneCodec, c1)
+	assert.Nil(t, err)
+
+	// check not-nil err on bad media type with auto codec
+	_, err = ResolveCodec(AutoCodec, "/blah")
+	assert.NotNil(t, err)
+
+	// check explicit codec overrides media type
+	c3, err := ResolveCodec(GZIPCodec, "application/x-gzip")
+	assert.Equal(t, GZIPCodec, c3)
+	assert.Nil(t, err)
+	c4, err := ResolveCodec(NoneCodec, "application/pdf")
+	assert.Equal(t, NoneCodec, c4)
+	assert.Nil(t, err)
+
+	// check unknown codec
+	c5, err := ResolveCodec(Codec("unknown"), "application/pdf")
+	assert.Equal(t, AutoCodec, c5)
+	assert.Equal(t, ErrUnexpectedCodec, err)
+}
+
 func TestNewCompressor_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	keys := enc.NewPseudoRandomEEK(rng)
@@ -1,13 +1,32 @@
 package enc
 
 import (
+	"bytes"
 	"crypto/cipher"
 	"crypto/hmac"
+	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
 	"hash"
 )
 
+// PageBindingLength is the length of the random page binding generated for each entry.
+const PageBindingLength = 32
+
+// NewPageBinding generates a new random page binding for an entry. Each page of the entry is
+// encrypted with the binding and its index as associated data, so decrypting a page fails if it
+// is moved into a different entry, even one encrypted with the same EEK.
+//
+// Pages can't be bound to the entry key itself since it is the hash of the entry, which includes
+// the page ciphertexts (via their keys).
+func NewPageBinding() ([]byte, error) {
+	binding := make([]byte, PageBindingLength)
+	if _, err := crand.Read(binding); err != nil {
+		return nil, err
+	}
+	return binding, nil
+}
+
 // Encrypter encrypts (compressed) plaintext of a page.
 type Encrypter interface {
 	// Encrypt encrypts the given plaintext for a given pageIndex, returning the ciphertext.
@@ -15,25 +34,29 @@ type Encrypter interface {
 }
 
 type encrypter struct {
-	gcmCipher cipher.AEAD
-	pageIVMAC hash.Hash
+	gcmCipher   cipher.AEAD
+	pageIVMAC   hash.Hash
+	pageBinding []byte
 }
 
-// NewEncrypter creates a new Encrypter using the encryption keys.
-func NewEncrypter(keys *EEK) (Encrypter, error) {
+// NewEncrypter creates a new Encrypter using the encryption keys. If the page binding is not
+// empty, each page is encrypted with it and the page index as associated data.
+func NewEncrypter(keys *EEK, pageBinding []byte) (Encrypter, error) {
 	gcmCipher, err := newGCMCipher(keys.AESKey)
 	if err != nil {
 		return nil, err
 	}
 	return &encrypter{
-		gcmCipher: gcmCipher,
-		pageIVMAC: hmac.New(sha256.New, keys.PageIVSeed),
+		gcmCipher:   gcmCipher,
+		pageIVMAC:   hmac.New(sha256.New, keys.PageIVSeed),
+		pageBinding: pageBinding,
 	}, nil
 }
 
 func (e *encrypter) Encrypt(plaintext []byte, pageIndex uint32) ([]byte, error) {
 	pageIV := generatePageIV(pageIndex, e.pageIVMAC, e.gcmCipher.NonceSize())
-	ciphertext := e.gcmCipher.Seal(nil, pageIV, plaintext, nil)
+	pageAD := generatePageAD(pageIndex, e.pageBinding)
+	ciphertext := e.gcmCipher.Seal(nil, pageIV, plaintext, pageAD)
 	return ciphertext, nil
 }
 
@@ -44,25 +67,29 @@ type Decrypter interface {
 }
 
 type decrypter struct {
-	gcmCipher cipher.AEAD
-	pageIVMAC hash.Hash
+	gcmCipher   cipher.AEAD
+	pageIVMAC   hash.Hash
+	pageBinding []byte
 }
 
-// NewDecrypter creates a new Decrypter instance using the encryption keys.
-func NewDecrypter(keys *EEK) (Decrypter, error) {
+// NewDecrypter creates a new Decrypter instance using the encryption keys and the page binding
+// the pages were encrypted with, which is empty for entries encrypted without one.
+func NewDecrypter(keys *EEK, pageBinding []byte) (Decrypter, error) {
 	gcmCipher, err := newGCMCipher(keys.AESKey)
 	if err != nil {
 		return nil, err
 	}
 	return &decrypter{
-		gcmCipher: gcmCipher,
-		pageIVMAC: hmac.New(sha256.New, keys.PageIVSeed),
+		gcmCipher:   gcmCipher,
+		pageIVMAC:   hmac.New(sha256.New, keys.PageIVSeed),
+		pageBinding: pageBinding,
 	}, nil
 }
 
 func (d *decrypter) Decrypt(ciphertext []byte, pageIndex uint32) ([]byte, error) {
 	pageIV := generatePageIV(pageIndex, d.pageIVMAC, d.gcmCipher.NonceSize())
-	return d.gcmCipher.Open(nil, pageIV, ciphertext, nil)
+	pageAD := generatePageAD(pageIndex, d.pageBinding)
+	return d.gcmCipher.Open(nil, pageIV, ciphertext, pageAD)
 }
 
 func generatePageIV(pageIndex uint32, pageIVMac hash.Hash, size int) []byte {
@@ -72,3 +99,14 @@ func generatePageIV(pageIndex uint32, pageIVMac hash.Hash, size int) []byte {
 	iv := pageIVMac.Sum(pageIndexBytes)
 	return iv[:size]
 }
+
+// generatePageAD returns the associated data of a page, the page binding followed by the
+// big-endian page index, or nil without a page binding.
+func generatePageAD(pageIndex uint32, pageBinding []byte) []byte {
+	if len(pageBinding) == 0 {
+		return nil
+	}
+	pageIndexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(pageIndexBytes, pageIndex)
+	return bytes.Join([][]byte{pageBinding, pageIndexBytes}, []byte{})
+}
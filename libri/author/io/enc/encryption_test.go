@@ -4,20 +4,21 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewEncrypter_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	keys := NewPseudoRandomEEK(rng)
-	enc, err := NewEncrypter(keys)
+	enc, err := NewEncrypter(keys, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, enc.(*encrypter).gcmCipher)
 	assert.NotNil(t, enc.(*encrypter).pageIVMAC)
 }
 
 func TestNewEncrypter_err(t *testing.T) {
-	enc, err := NewEncrypter(&EEK{})
+	enc, err := NewEncrypter(&EEK{}, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, enc)
 }
@@ -25,14 +26,14 @@ func TestNewEncrypter_err(t *testing.T) {
 func TestNewDecrypter_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	keys := NewPseudoRandomEEK(rng)
-	enc, err := NewDecrypter(keys)
+	enc, err := NewDecrypter(keys, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, enc.(*decrypter).gcmCipher)
 	assert.NotNil(t, enc.(*decrypter).pageIVMAC)
 }
 
 func TestNewDecrypter_err(t *testing.T) {
-	enc, err := NewDecrypter(&EEK{})
+	enc, err := NewDecrypter(&EEK{}, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, enc)
 }
@@ -42,10 +43,10 @@ func TestEncryptDecrypt(t *testing.T) {
 	keys := NewPseudoRandomEEK(rng)
 	nPlaintextBytesPerPage, nPages := 32, uint32(3)
 
-	encrypter, err := NewEncrypter(keys)
+	encrypter, err := NewEncrypter(keys, nil)
 	assert.Nil(t, err)
 
-	decrypter, err := NewDecrypter(keys)
+	decrypter, err := NewDecrypter(keys, nil)
 	assert.Nil(t, err)
 
 	for p := uint32(0); p < nPages; p++ {
@@ -72,3 +73,44 @@ func TestEncryptDecrypt(t *testing.T) {
 		assert.Equal(t, plaintext1, plaintext2)
 	}
 }
+
+func TestNewPageBinding(t *testing.T) {
+	binding1, err := NewPageBinding()
+	assert.Nil(t, err)
+	assert.Len(t, binding1, PageBindingLength)
+	binding2, err := NewPageBinding()
+	assert.Nil(t, err)
+	assert.NotEqual(t, binding1, binding2)
+}
+
+func TestEncryptDecrypt_pageBinding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	keys := NewPseudoRandomEEK(rng)
+	binding1, binding2 := api.RandBytes(rng, PageBindingLength),
+		api.RandBytes(rng, PageBindingLength)
+	plaintext1 := api.RandBytes(rng, 32)
+
+	encrypter, err := NewEncrypter(keys, binding1)
+	assert.Nil(t, err)
+	ciphertext, err := encrypter.Encrypt(plaintext1, 1)
+	assert.Nil(t, err)
+
+	// check decrypting with same page binding succeeds
+	decrypter1, err := NewDecrypter(keys, binding1)
+	assert.Nil(t, err)
+	plaintext2, err := decrypter1.Decrypt(ciphertext, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, plaintext1, plaintext2)
+
+	// check decrypting with a different or no page binding fails
+	decrypter2, err := NewDecrypter(keys, binding2)
+	assert.Nil(t, err)
+	plaintext2, err = decrypter2.Decrypt(ciphertext, 1)
+	assert.NotNil(t, err)
+	assert.Nil(t, plaintext2)
+	decrypter3, err := NewDecrypter(keys, nil)
+	assert.Nil(t, err)
+	plaintext2, err = decrypter3.Decrypt(ciphertext, 1)
+	assert.NotNil(t, err)
+	assert.Nil(t, plaintext2)
+}
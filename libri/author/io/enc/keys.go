@@ -25,6 +25,9 @@ var ErrReaderOffCurve = errors.New("reader public key point not on expected elli
 // the required number of bytes for the encryption keys.
 var ErrIncompleteKeyDefinition = errors.New("incomplete key definition")
 
+// ErrEmptyEEKSecret indicates when an EEK is derived from an empty secret.
+var ErrEmptyEEKSecret = errors.New("empty EEK secret")
+
 // ErrInsufficientEEKBytes indicates when the crypto random number generator is unable to generate
 // the sufficient number of bytes for the EEK key.
 var ErrInsufficientEEKBytes = errors.New("insufficient EEK bytes")
@@ -84,6 +87,7 @@ func (kek *KEK) Encrypt(eek *EEK) ([]byte, []byte, error) {
 	}
 	plaintext := MarshalEEK(eek)
 	eekCiphertext := gcmCipher.Seal(nil, kek.IV, plaintext, nil)
+	zero(plaintext)
 	eekCiphertextMAC := HMAC(eekCiphertext, kek.HMACKey)
 	return eekCiphertext, eekCiphertextMAC, nil
 }
@@ -104,6 +108,19 @@ func (kek *KEK) Decrypt(eekCiphertext []byte, eekCiphertextMAC []byte) (*EEK, er
 	return UnmarshalEEK(eekPlaintext)
 }
 
+// Zero overwrites the KEK keys with zeros once they are no longer needed. It is best-effort only:
+// the Go runtime may have already copied the keys elsewhere in memory (e.g., when growing a stack),
+// and those copies remain until the garbage collector reuses their memory. Zero is a no-op on a
+// nil KEK.
+func (kek *KEK) Zero() {
+	if kek == nil {
+		return
+	}
+	zero(kek.AESKey)
+	zero(kek.IV)
+	zero(kek.HMACKey)
+}
+
 // MarshalKEK serializes the EEK to their byte representation.
 func MarshalKEK(keys *KEK) []byte {
 	return bytes.Join(
@@ -166,6 +183,28 @@ func NewEEK() (*EEK, error) {
 	return UnmarshalEEK(eekBytes)
 }
 
+// NewDerivedEEK deterministically derives an *EEK instance from the given secret, so the same
+// secret always gives the same EEK. Since pages are encrypted deterministically from their
+// content and EEK, uploads of the same content by the same author with a derived EEK have the same
+// pages. The secret should thus be at least as hard to guess as the content, e.g., a keyed hash
+// of it: anyone who learns the EEK (e.g., from an envelope shared with them) can decrypt every
+// upload encrypted with it.
+func NewDerivedEEK(secret []byte) (*EEK, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptyEEKSecret
+	}
+	kdf := hkdf.New(sha256.New, secret, nil, nil)
+	eekBytes := make([]byte, api.EEKLength)
+	n, err := kdf.Read(eekBytes)
+	if err != nil {
+		return nil, err
+	}
+	if n != api.EEKLength {
+		return nil, ErrIncompleteKeyDefinition
+	}
+	return UnmarshalEEK(eekBytes)
+}
+
 // NewPseudoRandomEEK generates a new *EEK instance from a random number generator for use in
 // testing.
 func NewPseudoRandomEEK(rng *mrand.Rand) *EEK {
@@ -184,6 +223,18 @@ func NewPseudoRandomEEK(rng *mrand.Rand) *EEK {
 	return eek
 }
 
+// Zero overwrites the EEK keys with zeros once they are no longer needed. Like KEK.Zero, it is
+// best-effort only. Zero is a no-op on a nil EEK.
+func (eek *EEK) Zero() {
+	if eek == nil {
+		return
+	}
+	zero(eek.AESKey)
+	zero(eek.PageIVSeed)
+	zero(eek.HMACKey)
+	zero(eek.MetadataIV)
+}
+
 // MarshalEEK serializes the EEK to their byte representation.
 func MarshalEEK(keys *EEK) []byte {
 	return bytes.Join(
@@ -220,6 +271,12 @@ func newGCMCipher(aesKey []byte) (cipher.AEAD, error) {
 	return cipher.NewGCM(block)
 }
 
+func zero(x []byte) {
+	for i := range x {
+		x[i] = 0
+	}
+}
+
 func next(x []byte, offset *int, len int) []byte {
 	next := x[*offset: *offset+len]
 	*offset += len
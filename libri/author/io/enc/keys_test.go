@@ -98,6 +98,19 @@ func TestKEK_Decrypt_err(t *testing.T) {
 	assert.Nil(t, eek)
 }
 
+func TestKEK_Zero(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kek, _, _ := NewPseudoRandomKEK(rng)
+	kek.Zero()
+	assert.Equal(t, make([]byte, api.AESKeyLength), kek.AESKey)
+	assert.Equal(t, make([]byte, api.BlockCipherIVLength), kek.IV)
+	assert.Equal(t, make([]byte, api.HMACKeyLength), kek.HMACKey)
+
+	// check nil KEK doesn't panic
+	var nilKEK *KEK
+	nilKEK.Zero()
+}
+
 func TestMarshallUnmarshallKEK_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	kek1, _, _ := NewPseudoRandomKEK(rng)
@@ -129,6 +142,35 @@ func TestNewEEK_ok(t *testing.T) {
 	}
 }
 
+func TestNewDerivedEEK(t *testing.T) {
+	eek1, err := NewDerivedEEK([]byte("some secret"))
+	assert.Nil(t, err)
+	assert.Len(t, MarshalEEK(eek1), api.EEKLength)
+
+	// check same secret gives the same EEK and a different one doesn't
+	eek2, err := NewDerivedEEK([]byte("some secret"))
+	assert.Nil(t, err)
+	assert.Equal(t, eek1, eek2)
+	eek3, err := NewDerivedEEK([]byte("some other secret"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, eek1, eek3)
+
+	eek4, err := NewDerivedEEK(nil)
+	assert.Equal(t, ErrEmptyEEKSecret, err)
+	assert.Nil(t, eek4)
+}
+
+func TestEEK_Zero(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	eek := NewPseudoRandomEEK(rng)
+	eek.Zero()
+	assert.Equal(t, make([]byte, api.EEKLength), MarshalEEK(eek))
+
+	// check nil EEK doesn't panic
+	var nilEEK *EEK
+	nilEEK.Zero()
+}
+
 func TestMarshallUnmarshall_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	eek1 := NewPseudoRandomEEK(rng)
@@ -33,6 +33,12 @@ func NewEncryptedMetadata(ciphertext, ciphertextMAC []byte) (*EncryptedMetadata,
 	}, nil
 }
 
+// NewEntryEncryptedMetadata creates a new *EncryptedMetadata instance from the metadata
+// ciphertext and ciphertext MAC of an entry.
+func NewEntryEncryptedMetadata(entry *api.Entry) (*EncryptedMetadata, error) {
+	return NewEncryptedMetadata(entry.MetadataCiphertext, entry.MetadataCiphertextMac)
+}
+
 // MetadataEncrypter encrypts *api.Metadata.
 type MetadataEncrypter interface {
 	// EncryptMetadata encrypts an *api.Metadata instance using the AES key and the MetadataIV
@@ -0,0 +1,91 @@
+package enc
+
+import "fmt"
+
+// SchemeID identifies the Scheme used to encrypt an entry. It is recorded in the entry, so a
+// Scheme's ID must never change once entries have been encrypted with it.
+type SchemeID uint32
+
+const (
+	// AESGCMSchemeID identifies the default AES-256-GCM Scheme, which is also implied by entries
+	// without a recorded scheme.
+	AESGCMSchemeID SchemeID = 0
+)
+
+// UnknownSchemeError indicates when an entry was encrypted with a Scheme that isn't available to
+// decrypt it.
+type UnknownSchemeError struct {
+	// ID is the identifier of the unknown Scheme.
+	ID SchemeID
+}
+
+func (e *UnknownSchemeError) Error() string {
+	return fmt.Sprintf("unknown encryption scheme %d", e.ID)
+}
+
+// Scheme encrypts and decrypts the metadata and pages of an entry using its EEK.
+type Scheme interface {
+	MetadataEncrypterDecrypter
+
+	// ID returns the identifier recorded in the entries encrypted with this scheme.
+	ID() SchemeID
+
+	// NewEncrypter creates a new page Encrypter using the encryption keys, binding each page to
+	// its entry with the page binding (see NewPageBinding) if it is not empty.
+	NewEncrypter(keys *EEK, pageBinding []byte) (Encrypter, error)
+
+	// NewDecrypter creates a new page Decrypter using the encryption keys and the page binding
+	// the pages were encrypted with.
+	NewDecrypter(keys *EEK, pageBinding []byte) (Decrypter, error)
+}
+
+type aesGCMScheme struct {
+	metadataEncDec
+}
+
+// NewAESGCMScheme returns the default Scheme. Pages and metadata are encrypted with AES-256-GCM
+// using the EEK AESKey. Each page's 12-byte nonce is the first 12 bytes of the HMAC-SHA256 of its
+// big-endian index keyed by the EEK PageIVSeed, and its associated data is the entry's page
+// binding (if any) followed by its big-endian index. The metadata nonce is the EEK MetadataIV.
+// The metadata ciphertext is also authenticated by its HMAC-SHA256 keyed by the EEK HMACKey.
+// EEKs are random; the KEKs that encrypt them are derived from the ECDH shared secret of the
+// author and reader keys with HKDF-SHA256, independent of the Scheme.
+func NewAESGCMScheme() Scheme {
+	return aesGCMScheme{}
+}
+
+func (aesGCMScheme) ID() SchemeID {
+	return AESGCMSchemeID
+}
+
+func (aesGCMScheme) NewEncrypter(keys *EEK, pageBinding []byte) (Encrypter, error) {
+	return NewEncrypter(keys, pageBinding)
+}
+
+func (aesGCMScheme) NewDecrypter(keys *EEK, pageBinding []byte) (Decrypter, error) {
+	return NewDecrypter(keys, pageBinding)
+}
+
+// Schemes are the Schemes available for decrypting entries, indexed by their ID.
+type Schemes map[SchemeID]Scheme
+
+// NewSchemes creates a new Schemes instance with the default AES-256-GCM Scheme and the given
+// Schemes, which take precedence over it.
+func NewSchemes(schemes ...Scheme) Schemes {
+	ss := Schemes{AESGCMSchemeID: NewAESGCMScheme()}
+	for _, s := range schemes {
+		if s != nil {
+			ss[s.ID()] = s
+		}
+	}
+	return ss
+}
+
+// Get returns the Scheme with the given ID or an *UnknownSchemeError if there isn't one.
+func (ss Schemes) Get(id SchemeID) (Scheme, error) {
+	s, in := ss[id]
+	if !in {
+		return nil, &UnknownSchemeError{ID: id}
+	}
+	return s, nil
+}
@@ -0,0 +1,83 @@
+package enc
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAESGCMScheme_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	s := NewAESGCMScheme()
+	assert.Equal(t, AESGCMSchemeID, s.ID())
+	keys := NewPseudoRandomEEK(rng)
+
+	// check pages encrypted with scheme are decrypted by it
+	encrypter, err := s.NewEncrypter(keys, nil)
+	assert.Nil(t, err)
+	decrypter, err := s.NewDecrypter(keys, nil)
+	assert.Nil(t, err)
+	plaintext1 := api.RandBytes(rng, 128)
+	ciphertext, err := encrypter.Encrypt(plaintext1, 1)
+	assert.Nil(t, err)
+	plaintext2, err := decrypter.Decrypt(ciphertext, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, plaintext1, plaintext2)
+
+	// check metadata encrypted with scheme is decrypted by it
+	m1, err := api.NewEntryMetadata("application/x-pdf", 1, api.RandBytes(rng, 32), 2,
+		api.RandBytes(rng, 32))
+	assert.Nil(t, err)
+	em, err := s.Encrypt(m1, keys)
+	assert.Nil(t, err)
+	m2, err := s.Decrypt(em, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, m1, m2)
+}
+
+func TestAESGCMScheme_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	s := NewAESGCMScheme()
+	keys := NewPseudoRandomEEK(rng)
+	keys.AESKey = []byte{} // will trigger cipher error
+
+	encrypter, err := s.NewEncrypter(keys, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, encrypter)
+
+	decrypter, err := s.NewDecrypter(keys, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, decrypter)
+}
+
+func TestSchemes_Get(t *testing.T) {
+	other := &otherScheme{Scheme: NewAESGCMScheme()}
+
+	// check default scheme is always present
+	ss := NewSchemes()
+	s, err := ss.Get(AESGCMSchemeID)
+	assert.Nil(t, err)
+	assert.Equal(t, NewAESGCMScheme(), s)
+
+	// check unknown scheme triggers error
+	s, err = ss.Get(other.ID())
+	assert.Equal(t, &UnknownSchemeError{ID: other.ID()}, err)
+	assert.Nil(t, s)
+
+	// check given schemes are present and nil schemes are ignored
+	ss = NewSchemes(other, nil)
+	assert.Len(t, ss, 2)
+	s, err = ss.Get(other.ID())
+	assert.Nil(t, err)
+	assert.Equal(t, other, s)
+}
+
+type otherScheme struct {
+	Scheme
+}
+
+func (otherScheme) ID() SchemeID {
+	return 7
+}
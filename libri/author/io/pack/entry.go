@@ -1,106 +1,244 @@
 package pack
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"time"
 
+	"github.com/drausin/libri/libri/author/io/comp"
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/page"
 	"github.com/drausin/libri/libri/author/io/print"
+	"github.com/drausin/libri/libri/author/io/publish"
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
+	"golang.org/x/net/context"
 )
 
 // EntryPacker creates entry documents from raw content.
 type EntryPacker interface {
 	// Pack prints pages from the content, encrypts their metadata, and binds them together
-	// into an entry *api.Document.
-	Pack(content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte) (
-		*api.Document, *api.Metadata, error)
+	// into an entry *api.Document. The content is compressed with the given codec, or with the
+	// print.Parameters CompressionCodec if it is comp.AutoCodec. The pages are at most pageSize
+	// bytes, or the print.Parameters PageSize if it is zero. If expiry is not zero, the entry
+	// expires at that time. It returns print.ErrDocumentTooLarge if a page or the entry is
+	// larger than the print.Parameters MaxDocumentBytes. If packing fails, the pages already
+	// stored are deleted again.
+	Pack(content io.Reader, mediaType string, codec comp.Codec, pageSize uint32,
+		expiry time.Time, keys *enc.EEK, authorPub []byte) (*api.Document, *api.Metadata, error)
 }
 
-// NewEntryPacker creates a new Packer instance.
+// EntryAtPacker is an EntryPacker that can also pack content with random access.
+type EntryAtPacker interface {
+	EntryPacker
+
+	// PackAt is like Pack but for the first size bytes of the content, whose pages are read and
+	// encrypted in parallel when it is uncompressed (see print.ReaderAtPrinter). The entry is
+	// the same as Pack's.
+	PackAt(content io.ReaderAt, size int64, mediaType string, codec comp.Codec,
+		pageSize uint32, expiry time.Time, keys *enc.EEK, authorPub []byte) (*api.Document,
+		*api.Metadata, error)
+}
+
+// NewEntryPacker creates a new Packer instance encrypting entries with the given scheme, which is
+// recorded in each entry. It is also an EntryAtPacker.
 func NewEntryPacker(
 	params *print.Parameters,
-	metadataEnc enc.MetadataEncrypter,
+	scheme enc.Scheme,
 	docSL storage.DocumentSLD,
 ) EntryPacker {
 	pageS := page.NewStorerLoader(docSL)
 	return &entryPacker{
-		params:      params,
-		metadataEnc: metadataEnc,
-		printer:     print.NewPrinter(params, pageS),
-		pageS:       pageS,
-		docL:        docSL,
+		params:  params,
+		scheme:  scheme,
+		printer: print.NewPrinter(params, scheme, pageS),
+		pageS:   pageS,
+		docL:    docSL,
 	}
 }
 
 type entryPacker struct {
-	params      *print.Parameters
-	metadataEnc enc.MetadataEncrypter
-	printer     print.Printer
-	pageS       page.Storer
-	docL        storage.DocumentLoader
+	params  *print.Parameters
+	scheme  enc.Scheme
+	printer print.Printer
+	pageS   page.Storer
+	docL    storage.DocumentLoader
+}
+
+func (p *entryPacker) Pack(
+	content io.Reader,
+	mediaType string,
+	codec comp.Codec,
+	pageSize uint32,
+	expiry time.Time,
+	keys *enc.EEK,
+	authorPub []byte,
+) (*api.Document, *api.Metadata, error) {
+
+	contentHash := sha256.New()
+	hashedContent := io.TeeReader(content, contentHash)
+	pageKeys, metadata, err := p.printer.Print(hashedContent, mediaType, codec, pageSize, keys,
+		authorPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.newEntryOrDelete(pageKeys, metadata, contentHash.Sum(nil), expiry, keys,
+		authorPub)
+}
+
+func (p *entryPacker) PackAt(
+	content io.ReaderAt,
+	size int64,
+	mediaType string,
+	codec comp.Codec,
+	pageSize uint32,
+	expiry time.Time,
+	keys *enc.EEK,
+	authorPub []byte,
+) (*api.Document, *api.Metadata, error) {
+
+	atPrinter, ok := p.printer.(print.ReaderAtPrinter)
+	if !ok {
+		return p.Pack(io.NewSectionReader(content, 0, size), mediaType, codec, pageSize, expiry,
+			keys, authorPub)
+	}
+	contentHash := sha256.New()
+	pageKeys, metadata, err := atPrinter.PrintAt(content, size, mediaType, codec, pageSize, keys,
+		authorPub, contentHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.newEntryOrDelete(pageKeys, metadata, contentHash.Sum(nil), expiry, keys,
+		authorPub)
 }
 
-func (p *entryPacker) Pack(content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte) (
-	*api.Document, *api.Metadata, error) {
+// newEntryOrDelete creates the entry document like newEntry but deletes the printed pages if
+// that fails, since without an entry they are unreachable.
+func (p *entryPacker) newEntryOrDelete(
+	pageKeys []id.ID,
+	metadata *api.Metadata,
+	contentHash []byte,
+	expiry time.Time,
+	keys *enc.EEK,
+	authorPub []byte,
+) (*api.Document, *api.Metadata, error) {
 
-	pageKeys, metadata, err := p.printer.Print(content, mediaType, keys, authorPub)
+	doc, metadata, err := p.newEntry(pageKeys, metadata, contentHash, expiry, keys, authorPub)
 	if err != nil {
+		// best effort, like the printer's own clean up
+		_ = p.pageS.Delete(pageKeys...)
 		return nil, nil, err
 	}
+	return doc, metadata, nil
+}
+
+// newEntry creates the entry document for the printed pages with the given metadata.
+func (p *entryPacker) newEntry(
+	pageKeys []id.ID,
+	metadata *api.Metadata,
+	contentHash []byte,
+	expiry time.Time,
+	keys *enc.EEK,
+	authorPub []byte,
+) (*api.Document, *api.Metadata, error) {
+
+	metadata.SetBytes(api.MetadataEntryContentHash, contentHash)
 	// TODO (drausin) add additional metadata K/V here
 	// - relative filepath
 	// - file mode permissions
-	encMetadata, err := p.metadataEnc.Encrypt(metadata, keys)
+	encMetadata, err := p.scheme.Encrypt(metadata, keys)
 	if err != nil {
 		return nil, nil, err
 	}
 	doc, err := newEntryDoc(authorPub, pageKeys, encMetadata, p.docL)
-	return doc, metadata, err
+	if err != nil {
+		return doc, metadata, err
+	}
+	doc.Contents.(*api.Document_Entry).Entry.EncryptionScheme = uint32(p.scheme.ID())
+	if !expiry.IsZero() {
+		doc.Contents.(*api.Document_Entry).Entry.ExpiryTime = expiry.Unix()
+	}
+	if err := print.CheckDocumentSize(doc, p.params.MaxDocumentBytes); err != nil {
+		return nil, nil, err
+	}
+	return doc, metadata, nil
 }
 
 // EntryUnpacker writes individual pages to the content io.Writer.
 type EntryUnpacker interface {
 	// Unpack extracts the individual pages from a document and stitches them together to write
-	// to the content io.Writer.
-	Unpack(content io.Writer, entry *api.Document, keys *enc.EEK) (*api.Metadata, error)
+	// to the content io.Writer. Pages acquired again from libri are acquired within ctx and
+	// checked against the entry's author public key.
+	Unpack(ctx context.Context, content io.Writer, entry *api.Document, keys *enc.EEK) (
+		*api.Metadata, error)
 }
 
 type entryUnpacker struct {
-	params      *print.Parameters
-	metadataDec enc.MetadataDecrypter
-	scanner     print.Scanner
+	params  *print.Parameters
+	schemes enc.Schemes
+	scanner print.Scanner
 }
 
-// NewEntryUnpacker creates a new EntryUnpacker with the given parameters, metadata decrypter, and
-// storage.DocumentStorerLoader.
+// NewEntryUnpacker creates a new EntryUnpacker with the given parameters, encryption schemes, and
+// storage.DocumentStorerLoader. Each entry is decrypted with the scheme recorded in it.
 func NewEntryUnpacker(
 	params *print.Parameters,
-	metadataDec enc.MetadataDecrypter,
+	schemes enc.Schemes,
 	docSL storage.DocumentSLD,
 ) EntryUnpacker {
-	pageL := page.NewStorerLoader(docSL)
+	return NewEntryUnpackerWithLoader(params, schemes, page.NewStorerLoader(docSL))
+}
+
+// NewRefetchingEntryUnpacker creates a new EntryUnpacker like NewEntryUnpacker, except that pages
+// found to be corrupt in the storage.DocumentSLD are acquired again from libri via the multi-store
+// acquirer instead of failing the unpack. Those re-acquisitions are counted in the page metrics,
+// which may be nil.
+func NewRefetchingEntryUnpacker(
+	params *print.Parameters,
+	schemes enc.Schemes,
+	docSL storage.DocumentSLD,
+	msAcquirer publish.MultiStoreAcquirer,
+	librarians api.ClientBalancer,
+	metrics *page.Metrics,
+) EntryUnpacker {
+	pageL := page.NewRefetchingStorerLoader(docSL, msAcquirer, librarians, metrics)
+	return NewEntryUnpackerWithLoader(params, schemes, pageL)
+}
+
+// NewEntryUnpackerWithLoader creates a new EntryUnpacker with the given parameters, encryption
+// schemes, and page.Loader.
+func NewEntryUnpackerWithLoader(
+	params *print.Parameters,
+	schemes enc.Schemes,
+	pageL page.Loader,
+) EntryUnpacker {
 	return &entryUnpacker{
-		params:      params,
-		metadataDec: metadataDec,
-		scanner:     print.NewScanner(params, pageL),
+		params:  params,
+		schemes: schemes,
+		scanner: print.NewScanner(params, pageL),
 	}
 }
 
-func (u *entryUnpacker) Unpack(content io.Writer, entry *api.Document, keys *enc.EEK) (
-	*api.Metadata, error) {
-	encMetadata, err := enc.NewEncryptedMetadata(
-		entry.Contents.(*api.Document_Entry).Entry.MetadataCiphertext,
-		entry.Contents.(*api.Document_Entry).Entry.MetadataCiphertextMac,
+func (u *entryUnpacker) Unpack(
+	ctx context.Context, content io.Writer, entry *api.Document, keys *enc.EEK,
+) (*api.Metadata, error) {
+	scheme, err := u.schemes.Get(
+		enc.SchemeID(entry.Contents.(*api.Document_Entry).Entry.EncryptionScheme),
+	)
+	if err != nil {
+		return nil, err
+	}
+	encMetadata, err := enc.NewEntryEncryptedMetadata(
+		entry.Contents.(*api.Document_Entry).Entry,
 	)
 	if err != nil {
 		return nil, err
 	}
-	metadata, err := u.metadataDec.Decrypt(encMetadata, keys)
+	metadata, err := scheme.Decrypt(encMetadata, keys)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +257,38 @@ func (u *entryUnpacker) Unpack(content io.Writer, entry *api.Document, keys *enc
 		}
 		pageKeys = []id.ID{docKey}
 	}
-	return metadata, u.scanner.Scan(content, pageKeys, keys, metadata)
+	contentHash := sha256.New()
+	hashedContent := io.MultiWriter(content, contentHash)
+	err = u.scanner.Scan(ctx, hashedContent, pageKeys, api.GetAuthorPub(entry), scheme, keys,
+		metadata)
+	if err != nil {
+		return metadata, err
+	}
+	return metadata, checkContentHash(metadata, contentHash.Sum(nil))
+}
+
+// ContentHashError indicates when the hash of the unpacked content differs from the one recorded
+// in the entry metadata.
+type ContentHashError struct {
+	// Expected is the content hash recorded in the entry metadata.
+	Expected []byte
+
+	// Actual is the hash of the unpacked content.
+	Actual []byte
+}
+
+func (e *ContentHashError) Error() string {
+	return fmt.Sprintf("unexpected content hash: expected %x, actual %x", e.Expected, e.Actual)
+}
+
+// checkContentHash checks the actual content hash against the one in the metadata, if present.
+// Entries packed before content hashes were recorded have none and are not checked.
+func checkContentHash(metadata *api.Metadata, actual []byte) error {
+	expected, in := metadata.GetContentHash()
+	if in && !bytes.Equal(expected, actual) {
+		return &ContentHashError{Expected: expected, Actual: actual}
+	}
+	return nil
 }
 
 func newEntryDoc(
@@ -2,19 +2,26 @@ package pack
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"io"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/author/io/common"
 	"github.com/drausin/libri/libri/author/io/comp"
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/page"
 	"github.com/drausin/libri/libri/author/io/print"
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestEntryPacker_Pack_ok(t *testing.T) {
@@ -25,7 +32,7 @@ func TestEntryPacker_Pack_ok(t *testing.T) {
 	docSL := &fixedDocSLD{
 		stored: make(map[string]*api.Document),
 	}
-	p := NewEntryPacker(params, enc.NewMetadataEncrypterDecrypter(), docSL)
+	p := NewEntryPacker(params, enc.NewAESGCMScheme(), docSL)
 	authorPub := api.RandBytes(rng, 65)
 	keys := enc.NewPseudoRandomEEK(rng)
 	mediaType := "application/x-pdf"
@@ -33,19 +40,23 @@ func TestEntryPacker_Pack_ok(t *testing.T) {
 	// test works with single-page content
 	uncompressedSize1 := int(params.PageSize/2)
 	content1 := common.NewCompressableBytes(rng, uncompressedSize1)
-	doc, metadata, err := p.Pack(content1, mediaType, keys, authorPub)
+	doc, metadata, err := p.Pack(content1, mediaType, comp.AutoCodec, 0, time.Time{},
+		keys, authorPub)
 	assert.Nil(t, err)
 	assert.NotNil(t, doc)
 	assert.NotNil(t, metadata)
 	origSize, in := metadata.GetUncompressedSize()
 	assert.True(t, in)
 	assert.Equal(t, uint64(uncompressedSize1), origSize)
+	assert.Equal(t, uint32(enc.AESGCMSchemeID),
+		doc.Contents.(*api.Document_Entry).Entry.EncryptionScheme)
 
 
 	// test works with multi-page content
 	uncompressedSize2 := int(params.PageSize*5)
 	content2 := common.NewCompressableBytes(rng, uncompressedSize2)
-	doc, metadata, err = p.Pack(content2, mediaType, keys, authorPub)
+	doc, metadata, err = p.Pack(content2, mediaType, comp.AutoCodec, 0, time.Time{},
+		keys, authorPub)
 	assert.Nil(t, err)
 	assert.NotNil(t, doc)
 	assert.NotNil(t, metadata)
@@ -55,6 +66,28 @@ func TestEntryPacker_Pack_ok(t *testing.T) {
 	pageKeys, err := api.GetEntryPageKeys(doc)
 	assert.Nil(t, err)
 	assert.True(t, len(pageKeys) > 1)
+
+	// test skips compression with none codec
+	content3 := common.NewCompressableBytes(rng, uncompressedSize2)
+	doc, metadata, err = p.Pack(content3, mediaType, comp.NoneCodec, 0, time.Time{},
+		keys, authorPub)
+	assert.Nil(t, err)
+	assert.NotNil(t, doc)
+	codec, in := metadata.GetCompressionCodec()
+	assert.True(t, in)
+	assert.Equal(t, string(comp.NoneCodec), codec)
+	origSize, in = metadata.GetUncompressedSize()
+	assert.True(t, in)
+	assert.Equal(t, uint64(uncompressedSize2), origSize)
+	assert.Zero(t, doc.Contents.(*api.Document_Entry).Entry.ExpiryTime)
+
+	// test sets expiry time when given
+	expiry := time.Now().Add(time.Hour)
+	content4 := common.NewCompressableBytes(rng, uncompressedSize1)
+	doc, _, err = p.Pack(content4, mediaType, comp.AutoCodec, 0, expiry, keys, authorPub)
+	assert.Nil(t, err)
+	assert.Equal(t, expiry.Unix(), doc.Contents.(*api.Document_Entry).Entry.ExpiryTime)
+	assert.Nil(t, api.ValidateDocument(doc))
 }
 
 func TestEntryPacker_Pack_err(t *testing.T) {
@@ -63,20 +96,22 @@ func TestEntryPacker_Pack_err(t *testing.T) {
 	docSL := &fixedDocSLD{
 		stored: make(map[string]*api.Document),
 	}
-	p := NewEntryPacker(params, enc.NewMetadataEncrypterDecrypter(), docSL)
+	p := NewEntryPacker(params, enc.NewAESGCMScheme(), docSL)
 	mediaType := "application/x-pdf"
 	content := common.NewCompressableBytes(rng, int(params.PageSize/2))
 	authorPub := api.RandBytes(rng, 65)
 	keys := enc.NewPseudoRandomEEK(rng)
 
 	// check error from bad mediaType bubbles up
-	doc, metadata, err := p.Pack(content, "application x-pdf", keys, authorPub)
+	doc, metadata, err := p.Pack(content, "application x-pdf", comp.AutoCodec, 0, time.Time{},
+		keys, authorPub)
 	assert.NotNil(t, err)
 	assert.Nil(t, doc)
 	assert.Nil(t, metadata)
 
 	// check Encrypt error from bad author key bubbles up
-	doc, metadata, err = p.Pack(content, mediaType, keys, []byte{})
+	doc, metadata, err = p.Pack(content, mediaType, comp.AutoCodec, 0, time.Time{},
+		keys, []byte{})
 	assert.NotNil(t, err)
 	assert.Nil(t, doc)
 	assert.Nil(t, metadata)
@@ -85,16 +120,104 @@ func TestEntryPacker_Pack_err(t *testing.T) {
 		stored:  make(map[string]*api.Document),
 		loadErr: errors.New("some Load error"),
 	}
-	p2 := NewEntryPacker(params, enc.NewMetadataEncrypterDecrypter(), errDocSL)
+	p2 := NewEntryPacker(params, enc.NewAESGCMScheme(), errDocSL)
 
 	// check error from missing page bubbles up
-	doc, metadata, err = p2.Pack(content, mediaType, keys, []byte{})
+	doc, metadata, err = p2.Pack(content, mediaType, comp.AutoCodec, 0, time.Time{},
+		keys, []byte{})
 	assert.NotNil(t, err)
 	assert.Nil(t, doc)
 	assert.Nil(t, metadata)
 
 }
 
+func TestEntryPacker_PackAt(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	params := print.NewDefaultParameters()
+	page.MinSize = 64 // just for testing
+	params.PageSize = 128
+	docSL := &fixedDocSLD{
+		stored: make(map[string]*api.Document),
+	}
+	p := NewEntryPacker(params, enc.NewAESGCMScheme(), docSL).(EntryAtPacker)
+	authorPub := api.RandBytes(rng, 65)
+	keys := enc.NewPseudoRandomEEK(rng)
+	content := common.NewCompressableBytes(rng, int(params.PageSize*5)).Bytes()
+
+	// check uncompressed (parallel) and compressed (sequential) content have same metadata
+	// as when packed sequentially
+	for _, codec := range []comp.Codec{comp.NoneCodec, comp.GZIPCodec} {
+		doc, metadata, err := p.PackAt(bytes.NewReader(content), int64(len(content)),
+			"application/x-pdf", codec, 0, time.Time{}, keys, authorPub)
+		assert.Nil(t, err)
+		assert.Nil(t, api.ValidateDocument(doc))
+		expectedDoc, expectedMetadata, err := p.Pack(bytes.NewReader(content),
+			"application/x-pdf", codec, 0, time.Time{}, keys, authorPub)
+		assert.Nil(t, err)
+
+		pageKeys, err := api.GetEntryPageKeys(doc)
+		assert.Nil(t, err)
+		expectedPageKeys, err := api.GetEntryPageKeys(expectedDoc)
+		assert.Nil(t, err)
+		assert.Equal(t, len(expectedPageKeys), len(pageKeys))
+		for _, key := range []string{
+			api.MetadataEntryCompressionCodec,
+			api.MetadataEntryUncompressedSize,
+			api.MetadataEntryUncompressedMAC,
+			api.MetadataEntryContentHash,
+		} {
+			expected, _ := expectedMetadata.GetBytes(key)
+			actual, _ := metadata.GetBytes(key)
+			assert.Equal(t, expected, actual)
+		}
+		contentHash, _ := metadata.GetContentHash()
+		expectedContentHash := sha256.Sum256(content)
+		assert.Equal(t, expectedContentHash[:], contentHash)
+	}
+
+	// check error from content shorter than its size bubbles up
+	doc, metadata, err := p.PackAt(bytes.NewReader(content), int64(len(content))+1,
+		"application/x-pdf", comp.NoneCodec, 0, time.Time{}, keys, authorPub)
+	assert.NotNil(t, err)
+	assert.Nil(t, doc)
+	assert.Nil(t, metadata)
+}
+
+func TestEntryPacker_Pack_maxDocumentBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	params := print.NewDefaultParameters()
+	docSL := &fixedDocSLD{
+		stored: make(map[string]*api.Document),
+	}
+	p := NewEntryPacker(params, enc.NewAESGCMScheme(), docSL)
+	mediaType := "application/x-pdf"
+	content := common.NewCompressableBytes(rng, int(params.PageSize/2)).Bytes()
+	authorPub := api.RandBytes(rng, 65)
+	keys := enc.NewPseudoRandomEEK(rng)
+	doc, _, err := p.Pack(bytes.NewReader(content), mediaType, comp.AutoCodec, 0, time.Time{},
+		keys, authorPub)
+	assert.Nil(t, err)
+	entryBytes := uint64(proto.Size(doc))
+
+	// check entry at the max document size is packed
+	params.MaxDocumentBytes = entryBytes
+	doc, metadata, err := p.Pack(bytes.NewReader(content), mediaType, comp.AutoCodec, 0,
+		time.Time{}, keys, authorPub)
+	assert.Nil(t, err)
+	assert.NotNil(t, doc)
+	assert.NotNil(t, metadata)
+
+	// check entry just over the max document size gives error and its page isn't left stored
+	params.MaxDocumentBytes = entryBytes - 1
+	nStored := len(docSL.stored)
+	doc, metadata, err = p.Pack(bytes.NewReader(content), mediaType, comp.AutoCodec, 0,
+		time.Time{}, keys, authorPub)
+	assert.Equal(t, print.ErrDocumentTooLarge, err)
+	assert.Nil(t, doc)
+	assert.Nil(t, metadata)
+	assert.Len(t, docSL.stored, nStored)
+}
+
 func TestEntryUnpacker_Unpack_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	params := print.NewDefaultParameters()
@@ -113,12 +236,9 @@ func TestEntryUnpacker_Unpack_ok(t *testing.T) {
 	)
 	assert.Nil(t, err)
 
-	metadataDec := &fixedMetadataDecrypter{
-		metadata: metadata1,
-	}
-	u := NewEntryUnpacker(params, metadataDec, docSL)
+	u := NewEntryUnpacker(params, newFixedSchemes(metadata1, nil), docSL)
 	u.(*entryUnpacker).scanner = &fixedScanner{}
-	metadata, err := u.Unpack(content, doc, keys)
+	metadata, err := u.Unpack(context.Background(), content, doc, keys)
 	assert.Nil(t, err)
 	assert.NotNil(t, metadata)
 }
@@ -134,31 +254,55 @@ func TestEntryUnpacker_Unpack_err(t *testing.T) {
 	keys := enc.NewPseudoRandomEEK(rng)
 
 	// check bad ciphertext/ciphertext MAC trigger error
-	u1 := NewEntryUnpacker(params, &fixedMetadataDecrypter{}, docSL)
+	u1 := NewEntryUnpacker(params, newFixedSchemes(nil, nil), docSL)
 	doc1, _ := api.NewTestDocument(rng)
 	doc1.Contents.(*api.Document_Entry).Entry.MetadataCiphertextMac = nil
-	metadata, err := u1.Unpack(content, doc1, keys)
+	metadata, err := u1.Unpack(context.Background(), content, doc1, keys)
 	assert.NotNil(t, err)
 	assert.Nil(t, metadata)
 
 	// check decryption error bubbles up
 	u2 := NewEntryUnpacker(
 		params,
-		&fixedMetadataDecrypter{err: errors.New("some Decrypt error")},
+		newFixedSchemes(nil, errors.New("some Decrypt error")),
 		docSL,
 	)
-	metadata, err = u2.Unpack(content, doc, keys)
+	metadata, err = u2.Unpack(context.Background(), content, doc, keys)
 	assert.NotNil(t, err)
 	assert.Nil(t, metadata)
 
+	// check unknown encryption scheme triggers error
+	doc2, _ := api.NewTestDocument(rng)
+	doc2.Contents.(*api.Document_Entry).Entry.EncryptionScheme = 7
+	metadata, err = u2.Unpack(context.Background(), content, doc2, keys)
+	assert.Equal(t, &enc.UnknownSchemeError{ID: 7}, err)
+	assert.Nil(t, metadata)
+
 	// check scanner error bubbles up
-	u3 := NewEntryUnpacker(params, &fixedMetadataDecrypter{}, docSL)
+	u3 := NewEntryUnpacker(params, newFixedSchemes(nil, nil), docSL)
 	u3.(*entryUnpacker).scanner = &fixedScanner{
 		err: errors.New("some Scan error"),
 	}
-	metadata, err = u3.Unpack(content, doc, keys)
+	metadata, err = u3.Unpack(context.Background(), content, doc, keys)
 	assert.NotNil(t, err)
 	assert.Nil(t, metadata)
+
+	// check content hash mismatch triggers error with expected & actual hashes
+	metadata4, err := api.NewEntryMetadata(
+		"application/x-pdf",
+		1,
+		api.RandBytes(rng, 32),
+		2,
+		api.RandBytes(rng, 32),
+	)
+	assert.Nil(t, err)
+	expectedHash := sha256.Sum256([]byte("some other content"))
+	metadata4.SetBytes(api.MetadataEntryContentHash, expectedHash[:])
+	u4 := NewEntryUnpacker(params, newFixedSchemes(metadata4, nil), docSL)
+	u4.(*entryUnpacker).scanner = &fixedScanner{content: []byte("some content")}
+	_, err = u4.Unpack(context.Background(), content, doc, keys)
+	actualHash := sha256.Sum256([]byte("some content"))
+	assert.Equal(t, &ContentHashError{Expected: expectedHash[:], Actual: actualHash[:]}, err)
 }
 
 func TestEntryPackUnpack(t *testing.T) {
@@ -166,7 +310,7 @@ func TestEntryPackUnpack(t *testing.T) {
 	page.MinSize = 64 // just for testing
 	authorPub := api.RandBytes(rng, 65)
 	keys := enc.NewPseudoRandomEEK(rng)
-	metadataEncDec := enc.NewMetadataEncrypterDecrypter()
+	scheme := enc.NewAESGCMScheme()
 
 	pageSizes := []uint32{128, 256, 512, 1024}
 	uncompressedSizes := []int{128, 192, 256, 384, 512, 768, 1024, 2048, 4096, 8192}
@@ -190,13 +334,14 @@ func TestEntryPackUnpack(t *testing.T) {
 		packParams, err := print.NewParameters(comp.MinBufferSize, c.pageSize,
 			c.packParallelism)
 		assert.Nil(t, err)
-		p := NewEntryPacker(packParams, metadataEncDec, docSL)
+		p := NewEntryPacker(packParams, scheme, docSL)
 		unpackParams, err := print.NewParameters(comp.MinBufferSize, c.pageSize,
 			c.unpackParallelism)
 		assert.Nil(t, err)
-		u := NewEntryUnpacker(unpackParams, metadataEncDec, docSL)
+		u := NewEntryUnpacker(unpackParams, enc.NewSchemes(), docSL)
 
-		doc, metadata1, err := p.Pack(content1, c.mediaType, keys, authorPub)
+		doc, metadata1, err := p.Pack(content1, c.mediaType, comp.AutoCodec, 0, time.Time{},
+			keys, authorPub)
 		assert.Nil(t, err)
 		assert.NotNil(t, doc)
 		uncompressedSize1, in := metadata1.GetUncompressedSize()
@@ -204,13 +349,129 @@ func TestEntryPackUnpack(t *testing.T) {
 		assert.Equal(t, c.uncompressedSize, int(uncompressedSize1))
 
 		content2 := new(bytes.Buffer)
-		metadata2, err := u.Unpack(content2, doc, keys)
+		metadata2, err := u.Unpack(context.Background(), content2, doc, keys)
 		assert.Nil(t, err)
 		assert.Equal(t, content1Bytes, content2.Bytes())
 		uncompressedSize2, in := metadata2.GetUncompressedSize()
 		assert.True(t, in)
 		assert.Equal(t, c.uncompressedSize, int(uncompressedSize2))
+		contentHash, in := metadata2.GetContentHash()
+		assert.True(t, in)
+		expectedHash := sha256.Sum256(content1Bytes)
+		assert.Equal(t, expectedHash[:], contentHash)
+	}
+}
+
+func TestEntryPackUnpack_scheme(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	page.MinSize = 64 // just for testing
+	params := print.NewDefaultParameters()
+	params.PageSize = 128
+	docSL := &fixedDocSLD{
+		stored: make(map[string]*api.Document),
+	}
+	scheme := otherScheme{enc.NewAESGCMScheme()}
+	p := NewEntryPacker(params, scheme, docSL)
+	authorPub := api.RandBytes(rng, 65)
+	keys := enc.NewPseudoRandomEEK(rng)
+	content1 := common.NewCompressableBytes(rng, int(params.PageSize*3))
+	content1Bytes := content1.Bytes()
+
+	// check scheme is recorded in entry
+	doc, _, err := p.Pack(content1, "application/x-pdf", comp.AutoCodec, 0, time.Time{}, keys,
+		authorPub)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(7), doc.Contents.(*api.Document_Entry).Entry.EncryptionScheme)
+
+	// check unpacking without the scheme errors
+	u1 := NewEntryUnpacker(params, enc.NewSchemes(), docSL)
+	_, err = u1.Unpack(context.Background(), new(bytes.Buffer), doc, keys)
+	assert.Equal(t, &enc.UnknownSchemeError{ID: 7}, err)
+
+	// check unpacking with the scheme succeeds
+	u2 := NewEntryUnpacker(params, enc.NewSchemes(scheme), docSL)
+	content2 := new(bytes.Buffer)
+	_, err = u2.Unpack(context.Background(), content2, doc, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, content1Bytes, content2.Bytes())
+}
+
+func TestEntryPackUnpack_refetch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	page.MinSize = 64 // just for testing
+	params := print.NewDefaultParameters()
+	params.PageSize = 128
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	docSL := storage.NewDocumentSLD(kvdb)
+	p := NewEntryPacker(params, enc.NewAESGCMScheme(), docSL)
+	authorPub := api.RandBytes(rng, 65)
+	keys := enc.NewPseudoRandomEEK(rng)
+	content1Bytes := api.RandBytes(rng, int(params.PageSize*4))
+	content1 := bytes.NewReader(content1Bytes)
+
+	// random content with an already-compressed media type spans multiple pages
+	doc, _, err := p.Pack(content1, "application/x-gzip", comp.AutoCodec, 0, time.Time{}, keys,
+		authorPub)
+	assert.Nil(t, err)
+	pageKeys, err := api.GetEntryPageKeys(doc)
+	assert.Nil(t, err)
+
+	// keep a copy of each page to acquire from "remote" storage
+	msAcq := &fixedMultiStoreAcquirer{
+		remote: make(map[string]*api.Document),
+		local:  docSL,
+	}
+	for _, pageKey := range pageKeys {
+		pageDoc, err2 := docSL.Load(pageKey)
+		assert.Nil(t, err2)
+		msAcq.remote[pageKey.String()] = pageDoc
+	}
+
+	// simulate bit rot by flipping a byte of the second stored page
+	nsKey := append(storage.Documents, pageKeys[1].Bytes()...)
+	valueBytes, err := kvdb.Get(nsKey)
+	assert.Nil(t, err)
+	valueBytes[0] ^= 0xff
+	err = kvdb.Put(nsKey, valueBytes)
+	assert.Nil(t, err)
+
+	// check unpacking without refetching fails
+	u1 := NewEntryUnpacker(params, enc.NewSchemes(), docSL)
+	_, err = u1.Unpack(context.Background(), new(bytes.Buffer), doc, keys)
+	assert.Equal(t, page.ErrCorruptPage, err)
+
+	// check unpacking with refetching acquires the corrupt page again and succeeds
+	u2 := NewRefetchingEntryUnpacker(params, enc.NewSchemes(), docSL, msAcq, nil, nil)
+	content2 := new(bytes.Buffer)
+	_, err = u2.Unpack(context.Background(), content2, doc, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, content1Bytes, content2.Bytes())
+	assert.Equal(t, []id.ID{pageKeys[1]}, msAcq.acquired)
+}
+
+// fixedMultiStoreAcquirer acquires documents from a remote map into local storage.
+type fixedMultiStoreAcquirer struct {
+	remote   map[string]*api.Document
+	local    storage.DocumentStorer
+	acquired []id.ID
+}
+
+func (a *fixedMultiStoreAcquirer) Acquire(
+	ctx context.Context,
+	docKeys []id.ID,
+	authorPub []byte,
+	cb api.ClientBalancer,
+	search *publish.Search,
+	progress publish.Progress,
+) error {
+	for _, docKey := range docKeys {
+		a.acquired = append(a.acquired, docKey)
+		if err := a.local.Store(docKey, a.remote[docKey.String()]); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 type fixedDocSLD struct {
@@ -234,23 +495,47 @@ func (f *fixedDocSLD) Delete(key id.ID) error {
 	return f.deleteErr
 }
 
-type fixedMetadataDecrypter struct {
+// fixedScheme is the default scheme but with fixed metadata decryption.
+type fixedScheme struct {
+	enc.Scheme
 	metadata *api.Metadata
 	err      error
 }
 
-func (f *fixedMetadataDecrypter) Decrypt(em *enc.EncryptedMetadata, keys *enc.EEK) (
+func newFixedSchemes(metadata *api.Metadata, err error) enc.Schemes {
+	return enc.NewSchemes(&fixedScheme{
+		Scheme:   enc.NewAESGCMScheme(),
+		metadata: metadata,
+		err:      err,
+	})
+}
+
+func (f *fixedScheme) Decrypt(em *enc.EncryptedMetadata, keys *enc.EEK) (
 	*api.Metadata, error) {
 	return f.metadata, f.err
 }
 
+// otherScheme is the default scheme but with a different ID.
+type otherScheme struct {
+	enc.Scheme
+}
+
+func (otherScheme) ID() enc.SchemeID {
+	return 7
+}
+
 type fixedScanner struct {
-	err error
+	content []byte
+	err     error
 }
 
 func (f *fixedScanner) Scan(
-	content io.Writer, pageKeys []id.ID, keys *enc.EEK, metatdata *api.Metadata,
+	ctx context.Context, content io.Writer, pageKeys []id.ID, authorPub []byte,
+	scheme enc.Scheme, keys *enc.EEK, metatdata *api.Metadata,
 ) error {
+	if _, err := content.Write(f.content); err != nil {
+		return err
+	}
 	return f.err
 }
 
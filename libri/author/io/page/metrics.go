@@ -0,0 +1,37 @@
+package page
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus metrics of page loading, so each author in a process can record
+// its loads separately.
+type Metrics struct {
+	corruptRefetches prometheus.Counter
+}
+
+// NewMetrics creates a new *Metrics instance.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		corruptRefetches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "libri",
+			Subsystem: "page",
+			Name:      "corrupt_refetches_total",
+			Help:      "Number of pages acquired again because they were corrupt in local storage.",
+		}),
+	}
+}
+
+// Register registers the page metrics with the given registerer.
+func (m *Metrics) Register(r prometheus.Registerer) {
+	r.MustRegister(m.corruptRefetches)
+}
+
+// incCorruptRefetches records a page acquired again because it was corrupt. It is a no-op for nil
+// metrics.
+func (m *Metrics) incCorruptRefetches() {
+	if m == nil {
+		return
+	}
+	m.corruptRefetches.Inc()
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/drausin/libri/libri/author/io/comp"
 	"github.com/drausin/libri/libri/author/io/enc"
@@ -17,6 +18,10 @@ var (
 
 	// DefaultSize is the default maximum number of bytes in a page.
 	DefaultSize = uint32(2 * 1024 * 1024) // 2 MB
+
+	// MaxSize is the largest maximum number of bytes in a page, limited by the max size of the
+	// values librarians store.
+	MaxSize = uint32(2 * 1024 * 1024) // 2 MB
 )
 
 // ErrUnexpectedCiphertextMAC indicates when the ciphertext MAC does not match the expected value.
@@ -25,6 +30,12 @@ var ErrUnexpectedCiphertextMAC = errors.New("ciphertext mac does not match expec
 // ErrPageSizeTooSmall indicates when the max page size is too small (often because it is zero).
 var ErrPageSizeTooSmall = fmt.Errorf("page size is below %d byte minimum", MinSize)
 
+// ErrPageSizeTooLarge indicates when the max page size is too large for librarians to store.
+var ErrPageSizeTooLarge = fmt.Errorf("page size is above %d byte maximum", MaxSize)
+
+// ErrZeroParallelism indicates when the ReaderAtPaginator parallelism is zero.
+var ErrZeroParallelism = errors.New("zero value parallelism")
+
 // Paginator is an io.ReaderFrom that reads from a compressor and writes encrypted pages to a
 // channel.
 type Paginator interface {
@@ -61,6 +72,9 @@ func NewPaginator(
 	if pageSize < MinSize {
 		return nil, ErrPageSizeTooSmall
 	}
+	if pageSize > MaxSize {
+		return nil, ErrPageSizeTooLarge
+	}
 	return &paginator{
 		pages:         pages,
 		encrypter:     encrypter,
@@ -128,6 +142,182 @@ func (p *paginator) CiphertextMAC() enc.MAC {
 	return p.ciphertextMAC
 }
 
+// ReaderAtPaginator reads pages of uncompressed content from an io.ReaderAt and encrypts them in
+// parallel, since each page is then a fixed range of the content.
+type ReaderAtPaginator interface {
+	// PaginateAt emits encrypted pages of the first size bytes of the content to the underlying
+	// channel in index order, also writing the content to the plaintext io.Writer in order. The
+	// pages are the same as those a Paginator emits when reading the same content through a
+	// comp.NoneCodec Compressor.
+	PaginateAt(content io.ReaderAt, size int64, plaintext io.Writer) error
+
+	// CiphertextMAC is the MAC for the entire ciphertext across all pages.
+	CiphertextMAC() enc.MAC
+}
+
+// pageResult is a page read and encrypted by a readerAtPaginator worker.
+type pageResult struct {
+	page      *api.Page
+	plaintext []byte
+	err       error
+}
+
+type readerAtPaginator struct {
+	pages         chan *api.Page
+	newEncrypter  func() (enc.Encrypter, error)
+	pageSize      uint32
+	parallelism   uint32
+	authorPub     []byte
+	hmacKey       []byte
+	ciphertextMAC enc.MAC
+}
+
+// NewReaderAtPaginator creates a new ReaderAtPaginator that emits pages to the given channel,
+// reading and encrypting up to parallelism pages at once, each with its own Encrypter from
+// newEncrypter (since Encrypters aren't safe for concurrent use).
+func NewReaderAtPaginator(
+	pages chan *api.Page,
+	newEncrypter func() (enc.Encrypter, error),
+	keys *enc.EEK,
+	authorPub []byte,
+	pageSize uint32,
+	parallelism uint32,
+) (ReaderAtPaginator, error) {
+	if err := api.ValidateHMACKey(keys.HMACKey); err != nil {
+		return nil, err
+	}
+	if err := api.ValidatePublicKey(authorPub); err != nil {
+		return nil, err
+	}
+	if pageSize < MinSize {
+		return nil, ErrPageSizeTooSmall
+	}
+	if pageSize > MaxSize {
+		return nil, ErrPageSizeTooLarge
+	}
+	if parallelism == 0 {
+		return nil, ErrZeroParallelism
+	}
+	return &readerAtPaginator{
+		pages:         pages,
+		newEncrypter:  newEncrypter,
+		pageSize:      pageSize,
+		parallelism:   parallelism,
+		authorPub:     authorPub,
+		hmacKey:       keys.HMACKey,
+		ciphertextMAC: enc.NewHMAC(keys.HMACKey),
+	}, nil
+}
+
+func (p *readerAtPaginator) PaginateAt(content io.ReaderAt, size int64, plaintext io.Writer) error {
+	// like a Paginator, always end with a partial (and possibly empty) page
+	nPages := uint32(size/int64(p.pageSize)) + 1
+
+	encrypters := make([]enc.Encrypter, p.parallelism)
+	for i := range encrypters {
+		var err error
+		if encrypters[i], err = p.newEncrypter(); err != nil {
+			return err
+		}
+	}
+
+	// limit the pages read ahead of the next one to emit, which buffer in pending
+	window := make(chan struct{}, 2*p.parallelism)
+	indices := make(chan uint32)
+	results := make(chan *pageResult, p.parallelism)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(indices)
+		for i := uint32(0); i < nPages; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case indices <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+	wg := new(sync.WaitGroup)
+	for _, encrypter := range encrypters {
+		wg.Add(1)
+		go func(encrypter enc.Encrypter) {
+			defer wg.Done()
+			for i := range indices {
+				select {
+				case results <- p.readPage(content, size, i, encrypter):
+				case <-done:
+					return
+				}
+			}
+		}(encrypter)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// emit pages in index order
+	pending := make(map[uint32]*pageResult)
+	next := uint32(0)
+	for result := range results {
+		if result.err != nil {
+			return result.err
+		}
+		pending[result.page.Index] = result
+		for r, in := pending[next]; in; r, in = pending[next] {
+			delete(pending, next)
+			if _, err := plaintext.Write(r.plaintext); err != nil {
+				return err
+			}
+			if _, err := p.ciphertextMAC.Write(r.page.Ciphertext); err != nil {
+				return err
+			}
+			p.pages <- r.page
+			<-window
+			next++
+		}
+	}
+	return nil
+}
+
+// readPage reads and encrypts the page with the given index from the content.
+func (p *readerAtPaginator) readPage(
+	content io.ReaderAt, size int64, index uint32, encrypter enc.Encrypter,
+) *pageResult {
+	offset := int64(index) * int64(p.pageSize)
+	n := size - offset
+	if n > int64(p.pageSize) {
+		n = int64(p.pageSize)
+	}
+	pagePlaintext := make([]byte, n)
+	if _, err := io.ReadFull(io.NewSectionReader(content, offset, n), pagePlaintext); err != nil {
+		return &pageResult{err: err}
+	}
+	ciphertext, err := encrypter.Encrypt(pagePlaintext, index)
+	if err != nil {
+		return &pageResult{err: err}
+	}
+	page := &api.Page{
+		AuthorPublicKey: p.authorPub,
+		Index:           index,
+		Ciphertext:      ciphertext,
+		CiphertextMac:   enc.HMAC(ciphertext, p.hmacKey),
+	}
+	if err := api.ValidatePage(page); err != nil {
+		return &pageResult{err: err}
+	}
+	return &pageResult{page: page, plaintext: pagePlaintext}
+}
+
+func (p *readerAtPaginator) CiphertextMAC() enc.MAC {
+	return p.ciphertextMAC
+}
+
 // Unpaginator writes content from discrete pages to a decompressed writer.
 type Unpaginator interface {
 	// WriteTo writes content from the underlying channel of pages to the decompressor.
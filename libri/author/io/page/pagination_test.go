@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"testing"
 
@@ -36,6 +37,12 @@ func TestNewPaginator_err(t *testing.T) {
 	p3, err := NewPaginator(nil, nil, eek3, authorPub, 0)
 	assert.NotNil(t, err)
 	assert.Nil(t, p3)
+
+	// too large page size should create error
+	eek4 := enc.NewPseudoRandomEEK(rng)
+	p4, err := NewPaginator(nil, nil, eek4, authorPub, MaxSize+1)
+	assert.Equal(t, ErrPageSizeTooLarge, err)
+	assert.Nil(t, p4)
 }
 
 type errReader struct{}
@@ -60,7 +67,7 @@ func TestPaginator_ReadFrom_err(t *testing.T) {
 	pages := make(chan *api.Page, 3)
 	compressedBytes := []byte("some fake compressed bytes")
 
-	encrypter, err := enc.NewEncrypter(keys)
+	encrypter, err := enc.NewEncrypter(keys, nil)
 	assert.Nil(t, err)
 
 	// check that compressed read error bubbles up
@@ -147,7 +154,7 @@ func (f *fixedDecrypter) Decrypt(ciphertext []byte, pageIndex uint32) ([]byte, e
 func TestUnpaginator_WriteTo_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	keys := enc.NewPseudoRandomEEK(rng)
-	decrypter, err := enc.NewDecrypter(keys)
+	decrypter, err := enc.NewDecrypter(keys, nil)
 	assert.Nil(t, err)
 	pages := make(chan *api.Page, 1)
 
@@ -257,9 +264,9 @@ func TestPaginateUnpaginate(t *testing.T) {
 	keys := enc.NewPseudoRandomEEK(rng)
 	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
 
-	encrypter, err := enc.NewEncrypter(keys)
+	encrypter, err := enc.NewEncrypter(keys, nil)
 	assert.Nil(t, err)
-	decrypter, err := enc.NewDecrypter(keys)
+	decrypter, err := enc.NewDecrypter(keys, nil)
 	assert.Nil(t, err)
 
 	MinSize = 64 // just for testing
@@ -307,6 +314,162 @@ func TestPaginateUnpaginate(t *testing.T) {
 	}
 }
 
+func TestNewReaderAtPaginator_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	badKeys := enc.NewPseudoRandomEEK(rng)
+	badKeys.HMACKey = nil
+
+	cases := []struct {
+		keys        *enc.EEK
+		authorPub   []byte
+		pageSize    uint32
+		parallelism uint32
+	}{
+		{badKeys, authorPub, MaxSize, 1},  // invalid HMAC key
+		{keys, nil, MaxSize, 1},           // invalid author public key
+		{keys, authorPub, 0, 1},           // page size too small
+		{keys, authorPub, MaxSize + 1, 1}, // page size too large
+		{keys, authorPub, MaxSize, 0},     // zero parallelism
+	}
+	for i, c := range cases {
+		p, err := NewReaderAtPaginator(nil, nil, c.keys, c.authorPub, c.pageSize,
+			c.parallelism)
+		assert.NotNil(t, err, "case %d", i)
+		assert.Nil(t, p, "case %d", i)
+	}
+}
+
+func TestReaderAtPaginator_PaginateAt_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	keys := enc.NewPseudoRandomEEK(rng)
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	pageBinding, err := enc.NewPageBinding()
+	assert.Nil(t, err)
+	newEncrypter := func() (enc.Encrypter, error) {
+		return enc.NewEncrypter(keys, pageBinding)
+	}
+
+	MinSize = 64 // just for testing
+	pageSize := uint32(128)
+	sizes := []int{0, 1, 127, 128, 129, 256, 1000, 4096}
+	parallelisms := []uint32{1, 2, 3, 8}
+
+	for _, size := range sizes {
+		content := api.RandBytes(rng, size)
+
+		// paginate sequentially as uncompressed content
+		encrypter, err := newEncrypter()
+		assert.Nil(t, err)
+		seqPages := make(chan *api.Page, size/int(pageSize)+1)
+		seqPaginator, err := NewPaginator(seqPages, encrypter, keys, authorPub, pageSize)
+		assert.Nil(t, err)
+		compressor, err := comp.NewCompressor(bytes.NewReader(content), comp.NoneCodec, keys,
+			pageSize/2)
+		assert.Nil(t, err)
+		_, err = seqPaginator.ReadFrom(compressor)
+		assert.Nil(t, err)
+		close(seqPages)
+		expected := make([]*api.Page, 0)
+		for page := range seqPages {
+			expected = append(expected, page)
+		}
+
+		for _, parallelism := range parallelisms {
+			info := fmt.Sprintf("size: %d, parallelism: %d", size, parallelism)
+			pages := make(chan *api.Page, 3)
+			paginator, err := NewReaderAtPaginator(pages, newEncrypter, keys, authorPub,
+				pageSize, parallelism)
+			assert.Nil(t, err)
+
+			// check pages, MACs, and plaintext are the same as the sequential paginator's
+			plaintext := new(bytes.Buffer)
+			errs := make(chan error, 1)
+			go func() {
+				errs <- paginator.PaginateAt(bytes.NewReader(content), int64(size), plaintext)
+				close(pages)
+			}()
+			actual := make([]*api.Page, 0)
+			for page := range pages {
+				actual = append(actual, page)
+			}
+			assert.Nil(t, <-errs, info)
+			assert.Equal(t, expected, actual, info)
+			assert.Equal(t, seqPaginator.CiphertextMAC().MessageSize(),
+				paginator.CiphertextMAC().MessageSize(), info)
+			assert.Equal(t, seqPaginator.CiphertextMAC().Sum(nil),
+				paginator.CiphertextMAC().Sum(nil), info)
+			assert.Equal(t, content, plaintext.Bytes(), info)
+		}
+	}
+}
+
+func TestReaderAtPaginator_PaginateAt_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	keys := enc.NewPseudoRandomEEK(rng)
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	MinSize = 64 // just for testing
+	pageSize := uint32(128)
+	content := api.RandBytes(rng, 1000)
+	okNewEncrypter := func() (enc.Encrypter, error) {
+		return enc.NewEncrypter(keys, nil)
+	}
+
+	cases := map[string]struct {
+		newEncrypter func() (enc.Encrypter, error)
+		size         int64
+		plaintext    io.Writer
+		authorPub    []byte
+	}{
+		"newEncrypter error": {
+			newEncrypter: func() (enc.Encrypter, error) {
+				return nil, errors.New("some NewEncrypter error")
+			},
+			size:      int64(len(content)),
+			plaintext: new(bytes.Buffer),
+		},
+		"Encrypt error": {
+			newEncrypter: func() (enc.Encrypter, error) {
+				return &fixedEncrypter{encryptErr: errors.New("some Encrypt error")}, nil
+			},
+			size:      int64(len(content)),
+			plaintext: new(bytes.Buffer),
+		},
+		"content shorter than size": {
+			newEncrypter: okNewEncrypter,
+			size:         int64(len(content)) + 1,
+			plaintext:    new(bytes.Buffer),
+		},
+		"plaintext Write error": {
+			newEncrypter: okNewEncrypter,
+			size:         int64(len(content)),
+			plaintext:    &errCloseWriter{writeErr: errors.New("some Write error")},
+		},
+		"ValidatePage error": {
+			newEncrypter: okNewEncrypter,
+			size:         int64(len(content)),
+			plaintext:    new(bytes.Buffer),
+			authorPub:    []byte{}, // will cause ValidatePage error
+		},
+	}
+	for desc, c := range cases {
+		pages := make(chan *api.Page, 3)
+		p, err := NewReaderAtPaginator(pages, c.newEncrypter, keys, authorPub, pageSize, 3)
+		assert.Nil(t, err, desc)
+		if c.authorPub != nil {
+			p.(*readerAtPaginator).authorPub = c.authorPub
+		}
+		go func() {
+			for range pages {
+			}
+		}()
+		err = p.PaginateAt(bytes.NewReader(content), c.size, c.plaintext)
+		assert.NotNil(t, err, desc)
+		close(pages)
+	}
+}
+
 type pageTestCase struct {
 	pageSize         uint32
 	uncompressedSize int
@@ -3,9 +3,12 @@ package page
 import (
 	"errors"
 
+	"github.com/drausin/libri/libri/author/io/publish"
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -16,19 +19,39 @@ var (
 
 	// ErrMissingPage indicates when a page was expected to be stored but was not found.
 	ErrMissingPage = errors.New("missing page")
+
+	// ErrCorruptPage indicates when a stored page no longer matches its key, and so should be
+	// acquired again from libri.
+	ErrCorruptPage = errors.New("corrupt page")
 )
 
+// MaxBatchBytes is the max total size (in bytes) of the pages Store writes together in one
+// batch. It bounds the memory held while storing and keeps each batch well under Badger's max
+// transaction size (about 10 MB).
+var MaxBatchBytes = 4 * 1024 * 1024 // 4 MB
+
 // Storer stores pages to an inner storage.DocumentStorer.
 type Storer interface {
-	// Store writes pages to inner storage and returns a slice of their keys.
+	// Store writes pages to inner storage as they're received, in batches of up to
+	// MaxBatchBytes, and returns a slice of their keys.
 	Store(pages chan *api.Page) ([]cid.ID, error)
+
+	// StoreBatch writes pages to inner storage together and returns a slice of their keys. When
+	// the inner storage is a storage.DocumentBatchStorer, either all or none of the pages are
+	// stored.
+	StoreBatch(pages ...*api.Page) ([]cid.ID, error)
+
+	// Delete removes the pages with the given keys from inner storage.
+	Delete(keys ...cid.ID) error
 }
 
 // Loader loads pages from an inner storage.DocmentLoader.
 type Loader interface {
 	// Load reads pages from inner storage and sends them on the supplied pages channel. A
-	// signal (or closing) on the abort channel interrupts the loading.
-	Load(keys []cid.ID, pages chan *api.Page, abort chan struct{}) error
+	// signal (or closing) on the abort channel interrupts the loading. Pages acquired from
+	// libri are acquired within ctx and checked against the author public key.
+	Load(ctx context.Context, keys []cid.ID, authorPub []byte, pages chan *api.Page,
+		abort chan struct{}) error
 }
 
 // StorerLoader stores and loads pages from an inner storage.DocumentStorerLoader.
@@ -39,6 +62,13 @@ type StorerLoader interface {
 
 type storerLoader struct {
 	inner storage.DocumentSLD
+
+	// optional acquirer of pages found to be corrupt in inner storage
+	msAcquirer publish.MultiStoreAcquirer
+	librarians api.ClientBalancer
+
+	// optional metrics counting those re-acquisitions
+	metrics *Metrics
 }
 
 // NewStorerLoader creates a new StorerLoader instance from an inner storage.DocumentSLD
@@ -49,40 +79,241 @@ func NewStorerLoader(inner storage.DocumentSLD) StorerLoader {
 	}
 }
 
+// NewRefetchingStorerLoader creates a new StorerLoader instance from an inner storage.DocumentSLD
+// instance that, when loading a page found to be corrupt in the inner storage, deletes it and
+// acquires it again from libri via the multi-store acquirer, counting it in the metrics, which
+// may be nil.
+func NewRefetchingStorerLoader(
+	inner storage.DocumentSLD,
+	msAcquirer publish.MultiStoreAcquirer,
+	librarians api.ClientBalancer,
+	metrics *Metrics,
+) StorerLoader {
+	return &storerLoader{
+		inner:      inner,
+		msAcquirer: msAcquirer,
+		librarians: librarians,
+		metrics:    metrics,
+	}
+}
+
+// Store writes the pages as they're received from the channel via StoreBatch, starting a new
+// batch whenever the next page would take the current one over MaxBatchBytes, so neither memory
+// nor any single batch grows with the number of pages.
 func (s *storerLoader) Store(pages chan *api.Page) ([]cid.ID, error) {
 	keys := make([]cid.ID, 0)
+	batch, batchBytes := make([]*api.Page, 0), 0
 	for page := range pages {
-		doc, key, err := api.GetPageDocument(page)
+		pageBytes := proto.Size(page)
+		if len(batch) > 0 && batchBytes+pageBytes > MaxBatchBytes {
+			batchKeys, err := s.StoreBatch(batch...)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, batchKeys...)
+			batch, batchBytes = make([]*api.Page, 0), 0
+		}
+		batch = append(batch, page)
+		batchBytes += pageBytes
+	}
+	batchKeys, err := s.StoreBatch(batch...)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, batchKeys...), nil
+}
+
+func (s *storerLoader) StoreBatch(pages ...*api.Page) ([]cid.ID, error) {
+	keys, docs := make([]cid.ID, len(pages)), make([]*api.Document, len(pages))
+	for i, page := range pages {
+		var err error
+		docs[i], keys[i], err = api.GetPageDocument(page)
 		if err != nil {
 			return nil, err
 		}
-		if err := s.inner.Store(key, doc); err != nil {
+	}
+	if batchS, ok := s.inner.(storage.DocumentBatchStorer); ok {
+		if err := batchS.StoreBatch(keys, docs); err != nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+	for i, key := range keys {
+		if err := s.inner.Store(key, docs[i]); err != nil {
 			return nil, err
 		}
-		keys = append(keys, key)
 	}
 	return keys, nil
 }
 
-func (s *storerLoader) Load(keys []cid.ID, pages chan *api.Page, abort chan struct{}) error {
+func (s *storerLoader) Delete(keys ...cid.ID) error {
 	for _, key := range keys {
-		doc, err := s.inner.Load(key)
-		if err != nil {
+		if err := s.inner.Delete(key); err != nil {
 			return err
 		}
-		if doc == nil {
-			return ErrMissingPage
-		}
-		docPage, ok := doc.Contents.(*api.Document_Page)
-		if !ok {
-			return ErrUnexpectedDocContent
+	}
+	return nil
+}
+
+func (s *storerLoader) Load(
+	ctx context.Context,
+	keys []cid.ID,
+	authorPub []byte,
+	pages chan *api.Page,
+	abort chan struct{},
+) error {
+	for _, key := range keys {
+		page, err := s.loadPage(ctx, key, authorPub)
+		if err != nil {
+			return err
 		}
 		select {
 		case <-abort:
 			return nil
 		default:
-			pages <- docPage.Page
+			pages <- page
+		}
+	}
+	return nil
+}
+
+type streamingStorerLoader struct {
+	storerLoader
+	parallelism int
+}
+
+// NewStreamingStorerLoader creates a new StorerLoader that stores pages in the inner
+// storage.DocumentSLD but acquires them from libri as they are loaded. Pages are acquired in
+// batches of parallelism pages via the multi-store acquirer and deleted from the inner storage
+// once they have been sent on, so at most parallelism acquired pages are held at once. Pages
+// already in the inner storage are left there. Pages found to be corrupt in the inner storage are
+// acquired again and counted in the metrics, which may be nil.
+func NewStreamingStorerLoader(
+	inner storage.DocumentSLD,
+	msAcquirer publish.MultiStoreAcquirer,
+	librarians api.ClientBalancer,
+	parallelism uint32,
+	metrics *Metrics,
+) StorerLoader {
+	return &streamingStorerLoader{
+		storerLoader: storerLoader{
+			inner:      inner,
+			msAcquirer: msAcquirer,
+			librarians: librarians,
+			metrics:    metrics,
+		},
+		parallelism: int(parallelism),
+	}
+}
+
+func (s *streamingStorerLoader) Load(
+	ctx context.Context,
+	keys []cid.ID,
+	authorPub []byte,
+	pages chan *api.Page,
+	abort chan struct{},
+) error {
+	for i := 0; i < len(keys); i += s.parallelism {
+		j := i + s.parallelism
+		if j > len(keys) {
+			j = len(keys)
+		}
+		acquired, err := s.acquireMissing(ctx, keys[i:j], authorPub)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys[i:j] {
+			page, err := s.loadPage(ctx, key, authorPub)
+			if err != nil {
+				return err
+			}
+			select {
+			case <-abort:
+				return nil
+			default:
+				pages <- page
+			}
+			if _, in := acquired[key.String()]; !in {
+				// leave pages that were stored before this Load
+				continue
+			}
+			if err := s.inner.Delete(key); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
+
+// acquireMissing acquires from libri the pages with the given keys not already in inner storage
+// or corrupt there, returning the set of (string) keys it acquired.
+func (s *streamingStorerLoader) acquireMissing(
+	ctx context.Context, keys []cid.ID, authorPub []byte,
+) (map[string]struct{}, error) {
+	missing := make([]cid.ID, 0, len(keys))
+	acquired := make(map[string]struct{})
+	for _, key := range keys {
+		doc, err := s.inner.Load(key)
+		if err == storage.ErrCorruptDocument {
+			if err = s.inner.Delete(key); err != nil {
+				return nil, err
+			}
+			s.metrics.incCorruptRefetches()
+			missing = append(missing, key)
+			acquired[key.String()] = struct{}{}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			missing = append(missing, key)
+			acquired[key.String()] = struct{}{}
+		}
+	}
+	if len(missing) == 0 {
+		return acquired, nil
+	}
+	err := s.msAcquirer.Acquire(ctx, missing, authorPub, s.librarians, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return acquired, nil
+}
+
+// loadPage loads the page with the given key from inner storage. If the stored page is corrupt
+// and there is a multi-store acquirer, the page is deleted and acquired again from libri.
+func (s *storerLoader) loadPage(ctx context.Context, key cid.ID, authorPub []byte) (
+	*api.Page, error) {
+	page, err := loadPage(s.inner, key)
+	if err != ErrCorruptPage || s.msAcquirer == nil {
+		return page, err
+	}
+	if err := s.inner.Delete(key); err != nil {
+		return nil, err
+	}
+	s.metrics.incCorruptRefetches()
+	err = s.msAcquirer.Acquire(ctx, []cid.ID{key}, authorPub, s.librarians, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return loadPage(s.inner, key)
+}
+
+func loadPage(docL storage.DocumentLoader, key cid.ID) (*api.Page, error) {
+	doc, err := docL.Load(key)
+	if err == storage.ErrCorruptDocument {
+		return nil, ErrCorruptPage
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrMissingPage
+	}
+	docPage, ok := doc.Contents.(*api.Document_Page)
+	if !ok {
+		return nil, ErrUnexpectedDocContent
+	}
+	return docPage.Page, nil
+}
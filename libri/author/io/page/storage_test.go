@@ -6,9 +6,14 @@ import (
 
 	"errors"
 
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestStorerLoader_Store_ok(t *testing.T) {
@@ -31,6 +36,40 @@ func TestStorerLoader_Store_ok(t *testing.T) {
 	assert.Equal(t, nPages, len(pageIDs))
 }
 
+func TestStorerLoader_Store_batches(t *testing.T) {
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	inner := &countingDocSLD{DocumentSLD: storage.NewDocumentSLD(kvdb)}
+	sl := NewStorerLoader(inner)
+	rng := rand.New(rand.NewSource(0))
+	nPages := 4
+	pages := make(chan *api.Page, nPages)
+	for c := 0; c < nPages; c++ {
+		pages <- api.NewTestPage(rng)
+	}
+	close(pages)
+	defer func(orig int) { MaxBatchBytes = orig }(MaxBatchBytes)
+	MaxBatchBytes = 1 // just for testing, so each page is its own batch
+
+	// check each page is stored in its own batch
+	pageIDs, err := sl.Store(pages)
+	assert.Nil(t, err)
+	assert.Equal(t, nPages, len(pageIDs))
+	assert.Equal(t, nPages, inner.nBatches)
+	for _, pageID := range pageIDs {
+		_, err := loadPage(inner, pageID)
+		assert.Nil(t, err)
+	}
+
+	// check deleted pages are removed
+	assert.Nil(t, sl.Delete(pageIDs...))
+	for _, pageID := range pageIDs {
+		stored, err := inner.Load(pageID)
+		assert.Nil(t, err)
+		assert.Nil(t, stored)
+	}
+}
+
 func TestStorerLoader_Store_err(t *testing.T) {
 	sl := NewStorerLoader(&fixedDocSLD{storeErr: errors.New("some Store error")})
 	rng := rand.New(rand.NewSource(0))
@@ -44,6 +83,62 @@ func TestStorerLoader_Store_err(t *testing.T) {
 	assert.Nil(t, pageIDs)
 }
 
+func TestStorerLoader_StoreBatch_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	nPages := 4
+	pages := make([]*api.Page, nPages)
+	for c := range pages {
+		pages[c] = api.NewTestPage(rng)
+	}
+
+	// check batched & non-batched inner storage both store all pages
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	inners := []storage.DocumentSLD{
+		storage.NewDocumentSLD(kvdb),
+		&fixedDocSLD{stored: make(map[string]*api.Document)},
+	}
+	for _, inner := range inners {
+		sl := NewStorerLoader(inner)
+		pageIDs, err := sl.StoreBatch(pages...)
+		assert.Nil(t, err)
+		assert.Equal(t, nPages, len(pageIDs))
+		for c, pageID := range pageIDs {
+			page, err := loadPage(inner, pageID)
+			assert.Nil(t, err)
+			assert.Equal(t, pages[c], page)
+		}
+	}
+}
+
+func TestStorerLoader_StoreBatch_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	inner := storage.NewDocumentSLD(kvdb)
+	sl := NewStorerLoader(inner)
+
+	okPage, badPage := api.NewTestPage(rng), api.NewTestPage(rng)
+	badPage.AuthorPublicKey = nil
+	okDoc, okKey, err := api.GetPageDocument(okPage)
+	assert.Nil(t, err)
+	assert.NotNil(t, okDoc)
+
+	// check invalid page fails the whole batch, leaving none of its pages stored
+	pageIDs, err := sl.StoreBatch(okPage, badPage)
+	assert.NotNil(t, err)
+	assert.Nil(t, pageIDs)
+	stored, err := inner.Load(okKey)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+
+	// check non-batched inner store error bubbles up
+	sl = NewStorerLoader(&fixedDocSLD{storeErr: errors.New("some Store error")})
+	pageIDs, err = sl.StoreBatch(okPage)
+	assert.NotNil(t, err)
+	assert.Nil(t, pageIDs)
+}
+
 func TestStorerLoader_Load_ok(t *testing.T) {
 	stored := make(map[string]*api.Document)
 	rng := rand.New(rand.NewSource(0))
@@ -66,7 +161,7 @@ func TestStorerLoader_Load_ok(t *testing.T) {
 	)
 
 	pages, abort := make(chan *api.Page, nPages), make(chan struct{})
-	err := sl.Load(pageIDs, pages, abort)
+	err := sl.Load(context.Background(), pageIDs, nil, pages, abort)
 	assert.Nil(t, err)
 	close(pages)
 
@@ -84,7 +179,7 @@ func TestStorerLoader_Load_ok(t *testing.T) {
 	pages, abort = make(chan *api.Page, nPages), make(chan struct{})
 	close(abort)
 	close(pages)
-	err = sl.Load(pageIDs, pages, abort)
+	err = sl.Load(context.Background(), pageIDs, nil, pages, abort)
 	assert.Nil(t, err)
 }
 
@@ -94,7 +189,7 @@ func TestStorerLoader_Load_err(t *testing.T) {
 	pageIDs1 := []id.ID{id.NewPseudoRandom(rng)}
 
 	// check inner load error bubbles up
-	err := sl1.Load(pageIDs1, nil, make(chan struct{}))
+	err := sl1.Load(context.Background(), pageIDs1, nil, nil, make(chan struct{}))
 	assert.NotNil(t, err)
 
 	sl2 := NewStorerLoader(
@@ -105,7 +200,7 @@ func TestStorerLoader_Load_err(t *testing.T) {
 	pageIDs2 := []id.ID{id.NewPseudoRandom(rng)}
 
 	// check error on missing doc
-	err = sl2.Load(pageIDs2, nil, make(chan struct{}))
+	err = sl2.Load(context.Background(), pageIDs2, nil, nil, make(chan struct{}))
 	assert.NotNil(t, err)
 
 	stored := make(map[string]*api.Document)
@@ -120,7 +215,7 @@ func TestStorerLoader_Load_err(t *testing.T) {
 	)
 
 	// check error returned from non-Page document
-	err = sl3.Load(pageIDs3, nil, make(chan struct{}))
+	err = sl3.Load(context.Background(), pageIDs3, nil, nil, make(chan struct{}))
 	assert.NotNil(t, err)
 }
 
@@ -145,7 +240,7 @@ func TestStorerLoader_StoreLoad(t *testing.T) {
 	assert.Equal(t, nPages, len(pageIDs))
 
 	pagesToLoad := make(chan *api.Page, nPages)
-	err = sl.Load(pageIDs, pagesToLoad, make(chan struct{}))
+	err = sl.Load(context.Background(), pageIDs, nil, pagesToLoad, make(chan struct{}))
 	assert.Nil(t, err)
 	close(pagesToLoad)
 
@@ -158,6 +253,157 @@ func TestStorerLoader_StoreLoad(t *testing.T) {
 	assert.Equal(t, originalPages, loadedPages)
 }
 
+func TestStorerLoader_Load_corrupt(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	local := storage.NewDocumentSLD(kvdb)
+	originalPage := api.NewTestPage(rng)
+	doc, key, err := api.GetPageDocument(originalPage)
+	assert.Nil(t, err)
+	corrupt := func() {
+		assert.Nil(t, local.Store(key, doc))
+		nsKey := append(storage.Documents, key.Bytes()...)
+		valueBytes, err2 := kvdb.Get(nsKey)
+		assert.Nil(t, err2)
+		valueBytes[rng.Intn(len(valueBytes))] ^= 0xff
+		assert.Nil(t, kvdb.Put(nsKey, valueBytes))
+	}
+
+	// check flipped byte is detected
+	corrupt()
+	sl1 := NewStorerLoader(local)
+	err = sl1.Load(context.Background(), []id.ID{key}, nil, make(chan *api.Page, 1),
+		make(chan struct{}))
+	assert.Equal(t, ErrCorruptPage, err)
+
+	// check corrupt page is acquired again when loading
+	metrics := NewMetrics()
+	msAcq := &memMultiStoreAcquirer{
+		remote: map[string]*api.Document{key.String(): doc},
+		local:  local,
+	}
+	sl2 := NewRefetchingStorerLoader(local, msAcq, nil, metrics)
+	pages := make(chan *api.Page, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authorPub := originalPage.AuthorPublicKey
+	err = sl2.Load(ctx, []id.ID{key}, authorPub, pages, make(chan struct{}))
+	assert.Nil(t, err)
+	assert.Equal(t, originalPage, <-pages)
+	assert.Equal(t, [][]id.ID{{key}}, msAcq.acquired)
+	assert.Equal(t, ctx, msAcq.ctx)
+	assert.Equal(t, authorPub, msAcq.authorPub)
+	assert.Equal(t, float64(1), getCorruptRefetches(t, metrics))
+
+	// check corrupt page is acquired again when streaming
+	corrupt()
+	msAcq.acquired, msAcq.ctx, msAcq.authorPub = nil, nil, nil
+	sl3 := NewStreamingStorerLoader(local, msAcq, nil, 1, metrics)
+	err = sl3.Load(ctx, []id.ID{key}, authorPub, pages, make(chan struct{}))
+	assert.Nil(t, err)
+	assert.Equal(t, originalPage, <-pages)
+	assert.Equal(t, [][]id.ID{{key}}, msAcq.acquired)
+	assert.Equal(t, ctx, msAcq.ctx)
+	assert.Equal(t, authorPub, msAcq.authorPub)
+	assert.Equal(t, float64(2), getCorruptRefetches(t, metrics))
+
+	// check acquire error bubbles up
+	corrupt()
+	msAcq.err = errors.New("some Acquire error")
+	err = sl2.Load(context.Background(), []id.ID{key}, nil, pages, make(chan struct{}))
+	assert.Equal(t, msAcq.err, err)
+}
+
+func TestStreamingStorerLoader_Load_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	nPages, parallelism := 5, uint32(2)
+	local := &fixedDocSLD{stored: make(map[string]*api.Document)}
+	msAcq := &memMultiStoreAcquirer{
+		remote: make(map[string]*api.Document),
+		local:  local,
+	}
+	originalPages := make([]*api.Page, nPages)
+	pageIDs := make([]id.ID, nPages)
+	for i := 0; i < nPages; i++ {
+		originalPages[i] = api.NewTestPage(rng)
+		doc, key, err := api.GetPageDocument(originalPages[i])
+		assert.Nil(t, err)
+		msAcq.remote[key.String()] = doc
+		pageIDs[i] = key
+	}
+
+	// first page is already stored locally, so shouldn't be acquired
+	err := local.Store(pageIDs[0], msAcq.remote[pageIDs[0].String()])
+	assert.Nil(t, err)
+
+	sl := NewStreamingStorerLoader(local, msAcq, nil, parallelism, nil)
+	pagesToLoad := make(chan *api.Page, nPages)
+	err = sl.Load(context.Background(), pageIDs, nil, pagesToLoad, make(chan struct{}))
+	assert.Nil(t, err)
+	close(pagesToLoad)
+
+	loadedPages := make([]*api.Page, 0, nPages)
+	for p := range pagesToLoad {
+		loadedPages = append(loadedPages, p)
+	}
+
+	// check pages are loaded in order & acquired in batches no larger than parallelism
+	assert.Equal(t, originalPages, loadedPages)
+	assert.Equal(t, [][]id.ID{pageIDs[1:2], pageIDs[2:4], pageIDs[4:5]}, msAcq.acquired)
+
+	// check acquired pages have been deleted from local storage but the already-stored one kept
+	assert.Len(t, local.stored, 1)
+	_, in := local.stored[pageIDs[0].String()]
+	assert.True(t, in)
+}
+
+func TestStreamingStorerLoader_Load_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	pageIDs := []id.ID{id.NewPseudoRandom(rng), id.NewPseudoRandom(rng)}
+
+	// check acquire error bubbles up
+	local1 := &fixedDocSLD{stored: make(map[string]*api.Document)}
+	msAcq1 := &memMultiStoreAcquirer{local: local1, err: errors.New("some Acquire error")}
+	sl1 := NewStreamingStorerLoader(local1, msAcq1, nil, 2, nil)
+	err := sl1.Load(context.Background(), pageIDs, nil, make(chan *api.Page, 2),
+		make(chan struct{}))
+	assert.NotNil(t, err)
+
+	// check local load error bubbles up
+	local2 := &fixedDocSLD{loadErr: errors.New("some Load error")}
+	msAcq2 := &memMultiStoreAcquirer{local: local2}
+	sl2 := NewStreamingStorerLoader(local2, msAcq2, nil, 2, nil)
+	err = sl2.Load(context.Background(), pageIDs, nil, make(chan *api.Page, 2),
+		make(chan struct{}))
+	assert.NotNil(t, err)
+
+	// check missing acquired page returns error
+	local3 := &fixedDocSLD{stored: make(map[string]*api.Document)}
+	msAcq3 := &memMultiStoreAcquirer{remote: make(map[string]*api.Document), local: local3}
+	sl3 := NewStreamingStorerLoader(local3, msAcq3, nil, 2, nil)
+	err = sl3.Load(context.Background(), pageIDs, nil, make(chan *api.Page, 2),
+		make(chan struct{}))
+	assert.Equal(t, ErrMissingPage, err)
+
+	// check delete error bubbles up
+	page := api.NewTestPage(rng)
+	doc, key, err := api.GetPageDocument(page)
+	assert.Nil(t, err)
+	local4 := &fixedDocSLD{
+		stored:    make(map[string]*api.Document),
+		deleteErr: errors.New("some Delete error"),
+	}
+	msAcq4 := &memMultiStoreAcquirer{
+		remote: map[string]*api.Document{key.String(): doc},
+		local:  local4,
+	}
+	sl4 := NewStreamingStorerLoader(local4, msAcq4, nil, 2, nil)
+	err = sl4.Load(context.Background(), []id.ID{key}, nil, make(chan *api.Page, 1),
+		make(chan struct{}))
+	assert.NotNil(t, err)
+}
+
 type fixedDocSLD struct {
 	storeErr error
 	stored   map[string]*api.Document
@@ -179,5 +425,58 @@ func (f *fixedDocSLD) Load(key id.ID) (*api.Document, error) {
 }
 
 func (f *fixedDocSLD) Delete(key id.ID) error {
-	return f.deleteErr
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	delete(f.stored, key.String())
+	return nil
+}
+
+// countingDocSLD counts the batches stored in its inner storage.DocumentSLD.
+type countingDocSLD struct {
+	storage.DocumentSLD
+	nBatches int
+}
+
+func (c *countingDocSLD) StoreBatch(keys []id.ID, values []*api.Document) error {
+	c.nBatches++
+	return c.DocumentSLD.(storage.DocumentBatchStorer).StoreBatch(keys, values)
+}
+
+// memMultiStoreAcquirer acquires documents from a remote map into local docSLD.
+type memMultiStoreAcquirer struct {
+	remote    map[string]*api.Document
+	local     storage.DocumentStorer
+	acquired  [][]id.ID
+	ctx       context.Context
+	authorPub []byte
+	err       error
+}
+
+func (a *memMultiStoreAcquirer) Acquire(
+	ctx context.Context,
+	docKeys []id.ID,
+	authorPub []byte,
+	cb api.ClientBalancer,
+	search *publish.Search,
+	progress publish.Progress,
+) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.acquired = append(a.acquired, docKeys)
+	a.ctx, a.authorPub = ctx, authorPub
+	for _, docKey := range docKeys {
+		if err := a.local.Store(docKey, a.remote[docKey.String()]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getCorruptRefetches(t *testing.T, metrics *Metrics) float64 {
+	m := &dto.Metric{}
+	err := metrics.corruptRefetches.Write(m)
+	assert.Nil(t, err)
+	return m.Counter.GetValue()
 }
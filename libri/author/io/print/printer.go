@@ -3,12 +3,14 @@ package print
 import (
 	"errors"
 	"io"
+	"io/ioutil"
 
 	"github.com/drausin/libri/libri/author/io/comp"
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/page"
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
 )
 
 const (
@@ -19,18 +21,31 @@ const (
 // ErrZeroParallelism indicates when Print and Scan parallelism is improperly set to zero.
 var ErrZeroParallelism = errors.New("zero value parallelism")
 
+// ErrDocumentTooLarge indicates when a page or entry document is larger than the maximum size
+// librarians store.
+var ErrDocumentTooLarge = errors.New("document is larger than librarians' maximum size")
+
 // Parameters define various parameters used by Printers and Scanners.
 type Parameters struct {
 	// CompressionBufferSize is the size of the internal buffer used by comp.Compressors and
 	// comp.Decompressors.
 	CompressionBufferSize uint32
 
+	// CompressionCodec is the codec used by Printers when none is given to Print. The default
+	// comp.AutoCodec chooses it from the media type.
+	CompressionCodec comp.Codec
+
 	// PageSize is the maximum size (in bytes) of an api.Page ciphertext.
 	PageSize uint32
 
 	// Parallelism is the parallelism used by Printers and Scanners when storing and loading
 	// pages.
 	Parallelism uint32
+
+	// MaxDocumentBytes is the maximum size (in bytes) of the documents librarians store, if
+	// known, which printed pages and packed entries are checked against before they're stored.
+	// Zero means the maximum isn't known, so documents aren't checked.
+	MaxDocumentBytes uint64
 }
 
 // NewParameters creates a new *Parameters instance.
@@ -60,35 +75,69 @@ func NewDefaultParameters() *Parameters {
 // Printer stores pages created from (uncompressed) content.
 type Printer interface {
 	// Print creates pages from the given content and stores them via an internal page.Storer.
-	Print(content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte) (
-		[]id.ID, *api.Metadata, error)
+	// If codec is comp.AutoCodec, the Parameters CompressionCodec is used instead. The codec
+	// used is recorded in the returned metadata. If pageSize is zero, the Parameters PageSize is
+	// used instead. If printing fails, e.g., because a page is larger than the Parameters
+	// MaxDocumentBytes, the pages already stored are deleted again.
+	Print(content io.Reader, mediaType string, codec comp.Codec, pageSize uint32, keys *enc.EEK,
+		authorPub []byte) ([]id.ID, *api.Metadata, error)
+}
+
+// ReaderAtPrinter is a Printer that can also print content with random access.
+type ReaderAtPrinter interface {
+	Printer
+
+	// PrintAt is like Print but for the first size bytes of the content, which are also written
+	// in order to the digest io.Writer (e.g., a hash), if it isn't nil. If the content is
+	// uncompressed (i.e., the codec resolves to comp.NoneCodec), each page is a fixed range of
+	// the content, so pages are read and encrypted in parallel (up to the Parameters
+	// Parallelism). Otherwise, the content is read sequentially. Either way, the pages and
+	// metadata are the same as Print's.
+	PrintAt(content io.ReaderAt, size int64, mediaType string, codec comp.Codec, pageSize uint32,
+		keys *enc.EEK, authorPub []byte, digest io.Writer) ([]id.ID, *api.Metadata, error)
 }
 
 type printer struct {
 	params *Parameters
+	scheme enc.Scheme
 	pageS  page.Storer
 	init   printInitializer
 }
 
-// NewPrinter returns a new Printer instance.
+// NewPrinter returns a new Printer instance encrypting pages with the given scheme. It is also a
+// ReaderAtPrinter.
 func NewPrinter(
 	params *Parameters,
+	scheme enc.Scheme,
 	pageS page.Storer,
 ) Printer {
 	return &printer{
 		params: params,
+		scheme: scheme,
 		pageS:  pageS,
 		init: &printInitializerImpl{
 			params: params,
+			scheme: scheme,
 		},
 	}
 }
 
-func (p *printer) Print(content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte) (
-	[]id.ID, *api.Metadata, error) {
+func (p *printer) Print(
+	content io.Reader,
+	mediaType string,
+	codec comp.Codec,
+	pageSize uint32,
+	keys *enc.EEK,
+	authorPub []byte,
+) ([]id.ID, *api.Metadata, error) {
 
+	codec, pageSize, pageBinding, err := p.resolve(mediaType, codec, pageSize)
+	if err != nil {
+		return nil, nil, err
+	}
 	pages := make(chan *api.Page, int(p.params.Parallelism))
-	compressor, paginator, err := p.init.Initialize(content, mediaType, keys, authorPub, pages)
+	compressor, paginator, err := p.init.Initialize(content, codec, pageSize, keys, pageBinding,
+		authorPub, pages)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -100,60 +149,191 @@ func (p *printer) Print(content io.Reader, mediaType string, keys *enc.EEK, auth
 		}
 		close(pages)
 	}()
+	return p.store(pages, errs, mediaType, codec, pageBinding, paginator.CiphertextMAC(),
+		compressor.UncompressedMAC())
+}
 
-	pageKeys, err := p.pageS.Store(pages)
+func (p *printer) PrintAt(
+	content io.ReaderAt,
+	size int64,
+	mediaType string,
+	codec comp.Codec,
+	pageSize uint32,
+	keys *enc.EEK,
+	authorPub []byte,
+	digest io.Writer,
+) ([]id.ID, *api.Metadata, error) {
+
+	if digest == nil {
+		digest = ioutil.Discard
+	}
+	codec, pageSize, pageBinding, err := p.resolve(mediaType, codec, pageSize)
 	if err != nil {
 		return nil, nil, err
 	}
+	if codec != comp.NoneCodec {
+		sequential := io.TeeReader(io.NewSectionReader(content, 0, size), digest)
+		return p.Print(sequential, mediaType, codec, pageSize, keys, authorPub)
+	}
+	pages := make(chan *api.Page, int(p.params.Parallelism))
+	newEncrypter := func() (enc.Encrypter, error) {
+		return p.scheme.NewEncrypter(keys, pageBinding)
+	}
+	paginator, err := page.NewReaderAtPaginator(pages, newEncrypter, keys, authorPub, pageSize,
+		p.params.Parallelism)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// uncompressed content is the same as the compressed content paginated
+	uncompressedMAC := enc.NewHMAC(keys.HMACKey)
+	errs := make(chan error, 1)
+	go func() {
+		paErr := paginator.PaginateAt(content, size, io.MultiWriter(uncompressedMAC, digest))
+		if paErr != nil {
+			errs <- paErr
+		}
+		close(pages)
+	}()
+	return p.store(pages, errs, mediaType, codec, pageBinding, paginator.CiphertextMAC(),
+		uncompressedMAC)
+}
+
+// resolve returns the codec and page size to print with, given the Print arguments, and a new
+// page binding.
+func (p *printer) resolve(mediaType string, codec comp.Codec, pageSize uint32) (
+	comp.Codec, uint32, []byte, error) {
+
+	if codec == comp.AutoCodec {
+		codec = p.params.CompressionCodec
+	}
+	codec, err := comp.ResolveCodec(codec, mediaType)
+	if err != nil {
+		return codec, 0, nil, err
+	}
+	if pageSize == 0 {
+		pageSize = p.params.PageSize
+	}
+	pageBinding, err := enc.NewPageBinding()
+	if err != nil {
+		return codec, 0, nil, err
+	}
+	return codec, pageSize, pageBinding, nil
+}
+
+// store stores the pages from the channel as they're created and returns their keys and the
+// entry metadata. The pages are written in batches of up to page.MaxBatchBytes, so memory doesn't
+// grow with the content. If the paginating goroutine sent an error or any page fails to store,
+// the pages stored so far are deleted again. Only the entry later stored with their keys commits
+// the pages, so an interrupted print never leaves a partially stored entry.
+func (p *printer) store(
+	pages chan *api.Page,
+	errs chan error,
+	mediaType string,
+	codec comp.Codec,
+	pageBinding []byte,
+	ciphertextMAC enc.MAC,
+	uncompressedMAC enc.MAC,
+) ([]id.ID, *api.Metadata, error) {
 
-	select {
-	case err = <-errs:
+	pageKeys := make([]id.ID, 0)
+	batch, batchBytes := make([]*api.Page, 0), 0
+	storeBatch := func() error {
+		batchKeys, err := p.pageS.StoreBatch(batch...)
+		if err != nil {
+			return err
+		}
+		pageKeys = append(pageKeys, batchKeys...)
+		batch, batchBytes = make([]*api.Page, 0), 0
+		return nil
+	}
+	var err error
+	for chanPage := range pages {
+		if err != nil {
+			continue // drain the remaining pages so the paginating goroutine can finish
+		}
+		pageDoc := &api.Document{Contents: &api.Document_Page{Page: chanPage}}
+		if err = CheckDocumentSize(pageDoc, p.params.MaxDocumentBytes); err != nil {
+			continue
+		}
+		pageBytes := proto.Size(chanPage)
+		if len(batch) > 0 && batchBytes+pageBytes > page.MaxBatchBytes {
+			if err = storeBatch(); err != nil {
+				continue
+			}
+		}
+		batch = append(batch, chanPage)
+		batchBytes += pageBytes
+	}
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+			err = storeBatch()
+		}
+	}
+	if err != nil {
+		// best effort, since without an entry the stored pages are unreachable either way
+		_ = p.pageS.Delete(pageKeys...)
 		return nil, nil, err
-	default:
 	}
 
 	metadata, err := api.NewEntryMetadata(
 		mediaType,
-		paginator.CiphertextMAC().MessageSize(),
-		paginator.CiphertextMAC().Sum(nil),
-		compressor.UncompressedMAC().MessageSize(),
-		compressor.UncompressedMAC().Sum(nil),
+		ciphertextMAC.MessageSize(),
+		ciphertextMAC.Sum(nil),
+		uncompressedMAC.MessageSize(),
+		uncompressedMAC.Sum(nil),
 	)
 	if err != nil {
 		return nil, nil, err
 	}
+	metadata.SetString(api.MetadataEntryCompressionCodec, string(codec))
+	metadata.SetBytes(api.MetadataEntryPageBinding, pageBinding)
 
 	return pageKeys, metadata, nil
 }
 
+// CheckDocumentSize returns ErrDocumentTooLarge if the document is larger than maxBytes, where
+// zero means no maximum.
+func CheckDocumentSize(doc *api.Document, maxBytes uint64) error {
+	if maxBytes > 0 && uint64(proto.Size(doc)) > maxBytes {
+		return ErrDocumentTooLarge
+	}
+	return nil
+}
+
 type printInitializer interface {
-	Initialize(content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte,
-		pages chan *api.Page) (comp.Compressor, page.Paginator, error)
+	Initialize(content io.Reader, codec comp.Codec, pageSize uint32, keys *enc.EEK,
+		pageBinding []byte, authorPub []byte, pages chan *api.Page) (comp.Compressor,
+		page.Paginator, error)
 }
 
 type printInitializerImpl struct {
 	params *Parameters
+	scheme enc.Scheme
 }
 
 func (pi *printInitializerImpl) Initialize(
-	content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte, pages chan *api.Page,
+	content io.Reader,
+	codec comp.Codec,
+	pageSize uint32,
+	keys *enc.EEK,
+	pageBinding []byte,
+	authorPub []byte,
+	pages chan *api.Page,
 ) (comp.Compressor, page.Paginator, error) {
 
-	codec, err := comp.GetCompressionCodec(mediaType)
-	if err != nil {
-		return nil, nil, err
-	}
 	compressor, err := comp.NewCompressor(content, codec, keys,
 		pi.params.CompressionBufferSize)
 	if err != nil {
 		return nil, nil, err
 	}
-	encrypter, err := enc.NewEncrypter(keys)
+	encrypter, err := pi.scheme.NewEncrypter(keys, pageBinding)
 	if err != nil {
 		return nil, nil, err
 	}
-	paginator, err := page.NewPaginator(pages, encrypter, keys, authorPub,
-		pi.params.PageSize)
+	paginator, err := page.NewPaginator(pages, encrypter, keys, authorPub, pageSize)
 	if err != nil {
 		return nil, nil, err
 	}
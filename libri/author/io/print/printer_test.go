@@ -16,7 +16,9 @@ import (
 	"github.com/drausin/libri/libri/author/io/page"
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestNewDefaultParameters(t *testing.T) {
@@ -66,14 +68,15 @@ func TestPrinter_Print_ok(t *testing.T) {
 		},
 	}
 
-	printer1 := NewPrinter(params, &fixedStorer{})
+	printer1 := NewPrinter(params, enc.NewAESGCMScheme(), &fixedStorer{})
 	printer1.(*printer).init = &fixedPrintInitializer{
 		initCompressor: compressor,
 		initPaginator:  paginator,
 		initErr:        nil,
 	}
 
-	pageKeys, entryMetadata, err := printer1.Print(nil, "application/x-pdf", keys, authorPub)
+	pageKeys, entryMetadata, err := printer1.Print(nil, "application/x-pdf", comp.AutoCodec, 0,
+		keys, authorPub)
 
 	assert.Nil(t, err)
 	assert.Equal(t, fixedPageKeys, pageKeys)
@@ -81,6 +84,100 @@ func TestPrinter_Print_ok(t *testing.T) {
 	assert.Equal(t, uint64(readCiphertextN), actualCiphertextSize)
 	actualCiphertextSum, _ := entryMetadata.GetCiphertextMAC()
 	assert.Equal(t, ciphertextSum, actualCiphertextSum)
+	actualCodec, _ := entryMetadata.GetCompressionCodec()
+	assert.Equal(t, string(comp.GZIPCodec), actualCodec)
+}
+
+func TestPrinter_Print_maxDocumentBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	params, err := NewParameters(comp.MinBufferSize, page.MinSize, DefaultParallelism)
+	assert.Nil(t, err)
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	fixedPageKeys, fixedPages := randPages(t, rng, 3)
+	maxPageDocBytes := uint64(0)
+	for _, p := range fixedPages {
+		pageDoc := &api.Document{Contents: &api.Document_Page{Page: p}}
+		if size := uint64(proto.Size(pageDoc)); size > maxPageDocBytes {
+			maxPageDocBytes = size
+		}
+	}
+	mac := &fixedMAC{messageSize: 1, sum: api.RandBytes(rng, api.HMAC256Length)}
+	newPrinter := func(storer page.Storer) Printer {
+		printer1 := NewPrinter(params, enc.NewAESGCMScheme(), storer)
+		printer1.(*printer).init = &fixedPrintInitializer{
+			initCompressor: &fixedCompressor{uncompressedMAC: mac},
+			initPaginator:  &fixedPaginator{fixedPages: fixedPages, ciphertextMAC: mac},
+		}
+		return printer1
+	}
+
+	// check pages at the max document size are stored
+	params.MaxDocumentBytes = maxPageDocBytes
+	pageKeys, _, err := newPrinter(&fixedStorer{}).Print(nil, "application/x-pdf",
+		comp.AutoCodec, 0, keys, authorPub)
+	assert.Nil(t, err)
+	assert.Equal(t, fixedPageKeys, pageKeys)
+
+	// check page just over the max document size gives error before storing any pages
+	params.MaxDocumentBytes = maxPageDocBytes - 1
+	storer := &fixedStorer{storeErr: errors.New("some Store error")}
+	pageKeys, _, err = newPrinter(storer).Print(nil, "application/x-pdf", comp.AutoCodec, 0,
+		keys, authorPub)
+	assert.Equal(t, ErrDocumentTooLarge, err)
+	assert.Nil(t, pageKeys)
+}
+
+func TestPrinter_Print_batches(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	params, err := NewParameters(comp.MinBufferSize, page.MinSize, DefaultParallelism)
+	assert.Nil(t, err)
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	nPages := 4
+	fixedPageKeys, fixedPages := randPages(t, rng, nPages)
+	mac := &fixedMAC{messageSize: 1, sum: api.RandBytes(rng, api.HMAC256Length)}
+	newPrinter := func(storer page.Storer, readErr error) Printer {
+		printer1 := NewPrinter(params, enc.NewAESGCMScheme(), storer)
+		printer1.(*printer).init = &fixedPrintInitializer{
+			initCompressor: &fixedCompressor{uncompressedMAC: mac},
+			initPaginator: &fixedPaginator{
+				fixedPages:    fixedPages,
+				readErr:       readErr,
+				ciphertextMAC: mac,
+			},
+		}
+		return printer1
+	}
+	defer func(orig int) { page.MaxBatchBytes = orig }(page.MaxBatchBytes)
+	page.MaxBatchBytes = 1 // just for testing, so each page is its own batch
+
+	// check each page is stored in its own batch as it's created
+	storer := &fixedStorer{}
+	pageKeys, _, err := newPrinter(storer, nil).Print(nil, "application/x-pdf",
+		comp.AutoCodec, 0, keys, authorPub)
+	assert.Nil(t, err)
+	assert.Equal(t, fixedPageKeys, pageKeys)
+	assert.Equal(t, nPages, storer.nBatches)
+	assert.Len(t, storer.deleted, 0)
+
+	// check pages already stored are deleted again when pagination then fails
+	storer = &fixedStorer{}
+	pageKeys, _, err = newPrinter(storer, errors.New("some ReadFrom error")).Print(nil,
+		"application/x-pdf", comp.AutoCodec, 0, keys, authorPub)
+	assert.NotNil(t, err)
+	assert.Nil(t, pageKeys)
+	assert.Equal(t, fixedPageKeys[:nPages-1], storer.deleted)
+}
+
+func TestCheckDocumentSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	doc, _ := api.NewTestDocument(rng)
+	size := uint64(proto.Size(doc))
+
+	assert.Nil(t, CheckDocumentSize(doc, 0))
+	assert.Nil(t, CheckDocumentSize(doc, size))
+	assert.Equal(t, ErrDocumentTooLarge, CheckDocumentSize(doc, size-1))
 }
 
 func TestPrinter_Print_err(t *testing.T) {
@@ -91,7 +188,7 @@ func TestPrinter_Print_err(t *testing.T) {
 	keys := enc.NewPseudoRandomEEK(rng)
 	content, mediaType := bytes.NewReader(api.RandBytes(rng, 64)), "application/x-pdf"
 
-	printer1 := NewPrinter(params, &fixedStorer{})
+	printer1 := NewPrinter(params, enc.NewAESGCMScheme(), &fixedStorer{})
 	printer1.(*printer).init = &fixedPrintInitializer{
 		initCompressor: nil,
 		initPaginator:  &fixedPaginator{},
@@ -99,15 +196,30 @@ func TestPrinter_Print_err(t *testing.T) {
 	}
 
 	// check that init error bubbles up
-	pageKeys, entryMetadata, err := printer1.Print(content, mediaType, keys, authorPub)
+	pageKeys, entryMetadata, err := printer1.Print(content, mediaType, comp.AutoCodec, 0,
+		keys, authorPub)
+	assert.NotNil(t, err)
+	assert.Nil(t, pageKeys)
+	assert.Nil(t, entryMetadata)
+
+	// check that bad media type triggers error
+	pageKeys, entryMetadata, err = printer1.Print(content, "application/", comp.AutoCodec, 0,
+		keys, authorPub)
 	assert.NotNil(t, err)
 	assert.Nil(t, pageKeys)
 	assert.Nil(t, entryMetadata)
 
+	// check that unknown codec triggers error
+	pageKeys, entryMetadata, err = printer1.Print(content, mediaType, comp.Codec("unknown"),
+		0, keys, authorPub)
+	assert.Equal(t, comp.ErrUnexpectedCodec, err)
+	assert.Nil(t, pageKeys)
+	assert.Nil(t, entryMetadata)
+
 	storer2 := &fixedStorer{
 		storeErr: errors.New("some Store error"),
 	}
-	printer2 := NewPrinter(params, storer2)
+	printer2 := NewPrinter(params, enc.NewAESGCMScheme(), storer2)
 	printer2.(*printer).init = &fixedPrintInitializer{
 		initCompressor: nil,
 		initPaginator:  &fixedPaginator{},
@@ -115,7 +227,8 @@ func TestPrinter_Print_err(t *testing.T) {
 	}
 
 	// check that store error bubbles up
-	pageKeys, entryMetadata, err = printer2.Print(content, mediaType, keys, authorPub)
+	pageKeys, entryMetadata, err = printer2.Print(content, mediaType, comp.AutoCodec, 0,
+		keys, authorPub)
 	assert.NotNil(t, err)
 	assert.Nil(t, pageKeys)
 	assert.Nil(t, entryMetadata)
@@ -125,7 +238,7 @@ func TestPrinter_Print_err(t *testing.T) {
 		readErr: errors.New("some ReadFrom error"),
 	}
 
-	printer3 := NewPrinter(params, &fixedStorer{})
+	printer3 := NewPrinter(params, enc.NewAESGCMScheme(), &fixedStorer{})
 	printer3.(*printer).init = &fixedPrintInitializer{
 		initCompressor: nil,
 		initPaginator:  paginator3,
@@ -133,7 +246,8 @@ func TestPrinter_Print_err(t *testing.T) {
 	}
 
 	// check that paginator.ReadFrom error bubbles up
-	pageKeys, entryMetadata, err = printer3.Print(content, mediaType, keys, authorPub)
+	pageKeys, entryMetadata, err = printer3.Print(content, mediaType, comp.AutoCodec, 0,
+		keys, authorPub)
 	assert.NotNil(t, err)
 	assert.Nil(t, pageKeys)
 	assert.Nil(t, entryMetadata)
@@ -150,7 +264,7 @@ func TestPrinter_Print_err(t *testing.T) {
 			sum:         []byte{},
 		},
 	}
-	printer4 := NewPrinter(params, &fixedStorer{})
+	printer4 := NewPrinter(params, enc.NewAESGCMScheme(), &fixedStorer{})
 	printer4.(*printer).init = &fixedPrintInitializer{
 		initCompressor: compressor,
 		initPaginator:  paginator,
@@ -158,7 +272,8 @@ func TestPrinter_Print_err(t *testing.T) {
 	}
 
 	// check that api.NewEntryMetadata error bubbles up
-	pageKeys, entryMetadata, err = printer4.Print(content, mediaType, keys, authorPub)
+	pageKeys, entryMetadata, err = printer4.Print(content, mediaType, comp.AutoCodec, 0,
+		keys, authorPub)
 	assert.NotNil(t, err)
 	assert.Nil(t, pageKeys)
 	assert.Nil(t, entryMetadata)
@@ -183,36 +298,290 @@ func TestPrintScan(t *testing.T) {
 	for _, c := range caseCrossProduct(pageSizes, uncompressedSizes, mediaTypes, parallelisms) {
 		params, err := NewParameters(comp.MinBufferSize, c.pageSize, c.parallelism)
 		assert.Nil(t, err)
-		p := NewPrinter(params, pageSL)
+		p := NewPrinter(params, enc.NewAESGCMScheme(), pageSL)
 		s := NewScanner(params, pageSL)
 
 		content1 := common.NewCompressableBytes(rng, c.uncompressedSize)
 		content1Bytes := content1.Bytes()
 
-		pageKey, metadata, err := p.Print(content1, c.mediaType, keys, authorPub)
+		pageKey, metadata, err := p.Print(content1, c.mediaType, comp.AutoCodec, 0,
+			keys, authorPub)
 		assert.Nil(t, err)
 
 		content2 := new(bytes.Buffer)
-		err = s.Scan(content2, pageKey, keys, metadata)
+		err = s.Scan(context.Background(), content2, pageKey, nil, enc.NewAESGCMScheme(),
+			keys, metadata)
 		assert.Nil(t, err)
 		assert.Equal(t, content1Bytes, content2.Bytes())
 	}
 }
 
+func TestPrintAtScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	pageSL := page.NewStorerLoader(
+		&fixedDocumentSLD{
+			stored: make(map[string]*api.Document),
+		},
+	)
+	page.MinSize = 64 // just for testing
+
+	uncompressedSizes := []int{0, 128, 192, 1024, 4096}
+	pageSizes := []uint32{128, 512}
+	mediaTypes := []string{"application/x-pdf", "application/x-gzip"} // GZIP and no codecs
+	parallelisms := []uint32{1, 3}
+
+	for _, c := range caseCrossProduct(pageSizes, uncompressedSizes, mediaTypes, parallelisms) {
+		params, err := NewParameters(comp.MinBufferSize, c.pageSize, c.parallelism)
+		assert.Nil(t, err)
+		p := NewPrinter(params, enc.NewAESGCMScheme(), pageSL).(ReaderAtPrinter)
+		s := NewScanner(params, pageSL)
+		content1Bytes := common.NewCompressableBytes(rng, c.uncompressedSize).Bytes()
+
+		digest := new(bytes.Buffer)
+		pageKeys, metadata, err := p.PrintAt(bytes.NewReader(content1Bytes),
+			int64(c.uncompressedSize), c.mediaType, comp.AutoCodec, 0, keys, authorPub, digest)
+		assert.Nil(t, err, c.String())
+		assert.Equal(t, content1Bytes, digest.Bytes(), c.String())
+
+		// check same number of pages and sizes as Print
+		expectedPageKeys, expectedMetadata, err := p.Print(bytes.NewReader(content1Bytes),
+			c.mediaType, comp.AutoCodec, 0, keys, authorPub)
+		assert.Nil(t, err)
+		assert.Equal(t, len(expectedPageKeys), len(pageKeys), c.String())
+		for _, key := range []string{
+			api.MetadataEntryMediaType,
+			api.MetadataEntryCompressionCodec,
+			api.MetadataEntryUncompressedSize,
+			api.MetadataEntryUncompressedMAC,
+		} {
+			expected, _ := expectedMetadata.GetBytes(key)
+			actual, _ := metadata.GetBytes(key)
+			assert.Equal(t, expected, actual, c.String())
+		}
+
+		content2 := new(bytes.Buffer)
+		err = s.Scan(context.Background(), content2, pageKeys, nil, enc.NewAESGCMScheme(),
+			keys, metadata)
+		assert.Nil(t, err, c.String())
+		assert.Equal(t, content1Bytes, content2.Bytes(), c.String())
+	}
+}
+
+func TestPrinter_PrintAt_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	page.MinSize = 64 // just for testing
+	params, err := NewParameters(comp.MinBufferSize, 256, DefaultParallelism)
+	assert.Nil(t, err)
+	content := api.RandBytes(rng, 1024)
+	mediaType := "application/x-gzip" // uncompressed, so paginated in parallel
+
+	// check bad codec error bubbles up
+	pageS := &fixedStorer{}
+	p := NewPrinter(params, enc.NewAESGCMScheme(), pageS).(ReaderAtPrinter)
+	pageKeys, metadata, err := p.PrintAt(bytes.NewReader(content), int64(len(content)),
+		mediaType, comp.Codec("bad"), 0, keys, authorPub, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, pageKeys)
+	assert.Nil(t, metadata)
+
+	// check NewReaderAtPaginator error bubbles up
+	pageKeys, metadata, err = p.PrintAt(bytes.NewReader(content), int64(len(content)),
+		mediaType, comp.AutoCodec, page.MaxSize+1, keys, authorPub, nil)
+	assert.Equal(t, page.ErrPageSizeTooLarge, err)
+	assert.Nil(t, pageKeys)
+	assert.Nil(t, metadata)
+
+	// check PaginateAt error bubbles up
+	pageKeys, metadata, err = p.PrintAt(bytes.NewReader(content), int64(len(content))+1,
+		mediaType, comp.AutoCodec, 0, keys, authorPub, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, pageKeys)
+	assert.Nil(t, metadata)
+}
+
+func TestPrintScan_codecs(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	pageSL := page.NewStorerLoader(
+		&fixedDocumentSLD{
+			stored: make(map[string]*api.Document),
+		},
+	)
+	page.MinSize = 64 // just for testing
+	params, err := NewParameters(comp.MinBufferSize, 256, DefaultParallelism)
+	assert.Nil(t, err)
+	mediaType, uncompressedSize := "application/x-pdf", 2048
+
+	cases := map[comp.Codec]comp.Codec{
+		comp.AutoCodec: comp.GZIPCodec, // implied by media type
+		comp.NoneCodec: comp.NoneCodec,
+		comp.GZIPCodec: comp.GZIPCodec,
+	}
+	for codec, expected := range cases {
+		p := NewPrinter(params, enc.NewAESGCMScheme(), pageSL)
+		s := NewScanner(params, pageSL)
+		content1 := common.NewCompressableBytes(rng, uncompressedSize)
+		content1Bytes := content1.Bytes()
+
+		pageKeys, metadata, err := p.Print(content1, mediaType, codec, 0, keys, authorPub)
+		assert.Nil(t, err)
+
+		// check codec and sizes are recorded accurately
+		actualCodec, _ := metadata.GetCompressionCodec()
+		assert.Equal(t, string(expected), actualCodec)
+		actualUncompressedSize, _ := metadata.GetUncompressedSize()
+		assert.Equal(t, uint64(uncompressedSize), actualUncompressedSize)
+		actualCiphertextSize, _ := metadata.GetCiphertextSize()
+		if expected == comp.NoneCodec {
+			assert.True(t, actualCiphertextSize >= uint64(uncompressedSize))
+		} else {
+			assert.True(t, actualCiphertextSize < uint64(uncompressedSize))
+		}
+
+		content2 := new(bytes.Buffer)
+		err = s.Scan(context.Background(), content2, pageKeys, nil, enc.NewAESGCMScheme(),
+			keys, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, content1Bytes, content2.Bytes())
+	}
+
+	// check params codec is used when none is given
+	params.CompressionCodec = comp.NoneCodec
+	p := NewPrinter(params, enc.NewAESGCMScheme(), pageSL)
+	_, metadata, err := p.Print(common.NewCompressableBytes(rng, uncompressedSize), mediaType,
+		comp.AutoCodec, 0, keys, authorPub)
+	assert.Nil(t, err)
+	actualCodec, _ := metadata.GetCompressionCodec()
+	assert.Equal(t, string(comp.NoneCodec), actualCodec)
+}
+
+func TestPrintScan_pageSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng)
+	pageSL := page.NewStorerLoader(
+		&fixedDocumentSLD{
+			stored: make(map[string]*api.Document),
+		},
+	)
+	page.MinSize = 64 // just for testing
+	params, err := NewParameters(comp.MinBufferSize, 256, DefaultParallelism)
+	assert.Nil(t, err)
+	p, s := NewPrinter(params, enc.NewAESGCMScheme(), pageSL), NewScanner(params, pageSL)
+	contentBytes := common.NewCompressableBytes(rng, 2048).Bytes()
+
+	// check params page size is used when none is given
+	pageKeys1, metadata1, err := p.Print(bytes.NewReader(contentBytes), "application/x-pdf",
+		comp.NoneCodec, 0, keys, authorPub)
+	assert.Nil(t, err)
+	assert.True(t, len(pageKeys1) >= len(contentBytes)/256)
+
+	// check given page size overrides params page size
+	pageKeys2, metadata2, err := p.Print(bytes.NewReader(contentBytes), "application/x-pdf",
+		comp.NoneCodec, 128, keys, authorPub)
+	assert.Nil(t, err)
+	assert.True(t, len(pageKeys2) >= len(contentBytes)/128)
+	assert.True(t, len(pageKeys2) > len(pageKeys1))
+
+	// check both scan to the same content
+	for i, pageKeys := range [][]cid.ID{pageKeys1, pageKeys2} {
+		metadata := []*api.Metadata{metadata1, metadata2}[i]
+		content := new(bytes.Buffer)
+		err = s.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+			keys, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, contentBytes, content.Bytes())
+	}
+
+	// check invalid page size bubbles up
+	pageKeys3, metadata3, err := p.Print(bytes.NewReader(contentBytes), "application/x-pdf",
+		comp.NoneCodec, page.MaxSize+1, keys, authorPub)
+	assert.Equal(t, page.ErrPageSizeTooLarge, err)
+	assert.Nil(t, pageKeys3)
+	assert.Nil(t, metadata3)
+}
+
+func TestPrintScan_swappedPages(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
+	keys := enc.NewPseudoRandomEEK(rng) // same keys for both entries to isolate page binding
+	docSLD := &fixedDocumentSLD{
+		stored: make(map[string]*api.Document),
+	}
+	pageSL := page.NewStorerLoader(docSLD)
+	page.MinSize = 64 // just for testing
+	params, err := NewParameters(comp.MinBufferSize, 128, DefaultParallelism)
+	assert.Nil(t, err)
+	scheme := enc.NewAESGCMScheme()
+	p, s := NewPrinter(params, scheme, pageSL), NewScanner(params, pageSL)
+	contentBytes := common.NewCompressableBytes(rng, 1024).Bytes()
+
+	pageKeys1, metadata1, err := p.Print(bytes.NewReader(contentBytes), "application/x-pdf",
+		comp.NoneCodec, 0, keys, authorPub)
+	assert.Nil(t, err)
+	pageKeys2, metadata2, err := p.Print(bytes.NewReader(contentBytes), "application/x-pdf",
+		comp.NoneCodec, 0, keys, authorPub)
+	assert.Nil(t, err)
+	assert.Equal(t, len(pageKeys1), len(pageKeys2))
+	assert.True(t, len(pageKeys1) > 1)
+	binding1, in := metadata1.GetPageBinding()
+	assert.True(t, in)
+	binding2, in := metadata2.GetPageBinding()
+	assert.True(t, in)
+	assert.NotEqual(t, binding1, binding2)
+
+	// check page from the other entry at the same index can't be decrypted
+	page2 := docSLD.stored[pageKeys2[1].String()].Contents.(*api.Document_Page).Page
+	decrypter1, err := scheme.NewDecrypter(keys, binding1)
+	assert.Nil(t, err)
+	plaintext, err := decrypter1.Decrypt(page2.Ciphertext, page2.Index)
+	assert.NotNil(t, err)
+	assert.Nil(t, plaintext)
+	decrypter2, err := scheme.NewDecrypter(keys, binding2)
+	assert.Nil(t, err)
+	_, err = decrypter2.Decrypt(page2.Ciphertext, page2.Index)
+	assert.Nil(t, err)
+
+	// check scanning an entry with a page swapped in from the other entry fails
+	swapped := append([]cid.ID{}, pageKeys1...)
+	swapped[1] = pageKeys2[1]
+	err = s.Scan(context.Background(), new(bytes.Buffer), swapped, nil, scheme, keys, metadata1)
+	assert.NotNil(t, err)
+
+	// check scanning an entry with reordered pages fails
+	reordered := append([]cid.ID{}, pageKeys1...)
+	reordered[0], reordered[1] = reordered[1], reordered[0]
+	err = s.Scan(context.Background(), new(bytes.Buffer), reordered, nil, scheme, keys,
+		metadata1)
+	assert.NotNil(t, err)
+
+	// check scanning the entry as is succeeds
+	content := new(bytes.Buffer)
+	err = s.Scan(context.Background(), content, pageKeys1, nil, scheme, keys, metadata1)
+	assert.Nil(t, err)
+	assert.Equal(t, contentBytes, content.Bytes())
+}
+
 func TestPrintInitializerImpl_Initialize_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	params, err := NewParameters(comp.MinBufferSize, page.MinSize, DefaultParallelism)
 	assert.Nil(t, err)
 	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
 	keys := enc.NewPseudoRandomEEK(rng)
-	content, mediaType := bytes.NewReader(api.RandBytes(rng, 64)), "application/x-pdf"
+	content, codec := bytes.NewReader(api.RandBytes(rng, 64)), comp.GZIPCodec
 	pages := make(chan *api.Page)
 
 	printInit := &printInitializerImpl{
 		params: params,
+		scheme: enc.NewAESGCMScheme(),
 	}
-	compressor, paginator, err := printInit.Initialize(content, mediaType, keys, authorPub,
-		pages)
+	compressor, paginator, err := printInit.Initialize(content, codec, page.MinSize, keys, nil,
+		authorPub, pages)
 	assert.Nil(t, err)
 	assert.NotNil(t, compressor)
 	assert.NotNil(t, paginator)
@@ -224,53 +593,43 @@ func TestPrintInitializerImpl_Initialize_err(t *testing.T) {
 	assert.Nil(t, err)
 	authorPub := api.RandBytes(rng, api.ECPubKeyLength)
 	keys := enc.NewPseudoRandomEEK(rng)
-	content, mediaType := bytes.NewReader(api.RandBytes(rng, 64)), "application/x-pdf"
+	content, codec := bytes.NewReader(api.RandBytes(rng, 64)), comp.GZIPCodec
 	pages := make(chan *api.Page)
 
-	printInit1 := &printInitializerImpl{
-		params: params,
-	}
-
-	// check that bad media type triggers error
-	compressor, paginator, err := printInit1.Initialize(content, "application/", keys,
-		authorPub, pages)
-	assert.NotNil(t, err)
-	assert.Nil(t, compressor)
-	assert.Nil(t, paginator)
-
 	printInit2 := &printInitializerImpl{
 		params: &Parameters{
 			CompressionBufferSize: 0, // will trigger error when creating compressor
 			PageSize:              page.MinSize,
 			Parallelism:           DefaultParallelism,
 		},
+		scheme: enc.NewAESGCMScheme(),
 	}
 
 	// check that error creating new compressor bubbles up
-	compressor, paginator, err = printInit2.Initialize(content, mediaType, keys, authorPub,
-		pages)
+	compressor, paginator, err := printInit2.Initialize(content, codec, page.MinSize, keys, nil,
+		authorPub, pages)
 	assert.NotNil(t, err)
 	assert.Nil(t, compressor)
 	assert.Nil(t, paginator)
 
 	keys3 := enc.NewPseudoRandomEEK(rng)
 	keys3.AESKey = []byte{} // will trigger error when creating encrypter
-	printInit3 := &printInitializerImpl{params}
+	printInit3 := &printInitializerImpl{params, enc.NewAESGCMScheme()}
 
 	// check that error creating new encrypter triggers error
-	compressor, paginator, err = printInit3.Initialize(content, mediaType, keys3, authorPub,
-		pages)
+	compressor, paginator, err = printInit3.Initialize(content, codec, page.MinSize, keys3, nil,
+		authorPub, pages)
 	assert.NotNil(t, err)
 	assert.Nil(t, compressor)
 	assert.Nil(t, paginator)
 
 	keys4 := enc.NewPseudoRandomEEK(rng)
 	keys4.HMACKey = []byte{} // will trigger error when creating paginator
-	printInit4 := &printInitializerImpl{params}
+	printInit4 := &printInitializerImpl{params, enc.NewAESGCMScheme()}
 
 	// check that error creating new encrypter triggers error
-	compressor, paginator, err = printInit4.Initialize(content, mediaType, keys4, authorPub,
-		pages)
+	compressor, paginator, err = printInit4.Initialize(content, codec, page.MinSize, keys4, nil,
+		authorPub, pages)
 	assert.NotNil(t, err)
 	assert.Nil(t, compressor)
 	assert.Nil(t, paginator)
@@ -278,6 +637,8 @@ func TestPrintInitializerImpl_Initialize_err(t *testing.T) {
 
 type fixedStorer struct {
 	storeErr error
+	nBatches int
+	deleted  []cid.ID
 }
 
 func (f *fixedStorer) Store(pages chan *api.Page) ([]cid.ID, error) {
@@ -296,6 +657,21 @@ func (f *fixedStorer) Store(pages chan *api.Page) ([]cid.ID, error) {
 	return pageKeys, nil
 }
 
+func (f *fixedStorer) StoreBatch(pages ...*api.Page) ([]cid.ID, error) {
+	f.nBatches++
+	pagesC := make(chan *api.Page, len(pages))
+	for _, page := range pages {
+		pagesC <- page
+	}
+	close(pagesC)
+	return f.Store(pagesC)
+}
+
+func (f *fixedStorer) Delete(keys ...cid.ID) error {
+	f.deleted = append(f.deleted, keys...)
+	return nil
+}
+
 type fixedPaginator struct {
 	readN         int64
 	readErr       error
@@ -336,7 +712,13 @@ type fixedPrintInitializer struct {
 }
 
 func (f *fixedPrintInitializer) Initialize(
-	content io.Reader, mediaType string, keys *enc.EEK, authorPub []byte, pages chan *api.Page,
+	content io.Reader,
+	codec comp.Codec,
+	pageSize uint32,
+	keys *enc.EEK,
+	pageBinding []byte,
+	authorPub []byte,
+	pages chan *api.Page,
 ) (comp.Compressor, page.Paginator, error) {
 
 	f.initPaginator.pages = pages
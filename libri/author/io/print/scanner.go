@@ -9,13 +9,17 @@ import (
 	"github.com/drausin/libri/libri/author/io/page"
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
+	"golang.org/x/net/context"
 )
 
 // Scanner writes locally-stored pages to a unified content stream.
 type Scanner interface {
-	// Scan loads pages with the given keys and metadata from an internal page.Loader and
-	// writes their concatenated output to the content io.Writer.
-	Scan(content io.Writer, pageKeys []id.ID, keys *enc.EEK, metatdata *api.Metadata) error
+	// Scan loads pages with the given keys and metadata from an internal page.Loader,
+	// decrypts them with the scheme they were encrypted with, and writes their concatenated
+	// output to the content io.Writer. Pages the page.Loader acquires from libri are acquired
+	// within ctx and checked against the author public key.
+	Scan(ctx context.Context, content io.Writer, pageKeys []id.ID, authorPub []byte,
+		scheme enc.Scheme, keys *enc.EEK, metatdata *api.Metadata) error
 }
 
 type scanner struct {
@@ -38,15 +42,26 @@ func NewScanner(params *Parameters, pageL page.Loader) Scanner {
 }
 
 func (s *scanner) Scan(
-	content io.Writer, pageKeys []id.ID, keys *enc.EEK, md *api.Metadata,
+	ctx context.Context,
+	content io.Writer,
+	pageKeys []id.ID,
+	authorPub []byte,
+	scheme enc.Scheme,
+	keys *enc.EEK,
+	md *api.Metadata,
 ) error {
 
 	pages := make(chan *api.Page, int(s.params.Parallelism))
 	if err := api.ValidateMetadata(md); err != nil {
 		return err
 	}
-	mediaType, _ := md.GetMediaType()
-	decompressor, unpaginator, err := s.init.Initialize(content, mediaType, keys, pages)
+	codec, err := getCompressionCodec(md)
+	if err != nil {
+		return err
+	}
+	pageBinding, _ := md.GetPageBinding()
+	decompressor, unpaginator, err := s.init.Initialize(content, codec, scheme, keys,
+		pageBinding, pages)
 	if err != nil {
 		return err
 	}
@@ -63,7 +78,7 @@ func (s *scanner) Scan(
 		wg.Done()
 	}()
 
-	err = s.pageL.Load(pageKeys, pages, abortLoad)
+	err = s.pageL.Load(ctx, pageKeys, authorPub, pages, abortLoad)
 	close(pages)
 	if err != nil {
 		return err
@@ -83,9 +98,17 @@ func (s *scanner) Scan(
 	return nil
 }
 
+// getCompressionCodec returns the codec recorded in the metadata or, for entries without one, the
+// codec implied by the media type.
+func getCompressionCodec(md *api.Metadata) (comp.Codec, error) {
+	mediaType, _ := md.GetMediaType()
+	codec, _ := md.GetCompressionCodec()
+	return comp.ResolveCodec(comp.Codec(codec), mediaType)
+}
+
 type scanInitializer interface {
-	Initialize(content io.Writer, mediaType string, keys *enc.EEK, pages chan *api.Page) (
-		comp.Decompressor, page.Unpaginator, error)
+	Initialize(content io.Writer, codec comp.Codec, scheme enc.Scheme, keys *enc.EEK,
+		pageBinding []byte, pages chan *api.Page) (comp.Decompressor, page.Unpaginator, error)
 }
 
 type scanInitializerImpl struct {
@@ -93,19 +116,20 @@ type scanInitializerImpl struct {
 }
 
 func (si *scanInitializerImpl) Initialize(
-	content io.Writer, mediaType string, keys *enc.EEK, pages chan *api.Page,
+	content io.Writer,
+	codec comp.Codec,
+	scheme enc.Scheme,
+	keys *enc.EEK,
+	pageBinding []byte,
+	pages chan *api.Page,
 ) (comp.Decompressor, page.Unpaginator, error) {
 
-	codec, err := comp.GetCompressionCodec(mediaType)
-	if err != nil {
-		return nil, nil, err
-	}
 	decompressor, err := comp.NewDecompressor(content, codec, keys,
 		si.params.CompressionBufferSize)
 	if err != nil {
 		return nil, nil, err
 	}
-	decrypter, err := enc.NewDecrypter(keys)
+	decrypter, err := scheme.NewDecrypter(keys, pageBinding)
 	if err != nil {
 		return nil, nil, err
 	}
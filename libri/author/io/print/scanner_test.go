@@ -13,6 +13,7 @@ import (
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestScanner_Scan_ok(t *testing.T) {
@@ -59,7 +60,8 @@ func TestScanner_Scan_ok(t *testing.T) {
 	)
 	assert.Nil(t, err)
 
-	err = scanner1.Scan(nil, pageKeys, keys, entryMetadata)
+	err = scanner1.Scan(context.Background(), nil, pageKeys, nil, enc.NewAESGCMScheme(), keys,
+		entryMetadata)
 	assert.Nil(t, err)
 	assert.Equal(t, pageKeys, pageKeys)
 	actualCiphertextSize, _ := entryMetadata.GetCiphertextSize()
@@ -88,9 +90,20 @@ func TestScanner_Scan_err(t *testing.T) {
 	// check that invalid metadata triggers error
 	scanner1 := NewScanner(params, &fixedLoader{})
 	md1 := &api.Metadata{} // empty, so missing all fields
-	err = scanner1.Scan(content, pageKeys, keys, md1)
+	err = scanner1.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+		keys, md1)
 	assert.NotNil(t, err)
 
+	// check that bad compression codec triggers error
+	md1b := &api.Metadata{Properties: make(map[string][]byte)}
+	for k, v := range entryMetadata.Properties {
+		md1b.Properties[k] = v
+	}
+	md1b.SetString(api.MetadataEntryCompressionCodec, "some unknown codec")
+	err = scanner1.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+		keys, md1b)
+	assert.Equal(t, comp.ErrUnexpectedCodec, err)
+
 	// check that init error bubbles up
 	scanner2 := NewScanner(params, &fixedLoader{})
 	scanner2.(*scanner).init = &fixedScanInitializer{
@@ -98,7 +111,8 @@ func TestScanner_Scan_err(t *testing.T) {
 		initUnpaginator:  &fixedUnpaginator{},
 		initErr:          errors.New("some Initialize error"),
 	}
-	err = scanner2.Scan(content, pageKeys, keys, entryMetadata)
+	err = scanner2.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+		keys, entryMetadata)
 	assert.NotNil(t, err)
 
 	// check that load error bubbles up
@@ -111,7 +125,8 @@ func TestScanner_Scan_err(t *testing.T) {
 		initUnpaginator:  &fixedUnpaginator{},
 		initErr:          nil,
 	}
-	err = scanner3.Scan(content, pageKeys, keys, entryMetadata)
+	err = scanner3.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+		keys, entryMetadata)
 	assert.NotNil(t, err)
 
 	// check that unpaginator.WriteTo error bubbles up
@@ -125,7 +140,8 @@ func TestScanner_Scan_err(t *testing.T) {
 		initUnpaginator:  unpaginator4,
 		initErr:          nil,
 	}
-	err = scanner4.Scan(content, pageKeys, keys, entryMetadata)
+	err = scanner4.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+		keys, entryMetadata)
 	assert.NotNil(t, err)
 
 	// check that MAC check error bubbles up
@@ -147,7 +163,8 @@ func TestScanner_Scan_err(t *testing.T) {
 		initUnpaginator:  unpaginator,
 		initErr:          nil,
 	}
-	err = scanner5.Scan(content, pageKeys, keys, entryMetadata)
+	err = scanner5.Scan(context.Background(), content, pageKeys, nil, enc.NewAESGCMScheme(),
+		keys, entryMetadata)
 	assert.NotNil(t, err)
 }
 
@@ -155,12 +172,13 @@ func TestScanInitializerImpl_Initialize_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	params, err := NewParameters(comp.MinBufferSize, page.MinSize, DefaultParallelism)
 	assert.Nil(t, err)
-	keys := enc.NewPseudoRandomEEK(rng)
-	content, mediaType := new(bytes.Buffer), "application/x-pdf"
+	scheme, keys := enc.NewAESGCMScheme(), enc.NewPseudoRandomEEK(rng)
+	content, codec := new(bytes.Buffer), comp.GZIPCodec
 	pages := make(chan *api.Page)
 
 	scanInit := &scanInitializerImpl{params: params}
-	decompressor, unpaginator, err := scanInit.Initialize(content, mediaType, keys, pages)
+	decompressor, unpaginator, err := scanInit.Initialize(content, codec, scheme, keys, nil,
+		pages)
 	assert.Nil(t, err)
 	assert.NotNil(t, decompressor)
 	assert.NotNil(t, unpaginator)
@@ -170,20 +188,10 @@ func TestScanInitializerImpl_Initialize_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	params, err := NewParameters(comp.MinBufferSize, page.MinSize, DefaultParallelism)
 	assert.Nil(t, err)
-	keys := enc.NewPseudoRandomEEK(rng)
-	content, mediaType := new(bytes.Buffer), "application/x-pdf"
+	scheme, keys := enc.NewAESGCMScheme(), enc.NewPseudoRandomEEK(rng)
+	content, codec := new(bytes.Buffer), comp.GZIPCodec
 	pages := make(chan *api.Page)
 
-	scanInit1 := &scanInitializerImpl{
-		params: params,
-	}
-
-	// check that bad media type triggers error
-	decompressor, unpaginator, err := scanInit1.Initialize(content, "application/", keys, pages)
-	assert.NotNil(t, err)
-	assert.Nil(t, decompressor)
-	assert.Nil(t, unpaginator)
-
 	scanInit2 := &scanInitializerImpl{
 		params: &Parameters{
 			CompressionBufferSize: 0, // will trigger error when creating decompressor
@@ -193,7 +201,8 @@ func TestScanInitializerImpl_Initialize_err(t *testing.T) {
 	}
 
 	// check that error creating new decompressor bubbles up
-	decompressor, unpaginator, err = scanInit2.Initialize(content, mediaType, keys, pages)
+	decompressor, unpaginator, err := scanInit2.Initialize(content, codec, scheme, keys, nil,
+		pages)
 	assert.NotNil(t, err)
 	assert.Nil(t, decompressor)
 	assert.Nil(t, unpaginator)
@@ -205,7 +214,8 @@ func TestScanInitializerImpl_Initialize_err(t *testing.T) {
 	}
 
 	// check that error creating new decrypter triggers error
-	decompressor, unpaginator, err = scanInit3.Initialize(content, mediaType, keys3, pages)
+	decompressor, unpaginator, err = scanInit3.Initialize(content, codec, scheme, keys3, nil,
+		pages)
 	assert.NotNil(t, err)
 	assert.Nil(t, decompressor)
 	assert.Nil(t, unpaginator)
@@ -217,7 +227,8 @@ func TestScanInitializerImpl_Initialize_err(t *testing.T) {
 	}
 
 	// check that error creating new decrypter triggers error
-	decompressor, unpaginator, err = scanInit4.Initialize(content, mediaType, keys4, pages)
+	decompressor, unpaginator, err = scanInit4.Initialize(content, codec, scheme, keys4, nil,
+		pages)
 	assert.NotNil(t, err)
 	assert.Nil(t, decompressor)
 	assert.Nil(t, unpaginator)
@@ -228,7 +239,10 @@ type fixedLoader struct {
 	pages   map[string]*api.Page
 }
 
-func (f *fixedLoader) Load(keys []id.ID, pages chan *api.Page, abort chan struct{}) error {
+func (f *fixedLoader) Load(
+	ctx context.Context, keys []id.ID, authorPub []byte, pages chan *api.Page,
+	abort chan struct{},
+) error {
 	if f.loadErr != nil {
 		return f.loadErr
 	}
@@ -290,7 +304,12 @@ type fixedScanInitializer struct {
 }
 
 func (f *fixedScanInitializer) Initialize(
-	content io.Writer, mediaType string, keys *enc.EEK, pages chan *api.Page,
+	content io.Writer,
+	codec comp.Codec,
+	scheme enc.Scheme,
+	keys *enc.EEK,
+	pageBinding []byte,
+	pages chan *api.Page,
 ) (comp.Decompressor, page.Unpaginator, error) {
 
 	f.initUnpaginator.pages = pages
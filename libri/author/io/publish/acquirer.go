@@ -9,12 +9,31 @@ import (
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
+	"golang.org/x/net/context"
 )
 
+// Search defines how librarians search the libri network for each acquired document. A nil
+// *Search uses the librarians' defaults.
+type Search struct {
+	// Concurrency, if non-zero, is the number of peers librarians query in parallel when
+	// searching for a document, at most api.MaxSearchConcurrency. Higher concurrency can cut tail
+	// latency on high-latency networks but puts more load on the network.
+	Concurrency uint32
+}
+
+func (s *Search) concurrency() uint32 {
+	if s == nil {
+		return 0
+	}
+	return s.Concurrency
+}
+
 // Acquirer Gets documents from the libri network.
 type Acquirer interface {
-	// Acquire Gets a document from the libri network using a librarian client.
-	Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (*api.Document, error)
+	// Acquire Gets a document from the libri network using a librarian client. How the
+	// librarian searches for the document is given by search.
+	Acquire(docKey id.ID, authorPub []byte, lc api.Getter, search *Search) (*api.Document,
+		error)
 }
 
 type acquirer struct {
@@ -32,8 +51,10 @@ func NewAcquirer(clientID ecid.ID, signer client.Signer, params *Parameters) Acq
 	}
 }
 
-func (a *acquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (*api.Document, error) {
+func (a *acquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter, search *Search) (
+	*api.Document, error) {
 	rq := client.NewGetRequest(a.clientID, docKey)
+	rq.SearchConcurrency = search.concurrency()
 	ctx, cancel, err := client.NewSignedTimeoutContext(a.signer, rq, a.params.GetTimeout)
 	if err != nil {
 		return nil, err
@@ -41,11 +62,14 @@ func (a *acquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (*api.
 	rp, err := lc.Get(ctx, rq)
 	cancel()
 	if err != nil {
-		return nil, err
+		return nil, api.FromRPCError(err)
 	}
 	if !bytes.Equal(rq.Metadata.RequestId, rp.Metadata.RequestId) {
 		return nil, client.ErrUnexpectedRequestID
 	}
+	if rp.Value == nil {
+		return nil, &api.DocumentNotFoundError{Key: docKey}
+	}
 	if err := api.ValidateDocument(rp.Value); err != nil {
 		return nil, err
 	}
@@ -55,8 +79,10 @@ func (a *acquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (*api.
 // SingleStoreAcquirer Gets a document and saves it to internal storage.
 type SingleStoreAcquirer interface {
 	// Acquire Gets the document with the given key from the libri network and saves it to
-	// internal storage.
-	Acquire(docKey id.ID, authorPub []byte, lc api.Getter) error
+	// internal storage. It returns the acquired document. How the librarian searches for the
+	// document is given by search.
+	Acquire(docKey id.ID, authorPub []byte, lc api.Getter, search *Search) (*api.Document,
+		error)
 }
 
 type singleStoreAcquirer struct {
@@ -73,22 +99,34 @@ func NewSingleStoreAcquirer(inner Acquirer, docS storage.DocumentStorer) SingleS
 	}
 }
 
-func (a *singleStoreAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) error {
-	doc, err := a.inner.Acquire(docKey, authorPub, lc)
+func (a *singleStoreAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *Search,
+) (*api.Document, error) {
+	doc, err := a.inner.Acquire(docKey, authorPub, lc, search)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err := a.docS.Store(docKey, doc); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return doc, nil
 }
 
 // MultiStoreAcquirer Gets and stores multiple documents.
 type MultiStoreAcquirer interface {
 	// Acquire in parallel Gets and stores the documents with the given keys. It balances
-	// between librarian clients for its Put requests.
-	Acquire(docKeys []id.ID, authorPub []byte, cb api.ClientBalancer) error
+	// between librarian clients for its Put requests. How the librarians search for each
+	// document is given by search. If progress is not nil, it is called after each document is
+	// acquired. No new documents are requested once ctx is done, in which case ctx.Err() is
+	// returned.
+	Acquire(
+		ctx context.Context,
+		docKeys []id.ID,
+		authorPub []byte,
+		cb api.ClientBalancer,
+		search *Search,
+		progress Progress,
+	) error
 }
 
 type multiStoreAcquirer struct {
@@ -106,9 +144,15 @@ func NewMultiStoreAcquirer(inner SingleStoreAcquirer, params *Parameters) MultiS
 }
 
 func (a *multiStoreAcquirer) Acquire(
-	docKeys []id.ID, authorPub []byte, cb api.ClientBalancer,
+	ctx context.Context,
+	docKeys []id.ID,
+	authorPub []byte,
+	cb api.ClientBalancer,
+	search *Search,
+	progress Progress,
 ) error {
 
+	tracker := newProgressTracker(progress, len(docKeys))
 	docKeysChan := make(chan id.ID, a.params.PutParallelism)
 	go loadChan(docKeys, docKeysChan)
 	wg := new(sync.WaitGroup)
@@ -116,18 +160,24 @@ func (a *multiStoreAcquirer) Acquire(
 	for c := uint32(0); c < a.params.GetParallelism; c++ {
 		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			for docKey := range docKeysChan {
+				if err := ctx.Err(); err != nil {
+					getErrs <- err
+					break
+				}
 				lc, err := cb.Next()
 				if err != nil {
 					getErrs <- err
 					return
 				}
-				if err := a.inner.Acquire(docKey, authorPub, lc); err != nil {
+				doc, err := a.inner.Acquire(docKey, authorPub, lc, search)
+				if err != nil {
 					getErrs <- err
 					break
 				}
+				tracker.done(doc)
 			}
-			wg.Done()
 		}()
 	}
 	wg.Wait()
@@ -27,15 +27,19 @@ func TestAcquirer_Acquire_ok(t *testing.T) {
 	}
 	acq := NewAcquirer(clientID, signer, params)
 
-	actualDoc, err := acq.Acquire(docKey, authorPub, lc)
+	actualDoc, err := acq.Acquire(docKey, authorPub, lc, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, actualDoc, expectedDoc)
 	assert.Equal(t, docKey.Bytes(), lc.request.Key)
 
-	actualDoc, err = acq.Acquire(docKey, authorPub, lc)
+	assert.Zero(t, lc.request.SearchConcurrency)
+
+	// check search concurrency is passed along in request
+	actualDoc, err = acq.Acquire(docKey, authorPub, lc, &Search{Concurrency: 8})
 	assert.Nil(t, err)
 	assert.Equal(t, actualDoc, expectedDoc)
 	assert.Equal(t, docKey.Bytes(), lc.request.Key)
+	assert.Equal(t, uint32(8), lc.request.SearchConcurrency)
 }
 
 func TestAcquirer_Acquire_err(t *testing.T) {
@@ -53,7 +57,7 @@ func TestAcquirer_Acquire_err(t *testing.T) {
 		err:       errors.New("some Sign error"),
 	}
 	acq1 := NewAcquirer(clientID, signer1, params)
-	actualDoc, err := acq1.Acquire(docKey, authorPub, lc)
+	actualDoc, err := acq1.Acquire(docKey, authorPub, lc, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, actualDoc)
 
@@ -61,16 +65,29 @@ func TestAcquirer_Acquire_err(t *testing.T) {
 		err: errors.New("some Get error"),
 	}
 	acq2 := NewAcquirer(clientID, signer, params)
-	actualDoc, err = acq2.Acquire(docKey, authorPub, lc2)
+	actualDoc, err = acq2.Acquire(docKey, authorPub, lc2, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, actualDoc)
 
 	// check that different request ID causes error
 	lc3 := &diffRequestIDGetter{rng}
 	acq3 := NewAcquirer(clientID, signer, params)
-	actualDoc, err = acq3.Acquire(docKey, authorPub, lc3)
+	actualDoc, err = acq3.Acquire(docKey, authorPub, lc3, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, actualDoc)
+
+	// check that missing document gives not found error
+	acq4 := NewAcquirer(clientID, signer, params)
+	actualDoc, err = acq4.Acquire(docKey, authorPub, &fixedGetter{}, nil)
+	assert.Equal(t, &api.DocumentNotFoundError{Key: docKey}, err)
+	assert.Equal(t, api.NotFoundErrorCode, api.ErrorCodeOf(err))
+	assert.Nil(t, actualDoc)
+
+	// check that expired document RPC error maps back to ErrExpired
+	acq5 := NewAcquirer(clientID, signer, params)
+	actualDoc, err = acq5.Acquire(docKey, authorPub, &fixedGetter{err: api.ErrExpiredRPC}, nil)
+	assert.Equal(t, api.ErrExpired, err)
+	assert.Nil(t, actualDoc)
 }
 
 func TestSingleStoreAcquirer_Acquire_ok(t *testing.T) {
@@ -82,8 +99,9 @@ func TestSingleStoreAcquirer_Acquire_ok(t *testing.T) {
 		&fixedAcquirer{doc: doc},
 		storer,
 	)
-	err := acq.Acquire(docKey, authorPub, &fixedGetter{})
+	acquiredDoc, err := acq.Acquire(docKey, authorPub, &fixedGetter{}, nil)
 	assert.Nil(t, err)
+	assert.Equal(t, doc, acquiredDoc)
 	assert.Equal(t, docKey, storer.storedKey)
 	assert.Equal(t, doc, storer.storedValue)
 }
@@ -99,7 +117,7 @@ func TestSingleStoreAcquirer_Acquire_err(t *testing.T) {
 		&fixedAcquirer{err: errors.New("some Acquire error")},
 		&fixedStorer{},
 	)
-	err := acq1.Acquire(docKey, authorPub, lc)
+	_, err := acq1.Acquire(docKey, authorPub, lc, nil)
 	assert.NotNil(t, err)
 
 	// check store error bubbles up
@@ -107,7 +125,7 @@ func TestSingleStoreAcquirer_Acquire_err(t *testing.T) {
 		&fixedAcquirer{},
 		&fixedStorer{err: errors.New("some Store error")},
 	)
-	err = acq2.Acquire(docKey, authorPub, lc)
+	_, err = acq2.Acquire(docKey, authorPub, lc, nil)
 	assert.NotNil(t, err)
 }
 
@@ -129,7 +147,7 @@ func TestMultiStoreAcquirer_Acquire_ok(t *testing.T) {
 			assert.Nil(t, err)
 			msAcq := NewMultiStoreAcquirer(slAcq, params)
 
-			err = msAcq.Acquire(docKeys, authorKey, cb)
+			err = msAcq.Acquire(context.Background(), docKeys, authorKey, cb, nil, nil)
 			assert.Nil(t, err)
 
 			// check all keys have been "acquired"
@@ -159,12 +177,52 @@ func TestMultiStoreAcquirer_Acquire_err(t *testing.T) {
 			assert.Nil(t, err)
 			mlAcq := NewMultiStoreAcquirer(slAcq, params)
 
-			err = mlAcq.Acquire(docKeys, authorKey, cb)
+			err = mlAcq.Acquire(context.Background(), docKeys, authorKey, cb, nil, nil)
+			assert.NotNil(t, err)
+
+			// check balancer error returns rather than hanging
+			slAcq = &fixedSingleStoreAcquirer{}
+			mlAcq = NewMultiStoreAcquirer(slAcq, params)
+			errCB := &fixedClientBalancer{err: errors.New("some Next error")}
+			err = mlAcq.Acquire(context.Background(), docKeys, authorKey, errCB, nil, nil)
 			assert.NotNil(t, err)
 		}
 	}
 }
 
+func TestMultiStoreAcquirer_Acquire_canceled(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	cb := &fixedClientBalancer{}
+	nDocs := 16
+	docKeys := make([]id.ID, nDocs)
+	for i := 0; i < nDocs; i++ {
+		docKeys[i] = id.NewPseudoRandom(rng)
+	}
+	authorKey := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	slAcq := &fixedSingleStoreAcquirer{
+		acquiredKeys: make(map[string]struct{}),
+	}
+	params, err := NewParameters(DefaultPutTimeout, DefaultGetTimeout,
+		DefaultPutParallelism, 1)
+	assert.Nil(t, err)
+	msAcq := NewMultiStoreAcquirer(slAcq, params)
+
+	// cancel after the first doc is acquired
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := func(nDone, nTotal int, bytesDone uint64) {
+		cancel()
+	}
+	err = msAcq.Acquire(ctx, docKeys, authorKey, cb, nil, progress)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, len(slAcq.acquiredKeys))
+
+	// check already-done context acquires nothing
+	slAcq.acquiredKeys = make(map[string]struct{})
+	err = msAcq.Acquire(ctx, docKeys, authorKey, cb, nil, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(slAcq.acquiredKeys))
+}
+
 type fixedGetter struct {
 	request       *api.GetRequest
 	responseValue *api.Document
@@ -203,8 +261,9 @@ type fixedAcquirer struct {
 	err error
 }
 
-func (f *fixedAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (
-	*api.Document, error) {
+func (f *fixedAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *Search,
+) (*api.Document, error) {
 	return f.doc, f.err
 }
 
@@ -225,11 +284,13 @@ type fixedSingleStoreAcquirer struct {
 	acquiredKeys map[string]struct{}
 }
 
-func (f *fixedSingleStoreAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) error {
+func (f *fixedSingleStoreAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *Search,
+) (*api.Document, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.err == nil {
 		f.acquiredKeys[docKey.String()] = struct{}{}
 	}
-	return f.err
+	return nil, f.err
 }
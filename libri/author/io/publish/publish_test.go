@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 func TestNewParameters_ok(t *testing.T) {
@@ -60,10 +61,89 @@ func TestPublisher_Publish_ok(t *testing.T) {
 	pub := NewPublisher(clientID, signer, params)
 
 	doc, expectedDocKey := api.NewTestDocument(rng)
-	actualDocKey, err := pub.Publish(doc, api.GetAuthorPub(doc), lc)
+	actualDocKey, result, err := pub.Publish(doc, api.GetAuthorPub(doc), lc, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, expectedDocKey, actualDocKey)
 	assert.Equal(t, doc, lc.request.Value)
+	assert.Zero(t, lc.request.NReplicas)
+	assert.Zero(t, result.NReplicas)
+	assert.Empty(t, result.ReplicaPeerIDs)
+
+	// check non-zero number of replicas is passed along in request
+	replicaPeerIDs := []id.ID{id.NewPseudoRandom(rng), id.NewPseudoRandom(rng)}
+	lc.nReplicas = 6
+	lc.replicaPeerIDs = [][]byte{replicaPeerIDs[0].Bytes(), replicaPeerIDs[1].Bytes()}
+	repl := &Replication{NReplicas: 6, MinNReplicas: 4}
+	actualDocKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc, repl)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedDocKey, actualDocKey)
+	assert.Equal(t, uint32(6), lc.request.NReplicas)
+	assert.Equal(t, uint32(4), lc.request.MinNReplicas)
+
+	// check stored replicas are returned in result
+	assert.Equal(t, uint32(6), result.NReplicas)
+	assert.Equal(t, replicaPeerIDs, result.ReplicaPeerIDs)
+}
+
+func TestPublisher_Publish_stream(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	clientID := ecid.NewPseudoRandom(rng)
+	signer := client.NewSigner(clientID.Key())
+	params := NewDefaultParameters()
+	doc, expectedDocKey := api.NewTestDocument(rng)
+	docBytes, err := proto.Marshal(doc)
+	assert.Nil(t, err)
+	repl := &Replication{NReplicas: 6, MinNReplicas: 4}
+
+	// check document no larger than threshold is Put in a single request
+	params.PutStreamThreshold = uint64(len(docBytes))
+	lc := &fixedPutStreamer{stream: &fixedPutStreamClient{nReplicas: 6}}
+	pub := NewPublisher(clientID, signer, params)
+	actualDocKey, result, err := pub.Publish(doc, api.GetAuthorPub(doc), lc, repl)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedDocKey, actualDocKey)
+	assert.Equal(t, doc, lc.request.Value)
+	assert.Nil(t, lc.stream.chunks)
+
+	// check larger document is streamed in chunks
+	params.PutStreamThreshold = uint64(len(docBytes)) - 1
+	lc = &fixedPutStreamer{stream: &fixedPutStreamClient{nReplicas: 6}}
+	actualDocKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc, repl)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedDocKey, actualDocKey)
+	assert.Equal(t, uint32(6), result.NReplicas)
+	assert.Nil(t, lc.request)
+	assert.True(t, len(lc.stream.chunks) > 1)
+	streamed := lc.stream.chunks[0].Request
+	assert.Equal(t, expectedDocKey.Bytes(), streamed.Key)
+	assert.Nil(t, streamed.Value)
+	assert.Equal(t, uint32(6), streamed.NReplicas)
+	assert.Equal(t, uint32(4), streamed.MinNReplicas)
+	streamedBytes := make([]byte, 0, len(docBytes))
+	for _, chunk := range lc.stream.chunks {
+		streamedBytes = append(streamedBytes, chunk.ValueChunk...)
+	}
+	assert.Equal(t, docBytes, streamedBytes)
+
+	// check larger document is Put in a single request when client can't stream
+	lc2 := &fixedPutter{nReplicas: 6}
+	actualDocKey, _, err = pub.Publish(doc, api.GetAuthorPub(doc), lc2, repl)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedDocKey, actualDocKey)
+	assert.Equal(t, doc, lc2.request.Value)
+
+	// check stream errors bubble up
+	lcs := []*fixedPutStreamer{
+		{err: errors.New("some PutStream error")},
+		{stream: &fixedPutStreamClient{sendErr: errors.New("some Send error")}},
+		{stream: &fixedPutStreamClient{recvErr: errors.New("some CloseAndRecv error")}},
+	}
+	for _, lc := range lcs {
+		docKey, result, err := pub.Publish(doc, api.GetAuthorPub(doc), lc, repl)
+		assert.NotNil(t, err)
+		assert.Nil(t, docKey)
+		assert.Nil(t, result)
+	}
 }
 
 func TestPublisher_Publish_err(t *testing.T) {
@@ -78,14 +158,16 @@ func TestPublisher_Publish_err(t *testing.T) {
 
 	// check that error from bad document bubbles up
 	diffAuthorPub := ecid.NewPseudoRandom(rng).PublicKeyBytes()
-	docKey, err := pub.Publish(nil, diffAuthorPub, lc)
+	docKey, result, err := pub.Publish(nil, diffAuthorPub, lc, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, docKey)
+	assert.Nil(t, result)
 
 	// check that different author pub key creates error
-	docKey, err = pub.Publish(doc, diffAuthorPub, lc)
+	docKey, result, err = pub.Publish(doc, diffAuthorPub, lc, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, docKey)
+	assert.Nil(t, result)
 
 	signer2 := &fixedSigner{ // causes client.NewSignedTimeoutContext to error
 		signature: "",
@@ -94,9 +176,10 @@ func TestPublisher_Publish_err(t *testing.T) {
 	pub = NewPublisher(clientID, signer2, params)
 
 	// check that error from client.NewSignedTimeoutContext error bubbles up
-	docKey, err = pub.Publish(doc, api.GetAuthorPub(doc), lc)
+	docKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, docKey)
+	assert.Nil(t, result)
 
 	lc3 := &fixedPutter{
 		err: errors.New("some Put error"),
@@ -104,17 +187,46 @@ func TestPublisher_Publish_err(t *testing.T) {
 	pub = NewPublisher(clientID, signer, params)
 
 	// check that Put error bubbles up
-	docKey, err = pub.Publish(doc, api.GetAuthorPub(doc), lc3)
+	docKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc3, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, docKey)
+	assert.Nil(t, result)
 
 	lc4 := &diffRequestIDPutter{rng}
 	pub = NewPublisher(clientID, signer, params)
 
 	// check that different request ID causes error
-	docKey, err = pub.Publish(doc, api.GetAuthorPub(doc), lc4)
+	docKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc4, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, docKey)
+	assert.Nil(t, result)
+
+	lc5 := &fixedPutter{nReplicas: 3}
+
+	// check that fewer stored replicas than the minimum causes error
+	repl := &Replication{NReplicas: 6, MinNReplicas: 4}
+	docKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc5, repl)
+	assert.Equal(t, &api.InsufficientReplicasError{NReplicas: 3, MinNReplicas: 4}, err)
+	assert.Equal(t, api.InsufficientReplicasErrorCode, api.ErrorCodeOf(err))
+	assert.Nil(t, docKey)
+	assert.Nil(t, result)
+
+	lc6 := &fixedPutter{nReplicas: 2, timedOut: true}
+
+	// check that a timed out store causes error
+	docKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc6, nil)
+	assert.Equal(t, ErrPutTimedOut, err)
+	assert.Nil(t, docKey)
+	assert.Nil(t, result)
+
+	lc7 := &fixedPutter{err: api.ErrExpiredRPC}
+
+	// check that expired document RPC error maps back to ErrExpired
+	docKey, result, err = pub.Publish(doc, api.GetAuthorPub(doc), lc7, nil)
+	assert.Equal(t, api.ErrExpired, err)
+	assert.False(t, isRetryable(err))
+	assert.Nil(t, docKey)
+	assert.Nil(t, result)
 }
 
 func TestSingleLoadPublisher_Publish_ok(t *testing.T) {
@@ -131,15 +243,17 @@ func TestSingleLoadPublisher_Publish_ok(t *testing.T) {
 	docLD.docs[docKey.String()] = doc1
 
 	// check publish without delete leaves doc
-	err := slPub.Publish(docKey, api.GetAuthorPub(doc1), lc, false)
+	publishedDoc, err := slPub.Publish(docKey, api.GetAuthorPub(doc1), lc, nil, false)
 	assert.Nil(t, err)
+	assert.Equal(t, doc1, publishedDoc)
 	doc2, err := docLD.Load(docKey)
 	assert.Nil(t, err)
 	assert.Equal(t, doc1, doc2)
 
 	// check publish with delete removes doc
-	err = slPub.Publish(docKey, api.GetAuthorPub(doc1), lc, true)
+	publishedDoc, err = slPub.Publish(docKey, api.GetAuthorPub(doc1), lc, nil, true)
 	assert.Nil(t, err)
+	assert.Equal(t, doc1, publishedDoc)
 	doc3, err := docLD.Load(docKey)
 	assert.Nil(t, err)
 	assert.Nil(t, doc3)
@@ -156,12 +270,12 @@ func TestSingleLoadPublisher_Publish_err(t *testing.T) {
 
 	// check docL.Load error bubbles up
 	slPub := NewSingleLoadPublisher(pub, &fixedDocSLD{loadError: errors.New("some Load error")})
-	err := slPub.Publish(docKey, api.GetAuthorPub(doc), lc, false)
+	_, err := slPub.Publish(docKey, api.GetAuthorPub(doc), lc, nil, false)
 	assert.NotNil(t, err)
 
 	// check missing doc triggers error
 	slPub = NewSingleLoadPublisher(pub, docL)
-	err = slPub.Publish(docKey, api.GetAuthorPub(doc), lc, false)
+	_, err = slPub.Publish(docKey, api.GetAuthorPub(doc), lc, nil, false)
 	assert.Equal(t, ErrUnexpectedMissingDocument, err)
 
 	// check missing doc triggers error
@@ -170,12 +284,12 @@ func TestSingleLoadPublisher_Publish_err(t *testing.T) {
 	}
 	slPub = NewSingleLoadPublisher(pub3, docL)
 	docL.docs[docKey.String()] = doc
-	err = slPub.Publish(docKey, api.GetAuthorPub(doc), lc, false)
+	_, err = slPub.Publish(docKey, api.GetAuthorPub(doc), lc, nil, false)
 	assert.NotNil(t, err)
 
 	// check delete error bubbles up
 	slPub = NewSingleLoadPublisher(pub, &fixedDocSLD{deleteError: errors.New("some Delete error")})
-	err = slPub.Publish(docKey, api.GetAuthorPub(doc), lc, false)
+	_, err = slPub.Publish(docKey, api.GetAuthorPub(doc), lc, nil, false)
 	assert.NotNil(t, err)
 }
 
@@ -198,7 +312,7 @@ func TestMultiLoadPublisher_Publish_ok(t *testing.T) {
 				assert.Nil(t, err)
 				mlPub := NewMultiLoadPublisher(slPub, params)
 
-				err = mlPub.Publish(docKeys, authorKey, cb, deleteDoc)
+				err = mlPub.Publish(context.Background(), docKeys, authorKey, cb, nil, deleteDoc, nil)
 				assert.Nil(t, err)
 
 				// check all keys have been "published"
@@ -228,14 +342,169 @@ func TestMultiLoadPublisher_Publish_err(t *testing.T) {
 			params, err := NewParameters(DefaultPutTimeout, DefaultGetTimeout,
 				putParallelism, DefaultGetParallelism)
 			assert.Nil(t, err)
+			params.PutRetryBaseDelay = time.Millisecond
 			mlPub := NewMultiLoadPublisher(slPub, params)
 
-			err = mlPub.Publish(docKeys, authorKey, cb, false)
+			err = mlPub.Publish(context.Background(), docKeys, authorKey, cb, nil, false, nil)
 			assert.NotNil(t, err)
 		}
 	}
 }
 
+func TestMultiLoadPublisher_Publish_retry(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorKey := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	docKeys := make([]id.ID, 8)
+	for i := range docKeys {
+		docKeys[i] = id.NewPseudoRandom(rng)
+	}
+	params := NewDefaultParameters()
+	params.PutRetryBaseDelay = time.Millisecond
+	params.PutRetryMaxDelay = 2 * time.Millisecond
+
+	// check docs that fail fewer than max attempts are retried with new clients
+	cb := &countingClientBalancer{}
+	slPub := &flakySingleLoadPublisher{
+		nFails:   DefaultPutMaxAttempts - 1,
+		attempts: make(map[string]int),
+	}
+	mlPub := NewMultiLoadPublisher(slPub, params)
+	err := mlPub.Publish(context.Background(), docKeys, authorKey, cb, nil, false, nil)
+	assert.Nil(t, err)
+	for _, docKey := range docKeys {
+		assert.Equal(t, DefaultPutMaxAttempts, slPub.attempts[docKey.String()])
+	}
+	assert.Equal(t, len(docKeys)*DefaultPutMaxAttempts, cb.nNext)
+
+	// check docs that fail max attempts return error
+	cb = &countingClientBalancer{}
+	slPub = &flakySingleLoadPublisher{
+		nFails:   DefaultPutMaxAttempts,
+		attempts: make(map[string]int),
+	}
+	mlPub = NewMultiLoadPublisher(slPub, params)
+	err = mlPub.Publish(context.Background(), docKeys[:1], authorKey, cb, nil, false, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, DefaultPutMaxAttempts, slPub.attempts[docKeys[0].String()])
+
+	// check non-retryable errors aren't retried
+	cb = &countingClientBalancer{}
+	slPub2 := &fixedSingleLoadPublisher{err: ErrUnexpectedMissingDocument}
+	mlPub = NewMultiLoadPublisher(slPub2, params)
+	err = mlPub.Publish(context.Background(), docKeys[:1], authorKey, cb, nil, false, nil)
+	assert.Equal(t, ErrUnexpectedMissingDocument, err)
+	assert.Equal(t, 1, cb.nNext)
+
+	// check non-retryable coded errors aren't retried
+	cb = &countingClientBalancer{}
+	invalidSigErr := &api.InvalidSignatureError{Reason: "some invalid signature"}
+	slPub3 := &fixedSingleLoadPublisher{err: invalidSigErr}
+	mlPub = NewMultiLoadPublisher(slPub3, params)
+	err = mlPub.Publish(context.Background(), docKeys[:1], authorKey, cb, nil, false, nil)
+	assert.Equal(t, invalidSigErr, err)
+	assert.Equal(t, 1, cb.nNext)
+
+	// check invalid argument errors from librarians aren't retried
+	cb = &countingClientBalancer{}
+	invalidArgErr := grpc.Errorf(codes.InvalidArgument, "requested too many replicas")
+	slPub4 := &fixedSingleLoadPublisher{err: invalidArgErr}
+	mlPub = NewMultiLoadPublisher(slPub4, params)
+	err = mlPub.Publish(context.Background(), docKeys[:1], authorKey, cb, nil, false, nil)
+	assert.Equal(t, invalidArgErr, err)
+	assert.Equal(t, 1, cb.nNext)
+}
+
+func TestMultiLoadPublisher_Publish_canceled(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorKey := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	docKeys := []id.ID{id.NewPseudoRandom(rng)}
+	params := NewDefaultParameters()
+	params.PutRetryBaseDelay = time.Hour
+	params.PutRetryMaxDelay = time.Hour
+	slPub := &flakySingleLoadPublisher{
+		nFails:   DefaultPutMaxAttempts,
+		attempts: make(map[string]int),
+	}
+	mlPub := NewMultiLoadPublisher(slPub, params)
+
+	// check cancellation short-circuits the backoff delay
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := mlPub.Publish(ctx, docKeys, authorKey, &countingClientBalancer{}, nil, false, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, slPub.attempts[docKeys[0].String()])
+
+	// check already canceled context publishes nothing
+	err = mlPub.Publish(ctx, docKeys, authorKey, &countingClientBalancer{}, nil, false, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, slPub.attempts[docKeys[0].String()])
+}
+
+func TestRetryDelay(t *testing.T) {
+	params := NewDefaultParameters()
+	params.PutRetryBaseDelay = 100 * time.Millisecond
+	params.PutRetryMaxDelay = 1 * time.Second
+	for c := 0; c < 16; c++ {
+		for retry, maxDelay := range []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			1 * time.Second,
+			1 * time.Second,
+		} {
+			delay := retryDelay(params, uint32(retry+1))
+			assert.True(t, delay >= maxDelay/2)
+			assert.True(t, delay < maxDelay)
+		}
+	}
+
+	params.PutRetryBaseDelay = 0
+	assert.Equal(t, time.Duration(0), retryDelay(params, 1))
+}
+
+func TestMultiLoadPublisher_Publish_maxInFlight(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	cb := &fixedClientBalancer{}
+	authorKey := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	for _, maxInFlight := range []uint32{1, 2, 4} {
+		slPub := &countingSingleLoadPublisher{}
+		params := NewDefaultParameters()
+		params.MaxInFlight = maxInFlight
+		mlPub := NewMultiLoadPublisher(slPub, params)
+
+		// check bound holds across concurrent Publish calls
+		nPublishes, nDocs := 4, 8
+		wg := new(sync.WaitGroup)
+		for c := 0; c < nPublishes; c++ {
+			docKeys := make([]id.ID, nDocs)
+			for i := range docKeys {
+				docKeys[i] = id.NewPseudoRandom(rng)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := mlPub.Publish(context.Background(), docKeys, authorKey, cb, nil, false, nil)
+				assert.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, nPublishes*nDocs, slPub.nPublished)
+		assert.True(t, slPub.maxInFlight <= int(maxInFlight))
+		assert.True(t, slPub.maxInFlight > 0)
+	}
+}
+
+func TestDefaultMaxInFlight(t *testing.T) {
+	assert.Equal(t, uint32(DefaultMaxInFlightPerLibrarian), DefaultMaxInFlight(0))
+	assert.Equal(t, uint32(DefaultMaxInFlightPerLibrarian), DefaultMaxInFlight(1))
+	assert.Equal(t, uint32(4*DefaultMaxInFlightPerLibrarian), DefaultMaxInFlight(4))
+}
+
 func TestMultiAcquirePublish(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	cb := &fixedClientBalancer{}
@@ -277,12 +546,28 @@ func TestMultiAcquirePublish(t *testing.T) {
 			assert.Nil(t, err)
 		}
 
-		// publish & then acquire docs
-		err = mlP.Publish(docKeys, nil, cb, false)
+		// publish & then acquire docs, tracking the progress of each
+		var nPublished, nAcquired int
+		var bytesPublished, bytesAcquired uint64
+		err = mlP.Publish(context.Background(), docKeys, nil, cb, nil, false,
+			func(nDone, nTotal int, bytesDone uint64) {
+				assert.Equal(t, int(c.numDocs), nTotal)
+				nPublished, bytesPublished = nDone, bytesDone
+			})
 		assert.Nil(t, err)
-		err = msA.Acquire(docKeys, nil, cb)
+		err = msA.Acquire(context.Background(), docKeys, nil, cb, nil,
+			func(nDone, nTotal int, bytesDone uint64) {
+				assert.Equal(t, int(c.numDocs), nTotal)
+				nAcquired, bytesAcquired = nDone, bytesDone
+			})
 		assert.Nil(t, err)
 
+		// check progress was reported for every doc
+		assert.Equal(t, int(c.numDocs), nPublished)
+		assert.Equal(t, int(c.numDocs), nAcquired)
+		assert.True(t, bytesPublished > 0)
+		assert.Equal(t, bytesPublished, bytesAcquired)
+
 		// test that states of both DocumentStorerLoaders contain all the docs
 		assert.Equal(t, int(c.numDocs), len(docSL1.docs))
 		for i := uint32(0); i < c.numDocs; i++ {
@@ -295,8 +580,11 @@ func TestMultiAcquirePublish(t *testing.T) {
 }
 
 type fixedPutter struct {
-	request *api.PutRequest
-	err     error
+	request        *api.PutRequest
+	nReplicas      uint32
+	replicaPeerIDs [][]byte
+	timedOut       bool
+	err            error
 }
 
 func (p *fixedPutter) Put(
@@ -308,9 +596,53 @@ func (p *fixedPutter) Put(
 		Metadata: &api.ResponseMetadata{
 			RequestId: in.Metadata.RequestId,
 		},
+		NReplicas:      p.nReplicas,
+		ReplicaPeerIds: p.replicaPeerIDs,
+		TimedOut:       p.timedOut,
 	}, p.err
 }
 
+type fixedPutStreamer struct {
+	fixedPutter
+	stream *fixedPutStreamClient
+	err    error
+}
+
+func (p *fixedPutStreamer) PutStream(
+	ctx context.Context, opts ...grpc.CallOption,
+) (api.Librarian_PutStreamClient, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.stream, nil
+}
+
+type fixedPutStreamClient struct {
+	grpc.ClientStream
+	chunks    []*api.PutChunk
+	nReplicas uint32
+	sendErr   error
+	recvErr   error
+}
+
+func (c *fixedPutStreamClient) Send(chunk *api.PutChunk) error {
+	if c.sendErr != nil {
+		return c.sendErr
+	}
+	c.chunks = append(c.chunks, chunk)
+	return nil
+}
+
+func (c *fixedPutStreamClient) CloseAndRecv() (*api.PutResponse, error) {
+	if c.recvErr != nil {
+		return nil, c.recvErr
+	}
+	return &api.PutResponse{
+		Metadata:  &api.ResponseMetadata{RequestId: c.chunks[0].Request.Metadata.RequestId},
+		NReplicas: c.nReplicas,
+	}, nil
+}
+
 type diffRequestIDPutter struct {
 	rng *rand.Rand
 }
@@ -362,6 +694,10 @@ func (f *fixedSigner) Sign(m proto.Message) (string, error) {
 	return f.signature, f.err
 }
 
+func (f *fixedSigner) SignUntil(m proto.Message, notAfter time.Time) (string, error) {
+	return f.signature, f.err
+}
+
 type fixedDocSLD struct {
 	docs        map[string]*api.Document
 	mu          sync.Mutex
@@ -395,10 +731,11 @@ type fixedPublisher struct {
 	publishErr error
 }
 
-func (p *fixedPublisher) Publish(doc *api.Document, authorPub []byte, lc api.Putter) (
-	id.ID, error) {
+func (p *fixedPublisher) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *Replication,
+) (id.ID, *Result, error) {
 	p.doc = doc
-	return p.publishID, p.publishErr
+	return p.publishID, nil, p.publishErr
 }
 
 type memPublisherAcquirer struct {
@@ -406,8 +743,9 @@ type memPublisherAcquirer struct {
 	mu   sync.Mutex
 }
 
-func (p *memPublisherAcquirer) Publish(doc *api.Document, authorPub []byte, lc api.Putter) (
-	id.ID, error) {
+func (p *memPublisherAcquirer) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *Replication,
+) (id.ID, *Result, error) {
 	docKey, err := api.GetKey(doc)
 	if err != nil {
 		panic(err)
@@ -415,11 +753,12 @@ func (p *memPublisherAcquirer) Publish(doc *api.Document, authorPub []byte, lc a
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.docs[docKey.String()] = doc
-	return docKey, nil
+	return docKey, &Result{}, nil
 }
 
-func (p *memPublisherAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (
-	*api.Document, error) {
+func (p *memPublisherAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *Search,
+) (*api.Document, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.docs[docKey.String()], nil
@@ -432,14 +771,76 @@ type fixedSingleLoadPublisher struct {
 }
 
 func (f *fixedSingleLoadPublisher) Publish(
-	docKey id.ID, authorPub []byte, lc api.Putter, delete bool,
-) error {
+	docKey id.ID, authorPub []byte, lc api.Putter, repl *Replication, delete bool,
+) (*api.Document, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.err == nil {
 		f.publishedKeys[docKey.String()] = delete
 	}
-	return f.err
+	return nil, f.err
+}
+
+// countingSingleLoadPublisher tracks the max number of concurrent Publish calls.
+type countingSingleLoadPublisher struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	nPublished  int
+}
+
+func (f *countingSingleLoadPublisher) Publish(
+	docKey id.ID, authorPub []byte, lc api.Putter, repl *Replication, delete bool,
+) (*api.Document, error) {
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inFlight--
+	f.nPublished++
+	return nil, nil
+}
+
+// flakySingleLoadPublisher fails the first nFails attempts to publish each document.
+type flakySingleLoadPublisher struct {
+	mu       sync.Mutex
+	nFails   int
+	attempts map[string]int
+}
+
+func (f *flakySingleLoadPublisher) Publish(
+	docKey id.ID, authorPub []byte, lc api.Putter, repl *Replication, delete bool,
+) (*api.Document, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts[docKey.String()]++
+	if f.attempts[docKey.String()] <= f.nFails {
+		return nil, errors.New("some Publish error")
+	}
+	return nil, nil
+}
+
+type countingClientBalancer struct {
+	mu    sync.Mutex
+	nNext int
+}
+
+func (f *countingClientBalancer) Next() (api.LibrarianClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nNext++
+	return nil, nil
+}
+
+func (f *countingClientBalancer) CloseAll() error {
+	return nil
 }
 
 type fixedClientBalancer struct {
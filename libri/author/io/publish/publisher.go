@@ -3,6 +3,8 @@ package publish
 import (
 	"bytes"
 	"errors"
+	"io"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -11,6 +13,10 @@ import (
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -29,6 +35,27 @@ const (
 	// DefaultGetParallelism is the default parallelism a MultiStoreAcquirer uses when
 	// making multiple Get calls to librarians.
 	DefaultGetParallelism = 3
+
+	// DefaultMaxInFlightPerLibrarian is the default number of documents a MultiLoadPublisher
+	// may have in flight per librarian it balances between.
+	DefaultMaxInFlightPerLibrarian = 3
+
+	// DefaultPutMaxAttempts is the default number of times a MultiLoadPublisher attempts to
+	// publish each document.
+	DefaultPutMaxAttempts = 3
+
+	// DefaultPutRetryBaseDelay is the default delay before a MultiLoadPublisher's first retry
+	// of a failed publish.
+	DefaultPutRetryBaseDelay = 250 * time.Millisecond
+
+	// DefaultPutRetryMaxDelay is the default max delay between a MultiLoadPublisher's retries
+	// of a failed publish.
+	DefaultPutRetryMaxDelay = 5 * time.Second
+
+	// DefaultPutStreamThreshold is the default size (in bytes) beyond which a Publisher
+	// streams a document to its librarian rather than sending it in a single Put request. It
+	// leaves ample headroom under the max gRPC message size.
+	DefaultPutStreamThreshold = 2 * 1024 * 1024
 )
 
 var (
@@ -53,6 +80,10 @@ var (
 	// ErrInconsistentAuthorPubKey indicates when the document author public key is different
 	// from the expected value.
 	ErrInconsistentAuthorPubKey = errors.New("inconsistent author public key")
+
+	// ErrPutTimedOut indicates when the librarian's store timed out before storing enough
+	// replicas of the document.
+	ErrPutTimedOut = errors.New("librarian timed out storing document")
 )
 
 // Parameters define configuration used by a Publisher.
@@ -70,6 +101,29 @@ type Parameters struct {
 	// GetParallelism is the number of simultaneous Ge requests (for different documents) that
 	// can occur.
 	GetParallelism uint32
+
+	// MaxInFlight is the max number of documents a MultiLoadPublisher may have loaded or being
+	// Put at once, across all of its concurrent Publish calls. Zero means no bound other than
+	// PutParallelism for each Publish call; DefaultMaxInFlight gives a bound scaled by the
+	// number of librarians.
+	MaxInFlight uint32
+
+	// PutMaxAttempts is the max number of times a MultiLoadPublisher attempts to publish each
+	// document, each time with a freshly-selected librarian. Zero is equivalent to one.
+	PutMaxAttempts uint32
+
+	// PutRetryBaseDelay is the delay before the first retry of a failed publish, doubling (with
+	// jitter) for each subsequent retry.
+	PutRetryBaseDelay time.Duration
+
+	// PutRetryMaxDelay is the max delay between retries of a failed publish.
+	PutRetryMaxDelay time.Duration
+
+	// PutStreamThreshold is the size (in bytes) beyond which a Publisher streams a document to
+	// its librarian in chunks via PutStream rather than sending it in a single Put request,
+	// which avoids the max gRPC message size for large pages. Zero means documents are never
+	// streamed.
+	PutStreamThreshold uint64
 }
 
 // NewParameters validates the parameters and returns a new *Parameters instance.
@@ -93,10 +147,14 @@ func NewParameters(
 		return nil, ErrGetParallelismZeroValue
 	}
 	return &Parameters{
-		PutTimeout:     putTimeout,
-		GetTimeout:     getTimeout,
-		PutParallelism: putParallelism,
-		GetParallelism: getParallelism,
+		PutTimeout:         putTimeout,
+		GetTimeout:         getTimeout,
+		PutParallelism:     putParallelism,
+		GetParallelism:     getParallelism,
+		PutMaxAttempts:     DefaultPutMaxAttempts,
+		PutRetryBaseDelay:  DefaultPutRetryBaseDelay,
+		PutRetryMaxDelay:   DefaultPutRetryMaxDelay,
+		PutStreamThreshold: DefaultPutStreamThreshold,
 	}, nil
 }
 
@@ -111,10 +169,107 @@ func NewDefaultParameters() *Parameters {
 	return params
 }
 
+// DefaultMaxInFlight returns the default MaxInFlight for a MultiLoadPublisher balancing between
+// the given number of librarians.
+func DefaultMaxInFlight(nLibrarians int) uint32 {
+	if nLibrarians < 1 {
+		nLibrarians = 1
+	}
+	return uint32(nLibrarians) * DefaultMaxInFlightPerLibrarian
+}
+
+// Progress receives updates from a MultiLoadPublisher or MultiStoreAcquirer after each document
+// is published or acquired, with the number of documents done so far, the total number of
+// documents, and the cumulative size (in bytes) of the documents done.
+type Progress func(nDone, nTotal int, bytesDone uint64)
+
+// progressTracker serializes the Progress updates from multiple workers.
+type progressTracker struct {
+	progress  Progress
+	nTotal    int
+	nDone     int
+	bytesDone uint64
+	mu        sync.Mutex
+}
+
+// newProgressTracker returns a tracker for the given Progress, or nil if the Progress is nil.
+func newProgressTracker(progress Progress, nTotal int) *progressTracker {
+	if progress == nil {
+		return nil
+	}
+	return &progressTracker{
+		progress: progress,
+		nTotal:   nTotal,
+	}
+}
+
+// done records that a document is done. It is a no-op for a nil tracker.
+func (t *progressTracker) done(doc *api.Document) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nDone++
+	if doc != nil {
+		t.bytesDone += uint64(proto.Size(doc))
+	}
+	t.progress(t.nDone, t.nTotal, t.bytesDone)
+}
+
+// Replication defines how many replicas librarians store of each published document. A nil
+// *Replication uses the librarians' defaults.
+type Replication struct {
+	// NReplicas, if non-zero, is the number of replicas to store instead of the librarians'
+	// default.
+	NReplicas uint32
+
+	// MinNReplicas, if non-zero, is the fewest replicas acceptable when librarians can't store
+	// all of them, below which publishing errors.
+	MinNReplicas uint32
+}
+
+func (r *Replication) nReplicas() uint32 {
+	if r == nil {
+		return 0
+	}
+	return r.NReplicas
+}
+
+func (r *Replication) minNReplicas() uint32 {
+	if r == nil {
+		return 0
+	}
+	return r.MinNReplicas
+}
+
+// Result describes how a librarian stored a published document.
+type Result struct {
+	// NReplicas is the number of replicas of the document stored in the libri network.
+	NReplicas uint32
+
+	// ReplicaPeerIDs are the IDs of the peers storing the replicas.
+	ReplicaPeerIDs []cid.ID
+}
+
+func newResult(rp *api.PutResponse) *Result {
+	peerIDs := make([]cid.ID, len(rp.ReplicaPeerIds))
+	for i, peerID := range rp.ReplicaPeerIds {
+		peerIDs[i] = cid.FromBytes(peerID)
+	}
+	return &Result{
+		NReplicas:      rp.NReplicas,
+		ReplicaPeerIDs: peerIDs,
+	}
+}
+
 // Publisher Puts a document into the libri network using a librarian client.
 type Publisher interface {
-	// Publish Puts a document using a librarian client and returns the ID of the document.
-	Publish(doc *api.Document, authorPub []byte, lc api.Putter) (cid.ID, error)
+	// Publish Puts a document using a librarian client and returns the ID of the document and
+	// the Result describing where it was stored. The number of replicas the librarian stores is
+	// given by repl.
+	Publish(doc *api.Document, authorPub []byte, lc api.Putter, repl *Replication) (cid.ID,
+		*Result, error)
 }
 
 type publisher struct {
@@ -132,35 +287,90 @@ func NewPublisher(clientID ecid.ID, signer client.Signer, params *Parameters) Pu
 	}
 }
 
-func (p *publisher) Publish(doc *api.Document, authorPub []byte, lc api.Putter) (cid.ID, error) {
+func (p *publisher) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *Replication,
+) (cid.ID, *Result, error) {
 	docKey, err := api.GetKey(doc)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if !bytes.Equal(authorPub, api.GetAuthorPub(doc)) {
-		return nil, ErrInconsistentAuthorPubKey
+		return nil, nil, ErrInconsistentAuthorPubKey
 	}
 	rq := client.NewPutRequest(p.clientID, docKey, doc)
+	rq.NReplicas = repl.nReplicas()
+	rq.MinNReplicas = repl.minNReplicas()
+	rp, err := p.put(rq, lc)
+	if err != nil {
+		return nil, nil, api.FromRPCError(err)
+	}
+	if !bytes.Equal(rq.Metadata.RequestId, rp.Metadata.RequestId) {
+		return nil, nil, client.ErrUnexpectedRequestID
+	}
+	if rp.Operation == api.PutOperation_STORED && rp.NReplicas < repl.minNReplicas() {
+		return nil, nil, &api.InsufficientReplicasError{
+			NReplicas:    rp.NReplicas,
+			MinNReplicas: repl.minNReplicas(),
+		}
+	}
+	if rp.TimedOut {
+		return nil, nil, ErrPutTimedOut
+	}
+	return docKey, newResult(rp), nil
+}
+
+// put sends the Put request to the librarian, streaming it in chunks if its value is larger than
+// the PutStreamThreshold and the librarian client supports it.
+func (p *publisher) put(rq *api.PutRequest, lc api.Putter) (*api.PutResponse, error) {
+	if ps, ok := lc.(api.PutStreamer); ok && p.params.PutStreamThreshold > 0 &&
+		uint64(proto.Size(rq.Value)) > p.params.PutStreamThreshold {
+		return p.putStream(rq, ps)
+	}
 	ctx, cancel, err := client.NewSignedTimeoutContext(p.signer, rq, p.params.PutTimeout)
 	if err != nil {
 		return nil, err
 	}
-	rp, err := lc.Put(ctx, rq)
-	cancel()
+	defer cancel()
+	return lc.Put(ctx, rq)
+}
+
+// putStream streams the Put request to the librarian in chunks, signing the request without its
+// value in the first chunk.
+func (p *publisher) putStream(rq *api.PutRequest, ps api.PutStreamer) (*api.PutResponse,
+	error) {
+	chunks, err := api.NewPutChunks(rq, api.DefaultPutChunkSize)
 	if err != nil {
 		return nil, err
 	}
-	if !bytes.Equal(rq.Metadata.RequestId, rp.Metadata.RequestId) {
-		return nil, client.ErrUnexpectedRequestID
+	ctx, cancel, err := client.NewSignedTimeoutContext(p.signer, chunks[0].Request,
+		p.params.PutTimeout)
+	if err != nil {
+		return nil, err
 	}
-	return docKey, nil
+	defer cancel()
+	stream, err := ps.PutStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		if err := stream.Send(chunk); err == io.EOF {
+			// librarian closed the stream early, so its error comes from CloseAndRecv
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
 }
 
 // SingleLoadPublisher publishes documents from internal storage.
 type SingleLoadPublisher interface {
 	// Publish loads a document with the given key and publishes them using the given
-	// librarian client, optionally deleting the document after it is published.
-	Publish(docKey cid.ID, authorPub []byte, lc api.Putter, delete bool) error
+	// librarian client, optionally deleting the document after it is published. It returns the
+	// published document, which may be nil if the document didn't need publishing. The number
+	// of replicas the librarian stores is given by repl.
+	Publish(docKey cid.ID, authorPub []byte, lc api.Putter, repl *Replication, delete bool) (
+		*api.Document, error)
 }
 
 type singleLoadPublisher struct {
@@ -178,50 +388,80 @@ func NewSingleLoadPublisher(inner Publisher, docLD storage.DocumentLD) SingleLoa
 }
 
 func (p *singleLoadPublisher) Publish(
-	docKey cid.ID, authorPub []byte, lc api.Putter, delete bool,
-) error {
+	docKey cid.ID, authorPub []byte, lc api.Putter, repl *Replication, delete bool,
+) (*api.Document, error) {
 
 	pageDoc, err := p.docLD.Load(docKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if pageDoc == nil {
-		return ErrUnexpectedMissingDocument
+		return nil, ErrUnexpectedMissingDocument
 	}
-	if _, err := p.inner.Publish(pageDoc, authorPub, lc); err != nil {
-		return err
+	if _, _, err := p.inner.Publish(pageDoc, authorPub, lc, repl); err != nil {
+		return nil, err
 	}
 	if delete {
-		return p.docLD.Delete(docKey)
+		if err := p.docLD.Delete(docKey); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return pageDoc, nil
 }
 
 // MultiLoadPublisher loads and publishes a collection of documents from internal storage.
 type MultiLoadPublisher interface {
 	// Publish in parallel loads and publishes the documents with the given keys, optionally
 	// deleting them from local storage after successful delete. It balances between librarian
-	// clients for its Put requests.
-	Publish(docKeys []cid.ID, authorPub []byte, cb api.ClientBalancer, delete bool) error
+	// clients for its Put requests, retrying failed publishes with exponential backoff. If
+	// progress is not nil, it is called after each document is published. No new documents are
+	// published or retried once ctx is done, in which case ctx.Err() is returned. The
+	// number of replicas librarians store of each document is given by repl.
+	Publish(
+		ctx context.Context,
+		docKeys []cid.ID,
+		authorPub []byte,
+		cb api.ClientBalancer,
+		repl *Replication,
+		delete bool,
+		progress Progress,
+	) error
 }
 
 type multiLoadPublisher struct {
 	inner  SingleLoadPublisher
 	params *Parameters
+
+	// semaphore bounding the documents in flight, or nil if unbounded
+	inFlight chan struct{}
 }
 
-// NewMultiLoadPublisher creates a new MultiLoadPublisher.
+// NewMultiLoadPublisher creates a new MultiLoadPublisher. If params.MaxInFlight is non-zero, at
+// most that many documents are loaded or being published at once, with further documents waiting
+// to be loaded until others finish.
 func NewMultiLoadPublisher(inner SingleLoadPublisher, params *Parameters) MultiLoadPublisher {
+	var inFlight chan struct{}
+	if params.MaxInFlight > 0 {
+		inFlight = make(chan struct{}, params.MaxInFlight)
+	}
 	return &multiLoadPublisher{
-		inner:  inner,
-		params: params,
+		inner:    inner,
+		params:   params,
+		inFlight: inFlight,
 	}
 }
 
 func (p *multiLoadPublisher) Publish(
-	docKeys []cid.ID, authorPub []byte, cb api.ClientBalancer, delete bool,
+	ctx context.Context,
+	docKeys []cid.ID,
+	authorPub []byte,
+	cb api.ClientBalancer,
+	repl *Replication,
+	delete bool,
+	progress Progress,
 ) error {
 
+	tracker := newProgressTracker(progress, len(docKeys))
 	docKeysChan := make(chan cid.ID, p.params.PutParallelism)
 	go loadChan(docKeys, docKeysChan)
 	wg := new(sync.WaitGroup)
@@ -230,15 +470,16 @@ func (p *multiLoadPublisher) Publish(
 		wg.Add(1)
 		go func() {
 			for docKey := range docKeysChan {
-				lc, err := cb.Next()
-				if err != nil {
+				if err := ctx.Err(); err != nil {
 					putErrs <- err
-					return
+					break
 				}
-				if err := p.inner.Publish(docKey, authorPub, lc, delete); err != nil {
+				doc, err := p.publishWithRetry(ctx, docKey, authorPub, cb, repl, delete)
+				if err != nil {
 					putErrs <- err
 					break
 				}
+				tracker.done(doc)
 			}
 			wg.Done()
 		}()
@@ -254,6 +495,78 @@ func (p *multiLoadPublisher) Publish(
 	}
 }
 
+// publishWithRetry publishes the document with a librarian client from the balancer, retrying
+// with a new client after a backoff delay if the publish fails.
+func (p *multiLoadPublisher) publishWithRetry(
+	ctx context.Context,
+	docKey cid.ID,
+	authorPub []byte,
+	cb api.ClientBalancer,
+	repl *Replication,
+	delete bool,
+) (*api.Document, error) {
+	for attempt := uint32(1); ; attempt++ {
+		lc, err := cb.Next()
+		if err != nil {
+			return nil, err
+		}
+		p.acquire()
+		doc, err := p.inner.Publish(docKey, authorPub, lc, repl, delete)
+		p.release()
+		if err == nil || !isRetryable(err) || attempt >= p.params.PutMaxAttempts {
+			return doc, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay(p.params, attempt)):
+		}
+	}
+}
+
+// isRetryable returns whether a failed publish might succeed if retried.
+func isRetryable(err error) bool {
+	if coded, ok := err.(api.CodedError); ok {
+		return coded.Retryable()
+	}
+	if grpc.Code(err) == codes.InvalidArgument {
+		// the librarian will reject the same request again
+		return false
+	}
+	return err != ErrUnexpectedMissingDocument && err != ErrInconsistentAuthorPubKey &&
+		err != api.ErrExpired
+}
+
+// retryDelay returns the delay before the given retry, which is sampled uniformly between half
+// and all of the base delay doubled for each previous retry, capped at the max delay.
+func retryDelay(params *Parameters, retry uint32) time.Duration {
+	delay := params.PutRetryBaseDelay
+	for i := uint32(1); i < retry && delay < params.PutRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > params.PutRetryMaxDelay {
+		delay = params.PutRetryMaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// acquire blocks until another document may be in flight.
+func (p *multiLoadPublisher) acquire() {
+	if p.inFlight != nil {
+		p.inFlight <- struct{}{}
+	}
+}
+
+// release marks a document as no longer in flight.
+func (p *multiLoadPublisher) release() {
+	if p.inFlight != nil {
+		<-p.inFlight
+	}
+}
+
 func loadChan(idSlice []cid.ID, idChan chan cid.ID) {
 	for _, id := range idSlice {
 		idChan <- id
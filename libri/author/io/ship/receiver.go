@@ -1,6 +1,9 @@
 package ship
 
 import (
+	"bytes"
+	"errors"
+
 	"github.com/drausin/libri/libri/author/io/enc"
 	"github.com/drausin/libri/libri/author/io/publish"
 	"github.com/drausin/libri/libri/author/keychain"
@@ -8,17 +11,40 @@ import (
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrUnexpectedEnvelopeKey indicates when a received envelope's key doesn't match the
+	// requested envelope key.
+	ErrUnexpectedEnvelopeKey = errors.New("unexpected envelope key")
+
+	// ErrUnexpectedEnvelopeAuthor indicates when a received envelope's EEK ciphertext MAC doesn't
+	// match the KEK derived from its author and reader public keys, so it wasn't created by its
+	// claimed author.
+	ErrUnexpectedEnvelopeAuthor error = &api.InvalidSignatureError{
+		Reason: "envelope not created by its claimed author",
+	}
 )
 
 // Receiver downloads the envelope, entry, and pages from the libri network.
 type Receiver interface {
 	// ReceiveEntry gets (from libri) the envelope, entry, and pages implied by the envelope key. It
 	// stores these documents in a storage.DocumentStorer and returns the entry and encryption
-	// keys.
-	ReceiveEntry(envelopeKey id.ID) (*api.Document, *enc.EEK, error)
+	// keys. How the librarians search for each document is given by search. If progress is not
+	// nil, it is called after each page is received. Once ctx is done, no further documents are
+	// requested and ctx.Err() is returned.
+	ReceiveEntry(
+		ctx context.Context, envelopeKey id.ID, search *publish.Search, progress publish.Progress,
+	) (*api.Document, *enc.EEK, error)
 
+	// ReceiveEnvelope gets (from libri) the envelope with the given key. It verifies that the
+	// envelope has that key and that it was created by its author public key, i.e., that its EEK
+	// ciphertext MAC matches the KEK shared by its author and reader public keys.
 	ReceiveEnvelope(envelopeKey id.ID) (*api.Envelope, error)
 
+	// GetEEK decrypts the envelope's EEK using the KEK shared by its author and reader public keys.
 	GetEEK(envelope *api.Envelope) (*enc.EEK, error)
 }
 
@@ -28,6 +54,7 @@ type receiver struct {
 	acquirer   publish.Acquirer
 	msAcquirer publish.MultiStoreAcquirer
 	docS       storage.DocumentStorer
+	streaming  bool
 }
 
 // NewReceiver creates a new Receiver from the librarian balancer, keychain of reader keys,
@@ -48,11 +75,31 @@ func NewReceiver(
 	}
 }
 
-func (r *receiver) ReceiveEntry(envelopeKey id.ID) (*api.Document, *enc.EEK, error) {
-	envelope, err := r.ReceiveEnvelope(envelopeKey)
+// NewStreamingReceiver creates a new Receiver like NewReceiver, except that ReceiveEntry does not
+// acquire the pages of multi-page entries, leaving them to be acquired as they are scanned by a
+// page.NewStreamingStorerLoader.
+func NewStreamingReceiver(
+	librarians api.ClientBalancer,
+	readerKeys keychain.Getter,
+	acquirer publish.Acquirer,
+	msAcquirer publish.MultiStoreAcquirer,
+	docS storage.DocumentStorer,
+) Receiver {
+	r := NewReceiver(librarians, readerKeys, acquirer, msAcquirer, docS).(*receiver)
+	r.streaming = true
+	return r
+}
+
+func (r *receiver) ReceiveEntry(
+	ctx context.Context, envelopeKey id.ID, search *publish.Search, progress publish.Progress,
+) (*api.Document, *enc.EEK, error) {
+	envelope, err := r.receiveEnvelope(envelopeKey, search)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	lc, err := r.librarians.Next()
 	if err != nil {
 		return nil, nil, err
@@ -63,22 +110,27 @@ func (r *receiver) ReceiveEntry(envelopeKey id.ID) (*api.Document, *enc.EEK, err
 	}
 	// get the entry and pages
 	entryKey := id.FromBytes(envelope.EntryKey)
-	entryDoc, err := r.acquirer.Acquire(entryKey, envelope.AuthorPublicKey, lc)
+	entryDoc, err := r.acquirer.Acquire(entryKey, envelope.AuthorPublicKey, lc, search)
 	if err != nil {
 		return nil, nil, err
 	}
-	if err := r.getPages(entryDoc, envelope.AuthorPublicKey); err != nil {
+	if err := r.getPages(ctx, entryDoc, envelope.AuthorPublicKey, search, progress); err != nil {
 		return nil, nil, err
 	}
 	return entryDoc, eek, nil
 }
 
 func (r *receiver) ReceiveEnvelope(envelopeKey id.ID) (*api.Envelope, error) {
+	return r.receiveEnvelope(envelopeKey, nil)
+}
+
+func (r *receiver) receiveEnvelope(envelopeKey id.ID, search *publish.Search) (
+	*api.Envelope, error) {
 	lc, err := r.librarians.Next()
 	if err != nil {
 		return nil, err
 	}
-	envelopeDoc, err := r.acquirer.Acquire(envelopeKey, nil, lc)
+	envelopeDoc, err := r.acquirer.Acquire(envelopeKey, nil, lc, search)
 	if err != nil {
 		return nil, err
 	}
@@ -86,10 +138,46 @@ func (r *receiver) ReceiveEnvelope(envelopeKey id.ID) (*api.Envelope, error) {
 	if !ok {
 		return nil, api.ErrUnexpectedDocumentType
 	}
+	receivedKey, err := api.GetKey(envelopeDoc)
+	if err != nil {
+		return nil, err
+	}
+	if receivedKey.Cmp(envelopeKey) != 0 {
+		return nil, ErrUnexpectedEnvelopeKey
+	}
+	if err := r.verifyAuthor(envelope.Envelope); err != nil {
+		return nil, err
+	}
 	return envelope.Envelope, nil
 }
 
 func (r *receiver) GetEEK(envelope *api.Envelope) (*enc.EEK, error) {
+	kek, err := r.getKEK(envelope)
+	if err != nil {
+		return nil, err
+	}
+	defer kek.Zero()
+	eek, err := kek.Decrypt(envelope.EekCiphertext, envelope.EekCiphertextMac)
+	return eek, err
+}
+
+// verifyAuthor checks that the envelope was created by its author public key. Since only the
+// author and reader can derive the KEK from the ECDH shared secret, an EEK ciphertext MAC matching
+// it attributes the envelope to its author.
+func (r *receiver) verifyAuthor(envelope *api.Envelope) error {
+	kek, err := r.getKEK(envelope)
+	if err != nil {
+		return err
+	}
+	defer kek.Zero()
+	if !bytes.Equal(envelope.EekCiphertextMac, enc.HMAC(envelope.EekCiphertext, kek.HMACKey)) {
+		return ErrUnexpectedEnvelopeAuthor
+	}
+	return nil
+}
+
+// getKEK derives the KEK shared by the envelope's author and (our) reader public keys.
+func (r *receiver) getKEK(envelope *api.Envelope) (*enc.KEK, error) {
 	readerPriv, in := r.readerKeys.Get(envelope.ReaderPublicKey)
 	if !in {
 		return nil, keychain.ErrUnexpectedMissingKey
@@ -98,33 +186,44 @@ func (r *receiver) GetEEK(envelope *api.Envelope) (*enc.EEK, error) {
 	if err != nil {
 		return nil, err
 	}
-	kek, err := enc.NewKEK(readerPriv.Key(), authorPub)
-	if err != nil {
-		return nil, err
-	}
-	eek, err := kek.Decrypt(envelope.EekCiphertext, envelope.EekCiphertextMac)
-	return eek, err
+	return enc.NewKEK(readerPriv.Key(), authorPub)
 }
 
-func (r *receiver) getPages(entry *api.Document, authorPubBytes []byte) error {
+func (r *receiver) getPages(
+	ctx context.Context,
+	entry *api.Document,
+	authorPubBytes []byte,
+	search *publish.Search,
+	progress publish.Progress,
+) error {
 	if _, ok := entry.Contents.(*api.Document_Entry); !ok {
 		return api.ErrUnexpectedDocumentType
 	}
 	switch ec := entry.Contents.(*api.Document_Entry).Entry.Contents.(type) {
 	case *api.Entry_PageKeys:
+		if r.streaming {
+			return nil
+		}
 		pageKeys, err := api.GetEntryPageKeys(entry)
 		if err != nil {
 			// should never get here
 			return err
 		}
-		return r.msAcquirer.Acquire(pageKeys, authorPubBytes, r.librarians)
+		return r.msAcquirer.Acquire(ctx, pageKeys, authorPubBytes, r.librarians, search,
+			progress)
 	case *api.Entry_Page:
 		pageDoc, docKey, err := api.GetPageDocument(ec.Page)
 		if err != nil {
 			// should never get here
 			return err
 		}
-		return r.docS.Store(docKey, pageDoc)
+		if err := r.docS.Store(docKey, pageDoc); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(1, 1, uint64(proto.Size(pageDoc)))
+		}
+		return nil
 	}
 
 	// should never get here
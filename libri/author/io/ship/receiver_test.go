@@ -9,11 +9,13 @@ import (
 	"errors"
 
 	"github.com/drausin/libri/libri/author/io/pack"
+	"github.com/drausin/libri/libri/author/io/publish"
 	"github.com/drausin/libri/libri/author/keychain"
 	"github.com/drausin/libri/libri/common/ecid"
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 	"github.com/drausin/libri/libri/author/io/enc"
 )
 
@@ -66,10 +68,16 @@ func TestReceiver_ReceiveEntry_ok(t *testing.T) {
 		docS := &fixedStorer{}
 		r := NewReceiver(cb, readerKeys, acq, msAcq, docS)
 
-		entry2, eek2, err := r.ReceiveEntry(envelopeKey)
+		nDone := 0
+		progress := func(nDone1, nTotal int, bytesDone uint64) {
+			nDone = nDone1
+		}
+		search := &publish.Search{Concurrency: 8}
+		entry2, eek2, err := r.ReceiveEntry(context.Background(), envelopeKey, search, progress)
 		assert.Nil(t, err)
 		assert.Equal(t, entry1, entry2)
 		assert.Equal(t, eek1, eek2)
+		assert.Equal(t, search, acq.search)
 
 		// check that pages have been stored, if necessary
 		assert.Equal(t, pageKeys, msAcq.docKeys)
@@ -77,15 +85,60 @@ func TestReceiver_ReceiveEntry_ok(t *testing.T) {
 		case *api.Entry_PageKeys:
 			// pages would have been stored on the MultiStoreAcquirer.Acquire(...)
 			// call
+			assert.Equal(t, search, msAcq.search)
 			assert.Nil(t, docS.storedKey)
 			assert.Nil(t, docS.storedValue)
 		case *api.Entry_Page:
 			assert.NotNil(t, docS.storedKey)
 			assert.NotNil(t, docS.storedValue)
+			assert.Equal(t, 1, nDone)
 		}
 	}
 }
 
+func TestStreamingReceiver_ReceiveEntry_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorKeys, readerKeys := keychain.New(3), keychain.New(3)
+	authorKey, err := authorKeys.Sample()
+	assert.Nil(t, err)
+	readerKey, err := readerKeys.Sample()
+	assert.Nil(t, err)
+	kek, err := enc.NewKEK(authorKey.Key(), &readerKey.Key().PublicKey)
+	assert.Nil(t, err)
+	entry1 := &api.Document{
+		Contents: &api.Document_Entry{
+			Entry: api.NewTestMultiPageEntry(rng),
+		},
+	}
+	entryKey, err := api.GetKey(entry1)
+	assert.Nil(t, err)
+	eek1 := enc.NewPseudoRandomEEK(rng)
+	eekCiphertext, eekCiphertextMAC, err := kek.Encrypt(eek1)
+	assert.Nil(t, err)
+	envelope := pack.NewEnvelopeDoc(entryKey, authorKey.PublicKeyBytes(),
+		readerKey.PublicKeyBytes(), eekCiphertext, eekCiphertextMAC)
+	envelopeKey, err := api.GetKey(envelope)
+	assert.Nil(t, err)
+	acq := &fixedAcquirer{
+		docs: map[string]*api.Document{
+			entryKey.String():    entry1,
+			envelopeKey.String(): envelope,
+		},
+	}
+	msAcq := &fixedMultiStoreAcquirer{}
+	docS := &fixedStorer{}
+	r := NewStreamingReceiver(&fixedClientBalancer{}, readerKeys, acq, msAcq, docS)
+
+	entry2, eek2, err := r.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, entry1, entry2)
+	assert.Equal(t, eek1, eek2)
+
+	// check pages were left to be acquired while streaming
+	assert.Nil(t, msAcq.docKeys)
+	assert.Nil(t, docS.storedKey)
+}
+
 func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	cb := &fixedClientBalancer{}
@@ -119,7 +172,7 @@ func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	// check clientBalancer.Next() error bubbles up
 	cb1 := &fixedClientBalancer{errors.New("some Next error")}
 	r1 := NewReceiver(cb1, readerKeys, acq, msAcq, docS)
-	receivedDoc, receivedKeys, err := r1.ReceiveEntry(envelopeKey)
+	receivedDoc, receivedKeys, err := r1.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, receivedDoc)
 	assert.Nil(t, receivedKeys)
@@ -127,7 +180,7 @@ func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	// check acquire error bubbles up
 	acq2 := &fixedAcquirer{err: errors.New("some Acquire error")}
 	r2 := NewReceiver(cb, readerKeys, acq2, msAcq, docS)
-	receivedDoc, receivedKeys, err = r2.ReceiveEntry(envelopeKey)
+	receivedDoc, receivedKeys, err = r2.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, receivedDoc)
 	assert.Nil(t, receivedKeys)
@@ -136,7 +189,7 @@ func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	acq3 := &fixedAcquirer{docs: make(map[string]*api.Document)}
 	acq3.docs[envelopeKey.String()] = entry // wrong doc type
 	r3 := NewReceiver(cb, readerKeys, acq3, msAcq, docS)
-	receivedDoc, receivedKeys, err = r3.ReceiveEntry(envelopeKey)
+	receivedDoc, receivedKeys, err = r3.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, receivedDoc)
 	assert.Nil(t, receivedKeys)
@@ -147,7 +200,7 @@ func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	// in the different keychain
 	readerKeys4 := keychain.New(1)
 	r4 := NewReceiver(cb, readerKeys4, acq, msAcq, docS)
-	receivedDoc, receivedKeys, err = r4.ReceiveEntry(envelopeKey)
+	receivedDoc, receivedKeys, err = r4.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, receivedDoc)
 	assert.Nil(t, receivedKeys)
@@ -158,7 +211,7 @@ func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	acq5 := &fixedAcquirer{docs: make(map[string]*api.Document)}
 	acq5.docs[envelopeKey.String()] = envelope
 	r5 := NewReceiver(cb, readerKeys, acq5, msAcq, docS)
-	receivedDoc, receivedKeys, err = r5.ReceiveEntry(envelopeKey)
+	receivedDoc, receivedKeys, err = r5.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, receivedDoc)
 	assert.Nil(t, receivedKeys)
@@ -168,12 +221,75 @@ func TestReceiver_ReceiveEntry_err(t *testing.T) {
 	acq6.docs[envelopeKey.String()] = envelope
 	acq6.docs[entryKey.String()] = envelope // wrong doc type
 	r6 := NewReceiver(cb, readerKeys, acq6, msAcq, docS)
-	receivedDoc, receivedKeys, err = r6.ReceiveEntry(envelopeKey)
+	receivedDoc, receivedKeys, err = r6.ReceiveEntry(context.Background(), envelopeKey, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, receivedDoc)
 	assert.Nil(t, receivedKeys)
 }
 
+func TestReceiver_ReceiveEnvelope_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	cb := &fixedClientBalancer{}
+	msAcq := &fixedMultiStoreAcquirer{}
+	docS := &fixedStorer{}
+	authorKeys, readerKeys := keychain.New(1), keychain.New(1)
+	authorKey, err := authorKeys.Sample()
+	assert.Nil(t, err)
+	readerKey, err := readerKeys.Sample()
+	assert.Nil(t, err)
+	kek, err := enc.NewKEK(authorKey.Key(), &readerKey.Key().PublicKey)
+	assert.Nil(t, err)
+	eekCiphertext, eekCiphertextMAC, err := kek.Encrypt(enc.NewPseudoRandomEEK(rng))
+	assert.Nil(t, err)
+	entryKey := id.NewPseudoRandom(rng)
+
+	// check envelope under a different key triggers error
+	envelope1 := pack.NewEnvelopeDoc(entryKey, authorKey.PublicKeyBytes(),
+		readerKey.PublicKeyBytes(), eekCiphertext, eekCiphertextMAC)
+	otherEnvelopeKey := id.NewPseudoRandom(rng)
+	acq1 := &fixedAcquirer{
+		docs: map[string]*api.Document{otherEnvelopeKey.String(): envelope1},
+	}
+	r1 := NewReceiver(cb, readerKeys, acq1, msAcq, docS)
+	env, err := r1.ReceiveEnvelope(otherEnvelopeKey)
+	assert.Equal(t, ErrUnexpectedEnvelopeKey, err)
+	assert.Nil(t, env)
+
+	// check envelope with tampered author key triggers error
+	otherAuthorKey := ecid.NewPseudoRandom(rng)
+	envelope2 := pack.NewEnvelopeDoc(entryKey, otherAuthorKey.PublicKeyBytes(),
+		readerKey.PublicKeyBytes(), eekCiphertext, eekCiphertextMAC)
+	envelope2Key, err := api.GetKey(envelope2)
+	assert.Nil(t, err)
+	acq2 := &fixedAcquirer{
+		docs: map[string]*api.Document{envelope2Key.String(): envelope2},
+	}
+	r2 := NewReceiver(cb, readerKeys, acq2, msAcq, docS)
+	env, err = r2.ReceiveEnvelope(envelope2Key)
+	assert.Equal(t, ErrUnexpectedEnvelopeAuthor, err)
+	assert.Equal(t, api.InvalidSignatureErrorCode, api.ErrorCodeOf(err))
+	assert.Nil(t, env)
+
+	// check envelope with missing reader key triggers error
+	envelope3 := pack.NewEnvelopeDoc(entryKey, authorKey.PublicKeyBytes(),
+		readerKey.PublicKeyBytes(), eekCiphertext, eekCiphertextMAC)
+	envelope3Key, err := api.GetKey(envelope3)
+	assert.Nil(t, err)
+	acq3 := &fixedAcquirer{
+		docs: map[string]*api.Document{envelope3Key.String(): envelope3},
+	}
+	r3 := NewReceiver(cb, keychain.New(1), acq3, msAcq, docS)
+	env, err = r3.ReceiveEnvelope(envelope3Key)
+	assert.Equal(t, keychain.ErrUnexpectedMissingKey, err)
+	assert.Nil(t, env)
+
+	// check untampered envelope is received
+	r4 := NewReceiver(cb, readerKeys, acq3, msAcq, docS)
+	env, err = r4.ReceiveEnvelope(envelope3Key)
+	assert.Nil(t, err)
+	assert.Equal(t, envelope3.Contents.(*api.Document_Envelope).Envelope, env)
+}
+
 func TestReceiver_GetEEK_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	cb := &fixedClientBalancer{}
@@ -234,12 +350,15 @@ func TestReceiver_GetEEK_err(t *testing.T) {
 }
 
 type fixedAcquirer struct {
-	docs map[string]*api.Document
-	err  error
+	docs   map[string]*api.Document
+	err    error
+	search *publish.Search
 }
 
-func (f *fixedAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (
-	*api.Document, error) {
+func (f *fixedAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *publish.Search,
+) (*api.Document, error) {
+	f.search = search
 	value, in := f.docs[docKey.String()]
 	if !in {
 		return nil, errors.New("missing")
@@ -251,12 +370,18 @@ type fixedMultiStoreAcquirer struct {
 	err       error
 	docKeys   []id.ID
 	authorPub []byte
+	search    *publish.Search
 }
 
 func (f *fixedMultiStoreAcquirer) Acquire(
-	docKeys []id.ID, authorPub []byte, cb api.ClientBalancer,
+	ctx context.Context,
+	docKeys []id.ID,
+	authorPub []byte,
+	cb api.ClientBalancer,
+	search *publish.Search,
+	progress publish.Progress,
 ) error {
-	f.docKeys, f.authorPub = docKeys, authorPub
+	f.docKeys, f.authorPub, f.search = docKeys, authorPub, search
 	return f.err
 }
 
@@ -1,26 +1,61 @@
 package ship
 
 import (
+	"errors"
+
 	"github.com/drausin/libri/libri/author/io/pack"
 	"github.com/drausin/libri/libri/author/io/publish"
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
 )
 
 // Shipper publishes documents to libri.
 type Shipper interface {
 	// ShipEntry publishes (to libri) the entry document, its page document keys (if more than one),
 	// and the envelope document with the author and reader public keys. It returns the
-	// published envelope document and its key.
+	// published envelope document, its key, and the publish.Result describing where the entry was
+	// stored. If progress is not nil, it is called after each page is published. Once ctx is
+	// done, no further pages are published and ctx.Err() is returned. The number of replicas
+	// librarians store of each published document is given by repl.
 	ShipEntry(
-		entry *api.Document, authorPub []byte, readerPub []byte, kek *enc.KEK, eek *enc.EEK,
+		ctx context.Context,
+		entry *api.Document,
+		authorPub []byte,
+		readerPub []byte,
+		kek *enc.KEK,
+		eek *enc.EEK,
+		repl *publish.Replication,
+		progress publish.Progress,
+	) (*api.Document, id.ID, *publish.Result, error)
+
+	ShipEnvelope(
+		kek *enc.KEK,
+		eek *enc.EEK,
+		entryKey id.ID,
+		authorPub []byte,
+		readerPub []byte,
+		repl *publish.Replication,
 	) (*api.Document, id.ID, error)
 
-	ShipEnvelope(kek *enc.KEK, eek *enc.EEK, entryKey id.ID, authorPub, readerPub []byte) (
-		*api.Document, id.ID, error)
+	// ShipEnvelopes publishes an envelope for each reader of an already published entry, all
+	// sharing the same EEK but each with its own KEK from keks. It returns the published envelope
+	// documents and their keys in the same order as the reader public keys.
+	ShipEnvelopes(
+		keks []*enc.KEK,
+		eek *enc.EEK,
+		entryKey id.ID,
+		authorPub []byte,
+		readerPubs [][]byte,
+		repl *publish.Replication,
+	) ([]*api.Document, []id.ID, error)
 }
 
+// ErrKEKReaderCountMismatch indicates when the number of KEKs and reader public keys differ.
+var ErrKEKReaderCountMismatch = errors.New("number of KEKs and reader public keys differ")
+
 type shipper struct {
 	librarians  api.ClientBalancer
 	publisher   publish.Publisher
@@ -42,34 +77,55 @@ func NewShipper(
 }
 
 func (s *shipper) ShipEntry(
-	entry *api.Document, authorPub []byte, readerPub []byte, kek *enc.KEK, eek *enc.EEK,
-) (*api.Document, id.ID, error) {
+	ctx context.Context,
+	entry *api.Document,
+	authorPub []byte,
+	readerPub []byte,
+	kek *enc.KEK,
+	eek *enc.EEK,
+	repl *publish.Replication,
+	progress publish.Progress,
+) (*api.Document, id.ID, *publish.Result, error) {
 
 	// publish separate pages, if necessary
 	pageKeys, err := api.GetEntryPageKeys(entry)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	if pageKeys != nil {
-		err = s.mlPublisher.Publish(pageKeys, authorPub, s.librarians, s.deletePages)
+		err = s.mlPublisher.Publish(ctx, pageKeys, authorPub, s.librarians, repl,
+			s.deletePages, progress)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 	}
 
 	lc, err := s.librarians.Next()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	entryKey, err := s.publisher.Publish(entry, authorPub, lc)
+	entryKey, result, err := s.publisher.Publish(entry, authorPub, lc, repl)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	return s.ShipEnvelope(kek, eek, entryKey, authorPub, readerPub)
+	if pageKeys == nil && progress != nil {
+		// single page is contained in the entry itself
+		progress(1, 1, uint64(proto.Size(entry)))
+	}
+	envelope, envelopeKey, err := s.ShipEnvelope(kek, eek, entryKey, authorPub, readerPub, repl)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return envelope, envelopeKey, result, nil
 }
 
 func (s *shipper) ShipEnvelope(
-	kek *enc.KEK, eek *enc.EEK, entryKey id.ID, authorPub, readerPub []byte,
+	kek *enc.KEK,
+	eek *enc.EEK,
+	entryKey id.ID,
+	authorPub []byte,
+	readerPub []byte,
+	repl *publish.Replication,
 ) (*api.Document, id.ID, error) {
 
 	lc, err := s.librarians.Next()
@@ -81,9 +137,34 @@ func (s *shipper) ShipEnvelope(
 		return nil, nil, err
 	}
 	envelope := pack.NewEnvelopeDoc(entryKey, authorPub, readerPub, eekCiphertext, eekCiphertextMAC)
-	envelopeKey, err := s.publisher.Publish(envelope, authorPub, lc)
+	envelopeKey, _, err := s.publisher.Publish(envelope, authorPub, lc, repl)
 	if err != nil {
 		return nil, nil, err
 	}
 	return envelope, envelopeKey, nil
 }
+
+func (s *shipper) ShipEnvelopes(
+	keks []*enc.KEK,
+	eek *enc.EEK,
+	entryKey id.ID,
+	authorPub []byte,
+	readerPubs [][]byte,
+	repl *publish.Replication,
+) ([]*api.Document, []id.ID, error) {
+
+	if len(keks) != len(readerPubs) {
+		return nil, nil, ErrKEKReaderCountMismatch
+	}
+	envelopes := make([]*api.Document, len(readerPubs))
+	envelopeKeys := make([]id.ID, len(readerPubs))
+	for i, readerPub := range readerPubs {
+		envelope, envelopeKey, err := s.ShipEnvelope(keks[i], eek, entryKey, authorPub,
+			readerPub, repl)
+		if err != nil {
+			return nil, nil, err
+		}
+		envelopes[i], envelopeKeys[i] = envelope, envelopeKey
+	}
+	return envelopes, envelopeKeys, nil
+}
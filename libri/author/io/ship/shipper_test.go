@@ -12,16 +12,19 @@ import (
 	"github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestShipper_Ship_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	kek, authorPub, readerPub := enc.NewPseudoRandomKEK(rng)
 	eek := enc.NewPseudoRandomEEK(rng)
+	entryResult := &publish.Result{NReplicas: 3, ReplicaPeerIDs: []id.ID{id.NewPseudoRandom(rng)}}
+	pub := &fixedPublisher{result: entryResult}
 	mlPub := &fixedMultiLoadPublisher{}
 	s := NewShipper(
 		&fixedClientBalancer{},
-		&fixedPublisher{},
+		pub,
 		mlPub,
 	)
 	entry := &api.Document{
@@ -33,13 +36,18 @@ func TestShipper_Ship_ok(t *testing.T) {
 	assert.Nil(t, err)
 
 	// test multi-page ship
-	envelope, envelopeKey, err := s.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	repl := &publish.Replication{NReplicas: 6}
+	envelope, envelopeKey, result, err := s.ShipEntry(context.Background(), entry, authorPub,
+		readerPub, kek, eek, repl, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, envelope)
 	assert.NotNil(t, envelopeKey)
+	assert.Equal(t, entryResult, result)
 	assert.Equal(t, origEntryKey.Bytes(),
 		envelope.Contents.(*api.Document_Envelope).Envelope.EntryKey)
 	assert.True(t, mlPub.deleted)
+	assert.Equal(t, repl, mlPub.repl)
+	assert.Equal(t, repl, pub.repl)
 
 	// test single-page ship
 	entry = &api.Document{
@@ -49,8 +57,15 @@ func TestShipper_Ship_ok(t *testing.T) {
 	}
 	origEntryKey, err = api.GetKey(entry)
 	assert.Nil(t, err)
-	envelope, envelopeKey, err = s.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	var nDone, nTotal int
+	progress := func(nDone1, nTotal1 int, bytesDone uint64) {
+		nDone, nTotal = nDone1, nTotal1
+	}
+	envelope, envelopeKey, _, err = s.ShipEntry(context.Background(), entry, authorPub, readerPub,
+		kek, eek, nil, progress)
 	assert.Nil(t, err)
+	assert.Equal(t, 1, nDone)
+	assert.Equal(t, 1, nTotal)
 	assert.NotNil(t, envelope)
 	assert.NotNil(t, envelopeKey)
 	assert.Equal(t, origEntryKey.Bytes(),
@@ -79,13 +94,15 @@ func TestShipper_Ship_err(t *testing.T) {
 			Envelope: api.NewTestEnvelope(rng),
 		},
 	}
-	envelope, entryKey, err := s.ShipEntry(envelope, authorPub, readerPub, kek, eek)
+	envelope, entryKey, _, err := s.ShipEntry(context.Background(), envelope, authorPub,
+		readerPub, kek, eek, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, envelope)
 	assert.Nil(t, entryKey)
 
 	// check page publish error bubbles up
-	envelope, entryKey, err = s.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	envelope, entryKey, _, err = s.ShipEntry(context.Background(), entry, authorPub, readerPub,
+		kek, eek, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, envelope)
 	assert.Nil(t, entryKey)
@@ -96,7 +113,8 @@ func TestShipper_Ship_err(t *testing.T) {
 		&fixedPublisher{},
 		&fixedMultiLoadPublisher{},
 	)
-	envelope, entryKey, err = s.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	envelope, entryKey, _, err = s.ShipEntry(context.Background(), entry, authorPub, readerPub,
+		kek, eek, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, envelope)
 	assert.Nil(t, entryKey)
@@ -104,10 +122,11 @@ func TestShipper_Ship_err(t *testing.T) {
 	// check entry publish error bubbles up
 	s = NewShipper(
 		&fixedClientBalancer{},
-		&fixedPublisher{[]error{errors.New("some Publish error")}},
+		&fixedPublisher{errs: []error{errors.New("some Publish error")}},
 		&fixedMultiLoadPublisher{},
 	)
-	envelope, entryKey, err = s.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	envelope, entryKey, _, err = s.ShipEntry(context.Background(), entry, authorPub, readerPub,
+		kek, eek, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, envelope)
 	assert.Nil(t, entryKey)
@@ -118,7 +137,8 @@ func TestShipper_Ship_err(t *testing.T) {
 		&fixedPublisher{},
 		&fixedMultiLoadPublisher{},
 	)
-	envelope, entryKey, err = s.ShipEntry(entry, authorPub, readerPub, &enc.KEK{}, eek)
+	envelope, entryKey, _, err = s.ShipEntry(context.Background(), entry, authorPub, readerPub,
+		&enc.KEK{}, eek, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, envelope)
 	assert.Nil(t, entryKey)
@@ -126,15 +146,78 @@ func TestShipper_Ship_err(t *testing.T) {
 	// check envelope publish error bubbles up
 	s = NewShipper(
 		&fixedClientBalancer{},
-		&fixedPublisher{[]error{nil, errors.New("some Publish error")}},
+		&fixedPublisher{errs: []error{nil, errors.New("some Publish error")}},
 		&fixedMultiLoadPublisher{},
 	)
-	envelope, entryKey, err = s.ShipEntry(entry, authorPub, readerPub, kek, eek)
+	envelope, entryKey, _, err = s.ShipEntry(context.Background(), entry, authorPub, readerPub,
+		kek, eek, nil, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, envelope)
 	assert.Nil(t, entryKey)
 }
 
+func TestShipper_ShipEnvelopes_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	eek := enc.NewPseudoRandomEEK(rng)
+	entryKey := id.NewPseudoRandom(rng)
+	nReaders := 3
+	keks, readerPubs := make([]*enc.KEK, nReaders), make([][]byte, nReaders)
+	var authorPub []byte
+	for i := range keks {
+		keks[i], authorPub, readerPubs[i] = enc.NewPseudoRandomKEK(rng)
+	}
+	pub := &fixedPublisher{}
+	s := NewShipper(&fixedClientBalancer{}, pub, &fixedMultiLoadPublisher{})
+
+	repl := &publish.Replication{NReplicas: 6}
+	envelopes, envelopeKeys, err := s.ShipEnvelopes(keks, eek, entryKey, authorPub, readerPubs,
+		repl)
+	assert.Nil(t, err)
+	assert.Len(t, envelopes, nReaders)
+	assert.Len(t, envelopeKeys, nReaders)
+	assert.Equal(t, repl, pub.repl)
+	for i, envelope := range envelopes {
+		env := envelope.Contents.(*api.Document_Envelope).Envelope
+		assert.Equal(t, entryKey.Bytes(), env.EntryKey)
+		assert.Equal(t, readerPubs[i], env.ReaderPublicKey)
+		envelopeKey, err := api.GetKey(envelope)
+		assert.Nil(t, err)
+		assert.Equal(t, envelopeKey, envelopeKeys[i])
+
+		// check each reader's KEK decrypts the shared EEK
+		eek2, err := keks[i].Decrypt(env.EekCiphertext, env.EekCiphertextMac)
+		assert.Nil(t, err)
+		assert.Equal(t, eek, eek2)
+	}
+}
+
+func TestShipper_ShipEnvelopes_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	eek := enc.NewPseudoRandomEEK(rng)
+	entryKey := id.NewPseudoRandom(rng)
+	kek, authorPub, readerPub := enc.NewPseudoRandomKEK(rng)
+	s := NewShipper(&fixedClientBalancer{}, &fixedPublisher{}, &fixedMultiLoadPublisher{})
+
+	// check mismatched number of KEKs and readers gives error
+	envelopes, envelopeKeys, err := s.ShipEnvelopes([]*enc.KEK{kek}, eek, entryKey, authorPub,
+		[][]byte{readerPub, readerPub}, nil)
+	assert.Equal(t, ErrKEKReaderCountMismatch, err)
+	assert.Nil(t, envelopes)
+	assert.Nil(t, envelopeKeys)
+
+	// check envelope publish error bubbles up
+	s = NewShipper(
+		&fixedClientBalancer{},
+		&fixedPublisher{errs: []error{nil, errors.New("some Publish error")}},
+		&fixedMultiLoadPublisher{},
+	)
+	envelopes, envelopeKeys, err = s.ShipEnvelopes([]*enc.KEK{kek, kek}, eek, entryKey,
+		authorPub, [][]byte{readerPub, readerPub}, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, envelopes)
+	assert.Nil(t, envelopeKeys)
+}
+
 func TestShipReceive(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	cb := &fixedClientBalancer{}
@@ -201,7 +284,8 @@ func TestShipReceive(t *testing.T) {
 		eek := enc.NewPseudoRandomEEK(rng)
 		envelopeKeys := make([]id.ID, nDocs)
 		for i := uint32(0); i < nDocs; i++ {
-			envelope, _, err := s.ShipEntry(docs[i], authorPub, readerPub, kek, eek)
+			envelope, _, _, err := s.ShipEntry(context.Background(), docs[i], authorPub,
+				readerPub, kek, eek, nil, nil)
 			assert.Nil(t, err)
 			envelopeKeys[i], err = api.GetKey(envelope)
 			assert.Nil(t, err)
@@ -217,7 +301,7 @@ func TestShipReceive(t *testing.T) {
 		)
 		r := NewReceiver(cb, readerKeys, pubAcq, msA, docSL2)
 		for i := uint32(0); i < nDocs; i++ {
-			entry, _, err := r.ReceiveEntry(envelopeKeys[i])
+			entry, _, err := r.ReceiveEntry(context.Background(), envelopeKeys[i], nil, nil)
 			assert.Equal(t, docs[i], entry)
 			assert.Nil(t, err)
 			entryKey, err := api.GetKey(entry)
@@ -239,31 +323,38 @@ func TestShipReceive(t *testing.T) {
 type fixedMultiLoadPublisher struct {
 	err     error
 	deleted bool
+	repl    *publish.Replication
 }
 
 func (f *fixedMultiLoadPublisher) Publish(
-	docKeys []id.ID, authorPub []byte, cb api.ClientBalancer, delete bool,
+	ctx context.Context, docKeys []id.ID, authorPub []byte, cb api.ClientBalancer,
+	repl *publish.Replication, delete bool, progress publish.Progress,
 ) error {
 	f.deleted = delete
+	f.repl = repl
 	return f.err
 }
 
 type fixedPublisher struct {
-	errs []error
+	errs   []error
+	repl   *publish.Replication
+	result *publish.Result
 }
 
-func (f *fixedPublisher) Publish(doc *api.Document, authorPub []byte, lc api.Putter) (
-	id.ID, error) {
+func (f *fixedPublisher) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *publish.Replication,
+) (id.ID, *publish.Result, error) {
+	f.repl = repl
 	docID, err := api.GetKey(doc)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if f.errs == nil {
-		return docID, nil
+		return docID, f.result, nil
 	}
 	nextErr := f.errs[0]
 	f.errs = f.errs[1:]
-	return docID, nextErr
+	return docID, f.result, nextErr
 }
 
 type fixedClientBalancer struct {
@@ -310,8 +401,9 @@ type memPublisherAcquirer struct {
 	mu   sync.Mutex
 }
 
-func (p *memPublisherAcquirer) Publish(doc *api.Document, authorPub []byte, lc api.Putter) (
-	id.ID, error) {
+func (p *memPublisherAcquirer) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *publish.Replication,
+) (id.ID, *publish.Result, error) {
 	docKey, err := api.GetKey(doc)
 	if err != nil {
 		panic(err)
@@ -319,11 +411,12 @@ func (p *memPublisherAcquirer) Publish(doc *api.Document, authorPub []byte, lc a
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.docs[docKey.String()] = doc
-	return docKey, nil
+	return docKey, &publish.Result{}, nil
 }
 
-func (p *memPublisherAcquirer) Acquire(docKey id.ID, authorPub []byte, lc api.Getter) (
-	*api.Document, error) {
+func (p *memPublisherAcquirer) Acquire(
+	docKey id.ID, authorPub []byte, lc api.Getter, search *publish.Search,
+) (*api.Document, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.docs[docKey.String()], nil
@@ -0,0 +1,57 @@
+package keychain
+
+import (
+	"sync"
+
+	"github.com/drausin/libri/libri/common/ecid"
+)
+
+// CountingGetterSampler is a GetterSampler that counts how many times each of its keys has been
+// sampled.
+type CountingGetterSampler interface {
+	GetterSampler
+
+	// Counts returns the number of times each key has been sampled, indexed by the hex of its
+	// 65-byte public key representation. Keys that have never been sampled are absent.
+	Counts() map[string]int
+}
+
+type counting struct {
+	inner  GetterSampler
+	counts map[string]int
+	mu     sync.Mutex
+}
+
+// NewCounting returns a CountingGetterSampler wrapping the inner GetterSampler. It is safe for
+// concurrent use.
+func NewCounting(inner GetterSampler) CountingGetterSampler {
+	return &counting{
+		inner:  inner,
+		counts: make(map[string]int),
+	}
+}
+
+func (c *counting) Sample() (ecid.ID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, err := c.inner.Sample()
+	if err != nil {
+		return nil, err
+	}
+	c.counts[pubKeyString(key.PublicKeyBytes())]++
+	return key, nil
+}
+
+func (c *counting) Get(publicKey []byte) (ecid.ID, bool) {
+	return c.inner.Get(publicKey)
+}
+
+func (c *counting) Counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.counts))
+	for pub, n := range c.counts {
+		counts[pub] = n
+	}
+	return counts
+}
@@ -0,0 +1,63 @@
+package keychain
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounting_Sample_ok(t *testing.T) {
+	inner := New(3)
+	kc := NewCounting(inner)
+	nSamplers, nSamples := 4, 25
+
+	wg := new(sync.WaitGroup)
+	for c := 0; c < nSamplers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < nSamples; i++ {
+				key, err := kc.Sample()
+				assert.Nil(t, err)
+				assert.NotNil(t, key)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := kc.Counts()
+	total := 0
+	for pub, n := range counts {
+		assert.Contains(t, inner.(*keychain).pubs, pub)
+		total += n
+	}
+	assert.Equal(t, nSamplers*nSamples, total)
+
+	// check returned counts are a copy
+	for pub := range counts {
+		counts[pub] = 0
+	}
+	assert.NotEqual(t, counts, kc.Counts())
+}
+
+func TestCounting_Sample_err(t *testing.T) {
+	kc := NewCounting(New(0))
+	key, err := kc.Sample()
+	assert.Equal(t, ErrEmptyKeychain, err)
+	assert.Nil(t, key)
+	assert.Len(t, kc.Counts(), 0)
+}
+
+func TestCounting_Get(t *testing.T) {
+	inner := New(3)
+	kc := NewCounting(inner)
+	key, err := inner.Sample()
+	assert.Nil(t, err)
+
+	// check Get passes through to inner and isn't counted
+	key2, in := kc.Get(key.PublicKeyBytes())
+	assert.True(t, in)
+	assert.Equal(t, key, key2)
+	assert.Len(t, kc.Counts(), 0)
+}
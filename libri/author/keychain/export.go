@@ -0,0 +1,218 @@
+package keychain
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// An exported keychain is a byte container with the following layout, all integers big-endian:
+//
+//	magic      8 bytes    "LIBRIKCX"
+//	version    1 byte     currently 1
+//	scrypt N   4 bytes    uint32
+//	scrypt r   4 bytes    uint32
+//	scrypt p   4 bytes    uint32
+//	salt       32 bytes
+//	nonce      12 bytes
+//	ciphertext remainder
+//
+// The ciphertext is the AES-256-GCM encryption, using the previous fields as additional
+// authenticated data, of a marshaled StoredKeychain whose PrivateKeys are each a marshaled
+// ecid.ECDSAPrivateKey. The AES key is scrypt(passphrase, salt, N, r, p) with 32 byte output.
+// Keys are stored in keychain order, so importing an export yields a keychain that samples keys
+// identically.
+
+const (
+	// ExportVersion is the current version of the exported keychain format.
+	ExportVersion = byte(1)
+
+	exportSaltLength   = 32
+	exportNonceLength  = 12
+	exportAESKeyLength = 32
+	exportScryptR      = 8
+
+	// maxExportScryptN, maxExportScryptR, and maxExportScryptP bound the scrypt parameters read
+	// from an export to prevent excessive CPU and memory use
+	maxExportScryptN = 1 << 22
+	maxExportScryptR = 32
+	maxExportScryptP = 16
+
+	// maxExportScryptMemory bounds the scrypt memory use (128 * N * r bytes) of an export
+	maxExportScryptMemory = 1 << 30
+)
+
+var (
+	// ErrUnexportableKeychain indicates when a keychain's keys cannot be enumerated for export.
+	ErrUnexportableKeychain = errors.New("keychain cannot be exported")
+
+	// ErrNotExportedKeychain indicates when the data to import does not start with the exported
+	// keychain magic header.
+	ErrNotExportedKeychain = errors.New("not an exported keychain")
+
+	// ErrUnsupportedExportVersion indicates when the exported keychain version is unknown.
+	ErrUnsupportedExportVersion = errors.New("unsupported exported keychain version")
+
+	// ErrInvalidExportParams indicates when the exported keychain scrypt parameters are invalid.
+	ErrInvalidExportParams = errors.New("invalid exported keychain scrypt parameters")
+
+	// ErrIncorrectPassphrase indicates when an exported keychain cannot be decrypted with the
+	// given passphrase.
+	ErrIncorrectPassphrase = errors.New("incorrect exported keychain passphrase")
+
+	exportMagic = []byte("LIBRIKCX")
+
+	// scrypt parameters used when exporting keychains
+	exportScryptN = StandardScryptN
+	exportScryptP = StandardScryptP
+)
+
+// exportHeaderLength is the length of the container fields preceding the ciphertext.
+var exportHeaderLength = len(exportMagic) + 1 + 3*4 + exportSaltLength + exportNonceLength
+
+// Export encrypts the keys of the keychain with the passphrase and writes them to w in the
+// versioned exported keychain format. Keychains wrapped by NewCounting are exported as their
+// inner keychain.
+func Export(kc Getter, w io.Writer, passphrase string) error {
+	kc1, ok := unwrapKeychain(kc)
+	if !ok {
+		return ErrUnexportableKeychain
+	}
+	stored := &StoredKeychain{
+		PrivateKeys: make([][]byte, len(kc1.pubs)),
+	}
+	for i, pub := range kc1.pubs {
+		keyBytes, err := proto.Marshal(ecid.ToStored(kc1.privs[pub]))
+		if err != nil {
+			return err
+		}
+		stored.PrivateKeys[i] = keyBytes
+	}
+	plaintext, err := proto.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	header := new(bytes.Buffer)
+	header.Write(exportMagic)
+	header.WriteByte(ExportVersion)
+	for _, param := range []int{exportScryptN, exportScryptR, exportScryptP} {
+		if err := binary.Write(header, binary.BigEndian, uint32(param)); err != nil {
+			return err
+		}
+	}
+	saltNonce := make([]byte, exportSaltLength+exportNonceLength)
+	if _, err := rand.Read(saltNonce); err != nil {
+		return err
+	}
+	header.Write(saltNonce)
+	salt, nonce := saltNonce[:exportSaltLength], saltNonce[exportSaltLength:]
+
+	gcm, err := newExportCipher(passphrase, salt, exportScryptN, exportScryptR, exportScryptP)
+	if err != nil {
+		return err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, header.Bytes())
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err = w.Write(ciphertext)
+	return err
+}
+
+// Import reads a keychain in the exported keychain format from r and decrypts it with the
+// passphrase.
+func Import(r io.Reader, passphrase string) (GetterSampler, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < exportHeaderLength || !bytes.Equal(buf[:len(exportMagic)], exportMagic) {
+		return nil, ErrNotExportedKeychain
+	}
+	header, ciphertext := buf[:exportHeaderLength], buf[exportHeaderLength:]
+	fields := header[len(exportMagic):]
+	if fields[0] != ExportVersion {
+		return nil, ErrUnsupportedExportVersion
+	}
+	fields = fields[1:]
+	scryptN := int(binary.BigEndian.Uint32(fields[0:4]))
+	scryptR := int(binary.BigEndian.Uint32(fields[4:8]))
+	scryptP := int(binary.BigEndian.Uint32(fields[8:12]))
+	if !validExportScryptParams(scryptN, scryptR, scryptP) {
+		return nil, ErrInvalidExportParams
+	}
+	fields = fields[12:]
+	salt, nonce := fields[:exportSaltLength], fields[exportSaltLength:]
+
+	gcm, err := newExportCipher(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	stored := &StoredKeychain{}
+	if err := proto.Unmarshal(plaintext, stored); err != nil {
+		return nil, err
+	}
+	ecids := make([]ecid.ID, len(stored.PrivateKeys))
+	for i, keyBytes := range stored.PrivateKeys {
+		storedKey := &ecid.ECDSAPrivateKey{}
+		if err := proto.Unmarshal(keyBytes, storedKey); err != nil {
+			return nil, err
+		}
+		if ecids[i], err = ecid.FromStored(storedKey); err != nil {
+			return nil, err
+		}
+	}
+	return FromECIDs(ecids), nil
+}
+
+// unwrapKeychain returns the *keychain underlying kc and whether there is one.
+func unwrapKeychain(kc Getter) (*keychain, bool) {
+	switch kc1 := kc.(type) {
+	case *keychain:
+		return kc1, true
+	case *counting:
+		return unwrapKeychain(kc1.inner)
+	}
+	return nil, false
+}
+
+// validExportScryptParams returns whether the scrypt parameters read from an export are within
+// the bounds on CPU and memory use, which a hostile export could otherwise set arbitrarily high.
+func validExportScryptParams(scryptN, scryptR, scryptP int) bool {
+	if scryptN <= 0 || scryptN > maxExportScryptN {
+		return false
+	}
+	if scryptR <= 0 || scryptR > maxExportScryptR || scryptP <= 0 || scryptP > maxExportScryptP {
+		return false
+	}
+	return 128*int64(scryptN)*int64(scryptR) <= maxExportScryptMemory
+}
+
+func newExportCipher(passphrase string, salt []byte, scryptN, scryptR, scryptP int) (
+	cipher.AEAD, error) {
+	aesKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP,
+		exportAESKeyLength)
+	if err != nil {
+		// scrypt validates N, r, and p
+		return nil, ErrInvalidExportParams
+	}
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
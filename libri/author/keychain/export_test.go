@@ -0,0 +1,124 @@
+package keychain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	exportScryptN, exportScryptP = LightScryptN, LightScryptP
+	defer func() { exportScryptN, exportScryptP = StandardScryptN, StandardScryptP }()
+
+	kc1 := New(4)
+	buf := new(bytes.Buffer)
+	err := Export(kc1, buf, "some passphrase")
+	assert.Nil(t, err)
+	assert.Equal(t, exportMagic, buf.Bytes()[:len(exportMagic)])
+	assert.Equal(t, ExportVersion, buf.Bytes()[len(exportMagic)])
+
+	kc2, err := Import(buf, "some passphrase")
+	assert.Nil(t, err)
+
+	// check key ordering is preserved
+	assert.Equal(t, kc1.(*keychain).pubs, kc2.(*keychain).pubs)
+	for _, pub := range kc1.(*keychain).pubs {
+		priv1, priv2 := kc1.(*keychain).privs[pub], kc2.(*keychain).privs[pub]
+		assert.Equal(t, priv1.Key().D.Bytes(), priv2.Key().D.Bytes())
+	}
+
+	// check sampling is unchanged
+	for c := 0; c < 8; c++ {
+		k1, err := kc1.Sample()
+		assert.Nil(t, err)
+		k2, err := kc2.Sample()
+		assert.Nil(t, err)
+		assert.Equal(t, k1.PublicKeyBytes(), k2.PublicKeyBytes())
+	}
+}
+
+func TestExport_counting(t *testing.T) {
+	exportScryptN, exportScryptP = LightScryptN, LightScryptP
+	defer func() { exportScryptN, exportScryptP = StandardScryptN, StandardScryptP }()
+
+	// check (nested) counting keychains are exported as their inner keychain
+	kc1 := New(3)
+	for _, wrapped := range []Getter{NewCounting(kc1), NewCounting(NewCounting(kc1))} {
+		buf := new(bytes.Buffer)
+		err := Export(wrapped, buf, "some passphrase")
+		assert.Nil(t, err)
+		kc2, err := Import(buf, "some passphrase")
+		assert.Nil(t, err)
+		assert.Equal(t, kc1.(*keychain).pubs, kc2.(*keychain).pubs)
+	}
+}
+
+func TestExport_err(t *testing.T) {
+	// check non-keychain Getter isn't exportable
+	err := Export(NewUnion(New(1)), new(bytes.Buffer), "some passphrase")
+	assert.Equal(t, ErrUnexportableKeychain, err)
+}
+
+func TestImport_err(t *testing.T) {
+	exportScryptN, exportScryptP = LightScryptN, LightScryptP
+	defer func() { exportScryptN, exportScryptP = StandardScryptN, StandardScryptP }()
+	buf := new(bytes.Buffer)
+	err := Export(New(2), buf, "some passphrase")
+	assert.Nil(t, err)
+	exported := buf.Bytes()
+
+	// check bad magic header triggers error
+	kc, err := Import(bytes.NewReader([]byte("not a keychain")), "some passphrase")
+	assert.Equal(t, ErrNotExportedKeychain, err)
+	assert.Nil(t, kc)
+
+	// check unknown version triggers error
+	exported2 := append([]byte{}, exported...)
+	exported2[len(exportMagic)] = ExportVersion + 1
+	kc, err = Import(bytes.NewReader(exported2), "some passphrase")
+	assert.Equal(t, ErrUnsupportedExportVersion, err)
+	assert.Nil(t, kc)
+
+	// check invalid scrypt params trigger error
+	exported3 := append([]byte{}, exported...)
+	exported3[len(exportMagic)+1] = 0xff // very large N
+	kc, err = Import(bytes.NewReader(exported3), "some passphrase")
+	assert.Equal(t, ErrInvalidExportParams, err)
+	assert.Nil(t, kc)
+
+	// check scrypt params with excessive CPU or memory use trigger error
+	cases := []struct {
+		scryptN, scryptR, scryptP uint32
+	}{
+		{0, exportScryptR, 1},                   // zero N
+		{1 << 14, 0, 1},                         // zero r
+		{1 << 14, exportScryptR, 0},             // zero p
+		{1 << 14, maxExportScryptR + 1, 1},      // very large r
+		{1 << 14, exportScryptR, 1 << 20},       // very large p
+		{maxExportScryptN, maxExportScryptR, 1}, // very large N * r
+	}
+	for i, c := range cases {
+		exported4 := append([]byte{}, exported...)
+		fields := exported4[len(exportMagic)+1:]
+		binary.BigEndian.PutUint32(fields[0:4], c.scryptN)
+		binary.BigEndian.PutUint32(fields[4:8], c.scryptR)
+		binary.BigEndian.PutUint32(fields[8:12], c.scryptP)
+		kc, err = Import(bytes.NewReader(exported4), "some passphrase")
+		assert.Equal(t, ErrInvalidExportParams, err, "case %d", i)
+		assert.Nil(t, kc, "case %d", i)
+	}
+
+	// check wrong passphrase triggers error
+	kc, err = Import(bytes.NewReader(exported), "some other passphrase")
+	assert.Equal(t, ErrIncorrectPassphrase, err)
+	assert.Nil(t, kc)
+
+	// check tampered ciphertext triggers error
+	exported5 := append([]byte{}, exported...)
+	exported5[len(exported5)-1] ^= 0xff
+	kc, err = Import(bytes.NewReader(exported5), "some passphrase")
+	assert.Equal(t, ErrIncorrectPassphrase, err)
+	assert.Nil(t, kc)
+}
@@ -0,0 +1,122 @@
+package keychain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"golang.org/x/crypto/hkdf"
+)
+
+// A mnemonic is a BIP39-style sequence of words, each encoding a single byte via mnemonicWords,
+// with the following layout:
+//
+//	version    1 word     currently 1
+//	entropy    16 words
+//	checksum   1 word     first byte of SHA-256(version || entropy)
+//
+// Unlike BIP39, the words come from libri's own list, so mnemonics are not interchangeable with
+// other wallets. The version determines how keys are derived from the entropy, so the derivation
+// can change without breaking recovery of existing mnemonics.
+
+const (
+	// MnemonicVersion is the current version of mnemonic key derivation.
+	MnemonicVersion = byte(1)
+
+	// MnemonicLength is the number of words in a mnemonic.
+	MnemonicLength = 1 + mnemonicEntropyLength + 1
+
+	mnemonicEntropyLength = 16
+
+	// mnemonicV1Salt is the HKDF salt used to derive keys from version 1 mnemonics
+	mnemonicV1Salt = "libri keychain mnemonic v1"
+)
+
+var (
+	// ErrInvalidMnemonicLength indicates when a mnemonic does not have MnemonicLength words.
+	ErrInvalidMnemonicLength = errors.New("invalid mnemonic length")
+
+	// ErrInvalidMnemonicWord indicates when a mnemonic contains an unknown word.
+	ErrInvalidMnemonicWord = errors.New("invalid mnemonic word")
+
+	// ErrInvalidMnemonicChecksum indicates when a mnemonic's checksum word does not match its
+	// other words, usually because of a mistyped or reordered word.
+	ErrInvalidMnemonicChecksum = errors.New("invalid mnemonic checksum")
+
+	// ErrUnsupportedMnemonicVersion indicates when the mnemonic version is unknown.
+	ErrUnsupportedMnemonicVersion = errors.New("unsupported mnemonic version")
+
+	// mnemonicWordIndices is the inverse of mnemonicWords
+	mnemonicWordIndices = make(map[string]byte)
+)
+
+func init() {
+	for i, word := range mnemonicWords {
+		mnemonicWordIndices[word] = byte(i)
+	}
+}
+
+// NewMnemonic generates a new mnemonic of the current MnemonicVersion with entropy read from rng,
+// usually crypto/rand.Reader.
+func NewMnemonic(rng io.Reader) ([]string, error) {
+	buf := make([]byte, 1+mnemonicEntropyLength+1)
+	buf[0] = MnemonicVersion
+	if _, err := io.ReadFull(rng, buf[1:1+mnemonicEntropyLength]); err != nil {
+		return nil, err
+	}
+	buf[len(buf)-1] = mnemonicChecksum(buf[:len(buf)-1])
+
+	words := make([]string, len(buf))
+	for i, b := range buf {
+		words[i] = mnemonicWords[b]
+	}
+	return words, nil
+}
+
+// FromMnemonic deterministically derives a keychain with n keys from the mnemonic words. The same
+// mnemonic and n always give the same keychain, and the first m < n keys of a keychain are the
+// same as those of a keychain with m keys.
+func FromMnemonic(words []string, n int) (GetterSampler, error) {
+	if len(words) != MnemonicLength {
+		return nil, ErrInvalidMnemonicLength
+	}
+	buf := make([]byte, len(words))
+	for i, word := range words {
+		b, in := mnemonicWordIndices[strings.ToLower(strings.TrimSpace(word))]
+		if !in {
+			return nil, ErrInvalidMnemonicWord
+		}
+		buf[i] = b
+	}
+	if mnemonicChecksum(buf[:len(buf)-1]) != buf[len(buf)-1] {
+		return nil, ErrInvalidMnemonicChecksum
+	}
+	if buf[0] != MnemonicVersion {
+		return nil, ErrUnsupportedMnemonicVersion
+	}
+	entropy := buf[1 : 1+mnemonicEntropyLength]
+
+	ecids := make([]ecid.ID, n)
+	for i := range ecids {
+		var err error
+		if ecids[i], err = deriveMnemonicV1Key(entropy, uint32(i)); err != nil {
+			return nil, err
+		}
+	}
+	return FromECIDs(ecids), nil
+}
+
+// deriveMnemonicV1Key derives the ith key from the mnemonic entropy via HKDF-SHA256.
+func deriveMnemonicV1Key(entropy []byte, i uint32) (ecid.ID, error) {
+	info := make([]byte, 4)
+	binary.BigEndian.PutUint32(info, i)
+	return ecid.NewFromSeed(hkdf.New(sha256.New, entropy, []byte(mnemonicV1Salt), info))
+}
+
+func mnemonicChecksum(buf []byte) byte {
+	hash := sha256.Sum256(buf)
+	return hash[0]
+}
@@ -0,0 +1,96 @@
+package keychain
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMnemonic(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	words1, err := NewMnemonic(rng)
+	assert.Nil(t, err)
+	assert.Len(t, words1, MnemonicLength)
+	assert.Equal(t, mnemonicWords[MnemonicVersion], words1[0])
+
+	words2, err := NewMnemonic(rng)
+	assert.Nil(t, err)
+	assert.NotEqual(t, words1, words2)
+}
+
+func TestFromMnemonic_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	words, err := NewMnemonic(rng)
+	assert.Nil(t, err)
+
+	kc1, err := FromMnemonic(words, 4)
+	assert.Nil(t, err)
+
+	// check derivation is deterministic
+	kc2, err := FromMnemonic(words, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, kc1.(*keychain).pubs, kc2.(*keychain).pubs)
+
+	// check mnemonic words are normalized
+	words3 := make([]string, len(words))
+	for i, word := range words {
+		words3[i] = " " + strings.ToUpper(word) + "\n"
+	}
+	kc3, err := FromMnemonic(words3, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, kc1.(*keychain).pubs, kc3.(*keychain).pubs)
+
+	// check smaller keychain contains subset of keys
+	kc4, err := FromMnemonic(words, 2)
+	assert.Nil(t, err)
+	for _, pub := range kc4.(*keychain).pubs {
+		assert.Contains(t, kc1.(*keychain).pubs, pub)
+	}
+
+	// check different mnemonic gives different keys
+	words5, err := NewMnemonic(rng)
+	assert.Nil(t, err)
+	kc5, err := FromMnemonic(words5, 4)
+	assert.Nil(t, err)
+	assert.NotEqual(t, kc1.(*keychain).pubs, kc5.(*keychain).pubs)
+}
+
+func TestFromMnemonic_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	words, err := NewMnemonic(rng)
+	assert.Nil(t, err)
+
+	// check wrong length triggers error
+	kc, err := FromMnemonic(words[1:], 4)
+	assert.Equal(t, ErrInvalidMnemonicLength, err)
+	assert.Nil(t, kc)
+
+	// check unknown word triggers error
+	words2 := append([]string{}, words...)
+	words2[3] = "notaword"
+	kc, err = FromMnemonic(words2, 4)
+	assert.Equal(t, ErrInvalidMnemonicWord, err)
+	assert.Nil(t, kc)
+
+	// check wrong checksum word triggers error
+	words3 := append([]string{}, words...)
+	checksum := mnemonicWordIndices[words3[len(words3)-1]]
+	words3[len(words3)-1] = mnemonicWords[checksum+1]
+	kc, err = FromMnemonic(words3, 4)
+	assert.Equal(t, ErrInvalidMnemonicChecksum, err)
+	assert.Nil(t, kc)
+
+	// check unknown version triggers error
+	buf := make([]byte, MnemonicLength)
+	buf[0] = MnemonicVersion + 1
+	buf[len(buf)-1] = mnemonicChecksum(buf[:len(buf)-1])
+	words4 := make([]string, len(buf))
+	for i, b := range buf {
+		words4[i] = mnemonicWords[b]
+	}
+	kc, err = FromMnemonic(words4, 4)
+	assert.Equal(t, ErrUnsupportedMnemonicVersion, err)
+	assert.Nil(t, kc)
+}
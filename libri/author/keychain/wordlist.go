@@ -0,0 +1,38 @@
+package keychain
+
+// mnemonicWords is the list of words encoding each byte of a mnemonic, indexed by byte value.
+// It must never be changed, since doing so would break recovery of existing mnemonics.
+var mnemonicWords = [256]string{
+	"able", "acid", "aged", "also", "area", "army", "away", "baby",
+	"back", "ball", "band", "bank", "base", "bath", "bear", "beat",
+	"bell", "belt", "best", "bird", "blow", "blue", "boat", "body",
+	"bone", "book", "boot", "born", "boss", "both", "bowl", "bulk",
+	"burn", "bush", "busy", "cake", "calm", "camp", "card", "care",
+	"cart", "case", "cash", "cast", "cell", "chat", "chip", "city",
+	"clay", "club", "coal", "coat", "code", "cold", "cook", "cool",
+	"cope", "copy", "core", "corn", "cost", "crew", "crop", "dark",
+	"data", "date", "dawn", "deal", "dear", "deep", "desk", "dial",
+	"diet", "dirt", "dish", "disk", "dock", "done", "door", "down",
+	"draw", "drop", "drum", "dual", "duck", "dust", "duty", "each",
+	"earn", "ease", "east", "easy", "edge", "even", "ever", "exit",
+	"face", "fact", "fair", "fall", "farm", "fast", "fate", "fear",
+	"feed", "feel", "file", "fill", "film", "find", "fine", "fire",
+	"firm", "fish", "five", "flag", "flat", "flow", "food", "foot",
+	"form", "fort", "four", "free", "frog", "fuel", "full", "fund",
+	"gain", "game", "gate", "gear", "gift", "give", "glad", "goal",
+	"gold", "golf", "gone", "good", "gray", "grid", "grow", "gulf",
+	"hair", "half", "hall", "hand", "hang", "hard", "have", "head",
+	"hear", "heat", "held", "help", "herb", "here", "hero", "high",
+	"hill", "hint", "hire", "hold", "hole", "home", "hope", "horn",
+	"host", "hour", "huge", "hunt", "idea", "inch", "iron", "item",
+	"jack", "jazz", "join", "joke", "jump", "jury", "just", "keen",
+	"keep", "kick", "kind", "king", "knee", "knot", "know", "lack",
+	"lady", "lake", "lamp", "land", "lane", "last", "late", "lawn",
+	"lead", "leaf", "lean", "left", "lens", "less", "life", "lift",
+	"like", "lime", "line", "link", "lion", "list", "live", "load",
+	"loan", "lock", "logo", "long", "look", "loud", "love", "luck",
+	"lung", "made", "mail", "main", "make", "mall", "many", "mark",
+	"mass", "meal", "mean", "meat", "meet", "menu", "mild", "milk",
+	"mill", "mind", "mine", "miss", "mode", "mood", "moon", "more",
+	"most", "move", "much", "must", "myth", "nail", "name", "navy",
+}
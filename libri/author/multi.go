@@ -0,0 +1,164 @@
+package author
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"golang.org/x/net/context"
+)
+
+// uploadMultiParallelism is the max number of entries concurrently shipped by UploadMulti.
+var uploadMultiParallelism = 4
+
+// NamedReader is an io.Reader with a name, e.g., an *os.File.
+type NamedReader interface {
+	io.Reader
+
+	// Name returns the name of the content, used to identify it in errors.
+	Name() string
+}
+
+// UploadMultiError indicates that some of the contents in an UploadMulti batch failed to upload.
+type UploadMultiError struct {
+	// Names are the names of the contents that failed.
+	Names []string
+
+	// Errs are the errors for each of the named contents.
+	Errs []error
+}
+
+func (e *UploadMultiError) Error() string {
+	msgs := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		msgs[i] = fmt.Sprintf("%s: %s", name, e.Errs[i])
+	}
+	return fmt.Sprintf("%d uploads failed: %s", len(e.Names), strings.Join(msgs, "; "))
+}
+
+// UploadMulti uploads each of the contents as its own entry and envelope. Contents are packed
+// one at a time while the entries already packed are shipped in parallel. The returned envelopes
+// and their keys are in the same order as the contents and are nil for contents that failed, in
+// which case the returned error is an *UploadMultiError describing each failure.
+func (a *Author) UploadMulti(contents []NamedReader, mediaType string) (
+	[]*api.Document, []id.ID, error) {
+	envs := make([]*api.Document, len(contents))
+	envKeys := make([]id.ID, len(contents))
+	errs := make([]error, len(contents))
+
+	toShip := make(chan *indexedUpload, uploadMultiParallelism)
+	wg := new(sync.WaitGroup)
+	for c := 0; c < uploadMultiParallelism; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for upload := range toShip {
+				result, err := a.shipUpload(upload.packedUpload, nil)
+				if err != nil {
+					errs[upload.i] = err
+				} else {
+					envs[upload.i], envKeys[upload.i] = result.Envelope, result.EnvelopeKey
+				}
+				upload.zeroKeys()
+			}
+		}()
+	}
+	for i, content := range contents {
+		upload, err := a.packUpload(content, mediaType, nil)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		toShip <- &indexedUpload{packedUpload: upload, i: i}
+	}
+	close(toShip)
+	wg.Wait()
+
+	multiErr := &UploadMultiError{}
+	for i, err := range errs {
+		if err != nil {
+			multiErr.Names = append(multiErr.Names, contents[i].Name())
+			multiErr.Errs = append(multiErr.Errs, err)
+		}
+	}
+	if len(multiErr.Errs) > 0 {
+		return envs, envKeys, multiErr
+	}
+	return envs, envKeys, nil
+}
+
+type indexedUpload struct {
+	*packedUpload
+	i int
+}
+
+// DownloadItem pairs the key of an envelope to download with the writer for its content.
+type DownloadItem struct {
+	// EnvelopeKey is the key of the envelope to download.
+	EnvelopeKey id.ID
+
+	// Content is where the downloaded content is written.
+	Content io.Writer
+}
+
+// DownloadMultiError indicates that some of the items in a DownloadMulti batch failed to
+// download.
+type DownloadMultiError struct {
+	// EnvelopeKeys are the keys of the envelopes that failed.
+	EnvelopeKeys []id.ID
+
+	// Errs are the errors for each of the envelope keys.
+	Errs []error
+}
+
+func (e *DownloadMultiError) Error() string {
+	msgs := make([]string, len(e.EnvelopeKeys))
+	for i, envKey := range e.EnvelopeKeys {
+		msgs[i] = fmt.Sprintf("%s: %s", envKey, e.Errs[i])
+	}
+	return fmt.Sprintf("%d downloads failed: %s", len(e.EnvelopeKeys), strings.Join(msgs, "; "))
+}
+
+// DownloadMulti downloads each of the items, writing the content of its envelope to its writer.
+// Up to the configured DownloadMultiParallelism items are downloaded concurrently, and a failed
+// item doesn't abort the rest of the batch. If any fail, the returned error is a
+// *DownloadMultiError describing each failure. Items not started before the context is done fail
+// with ctx.Err().
+func (a *Author) DownloadMulti(ctx context.Context, items []DownloadItem) error {
+	errs := make([]error, len(items))
+	parallelism := a.config.downloadMultiParallelism()
+	toDownload := make(chan int, parallelism)
+	wg := new(sync.WaitGroup)
+	for c := 0; c < parallelism; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range toDownload {
+				if errs[i] = ctx.Err(); errs[i] != nil {
+					continue
+				}
+				_, errs[i] = a.DownloadWithContext(ctx, items[i].Content, items[i].EnvelopeKey)
+			}
+		}()
+	}
+	for i := range items {
+		toDownload <- i
+	}
+	close(toDownload)
+	wg.Wait()
+
+	multiErr := &DownloadMultiError{}
+	for i, err := range errs {
+		if err != nil {
+			multiErr.EnvelopeKeys = append(multiErr.EnvelopeKeys, items[i].EnvelopeKey)
+			multiErr.Errs = append(multiErr.Errs, err)
+		}
+	}
+	if len(multiErr.Errs) > 0 {
+		return multiErr
+	}
+	return nil
+}
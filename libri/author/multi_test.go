@@ -0,0 +1,217 @@
+package author
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/author/io/common"
+	"github.com/drausin/libri/libri/author/io/page"
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/author/io/ship"
+	"github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestAuthor_UploadMulti_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+
+	// just mock interaction with libri network
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	nContents := 6
+	contents := make([]NamedReader, nContents)
+	contentsBytes := make([][]byte, nContents)
+	for i := range contents {
+		content := common.NewCompressableBytes(rng, 128*(i+1))
+		contentsBytes[i] = content.Bytes()
+		contents[i] = &namedReader{Reader: content, name: fmt.Sprintf("file-%d", i)}
+	}
+
+	envs, envKeys, err := a.UploadMulti(contents, "application/x-pdf")
+	assert.Nil(t, err)
+	assert.Len(t, envs, nContents)
+	assert.Len(t, envKeys, nContents)
+
+	// check each content --> UploadMulti --> Download
+	for i, envKey := range envKeys {
+		assert.NotNil(t, envs[i])
+		content2 := new(bytes.Buffer)
+		_, err = a.Download(content2, envKey)
+		assert.Nil(t, err)
+		assert.Equal(t, contentsBytes[i], content2.Bytes())
+	}
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_UploadMulti_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	a.librarians = &fixedClientBalancer{}
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	contents := []NamedReader{
+		&namedReader{Reader: common.NewCompressableBytes(rng, 256), name: "ok-1"},
+		&namedReader{Reader: &errReader{err: errors.New("some Read error")}, name: "bad"},
+		&namedReader{Reader: common.NewCompressableBytes(rng, 256), name: "ok-2"},
+	}
+
+	// check failed content doesn't abort the rest of the batch
+	envs, envKeys, err := a.UploadMulti(contents, "application/x-pdf")
+	assert.NotNil(t, err)
+	multiErr, ok := err.(*UploadMultiError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"bad"}, multiErr.Names)
+	assert.Len(t, multiErr.Errs, 1)
+	assert.NotNil(t, envs[0])
+	assert.NotNil(t, envKeys[0])
+	assert.Nil(t, envs[1])
+	assert.Nil(t, envKeys[1])
+	assert.NotNil(t, envs[2])
+	assert.NotNil(t, envKeys[2])
+}
+
+func TestAuthor_DownloadMulti_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+	a.config.DownloadMultiParallelism = 3
+
+	// just mock interaction with libri network
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	slPublisher := publish.NewSingleLoadPublisher(pubAcq, a.documentSLD)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pubAcq, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	nContents := 8
+	contents := make([]NamedReader, nContents)
+	contentsBytes := make([][]byte, nContents)
+	for i := range contents {
+		content := common.NewCompressableBytes(rng, 128*(i+1))
+		contentsBytes[i] = content.Bytes()
+		contents[i] = &namedReader{Reader: content, name: fmt.Sprintf("file-%d", i)}
+	}
+	envs, envKeys, err := a.UploadMulti(contents, "application/x-pdf")
+	assert.Nil(t, err)
+
+	// check each content --> UploadMulti --> DownloadMulti
+	items := make([]DownloadItem, nContents)
+	downloaded := make([]*bytes.Buffer, nContents)
+	for i, envKey := range envKeys {
+		downloaded[i] = new(bytes.Buffer)
+		items[i] = DownloadItem{EnvelopeKey: envKey, Content: downloaded[i]}
+	}
+	err = a.DownloadMulti(context.Background(), items)
+	assert.Nil(t, err)
+	for i := range items {
+		assert.Equal(t, contentsBytes[i], downloaded[i].Bytes())
+	}
+
+	// check failed item doesn't abort the rest of the batch
+	entryKey := id.FromBytes(envs[1].Contents.(*api.Document_Envelope).Envelope.EntryKey)
+	items[1].EnvelopeKey = entryKey // not an envelope
+	for i := range items {
+		downloaded[i].Reset()
+	}
+	err = a.DownloadMulti(context.Background(), items)
+	assert.NotNil(t, err)
+	multiErr, ok := err.(*DownloadMultiError)
+	assert.True(t, ok)
+	assert.Equal(t, []id.ID{entryKey}, multiErr.EnvelopeKeys)
+	assert.Equal(t, []error{api.ErrUnexpectedDocumentType}, multiErr.Errs)
+	for i := range items {
+		if i != 1 {
+			assert.Equal(t, contentsBytes[i], downloaded[i].Bytes())
+		}
+	}
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_DownloadMulti_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	receiveErr := errors.New("some Receive error")
+	a := &Author{
+		config:        &Config{},
+		logger:        clogging.NewDevInfoLogger(),
+		receiver:      &fixedReceiver{receiveEntryErr: receiveErr},
+		entryUnpacker: &fixedUnpacker{},
+	}
+	items := []DownloadItem{
+		{EnvelopeKey: id.NewPseudoRandom(rng)},
+		{EnvelopeKey: id.NewPseudoRandom(rng)},
+	}
+
+	// check each item's error is collected
+	err := a.DownloadMulti(context.Background(), items)
+	multiErr, ok := err.(*DownloadMultiError)
+	assert.True(t, ok)
+	assert.Equal(t, []id.ID{items[0].EnvelopeKey, items[1].EnvelopeKey}, multiErr.EnvelopeKeys)
+	assert.Equal(t, []error{receiveErr, receiveErr}, multiErr.Errs)
+
+	// check items aren't started once context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = a.DownloadMulti(ctx, items)
+	multiErr, ok = err.(*DownloadMultiError)
+	assert.True(t, ok)
+	assert.Equal(t, []error{context.Canceled, context.Canceled}, multiErr.Errs)
+}
+
+type namedReader struct {
+	io.Reader
+	name string
+}
+
+func (r *namedReader) Name() string {
+	return r.name
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,177 @@
+package author
+
+import (
+	"time"
+
+	"github.com/drausin/libri/libri/author/io/comp"
+	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/author/io/publish"
+	"golang.org/x/net/context"
+)
+
+// ProgressFunc receives the progress of an upload or download after each page is shipped or
+// received, with the number of pages done so far, the total number of pages, and the cumulative
+// size (in bytes) of the page documents done.
+type ProgressFunc func(pagesDone, pagesTotal int, bytesDone uint64)
+
+// UploadOpts are optional parameters for an upload.
+type UploadOpts struct {
+	// Progress, if not nil, is called after each page is shipped.
+	Progress ProgressFunc
+
+	// Codec is the compression codec to use, e.g., comp.NoneCodec for already-compressed media.
+	// The default comp.AutoCodec defers to the configured print.Parameters CompressionCodec.
+	Codec comp.Codec
+
+	// Context, if not nil, stops the upload from publishing or retrying further pages once it
+	// is done.
+	Context context.Context
+
+	// NReplicas, if not zero, is the number of replicas librarians store of each document in the
+	// upload, e.g., more for critical documents and fewer for ephemeral ones. Zero uses the
+	// librarians' default.
+	NReplicas uint32
+
+	// MinNReplicas, if not zero, is the fewest replicas of each document in the upload acceptable
+	// when librarians can't store all of them, below which the upload errors. Zero requires
+	// librarians to store all of them.
+	MinNReplicas uint32
+
+	// Expiry, if not zero, is the time after which librarians delete the uploaded entry.
+	Expiry time.Time
+
+	// PageSize, if not zero, is the maximum size (in bytes) of each page, overriding the
+	// configured print.Parameters PageSize, e.g., smaller for small documents to reduce overhead
+	// and larger for huge ones to reduce the number of pages. It must be between page.MinSize and
+	// page.MaxSize.
+	PageSize uint32
+
+	// Identity is the name of the identity (see Author.AddIdentity) whose author keychain
+	// encrypts the upload. The default DefaultIdentity uses the keychain given to NewAuthor.
+	Identity string
+
+	// EEK, if not nil, encrypts the upload instead of a newly sampled EEK, e.g., one from
+	// enc.NewDerivedEEK. Since pages are encrypted deterministically, uploads of the same content
+	// by the same author with the same EEK and page size have the same pages, which
+	// SkipExistingPages can then avoid re-shipping. Reusing an EEK lets anyone given it (e.g.,
+	// via a shared envelope) decrypt every upload encrypted with it, and shows librarians which
+	// uploads have the same content.
+	EEK *enc.EEK
+
+	// SkipExistingPages, when true, first checks whether each page of the upload already exists
+	// in the libri network and if so doesn't ship it again, shipping just the entry and envelope
+	// for it. Since page keys are hashes of their ciphertext, pages only ever exist already when
+	// the same content has been uploaded with the same EEK (see EEK). Single-page entries contain
+	// their page, so nothing is skipped for them.
+	SkipExistingPages bool
+}
+
+// ShareOpts are optional parameters for a share.
+type ShareOpts struct {
+	// Identity is the name of the identity (see Author.AddIdentity) whose author keychain
+	// encrypts the shared envelope. The default DefaultIdentity uses the keychain given to
+	// NewAuthor.
+	Identity string
+}
+
+// DownloadOpts are optional parameters for a download.
+type DownloadOpts struct {
+	// Progress, if not nil, is called after each page is received.
+	Progress ProgressFunc
+
+	// NoOverwrite, when downloading to a file, fails the download with ErrDownloadFileExists
+	// rather than overwriting an existing file at the path.
+	NoOverwrite bool
+
+	// SearchConcurrency, if not zero, is the number of peers librarians query in parallel when
+	// searching for each document of the download, at most api.MaxSearchConcurrency. Higher
+	// concurrency can cut tail latency on high-latency networks but puts more load on the
+	// network. Zero uses the librarians' default. When the Author streams downloads, pages are
+	// acquired while unpacking with the librarians' default.
+	SearchConcurrency uint32
+}
+
+func (o *UploadOpts) progress() publish.Progress {
+	if o == nil || o.Progress == nil {
+		return nil
+	}
+	return publish.Progress(o.Progress)
+}
+
+func (o *UploadOpts) codec() comp.Codec {
+	if o == nil {
+		return comp.AutoCodec
+	}
+	return o.Codec
+}
+
+func (o *UploadOpts) expiry() time.Time {
+	if o == nil {
+		return time.Time{}
+	}
+	return o.Expiry
+}
+
+func (o *UploadOpts) pageSize() uint32 {
+	if o == nil {
+		return 0
+	}
+	return o.PageSize
+}
+
+func (o *UploadOpts) context() context.Context {
+	if o == nil || o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+func (o *UploadOpts) replication() *publish.Replication {
+	if o == nil {
+		return nil
+	}
+	return &publish.Replication{NReplicas: o.NReplicas, MinNReplicas: o.MinNReplicas}
+}
+
+func (o *UploadOpts) eek() *enc.EEK {
+	if o == nil {
+		return nil
+	}
+	return o.EEK
+}
+
+func (o *UploadOpts) skipExistingPages() bool {
+	return o != nil && o.SkipExistingPages
+}
+
+func (o *UploadOpts) identity() string {
+	if o == nil {
+		return DefaultIdentity
+	}
+	return o.Identity
+}
+
+func (o *ShareOpts) identity() string {
+	if o == nil {
+		return DefaultIdentity
+	}
+	return o.Identity
+}
+
+func (o *DownloadOpts) progress() publish.Progress {
+	if o == nil || o.Progress == nil {
+		return nil
+	}
+	return publish.Progress(o.Progress)
+}
+
+func (o *DownloadOpts) search() *publish.Search {
+	if o == nil || o.SearchConcurrency == 0 {
+		return nil
+	}
+	return &publish.Search{Concurrency: o.SearchConcurrency}
+}
+
+func (o *DownloadOpts) noOverwrite() bool {
+	return o != nil && o.NoOverwrite
+}
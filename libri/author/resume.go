@@ -0,0 +1,197 @@
+package author
+
+import (
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/drausin/libri/libri/author/io/enc"
+	"github.com/drausin/libri/libri/author/io/pack"
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrMissingUpload indicates when no resume state exists for an upload key, usually because the
+// upload has already completed.
+var ErrMissingUpload = errors.New("missing upload resume state")
+
+// shippedValue is the value stored under a page's shipped key (c.f., newShippedKey) in the
+// uploads namespace once that page has been shipped.
+var shippedValue = []byte{1}
+
+// shippedKeyPrefix distinguishes the shipped keys of pages from the upload keys of entries.
+var shippedKeyPrefix = []byte("shipped")
+
+// newUploadKey returns the key under which the resume state of an upload is stored. Since each
+// upload samples its own author key and EEK, even concurrent uploads of the same content have
+// different (author public key, entry key) pairs and thus never share resume state.
+func newUploadKey(authorPub []byte, entryKey id.ID) id.ID {
+	h := sha256.New()
+	h.Write(authorPub)
+	h.Write(entryKey.Bytes())
+	return id.FromBytes(h.Sum(nil))
+}
+
+// newShippedKey returns the key under which a page is recorded as shipped in the uploads
+// namespace. Like the upload key, it includes the author public key, so uploads by different
+// identities never share the resume state of their pages.
+func newShippedKey(authorPub []byte, pageKey id.ID) []byte {
+	h := sha256.New()
+	h.Write(shippedKeyPrefix)
+	h.Write(authorPub)
+	h.Write(pageKey.Bytes())
+	return h.Sum(nil)
+}
+
+// resumingPublisher is a publish.SingleLoadPublisher that records each page it publishes in the
+// uploads namespace and skips pages already recorded there, so re-shipping a partially shipped
+// entry only publishes the remaining pages.
+type resumingPublisher struct {
+	inner   publish.SingleLoadPublisher
+	docD    storage.DocumentDeleter
+	shipped storage.NamespaceSL
+}
+
+func newResumingPublisher(
+	inner publish.SingleLoadPublisher, docD storage.DocumentDeleter, shipped storage.NamespaceSL,
+) publish.SingleLoadPublisher {
+	return &resumingPublisher{
+		inner:   inner,
+		docD:    docD,
+		shipped: shipped,
+	}
+}
+
+func (p *resumingPublisher) Publish(
+	docKey id.ID, authorPub []byte, lc api.Putter, repl *publish.Replication, delete bool,
+) (*api.Document, error) {
+
+	shippedKey := newShippedKey(authorPub, docKey)
+	shipped, err := p.shipped.Load(shippedKey)
+	if err != nil {
+		return nil, err
+	}
+	var doc *api.Document
+	if shipped == nil {
+		// only delete the local page after it's recorded as shipped, so an interruption between
+		// the two never leaves a page that is neither stored locally nor marked as shipped
+		doc, err = p.inner.Publish(docKey, authorPub, lc, repl, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.shipped.Store(shippedKey, shippedValue); err != nil {
+			return nil, err
+		}
+	}
+	if delete {
+		if err := p.docD.Delete(docKey); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// saveUpload stores the entry and an envelope for it so the upload can be resumed later. It
+// returns the upload key under which the envelope is stored.
+func (a *Author) saveUpload(
+	entry *api.Document, authorPub, readerPub []byte, kek *enc.KEK, eek *enc.EEK,
+) (id.ID, error) {
+
+	entryKey, err := api.GetKey(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.documentSLD.Store(entryKey, entry); err != nil {
+		return nil, err
+	}
+	eekCiphertext, eekCiphertextMAC, err := kek.Encrypt(eek)
+	if err != nil {
+		return nil, err
+	}
+	envelope := pack.NewEnvelopeDoc(entryKey, authorPub, readerPub, eekCiphertext,
+		eekCiphertextMAC)
+	envelopeBytes, err := proto.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	uploadKey := newUploadKey(authorPub, entryKey)
+	return uploadKey, a.uploadSLD.Store(uploadKey.Bytes(), envelopeBytes)
+}
+
+// loadUpload loads the envelope and entry saved for the given upload key.
+func (a *Author) loadUpload(uploadKey id.ID) (*api.Envelope, *api.Document, error) {
+	envelopeBytes, err := a.uploadSLD.Load(uploadKey.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	if envelopeBytes == nil {
+		return nil, nil, ErrMissingUpload
+	}
+	envelopeDoc := &api.Document{}
+	if err := proto.Unmarshal(envelopeBytes, envelopeDoc); err != nil {
+		return nil, nil, err
+	}
+	envelope, ok := envelopeDoc.Contents.(*api.Document_Envelope)
+	if !ok {
+		return nil, nil, api.ErrUnexpectedDocumentType
+	}
+	entry, err := a.documentSLD.Load(id.FromBytes(envelope.Envelope.EntryKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil {
+		return nil, nil, ErrMissingUpload
+	}
+	return envelope.Envelope, entry, nil
+}
+
+// getUploadKeys re-derives the KEK and EEK used by an upload from its saved envelope.
+func (a *Author) getUploadKeys(envelope *api.Envelope) (*enc.KEK, *enc.EEK, error) {
+	authorID, in := a.getAuthorKey(envelope.AuthorPublicKey)
+	if !in {
+		return nil, nil, keychain.ErrUnexpectedMissingKey
+	}
+	readerPub, err := ecid.FromPublicKeyBytes(envelope.ReaderPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	kek, err := enc.NewKEK(authorID.Key(), readerPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	eek, err := kek.Decrypt(envelope.EekCiphertext, envelope.EekCiphertextMac)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kek, eek, nil
+}
+
+// completeUpload atomically stores the audit record of the shipped envelope and removes all
+// resume state of the upload, so an interruption never leaves a completed upload without its
+// record or a recorded upload still resumable.
+func (a *Author) completeUpload(uploadKey id.ID, entry *api.Document, env *api.Document) error {
+	entryKey, err := api.GetKey(entry)
+	if err != nil {
+		return err
+	}
+	pageKeys, err := api.GetEntryPageKeys(entry)
+	if err != nil {
+		return err
+	}
+	batch := &storage.Batch{}
+	if err := addAuditRecords(batch, time.Now(), env); err != nil {
+		return err
+	}
+	authorPub := api.GetAuthorPub(entry)
+	for _, pageKey := range pageKeys {
+		batch.Delete(storage.Uploads, newShippedKey(authorPub, pageKey))
+	}
+	batch.Delete(storage.Documents, entryKey.Bytes())
+	batch.Delete(storage.Uploads, uploadKey.Bytes())
+	return a.batchWriter.WriteBatch(batch)
+}
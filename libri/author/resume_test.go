@@ -0,0 +1,249 @@
+package author
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/author/io/common"
+	"github.com/drausin/libri/libri/author/io/page"
+	"github.com/drausin/libri/libri/author/io/publish"
+	"github.com/drausin/libri/libri/author/io/ship"
+	"github.com/drausin/libri/libri/common/db"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUploadKey(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub1 := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	authorPub2 := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	entryKey1, entryKey2 := id.NewPseudoRandom(rng), id.NewPseudoRandom(rng)
+
+	assert.Equal(t, newUploadKey(authorPub1, entryKey1), newUploadKey(authorPub1, entryKey1))
+	assert.NotEqual(t, newUploadKey(authorPub1, entryKey1), newUploadKey(authorPub2, entryKey1))
+	assert.NotEqual(t, newUploadKey(authorPub1, entryKey1), newUploadKey(authorPub1, entryKey2))
+}
+
+func TestNewShippedKey(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPub1 := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	authorPub2 := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	pageKey1, pageKey2 := id.NewPseudoRandom(rng), id.NewPseudoRandom(rng)
+
+	assert.Equal(t, newShippedKey(authorPub1, pageKey1), newShippedKey(authorPub1, pageKey1))
+	assert.NotEqual(t, newShippedKey(authorPub1, pageKey1), newShippedKey(authorPub2, pageKey1))
+	assert.NotEqual(t, newShippedKey(authorPub1, pageKey1), newShippedKey(authorPub1, pageKey2))
+	assert.NotEqual(t, newUploadKey(authorPub1, pageKey1).Bytes(),
+		newShippedKey(authorPub1, pageKey1))
+}
+
+func TestResumingPublisher_Publish(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	docSLD, uploadSLD := storage.NewDocumentSLD(kvdb), storage.NewUploadSLD(kvdb)
+	pub := &flakyPublisher{
+		inner:     &memPublisherAcquirer{docs: make(map[string]*api.Document)},
+		published: make(map[string]int),
+		nMax:      1,
+	}
+	rp := newResumingPublisher(publish.NewSingleLoadPublisher(pub, docSLD), docSLD, uploadSLD)
+	doc1, docKey1 := api.NewTestDocument(rng)
+	doc2, docKey2 := api.NewTestDocument(rng)
+	err := docSLD.Store(docKey1, doc1)
+	assert.Nil(t, err)
+	err = docSLD.Store(docKey2, doc2)
+	assert.Nil(t, err)
+
+	// check first publish marks doc as shipped and deletes it
+	_, err = rp.Publish(docKey1, api.GetAuthorPub(doc1), nil, nil, true)
+	assert.Nil(t, err)
+	shipped, err := uploadSLD.Load(newShippedKey(api.GetAuthorPub(doc1), docKey1))
+	assert.Nil(t, err)
+	assert.NotNil(t, shipped)
+	stored, err := docSLD.Load(docKey1)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+
+	// check publish error leaves doc unmarked and in local storage
+	_, err = rp.Publish(docKey2, api.GetAuthorPub(doc2), nil, nil, true)
+	assert.NotNil(t, err)
+	shipped, err = uploadSLD.Load(newShippedKey(api.GetAuthorPub(doc2), docKey2))
+	assert.Nil(t, err)
+	assert.Nil(t, shipped)
+	stored, err = docSLD.Load(docKey2)
+	assert.Nil(t, err)
+	assert.Equal(t, doc2, stored)
+
+	// check already shipped doc isn't published again
+	_, err = rp.Publish(docKey1, api.GetAuthorPub(doc1), nil, nil, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, pub.published[docKey1.String()])
+
+	// check doc shipped by another author isn't recorded as shipped by this one
+	otherAuthorPub := ecid.NewPseudoRandom(rng).PublicKeyBytes()
+	shipped, err = uploadSLD.Load(newShippedKey(otherAuthorPub, docKey1))
+	assert.Nil(t, err)
+	assert.Nil(t, shipped)
+}
+
+func TestAuthor_UploadResumable_ResumeUpload(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	a.librarians = &fixedClientBalancer{}
+
+	// mock interaction with libri network, failing after the first few publishes
+	pubAcq := &memPublisherAcquirer{
+		docs: make(map[string]*api.Document),
+	}
+	pub := &flakyPublisher{
+		inner:     pubAcq,
+		published: make(map[string]int),
+		nMax:      3,
+	}
+	slPublisher := newResumingPublisher(
+		publish.NewSingleLoadPublisher(pub, a.documentSLD),
+		a.documentSLD,
+		a.uploadSLD,
+	)
+	ssAcquirer := publish.NewSingleStoreAcquirer(pubAcq, a.documentSLD)
+	mlPublisher := publish.NewMultiLoadPublisher(slPublisher, a.config.Publish)
+	msAcquirer := publish.NewMultiStoreAcquirer(ssAcquirer, a.config.Publish)
+	a.shipper = ship.NewShipper(a.librarians, pub, mlPublisher)
+	a.receiver = ship.NewReceiver(a.librarians, a.selfReaderKeys, pubAcq, msAcquirer,
+		a.documentSLD)
+
+	page.MinSize = 64 // just for testing
+	a.config.Print.PageSize = 128
+	content1 := common.NewCompressableBytes(rng, 2048)
+	content1Bytes := content1.Bytes()
+
+	// check failed upload returns upload key & leaves resume state
+	env, envKey, uploadKey, err := a.UploadResumable(content1, "application/x-gzip", nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envKey)
+	assert.NotNil(t, uploadKey)
+	state, err := a.uploadSLD.Load(uploadKey.Bytes())
+	assert.Nil(t, err)
+	assert.NotNil(t, state)
+
+	// let all subsequent publishes succeed
+	pub.mu.Lock()
+	pub.nMax = len(pub.published) + 1024
+	pub.mu.Unlock()
+
+	env, envKey, err = a.ResumeUpload(uploadKey, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, env)
+	assert.NotNil(t, envKey)
+
+	// check pages shipped before the failure weren't published again
+	for _, n := range pub.published {
+		assert.Equal(t, 1, n)
+	}
+
+	// check resume state has been garbage collected
+	state, err = a.uploadSLD.Load(uploadKey.Bytes())
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+	_, _, err = a.ResumeUpload(uploadKey, nil)
+	assert.Equal(t, ErrMissingUpload, err)
+
+	// check the completed upload was recorded in the audit log
+	records, err := a.AuditLog(time.Time{}, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, envKey, records[0].EnvelopeKey)
+
+	// check content1 == content1 --> UploadResumable --> ResumeUpload --> Download
+	content2 := new(bytes.Buffer)
+	_, err = a.Download(content2, envKey)
+	assert.Nil(t, err)
+	assert.Equal(t, content1Bytes, content2.Bytes())
+
+	err = a.CloseAndRemove()
+	assert.Nil(t, err)
+}
+
+func TestAuthor_ResumeUpload_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+
+	// check missing upload state returns error
+	env, envKey, err := a.ResumeUpload(id.NewPseudoRandom(rng), nil)
+	assert.Equal(t, ErrMissingUpload, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envKey)
+
+	// check ship error bubbles up
+	entry, _ := api.NewTestDocument(rng)
+	a.entryPacker = &fixedEntryPacker{entry: entry}
+	a.shipper = &fixedShipper{err: errors.New("some Ship error")}
+	_, _, uploadKey, err := a.UploadResumable(nil, "", nil)
+	assert.NotNil(t, err)
+	env, envKey, err = a.ResumeUpload(uploadKey, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, env)
+	assert.Nil(t, envKey)
+}
+
+func TestAuthor_completeUpload_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newTestAuthor()
+	defer func() {
+		err := a.CloseAndRemove()
+		assert.Nil(t, err)
+	}()
+	entry, _ := api.NewTestDocument(rng)
+	a.entryPacker = &fixedEntryPacker{entry: entry}
+	a.shipper = &fixedShipper{err: errors.New("some Ship error")}
+	_, _, uploadKey, err := a.UploadResumable(nil, "", nil)
+	assert.NotNil(t, err)
+
+	// check write error neither records the upload nor removes its resume state
+	a.batchWriter = &fixedBatchWriter{err: errors.New("some WriteBatch error")}
+	err = a.completeUpload(uploadKey, entry, newTestEnvelopeDoc(rng))
+	assert.NotNil(t, err)
+	state, err := a.uploadSLD.Load(uploadKey.Bytes())
+	assert.Nil(t, err)
+	assert.NotNil(t, state)
+	records, err := a.AuditLog(time.Time{}, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+// flakyPublisher publishes up to nMax documents via its inner publisher before erroring.
+type flakyPublisher struct {
+	inner     publish.Publisher
+	published map[string]int
+	nMax      int
+	mu        sync.Mutex
+}
+
+func (p *flakyPublisher) Publish(
+	doc *api.Document, authorPub []byte, lc api.Putter, repl *publish.Replication,
+) (id.ID, *publish.Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.published) >= p.nMax {
+		return nil, nil, errors.New("some Publish error")
+	}
+	docKey, result, err := p.inner.Publish(doc, authorPub, lc, repl)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.published[docKey.String()]++
+	return docKey, result, nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 
 	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/ecid"
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/golang/protobuf/proto"
@@ -30,6 +31,9 @@ const (
 	// LoggerClientID is a client ID.
 	LoggerClientID = "clientId"
 
+	// LoggerPreviousClientID is the client ID before rotating it.
+	LoggerPreviousClientID = "previousClientId"
+
 	// LoggerKeychainFilepath is a keychain filepath.
 	LoggerKeychainFilepath = "keychainFilepath"
 
@@ -49,45 +53,74 @@ const (
 )
 
 var (
-	clientIDKey = []byte("ClientID")
+	clientIDKey         = []byte("ClientID")
+	previousClientIDKey = []byte("PreviousClientID")
 )
 
 func loadOrCreateClientID(logger *zap.Logger, nsl storage.NamespaceSL) (ecid.ID, error) {
-	bytes, err := nsl.Load(clientIDKey)
+	clientID, err := loadStoredClientID(nsl, clientIDKey)
 	if err != nil {
 		logger.Error("error loading client ID", zap.Error(err))
 		return nil, err
 	}
-
-	if bytes != nil {
-		// return saved PeerID
-		stored := &ecid.ECDSAPrivateKey{}
-		if err := proto.Unmarshal(bytes, stored); err != nil {
-			return nil, err
-		}
-		clientID, err := ecid.FromStored(stored)
-		if err != nil {
-			logger.Error("error deserializing client ID keys", zap.Error(err))
-			return nil, err
-		}
+	if clientID != nil {
 		logger.Info("loaded exsting client ID", zap.String(LoggerClientID,
 			clientID.String()))
 		return clientID, nil
 	}
 
 	// return new client ID
-	clientID := ecid.NewRandom()
+	clientID = ecid.NewRandom()
 	logger.Info("created new client ID", zap.String(LoggerClientID, clientID.String()))
 
 	return clientID, saveClientID(nsl, clientID)
 }
 
+func loadPreviousClientID(nl storage.NamespaceLoader) (ecid.ID, error) {
+	return loadStoredClientID(nl, previousClientIDKey)
+}
+
+// loadStoredClientID loads the client ID stored under the given key, returning nil if none is.
+func loadStoredClientID(nl storage.NamespaceLoader, key []byte) (ecid.ID, error) {
+	bytes, err := nl.Load(key)
+	if err != nil || bytes == nil {
+		return nil, err
+	}
+	stored := &ecid.ECDSAPrivateKey{}
+	if err := proto.Unmarshal(bytes, stored); err != nil {
+		return nil, err
+	}
+	return ecid.FromStored(stored)
+}
+
+// LoadClientID loads the client ID from the author DB in the config's DbDir, creating and saving a
+// new one if the DB doesn't yet have one, so that it matches the ID of an Author later created
+// with the same config.
+func LoadClientID(config *Config, logger *zap.Logger) (ecid.ID, error) {
+	rdb, err := db.NewKVDB(config.DBBackend, config.DbDir)
+	if err != nil {
+		logger.Error("unable to init DB", zap.String("db_backend", config.DBBackend),
+			zap.String("db_dir", config.DbDir), zap.Error(err))
+		return nil, err
+	}
+	defer rdb.Close()
+	return loadOrCreateClientID(logger, storage.NewClientSL(rdb))
+}
+
 func saveClientID(ns storage.NamespaceStorer, clientID ecid.ID) error {
+	return saveStoredClientID(ns, clientIDKey, clientID)
+}
+
+func savePreviousClientID(ns storage.NamespaceStorer, clientID ecid.ID) error {
+	return saveStoredClientID(ns, previousClientIDKey, clientID)
+}
+
+func saveStoredClientID(ns storage.NamespaceStorer, key []byte, clientID ecid.ID) error {
 	bytes, err := proto.Marshal(ecid.ToStored(clientID))
 	if err != nil {
 		return err
 	}
-	return ns.Store(clientIDKey, bytes)
+	return ns.Store(key, bytes)
 }
 
 // LoadKeychains loads the author and self-reader keychains from a directory on the local
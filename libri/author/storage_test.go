@@ -8,8 +8,10 @@ import (
 	"path"
 	"testing"
 
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/ecid"
 	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/common/storage"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 )
@@ -52,6 +54,25 @@ func TestLoadOrCreatePeerID_err(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestLoadClientID(t *testing.T) {
+	config := newTestConfig().WithDBBackend(db.RocksDBBackend)
+	defer func() { assert.Nil(t, os.RemoveAll(config.DataDir)) }()
+	logger := clogging.NewDevInfoLogger()
+
+	// check client ID is created on first load and the same on subsequent loads
+	id1, err := LoadClientID(config, logger)
+	assert.Nil(t, err)
+	assert.NotNil(t, id1)
+	id2, err := LoadClientID(config, logger)
+	assert.Nil(t, err)
+	assert.Equal(t, id1, id2)
+
+	// check unknown DB backend gives error
+	id3, err := LoadClientID(newTestConfig().WithDBBackend("unknown"), logger)
+	assert.NotNil(t, err)
+	assert.Nil(t, id3)
+}
+
 func TestSaveClientID(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	assert.Nil(t, saveClientID(&fixedStorerLoader{}, ecid.NewPseudoRandom(rng)))
@@ -204,9 +225,10 @@ func rmDir(dir string) {
 }
 
 type fixedStorerLoader struct {
-	loadBytes []byte
-	loadErr   error
-	storeErr  error
+	loadBytes  []byte
+	loadErr    error
+	storeErr   error
+	iterateErr error
 }
 
 func (l *fixedStorerLoader) Load(key []byte) ([]byte, error) {
@@ -216,3 +238,21 @@ func (l *fixedStorerLoader) Load(key []byte) ([]byte, error) {
 func (l *fixedStorerLoader) Store(key []byte, value []byte) error {
 	return l.storeErr
 }
+
+func (l *fixedStorerLoader) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return l.iterateErr
+}
+
+func (l *fixedStorerLoader) IterateRange(
+	start []byte, end []byte, fn func(key, value []byte) error,
+) error {
+	return l.iterateErr
+}
+
+type fixedBatchWriter struct {
+	err error
+}
+
+func (w *fixedBatchWriter) WriteBatch(batch *storage.Batch) error {
+	return w.err
+}
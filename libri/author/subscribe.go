@@ -0,0 +1,136 @@
+package author
+
+import (
+	"sync"
+	"time"
+
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/subscribe"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+var (
+	// subscribeNLibrarians is the number of concurrent subscriptions to librarians maintained by
+	// Subscribe. More than one means a publication is still received while a subscription is
+	// reconnecting.
+	subscribeNLibrarians = 3
+
+	// subscribeRetryWait is how long a subscription waits before reconnecting after an error.
+	subscribeRetryWait = 1 * time.Second
+)
+
+// Subscribe subscribes to new publications whose reader public key is in the filter (e.g., the
+// ReaderPublicKeys of a subscribe.NewReaderSubscription), returning a channel of the envelope
+// keys of the matching publications. It maintains subscriptions to a few librarians, reconnecting
+// to another librarian when one fails, and deduplicates the publications received from each of
+// them. The channel is closed once the context is done.
+func (a *Author) Subscribe(ctx context.Context, filter *api.BloomFilter) (<-chan id.ID, error) {
+	sub, err := subscribe.NewReaderFilterSubscription(filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.ValidateSubscription(sub); err != nil {
+		return nil, err
+	}
+	recent, err := lru.New(subscribe.DefaultRecentCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	received := make(chan *api.Publication, subscribeNLibrarians)
+	envKeys := make(chan id.ID, subscribeNLibrarians)
+
+	// subscriptions writing to received until the context is done
+	wg := new(sync.WaitGroup)
+	for c := 0; c < subscribeNLibrarians; c++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				err := a.subscribeLibrarian(ctx, sub, received)
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				a.logger.Debug("reconnecting subscription",
+					zap.Int("index", i),
+					zap.Error(err),
+				)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(subscribeRetryWait):
+				}
+			}
+		}(c)
+	}
+	go func() {
+		wg.Wait()
+		close(received)
+	}()
+
+	// dedup all received publications, writing new envelope keys to envKeys
+	go func() {
+		defer close(envKeys)
+		for pub := range received {
+			pubKey, err := api.GetKey(pub)
+			if err != nil {
+				// should never happen
+				panic(err)
+			}
+			if recent.Contains(pubKey.String()) {
+				continue
+			}
+			recent.Add(pubKey.String(), nil)
+			a.logger.Debug("received new publication",
+				zap.Stringer(LoggerPublicationKey, pubKey),
+				zap.Stringer(LoggerEnvelopeKey, id.FromBytes(pub.EnvelopeKey)),
+			)
+			select {
+			case <-ctx.Done():
+			case envKeys <- id.FromBytes(pub.EnvelopeKey):
+			}
+		}
+	}()
+	return envKeys, nil
+}
+
+// subscribeLibrarian subscribes to the next librarian, writing its valid publications to
+// received until the subscription ends, either with an error or once the context is done.
+func (a *Author) subscribeLibrarian(
+	ctx context.Context, sub *api.Subscription, received chan *api.Publication,
+) error {
+	lc, err := a.librarians.Next()
+	if err != nil {
+		return err
+	}
+	rq := client.NewSubscribeRequest(a.clientID, sub)
+	subCtx, cancel, err := client.NewSignedParentTimeoutContext(ctx, a.signer, rq,
+		subscribe.DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	subscribeClient, err := lc.Subscribe(subCtx, rq)
+	if err != nil {
+		return err
+	}
+	for {
+		rp, err := subscribeClient.Recv()
+		if err != nil {
+			return err
+		}
+		if err := api.ValidatePublication(rp.Value); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case received <- rp.Value:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package author
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/subscribe"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestAuthor_Subscribe_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	origRetryWait := subscribeRetryWait
+	subscribeRetryWait = 10 * time.Millisecond
+	defer func() { subscribeRetryWait = origRetryWait }()
+
+	nPubs := 4
+	pubs := make([]*api.Publication, nPubs)
+	expected := make(map[string]struct{})
+	for i := range pubs {
+		pubs[i] = api.NewTestPublication(rng)
+		expected[id.FromBytes(pubs[i].EnvelopeKey).String()] = struct{}{}
+	}
+
+	// all initial subscriptions fail, so publications are only received after reconnecting;
+	// each librarian then sends every publication
+	lc := &fixedSubscribeClient{
+		pubs:  pubs,
+		nErrs: subscribeNLibrarians,
+	}
+	a := newLocateAuthor(rng, &fixedClientBalancer{client: lc})
+	sub, err := subscribe.NewReaderSubscription([][]byte{}, 0.5, rng)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	envKeys, err := a.Subscribe(ctx, sub.ReaderPublicKeys)
+	assert.Nil(t, err)
+
+	// check each publication is received exactly once
+	received := make(map[string]struct{})
+	for range pubs {
+		select {
+		case envKey := <-envKeys:
+			received[envKey.String()] = struct{}{}
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "timed out waiting for publication")
+		}
+	}
+	assert.Equal(t, expected, received)
+	select {
+	case envKey := <-envKeys:
+		assert.Fail(t, "received duplicate publication", envKey.String())
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.True(t, lc.subscribeCount() > subscribeNLibrarians)
+
+	// check channel is closed once context is done
+	cancel()
+	for range envKeys {
+		// drain any publications sent before the context was done
+	}
+}
+
+func TestAuthor_Subscribe_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	a := newLocateAuthor(rng, &fixedClientBalancer{})
+
+	// check nil filter triggers error
+	envKeys, err := a.Subscribe(context.Background(), nil)
+	assert.Equal(t, subscribe.ErrNilFilter, err)
+	assert.Nil(t, envKeys)
+
+	// check invalid filter triggers error
+	envKeys, err = a.Subscribe(context.Background(), &api.BloomFilter{})
+	assert.Equal(t, api.ErrEmptySubscriptionFilters, err)
+	assert.Nil(t, envKeys)
+}
+
+func TestAuthor_Subscribe_librarianErr(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	origRetryWait := subscribeRetryWait
+	subscribeRetryWait = 10 * time.Millisecond
+	defer func() { subscribeRetryWait = origRetryWait }()
+
+	// check subscriptions keep retrying when no librarians are available
+	cb := &fixedClientBalancer{err: errors.New("some Next error")}
+	a := newLocateAuthor(rng, cb)
+	sub, err := subscribe.NewReaderSubscription([][]byte{}, 0.5, rng)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	envKeys, err := a.Subscribe(ctx, sub.ReaderPublicKeys)
+	assert.Nil(t, err)
+
+	// check channel is closed without any publications once context is done
+	for envKey := range envKeys {
+		assert.Fail(t, "unexpected publication", envKey.String())
+	}
+}
+
+type fixedSubscribeClient struct {
+	api.LibrarianClient
+	pubs        []*api.Publication
+	nErrs       int
+	nSubscribes int
+	mu          sync.Mutex
+}
+
+func (f *fixedSubscribeClient) Subscribe(
+	ctx context.Context, in *api.SubscribeRequest, opts ...grpc.CallOption,
+) (api.Librarian_SubscribeClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nSubscribes++
+	if f.nSubscribes <= f.nErrs {
+		return nil, errors.New("some Subscribe error")
+	}
+	return &fixedSubscribeStream{ctx: ctx, pubs: f.pubs}, nil
+}
+
+func (f *fixedSubscribeClient) subscribeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nSubscribes
+}
+
+// fixedSubscribeStream sends each publication and then blocks until its context is done.
+type fixedSubscribeStream struct {
+	grpc.ClientStream
+	ctx  context.Context
+	pubs []*api.Publication
+	i    int
+}
+
+func (f *fixedSubscribeStream) Recv() (*api.SubscribeResponse, error) {
+	if f.i < len(f.pubs) {
+		pub := f.pubs[f.i]
+		f.i++
+		pubKey, err := api.GetKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return &api.SubscribeResponse{
+			Metadata: &api.ResponseMetadata{},
+			Key:      pubKey.Bytes(),
+			Value:    pub,
+		}, nil
+	}
+	<-f.ctx.Done()
+	return nil, f.ctx.Err()
+}
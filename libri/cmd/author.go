@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"crypto/ecdsa"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	lauthor "github.com/drausin/libri/libri/author"
@@ -13,6 +14,7 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 	"github.com/drausin/libri/libri/common/id"
 	"io"
+	"log"
 	"time"
 )
 
@@ -22,13 +24,23 @@ const (
 	passphraseVar        = "passphrase"
 	authorLibrariansFlag = "authorLibrarians"
 	timeoutFlag          = "timeout"
+	authorConfigFlag     = "authorConfig"
 )
 
 // authorCmd represents the author command
 var authorCmd = &cobra.Command{
 	Use:   "author",
 	Short: "run an author client of the libri network",
-	Long:  `TODO (drausin) add longer description and examples here`,
+	Long: `Run an author client of the libri network. Settings are read from flags, LIBRI_ environment
+variables, and an optional YAML, TOML, or JSON config file given with the authorConfig flag, in
+that order of precedence. Config file keys are flag names, e.g.,
+
+	authorLibrarians: ["127.0.0.1:20100", "127.0.0.1:20101"]
+	keychainsDir: /path/to/keychains
+	parallelism: 8`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return readAuthorConfigFile()
+	},
 }
 
 func init() {
@@ -36,9 +48,11 @@ func init() {
 
 	authorCmd.PersistentFlags().StringP(keychainDirFlag, "k", "", "local keychains directory")
 	authorCmd.PersistentFlags().StringSliceP(authorLibrariansFlag, "a", nil,
-		"comma-separated addresses (IPv4:Port) of librarian(s)")
+		"comma-separated addresses (Host:Port, with IPv6 hosts in brackets) of librarian(s)")
 	authorCmd.PersistentFlags().Int(timeoutFlag, 5,
 		"timeout (seconds) for requests to librarians")
+	authorCmd.PersistentFlags().StringP(authorConfigFlag, "c", "",
+		"path of YAML, TOML, or JSON author config file")
 
 	// bind viper flags
 	viper.SetEnvPrefix(envVarPrefix) // look for env vars with "LIBRI_" prefix
@@ -82,12 +96,39 @@ type authorConfigGetter interface {
 type authorConfigGetterImpl struct{}
 
 func (*authorConfigGetterImpl) get(librariansFlag string) (*author.Config, *zap.Logger, error) {
+	return getAuthorConfig(librariansFlag)
+}
+
+// readAuthorConfigFile reads the author config file, if one is given, into viper so its values
+// back those not given by flags or environment variables.
+func readAuthorConfigFile() error {
+	configFile := viper.GetString(authorConfigFlag)
+	if configFile == "" {
+		return nil
+	}
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		log.Printf("fatal error reading author config file: %v", err)
+		return err
+	}
+	return nil
+}
+
+func getAuthorConfig(librariansFlag string) (*author.Config, *zap.Logger, error) {
 	config := author.NewDefaultConfig().
 		WithDataDir(viper.GetString(dataDirFlag)).
-		WithLogLevel(getLogLevel())
+		WithDefaultDBDir(). // depends on DataDir
+		WithKeychainDir(viper.GetString(keychainDirFlag)).
+		WithDBBackend(viper.GetString(dbBackendFlag)).
+		WithLogLevel(getLogLevel()).
+		WithStreamDownloads(viper.GetBool(streamFlag))
 	timeout := time.Duration(viper.GetInt(timeoutFlag) * 1e9)
 	config.Publish.PutTimeout = timeout
 	config.Publish.GetTimeout = timeout
+	if parallelism := viper.GetInt(parallelismFlag); parallelism > 0 {
+		config.Publish.PutParallelism = uint32(parallelism)
+		config.Publish.GetParallelism = uint32(parallelism)
+	}
 
 	logger := clogging.NewDevLogger(config.LogLevel)
 	librarianNetAddrs, err := server.ParseAddrs(viper.GetStringSlice(librariansFlag))
@@ -96,12 +137,23 @@ func (*authorConfigGetterImpl) get(librariansFlag string) (*author.Config, *zap.
 		return nil, logger, err
 	}
 	config.WithLibrarianAddrs(librarianNetAddrs)
+	creds, err := getClientCredentials()
+	if err != nil {
+		logger.Error("unable to load client TLS credentials", zap.Error(err))
+		return nil, logger, err
+	}
+	config.WithTransportCredentials(creds)
 
 	logger.Info("author configuration",
 		zap.String(librariansFlag, fmt.Sprintf("%v", config.LibrarianAddrs)),
 		zap.String(dataDirFlag, config.DataDir),
+		zap.String(keychainDirFlag, config.KeychainDir),
+		zap.String(dbBackendFlag, config.DBBackend),
 		zap.Stringer(logLevelFlag, config.LogLevel),
 		zap.Int(timeoutFlag, int(timeout.Seconds())),
+		zap.Uint32(parallelismFlag, config.Publish.PutParallelism),
+		zap.Bool(streamFlag, config.StreamDownloads),
+		zap.Bool(tlsFlag, config.TransportCredentials != nil),
 	)
 	return config, logger, nil
 }
@@ -133,13 +185,27 @@ func (*authorUploaderImpl) upload(author *lauthor.Author, content io.Reader, med
 
 // authorDownloader just wraps an *author.Author Download call for the same reason as authorUploader
 type authorDownloader interface {
-	download(author *lauthor.Author, content io.Writer, envelopeKey id.ID) error
+	download(author *lauthor.Author, content io.Writer, envelopeKey id.ID) (string, error)
 }
 
 type authorDownloaderImpl struct{}
 
 func (*authorDownloaderImpl) download(
 	author *lauthor.Author, content io.Writer, envelopeKey id.ID,
-) error {
+) (string, error) {
 	return author.Download(content, envelopeKey)
 }
+
+// authorSharer just wraps an *author.Author Share call for the same reason as authorUploader
+type authorSharer interface {
+	share(author *lauthor.Author, envelopeKey id.ID, readerPub *ecdsa.PublicKey) (id.ID, error)
+}
+
+type authorSharerImpl struct{}
+
+func (*authorSharerImpl) share(
+	author *lauthor.Author, envelopeKey id.ID, readerPub *ecdsa.PublicKey,
+) (id.ID, error) {
+	_, sharedEnvelopeKey, err := author.Share(envelopeKey, readerPub)
+	return sharedEnvelopeKey, err
+}
@@ -9,10 +9,13 @@ import (
 	"github.com/drausin/libri/libri/author"
 	"github.com/drausin/libri/libri/common/logging"
 	"github.com/drausin/libri/libri/author/keychain"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/pkg/errors"
 	"log"
 	"io/ioutil"
 	"os"
+	"path"
+	"time"
 )
 
 
@@ -61,6 +64,7 @@ func TestAuthorConfigGetter_get_ok(t *testing.T) {
 	libAddrsArg := strings.Join(libAddrs, " ")
 	log.Print(libAddrsArg)
 	viper.Set(dataDirFlag, dataDir)
+	viper.Set(dbBackendFlag, db.BadgerDBBackend)
 	viper.Set(logLevelFlag, logLevel)
 	viper.Set(authorLibrariansFlag, libAddrsArg)
 	acg := &authorConfigGetterImpl{}
@@ -69,6 +73,7 @@ func TestAuthorConfigGetter_get_ok(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, logLevel, config.LogLevel)
+	assert.Equal(t, db.BadgerDBBackend, config.DBBackend)
 	assert.Equal(t, len(libAddrs), len(config.LibrarianAddrs))
 	for i, la := range config.LibrarianAddrs {
 		assert.Equal(t, libAddrs[i], la.String())
@@ -87,6 +92,110 @@ func TestAuthorConfigGetter_get_err(t *testing.T) {
 	assert.NotNil(t, logger)  // still should have been created
 }
 
+func TestGetAuthorConfig_ok(t *testing.T) {
+	dataDir, keychainDir := "some/data/dir", "some/keychain/dir"
+	parallelism := 6
+	viper.Set(dataDirFlag, dataDir)
+	viper.Set(keychainDirFlag, keychainDir)
+	viper.Set(dbBackendFlag, db.BadgerDBBackend)
+	viper.Set(logLevelFlag, zap.DebugLevel)
+	viper.Set(parallelismFlag, parallelism)
+	viper.Set(authorLibrariansFlag, "127.0.0.1:1234 127.0.0.1:5678")
+
+	config, logger, err := getAuthorConfig(authorLibrariansFlag)
+	assert.Nil(t, err)
+	assert.NotNil(t, logger)
+	assert.Equal(t, dataDir, config.DataDir)
+	assert.Equal(t, dataDir+"/"+author.DBSubDir, config.DbDir)
+	assert.Equal(t, keychainDir, config.KeychainDir)
+	assert.Equal(t, db.BadgerDBBackend, config.DBBackend)
+	assert.Equal(t, uint32(parallelism), config.Publish.PutParallelism)
+	assert.Equal(t, uint32(parallelism), config.Publish.GetParallelism)
+	assert.Equal(t, 2, len(config.LibrarianAddrs))
+	assert.Nil(t, config.TransportCredentials)
+
+	// check TLS flag gives transport credentials
+	viper.Set(tlsFlag, true)
+	defer viper.Set(tlsFlag, false)
+	config, _, err = getAuthorConfig(authorLibrariansFlag)
+	assert.Nil(t, err)
+	assert.NotNil(t, config.TransportCredentials)
+}
+
+func TestGetAuthorConfig_err(t *testing.T) {
+	viper.Set(authorLibrariansFlag, "bad librarian address")
+	config, logger, err := getAuthorConfig(authorLibrariansFlag)
+	assert.NotNil(t, err)
+	assert.Nil(t, config)
+	assert.NotNil(t, logger) // still should have been created
+
+	// check client TLS credentials error bubbles up
+	viper.Set(authorLibrariansFlag, "127.0.0.1:1234")
+	viper.Set(tlsCAFlag, "missing/ca.pem")
+	defer viper.Set(tlsCAFlag, "")
+	config, logger, err = getAuthorConfig(authorLibrariansFlag)
+	assert.NotNil(t, err)
+	assert.Nil(t, config)
+	assert.NotNil(t, logger)
+}
+
+func TestReadAuthorConfigFile_ok(t *testing.T) {
+	// check no config file is ok
+	viper.Set(authorConfigFlag, "")
+	err := readAuthorConfigFile()
+	assert.Nil(t, err)
+
+	// check config file values are read
+	configDir, err := ioutil.TempDir("", "author-config")
+	assert.Nil(t, err)
+	configFile := path.Join(configDir, "author.yml")
+	err = ioutil.WriteFile(configFile, []byte("timeout: 20\nstream: true\n"), 0600)
+	assert.Nil(t, err)
+	viper.Set(authorConfigFlag, configFile)
+
+	err = readAuthorConfigFile()
+	assert.Nil(t, err)
+	config, _, err := getAuthorConfig(authorLibrariansFlag)
+	assert.Nil(t, err)
+	assert.Equal(t, 20*time.Second, config.Publish.PutTimeout)
+	assert.True(t, config.StreamDownloads)
+
+	// reset config file values to flag defaults for subsequent tests
+	viper.Set(authorConfigFlag, "")
+	viper.Set(timeoutFlag, 5)
+	viper.Set(streamFlag, false)
+	assert.Nil(t, os.RemoveAll(configDir))
+}
+
+func TestReadAuthorConfigFile_err(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "author-config")
+	assert.Nil(t, err)
+
+	// check missing config file errors
+	viper.Set(authorConfigFlag, path.Join(configDir, "missing.yml"))
+	err = readAuthorConfigFile()
+	assert.NotNil(t, err)
+
+	// check unsupported config file type errors
+	configFile := path.Join(configDir, "author.unsupported")
+	err = ioutil.WriteFile(configFile, []byte("timeout: 20\n"), 0600)
+	assert.Nil(t, err)
+	viper.Set(authorConfigFlag, configFile)
+	err = readAuthorConfigFile()
+	assert.NotNil(t, err)
+
+	// check malformed config file errors
+	configFile = path.Join(configDir, "author.toml")
+	err = ioutil.WriteFile(configFile, []byte("timeout = = 20\n"), 0600)
+	assert.Nil(t, err)
+	viper.Set(authorConfigFlag, configFile)
+	err = readAuthorConfigFile()
+	assert.NotNil(t, err)
+
+	viper.Set(authorConfigFlag, "")
+	assert.Nil(t, os.RemoveAll(configDir))
+}
+
 type fixedAuthorConfigGetter struct {
 	config *author.Config
 	logger *zap.Logger
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"os"
@@ -13,6 +14,10 @@ import (
 const (
 	envelopeKeyFlag = "envelopeKey"
 	downFilepathFlag = "downFilepath"
+	streamFlag       = "stream"
+
+	// stdoutFilepath is the download filepath for writing content to stdout
+	stdoutFilepath = "-"
 )
 
 var (
@@ -21,10 +26,16 @@ var (
 
 // downloadCmd represents the download command
 var downloadCmd = &cobra.Command{
-	Use:   "download",
-	Short: "A brief description of your command",
-	Long: `TODO (drausin) add long description and examples`,
+	Use:   "download [envelope key]",
+	Short: "download a document from the libri network to a local file",
+	Long: `Download the document with the given envelope key to a local file, or stdout if the file
+is "-", and report its media type on stderr. The envelope key may be given as an argument or with
+the envelopeKey flag.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// envelope key argument takes precedence over the flag
+			viper.Set(envelopeKeyFlag, args[0])
+		}
 		if err := newFileDownloader().download(); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -38,9 +49,11 @@ func init() {
 	downloadCmd.Flags().Uint32P(parallelismFlag, "n", 3,
 		"number of parallel processes")
 	downloadCmd.Flags().StringP(downFilepathFlag, "f", "",
-		"path of local file to write downloaded contents to")
+		"path of local file to write downloaded contents to, or - for stdout")
 	downloadCmd.Flags().StringP(envelopeKeyFlag, "e", "",
 		"key of envelope to download")
+	downloadCmd.Flags().Bool(streamFlag, false,
+		"acquire pages as they are written rather than all before writing")
 
 	// bind viper flags
 	viper.SetEnvPrefix(envVarPrefix) // look for env vars with "LIBRI_" prefix
@@ -61,13 +74,17 @@ func newFileDownloader() fileDownloader {
 		kc: &keychainsGetterImpl{
 			pg: &terminalPassphraseGetter{},
 		},
+		out:    os.Stdout,
+		report: os.Stderr,
 	}
 }
 
 type fileDownloaderImpl struct {
-	ag  authorGetter
-	ad  authorDownloader
-	kc  keychainsGetter
+	ag     authorGetter
+	ad     authorDownloader
+	kc     keychainsGetter
+	out    io.Writer
+	report io.Writer
 }
 
 func (d *fileDownloaderImpl) download() error {
@@ -91,7 +108,7 @@ func (d *fileDownloaderImpl) download() error {
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(downFilepath)
+	file, err := d.create(downFilepath)
 	if err != nil {
 		return err
 	}
@@ -99,9 +116,32 @@ func (d *fileDownloaderImpl) download() error {
 		zap.Stringer("envelope_key", envelopeKey),
 		zap.String("filepath", downFilepath),
 	)
-	err = d.ad.download(author, file, envelopeKey)
+	mediaType, err := d.ad.download(author, file, envelopeKey)
 	if err != nil {
 		return err
 	}
+	logger.Info("downloaded document",
+		zap.Stringer("envelope_key", envelopeKey),
+		zap.String("media_type", mediaType),
+	)
+	if _, err = fmt.Fprintf(d.report, "media type: %s\n", mediaType); err != nil {
+		return err
+	}
 	return file.Close()
+}
+
+// create returns the destination for the downloaded content, which is stdout for stdoutFilepath.
+func (d *fileDownloaderImpl) create(downFilepath string) (io.WriteCloser, error) {
+	if downFilepath == stdoutFilepath {
+		return nopWriteCloser{d.out}, nil
+	}
+	return os.Create(downFilepath)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
 }
\ No newline at end of file
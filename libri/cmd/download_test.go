@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	lauthor "github.com/drausin/libri/libri/author"
 	"github.com/drausin/libri/libri/common/id"
@@ -19,13 +20,15 @@ func TestNewFileDownloader(t *testing.T) {
 }
 
 func TestFileDownloader_download_ok(t *testing.T) {
+	report := new(bytes.Buffer)
 	d := &fileDownloaderImpl{
 		ag: &fixedAuthorGetter{
 			author: nil, // ok since we're passing it into a mocked method anyway
 			logger: server.NewDevInfoLogger(),
 		},
-		ad: &fixedAuthorDownloader{},
-		kc: &fixedKeychainsGetter{}, // ok that KCs are null since passing to mock
+		ad:     &fixedAuthorDownloader{mediaType: "application/pdf"},
+		kc:     &fixedKeychainsGetter{}, // ok that KCs are null since passing to mock
+		report: report,
 	}
 	toDownloadFile, err := ioutil.TempFile("", "to-download")
 	assert.Nil(t, err)
@@ -36,11 +39,36 @@ func TestFileDownloader_download_ok(t *testing.T) {
 
 	err = d.download()
 	assert.Nil(t, err)
+	assert.Equal(t, "media type: application/pdf\n", report.String())
 
 	err = os.Remove(toDownloadFile.Name())
 	assert.Nil(t, err)
 }
 
+func TestFileDownloader_download_stdout(t *testing.T) {
+	out, report := new(bytes.Buffer), new(bytes.Buffer)
+	d := &fileDownloaderImpl{
+		ag: &fixedAuthorGetter{
+			author: nil, // ok since we're passing it into a mocked method anyway
+			logger: server.NewDevInfoLogger(),
+		},
+		ad: &fixedAuthorDownloader{
+			mediaType: "text/plain",
+			content:   []byte("some downloaded content"),
+		},
+		kc:     &fixedKeychainsGetter{}, // ok that KCs are null since passing to mock
+		out:    out,
+		report: report,
+	}
+	viper.Set(downFilepathFlag, stdoutFilepath)
+	viper.Set(envelopeKeyFlag, id.LowerBound.String())
+
+	err := d.download()
+	assert.Nil(t, err)
+	assert.Equal(t, "some downloaded content", out.String())
+	assert.Equal(t, "media type: text/plain\n", report.String())
+}
+
 func TestFileDownloader_download_err(t *testing.T) {
 	// should error on missing envelopeKey
 	d1 := &fileDownloaderImpl{}
@@ -99,11 +127,19 @@ func TestFileDownloader_download_err(t *testing.T) {
 }
 
 type fixedAuthorDownloader struct {
-	err error
+	mediaType string
+	content   []byte
+	err       error
 }
 
 func (f *fixedAuthorDownloader) download(
 	author *lauthor.Author, content io.Writer, envelopeKey id.ID,
-) error {
-	return f.err
+) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	if _, err := content.Write(f.content); err != nil {
+		return "", err
+	}
+	return f.mediaType, nil
 }
@@ -1,23 +1,42 @@
 package cmd
 
 import (
-	"github.com/spf13/cobra"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	lauthor "github.com/drausin/libri/libri/author"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+const (
+	jsonFlag = "json"
+)
+
+var errUnhealthy = errors.New("not all librarians are healthy")
+
 // healthCmd represents the health command
 var healthCmd = &cobra.Command{
 	Use:   "health",
 	Short: "check health of librarian peers",
-	Long: `TODO (drausin) more detailed description`,
+	Long: `Check the health of each librarian, printing a table (or JSON with the json flag) of its
+serving status and round-trip latency. Exits non-zero if any librarian is not healthy.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		author, logger, err := newTestAuthorGetter().get()
 		if err != nil {
 			logger.Error("fatal error while initializing author", zap.Error(err))
 			os.Exit(1)
 		}
-		if allHealthy, _ := author.Healthcheck(); !allHealthy {
+		if err := newHealthReporter(os.Stdout).report(author); err != nil {
+			logger.Error("error while checking health", zap.Error(err))
 			os.Exit(1)
 		}
 	},
@@ -25,4 +44,105 @@ var healthCmd = &cobra.Command{
 
 func init() {
 	testCmd.AddCommand(healthCmd)
+
+	healthCmd.Flags().Bool(jsonFlag, false, "print health as JSON")
+
+	// bind viper flags
+	viper.SetEnvPrefix(envVarPrefix) // look for env vars with "LIBRI_" prefix
+	viper.AutomaticEnv()             // read in environment variables that match
+	if err := viper.BindPFlags(healthCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
+
+type healthReporter interface {
+	report(author *lauthor.Author) error
+}
+
+type healthReporterImpl struct {
+	ah  authorHealthchecker
+	out io.Writer
+}
+
+func newHealthReporter(out io.Writer) healthReporter {
+	return &healthReporterImpl{
+		ah:  &authorHealthcheckerImpl{},
+		out: out,
+	}
+}
+
+// librarianHealth is the health of a single librarian.
+type librarianHealth struct {
+	Address   string  `json:"address"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// healthReport is the health of all librarians.
+type healthReport struct {
+	Healthy    bool               `json:"healthy"`
+	Librarians []*librarianHealth `json:"librarians"`
+}
+
+func (r *healthReporterImpl) report(author *lauthor.Author) error {
+	allHealthy, statuses, latencies := r.ah.healthcheck(author)
+	hr := &healthReport{
+		Healthy:    allHealthy,
+		Librarians: make([]*librarianHealth, 0, len(statuses)),
+	}
+	addrs := make([]string, 0, len(statuses))
+	for addr := range statuses {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		hr.Librarians = append(hr.Librarians, &librarianHealth{
+			Address:   addr,
+			Status:    statuses[addr].String(),
+			LatencyMs: float64(latencies[addr]) / float64(time.Millisecond),
+		})
+	}
+
+	var err error
+	if viper.GetBool(jsonFlag) {
+		err = json.NewEncoder(r.out).Encode(hr)
+	} else {
+		err = writeHealthTable(r.out, hr)
+	}
+	if err != nil {
+		return err
+	}
+	if !allHealthy {
+		return errUnhealthy
+	}
+	return nil
+}
+
+func writeHealthTable(out io.Writer, hr *healthReport) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tSTATUS\tLATENCY")
+	for _, lh := range hr.Librarians {
+		fmt.Fprintf(w, "%s\t%s\t%.1fms\n", lh.Address, lh.Status, lh.LatencyMs)
+	}
+	return w.Flush()
+}
+
+// authorHealthchecker just wraps an *author.Author Healthcheck call for the same reason as
+// authorUploader
+type authorHealthchecker interface {
+	healthcheck(author *lauthor.Author) (
+		bool,
+		map[string]healthpb.HealthCheckResponse_ServingStatus,
+		map[string]time.Duration,
+	)
+}
+
+type authorHealthcheckerImpl struct{}
+
+func (*authorHealthcheckerImpl) healthcheck(author *lauthor.Author) (
+	bool,
+	map[string]healthpb.HealthCheckResponse_ServingStatus,
+	map[string]time.Duration,
+) {
+	return author.Healthcheck()
 }
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	lauthor "github.com/drausin/libri/libri/author"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestNewHealthReporter(t *testing.T) {
+	r := newHealthReporter(new(bytes.Buffer))
+	assert.NotNil(t, r)
+}
+
+func TestHealthReporter_report_table(t *testing.T) {
+	viper.Set(jsonFlag, false)
+
+	// check healthy librarians are printed in address order
+	out := new(bytes.Buffer)
+	r := &healthReporterImpl{
+		ah: &fixedAuthorHealthchecker{
+			allHealthy: true,
+			statuses: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				"127.0.0.1:20101": healthpb.HealthCheckResponse_SERVING,
+				"127.0.0.1:20100": healthpb.HealthCheckResponse_SERVING,
+			},
+			latencies: map[string]time.Duration{
+				"127.0.0.1:20101": 2 * time.Millisecond,
+				"127.0.0.1:20100": 1500 * time.Microsecond,
+			},
+		},
+		out: out,
+	}
+	err := r.report(nil)
+	assert.Nil(t, err)
+	expected := "ADDRESS          STATUS   LATENCY\n" +
+		"127.0.0.1:20100  SERVING  1.5ms\n" +
+		"127.0.0.1:20101  SERVING  2.0ms\n"
+	assert.Equal(t, expected, out.String())
+
+	// check unhealthy librarian gives error
+	out = new(bytes.Buffer)
+	r = &healthReporterImpl{
+		ah: &fixedAuthorHealthchecker{
+			allHealthy: false,
+			statuses: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				"127.0.0.1:20100": healthpb.HealthCheckResponse_NOT_SERVING,
+			},
+		},
+		out: out,
+	}
+	err = r.report(nil)
+	assert.Equal(t, errUnhealthy, err)
+	assert.Contains(t, out.String(), "NOT_SERVING")
+}
+
+func TestHealthReporter_report_json(t *testing.T) {
+	viper.Set(jsonFlag, true)
+	defer viper.Set(jsonFlag, false)
+	out := new(bytes.Buffer)
+	r := &healthReporterImpl{
+		ah: &fixedAuthorHealthchecker{
+			allHealthy: false,
+			statuses: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				"127.0.0.1:20100": healthpb.HealthCheckResponse_SERVING,
+				"127.0.0.1:20101": healthpb.HealthCheckResponse_UNKNOWN,
+			},
+			latencies: map[string]time.Duration{
+				"127.0.0.1:20100": time.Millisecond,
+			},
+		},
+		out: out,
+	}
+	err := r.report(nil)
+	assert.Equal(t, errUnhealthy, err)
+
+	hr := &healthReport{}
+	err = json.Unmarshal(out.Bytes(), hr)
+	assert.Nil(t, err)
+	assert.False(t, hr.Healthy)
+	assert.Equal(t, []*librarianHealth{
+		{Address: "127.0.0.1:20100", Status: "SERVING", LatencyMs: 1},
+		{Address: "127.0.0.1:20101", Status: "UNKNOWN", LatencyMs: 0},
+	}, hr.Librarians)
+}
+
+type fixedAuthorHealthchecker struct {
+	allHealthy bool
+	statuses   map[string]healthpb.HealthCheckResponse_ServingStatus
+	latencies  map[string]time.Duration
+}
+
+func (f *fixedAuthorHealthchecker) healthcheck(author *lauthor.Author) (
+	bool,
+	map[string]healthpb.HealthCheckResponse_ServingStatus,
+	map[string]time.Duration,
+) {
+	return f.allHealthy, f.statuses, f.latencies
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/drausin/libri/libri/author"
+	"github.com/drausin/libri/libri/common/ecid"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// idCmd represents the id command
+var idCmd = &cobra.Command{
+	Use:   "id",
+	Short: "print the client ID of the author in the data directory",
+	Long: `Print the hex public client ID of the author whose DB is in the local data directory,
+creating and saving a new one if the DB doesn't yet have one. This is the ID librarians see in
+the metadata of the author's requests.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := newIDPrinter(os.Stdout).print(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(idCmd)
+}
+
+type idPrinter interface {
+	print() error
+}
+
+type idPrinterImpl struct {
+	load func(config *author.Config, logger *zap.Logger) (ecid.ID, error)
+	out  io.Writer
+}
+
+func newIDPrinter(out io.Writer) idPrinter {
+	return &idPrinterImpl{
+		load: author.LoadClientID,
+		out:  out,
+	}
+}
+
+func (p *idPrinterImpl) print() error {
+	config := author.NewDefaultConfig().
+		WithDataDir(viper.GetString(dataDirFlag)).
+		WithDefaultDBDir(). // depends on DataDir
+		WithDBBackend(viper.GetString(dbBackendFlag)).
+		WithLogLevel(getLogLevel())
+	logger := clogging.NewDevLogger(config.LogLevel)
+	clientID, err := p.load(config, logger)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(p.out, clientID.String())
+	return nil
+}
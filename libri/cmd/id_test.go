@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/drausin/libri/libri/author"
+	"github.com/drausin/libri/libri/common/db"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewIDPrinter(t *testing.T) {
+	p := newIDPrinter(new(bytes.Buffer))
+	assert.NotNil(t, p)
+}
+
+func TestIDPrinter_print_ok(t *testing.T) {
+	dataDir := "some/data/dir"
+	viper.Set(dataDirFlag, dataDir)
+	viper.Set(dbBackendFlag, db.MemoryDBBackend)
+	defer viper.Set(dataDirFlag, "")
+	defer viper.Set(dbBackendFlag, db.RocksDBBackend)
+
+	rng := rand.New(rand.NewSource(0))
+	clientID := ecid.NewPseudoRandom(rng)
+	out := new(bytes.Buffer)
+	p := &idPrinterImpl{
+		load: func(config *author.Config, logger *zap.Logger) (ecid.ID, error) {
+			assert.Equal(t, dataDir, config.DataDir)
+			assert.Equal(t, filepath.Join(dataDir, author.DBSubDir), config.DbDir)
+			assert.Equal(t, db.MemoryDBBackend, config.DBBackend)
+			return clientID, nil
+		},
+		out: out,
+	}
+
+	err := p.print()
+	assert.Nil(t, err)
+	assert.Equal(t, clientID.String()+"\n", out.String())
+}
+
+func TestIDPrinter_print_err(t *testing.T) {
+	out := new(bytes.Buffer)
+	p := &idPrinterImpl{
+		load: func(config *author.Config, logger *zap.Logger) (ecid.ID, error) {
+			return nil, errors.New("some load error")
+		},
+		out: out,
+	}
+
+	err := p.print()
+	assert.NotNil(t, err)
+	assert.Empty(t, out.String())
+}
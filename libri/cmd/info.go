@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/drausin/libri/libri/librarian/server"
+	"github.com/spf13/cobra"
+)
+
+const (
+	infoTimeout = 5 * time.Second
+)
+
+var errMissingAddress = errors.New("missing librarian address")
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info <address>",
+	Short: "get the peer ID and routing table stats of a librarian",
+	Long: `Get the public peer ID, number of peers, number of routing table buckets, and number of
+peers in each bucket of the librarian at the given Host:Port address.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := newInfoPrinter(os.Stdout).print(args); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(infoCmd)
+}
+
+type infoPrinter interface {
+	print(args []string) error
+}
+
+type infoPrinterImpl struct {
+	connect  func(addr string) (api.Informer, error)
+	informer librarianInformer
+	out      io.Writer
+}
+
+func newInfoPrinter(out io.Writer) infoPrinter {
+	clientID := ecid.NewRandom()
+	return &infoPrinterImpl{
+		connect: connectLibrarian,
+		informer: &librarianInformerImpl{
+			clientID: clientID,
+			signer:   client.NewSigner(clientID.Key()),
+			timeout:  infoTimeout,
+		},
+		out: out,
+	}
+}
+
+func (p *infoPrinterImpl) print(args []string) error {
+	if len(args) != 1 {
+		return errMissingAddress
+	}
+	lc, err := p.connect(args[0])
+	if err != nil {
+		return err
+	}
+	rp, err := p.informer.info(lc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "peer ID:      %s\n", id.FromBytes(rp.PeerId))
+	fmt.Fprintf(p.out, "peers:        %d\n", rp.NumPeers)
+	fmt.Fprintf(p.out, "buckets:      %d\n", rp.NumBuckets)
+	fmt.Fprintf(p.out, "bucket peers: %v\n", rp.BucketPeers)
+	return nil
+}
+
+func connectLibrarian(addr string) (api.Informer, error) {
+	netAddrs, err := server.ParseAddrs([]string{addr})
+	if err != nil {
+		return nil, err
+	}
+	creds, err := getClientCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return api.NewSecureConnector(netAddrs[0], creds).Connect()
+}
+
+type librarianInformer interface {
+	info(lc api.Informer) (*api.InfoResponse, error)
+}
+
+type librarianInformerImpl struct {
+	clientID ecid.ID
+	signer   client.Signer
+	timeout  time.Duration
+}
+
+func (i *librarianInformerImpl) info(lc api.Informer) (*api.InfoResponse, error) {
+	rq := client.NewInfoRequest(i.clientID)
+	ctx, cancel, err := client.NewSignedTimeoutContext(i.signer, rq, i.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := lc.Info(ctx, rq)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(rq.Metadata.RequestId, rp.Metadata.RequestId) {
+		return nil, client.ErrUnexpectedRequestID
+	}
+	return rp, nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestNewInfoPrinter(t *testing.T) {
+	p := newInfoPrinter(new(bytes.Buffer))
+	assert.NotNil(t, p)
+}
+
+func TestInfoPrinter_print_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := id.NewPseudoRandom(rng)
+	out := new(bytes.Buffer)
+	p := &infoPrinterImpl{
+		connect: func(addr string) (api.Informer, error) { return nil, nil },
+		informer: &fixedLibrarianInformer{
+			rp: &api.InfoResponse{
+				PeerId:      peerID.Bytes(),
+				NumPeers:    3,
+				NumBuckets:  2,
+				BucketPeers: []uint32{1, 2},
+			},
+		},
+		out: out,
+	}
+
+	err := p.print([]string{"localhost:20100"})
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), peerID.String())
+	assert.Contains(t, out.String(), "[1 2]")
+}
+
+func TestInfoPrinter_print_err(t *testing.T) {
+	okConnect := func(addr string) (api.Informer, error) { return nil, nil }
+
+	// check missing address gives error
+	p := &infoPrinterImpl{}
+	err := p.print([]string{})
+	assert.Equal(t, errMissingAddress, err)
+
+	// check connect error bubbles up
+	p = &infoPrinterImpl{
+		connect: func(addr string) (api.Informer, error) {
+			return nil, errors.New("some Connect error")
+		},
+	}
+	err = p.print([]string{"localhost:20100"})
+	assert.NotNil(t, err)
+
+	// check info error bubbles up
+	p = &infoPrinterImpl{
+		connect:  okConnect,
+		informer: &fixedLibrarianInformer{err: errors.New("some info error")},
+	}
+	err = p.print([]string{"localhost:20100"})
+	assert.NotNil(t, err)
+}
+
+func TestConnectLibrarian_err(t *testing.T) {
+	lc, err := connectLibrarian("bad address")
+	assert.NotNil(t, err)
+	assert.Nil(t, lc)
+
+	// check client TLS credentials error bubbles up
+	viper.Set(tlsCAFlag, "missing/ca.pem")
+	defer viper.Set(tlsCAFlag, "")
+	lc, err = connectLibrarian("localhost:20100")
+	assert.NotNil(t, err)
+	assert.Nil(t, lc)
+}
+
+func TestLibrarianInformer_info_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	i := &librarianInformerImpl{
+		clientID: ecid.NewPseudoRandom(rng),
+		signer:   &client.TestNoOpSigner{},
+		timeout:  time.Second,
+	}
+	lc := &fixedInformer{numPeers: 3}
+	rp, err := i.info(lc)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), rp.NumPeers)
+}
+
+func TestLibrarianInformer_info_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	i := &librarianInformerImpl{
+		clientID: ecid.NewPseudoRandom(rng),
+		signer:   &client.TestNoOpSigner{},
+		timeout:  time.Second,
+	}
+
+	// check Info error bubbles up
+	rp, err := i.info(&fixedInformer{err: errors.New("some Info error")})
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+
+	// check unexpected request ID gives error
+	rp, err = i.info(&fixedInformer{badRequestID: true})
+	assert.Equal(t, client.ErrUnexpectedRequestID, err)
+	assert.Nil(t, rp)
+}
+
+type fixedLibrarianInformer struct {
+	rp  *api.InfoResponse
+	err error
+}
+
+func (f *fixedLibrarianInformer) info(lc api.Informer) (*api.InfoResponse, error) {
+	return f.rp, f.err
+}
+
+type fixedInformer struct {
+	numPeers     uint32
+	err          error
+	badRequestID bool
+}
+
+func (f *fixedInformer) Info(
+	ctx context.Context, in *api.InfoRequest, opts ...grpc.CallOption,
+) (*api.InfoResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	requestID := in.Metadata.RequestId
+	if f.badRequestID {
+		requestID = id.NewPseudoRandom(rand.New(rand.NewSource(0))).Bytes()
+	}
+	return &api.InfoResponse{
+		Metadata: &api.ResponseMetadata{RequestId: requestID},
+		NumPeers: f.numPeers,
+	}, nil
+}
@@ -86,7 +86,7 @@ func (t *ioTesterImpl) test(author *author.Author, logger *zap.Logger) error {
 			return err
 		}
 		downloadedBuf := new(bytes.Buffer)
-		if err := t.ad.download(author, downloadedBuf, envelopeKey); err != nil {
+		if _, err := t.ad.download(author, downloadedBuf, envelopeKey); err != nil {
 			return err
 		}
 		downloaded := downloadedBuf.Bytes()
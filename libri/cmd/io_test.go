@@ -85,15 +85,15 @@ func (f *fixedAuthorUploaderDownloader) upload(
 
 func (f *fixedAuthorUploaderDownloader) download(
 	author *lauthor.Author, content io.Writer, envelopeKey id.ID,
-) error {
+) (string, error) {
 	if f.downloadErr != nil {
-		return f.downloadErr
+		return "", f.downloadErr
 	}
 	doc, _ := f.uploaded[envelopeKey.String()]
 	if doc == nil {
-		return nil
+		return "", nil
 	}
 	_, err := doc.WriteTo(content)
-	return err
+	return "", err
 }
 
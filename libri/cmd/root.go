@@ -3,16 +3,25 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"github.com/spf13/viper"
+	"github.com/drausin/libri/libri/librarian/server"
+	"google.golang.org/grpc/credentials"
 )
 
 const (
 	dataDirFlag    = "dataDir"
+	dbBackendFlag  = "dbBackend"
 	logLevelFlag   = "logLevel"
 	envVarPrefix   = "LIBRI"
+
+	tlsFlag           = "tls"
+	tlsCAFlag         = "tlsCA"
+	tlsClientCertFlag = "tlsClientCert"
+	tlsClientKeyFlag  = "tlsClientKey"
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -33,8 +42,18 @@ func Execute() {
 func init() {
 	RootCmd.PersistentFlags().StringP(dataDirFlag, "d", "",
 		"local data directory")
+	RootCmd.PersistentFlags().String(dbBackendFlag, db.RocksDBBackend,
+		"DB backend (rocksdb or the pure-Go badger)")
 	RootCmd.PersistentFlags().StringP(logLevelFlag, "l", zap.InfoLevel.String(),
 		"log level")
+	RootCmd.PersistentFlags().Bool(tlsFlag, false,
+		"dial librarians with TLS, implied by the other client TLS flags (plaintext if false)")
+	RootCmd.PersistentFlags().String(tlsCAFlag, "",
+		"path of PEM-encoded CA certificates verifying librarian certificates (system CAs if empty)")
+	RootCmd.PersistentFlags().String(tlsClientCertFlag, "",
+		"path of PEM-encoded TLS client certificate presented to librarians requiring one")
+	RootCmd.PersistentFlags().String(tlsClientKeyFlag, "",
+		"path of PEM-encoded TLS private key of the tlsClientCert certificate")
 
 	// bind viper flags
 	viper.SetEnvPrefix("LIBRI") // look for env vars with "LIBRI_" prefix
@@ -51,4 +70,15 @@ func getLogLevel() zapcore.Level {
 		panic(err)
 	}
 	return ll
-}
\ No newline at end of file
+}
+
+// getClientCredentials returns the transport credentials for dialing librarians given by the
+// client TLS flags, or nil for plaintext connections when none of them are set.
+func getClientCredentials() (credentials.TransportCredentials, error) {
+	caFile := viper.GetString(tlsCAFlag)
+	certFile, keyFile := viper.GetString(tlsClientCertFlag), viper.GetString(tlsClientKeyFlag)
+	if !viper.GetBool(tlsFlag) && caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	return server.NewClientCredentials(caFile, certFile, keyFile)
+}
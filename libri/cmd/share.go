@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	readerPubFlag     = "readerPub"
+	readerPubFileFlag = "readerPubFile"
+)
+
+var (
+	errMissingReaderPub   = errors.New("missing reader public key")
+	errMultipleReaderPubs = errors.New("only one of reader public key and its file may be given")
+)
+
+// shareCmd represents the share command
+var shareCmd = &cobra.Command{
+	Use:   "share <envelope key>",
+	Short: "share a document on the libri network with a reader",
+	Long: `Share the document with the given envelope key with the reader whose hex-encoded public key
+is given with the readerPub flag or read from the readerPubFile flag file, and print the new
+envelope key for that reader.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			viper.Set(envelopeKeyFlag, args[0])
+		}
+		if err := newEnvelopeSharer().share(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	authorCmd.AddCommand(shareCmd)
+
+	shareCmd.Flags().StringP(readerPubFlag, "r", "",
+		"hex-encoded public key of reader to share with")
+	shareCmd.Flags().String(readerPubFileFlag, "",
+		"path of local file containing hex-encoded public key of reader to share with")
+
+	// bind viper flags
+	viper.SetEnvPrefix(envVarPrefix) // look for env vars with "LIBRI_" prefix
+	viper.AutomaticEnv()             // read in environment variables that match
+	if err := viper.BindPFlags(shareCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
+
+type envelopeSharer interface {
+	share() error
+}
+
+type envelopeSharerImpl struct {
+	ag  authorGetter
+	as  authorSharer
+	kc  keychainsGetter
+	out io.Writer
+}
+
+func newEnvelopeSharer() envelopeSharer {
+	return &envelopeSharerImpl{
+		ag: newAuthorGetter(),
+		as: &authorSharerImpl{},
+		kc: &keychainsGetterImpl{
+			pg: &terminalPassphraseGetter{},
+		},
+		out: os.Stdout,
+	}
+}
+
+func (s *envelopeSharerImpl) share() error {
+	envelopeKeyStr := viper.GetString(envelopeKeyFlag)
+	if envelopeKeyStr == "" {
+		return errMissingEnvelopeKey
+	}
+	envelopeKey, err := id.FromString(envelopeKeyStr)
+	if err != nil {
+		return err
+	}
+	readerPub, err := getReaderPub(viper.GetString(readerPubFlag),
+		viper.GetString(readerPubFileFlag))
+	if err != nil {
+		return err
+	}
+	authorKeys, selfReaderKeys, err := s.kc.get()
+	if err != nil {
+		return err
+	}
+	author, logger, err := s.ag.get(authorKeys, selfReaderKeys)
+	if err != nil {
+		return err
+	}
+	logger.Info("sharing document",
+		zap.Stringer("envelope_key", envelopeKey),
+		zap.String("reader_pub", hex.EncodeToString(ecid.ToPublicKeyBytes(readerPub))),
+	)
+	sharedEnvelopeKey, err := s.as.share(author, envelopeKey, readerPub)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(s.out, sharedEnvelopeKey.String())
+	return err
+}
+
+// getReaderPub returns the reader public key from either its hex encoding or the file containing
+// it.
+func getReaderPub(readerPubHex, readerPubFilepath string) (*ecdsa.PublicKey, error) {
+	if readerPubHex != "" && readerPubFilepath != "" {
+		return nil, errMultipleReaderPubs
+	}
+	if readerPubFilepath != "" {
+		readerPubBytes, err := ioutil.ReadFile(readerPubFilepath)
+		if err != nil {
+			return nil, err
+		}
+		readerPubHex = string(readerPubBytes)
+	}
+	if readerPubHex == "" {
+		return nil, errMissingReaderPub
+	}
+	return parseReaderPub(readerPubHex)
+}
+
+// parseReaderPub parses a hex-encoded marshaled public key, rejecting those not on the curve.
+func parseReaderPub(readerPubHex string) (*ecdsa.PublicKey, error) {
+	readerPubBytes, err := hex.DecodeString(strings.TrimSpace(readerPubHex))
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed reader public key")
+	}
+	return ecid.FromPublicKeyBytes(readerPubBytes)
+}
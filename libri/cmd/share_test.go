@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	lauthor "github.com/drausin/libri/libri/author"
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEnvelopeSharer(t *testing.T) {
+	s := newEnvelopeSharer()
+	assert.NotNil(t, s)
+}
+
+func TestEnvelopeSharer_share_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	readerPub := &ecid.NewPseudoRandom(rng).Key().PublicKey
+	sharedEnvelopeKey := id.NewPseudoRandom(rng)
+	as := &fixedAuthorSharer{sharedEnvelopeKey: sharedEnvelopeKey}
+	out := new(bytes.Buffer)
+	s := &envelopeSharerImpl{
+		ag: &fixedAuthorGetter{
+			author: nil, // ok since we're passing it into a mocked method anyway
+			logger: clogging.NewDevInfoLogger(),
+		},
+		as:  as,
+		kc:  &fixedKeychainsGetter{}, // ok that KCs are null since passing to mock
+		out: out,
+	}
+	viper.Set(envelopeKeyFlag, id.LowerBound.String())
+	viper.Set(readerPubFlag, hex.EncodeToString(ecid.ToPublicKeyBytes(readerPub)))
+	viper.Set(readerPubFileFlag, "")
+
+	err := s.share()
+	assert.Nil(t, err)
+	assert.Equal(t, sharedEnvelopeKey.String()+"\n", out.String())
+	assert.Equal(t, id.LowerBound, as.envelopeKey)
+	assert.Equal(t, readerPub.X, as.readerPub.X)
+	assert.Equal(t, readerPub.Y, as.readerPub.Y)
+}
+
+func TestEnvelopeSharer_share_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	readerPub := &ecid.NewPseudoRandom(rng).Key().PublicKey
+	readerPubHex := hex.EncodeToString(ecid.ToPublicKeyBytes(readerPub))
+	viper.Set(readerPubFileFlag, "")
+
+	// should error on missing envelope key
+	s1 := &envelopeSharerImpl{}
+	viper.Set(envelopeKeyFlag, "")
+	err := s1.share()
+	assert.Equal(t, errMissingEnvelopeKey, err)
+
+	// should error on bad envelope key
+	s2 := &envelopeSharerImpl{}
+	viper.Set(envelopeKeyFlag, "0")
+	err = s2.share()
+	assert.NotNil(t, err)
+
+	// should error on missing reader public key
+	s3 := &envelopeSharerImpl{}
+	viper.Set(envelopeKeyFlag, id.LowerBound.String())
+	viper.Set(readerPubFlag, "")
+	err = s3.share()
+	assert.Equal(t, errMissingReaderPub, err)
+
+	// error getting author keys should bubble up
+	viper.Set(readerPubFlag, readerPubHex)
+	s4 := &envelopeSharerImpl{
+		kc: &fixedKeychainsGetter{err: errors.New("some get error")},
+	}
+	err = s4.share()
+	assert.NotNil(t, err)
+
+	// error getting author should bubble up
+	s5 := &envelopeSharerImpl{
+		ag: &fixedAuthorGetter{err: errors.New("some get error")},
+		kc: &fixedKeychainsGetter{},
+	}
+	err = s5.share()
+	assert.NotNil(t, err)
+
+	// share error should bubble up
+	s6 := &envelopeSharerImpl{
+		ag: &fixedAuthorGetter{
+			author: nil, // ok since we're passing it into a mocked method anyway
+			logger: clogging.NewDevInfoLogger(),
+		},
+		as: &fixedAuthorSharer{err: errors.New("some share error")},
+		kc: &fixedKeychainsGetter{},
+	}
+	err = s6.share()
+	assert.NotNil(t, err)
+}
+
+func TestGetReaderPub_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	readerPub := &ecid.NewPseudoRandom(rng).Key().PublicKey
+	readerPubHex := hex.EncodeToString(ecid.ToPublicKeyBytes(readerPub))
+
+	// check from hex
+	pub, err := getReaderPub(readerPubHex, "")
+	assert.Nil(t, err)
+	assert.Equal(t, readerPub.X, pub.X)
+	assert.Equal(t, readerPub.Y, pub.Y)
+
+	// check from file, with trailing newline
+	readerPubFile, err := ioutil.TempFile("", "reader-pub")
+	assert.Nil(t, err)
+	_, err = readerPubFile.WriteString(readerPubHex + "\n")
+	assert.Nil(t, err)
+	err = readerPubFile.Close()
+	assert.Nil(t, err)
+
+	pub, err = getReaderPub("", readerPubFile.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, readerPub.X, pub.X)
+	assert.Equal(t, readerPub.Y, pub.Y)
+
+	err = os.Remove(readerPubFile.Name())
+	assert.Nil(t, err)
+}
+
+func TestGetReaderPub_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	readerPub := &ecid.NewPseudoRandom(rng).Key().PublicKey
+	readerPubBytes := ecid.ToPublicKeyBytes(readerPub)
+	readerPubHex := hex.EncodeToString(readerPubBytes)
+
+	// check missing and multiple reader public keys error
+	pub, err := getReaderPub("", "")
+	assert.Equal(t, errMissingReaderPub, err)
+	assert.Nil(t, pub)
+	pub, err = getReaderPub(readerPubHex, "some/reader/pub/file")
+	assert.Equal(t, errMultipleReaderPubs, err)
+	assert.Nil(t, pub)
+
+	// check missing file errors
+	pub, err = getReaderPub("", "/path/does/not/exist")
+	assert.NotNil(t, err)
+	assert.Nil(t, pub)
+
+	// check malformed keys error
+	cases := []string{
+		"not hex",                          // not hex
+		readerPubHex[:len(readerPubHex)-1], // odd length
+		readerPubHex[:len(readerPubHex)-2], // truncated
+		hex.EncodeToString(append([]byte{0}, readerPubBytes[1:]...)), // bad prefix
+	}
+	offCurve := make([]byte, len(readerPubBytes))
+	copy(offCurve, readerPubBytes)
+	offCurve[len(offCurve)-1]++
+	cases = append(cases, hex.EncodeToString(offCurve)) // point off curve
+	for _, c := range cases {
+		pub, err = getReaderPub(c, "")
+		assert.NotNil(t, err, c)
+		assert.Nil(t, pub)
+	}
+}
+
+type fixedAuthorSharer struct {
+	sharedEnvelopeKey id.ID
+	err               error
+	envelopeKey       id.ID
+	readerPub         *ecdsa.PublicKey
+}
+
+func (f *fixedAuthorSharer) share(
+	author *lauthor.Author, envelopeKey id.ID, readerPub *ecdsa.PublicKey,
+) (id.ID, error) {
+	f.envelopeKey, f.readerPub = envelopeKey, readerPub
+	return f.sharedEnvelopeKey, f.err
+}
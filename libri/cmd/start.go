@@ -7,21 +7,55 @@ import (
 	clogging "github.com/drausin/libri/libri/common/logging"
 	"github.com/drausin/libri/libri/common/subscribe"
 	"github.com/drausin/libri/libri/librarian/server"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/drausin/libri/libri/librarian/server/search"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"log"
+	"net"
 )
 
 const (
-	bootstrapsFlag     = "bootstraps"
-	localHostFlag      = "localHost"
-	localPortFlag      = "localPort"
-	publicHostFlag     = "publicHost"
-	publicNameFlag     = "publicName"
-	publicPortFlag     = "publicPort"
-	nSubscriptionsFlag = "nSubscriptions"
-	fpRateFlag         = "fpRate"
+	bootstrapsFlag       = "bootstraps"
+	bootstrapInitFlag    = "bootstrapRetryInitial"
+	bootstrapMaxFlag     = "bootstrapRetryMax"
+	bootstrapElapsedFlag = "bootstrapRetryMaxElapsed"
+	bootstrapBgFlag      = "bootstrapInBackground"
+	localHostFlag        = "localHost"
+	localPortFlag        = "localPort"
+	localMetricsPortFlag = "localMetricsPort"
+	publicHostFlag       = "publicHost"
+	publicNameFlag       = "publicName"
+	publicPortFlag       = "publicPort"
+	nSubscriptionsFlag   = "nSubscriptions"
+	fpRateFlag           = "fpRate"
+	minFilterElemsFlag   = "minFilterElements"
+	accessLogLevelFlag   = "accessLogLevel"
+	accessLogSampleFlag  = "accessLogSample"
+	searchCacheSizeFlag  = "searchCacheSize"
+	searchCacheTTLFlag   = "searchCacheTTL"
+	expirySweepFlag      = "expirySweepInterval"
+	replayWindowFlag     = "requestReplayWindow"
+	replayCacheSizeFlag  = "requestReplayCacheSize"
+	storeRateFlag        = "storeRequestRate"
+	storeBurstFlag       = "storeRequestBurst"
+	dataDirQuotaFlag     = "dataDirQuota"
+	maxStoredDocsFlag    = "maxStoredDocumentBytes"
+	maxDocBytesFlag      = "maxDocumentBytes"
+	maxMsgBytesFlag      = "maxMessageBytes"
+	bucketSizeFlag       = "routingBucketSize"
+	splitAllBucketsFlag  = "routingSplitAllBuckets"
+	bucketRefreshFlag    = "bucketRefreshInterval"
+	replicationFlag      = "replication"
+	replicationCheckFlag = "replicationCheckInterval"
+	replicationMaxFlag   = "replicationMaxStores"
+	minHealthyPeersFlag  = "minHealthyPeers"
+	tlsCertFlag          = "tlsCert"
+	tlsKeyFlag           = "tlsKey"
+	tlsClientCAFlag      = "tlsClientCA"
+	tlsPeerCAFlag        = "tlsPeerCA"
 )
 
 // startLibrarianCmd represents the librarian start command
@@ -47,21 +81,86 @@ func init() {
 	librarianCmd.AddCommand(startLibrarianCmd)
 
 	startLibrarianCmd.Flags().String(localHostFlag, server.DefaultIP,
-		"local host (IPv4 or URL)")
+		"local host (IPv4, IPv6, or URL)")
 	startLibrarianCmd.Flags().Int(localPortFlag, server.DefaultPort,
 		"local port")
+	startLibrarianCmd.Flags().Int(localMetricsPortFlag, 0,
+		"local Prometheus metrics port (default: local port + 100)")
 	startLibrarianCmd.Flags().StringP(publicHostFlag, "i", server.DefaultIP,
-		"public host (IPv4 or URL)")
+		"public host (IPv4, IPv6, or URL)")
 	startLibrarianCmd.Flags().IntP(publicPortFlag, "p", server.DefaultPort,
 		"public port")
 	startLibrarianCmd.Flags().StringSliceP(bootstrapsFlag, "b", nil,
-		"comma-separated addresses (IPv4:Port) of bootstrap peers")
+		"comma-separated addresses (Host:Port, with IPv6 hosts in brackets) of bootstrap peers")
+	startLibrarianCmd.Flags().Duration(bootstrapInitFlag,
+		server.DefaultBootstrapRetryInitialInterval,
+		"interval before the first retry of a failed bootstrap")
+	startLibrarianCmd.Flags().Duration(bootstrapMaxFlag, server.DefaultBootstrapRetryMaxInterval,
+		"maximum interval between bootstrap retries")
+	startLibrarianCmd.Flags().Duration(bootstrapElapsedFlag,
+		server.DefaultBootstrapRetryMaxElapsedTime,
+		"maximum time spent retrying a bootstrap before giving up")
+	startLibrarianCmd.Flags().Bool(bootstrapBgFlag, server.DefaultBootstrapInBackground,
+		"keep retrying a failed bootstrap in the background while serving requests")
 	startLibrarianCmd.Flags().StringP(publicNameFlag, "n", "",
 		"public peer name")
 	startLibrarianCmd.Flags().IntP(nSubscriptionsFlag, "s", subscribe.DefaultNSubscriptionsTo,
 		"number of active subscriptions to other peers to maintain")
 	startLibrarianCmd.Flags().Float32P(fpRateFlag, "f", subscribe.DefaultFPRate,
 		"false positive rate for subscriptions to other peers")
+	startLibrarianCmd.Flags().Int(minFilterElemsFlag, subscribe.DefaultMinFilterElements,
+		"minimum number of (padded) elements in subscription filters; higher obscures the "+
+			"number of keys subscribed to but costs larger filters")
+	startLibrarianCmd.Flags().String(accessLogLevelFlag, server.DefaultAccessLogLevel.String(),
+		"log level of RPC access logs")
+	startLibrarianCmd.Flags().Float32(accessLogSampleFlag, server.DefaultAccessLogSampleRate,
+		"fraction of RPC requests to access log")
+	startLibrarianCmd.Flags().Uint(searchCacheSizeFlag, search.DefaultCacheSize,
+		"number of keys whose closest peers are cached to seed repeated searches")
+	startLibrarianCmd.Flags().Duration(searchCacheTTLFlag, search.DefaultCacheTTL,
+		"duration after which cached closest peers expire")
+	startLibrarianCmd.Flags().Duration(expirySweepFlag, server.DefaultExpirySweepInterval,
+		"interval between sweeps deleting expired documents")
+	startLibrarianCmd.Flags().Duration(replayWindowFlag, server.DefaultRequestReplayWindow,
+		"window within which requests with already seen IDs are rejected")
+	startLibrarianCmd.Flags().Uint(replayCacheSizeFlag, server.DefaultRequestReplayCacheSize,
+		"maximum number of recently seen request IDs remembered to reject replays")
+	startLibrarianCmd.Flags().Float32(storeRateFlag, server.DefaultStoreRequestRate,
+		"rate (per second) of Store requests allowed from each peer")
+	startLibrarianCmd.Flags().Uint(storeBurstFlag, server.DefaultStoreRequestBurst,
+		"maximum number of Store requests allowed from each peer in a burst above the rate")
+	startLibrarianCmd.Flags().Uint64(dataDirQuotaFlag, server.DefaultDataDirQuota,
+		"maximum number of bytes stored in the data directory, or 0 for no maximum")
+	startLibrarianCmd.Flags().Uint64(maxStoredDocsFlag, server.DefaultMaxStoredDocumentBytes,
+		"maximum number of bytes of stored documents before evicting those not replicated here, "+
+			"or 0 for no maximum")
+	startLibrarianCmd.Flags().Uint64(maxDocBytesFlag, server.DefaultMaxDocumentBytes,
+		"maximum number of bytes in a stored document, or 0 for no maximum")
+	startLibrarianCmd.Flags().Uint64(maxMsgBytesFlag, server.DefaultMaxMessageBytes,
+		"maximum size (bytes) of gRPC messages, at least maxDocumentBytes")
+	startLibrarianCmd.Flags().Uint(bucketSizeFlag, routing.DefaultMaxActivePeers,
+		"maximum number of peers in each routing table bucket")
+	startLibrarianCmd.Flags().Bool(splitAllBucketsFlag, false,
+		"split full routing table buckets even when they don't contain the librarian's ID")
+	startLibrarianCmd.Flags().Duration(bucketRefreshFlag, server.DefaultBucketRefreshInterval,
+		"interval between refreshes of routing table buckets without recent lookups")
+	startLibrarianCmd.Flags().Bool(replicationFlag, server.DefaultReplicationEnabled,
+		"periodically re-store stored documents with too few reachable replicas")
+	startLibrarianCmd.Flags().Duration(replicationCheckFlag,
+		server.DefaultReplicationCheckInterval,
+		"interval between checks for stored documents with too few reachable replicas")
+	startLibrarianCmd.Flags().Uint(replicationMaxFlag, server.DefaultReplicationMaxStores,
+		"maximum number of under-replicated documents re-stored per replication check")
+	startLibrarianCmd.Flags().Uint(minHealthyPeersFlag, server.DefaultMinHealthyPeers,
+		"minimum number of routing table peers to report a serving health status")
+	startLibrarianCmd.Flags().String(tlsCertFlag, "",
+		"path of PEM-encoded TLS certificate, which with tlsKey enables TLS (plaintext if empty)")
+	startLibrarianCmd.Flags().String(tlsKeyFlag, "",
+		"path of PEM-encoded TLS private key of the tlsCert certificate")
+	startLibrarianCmd.Flags().String(tlsClientCAFlag, "",
+		"path of PEM-encoded CA certificates verifying required client certificates")
+	startLibrarianCmd.Flags().String(tlsPeerCAFlag, "",
+		"path of PEM-encoded CA certificates verifying peer certificates (system CAs if empty)")
 
 	// bind viper flags
 	viper.SetEnvPrefix("LIBRI") // look for env vars with "LIBRI_" prefix
@@ -80,6 +179,14 @@ func getLibrarianConfig() (*server.Config, *zap.Logger, error) {
 		log.Printf("fatal error parsing local address: %v", err)
 		return nil, nil, err
 	}
+	var localMetricsAddr *net.TCPAddr
+	if localMetricsPort := viper.GetInt(localMetricsPortFlag); localMetricsPort != 0 {
+		localMetricsAddr, err = server.ParseAddr(viper.GetString(localHostFlag), localMetricsPort)
+		if err != nil {
+			log.Printf("fatal error parsing local metrics address: %v", err)
+			return nil, nil, err
+		}
+	}
 	publicAddr, err := server.ParseAddr(
 		viper.GetString(publicHostFlag),
 		viper.GetInt(publicPortFlag),
@@ -88,15 +195,46 @@ func getLibrarianConfig() (*server.Config, *zap.Logger, error) {
 		log.Printf("fatal error parsing public address: %v", err)
 		return nil, nil, err
 	}
+	var accessLogLevel zapcore.Level
+	if err = accessLogLevel.Set(viper.GetString(accessLogLevelFlag)); err != nil {
+		log.Printf("fatal error parsing access log level: %v", err)
+		return nil, nil, err
+	}
 	config := server.NewDefaultConfig().
 		WithLocalAddr(localAddr).
+		WithLocalMetricsAddr(localMetricsAddr). // nil gives default, which depends on LocalAddr
 		WithPublicAddr(publicAddr).
 		WithPublicName(viper.GetString(publicNameFlag)).
 		WithDataDir(viper.GetString(dataDirFlag)).
 		WithDefaultDBDir().  // depends on DataDir
-		WithLogLevel(getLogLevel())
+		WithDBBackend(viper.GetString(dbBackendFlag)).
+		WithDataDirQuota(uint64(viper.GetInt64(dataDirQuotaFlag))).
+		WithMaxStoredDocumentBytes(uint64(viper.GetInt64(maxStoredDocsFlag))).
+		WithMaxDocumentBytes(uint64(viper.GetInt64(maxDocBytesFlag))).
+		WithMaxMessageBytes(uint64(viper.GetInt64(maxMsgBytesFlag))).
+		WithLogLevel(getLogLevel()).
+		WithAccessLogLevel(accessLogLevel).
+		WithAccessLogSampleRate(float32(viper.GetFloat64(accessLogSampleFlag))).
+		WithExpirySweepInterval(viper.GetDuration(expirySweepFlag)).
+		WithRequestReplayWindow(viper.GetDuration(replayWindowFlag)).
+		WithRequestReplayCacheSize(uint(viper.GetInt(replayCacheSizeFlag))).
+		WithStoreRequestRate(float32(viper.GetFloat64(storeRateFlag))).
+		WithStoreRequestBurst(uint(viper.GetInt(storeBurstFlag))).
+		WithBucketRefreshInterval(viper.GetDuration(bucketRefreshFlag)).
+		WithReplicationEnabled(viper.GetBool(replicationFlag)).
+		WithReplicationCheckInterval(viper.GetDuration(replicationCheckFlag)).
+		WithReplicationMaxStores(uint(viper.GetInt(replicationMaxFlag))).
+		WithMinHealthyPeers(uint(viper.GetInt(minHealthyPeersFlag))).
+		WithTLS(viper.GetString(tlsCertFlag), viper.GetString(tlsKeyFlag)).
+		WithTLSClientCAFile(viper.GetString(tlsClientCAFlag)).
+		WithTLSPeerCAFile(viper.GetString(tlsPeerCAFlag))
 	config.SubscribeTo.NSubscriptions = uint32(viper.GetInt(nSubscriptionsFlag))
 	config.SubscribeTo.FPRate = float32(viper.GetFloat64(fpRateFlag))
+	config.SubscribeTo.MinFilterElements = uint32(viper.GetInt(minFilterElemsFlag))
+	config.Search.CacheSize = uint(viper.GetInt(searchCacheSizeFlag))
+	config.Search.CacheTTL = viper.GetDuration(searchCacheTTLFlag)
+	config.Routing.MaxBucketPeers = uint(viper.GetInt(bucketSizeFlag))
+	config.Routing.SplitAllBuckets = viper.GetBool(splitAllBucketsFlag)
 
 	logger := clogging.NewDevLogger(config.LogLevel)
 	bootstrapNetAddrs, err := server.ParseAddrs(viper.GetStringSlice(bootstrapsFlag))
@@ -105,17 +243,56 @@ func getLibrarianConfig() (*server.Config, *zap.Logger, error) {
 		return nil, nil, err
 
 	}
-	config.WithBootstrapAddrs(bootstrapNetAddrs)
+	config.WithBootstrapAddrs(bootstrapNetAddrs).
+		WithBootstrapRetryInitialInterval(viper.GetDuration(bootstrapInitFlag)).
+		WithBootstrapRetryMaxInterval(viper.GetDuration(bootstrapMaxFlag)).
+		WithBootstrapRetryMaxElapsedTime(viper.GetDuration(bootstrapElapsedFlag)).
+		WithBootstrapInBackground(viper.GetBool(bootstrapBgFlag))
+	if err = config.Validate(); err != nil {
+		logger.Error("invalid librarian configuration", zap.Error(err))
+		return nil, nil, err
+	}
 
 	logger.Info("librarian configuration",
 		zap.Stringer("localAddress", config.LocalAddr),
+		zap.Stringer("localMetricsAddress", config.LocalMetricsAddr),
 		zap.Stringer("publicAddress", config.PublicAddr),
 		zap.String(bootstrapsFlag, fmt.Sprintf("%v", config.BootstrapAddrs)),
+		zap.Duration(bootstrapInitFlag, config.BootstrapRetryInitialInterval),
+		zap.Duration(bootstrapMaxFlag, config.BootstrapRetryMaxInterval),
+		zap.Duration(bootstrapElapsedFlag, config.BootstrapRetryMaxElapsedTime),
+		zap.Bool(bootstrapBgFlag, config.BootstrapInBackground),
 		zap.String(publicNameFlag, config.PublicName),
 		zap.String(dataDirFlag, config.DataDir),
+		zap.String(dbBackendFlag, config.DBBackend),
+		zap.Uint64(dataDirQuotaFlag, config.DataDirQuota),
+		zap.Uint64(maxStoredDocsFlag, config.MaxStoredDocumentBytes),
+		zap.Uint64(maxDocBytesFlag, config.MaxDocumentBytes),
+		zap.Uint64(maxMsgBytesFlag, config.MaxMessageBytes),
 		zap.Stringer(logLevelFlag, config.LogLevel),
 		zap.Uint32(nSubscriptionsFlag, config.SubscribeTo.NSubscriptions),
 		zap.Float32(fpRateFlag, config.SubscribeTo.FPRate),
+		zap.Uint32(minFilterElemsFlag, config.SubscribeTo.MinFilterElements),
+		zap.Stringer(accessLogLevelFlag, config.AccessLogLevel),
+		zap.Float32(accessLogSampleFlag, config.AccessLogSampleRate),
+		zap.Uint(searchCacheSizeFlag, config.Search.CacheSize),
+		zap.Duration(searchCacheTTLFlag, config.Search.CacheTTL),
+		zap.Duration(expirySweepFlag, config.ExpirySweepInterval),
+		zap.Duration(replayWindowFlag, config.RequestReplayWindow),
+		zap.Uint(replayCacheSizeFlag, config.RequestReplayCacheSize),
+		zap.Float32(storeRateFlag, config.StoreRequestRate),
+		zap.Uint(storeBurstFlag, config.StoreRequestBurst),
+		zap.Uint(bucketSizeFlag, config.Routing.MaxBucketPeers),
+		zap.Bool(splitAllBucketsFlag, config.Routing.SplitAllBuckets),
+		zap.Duration(bucketRefreshFlag, config.BucketRefreshInterval),
+		zap.Bool(replicationFlag, config.ReplicationEnabled),
+		zap.Duration(replicationCheckFlag, config.ReplicationCheckInterval),
+		zap.Uint(replicationMaxFlag, config.ReplicationMaxStores),
+		zap.Uint(minHealthyPeersFlag, config.MinHealthyPeers),
+		zap.String(tlsCertFlag, config.TLSCertFile),
+		zap.String(tlsKeyFlag, config.TLSKeyFile),
+		zap.String(tlsClientCAFlag, config.TLSClientCAFile),
+		zap.String(tlsPeerCAFlag, config.TLSPeerCAFile),
 	)
 	return config, logger, nil
 }
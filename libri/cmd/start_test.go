@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"testing"
+	"time"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/drausin/libri/libri/common/db"
+	"github.com/drausin/libri/libri/common/subscribe"
 	"github.com/drausin/libri/libri/librarian/server"
 )
 
@@ -13,8 +16,19 @@ func TestGetLibrarianConfig_ok(t *testing.T) {
 	publicName := "some name"
 	dataDir := "some/data/dir"
 	logLevel := "debug"
-	nSubscriptions, fpRate := 5, 0.5
+	nSubscriptions, fpRate, minFilterElements := 5, 0.5, 20
 	bootstraps := "1.2.3.5:1000 1.2.3.6:1000"
+	bootstrapInit, bootstrapMax, bootstrapElapsed, bootstrapBg := "1s", "10s", "1m", true
+	accessLogLevel, accessLogSample := "info", 0.25
+	searchCacheSize, searchCacheTTL := 16, "1m"
+	expirySweepInterval, replayWindow, replayCacheSize := "10m", "5m", 1024
+	storeRate, storeBurst, dataDirQuota, maxDocBytes := 10.0, 50, 1<<30, 1<<20
+	maxMsgBytes, maxStoredDocBytes := 2<<20, 8<<30
+	bucketSize, splitAllBuckets, bucketRefreshInterval := 32, true, "30m"
+	replication, replicationCheckInterval, replicationMaxStores := false, "2h", 8
+	minHealthyPeers := 4
+	tlsCert, tlsKey, tlsClientCA := "some/cert.pem", "some/key.pem", "some/ca.pem"
+	tlsPeerCA := "some/peer-ca.pem"
 
 	viper.Set(logLevelFlag, logLevel)
 	viper.Set(localHostFlag, localIP)
@@ -23,9 +37,39 @@ func TestGetLibrarianConfig_ok(t *testing.T) {
 	viper.Set(publicPortFlag, publicPort)
 	viper.Set(publicNameFlag, publicName)
 	viper.Set(dataDirFlag, dataDir)
+	viper.Set(dbBackendFlag, db.BadgerDBBackend)
 	viper.Set(nSubscriptionsFlag, nSubscriptions)
 	viper.Set(fpRateFlag, fpRate)
+	viper.Set(minFilterElemsFlag, minFilterElements)
 	viper.Set(bootstrapsFlag, bootstraps)
+	viper.Set(bootstrapInitFlag, bootstrapInit)
+	viper.Set(bootstrapMaxFlag, bootstrapMax)
+	viper.Set(bootstrapElapsedFlag, bootstrapElapsed)
+	viper.Set(bootstrapBgFlag, bootstrapBg)
+	viper.Set(accessLogLevelFlag, accessLogLevel)
+	viper.Set(accessLogSampleFlag, accessLogSample)
+	viper.Set(searchCacheSizeFlag, searchCacheSize)
+	viper.Set(searchCacheTTLFlag, searchCacheTTL)
+	viper.Set(expirySweepFlag, expirySweepInterval)
+	viper.Set(replayWindowFlag, replayWindow)
+	viper.Set(replayCacheSizeFlag, replayCacheSize)
+	viper.Set(storeRateFlag, storeRate)
+	viper.Set(storeBurstFlag, storeBurst)
+	viper.Set(dataDirQuotaFlag, dataDirQuota)
+	viper.Set(maxStoredDocsFlag, maxStoredDocBytes)
+	viper.Set(maxDocBytesFlag, maxDocBytes)
+	viper.Set(maxMsgBytesFlag, maxMsgBytes)
+	viper.Set(bucketSizeFlag, bucketSize)
+	viper.Set(splitAllBucketsFlag, splitAllBuckets)
+	viper.Set(bucketRefreshFlag, bucketRefreshInterval)
+	viper.Set(replicationFlag, replication)
+	viper.Set(replicationCheckFlag, replicationCheckInterval)
+	viper.Set(replicationMaxFlag, replicationMaxStores)
+	viper.Set(minHealthyPeersFlag, minHealthyPeers)
+	viper.Set(tlsCertFlag, tlsCert)
+	viper.Set(tlsKeyFlag, tlsKey)
+	viper.Set(tlsClientCAFlag, tlsClientCA)
+	viper.Set(tlsPeerCAFlag, tlsPeerCA)
 
 	config, logger, err := getLibrarianConfig()
 	assert.Nil(t, err)
@@ -35,10 +79,63 @@ func TestGetLibrarianConfig_ok(t *testing.T) {
 	assert.Equal(t, publicName, config.PublicName)
 	assert.Equal(t, dataDir, config.DataDir)
 	assert.Equal(t, dataDir + "/" + server.DBSubDir, config.DbDir)
+	assert.Equal(t, db.BadgerDBBackend, config.DBBackend)
 	assert.Equal(t, logLevel, config.LogLevel.String())
 	assert.Equal(t, uint32(nSubscriptions), config.SubscribeTo.NSubscriptions)
 	assert.Equal(t, float32(fpRate), config.SubscribeTo.FPRate)
+	assert.Equal(t, uint32(minFilterElements), config.SubscribeTo.MinFilterElements)
 	assert.Equal(t, 2, len(config.BootstrapAddrs))
+	assert.Equal(t, time.Second, config.BootstrapRetryInitialInterval)
+	assert.Equal(t, 10*time.Second, config.BootstrapRetryMaxInterval)
+	assert.Equal(t, time.Minute, config.BootstrapRetryMaxElapsedTime)
+	assert.Equal(t, bootstrapBg, config.BootstrapInBackground)
+	assert.Equal(t, accessLogLevel, config.AccessLogLevel.String())
+	assert.Equal(t, float32(accessLogSample), config.AccessLogSampleRate)
+	assert.Equal(t, uint(searchCacheSize), config.Search.CacheSize)
+	assert.Equal(t, time.Minute, config.Search.CacheTTL)
+	assert.Equal(t, 10*time.Minute, config.ExpirySweepInterval)
+	assert.Equal(t, 5*time.Minute, config.RequestReplayWindow)
+	assert.Equal(t, uint(replayCacheSize), config.RequestReplayCacheSize)
+	assert.Equal(t, float32(storeRate), config.StoreRequestRate)
+	assert.Equal(t, uint(storeBurst), config.StoreRequestBurst)
+	assert.Equal(t, uint64(dataDirQuota), config.DataDirQuota)
+	assert.Equal(t, uint64(maxStoredDocBytes), config.MaxStoredDocumentBytes)
+	assert.Equal(t, uint64(maxDocBytes), config.MaxDocumentBytes)
+	assert.Equal(t, uint64(maxMsgBytes), config.MaxMessageBytes)
+	assert.Equal(t, uint(bucketSize), config.Routing.MaxBucketPeers)
+	assert.Equal(t, splitAllBuckets, config.Routing.SplitAllBuckets)
+	assert.Equal(t, 30*time.Minute, config.BucketRefreshInterval)
+	assert.Equal(t, replication, config.ReplicationEnabled)
+	assert.Equal(t, 2*time.Hour, config.ReplicationCheckInterval)
+	assert.Equal(t, uint(replicationMaxStores), config.ReplicationMaxStores)
+	assert.Equal(t, uint(minHealthyPeers), config.MinHealthyPeers)
+	assert.Equal(t, tlsCert, config.TLSCertFile)
+	assert.Equal(t, tlsKey, config.TLSKeyFile)
+	assert.Equal(t, tlsClientCA, config.TLSClientCAFile)
+	assert.Equal(t, tlsPeerCA, config.TLSPeerCAFile)
+
+	// reset to plaintext default for subsequent tests
+	viper.Set(tlsCertFlag, "")
+	viper.Set(tlsKeyFlag, "")
+	viper.Set(tlsClientCAFlag, "")
+	viper.Set(tlsPeerCAFlag, "")
+}
+
+func TestGetLibrarianConfig_ipv6(t *testing.T) {
+	viper.Set(localHostFlag, "::1")
+	viper.Set(localPortFlag, "1234")
+	viper.Set(publicHostFlag, "[2001:db8::1]")
+	viper.Set(publicPortFlag, "5678")
+	viper.Set(bootstrapsFlag, "[2001:db8::2]:1000 1.2.3.5:1000")
+
+	config, _, err := getLibrarianConfig()
+	assert.Nil(t, err)
+
+	assert.Equal(t, "[::1]:1234", config.LocalAddr.String())
+	assert.Equal(t, "[2001:db8::1]:5678", config.PublicAddr.String())
+	assert.Equal(t, 2, len(config.BootstrapAddrs))
+	assert.Equal(t, "[2001:db8::2]:1000", config.BootstrapAddrs[0].String())
+	assert.Equal(t, "1.2.3.5:1000", config.BootstrapAddrs[1].String())
 }
 
 func TestGetLibrarianConfig_err(t *testing.T) {
@@ -56,9 +153,38 @@ func TestGetLibrarianConfig_err(t *testing.T) {
 	assert.Nil(t, logger)
 
 	viper.Set(publicHostFlag, "1.2.3.4")
+	viper.Set(accessLogLevelFlag, "bad level")
+	config, logger, err = getLibrarianConfig()
+	assert.NotNil(t, err)
+	assert.Nil(t, config)
+	assert.Nil(t, logger)
+
+	viper.Set(accessLogLevelFlag, "debug")
 	viper.Set(bootstrapsFlag, "bad bootstrap")
 	config, logger, err = getLibrarianConfig()
 	assert.NotNil(t, err)
 	assert.Nil(t, config)
 	assert.Nil(t, logger)
+
+	viper.Set(bootstrapsFlag, "1.2.3.5:1000")
+	viper.Set(fpRateFlag, 2.0)
+	config, logger, err = getLibrarianConfig()
+	assert.Equal(t, server.ErrOutOfBoundsFPRate, err)
+	assert.Nil(t, config)
+	assert.Nil(t, logger)
+	viper.Set(fpRateFlag, 0.5)
+
+	viper.Set(minFilterElemsFlag, 0)
+	config, logger, err = getLibrarianConfig()
+	assert.Equal(t, subscribe.ErrZeroMinFilterElements, err)
+	assert.Nil(t, config)
+	assert.Nil(t, logger)
+	viper.Set(minFilterElemsFlag, subscribe.DefaultMinFilterElements)
+
+	viper.Set(tlsCertFlag, "some/cert.pem")
+	config, logger, err = getLibrarianConfig()
+	assert.Equal(t, server.ErrIncompleteTLS, err)
+	assert.Nil(t, config)
+	assert.Nil(t, logger)
+	viper.Set(tlsCertFlag, "")
 }
\ No newline at end of file
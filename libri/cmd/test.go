@@ -23,7 +23,7 @@ func init() {
 	RootCmd.AddCommand(testCmd)
 
 	testCmd.PersistentFlags().StringSliceP(testLibrariansFlag, "a", nil,
-		"comma-separated addresses (IPv4:Port) of librarian(s)")
+		"comma-separated addresses (Host:Port, with IPv6 hosts in brackets) of librarian(s)")
 	testCmd.PersistentFlags().Int(timeoutFlag, 5,
 		"timeout (seconds) for requests to librarians")
 
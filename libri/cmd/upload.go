@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	lauthor "github.com/drausin/libri/libri/author"
 	"github.com/drausin/libri/libri/author/keychain"
@@ -9,6 +10,7 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"io"
+	"io/ioutil"
 	"mime"
 	"net/http"
 	"os"
@@ -16,8 +18,15 @@ import (
 )
 
 const (
-	upFilepathFlag = "upFilepath"
-	octetMediaType = "application/octet-stream"
+	upFilepathFlag  = "upFilepath"
+	upMediaTypeFlag = "mediaType"
+	octetMediaType  = "application/octet-stream"
+
+	// stdinFilepath is the upload filepath for reading content from stdin
+	stdinFilepath = "-"
+
+	// sniffLen is the number of leading content bytes used to detect its media type
+	sniffLen = 512
 )
 
 var (
@@ -27,10 +36,16 @@ var (
 
 // uploadCmd represents the upload command
 var uploadCmd = &cobra.Command{
-	Use:   "upload",
+	Use:   "upload [file]",
 	Short: "upload a local file to the libri network",
-	Long:  `TODO (drausin) add longer description and examples here`,
+	Long: `Upload a local file, or stdin if the file is "-", to the libri network and print its
+envelope key. The file may be given as an argument or with the upFilepath flag. Its media type is
+detected from its content or extension unless given with the mediaType flag.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// file argument takes precedence over the flag
+			viper.Set(upFilepathFlag, args[0])
+		}
 		if err := newFileUploader().upload(); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -44,7 +59,9 @@ func init() {
 	uploadCmd.Flags().Uint32P(parallelismFlag, "n", 3,
 		"number of parallel processes")
 	uploadCmd.Flags().StringP(upFilepathFlag, "f", "",
-		"path of local file to upload")
+		"path of local file to upload, or - for stdin")
+	uploadCmd.Flags().StringP(upMediaTypeFlag, "m", "",
+		"media type of the uploaded content, detected from it if empty")
 
 	// bind viper flags
 	viper.SetEnvPrefix(envVarPrefix) // look for env vars with "LIBRI_" prefix
@@ -63,6 +80,8 @@ type fileUploaderImpl struct {
 	au  authorUploader
 	mtg mediaTypeGetter
 	kc  keychainsGetter
+	in  io.Reader
+	out io.Writer
 }
 
 func newFileUploader() fileUploader {
@@ -73,6 +92,8 @@ func newFileUploader() fileUploader {
 		kc: &keychainsGetterImpl{
 			pg: &terminalPassphraseGetter{},
 		},
+		in:  os.Stdin,
+		out: os.Stdout,
 	}
 }
 
@@ -81,14 +102,7 @@ func (u *fileUploaderImpl) upload() error {
 	if upFilepath == "" {
 		return errMissingFilepath
 	}
-	mediaType, err := u.mtg.get(upFilepath)
-	if err != nil {
-		return err
-	}
-	if _, err = os.Stat(upFilepath); err != nil {
-		return err
-	}
-	file, err := os.Open(upFilepath)
+	file, mediaType, err := u.open(upFilepath, viper.GetString(upMediaTypeFlag))
 	if err != nil {
 		return err
 	}
@@ -105,12 +119,46 @@ func (u *fileUploaderImpl) upload() error {
 		zap.String("filepath", upFilepath),
 		zap.String("media_type", mediaType),
 	)
-	if _, err = u.au.upload(author, file, mediaType); err != nil {
+	envelopeKey, err := u.au.upload(author, file, mediaType)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintln(u.out, envelopeKey.String()); err != nil {
 		return err
 	}
 	return file.Close()
 }
 
+// open returns the content to upload from the filepath, or stdin for stdinFilepath, along with its
+// media type, which is detected when not given.
+func (u *fileUploaderImpl) open(upFilepath, mediaType string) (io.ReadCloser, string, error) {
+	if upFilepath == stdinFilepath {
+		in := bufio.NewReader(u.in)
+		if mediaType == "" {
+			head, err := in.Peek(sniffLen)
+			if err != nil && err != io.EOF {
+				return nil, "", err
+			}
+			mediaType = http.DetectContentType(head)
+		}
+		return ioutil.NopCloser(in), mediaType, nil
+	}
+	var err error
+	if mediaType == "" {
+		if mediaType, err = u.mtg.get(upFilepath); err != nil {
+			return nil, "", err
+		}
+	}
+	if _, err = os.Stat(upFilepath); err != nil {
+		return nil, "", err
+	}
+	file, err := os.Open(upFilepath)
+	if err != nil {
+		return nil, "", err
+	}
+	return file, mediaType, nil
+}
+
 func maybePanic(err error) {
 	if err != nil {
 		panic(err)
@@ -128,7 +176,7 @@ func (*mediaTypeGetterImpl) get(upFilepath string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	head := make([]byte, 512)
+	head := make([]byte, sniffLen)
 	_, err = file.Read(head)
 	if err != nil && err != io.EOF {
 		return "", err
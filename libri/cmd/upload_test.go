@@ -30,14 +30,17 @@ func TestNewFileUploader(t *testing.T) {
 }
 
 func TestFileUploader_upload_ok(t *testing.T) {
+	envelopeKey := id.FromInt64(1)
+	out := new(bytes.Buffer)
 	u := &fileUploaderImpl{
 		ag: &fixedAuthorGetter{
 			author: nil, // ok since we're passing it into a mocked method anyway
 			logger: server.NewDevInfoLogger(),
 		},
-		au:  &fixedAuthorUploader{},
+		au:  &fixedAuthorUploader{envelopeKey: envelopeKey},
 		mtg: &fixedMediaTypeGetter{}, // ok that mediaType is nil since passing to mock
 		kc:  &fixedKeychainsGetter{}, // ok that KCs are null for same reason
+		out: out,
 	}
 	toUploadFile, err := ioutil.TempFile("", "to-upload")
 	assert.Nil(t, err)
@@ -47,11 +50,44 @@ func TestFileUploader_upload_ok(t *testing.T) {
 
 	err = u.upload()
 	assert.Nil(t, err)
+	assert.Equal(t, envelopeKey.String()+"\n", out.String())
 
 	err = os.Remove(toUploadFile.Name())
 	assert.Nil(t, err)
 }
 
+func TestFileUploader_upload_stdin(t *testing.T) {
+	au := &fixedAuthorUploader{envelopeKey: id.FromInt64(1)}
+	u := &fileUploaderImpl{
+		ag: &fixedAuthorGetter{
+			author: nil, // ok since we're passing it into a mocked method anyway
+			logger: server.NewDevInfoLogger(),
+		},
+		au:  au,
+		// media type getter isn't used for stdin
+		mtg: &fixedMediaTypeGetter{err: errors.New("some get error")},
+		kc:  &fixedKeychainsGetter{}, // ok that KCs are null since passing to mock
+		in:  bytes.NewBufferString("some stdin content"),
+		out: new(bytes.Buffer),
+	}
+	viper.Set(upFilepathFlag, stdinFilepath)
+
+	// check media type is detected from content
+	err := u.upload()
+	assert.Nil(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", au.mediaType)
+	assert.Equal(t, []byte("some stdin content"), au.content)
+
+	// check given media type is used
+	u.in = bytes.NewBufferString("some other stdin content")
+	viper.Set(upMediaTypeFlag, "application/pdf")
+	err = u.upload()
+	viper.Set(upMediaTypeFlag, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "application/pdf", au.mediaType)
+	assert.Equal(t, []byte("some other stdin content"), au.content)
+}
+
 func TestFileUploader_upload_err(t *testing.T) {
 
 	// should error on missing filepath
@@ -254,11 +290,17 @@ func TestKeychainsGetter_get_err(t *testing.T) {
 
 type fixedAuthorUploader struct {
 	envelopeKey id.ID
-	err error
+	err         error
+	content     []byte
+	mediaType   string
 }
 
 func (f *fixedAuthorUploader) upload(author *lauthor.Author, content io.Reader, mediaType string) (
 	id.ID, error) {
+	f.mediaType = mediaType
+	if content != nil {
+		f.content, _ = ioutil.ReadAll(content)
+	}
 	return f.envelopeKey, f.err
 }
 
@@ -0,0 +1,83 @@
+package clock
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Clock tells the current time and waits for durations to elapse. It lets time-dependent
+// behavior like timeouts use the wall clock in production and a controllable Fake in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel receiving the current time once the duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a new Timer firing once the duration has elapsed.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event firing on its channel, like a time.Timer.
+type Timer interface {
+	// C returns the channel receiving the time when the Timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing, returning false if it already has.
+	Stop() bool
+}
+
+// New returns a Clock using the wall clock.
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// WithTimeout returns a child of the parent context canceled once the timeout elapses on the
+// Clock. For the wall clock, it is just context.WithTimeout; for other Clocks, the context's error
+// after the timeout is context.Canceled rather than context.DeadlineExceeded, and it has no
+// deadline.
+func WithTimeout(parent context.Context, c Clock, timeout time.Duration) (
+	context.Context, context.CancelFunc) {
+
+	if _, ok := c.(realClock); ok {
+		return context.WithTimeout(parent, timeout)
+	}
+	ctx, cancel := context.WithCancel(parent)
+	timer := c.NewTimer(timeout)
+	go func() {
+		select {
+		case <-timer.C():
+			cancel()
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}()
+	return ctx, cancel
+}
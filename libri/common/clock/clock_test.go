@@ -0,0 +1,61 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRealClock(t *testing.T) {
+	c := New()
+	before := time.Now()
+	assert.False(t, c.Now().Before(before))
+
+	<-c.After(time.Millisecond)
+	assert.True(t, time.Since(before) >= time.Millisecond)
+
+	timer := c.NewTimer(time.Hour)
+	assert.True(t, timer.Stop())
+	timer = c.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop())
+}
+
+func TestWithTimeout_real(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), New(), time.Millisecond)
+	defer cancel()
+	_, hasDeadline := ctx.Deadline()
+	assert.True(t, hasDeadline)
+	<-ctx.Done()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+}
+
+func TestWithTimeout_fake(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+
+	// check context is canceled once the timeout elapses
+	ctx1, cancel1 := WithTimeout(context.Background(), f, time.Second)
+	defer cancel1()
+	f.BlockUntil(1)
+	f.Advance(500 * time.Millisecond)
+	assert.Nil(t, ctx1.Err())
+	f.Advance(500 * time.Millisecond)
+	<-ctx1.Done()
+	assert.Equal(t, context.Canceled, ctx1.Err())
+
+	// check canceling stops the timer
+	_, cancel2 := WithTimeout(context.Background(), f, time.Second)
+	f.BlockUntil(1)
+	cancel2()
+	for {
+		f.mu.Lock()
+		nTimers := len(f.timers)
+		f.mu.Unlock()
+		if nTimers == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,100 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only moves when advanced, firing any timers that have then elapsed.
+// It is mostly used for testing.
+type Fake struct {
+	now    time.Time
+	timers []*fakeTimer
+	mu     sync.Mutex
+	added  *sync.Cond
+}
+
+// NewFake returns a new Fake clock starting at the given time.
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.added = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the current fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel receiving the fake time once the clock is advanced by the duration.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer returns a new Timer firing once the clock is advanced by the duration.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{
+		fake: f,
+		at:   f.now.Add(d),
+		c:    make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		t.c <- f.now
+		return t
+	}
+	f.timers = append(f.timers, t)
+	f.added.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by the duration, firing the timers that have elapsed.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if t.at.After(f.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- f.now
+	}
+	f.timers = pending
+}
+
+// BlockUntil blocks until the clock has at least n pending (i.e., unfired and unstopped) timers,
+// so tests can advance it only once the code under test is waiting on it.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.timers) < n {
+		f.added.Wait()
+	}
+}
+
+type fakeTimer struct {
+	fake *Fake
+	at   time.Time
+	c    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	for i, pending := range t.fake.timers {
+		if pending == t {
+			t.fake.timers = append(t.fake.timers[:i], t.fake.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake_Now(t *testing.T) {
+	start := time.Unix(1000, 0)
+	f := NewFake(start)
+	assert.Equal(t, start, f.Now())
+	f.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), f.Now())
+}
+
+func TestFake_NewTimer(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	t1, t2, t3 := f.NewTimer(time.Second), f.NewTimer(2*time.Second), f.NewTimer(3*time.Second)
+
+	// check only elapsed timers fire
+	f.Advance(time.Second)
+	assert.Equal(t, time.Unix(1, 0), <-t1.C())
+	assert.Len(t, t2.C(), 0)
+
+	// check stopped timers don't fire
+	assert.True(t, t3.Stop())
+	assert.False(t, t3.Stop())
+	f.Advance(2 * time.Second)
+	assert.Equal(t, time.Unix(3, 0), <-t2.C())
+	assert.Len(t, t3.C(), 0)
+	assert.False(t, t1.Stop())
+
+	// check non-positive durations fire immediately
+	assert.Equal(t, time.Unix(3, 0), <-f.NewTimer(0).C())
+}
+
+func TestFake_After(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	after := f.After(time.Second)
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	assert.Equal(t, time.Unix(1, 0), <-after)
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		f.BlockUntil(2)
+		close(done)
+	}()
+	f.NewTimer(time.Second)
+	f.NewTimer(time.Second)
+	<-done
+}
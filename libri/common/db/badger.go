@@ -0,0 +1,194 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerDB implements the KVDB interface with a thinly wrapped Badger instance, which unlike
+// RocksDB is pure Go and so doesn't require cgo.
+type BadgerDB struct {
+	// Pointer to the Badger object
+	bdb *badger.DB
+
+	// dir is the directory of the Badger key and value files
+	dir string
+}
+
+// NewBadgerDB creates a new BadgerDB instance with default options. It returns a *DirInUseError if
+// another instance has the directory open.
+func NewBadgerDB(dbDir string) (*BadgerDB, error) {
+	err := os.MkdirAll(dbDir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	options := badger.DefaultOptions
+	options.Dir = dbDir
+	options.ValueDir = dbDir
+	db, err := badger.Open(options)
+	if err != nil {
+		return nil, maybeDirInUse(dbDir, err)
+	}
+
+	return &BadgerDB{
+		bdb: db,
+		dir: dbDir,
+	}, nil
+}
+
+// Get returns the value for a key, or nil if the key is missing.
+func (db *BadgerDB) Get(key []byte) ([]byte, error) {
+	if db.bdb == nil {
+		return nil, errors.New("bdb is nil!")
+	}
+	var value []byte
+	err := db.bdb.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err = copyValue(item)
+		return err
+	})
+	return value, err
+}
+
+// Put stores the value for a key.
+func (db *BadgerDB) Put(key []byte, value []byte) error {
+	return db.bdb.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// PutBatch atomically stores the values for their keys in a single transaction, whose size is
+// limited by Badger's max transaction size.
+func (db *BadgerDB) PutBatch(keys [][]byte, values [][]byte) error {
+	if len(keys) != len(values) {
+		return ErrBatchLengthMismatch
+	}
+	return db.bdb.Update(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			if err := txn.Set(key, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes the value for a key.
+func (db *BadgerDB) Delete(key []byte) error {
+	return db.bdb.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// WriteBatch atomically stores the values for their keys and removes the values for the delete
+// keys in a single transaction, whose size is limited by Badger's max transaction size.
+func (db *BadgerDB) WriteBatch(keys [][]byte, values [][]byte, deleteKeys [][]byte) error {
+	if len(keys) != len(values) {
+		return ErrBatchLengthMismatch
+	}
+	return db.bdb.Update(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			if err := txn.Set(key, values[i]); err != nil {
+				return err
+			}
+		}
+		for _, key := range deleteKeys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Iterate calls fn on each key-value pair whose key starts with the given prefix, in key order,
+// within a read-only transaction, which sees a snapshot of the database taken when iteration
+// starts. The keys and values passed to fn are copies, so fn may keep or modify them.
+func (db *BadgerDB) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return db.iterate(prefix, func(iter *badger.Iterator) bool {
+		return iter.ValidForPrefix(prefix)
+	}, fn)
+}
+
+// IterateRange is like Iterate but over the key-value pairs whose keys are from start
+// (inclusive) to end (exclusive).
+func (db *BadgerDB) IterateRange(start []byte, end []byte, fn func(key, value []byte) error) error {
+	return db.iterate(start, func(iter *badger.Iterator) bool {
+		return iter.Valid() && bytes.Compare(iter.Item().Key(), end) < 0
+	}, fn)
+}
+
+// iterate calls fn on each key-value pair from the seek key onwards while valid holds for the
+// iterator.
+func (db *BadgerDB) iterate(
+	seek []byte, valid func(iter *badger.Iterator) bool, fn func(key, value []byte) error,
+) error {
+	if db.bdb == nil {
+		return errors.New("bdb is nil!")
+	}
+	return db.bdb.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		for iter.Seek(seek); valid(iter); iter.Next() {
+			item := iter.Item()
+			key := copyBytes(item.Key())
+			value, err := copyValue(item)
+			if err != nil {
+				return err
+			}
+			if err := fn(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Size returns the number of bytes of the database's LSM tree and value log files on disk. Since
+// deleted values are only removed from the value log when it is garbage collected, the size may
+// lag behind deletions.
+func (db *BadgerDB) Size() (uint64, error) {
+	if db.bdb == nil {
+		return 0, errors.New("bdb is nil!")
+	}
+	var size uint64
+	err := filepath.Walk(db.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Close gracefully shuts down the database.
+func (db *BadgerDB) Close() {
+	_ = db.bdb.Close()
+}
+
+// copyValue copies the value of a Badger item, which is otherwise only valid within its
+// transaction.
+func copyValue(item *badger.Item) ([]byte, error) {
+	value, err := item.Value()
+	if err != nil {
+		return nil, err
+	}
+	return copyBytes(value), nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test creating a new BadgerDB instance.
+func TestBadgerDB_NewBadgerDB(t *testing.T) {
+	kvdb, cleanup, err := NewTempDirKVDB(BadgerDBBackend)
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+
+	db, ok := kvdb.(*BadgerDB)
+	assert.True(t, ok)
+	assert.NotNil(t, db.bdb)
+	assert.NotEmpty(t, db.dir)
+}
+
+func TestBadgerDB_NewBadgerDB_dirInUse(t *testing.T) {
+	kvdb, cleanup, err := NewTempDirKVDB(BadgerDBBackend)
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+
+	// check opening the same dir again gives a dir in use error
+	db2, err := NewBadgerDB(kvdb.(*BadgerDB).dir)
+	assert.True(t, IsDirInUse(err))
+	assert.Nil(t, db2)
+}
+
+func TestBadgerDB_Get_err(t *testing.T) {
+	db := &BadgerDB{}
+	value, err := db.Get([]byte("key"))
+	assert.Nil(t, value)
+	assert.NotNil(t, err)
+}
+
+func TestBadgerDB_Iterate_err(t *testing.T) {
+	db := &BadgerDB{}
+	err := db.Iterate([]byte("prefix"), func(key, value []byte) error { return nil })
+	assert.NotNil(t, err)
+}
+
+func TestBadgerDB_Size_err(t *testing.T) {
+	db := &BadgerDB{}
+	size, err := db.Size()
+	assert.Zero(t, size)
+	assert.NotNil(t, err)
+}
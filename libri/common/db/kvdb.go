@@ -1,14 +1,79 @@
 package db
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+)
 
-	"errors"
+const (
+	// RocksDBBackend is the RocksDB KVDB backend, which requires cgo.
+	RocksDBBackend = "rocksdb"
+
+	// BadgerDBBackend is the pure-Go Badger KVDB backend.
+	BadgerDBBackend = "badger"
+
+	// MemoryDBBackend is the in-memory KVDB backend, whose state does not persist on disk.
+	MemoryDBBackend = "memory"
+)
+
+var (
+	// ErrUnknownBackend indicates when a KVDB backend is not one of the known backends.
+	ErrUnknownBackend = errors.New("unknown KVDB backend")
+
+	// ErrBatchLengthMismatch indicates when a batch has a different number of keys and values.
+	ErrBatchLengthMismatch = errors.New("batch keys and values have different lengths")
 
-	"github.com/tecbot/gorocksdb"
+	// ErrRocksDBUnavailable indicates when the RocksDB backend is requested from a binary built
+	// without cgo.
+	ErrRocksDBUnavailable = errors.New("RocksDB backend requires building with cgo")
 )
 
+// lockErrMsgs are (lower case) fragments of the RocksDB and Badger errors from opening a DB whose
+// directory another instance has locked.
+var lockErrMsgs = []string{
+	"resource temporarily unavailable", // RocksDB & Badger, locked by another process
+	"lock hold by current process",     // RocksDB, locked by another instance in this process
+	"cannot acquire directory lock",    // Badger
+}
+
+// DirInUseError indicates when a DB directory is locked by another process (or another instance
+// in the same process), usually because another librarian or author is using the same data dir.
+type DirInUseError struct {
+	// Dir is the locked DB directory.
+	Dir string
+
+	// Err is the underlying error from opening the DB.
+	Err error
+}
+
+func (e *DirInUseError) Error() string {
+	return fmt.Sprintf("data directory %s is in use by another process: %v", e.Dir, e.Err)
+}
+
+// IsDirInUse returns whether the error is a *DirInUseError.
+func IsDirInUse(err error) bool {
+	_, ok := err.(*DirInUseError)
+	return ok
+}
+
+// maybeDirInUse returns a *DirInUseError wrapping the error from opening the DB in the given
+// directory if it indicates the directory is locked and otherwise just the error.
+func maybeDirInUse(dbDir string, err error) error {
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "lock") {
+		return err
+	}
+	for _, lockErrMsg := range lockErrMsgs {
+		if strings.Contains(msg, lockErrMsg) {
+			return &DirInUseError{Dir: dbDir, Err: err}
+		}
+	}
+	return err
+}
+
 // KVDB is the (thin) abstraction layer of an implementation-agnostic key-value store.
 type KVDB interface {
 	// Get returns the value for a key.
@@ -17,49 +82,65 @@ type KVDB interface {
 	// Put stores the value for a key.
 	Put(key []byte, value []byte) error
 
+	// PutBatch atomically stores the values for their keys, so either all or none of them are
+	// stored.
+	PutBatch(keys [][]byte, values [][]byte) error
+
 	// Delete removes the value for a key.
 	Delete(key []byte) error
 
+	// WriteBatch atomically stores the values for their keys and removes the values for the
+	// delete keys, so either all or none of the changes are applied.
+	WriteBatch(keys [][]byte, values [][]byte, deleteKeys [][]byte) error
+
+	// Iterate calls fn on each key-value pair whose key starts with the given prefix, in key
+	// order, over a consistent snapshot of the store. Iteration stops early if fn returns an
+	// error, which is then returned.
+	Iterate(prefix []byte, fn func(key, value []byte) error) error
+
+	// IterateRange is like Iterate but over the key-value pairs whose keys are from start
+	// (inclusive) to end (exclusive), seeking directly to start.
+	IterateRange(start []byte, end []byte, fn func(key, value []byte) error) error
+
 	// Close gracefully shuts down the database.
 	Close()
 }
 
-// RocksDB implements the KVStore interface with a thinly wrapped RocksDB instance.
-type RocksDB struct {
-	// Pointer to the RocksDB object
-	rdb *gorocksdb.DB
-
-	// Read options for generic reads
-	ro *gorocksdb.ReadOptions
+// Sizer reports the size of a database.
+type Sizer interface {
+	// Size returns the (approximate) number of bytes the database occupies.
+	Size() (uint64, error)
+}
 
-	// Write options for generic writes
-	wo *gorocksdb.WriteOptions
+// SizedKVDB is a KVDB that also reports its size.
+type SizedKVDB interface {
+	KVDB
+	Sizer
 }
 
-// NewRocksDB creates a new RocksDB instance with default read and write options.
-func NewRocksDB(dbDir string) (*RocksDB, error) {
-	err := os.MkdirAll(dbDir, os.ModePerm)
-	if err != nil {
-		return nil, err
-	}
-	options := gorocksdb.NewDefaultOptions()
-	options.SetCreateIfMissing(true)
-	db, err := gorocksdb.OpenDb(options, dbDir)
-	if err != nil {
-		return nil, err
+// NewKVDB creates a new KVDB of the given backend (e.g., RocksDBBackend or BadgerDBBackend) in
+// the given directory, which MemoryDBBackend ignores.
+func NewKVDB(backend string, dbDir string) (SizedKVDB, error) {
+	switch backend {
+	case RocksDBBackend:
+		return newRocksDB(dbDir)
+	case BadgerDBBackend:
+		bdb, err := NewBadgerDB(dbDir)
+		if err != nil {
+			return nil, err
+		}
+		return bdb, nil
+	case MemoryDBBackend:
+		return NewMemoryDB(), nil
+	default:
+		return nil, ErrUnknownBackend
 	}
-
-	return &RocksDB{
-		rdb: db,
-		ro:  gorocksdb.NewDefaultReadOptions(),
-		wo:  gorocksdb.NewDefaultWriteOptions(),
-	}, nil
 }
 
-// NewTempDirRocksDB creates a new RocksDB instance (used mostly for local testing) in a local
-// temporary directory.
-func NewTempDirRocksDB() (*RocksDB, func(), error) {
-	dir, err := ioutil.TempDir("", "kvdb-test-rocksdb")
+// NewTempDirKVDB creates a new KVDB of the given backend (used mostly for local testing) in a
+// local temporary directory.
+func NewTempDirKVDB(backend string) (SizedKVDB, func(), error) {
+	dir, err := ioutil.TempDir("", "kvdb-test-"+backend)
 	cleanup := func() {
 		rmErr := os.RemoveAll(dir)
 		if rmErr != nil {
@@ -69,32 +150,11 @@ func NewTempDirRocksDB() (*RocksDB, func(), error) {
 	if err != nil {
 		return nil, cleanup, err
 	}
-	rdb, err := NewRocksDB(dir)
-	return rdb, cleanup, err
-}
-
-// Get returns the value for a key.
-func (db *RocksDB) Get(key []byte) ([]byte, error) {
-	// Return copy of bytes instead of a slice to make it simpler for the user. If this proves
-	// slow for large reads we might want to add a separate method for getting the slice
-	// (or an abstraction of it) directly.
-	if db.rdb == nil {
-		return nil, errors.New("rdb is nil!")
-	}
-	return db.rdb.GetBytes(db.ro, key)
-}
-
-// Put stores the value for a key.
-func (db *RocksDB) Put(key []byte, value []byte) error {
-	return db.rdb.Put(db.wo, key, value)
-}
-
-// Delete removes the value for a key.
-func (db *RocksDB) Delete(key []byte) error {
-	return db.rdb.Delete(db.wo, key)
+	kvdb, err := NewKVDB(backend, dir)
+	return kvdb, cleanup, err
 }
 
-// Close gracefully shuts down the database.
-func (db *RocksDB) Close() {
-	db.rdb.Close()
+// copyBytes returns a copy of the value.
+func copyBytes(value []byte) []byte {
+	return append([]byte{}, value...)
 }
@@ -1,78 +1,244 @@
 package db
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-// Test creating a new RocksDB instance.
-func TestRocksDB_NewRocksDB(t *testing.T) {
-	db, cleanup, err := NewTempDirRocksDB()
-	defer cleanup()
-	defer db.Close()
-	assert.Nil(t, err)
+// testBackends are the KVDB backends the shared tests run against.
+var testBackends = []string{BadgerDBBackend, MemoryDBBackend}
 
-	assert.NotNil(t, db.wo)
-	assert.NotNil(t, db.ro)
-	assert.NotNil(t, db.rdb)
+// forEachBackend runs the test against a new temporary KVDB of each backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, db SizedKVDB)) {
+	for _, backend := range testBackends {
+		t.Run(backend, func(t *testing.T) {
+			db, cleanup, err := NewTempDirKVDB(backend)
+			defer cleanup()
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer db.Close()
+			test(t, db)
+		})
+	}
 }
 
-// Test putting and then getting a value works as expected.
-func TestRocksDB_PutGet(t *testing.T) {
-	db, cleanup, err := NewTempDirRocksDB()
-	defer cleanup()
-	defer db.Close()
-	assert.Nil(t, err)
-	key, value1 := []byte("key"), []byte("value1")
-
-	assert.Nil(t, db.Put(key, value1))
-	getValue1, err := db.Get(key)
-	assert.Nil(t, err)
-	assert.Equal(t, value1, getValue1)
+func TestNewKVDB_err(t *testing.T) {
+	db, err := NewKVDB("some unknown backend", "some/dir")
+	assert.Equal(t, ErrUnknownBackend, err)
+	assert.Nil(t, db)
+}
+
+func TestMaybeDirInUse(t *testing.T) {
+	dbDir := "some/db/dir"
+	lockedErrs := []error{
+		errors.New("IO error: While lock file: some/db/dir/LOCK: Resource temporarily unavailable"),
+		errors.New("IO error: lock some/db/dir/LOCK: lock hold by current process"),
+		errors.New("Cannot acquire directory lock on \"some/db/dir\".  Another process is " +
+			"using this Badger database.: resource temporarily unavailable"),
+	}
+	for _, lockedErr := range lockedErrs {
+		err := maybeDirInUse(dbDir, lockedErr)
+		assert.True(t, IsDirInUse(err), lockedErr.Error())
+		assert.Equal(t, dbDir, err.(*DirInUseError).Dir)
+		assert.Equal(t, lockedErr, err.(*DirInUseError).Err)
+		assert.Contains(t, err.Error(), dbDir)
+	}
+
+	otherErrs := []error{
+		errors.New("IO error: some/db/dir/CURRENT: No such file or directory"),
+		errors.New("resource temporarily unavailable"),
+	}
+	for _, otherErr := range otherErrs {
+		err := maybeDirInUse(dbDir, otherErr)
+		assert.False(t, IsDirInUse(err))
+		assert.Equal(t, otherErr, err)
+	}
 }
 
-func TestRocksDB_Get_err(t *testing.T) {
-	db := &RocksDB{}
-	value, err := db.Get([]byte("key"))
-	assert.Nil(t, value)
-	assert.NotNil(t, err)
+// Test putting and then getting a value works as expected.
+func TestKVDB_PutGet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		key, value1 := []byte("key"), []byte("value1")
+
+		assert.Nil(t, db.Put(key, value1))
+		getValue1, err := db.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, value1, getValue1)
+	})
 }
 
 // Test a second put overwrites the value of the first.
-func TestRocksDB_PutGetPutGet(t *testing.T) {
-	db, cleanup, err := NewTempDirRocksDB()
-	defer cleanup()
-	defer db.Close()
-	assert.Nil(t, err)
-	key, value1, value2 := []byte("key"), []byte("value1"), []byte("value2")
-
-	assert.Nil(t, db.Put(key, value1))
-	getValue1, err := db.Get(key)
-	assert.Nil(t, err)
-	assert.Equal(t, value1, getValue1)
-
-	assert.Nil(t, db.Put(key, value2))
-	getValue2, err := db.Get(key)
-	assert.Nil(t, err)
-	assert.Equal(t, value2, getValue2)
+func TestKVDB_PutGetPutGet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		key, value1, value2 := []byte("key"), []byte("value1"), []byte("value2")
+
+		assert.Nil(t, db.Put(key, value1))
+		getValue1, err := db.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, value1, getValue1)
+
+		assert.Nil(t, db.Put(key, value2))
+		getValue2, err := db.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, value2, getValue2)
+	})
+}
+
+// Test putting a batch of values and then getting each works as expected.
+func TestKVDB_PutBatchGet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		keys := [][]byte{[]byte("key1"), []byte("key2"), []byte("key3")}
+		values := [][]byte{[]byte("value1"), []byte("value2"), []byte("value3")}
+
+		assert.Nil(t, db.PutBatch(keys, values))
+		for i, key := range keys {
+			getValue, err := db.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, values[i], getValue)
+		}
+
+		// check mismatched batch stores nothing
+		err := db.PutBatch([][]byte{[]byte("key4")}, [][]byte{})
+		assert.Equal(t, ErrBatchLengthMismatch, err)
+		getValue, err := db.Get([]byte("key4"))
+		assert.Nil(t, err)
+		assert.Nil(t, getValue)
+	})
+}
+
+// Test atomically writing a batch of puts and deletes.
+func TestKVDB_WriteBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		assert.Nil(t, db.Put([]byte("key1"), []byte("value1")))
+
+		keys := [][]byte{[]byte("key2"), []byte("key3")}
+		values := [][]byte{[]byte("value2"), []byte("value3")}
+		assert.Nil(t, db.WriteBatch(keys, values, [][]byte{[]byte("key1")}))
+		getValue, err := db.Get([]byte("key1"))
+		assert.Nil(t, err)
+		assert.Nil(t, getValue)
+		for i, key := range keys {
+			getValue, err = db.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, values[i], getValue)
+		}
+
+		// check mismatched batch neither stores nor deletes anything
+		err = db.WriteBatch([][]byte{[]byte("key4")}, [][]byte{}, [][]byte{[]byte("key2")})
+		assert.Equal(t, ErrBatchLengthMismatch, err)
+		getValue, err = db.Get([]byte("key4"))
+		assert.Nil(t, err)
+		assert.Nil(t, getValue)
+		getValue, err = db.Get([]byte("key2"))
+		assert.Nil(t, err)
+		assert.Equal(t, values[0], getValue)
+	})
 }
 
 // Test deleting a put value.
-func TestRocksDB_PutGetDeleteGet(t *testing.T) {
-	db, cleanup, err := NewTempDirRocksDB()
-	defer cleanup()
-	defer db.Close()
-	assert.Nil(t, err)
-	key, value1 := []byte("key"), []byte("value1")
-
-	assert.Nil(t, db.Put(key, value1))
-	getValue1, err := db.Get(key)
-	assert.Nil(t, err)
-	assert.Equal(t, value1, getValue1)
-
-	assert.Nil(t, db.Delete(key))
-	getValue2, err := db.Get(key)
-	assert.Nil(t, err)
-	assert.Nil(t, getValue2)
+func TestKVDB_PutGetDeleteGet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		key, value1 := []byte("key"), []byte("value1")
+
+		assert.Nil(t, db.Put(key, value1))
+		getValue1, err := db.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, value1, getValue1)
+
+		assert.Nil(t, db.Delete(key))
+		getValue2, err := db.Get(key)
+		assert.Nil(t, err)
+		assert.Nil(t, getValue2)
+	})
+}
+
+// Test iterating over the values with a given key prefix.
+func TestKVDB_Iterate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		for _, key := range []string{"a1", "b1", "b2", "b3", "c1"} {
+			assert.Nil(t, db.Put([]byte(key), []byte("value "+key)))
+		}
+
+		// check only keys with prefix are iterated over, in order
+		keys := make([]string, 0)
+		err := db.Iterate([]byte("b"), func(key, value []byte) error {
+			keys = append(keys, string(key))
+			assert.Equal(t, "value "+string(key), string(value))
+
+			// check writes during iteration aren't seen by it
+			return db.Put([]byte("b4"), []byte("value b4"))
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"b1", "b2", "b3"}, keys)
+
+		// check fn error stops iteration
+		errStop := errors.New("some fn error")
+		keys = make([]string, 0)
+		err = db.Iterate([]byte("b"), func(key, value []byte) error {
+			keys = append(keys, string(key))
+			return errStop
+		})
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, []string{"b1"}, keys)
+
+		// check nothing iterated over for missing prefix
+		err = db.Iterate([]byte("d"), func(key, value []byte) error {
+			return errStop
+		})
+		assert.Nil(t, err)
+	})
+}
+
+// Test iterating over the values with keys in a given range.
+func TestKVDB_IterateRange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		for _, key := range []string{"a1", "b1", "b2", "b3", "c1"} {
+			assert.Nil(t, db.Put([]byte(key), []byte("value "+key)))
+		}
+
+		// check only keys from start (inclusive) to end (exclusive) are iterated over, in order
+		keys := make([]string, 0)
+		err := db.IterateRange([]byte("a2"), []byte("b3"), func(key, value []byte) error {
+			keys = append(keys, string(key))
+			assert.Equal(t, "value "+string(key), string(value))
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"b1", "b2"}, keys)
+
+		// check fn error stops iteration
+		errStop := errors.New("some fn error")
+		keys = make([]string, 0)
+		err = db.IterateRange([]byte("b"), []byte("c"), func(key, value []byte) error {
+			keys = append(keys, string(key))
+			return errStop
+		})
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, []string{"b1"}, keys)
+
+		// check nothing iterated over for empty range
+		err = db.IterateRange([]byte("c2"), []byte("d"), func(key, value []byte) error {
+			return errStop
+		})
+		assert.Nil(t, err)
+	})
+}
+
+func TestKVDB_Size(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db SizedKVDB) {
+		size1, err := db.Size()
+		assert.Nil(t, err)
+
+		// check size grows with stored values
+		value := make([]byte, 1<<16)
+		for i := 0; i < 16; i++ {
+			assert.Nil(t, db.Put([]byte{byte(i)}, value))
+		}
+		size2, err := db.Size()
+		assert.Nil(t, err)
+		assert.True(t, size2 > size1)
+	})
 }
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// errMemoryDBClosed indicates when a MemoryDB is used after it has been closed.
+var errMemoryDBClosed = errors.New("memory DB is closed")
+
+// MemoryDB implements the KVDB interface with a guarded in-memory map, which is mostly useful for
+// tests that don't need their state to persist on disk.
+type MemoryDB struct {
+	kvs map[string][]byte
+	mu  sync.RWMutex
+}
+
+// NewMemoryDB creates a new, empty MemoryDB instance.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		kvs: make(map[string][]byte),
+	}
+}
+
+// Get returns a copy of the value for a key, or nil if the key is missing.
+func (db *MemoryDB) Get(key []byte) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.kvs == nil {
+		return nil, errMemoryDBClosed
+	}
+	value, in := db.kvs[string(key)]
+	if !in {
+		return nil, nil
+	}
+	return copyBytes(value), nil
+}
+
+// Put stores a copy of the value for a key.
+func (db *MemoryDB) Put(key []byte, value []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.kvs == nil {
+		return errMemoryDBClosed
+	}
+	db.kvs[string(key)] = copyBytes(value)
+	return nil
+}
+
+// PutBatch atomically stores copies of the values for their keys.
+func (db *MemoryDB) PutBatch(keys [][]byte, values [][]byte) error {
+	if len(keys) != len(values) {
+		return ErrBatchLengthMismatch
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.kvs == nil {
+		return errMemoryDBClosed
+	}
+	for i, key := range keys {
+		db.kvs[string(key)] = copyBytes(values[i])
+	}
+	return nil
+}
+
+// Delete removes the value for a key.
+func (db *MemoryDB) Delete(key []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.kvs == nil {
+		return errMemoryDBClosed
+	}
+	delete(db.kvs, string(key))
+	return nil
+}
+
+// WriteBatch atomically stores copies of the values for their keys and removes the values for
+// the delete keys.
+func (db *MemoryDB) WriteBatch(keys [][]byte, values [][]byte, deleteKeys [][]byte) error {
+	if len(keys) != len(values) {
+		return ErrBatchLengthMismatch
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.kvs == nil {
+		return errMemoryDBClosed
+	}
+	for i, key := range keys {
+		db.kvs[string(key)] = copyBytes(values[i])
+	}
+	for _, key := range deleteKeys {
+		delete(db.kvs, string(key))
+	}
+	return nil
+}
+
+// Iterate calls fn on each key-value pair whose key starts with the given prefix, in key order,
+// over a snapshot of the database taken when iteration starts. The keys and values passed to fn
+// are copies, so fn may keep or modify them.
+func (db *MemoryDB) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return db.iterate(func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	}, fn)
+}
+
+// IterateRange is like Iterate but over the key-value pairs whose keys are from start
+// (inclusive) to end (exclusive).
+func (db *MemoryDB) IterateRange(start []byte, end []byte, fn func(key, value []byte) error) error {
+	return db.iterate(func(key []byte) bool {
+		return bytes.Compare(key, start) >= 0 && bytes.Compare(key, end) < 0
+	}, fn)
+}
+
+// iterate calls fn on each key-value pair whose key is included, in key order, over a snapshot
+// of the database.
+func (db *MemoryDB) iterate(include func(key []byte) bool, fn func(key, value []byte) error) error {
+	keys, values, err := db.snapshot(include)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err := fn(key, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the number of bytes of the keys and values stored in the database.
+func (db *MemoryDB) Size() (uint64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.kvs == nil {
+		return 0, errMemoryDBClosed
+	}
+	var size uint64
+	for key, value := range db.kvs {
+		size += uint64(len(key) + len(value))
+	}
+	return size, nil
+}
+
+// Close releases the stored values, after which the database can no longer be used.
+func (db *MemoryDB) Close() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.kvs = nil
+}
+
+// snapshot returns copies of the included keys, in key order, and of their values.
+func (db *MemoryDB) snapshot(include func(key []byte) bool) ([][]byte, [][]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.kvs == nil {
+		return nil, nil, errMemoryDBClosed
+	}
+	strKeys := make([]string, 0)
+	for key := range db.kvs {
+		if include([]byte(key)) {
+			strKeys = append(strKeys, key)
+		}
+	}
+	sort.Strings(strKeys)
+	keys, values := make([][]byte, len(strKeys)), make([][]byte, len(strKeys))
+	for i, key := range strKeys {
+		keys[i], values[i] = []byte(key), copyBytes(db.kvs[key])
+	}
+	return keys, values, nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDB_PutGet_copies(t *testing.T) {
+	db := NewMemoryDB()
+	defer db.Close()
+	key, value := []byte("key"), []byte("value")
+	assert.Nil(t, db.Put(key, value))
+
+	// check modifying the put value doesn't modify the stored value
+	value[0] = 'V'
+	getValue1, err := db.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), getValue1)
+
+	// check modifying the got value doesn't modify the stored value
+	getValue1[0] = 'V'
+	getValue2, err := db.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), getValue2)
+}
+
+func TestMemoryDB_Size(t *testing.T) {
+	db := NewMemoryDB()
+	defer db.Close()
+	assert.Nil(t, db.Put([]byte("key1"), []byte("value1")))
+	assert.Nil(t, db.Put([]byte("key2"), []byte("value2")))
+	size, err := db.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(20), size)
+
+	assert.Nil(t, db.Delete([]byte("key1")))
+	size, err = db.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), size)
+}
+
+func TestMemoryDB_Close(t *testing.T) {
+	db := NewMemoryDB()
+	db.Close()
+
+	// check all operations error once closed
+	value, err := db.Get([]byte("key"))
+	assert.Equal(t, errMemoryDBClosed, err)
+	assert.Nil(t, value)
+	assert.Equal(t, errMemoryDBClosed, db.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, errMemoryDBClosed, db.Delete([]byte("key")))
+	err = db.Iterate(nil, func(key, value []byte) error { return nil })
+	assert.Equal(t, errMemoryDBClosed, err)
+	size, err := db.Size()
+	assert.Equal(t, errMemoryDBClosed, err)
+	assert.Zero(t, size)
+}
@@ -0,0 +1,200 @@
+// +build cgo
+
+package db
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"errors"
+
+	"github.com/tecbot/gorocksdb"
+)
+
+// newRocksDB creates a new RocksDB instance in the given directory.
+func newRocksDB(dbDir string) (SizedKVDB, error) {
+	rdb, err := NewRocksDB(dbDir)
+	if err != nil {
+		return nil, err
+	}
+	return rdb, nil
+}
+
+// RocksDB implements the KVStore interface with a thinly wrapped RocksDB instance.
+type RocksDB struct {
+	// Pointer to the RocksDB object
+	rdb *gorocksdb.DB
+
+	// Read options for generic reads
+	ro *gorocksdb.ReadOptions
+
+	// Write options for generic writes
+	wo *gorocksdb.WriteOptions
+}
+
+// NewRocksDB creates a new RocksDB instance with default read and write options. It returns a
+// *DirInUseError if another instance has the directory open.
+func NewRocksDB(dbDir string) (*RocksDB, error) {
+	err := os.MkdirAll(dbDir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	options := gorocksdb.NewDefaultOptions()
+	options.SetCreateIfMissing(true)
+	db, err := gorocksdb.OpenDb(options, dbDir)
+	if err != nil {
+		return nil, maybeDirInUse(dbDir, err)
+	}
+
+	return &RocksDB{
+		rdb: db,
+		ro:  gorocksdb.NewDefaultReadOptions(),
+		wo:  gorocksdb.NewDefaultWriteOptions(),
+	}, nil
+}
+
+// NewTempDirRocksDB creates a new RocksDB instance (used mostly for local testing) in a local
+// temporary directory.
+func NewTempDirRocksDB() (*RocksDB, func(), error) {
+	dir, err := ioutil.TempDir("", "kvdb-test-rocksdb")
+	cleanup := func() {
+		rmErr := os.RemoveAll(dir)
+		if rmErr != nil {
+			panic(rmErr)
+		}
+	}
+	if err != nil {
+		return nil, cleanup, err
+	}
+	rdb, err := NewRocksDB(dir)
+	return rdb, cleanup, err
+}
+
+// Get returns the value for a key.
+func (db *RocksDB) Get(key []byte) ([]byte, error) {
+	// Return copy of bytes instead of a slice to make it simpler for the user. If this proves
+	// slow for large reads we might want to add a separate method for getting the slice
+	// (or an abstraction of it) directly.
+	if db.rdb == nil {
+		return nil, errors.New("rdb is nil!")
+	}
+	return db.rdb.GetBytes(db.ro, key)
+}
+
+// Put stores the value for a key.
+func (db *RocksDB) Put(key []byte, value []byte) error {
+	return db.rdb.Put(db.wo, key, value)
+}
+
+// PutBatch atomically stores the values for their keys in a single write batch.
+func (db *RocksDB) PutBatch(keys [][]byte, values [][]byte) error {
+	if len(keys) != len(values) {
+		return ErrBatchLengthMismatch
+	}
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	for i, key := range keys {
+		wb.Put(key, values[i])
+	}
+	return db.rdb.Write(db.wo, wb)
+}
+
+// Delete removes the value for a key.
+func (db *RocksDB) Delete(key []byte) error {
+	return db.rdb.Delete(db.wo, key)
+}
+
+// WriteBatch atomically stores the values for their keys and removes the values for the delete
+// keys in a single write batch.
+func (db *RocksDB) WriteBatch(keys [][]byte, values [][]byte, deleteKeys [][]byte) error {
+	if len(keys) != len(values) {
+		return ErrBatchLengthMismatch
+	}
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	for i, key := range keys {
+		wb.Put(key, values[i])
+	}
+	for _, key := range deleteKeys {
+		wb.Delete(key)
+	}
+	return db.rdb.Write(db.wo, wb)
+}
+
+// Iterate calls fn on each key-value pair whose key starts with the given prefix, in key order,
+// over a snapshot of the database taken when iteration starts. The keys and values passed to fn
+// are copies, so fn may keep or modify them.
+func (db *RocksDB) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return db.iterate(prefix, func(iter *gorocksdb.Iterator) bool {
+		return iter.ValidForPrefix(prefix)
+	}, fn)
+}
+
+// IterateRange is like Iterate but over the key-value pairs whose keys are from start
+// (inclusive) to end (exclusive).
+func (db *RocksDB) IterateRange(start []byte, end []byte, fn func(key, value []byte) error) error {
+	return db.iterate(start, func(iter *gorocksdb.Iterator) bool {
+		return iter.Valid() && bytes.Compare(copySlice(iter.Key()), end) < 0
+	}, fn)
+}
+
+// iterate calls fn on each key-value pair from the seek key onwards while valid holds for the
+// iterator.
+func (db *RocksDB) iterate(
+	seek []byte, valid func(iter *gorocksdb.Iterator) bool, fn func(key, value []byte) error,
+) error {
+	if db.rdb == nil {
+		return errors.New("rdb is nil!")
+	}
+	snapshot := db.rdb.NewSnapshot()
+	defer db.rdb.ReleaseSnapshot(snapshot)
+	ro := gorocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snapshot)
+	iter := db.rdb.NewIterator(ro)
+	defer iter.Close()
+
+	for iter.Seek(seek); valid(iter); iter.Next() {
+		key, value := copySlice(iter.Key()), copySlice(iter.Value())
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// Size returns the number of bytes of the database's SST files on disk plus those of its
+// memtables, which will be flushed to disk. Since deleted values are only removed from SST files
+// when they are compacted, the size may lag behind deletions.
+func (db *RocksDB) Size() (uint64, error) {
+	if db.rdb == nil {
+		return 0, errors.New("rdb is nil!")
+	}
+	var size uint64
+	for _, property := range []string{
+		"rocksdb.total-sst-files-size",
+		"rocksdb.cur-size-all-mem-tables",
+	} {
+		propertySize, err := strconv.ParseUint(db.rdb.GetProperty(property), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		size += propertySize
+	}
+	return size, nil
+}
+
+// Close gracefully shuts down the database.
+func (db *RocksDB) Close() {
+	db.rdb.Close()
+}
+
+// copySlice copies the data of a RocksDB slice and frees it.
+func copySlice(s *gorocksdb.Slice) []byte {
+	defer s.Free()
+	data := make([]byte, s.Size())
+	copy(data, s.Data())
+	return data
+}
@@ -0,0 +1,8 @@
+// +build !cgo
+
+package db
+
+// newRocksDB returns ErrRocksDBUnavailable since RocksDB requires cgo.
+func newRocksDB(dbDir string) (SizedKVDB, error) {
+	return nil, ErrRocksDBUnavailable
+}
@@ -0,0 +1,61 @@
+// +build cgo
+
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	testBackends = append(testBackends, RocksDBBackend)
+}
+
+// Test creating a new RocksDB instance.
+func TestRocksDB_NewRocksDB(t *testing.T) {
+	db, cleanup, err := NewTempDirRocksDB()
+	defer cleanup()
+	defer db.Close()
+	assert.Nil(t, err)
+
+	assert.NotNil(t, db.wo)
+	assert.NotNil(t, db.ro)
+	assert.NotNil(t, db.rdb)
+}
+
+func TestRocksDB_NewRocksDB_dirInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvdb-test-rocksdb")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	db1, err := NewRocksDB(dir)
+	assert.Nil(t, err)
+	defer db1.Close()
+
+	// check opening the same dir again gives a dir in use error
+	db2, err := NewRocksDB(dir)
+	assert.True(t, IsDirInUse(err))
+	assert.Nil(t, db2)
+}
+
+func TestRocksDB_Get_err(t *testing.T) {
+	db := &RocksDB{}
+	value, err := db.Get([]byte("key"))
+	assert.Nil(t, value)
+	assert.NotNil(t, err)
+}
+
+func TestRocksDB_Iterate_err(t *testing.T) {
+	db := &RocksDB{}
+	err := db.Iterate([]byte("prefix"), func(key, value []byte) error { return nil })
+	assert.NotNil(t, err)
+}
+
+func TestRocksDB_Size_err(t *testing.T) {
+	db := &RocksDB{}
+	size, err := db.Size()
+	assert.Zero(t, size)
+	assert.NotNil(t, err)
+}
@@ -64,6 +64,28 @@ func newRandom(reader io.Reader) ID {
 	return FromPrivateKey(key)
 }
 
+// NewFromSeed deterministically creates a new ID instance from the seed source of entropy. It
+// reads 8 more bytes than the size of the curve order so the bias of the resulting private key
+// is negligible.
+func NewFromSeed(seed io.Reader) (ID, error) {
+	params := Curve.Params()
+	buf := make([]byte, params.BitSize/8+8)
+	if _, err := io.ReadFull(seed, buf); err != nil {
+		return nil, err
+	}
+	one := big.NewInt(1)
+	d := new(big.Int).SetBytes(buf)
+	d.Mod(d, new(big.Int).Sub(params.N, one))
+	d.Add(d, one)
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: Curve},
+		D:         d,
+	}
+	key.PublicKey.X, key.PublicKey.Y = Curve.ScalarBaseMult(d.Bytes())
+	return FromPrivateKey(key), nil
+}
+
 func (x *ecid) String() string {
 	return x.id.String()
 }
@@ -1,6 +1,7 @@
 package ecid
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"math/rand"
@@ -44,6 +45,26 @@ func TestEcid_NewPsuedoRandom(t *testing.T) {
 	}
 }
 
+func TestEcid_NewFromSeed_ok(t *testing.T) {
+	seed := make([]byte, 40)
+	rand.New(rand.NewSource(0)).Read(seed)
+	val1, err := NewFromSeed(bytes.NewReader(seed))
+	assert.Nil(t, err)
+	val2, err := NewFromSeed(bytes.NewReader(seed))
+	assert.Nil(t, err)
+
+	// check same seed gives same key & key is valid
+	assert.Equal(t, val1.Key().D, val2.Key().D)
+	assert.Equal(t, val1.PublicKeyBytes(), val2.PublicKeyBytes())
+	assert.True(t, Curve.IsOnCurve(val1.Key().X, val1.Key().Y))
+}
+
+func TestEcid_NewFromSeed_err(t *testing.T) {
+	val, err := NewFromSeed(&truncReader{})
+	assert.NotNil(t, err)
+	assert.Nil(t, val)
+}
+
 func TestEcid_String(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	for c := 0; c < 10; c++ {
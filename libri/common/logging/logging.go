@@ -23,3 +23,34 @@ func NewDevLogger(logLevel zapcore.Level) *zap.Logger {
 func NewDevInfoLogger() *zap.Logger {
 	return NewDevLogger(zap.InfoLevel)
 }
+
+// WithLevel returns a copy of the logger that drops entries below the given level, e.g., to apply
+// a configured log level to an injected logger. It can only raise the logger's level, since the
+// entries the logger already drops are never passed to it.
+func WithLevel(logger *zap.Logger, level zapcore.Level) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
+}
+
+// levelCore is a zapcore.Core that drops entries below its level before passing them to the
+// wrapped Core.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
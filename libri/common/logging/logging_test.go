@@ -1,10 +1,13 @@
 package server
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestNewDevLogger(t *testing.T) {
@@ -16,3 +19,28 @@ func TestNewDevInfoLogger(t *testing.T) {
 	l := NewDevInfoLogger()
 	assert.NotNil(t, l)
 }
+
+func TestWithLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := WithLevel(newBufferLogger(buf, zap.DebugLevel), zap.InfoLevel)
+
+	// check entries below level are dropped, including from derived loggers
+	l.Debug("some debug message")
+	l.Info("some info message")
+	l.With(zap.String("some", "field")).Debug("some other debug message")
+	l.With(zap.String("some", "field")).Warn("some warn message")
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	assert.False(t, l.Core().Enabled(zap.DebugLevel))
+	assert.True(t, l.Core().Enabled(zap.InfoLevel))
+
+	// check can't lower the logger's level
+	buf.Reset()
+	l = WithLevel(newBufferLogger(buf, zap.WarnLevel), zap.DebugLevel)
+	l.Info("some info message")
+	assert.Equal(t, 0, buf.Len())
+}
+
+func newBufferLogger(buf *bytes.Buffer, level zapcore.Level) *zap.Logger {
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(buf), level))
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+)
+
+// DocumentPinner marks documents as never to be evicted.
+type DocumentPinner interface {
+	// Pin marks the document with the given key as one this node is responsible for
+	// replicating, so it is never evicted. The document need not be stored yet.
+	Pin(key cid.ID)
+
+	// Unpin reverses a previous Pin, making the document with the given key evictable again
+	// from the next Store on.
+	Unpin(key cid.ID)
+}
+
+// BoundedDocumentSLD is a DocumentSLD that evicts the least recently accessed documents once the
+// total size of its documents exceeds a byte budget.
+type BoundedDocumentSLD interface {
+	DocumentSLD
+	DocumentPinner
+
+	// NBytes returns the total number of bytes the tracked documents take in storage.
+	NBytes() uint64
+}
+
+type trackedDocument struct {
+	key   cid.ID
+	size  uint64
+	entry *list.Element
+}
+
+// keyLock serializes the inner storage I/O on a single document.
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+type boundedDocumentSLD struct {
+	inner    DocumentSLD
+	maxBytes uint64
+	nBytes   uint64
+
+	// tracked documents by key string, with the most recently accessed at the front of recent
+	docs   map[string]*trackedDocument
+	recent *list.List
+	pinned map[string]struct{}
+
+	// mu guards the fields above and keyLocks, but is never held during inner I/O
+	keyLocks map[string]*keyLock
+	mu       sync.Mutex
+}
+
+// NewBoundedDocumentSLD creates a new BoundedDocumentSLD wrapping an inner DocumentSLD. When a
+// Store brings the total size of documents over maxBytes, the least recently stored or loaded
+// documents are deleted from the inner DocumentSLD until it is under budget again, though pinned
+// documents and the document just stored are never evicted. If the inner DocumentSLD is also a
+// DocumentIterator, the documents it already has are tracked from the start as the least
+// recently accessed; otherwise, they are tracked once stored or loaded.
+func NewBoundedDocumentSLD(inner DocumentSLD, maxBytes uint64) (BoundedDocumentSLD, error) {
+	b := &boundedDocumentSLD{
+		inner:    inner,
+		maxBytes: maxBytes,
+		docs:     make(map[string]*trackedDocument),
+		recent:   list.New(),
+		pinned:   make(map[string]struct{}),
+		keyLocks: make(map[string]*keyLock),
+	}
+	if docs, ok := inner.(DocumentIterator); ok {
+		err := docs.Iterate(func(key cid.ID, value *api.Document) error {
+			// iterated in key order, so push each to the back as least recently accessed
+			doc := &trackedDocument{key: key, size: documentSize(key, value)}
+			doc.entry = b.recent.PushBack(doc)
+			b.docs[key.String()] = doc
+			b.nBytes += doc.size
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *boundedDocumentSLD) Store(key cid.ID, value *api.Document) error {
+	unlock := b.lockKey(key)
+	if err := b.inner.Store(key, value); err != nil {
+		unlock()
+		return err
+	}
+	b.mu.Lock()
+	b.track(key, documentSize(key, value))
+	victims := b.victims()
+	b.mu.Unlock()
+	unlock()
+	return b.evict(victims)
+}
+
+func (b *boundedDocumentSLD) Load(key cid.ID) (*api.Document, error) {
+	// hold key lock while loading so a concurrent Delete can't leave a loaded document tracked
+	unlock := b.lockKey(key)
+	defer unlock()
+	value, err := b.inner.Load(key)
+	if err != nil || value == nil {
+		return value, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.track(key, documentSize(key, value))
+	return value, nil
+}
+
+// Delete deletes the document with the given key, also unpinning it since it's no longer wanted.
+func (b *boundedDocumentSLD) Delete(key cid.ID) error {
+	unlock := b.lockKey(key)
+	defer unlock()
+	if err := b.inner.Delete(key); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.untrack(key)
+	delete(b.pinned, key.String())
+	return nil
+}
+
+func (b *boundedDocumentSLD) Pin(key cid.ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pinned[key.String()] = struct{}{}
+}
+
+func (b *boundedDocumentSLD) Unpin(key cid.ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pinned, key.String())
+}
+
+func (b *boundedDocumentSLD) NBytes() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nBytes
+}
+
+// lockKey acquires the lock serializing I/O on the document with the given key and returns the
+// function releasing it.
+func (b *boundedDocumentSLD) lockKey(key cid.ID) func() {
+	keyStr := key.String()
+	b.mu.Lock()
+	kl, in := b.keyLocks[keyStr]
+	if !in {
+		kl = &keyLock{}
+		b.keyLocks[keyStr] = kl
+	}
+	kl.refs++
+	b.mu.Unlock()
+
+	kl.mu.Lock()
+	return func() {
+		kl.mu.Unlock()
+		b.mu.Lock()
+		kl.refs--
+		if kl.refs == 0 {
+			delete(b.keyLocks, keyStr)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// track records the document with the given key and size as the most recently accessed.
+func (b *boundedDocumentSLD) track(key cid.ID, size uint64) {
+	keyStr := key.String()
+	if doc, in := b.docs[keyStr]; in {
+		b.nBytes = b.nBytes - doc.size + size
+		doc.size = size
+		b.recent.MoveToFront(doc.entry)
+		return
+	}
+	doc := &trackedDocument{key: key, size: size}
+	doc.entry = b.recent.PushFront(doc)
+	b.docs[keyStr] = doc
+	b.nBytes += size
+}
+
+func (b *boundedDocumentSLD) untrack(key cid.ID) {
+	keyStr := key.String()
+	if doc, in := b.docs[keyStr]; in {
+		b.recent.Remove(doc.entry)
+		delete(b.docs, keyStr)
+		b.nBytes -= doc.size
+	}
+}
+
+// victims returns the keys of the least recently accessed unpinned documents, other than the
+// most recent one, whose eviction would bring the total size within budget.
+func (b *boundedDocumentSLD) victims() []cid.ID {
+	victims := make([]cid.ID, 0)
+	nBytes := b.nBytes
+	next := b.recent.Back()
+	for nBytes > b.maxBytes && next != nil && next != b.recent.Front() {
+		doc := next.Value.(*trackedDocument)
+		if _, pinned := b.pinned[doc.key.String()]; !pinned {
+			victims = append(victims, doc.key)
+			nBytes -= doc.size
+		}
+		next = next.Prev()
+	}
+	return victims
+}
+
+// evict deletes the given documents from the inner DocumentSLD while the total size is still
+// over budget, skipping any that have since been pinned or deleted.
+func (b *boundedDocumentSLD) evict(victims []cid.ID) error {
+	for _, key := range victims {
+		if err := b.evictOne(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *boundedDocumentSLD) evictOne(key cid.ID) error {
+	unlock := b.lockKey(key)
+	defer unlock()
+	b.mu.Lock()
+	_, tracked := b.docs[key.String()]
+	_, pinned := b.pinned[key.String()]
+	overBudget := b.nBytes > b.maxBytes
+	b.mu.Unlock()
+	if !tracked || pinned || !overBudget {
+		return nil
+	}
+	if err := b.inner.Delete(key); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.untrack(key)
+	return nil
+}
+
+// documentSize returns the number of bytes the document takes in the KVDB, which stores its
+// marshaled value under its key prefixed by the documents namespace.
+func documentSize(key cid.ID, value *api.Document) uint64 {
+	return uint64(len(Documents) + len(key.Bytes()) + proto.Size(value))
+}
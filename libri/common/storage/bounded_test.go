@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/db"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedDocumentSLD_StoreLoadDelete(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	inner := newMemDocumentSLD()
+	docs, keys := newTestDocuments(rng, 4)
+	docSize := documentSize(keys[0], docs[0])
+	b, err := NewBoundedDocumentSLD(inner, 3*docSize)
+	assert.Nil(t, err)
+
+	// check documents within budget are all kept
+	for i := 0; i < 3; i++ {
+		err = b.Store(keys[i], docs[i])
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3*docSize, b.NBytes())
+	assert.Equal(t, 3, inner.len())
+
+	// check least recently accessed document is evicted once over budget
+	loaded, err := b.Load(keys[0])
+	assert.Nil(t, err)
+	assert.Equal(t, docs[0], loaded)
+	err = b.Store(keys[3], docs[3])
+	assert.Nil(t, err)
+	assert.Equal(t, 3*docSize, b.NBytes())
+	loaded, err = b.Load(keys[1])
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+	for _, i := range []int{0, 2, 3} {
+		loaded, err = b.Load(keys[i])
+		assert.Nil(t, err)
+		assert.Equal(t, docs[i], loaded)
+	}
+
+	// check storing same document again doesn't double count it
+	err = b.Store(keys[3], docs[3])
+	assert.Nil(t, err)
+	assert.Equal(t, 3*docSize, b.NBytes())
+
+	// check deleted documents are no longer counted
+	err = b.Delete(keys[2])
+	assert.Nil(t, err)
+	assert.Equal(t, 2*docSize, b.NBytes())
+	assert.Equal(t, 2, inner.len())
+}
+
+func TestBoundedDocumentSLD_Pin(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	inner := newMemDocumentSLD()
+	docs, keys := newTestDocuments(rng, 4)
+	docSize := documentSize(keys[0], docs[0])
+	b, err := NewBoundedDocumentSLD(inner, 2*docSize)
+	assert.Nil(t, err)
+
+	// check pinned documents are never evicted, even when over budget
+	b.Pin(keys[0])
+	b.Pin(keys[1])
+	for i := 0; i < 4; i++ {
+		err = b.Store(keys[i], docs[i])
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3*docSize, b.NBytes())
+	for i, expected := range []bool{true, true, false, true} {
+		loaded, err := b.Load(keys[i])
+		assert.Nil(t, err)
+		assert.Equal(t, expected, loaded != nil, "doc %d", i)
+	}
+}
+
+func TestBoundedDocumentSLD_Unpin(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	inner := newMemDocumentSLD()
+	docs, keys := newTestDocuments(rng, 3)
+	docSize := documentSize(keys[0], docs[0])
+	b, err := NewBoundedDocumentSLD(inner, 2*docSize)
+	assert.Nil(t, err)
+
+	b.Pin(keys[0])
+	for i := 0; i < 3; i++ {
+		err = b.Store(keys[i], docs[i])
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2*docSize, b.NBytes())
+
+	// check unpinned document is evicted by the next Store over budget
+	b.Unpin(keys[0])
+	err = b.Store(keys[1], docs[1])
+	assert.Nil(t, err)
+	assert.Equal(t, 2*docSize, b.NBytes())
+	loaded, err := b.Load(keys[0])
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestBoundedDocumentSLD_Load_untracked(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	inner := newMemDocumentSLD()
+	docs, keys := newTestDocuments(rng, 1)
+	err := inner.Store(keys[0], docs[0])
+	assert.Nil(t, err)
+	b, err := NewBoundedDocumentSLD(inner, 0)
+	assert.Nil(t, err)
+
+	// check documents stored before wrapping are tracked once loaded
+	assert.Zero(t, b.NBytes())
+	loaded, err := b.Load(keys[0])
+	assert.Nil(t, err)
+	assert.Equal(t, docs[0], loaded)
+	assert.Equal(t, documentSize(keys[0], docs[0]), b.NBytes())
+}
+
+func TestNewBoundedDocumentSLD_seed(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	inner := NewDocumentSLD(kvdb)
+	docs, keys := newTestDocuments(rng, 4)
+	for i := 0; i < 3; i++ {
+		err := inner.Store(keys[i], docs[i])
+		assert.Nil(t, err)
+	}
+
+	// check documents already stored are tracked with the bytes they take in the DB
+	b, err := NewBoundedDocumentSLD(inner, 3*documentSize(keys[0], docs[0]))
+	assert.Nil(t, err)
+	nDBBytes := uint64(0)
+	err = kvdb.Iterate(Documents, func(key, value []byte) error {
+		nDBBytes += uint64(len(key) + len(value))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, nDBBytes, b.NBytes())
+
+	// check documents already stored are evicted before those stored or loaded since
+	loaded, err := b.Load(keys[0])
+	assert.Nil(t, err)
+	assert.Equal(t, docs[0], loaded)
+	err = b.Store(keys[3], docs[3])
+	assert.Nil(t, err)
+	assert.Equal(t, nDBBytes, b.NBytes())
+	nStored := 0
+	for i := range keys {
+		loaded, err = inner.Load(keys[i])
+		assert.Nil(t, err)
+		if loaded != nil {
+			nStored++
+		}
+	}
+	assert.Equal(t, 3, nStored)
+	loaded, err = inner.Load(keys[0])
+	assert.Nil(t, err)
+	assert.NotNil(t, loaded)
+
+	// check Iterate error bubbles up
+	_, err = NewBoundedDocumentSLD(&errDocumentIterator{memDocumentSLD: newMemDocumentSLD()}, 0)
+	assert.NotNil(t, err)
+}
+
+func TestBoundedDocumentSLD_Delete_pinned(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	inner := newMemDocumentSLD()
+	docs, keys := newTestDocuments(rng, 2)
+	docSize := documentSize(keys[0], docs[0])
+	b, err := NewBoundedDocumentSLD(inner, docSize)
+	assert.Nil(t, err)
+
+	// check deleting a pinned document also unpins it, so it's evictable once stored again
+	b.Pin(keys[0])
+	assert.Nil(t, b.Store(keys[0], docs[0]))
+	assert.Nil(t, b.Delete(keys[0]))
+	assert.Nil(t, b.Store(keys[0], docs[0]))
+	assert.Nil(t, b.Store(keys[1], docs[1]))
+	assert.Equal(t, docSize, b.NBytes())
+	loaded, err := b.Load(keys[0])
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestBoundedDocumentSLD_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	docs, keys := newTestDocuments(rng, 1)
+	inner := newMemDocumentSLD()
+	inner.err = errors.New("some storage error")
+	b, err := NewBoundedDocumentSLD(inner, 0)
+	assert.Nil(t, err)
+
+	err = b.Store(keys[0], docs[0])
+	assert.NotNil(t, err)
+	loaded, err := b.Load(keys[0])
+	assert.NotNil(t, err)
+	assert.Nil(t, loaded)
+	err = b.Delete(keys[0])
+	assert.NotNil(t, err)
+	assert.Zero(t, b.NBytes())
+}
+
+func TestBoundedDocumentSLD_concurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	inner := newMemDocumentSLD()
+	docs, keys := newTestDocuments(rng, 32)
+	docSize := documentSize(keys[0], docs[0])
+	b, err := NewBoundedDocumentSLD(inner, 8*docSize)
+	assert.Nil(t, err)
+
+	wg := new(sync.WaitGroup)
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for i := c; i < len(keys); i += 4 {
+				assert.Nil(t, b.Store(keys[i], docs[i]))
+				_, err := b.Load(keys[(i+1)%len(keys)])
+				assert.Nil(t, err)
+				if i%3 == 0 {
+					assert.Nil(t, b.Delete(keys[i]))
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	// check tracked size matches what's actually stored
+	assert.True(t, b.NBytes() <= 8*docSize)
+	assert.Equal(t, uint64(inner.len())*docSize, b.NBytes())
+}
+
+// newTestDocuments creates n test documents of equal size and their keys.
+func newTestDocuments(rng *rand.Rand, n int) ([]*api.Document, []cid.ID) {
+	docs, keys := make([]*api.Document, n), make([]cid.ID, n)
+	for i := range docs {
+		docs[i], keys[i] = api.NewTestDocument(rng)
+	}
+	return docs, keys
+}
+
+// memDocumentSLD is an in-memory DocumentSLD.
+type memDocumentSLD struct {
+	docs map[string]*api.Document
+	err  error
+	mu   sync.Mutex
+}
+
+func newMemDocumentSLD() *memDocumentSLD {
+	return &memDocumentSLD{docs: make(map[string]*api.Document)}
+}
+
+func (m *memDocumentSLD) Store(key cid.ID, value *api.Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.docs[key.String()] = value
+	return nil
+}
+
+func (m *memDocumentSLD) Load(key cid.ID) (*api.Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.docs[key.String()], nil
+}
+
+func (m *memDocumentSLD) Delete(key cid.ID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.docs, key.String())
+	return nil
+}
+
+// errDocumentIterator is a memDocumentSLD whose Iterate always errors.
+type errDocumentIterator struct {
+	*memDocumentSLD
+}
+
+func (e *errDocumentIterator) Iterate(fn func(key cid.ID, value *api.Document) error) error {
+	return errors.New("some Iterate error")
+}
+
+func (m *memDocumentSLD) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.docs)
+}
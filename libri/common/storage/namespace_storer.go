@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"errors"
+
 	"github.com/drausin/libri/libri/common/db"
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
@@ -32,8 +34,19 @@ var (
 
 	// Documents namespace contains all libri p2p stored values.
 	Documents Namespace = []byte("documents")
+
+	// Uploads namespace contains the state of in-progress client uploads.
+	Uploads Namespace = []byte("uploads")
+
+	// Audit namespace contains the append-only audit records of a client's uploads and shares.
+	Audit Namespace = []byte("audit")
 )
 
+// ErrCorruptDocument indicates when a stored document no longer matches the SHA256 hash of its
+// value, which is also its key, or can no longer be unmarshaled and validated, e.g., because of
+// bit rot on disk.
+var ErrCorruptDocument = errors.New("stored document is corrupt")
+
 // Namespace denotes a storage namespace, which reduces to a key prefix.
 type Namespace []byte
 
@@ -43,6 +56,14 @@ type NamespaceStorer interface {
 	Store(key []byte, value []byte) error
 }
 
+// NamespaceBatchStorer atomically stores a batch of values to durable storage under the
+// configured namespace.
+type NamespaceBatchStorer interface {
+	// StoreBatch stores the values for the keys in the configured namespace, so either all or
+	// none of them are stored.
+	StoreBatch(keys [][]byte, values [][]byte) error
+}
+
 // NamespaceLoader loads a value in the configured namespace from the durable storage.
 type NamespaceLoader interface {
 	// Load the value for the key in the configured namespace.
@@ -54,10 +75,23 @@ type NamespaceDeleter interface {
 	Delete(key []byte) error
 }
 
-// NamespaceSL both stores and loads values in a configured namespace.
+// NamespaceIterator iterates over values in the configured namespace of the durable storage.
+type NamespaceIterator interface {
+	// Iterate calls fn on each key-value pair in the configured namespace whose key starts with
+	// the given prefix, in key order, over a consistent snapshot of the storage. Iteration stops
+	// early if fn returns an error, which is then returned.
+	Iterate(prefix []byte, fn func(key, value []byte) error) error
+
+	// IterateRange is like Iterate but over the key-value pairs in the configured namespace
+	// whose keys are from start (inclusive) to end (exclusive), seeking directly to start.
+	IterateRange(start []byte, end []byte, fn func(key, value []byte) error) error
+}
+
+// NamespaceSL stores, loads, and iterates over values in a configured namespace.
 type NamespaceSL interface {
 	NamespaceStorer
 	NamespaceLoader
+	NamespaceIterator
 }
 
 // NamespaceSLD stores, loads, and deletes values in a configured namespace.
@@ -95,10 +129,49 @@ func NewClientSL(kvdb db.KVDB) NamespaceSL {
 	}
 }
 
+// NewUploadSLD creates a new NamespaceSLD for the "uploads" namespace backed by a db.KVDB
+// instance.
+func NewUploadSLD(kvdb db.KVDB) NamespaceSLD {
+	return &namespaceSLD{
+		ns: Uploads,
+		sld: NewKVDBStorerLoaderDeleter(
+			kvdb,
+			NewMaxLengthChecker(MaxNamespaceKeyLength),
+			NewMaxLengthChecker(MaxNamespaceValueLength),
+		),
+	}
+}
+
+// NewBatchWriter creates a new BatchWriter backed by a db.KVDB instance for batches across the
+// namespaces of NamespaceSLs backed by the same instance.
+func NewBatchWriter(kvdb db.KVDB) BatchWriter {
+	return NewKVDBStorerLoaderDeleter(
+		kvdb,
+		NewMaxLengthChecker(MaxNamespaceKeyLength),
+		NewMaxLengthChecker(MaxNamespaceValueLength),
+	)
+}
+
+// NewAuditSL creates a new NamespaceSL for the "audit" namespace backed by a db.KVDB instance.
+func NewAuditSL(kvdb db.KVDB) NamespaceSL {
+	return &namespaceSLD{
+		ns: Audit,
+		sld: NewKVDBStorerLoaderDeleter(
+			kvdb,
+			NewMaxLengthChecker(MaxNamespaceKeyLength),
+			NewMaxLengthChecker(MaxNamespaceValueLength),
+		),
+	}
+}
+
 func (nsl *namespaceSLD) Store(key []byte, value []byte) error {
 	return nsl.sld.Store(nsl.ns, key, value)
 }
 
+func (nsl *namespaceSLD) StoreBatch(keys [][]byte, values [][]byte) error {
+	return nsl.sld.StoreBatch(nsl.ns, keys, values)
+}
+
 func (nsl *namespaceSLD) Load(key []byte) ([]byte, error) {
 	return nsl.sld.Load(nsl.ns, key)
 }
@@ -107,12 +180,29 @@ func (nsl *namespaceSLD) Delete(key []byte) error {
 	return nsl.sld.Delete(nsl.ns, key)
 }
 
+func (nsl *namespaceSLD) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return nsl.sld.Iterate(nsl.ns, prefix, fn)
+}
+
+func (nsl *namespaceSLD) IterateRange(
+	start []byte, end []byte, fn func(key, value []byte) error,
+) error {
+	return nsl.sld.IterateRange(nsl.ns, start, end, fn)
+}
+
 // DocumentStorer stores api.Document values.
 type DocumentStorer interface {
 	// Store an api.Document value under the given key.
 	Store(key cid.ID, value *api.Document) error
 }
 
+// DocumentBatchStorer atomically stores batches of api.Document values.
+type DocumentBatchStorer interface {
+	// StoreBatch stores the api.Document values under their keys, so either all or none of them
+	// are stored.
+	StoreBatch(keys []cid.ID, values []*api.Document) error
+}
+
 // DocumentLoader loads api.Document values.
 type DocumentLoader interface {
 	// Load an api.Document value with the given key.
@@ -143,14 +233,39 @@ type DocumentSLD interface {
 	DocumentDeleter
 }
 
+// DocumentIterator iterates over stored api.Document values.
+type DocumentIterator interface {
+	// Iterate calls fn on each stored api.Document value and its key, over a consistent
+	// snapshot of the storage. Iteration stops early if fn returns an error, which is then
+	// returned.
+	Iterate(fn func(key cid.ID, value *api.Document) error) error
+}
+
+// namespaceBatchSLD stores (individually or in batches), loads, and deletes values in a
+// configured namespace.
+type namespaceBatchSLD interface {
+	NamespaceSLD
+	NamespaceBatchStorer
+}
+
 type documentSLD struct {
-	sld NamespaceSLD
+	sld namespaceBatchSLD
 	c   KeyValueChecker
 }
 
 // NewDocumentSLD creates a new NamespaceSL for the "entries" namespace
 // backed by a db.KVDB instance.
 func NewDocumentSLD(kvdb db.KVDB) DocumentSLD {
+	return newDocumentSLD(kvdb)
+}
+
+// NewDocumentIterator creates a new DocumentIterator over the "documents" namespace backed by a
+// db.KVDB instance.
+func NewDocumentIterator(kvdb db.KVDB) DocumentIterator {
+	return newDocumentSLD(kvdb)
+}
+
+func newDocumentSLD(kvdb db.KVDB) *documentSLD {
 	return &documentSLD{
 		sld: &namespaceSLD{
 			ns: Documents,
@@ -166,18 +281,45 @@ func NewDocumentSLD(kvdb db.KVDB) DocumentSLD {
 
 // Store checks that the key equals the SHA256 hash of the value before storing it.
 func (dsld *documentSLD) Store(key cid.ID, value *api.Document) error {
-	if err := api.ValidateDocument(value); err != nil {
+	keyBytes, valueBytes, err := dsld.marshal(key, value)
+	if err != nil {
 		return err
 	}
+	return dsld.sld.Store(keyBytes, valueBytes)
+}
+
+// StoreBatch checks that each key equals the SHA256 hash of its value before atomically storing
+// them all.
+func (dsld *documentSLD) StoreBatch(keys []cid.ID, values []*api.Document) error {
+	if len(keys) != len(values) {
+		return db.ErrBatchLengthMismatch
+	}
+	keysBytes, valuesBytes := make([][]byte, len(keys)), make([][]byte, len(keys))
+	for i, key := range keys {
+		var err error
+		keysBytes[i], valuesBytes[i], err = dsld.marshal(key, values[i])
+		if err != nil {
+			return err
+		}
+	}
+	return dsld.sld.StoreBatch(keysBytes, valuesBytes)
+}
+
+// marshal validates and marshals the document, checking that the key equals the SHA256 hash of
+// the marshaled value.
+func (dsld *documentSLD) marshal(key cid.ID, value *api.Document) ([]byte, []byte, error) {
+	if err := api.ValidateDocument(value); err != nil {
+		return nil, nil, err
+	}
 	valueBytes, err := proto.Marshal(value)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	keyBytes := key.Bytes()
 	if err := dsld.c.Check(keyBytes, valueBytes); err != nil {
-		return err
+		return nil, nil, err
 	}
-	return dsld.sld.Store(keyBytes, valueBytes)
+	return keyBytes, valueBytes, nil
 }
 
 func (dsld *documentSLD) Load(key cid.ID) (*api.Document, error) {
@@ -189,17 +331,17 @@ func (dsld *documentSLD) Load(key cid.ID) (*api.Document, error) {
 	if valueBytes == nil {
 		return nil, nil
 	}
+	// since the key is the hash of the value checked on Store, it also serves as the checksum of
+	// the stored value
 	if err := dsld.c.Check(keyBytes, valueBytes); err != nil {
-		// should never happen b/c we check on Store, but being defensive just in case
-		return nil, err
+		return nil, ErrCorruptDocument
 	}
 	doc := &api.Document{}
 	if err := proto.Unmarshal(valueBytes, doc); err != nil {
-		return nil, err
+		return nil, ErrCorruptDocument
 	}
 	if err := api.ValidateDocument(doc); err != nil {
-		// should never happen b/c we check on Store, but being defensive just in case
-		return nil, err
+		return nil, ErrCorruptDocument
 	}
 	return doc, nil
 }
@@ -207,3 +349,13 @@ func (dsld *documentSLD) Load(key cid.ID) (*api.Document, error) {
 func (dsld *documentSLD) Delete(key cid.ID) error {
 	return dsld.sld.Delete(key.Bytes())
 }
+
+func (dsld *documentSLD) Iterate(fn func(key cid.ID, value *api.Document) error) error {
+	return dsld.sld.Iterate(nil, func(keyBytes, valueBytes []byte) error {
+		doc := &api.Document{}
+		if err := proto.Unmarshal(valueBytes, doc); err != nil {
+			return err
+		}
+		return fn(cid.FromBytes(keyBytes), doc)
+	})
+}
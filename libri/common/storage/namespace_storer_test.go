@@ -24,10 +24,8 @@ func TestServerClientStorerLoader_StoreLoad_ok(t *testing.T) {
 		{cid.NewPseudoRandom(rng).Bytes(), []byte("test value")},
 	}
 
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	ssl := NewServerSL(kvdb)
 	csl := NewClientSL(kvdb)
 
@@ -59,10 +57,8 @@ func TestServerClientStorerLoader_Store_err(t *testing.T) {
 		{[]byte("test key"), []byte("")},                       // empty value
 	}
 
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	ssl := NewServerSL(kvdb)
 	csl := NewClientSL(kvdb)
 
@@ -84,32 +80,175 @@ func TestServerClientStorerLoader_Load_err(t *testing.T) {
 		{bytes.Repeat([]byte{255}, 257), []byte("test value")}, // too long key
 	}
 
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	ssl := NewServerSL(kvdb)
 	csl := NewServerSL(kvdb)
 
 	for _, c := range cases {
-		_, err = ssl.Load(c.key)
+		_, err := ssl.Load(c.key)
 		assert.NotNil(t, err)
 		_, err = csl.Load(c.key)
 		assert.NotNil(t, err)
 	}
 }
 
-func TestDocumentNamespaceStorerLoader_StoreLoad_ok(t *testing.T) {
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+func TestUploadStorerLoaderDeleter_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	usld := NewUploadSLD(kvdb)
+	csl := NewClientSL(kvdb)
+
+	key, value := cid.NewPseudoRandom(rng).Bytes(), []byte("test value")
+	err := usld.Store(key, value)
+	assert.Nil(t, err)
+
+	loaded, err := usld.Load(key)
+	assert.Nil(t, err)
+	assert.Equal(t, value, loaded)
+
+	// check value isn't visible in client namespace
+	loaded, err = csl.Load(key)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+
+	err = usld.Delete(key)
+	assert.Nil(t, err)
+	loaded, err = usld.Load(key)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestUploadStorerLoaderDeleter_Iterate(t *testing.T) {
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	usld := NewUploadSLD(kvdb)
+	csl := NewClientSL(kvdb)
+	for _, key := range []string{"a1", "a2", "b1"} {
+		assert.Nil(t, usld.Store([]byte(key), []byte("value "+key)))
+	}
+	assert.Nil(t, csl.Store([]byte("a3"), []byte("value a3")))
+
+	// check only keys in namespace with prefix are iterated over
+	iterated := make(map[string]string)
+	err := usld.Iterate([]byte("a"), func(key, value []byte) error {
+		iterated[string(key)] = string(value)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a1": "value a1", "a2": "value a2"}, iterated)
+
+	// check empty prefix iterates over whole namespace
+	nIterated := 0
+	err = usld.Iterate(nil, func(key, value []byte) error {
+		nIterated++
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, nIterated)
+
+	// check fn error stops iteration
+	errStop := errors.New("some fn error")
+	nIterated = 0
+	err = usld.Iterate(nil, func(key, value []byte) error {
+		nIterated++
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, 1, nIterated)
+}
+
+func TestUploadStorerLoaderDeleter_IterateRange(t *testing.T) {
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	usld := NewUploadSLD(kvdb)
+	csl := NewClientSL(kvdb)
+	for _, key := range []string{"a1", "a2", "b1"} {
+		assert.Nil(t, usld.Store([]byte(key), []byte("value "+key)))
+	}
+	assert.Nil(t, csl.Store([]byte("a3"), []byte("value a3")))
+
+	// check only keys in namespace and range are iterated over, without the namespace
+	iterated := make(map[string]string)
+	err := usld.IterateRange([]byte("a2"), []byte("c"), func(key, value []byte) error {
+		iterated[string(key)] = string(value)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a2": "value a2", "b1": "value b1"}, iterated)
+}
+
+func TestBatchWriter_WriteBatch(t *testing.T) {
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
+	bw := NewBatchWriter(kvdb)
+	usld, asl := NewUploadSLD(kvdb), NewAuditSL(kvdb)
+	assert.Nil(t, usld.Store([]byte("key1"), []byte("value1")))
+
+	// check stores and deletes across namespaces are all applied
+	batch := &Batch{}
+	batch.Store(Audit, []byte("key2"), []byte("value2"))
+	batch.Delete(Uploads, []byte("key1"))
+	assert.Nil(t, bw.WriteBatch(batch))
+	loaded, err := asl.Load([]byte("key2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value2"), loaded)
+	loaded, err = usld.Load([]byte("key1"))
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+
+	// check invalid key applies nothing
+	batch = &Batch{}
+	batch.Store(Audit, []byte("key3"), []byte("value3"))
+	batch.Delete(Audit, bytes.Repeat([]byte{0}, MaxNamespaceKeyLength+1))
+	assert.NotNil(t, bw.WriteBatch(batch))
+	loaded, err = asl.Load([]byte("key3"))
+	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestAuditStorerLoader_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	asl := NewAuditSL(kvdb)
+	csl := NewClientSL(kvdb)
+
+	key, value := cid.NewPseudoRandom(rng).Bytes(), []byte("test value")
+	err := asl.Store(key, value)
+	assert.Nil(t, err)
+
+	loaded, err := asl.Load(key)
+	assert.Nil(t, err)
+	assert.Equal(t, value, loaded)
+
+	// check value isn't visible in client namespace
+	loaded, err = csl.Load(key)
 	assert.Nil(t, err)
+	assert.Nil(t, loaded)
+
+	// check value is iterated over in audit namespace
+	nIterated := 0
+	err = asl.Iterate(nil, func(k, v []byte) error {
+		assert.Equal(t, key, k)
+		assert.Equal(t, value, v)
+		nIterated++
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, nIterated)
+}
+
+func TestDocumentNamespaceStorerLoader_StoreLoad_ok(t *testing.T) {
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
 	dsl := NewDocumentSLD(kvdb)
 
 	rng := rand.New(rand.NewSource(0))
 	value1, key := api.NewTestDocument(rng)
 
-	err = dsl.Store(key, value1)
+	err := dsl.Store(key, value1)
 	assert.Nil(t, err)
 
 	value2, err := dsl.Load(key)
@@ -117,13 +256,43 @@ func TestDocumentNamespaceStorerLoader_StoreLoad_ok(t *testing.T) {
 	assert.Equal(t, value1, value2)
 }
 
+func TestDocumentIterator_Iterate(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	dsl, di := NewDocumentSLD(kvdb), NewDocumentIterator(kvdb)
+	csl := NewClientSL(kvdb)
+
+	stored := make(map[string]*api.Document)
+	for c := 0; c < 4; c++ {
+		value, key := api.NewTestDocument(rng)
+		assert.Nil(t, dsl.Store(key, value))
+		stored[key.String()] = value
+	}
+	assert.Nil(t, csl.Store(cid.NewPseudoRandom(rng).Bytes(), []byte("test value")))
+
+	// check all documents (and only documents) are iterated over
+	iterated := make(map[string]*api.Document)
+	err := di.Iterate(func(key cid.ID, value *api.Document) error {
+		iterated[key.String()] = value
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, stored, iterated)
+
+	// check fn error propagates up
+	errStop := errors.New("some fn error")
+	err = di.Iterate(func(key cid.ID, value *api.Document) error {
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+}
+
 func TestDocumentNamespaceStorerLoader_Store_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	dsl := NewDocumentSLD(kvdb)
 
 	// check invalid document returns error
@@ -141,6 +310,57 @@ func TestDocumentNamespaceStorerLoader_Store_err(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestDocumentNamespaceStorerLoader_StoreBatch_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	dsl := NewDocumentSLD(kvdb)
+	dbs := dsl.(DocumentBatchStorer)
+
+	keys, values := make([]cid.ID, 3), make([]*api.Document, 3)
+	for i := range keys {
+		values[i], keys[i] = api.NewTestDocument(rng)
+	}
+	err := dbs.StoreBatch(keys, values)
+	assert.Nil(t, err)
+
+	for i, key := range keys {
+		value, err := dsl.Load(key)
+		assert.Nil(t, err)
+		assert.Equal(t, values[i], value)
+	}
+}
+
+func TestDocumentNamespaceStorerLoader_StoreBatch_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	dsl := NewDocumentSLD(kvdb)
+	dbs := dsl.(DocumentBatchStorer)
+
+	// check mismatched keys & values returns error
+	value1, key1 := api.NewTestDocument(rng)
+	err := dbs.StoreBatch([]cid.ID{key1}, []*api.Document{})
+	assert.Equal(t, db.ErrBatchLengthMismatch, err)
+
+	// check bad key returns error and stores none of the batch
+	value2, _ := api.NewTestDocument(rng)
+	key2 := cid.NewPseudoRandom(rng)
+	err = dbs.StoreBatch([]cid.ID{key1, key2}, []*api.Document{value1, value2})
+	assert.NotNil(t, err)
+	stored, err := dsl.Load(key1)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+
+	// check inner store error propagates up
+	dsl2 := &documentSLD{
+		sld: &fixedNamespaceSLD{storeErr: errors.New("some store error")},
+		c:   NewHashKeyValueChecker(),
+	}
+	err = dsl2.StoreBatch([]cid.ID{key1}, []*api.Document{value1})
+	assert.NotNil(t, err)
+}
+
 func TestDocumentStorerLoader_Load_empty(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	key := cid.NewPseudoRandom(rng)
@@ -174,10 +394,8 @@ func TestDocumentStorerLoader_Load_checkErr(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	value, _ := api.NewTestDocument(rng)
 	key := cid.NewPseudoRandom(rng)
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	dsl := NewDocumentSLD(kvdb)
 
 	// hackily put a value with a non-hash key; should never happen in the wild
@@ -188,7 +406,30 @@ func TestDocumentStorerLoader_Load_checkErr(t *testing.T) {
 
 	// check Check error propagates up
 	_, err = dsl.Load(key)
-	assert.NotNil(t, err)
+	assert.Equal(t, ErrCorruptDocument, err)
+}
+
+func TestDocumentStorerLoader_Load_corruptErr(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	value, key := api.NewTestDocument(rng)
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	dsl := NewDocumentSLD(kvdb)
+	err := dsl.Store(key, value)
+	assert.Nil(t, err)
+
+	// simulate bit rot by flipping a byte of the stored value
+	nsKey := append(Documents, key.Bytes()...)
+	valueBytes, err := kvdb.Get(nsKey)
+	assert.Nil(t, err)
+	valueBytes[rng.Intn(len(valueBytes))] ^= 0xff
+	err = kvdb.Put(nsKey, valueBytes)
+	assert.Nil(t, err)
+
+	// check corruption is detected
+	loaded, err := dsl.Load(key)
+	assert.Equal(t, ErrCorruptDocument, err)
+	assert.Nil(t, loaded)
 }
 
 func TestDocumentStorerLoader_Load_validateDocumentErr(t *testing.T) {
@@ -196,10 +437,8 @@ func TestDocumentStorerLoader_Load_validateDocumentErr(t *testing.T) {
 	value, _ := api.NewTestDocument(rng)
 	value.Contents.(*api.Document_Entry).Entry.AuthorPublicKey = nil
 	key := cid.NewPseudoRandom(rng)
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	dsl := NewDocumentSLD(kvdb)
 
 	// hackily put a value with a non-hash key; should never happen in the wild
@@ -224,6 +463,10 @@ func (fsld *fixedSLD) Store(namespace []byte, key []byte, value []byte) error {
 	return fsld.storeErr
 }
 
+func (fsld *fixedSLD) StoreBatch(namespace []byte, keys [][]byte, values [][]byte) error {
+	return fsld.storeErr
+}
+
 func (fsld *fixedSLD) Load(namespace []byte, key []byte) ([]byte, error) {
 	return fsld.loadValue, fsld.loadErr
 }
@@ -232,6 +475,22 @@ func (fsld *fixedSLD) Delete(namespace []byte, key []byte) error {
 	return fsld.deleteErr
 }
 
+func (fsld *fixedSLD) Iterate(
+	namespace []byte, prefix []byte, fn func(key, value []byte) error,
+) error {
+	return nil
+}
+
+func (fsld *fixedSLD) IterateRange(
+	namespace []byte, start []byte, end []byte, fn func(key, value []byte) error,
+) error {
+	return nil
+}
+
+func (fsld *fixedSLD) WriteBatch(batch *Batch) error {
+	return fsld.storeErr
+}
+
 type fixedNamespaceSLD struct {
 	loadValue []byte
 	storeErr  error
@@ -243,6 +502,10 @@ func (f *fixedNamespaceSLD) Store(key []byte, value []byte) error {
 	return f.storeErr
 }
 
+func (f *fixedNamespaceSLD) StoreBatch(keys [][]byte, values [][]byte) error {
+	return f.storeErr
+}
+
 func (f *fixedNamespaceSLD) Load(key []byte) ([]byte, error) {
 	return f.loadValue, f.loadErr
 
@@ -252,3 +515,69 @@ func (f *fixedNamespaceSLD) Delete(key []byte) error {
 	return f.deleteErr
 }
 
+func (f *fixedNamespaceSLD) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return nil
+}
+
+func (f *fixedNamespaceSLD) IterateRange(
+	start []byte, end []byte, fn func(key, value []byte) error,
+) error {
+	return nil
+}
+
+func TestNamespaceSLD_DocumentSLD_backends(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, backend := range []string{db.RocksDBBackend, db.BadgerDBBackend, db.MemoryDBBackend} {
+		kvdb, cleanup, err := db.NewTempDirKVDB(backend)
+		assert.Nil(t, err, backend)
+		usld := NewUploadSLD(kvdb)
+		dsld := NewDocumentSLD(kvdb)
+		docIter := NewDocumentIterator(kvdb)
+
+		// check namespace store, load, iterate, & delete work the same against each backend
+		key, value := cid.NewPseudoRandom(rng).Bytes(), []byte("some value")
+		err = usld.Store(key, value)
+		assert.Nil(t, err, backend)
+		loaded, err := usld.Load(key)
+		assert.Nil(t, err, backend)
+		assert.Equal(t, value, loaded, backend)
+		nIterated := 0
+		err = usld.Iterate(nil, func(iterKey, iterValue []byte) error {
+			assert.Equal(t, key, iterKey, backend)
+			assert.Equal(t, value, iterValue, backend)
+			nIterated++
+			return nil
+		})
+		assert.Nil(t, err, backend)
+		assert.Equal(t, 1, nIterated, backend)
+		err = usld.Delete(key)
+		assert.Nil(t, err, backend)
+		loaded, err = usld.Load(key)
+		assert.Nil(t, err, backend)
+		assert.Nil(t, loaded, backend)
+
+		// check document store, load, iterate, & delete work the same against each backend
+		doc, docKey := api.NewTestDocument(rng)
+		err = dsld.Store(docKey, doc)
+		assert.Nil(t, err, backend)
+		loadedDoc, err := dsld.Load(docKey)
+		assert.Nil(t, err, backend)
+		assert.True(t, proto.Equal(doc, loadedDoc), backend)
+		nIterated = 0
+		err = docIter.Iterate(func(iterKey cid.ID, iterDoc *api.Document) error {
+			assert.Equal(t, docKey, iterKey, backend)
+			nIterated++
+			return nil
+		})
+		assert.Nil(t, err, backend)
+		assert.Equal(t, 1, nIterated, backend)
+		err = dsld.Delete(docKey)
+		assert.Nil(t, err, backend)
+		loadedDoc, err = dsld.Load(docKey)
+		assert.Nil(t, err, backend)
+		assert.Nil(t, loadedDoc, backend)
+
+		kvdb.Close()
+		cleanup()
+	}
+}
@@ -15,6 +15,13 @@ type Storer interface {
 	Store(namespace []byte, key []byte, value []byte) error
 }
 
+// BatchStorer atomically stores a batch of values to durable storage.
+type BatchStorer interface {
+	// StoreBatch stores the key-value pairs in a given namespace, so either all or none of them
+	// are stored.
+	StoreBatch(namespace []byte, keys [][]byte, values [][]byte) error
+}
+
 // Loader loads a value from durable storage.
 type Loader interface {
 	// Load a value for a given key and namespace.
@@ -27,16 +34,64 @@ type Deleter interface {
 	Delete(namespace []byte, key []byte) error
 }
 
+// Iterator iterates over values in durable storage.
+type Iterator interface {
+	// Iterate calls fn on each key-value pair in a given namespace whose key starts with the
+	// given prefix. The keys passed to fn exclude the namespace. Iteration stops early if fn
+	// returns an error, which is then returned.
+	Iterate(namespace []byte, prefix []byte, fn func(key, value []byte) error) error
+
+	// IterateRange is like Iterate but over the key-value pairs in a given namespace whose keys
+	// are from start (inclusive) to end (exclusive), seeking directly to start.
+	IterateRange(
+		namespace []byte, start []byte, end []byte, fn func(key, value []byte) error,
+	) error
+}
+
+// Batch collects values to store and delete across namespaces, which a BatchWriter then writes
+// atomically. The zero value is an empty batch.
+type Batch struct {
+	stores  []batchOp
+	deletes []batchOp
+}
+
+type batchOp struct {
+	namespace []byte
+	key       []byte
+	value     []byte
+}
+
+// Store adds storing the value for the key in the given namespace to the batch.
+func (b *Batch) Store(namespace []byte, key []byte, value []byte) {
+	b.stores = append(b.stores, batchOp{namespace: namespace, key: key, value: value})
+}
+
+// Delete adds deleting the value for the key in the given namespace to the batch.
+func (b *Batch) Delete(namespace []byte, key []byte) {
+	b.deletes = append(b.deletes, batchOp{namespace: namespace, key: key})
+}
+
+// BatchWriter atomically writes batches of changes to durable storage.
+type BatchWriter interface {
+	// WriteBatch applies the stores and deletes of the batch, so either all or none of them are
+	// applied.
+	WriteBatch(batch *Batch) error
+}
+
 // StorerLoader can both store and load values.
 type StorerLoader interface {
 	Storer
 	Loader
 }
 
-// StorerLoaderDeleter can store, load, and delete values.
+// StorerLoaderDeleter can store (individually or in batches), load, delete, and iterate over
+// values.
 type StorerLoaderDeleter interface {
 	StorerLoader
+	BatchStorer
 	Deleter
+	Iterator
+	BatchWriter
 }
 
 type kvdbSLD struct {
@@ -80,6 +135,26 @@ func (sld *kvdbSLD) Store(namespace []byte, key []byte, value []byte) error {
 	return sld.db.Put(namespaceKey(namespace, key), value)
 }
 
+func (sld *kvdbSLD) StoreBatch(namespace []byte, keys [][]byte, values [][]byte) error {
+	if err := sld.nc.Check(namespace); err != nil {
+		return err
+	}
+	if len(keys) != len(values) {
+		return db.ErrBatchLengthMismatch
+	}
+	nsKeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		if err := sld.kc.Check(key); err != nil {
+			return err
+		}
+		if err := sld.vc.Check(values[i]); err != nil {
+			return err
+		}
+		nsKeys[i] = namespaceKey(namespace, key)
+	}
+	return sld.db.PutBatch(nsKeys, values)
+}
+
 func (sld *kvdbSLD) Load(namespace []byte, key []byte) ([]byte, error) {
 	if err := sld.nc.Check(namespace); err != nil {
 		return nil, err
@@ -100,6 +175,63 @@ func (sld *kvdbSLD) Delete(namespace []byte, key []byte) error {
 	return sld.db.Delete(namespaceKey(namespace, key))
 }
 
+func (sld *kvdbSLD) Iterate(
+	namespace []byte, prefix []byte, fn func(key, value []byte) error,
+) error {
+	if err := sld.nc.Check(namespace); err != nil {
+		return err
+	}
+	return sld.db.Iterate(namespaceKey(namespace, prefix), func(key, value []byte) error {
+		return fn(key[len(namespace):], value)
+	})
+}
+
+func (sld *kvdbSLD) IterateRange(
+	namespace []byte, start []byte, end []byte, fn func(key, value []byte) error,
+) error {
+	if err := sld.nc.Check(namespace); err != nil {
+		return err
+	}
+	nsStart, nsEnd := namespaceKey(namespace, start), namespaceKey(namespace, end)
+	return sld.db.IterateRange(nsStart, nsEnd, func(key, value []byte) error {
+		return fn(key[len(namespace):], value)
+	})
+}
+
+func (sld *kvdbSLD) WriteBatch(batch *Batch) error {
+	keys := make([][]byte, len(batch.stores))
+	values := make([][]byte, len(batch.stores))
+	for i, op := range batch.stores {
+		if err := sld.check(op); err != nil {
+			return err
+		}
+		if err := sld.vc.Check(op.value); err != nil {
+			return err
+		}
+		keys[i], values[i] = namespaceKey(op.namespace, op.key), op.value
+	}
+	deleteKeys := make([][]byte, len(batch.deletes))
+	for i, op := range batch.deletes {
+		if err := sld.check(op); err != nil {
+			return err
+		}
+		deleteKeys[i] = namespaceKey(op.namespace, op.key)
+	}
+	return sld.db.WriteBatch(keys, values, deleteKeys)
+}
+
+// check checks the namespace and key of a batch operation.
+func (sld *kvdbSLD) check(op batchOp) error {
+	if err := sld.nc.Check(op.namespace); err != nil {
+		return err
+	}
+	return sld.kc.Check(op.key)
+}
+
+// namespaceKey returns a new slice with the key prefixed by the namespace, which never shares
+// the namespace's underlying array, so keys built in the same batch or range don't clobber each
+// other.
 func namespaceKey(namespace []byte, key []byte) []byte {
-	return append(namespace, key...)
+	nsKey := make([]byte, 0, len(namespace)+len(key))
+	return append(append(nsKey, namespace...), key...)
 }
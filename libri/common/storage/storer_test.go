@@ -23,10 +23,8 @@ func TestKvdbSLD_StoreLoadDelete(t *testing.T) {
 		{[]byte("test namespace"), cid.NewPseudoRandom(rng).Bytes(), []byte("test value")},
 	}
 
-	kvdb, cleanup, err := db.NewTempDirRocksDB()
-	defer cleanup()
+	kvdb := db.NewMemoryDB()
 	defer kvdb.Close()
-	assert.Nil(t, err)
 	sld := NewKVDBStorerLoaderDeleter(kvdb, NewMaxLengthChecker(256), NewMaxLengthChecker(1024))
 
 	for _, c := range cases {
@@ -41,3 +39,40 @@ func TestKvdbSLD_StoreLoadDelete(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+func TestKvdbSLD_StoreBatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	kvdb := db.NewMemoryDB()
+	defer kvdb.Close()
+	sld := NewKVDBStorerLoaderDeleter(kvdb, NewMaxLengthChecker(256), NewMaxLengthChecker(1024))
+	ns := []byte("ns")
+
+	keys := [][]byte{cid.NewPseudoRandom(rng).Bytes(), cid.NewPseudoRandom(rng).Bytes()}
+	values := [][]byte{[]byte("value 1"), []byte("value 2")}
+	err := sld.StoreBatch(ns, keys, values)
+	assert.Nil(t, err)
+	for i, key := range keys {
+		loaded, err2 := sld.Load(ns, key)
+		assert.Nil(t, err2)
+		assert.Equal(t, values[i], loaded)
+	}
+
+	// check bad namespace returns error
+	err = sld.StoreBatch(nil, keys, values)
+	assert.NotNil(t, err)
+
+	// check mismatched keys & values returns error
+	err = sld.StoreBatch(ns, keys, values[:1])
+	assert.Equal(t, db.ErrBatchLengthMismatch, err)
+
+	// check a bad value returns error and stores none of the batch
+	keys = [][]byte{cid.NewPseudoRandom(rng).Bytes(), cid.NewPseudoRandom(rng).Bytes()}
+	values = [][]byte{[]byte("value 3"), bytes.Repeat([]byte{0}, 1025)}
+	err = sld.StoreBatch(ns, keys, values)
+	assert.NotNil(t, err)
+	for _, key := range keys {
+		loaded, err2 := sld.Load(ns, key)
+		assert.Nil(t, err2)
+		assert.Nil(t, loaded)
+	}
+}
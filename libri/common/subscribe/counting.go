@@ -0,0 +1,159 @@
+package subscribe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/willf/bitset"
+	"github.com/willf/bloom"
+)
+
+// countingFilterPrefix prefixes encoded CountingFilters to distinguish them from encoded
+// bloom.BloomFilters, which start with a (much smaller) big-endian uint64 number of bits.
+var countingFilterPrefix = []byte("libri:cbf")
+
+// ErrNotCountingFilter indicates when encoded bytes are not a CountingFilter.
+var ErrNotCountingFilter = errors.New("encoded bytes are not a counting filter")
+
+// CountingFilter is a counting Bloom filter, which replaces each of the m bits of a standard Bloom
+// filter with a counter so that elements can be removed as well as added. This makes incremental
+// subscription changes possible without rebuilding the filter, at the cost of 8x the memory (and
+// encoded size) of a bloom.BloomFilter with the same m and k, since each counter is a byte. Each
+// counter saturates at 255, after which it is never decremented.
+//
+// A CountingFilter uses the same hash locations as a bloom.BloomFilter, so it can be converted to
+// an equivalent one via BloomFilter.
+type CountingFilter struct {
+	m      uint
+	k      uint
+	counts []uint8
+}
+
+// NewCountingFilter creates a new CountingFilter with m counters and k hash functions.
+func NewCountingFilter(m uint, k uint) *CountingFilter {
+	if m < 1 {
+		m = 1
+	}
+	if k < 1 {
+		k = 1
+	}
+	return &CountingFilter{m: m, k: k, counts: make([]uint8, m)}
+}
+
+// NewCountingFilterWithEstimates creates a new CountingFilter for about n elements with the given
+// false positive rate.
+func NewCountingFilterWithEstimates(n uint, fp float64) *CountingFilter {
+	m, k := bloom.EstimateParameters(n, fp)
+	return NewCountingFilter(m, k)
+}
+
+// Cap returns the number of counters, m.
+func (f *CountingFilter) Cap() uint {
+	return f.m
+}
+
+// K returns the number of hash functions.
+func (f *CountingFilter) K() uint {
+	return f.k
+}
+
+// Add adds an element to the filter.
+func (f *CountingFilter) Add(data []byte) *CountingFilter {
+	for _, loc := range f.locations(data) {
+		if f.counts[loc] < math.MaxUint8 {
+			f.counts[loc]++
+		}
+	}
+	return f
+}
+
+// Remove removes an element previously added to the filter. Removing an element that was never
+// added may remove others (i.e., cause false negatives), though elements that are definitely not
+// in the filter are ignored.
+func (f *CountingFilter) Remove(data []byte) *CountingFilter {
+	locs := f.locations(data)
+	for _, loc := range locs {
+		if f.counts[loc] == 0 {
+			return f
+		}
+	}
+	for _, loc := range locs {
+		if f.counts[loc] < math.MaxUint8 {
+			f.counts[loc]--
+		}
+	}
+	return f
+}
+
+// Test returns whether the element may be in the filter.
+func (f *CountingFilter) Test(data []byte) bool {
+	for _, loc := range f.locations(data) {
+		if f.counts[loc] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BloomFilter returns the equivalent standard bloom.BloomFilter, with a bit set for each non-zero
+// counter.
+func (f *CountingFilter) BloomFilter() *bloom.BloomFilter {
+	bits := bitset.New(f.m)
+	for loc, count := range f.counts {
+		if count > 0 {
+			bits.Set(uint(loc))
+		}
+	}
+	decoded, err := newBloomFilter(f.m, f.k, bits)
+	if err != nil {
+		// should never happen since we're writing to an in-memory buffer
+		panic(err)
+	}
+	return decoded
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (f *CountingFilter) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(countingFilterPrefix)
+	mk := [2]uint64{uint64(f.m), uint64(f.k)}
+	if err := binary.Write(&buf, binary.BigEndian, mk); err != nil {
+		return nil, err
+	}
+	buf.Write(f.counts)
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (f *CountingFilter) GobDecode(data []byte) error {
+	if !isCountingFilter(data) {
+		return ErrNotCountingFilter
+	}
+	buf := bytes.NewBuffer(data[len(countingFilterPrefix):])
+	var mk [2]uint64
+	if err := binary.Read(buf, binary.BigEndian, &mk); err != nil {
+		return err
+	}
+	if mk[0] < 1 || mk[1] < 1 || uint64(buf.Len()) != mk[0] {
+		return ErrNotCountingFilter
+	}
+	f.m, f.k = uint(mk[0]), uint(mk[1])
+	f.counts = make([]uint8, f.m)
+	copy(f.counts, buf.Bytes())
+	return nil
+}
+
+func (f *CountingFilter) locations(data []byte) []uint {
+	hashes := bloom.Locations(data, f.k)
+	locs := make([]uint, len(hashes))
+	for i, h := range hashes {
+		locs[i] = uint(h % uint64(f.m))
+	}
+	return locs
+}
+
+func isCountingFilter(encoded []byte) bool {
+	return bytes.HasPrefix(encoded, countingFilterPrefix)
+}
@@ -0,0 +1,121 @@
+package subscribe
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/willf/bloom"
+)
+
+func TestCountingFilter_AddRemoveTest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	n, fp := uint(64), 0.01
+	f := NewCountingFilterWithEstimates(n, fp)
+	elements := make([][]byte, n)
+	for i := range elements {
+		elements[i] = api.RandBytes(rng, api.ECPubKeyLength)
+		f.Add(elements[i])
+	}
+
+	// check all added elements are in the filter
+	for _, e := range elements {
+		assert.True(t, f.Test(e))
+	}
+
+	// check removed elements are no longer in the filter (w/ high probability) while others remain
+	removed := elements[:n/2]
+	for _, e := range removed {
+		f.Remove(e)
+	}
+	nFalsePositives := 0
+	for _, e := range removed {
+		if f.Test(e) {
+			nFalsePositives++
+		}
+	}
+	assert.True(t, nFalsePositives <= 2)
+	for _, e := range elements[n/2:] {
+		assert.True(t, f.Test(e))
+	}
+
+	// check removing an element not in the filter doesn't affect others
+	f.Remove(api.RandBytes(rng, api.ECPubKeyLength))
+	for _, e := range elements[n/2:] {
+		assert.True(t, f.Test(e))
+	}
+}
+
+func TestCountingFilter_saturated(t *testing.T) {
+	f := NewCountingFilter(8, 2)
+	e := []byte{1, 2, 3}
+	for c := 0; c < 300; c++ {
+		f.Add(e)
+	}
+
+	// check saturated counters are never decremented
+	for c := 0; c < 300; c++ {
+		f.Remove(e)
+	}
+	assert.True(t, f.Test(e))
+}
+
+func TestCountingFilter_BloomFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	cf := NewCountingFilterWithEstimates(32, 0.01)
+	bf := bloom.New(cf.Cap(), cf.K())
+	for c := 0; c < 32; c++ {
+		e := api.RandBytes(rng, api.ECPubKeyLength)
+		cf.Add(e)
+		bf.Add(e)
+	}
+
+	// check converted filter has same bits as one with the same elements added directly
+	assert.True(t, bf.Equal(cf.BloomFilter()))
+}
+
+func TestCountingFilter_GobEncodeDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	f1 := NewCountingFilterWithEstimates(32, 0.01)
+	for c := 0; c < 32; c++ {
+		f1.Add(api.RandBytes(rng, api.ECPubKeyLength))
+	}
+	encoded, err := f1.GobEncode()
+	assert.Nil(t, err)
+
+	// check encoded size is several times that of the equivalent bloom filter
+	bfEncoded, err := f1.BloomFilter().GobEncode()
+	assert.Nil(t, err)
+	assert.True(t, len(encoded) > 4*len(bfEncoded))
+
+	f2 := &CountingFilter{}
+	err = f2.GobDecode(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, f1, f2)
+
+	// check bloom filter encoding and truncated encodings aren't decoded
+	assert.Equal(t, ErrNotCountingFilter, f2.GobDecode(bfEncoded))
+	assert.Equal(t, ErrNotCountingFilter, f2.GobDecode(encoded[:len(encoded)-1]))
+	assert.NotNil(t, f2.GobDecode(encoded[:len(countingFilterPrefix)+4]))
+}
+
+func TestFromAPI_countingFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	cf := NewCountingFilterWithEstimates(32, 0.01)
+	e1, e2 := api.RandBytes(rng, api.ECPubKeyLength), api.RandBytes(rng, api.ECPubKeyLength)
+	cf.Add(e1).Add(e2).Remove(e2)
+
+	a, err := ToAPI(cf)
+	assert.Nil(t, err)
+	bf, err := FromAPI(a)
+	assert.Nil(t, err)
+	assert.True(t, cf.BloomFilter().Equal(bf))
+	assert.True(t, bf.Test(e1))
+
+	// check corrupted counting filter errors
+	a.Encoded = a.Encoded[:len(a.Encoded)-1]
+	bf, err = FromAPI(a)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+}
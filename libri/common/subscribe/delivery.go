@@ -0,0 +1,192 @@
+package subscribe
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeliveryPolicy is what to do with a publication for a subscriber whose buffer is full, i.e.,
+// who is reading publications more slowly than they are being published.
+type DeliveryPolicy int
+
+const (
+	// DeliverBlock waits for the subscriber to read from its buffer, which also holds up
+	// delivery to all other subscribers.
+	DeliverBlock DeliveryPolicy = iota
+
+	// DeliverDropOldest drops the oldest publication in the subscriber's buffer to make room for
+	// the new one.
+	DeliverDropOldest
+
+	// DeliverError drops the new publication and ends the subscription with
+	// ErrSubscriberTooSlow.
+	DeliverError
+)
+
+var (
+	// ErrSubscriberTooSlow indicates when a subscription with the DeliverError policy was ended
+	// because its buffer was full.
+	ErrSubscriberTooSlow = errors.New("subscriber buffer full")
+
+	// ErrZeroDeliveryBuffer indicates when a non-blocking delivery policy is given a zero
+	// buffer size.
+	ErrZeroDeliveryBuffer = errors.New("zero delivery buffer size with non-blocking policy")
+
+	// ErrUnknownDeliveryPolicy indicates when a delivery policy is not one of the defined ones.
+	ErrUnknownDeliveryPolicy = errors.New("unknown delivery policy")
+)
+
+// Metrics holds the Prometheus metrics of a From's deliveries, so each librarian in a process can
+// record its subscriptions separately.
+type Metrics struct {
+	droppedPubs *prometheus.CounterVec
+}
+
+// NewMetrics creates a new *Metrics instance.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		droppedPubs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "libri",
+			Subsystem: "subscribe",
+			Name:      "dropped_publications_total",
+			Help:      "Number of publications dropped because a subscriber's buffer was full.",
+		}, []string{"policy"}),
+	}
+}
+
+// Register registers the subscribe metrics with the given registerer.
+func (m *Metrics) Register(r prometheus.Registerer) {
+	r.MustRegister(m.droppedPubs)
+}
+
+// observeDrop records a publication dropped under the given policy. It is a no-op for nil
+// metrics.
+func (m *Metrics) observeDrop(policy DeliveryPolicy) {
+	if m == nil {
+		return
+	}
+	m.droppedPubs.WithLabelValues(policy.String()).Inc()
+}
+
+// String returns the name of the delivery policy.
+func (p DeliveryPolicy) String() string {
+	switch p {
+	case DeliverBlock:
+		return "block"
+	case DeliverDropOldest:
+		return "drop_oldest"
+	case DeliverError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// DeliveryParameters define how publications are delivered to a subscriber.
+type DeliveryParameters struct {
+	// BufferSize is the maximum number of publications waiting to be read by the subscriber.
+	BufferSize uint32
+
+	// Policy is what to do with a new publication when the buffer is full.
+	Policy DeliveryPolicy
+}
+
+// NewDefaultDeliveryParameters returns a *DeliveryParameters object with default values, which
+// block on a small buffer.
+func NewDefaultDeliveryParameters() *DeliveryParameters {
+	return &DeliveryParameters{
+		BufferSize: fanSlack,
+		Policy:     DeliverBlock,
+	}
+}
+
+func (p *DeliveryParameters) validate() error {
+	switch p.Policy {
+	case DeliverBlock:
+		return nil
+	case DeliverDropOldest, DeliverError:
+		if p.BufferSize == 0 {
+			return ErrZeroDeliveryBuffer
+		}
+		return nil
+	default:
+		return ErrUnknownDeliveryPolicy
+	}
+}
+
+// Delivery is a subscriber's view of its subscription. Publications are read from Pubs, which is
+// closed when the subscription ends, and the subscriber closes Done to end the subscription
+// itself.
+type Delivery struct {
+	// Pubs is the subscriber's buffer of publications.
+	Pubs chan *KeyedPub
+
+	// Done is closed to signal the subscriber is finished with the subscription.
+	Done chan struct{}
+
+	params   *DeliveryParameters
+	metrics  *Metrics
+	err      error
+	nDropped uint64
+}
+
+func newDelivery(params *DeliveryParameters) *Delivery {
+	return &Delivery{
+		Pubs:   make(chan *KeyedPub, params.BufferSize),
+		Done:   make(chan struct{}),
+		params: params,
+	}
+}
+
+// Err returns ErrSubscriberTooSlow if the subscription was ended because the subscriber's buffer
+// was full and nil otherwise. It should only be called after Pubs is closed.
+func (d *Delivery) Err() error {
+	return d.err
+}
+
+// NDropped returns the number of publications dropped because the subscriber's buffer was full.
+func (d *Delivery) NDropped() uint64 {
+	return atomic.LoadUint64(&d.nDropped)
+}
+
+// deliver sends the publication to the subscriber according to the delivery policy, returning
+// whether the subscription should end.
+func (d *Delivery) deliver(pub *KeyedPub) bool {
+	if d.params.Policy == DeliverBlock {
+		select {
+		case <-d.Done:
+			return true
+		case d.Pubs <- pub:
+			return false
+		}
+	}
+	for {
+		select {
+		case <-d.Done:
+			return true
+		case d.Pubs <- pub:
+			return false
+		default:
+		}
+		if d.params.Policy == DeliverError {
+			d.drop()
+			d.err = ErrSubscriberTooSlow
+			return true
+		}
+
+		// the subscriber may have read from the buffer in the meantime, in which case there's
+		// now room for the publication without dropping any
+		select {
+		case <-d.Pubs:
+			d.drop()
+		default:
+		}
+	}
+}
+
+func (d *Delivery) drop() {
+	atomic.AddUint64(&d.nDropped, 1)
+	d.metrics.observeDrop(d.params.Policy)
+}
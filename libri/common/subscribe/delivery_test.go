@@ -0,0 +1,140 @@
+package subscribe
+
+import (
+	"math/rand"
+	"testing"
+
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryParameters_validate(t *testing.T) {
+	cases := []struct {
+		params   *DeliveryParameters
+		expected error
+	}{
+		{NewDefaultDeliveryParameters(), nil},
+		{&DeliveryParameters{BufferSize: 0, Policy: DeliverBlock}, nil},
+		{&DeliveryParameters{BufferSize: 1, Policy: DeliverDropOldest}, nil},
+		{&DeliveryParameters{BufferSize: 1, Policy: DeliverError}, nil},
+		{&DeliveryParameters{BufferSize: 0, Policy: DeliverDropOldest}, ErrZeroDeliveryBuffer},
+		{&DeliveryParameters{BufferSize: 0, Policy: DeliverError}, ErrZeroDeliveryBuffer},
+		{&DeliveryParameters{BufferSize: 1, Policy: DeliveryPolicy(-1)}, ErrUnknownDeliveryPolicy},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.expected, c.params.validate(), "case %d", i)
+	}
+}
+
+func TestDeliveryPolicy_String(t *testing.T) {
+	assert.Equal(t, "block", DeliverBlock.String())
+	assert.Equal(t, "drop_oldest", DeliverDropOldest.String())
+	assert.Equal(t, "error", DeliverError.String())
+	assert.Equal(t, "unknown", DeliveryPolicy(-1).String())
+}
+
+func TestDelivery_deliver_block(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	d := newDelivery(&DeliveryParameters{BufferSize: 1, Policy: DeliverBlock})
+	pub1 := newKeyedPub(t, api.NewTestPublication(rng))
+	pub2 := newKeyedPub(t, api.NewTestPublication(rng))
+
+	assert.False(t, d.deliver(pub1))
+
+	// check full buffer blocks until subscriber is done
+	ended := make(chan bool)
+	go func() { ended <- d.deliver(pub2) }()
+	close(d.Done)
+	assert.True(t, <-ended)
+	assert.Equal(t, pub1, <-d.Pubs)
+	assert.Zero(t, d.NDropped())
+	assert.Nil(t, d.Err())
+}
+
+func TestDelivery_deliver_dropOldest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	bufferSize := 3
+	d := newDelivery(&DeliveryParameters{
+		BufferSize: uint32(bufferSize),
+		Policy:     DeliverDropOldest,
+	})
+	pubs := make([]*KeyedPub, bufferSize*2)
+	for i := range pubs {
+		pubs[i] = newKeyedPub(t, api.NewTestPublication(rng))
+		assert.False(t, d.deliver(pubs[i]))
+	}
+
+	// check only newest pubs remain, in order
+	assert.Equal(t, uint64(bufferSize), d.NDropped())
+	for _, expected := range pubs[bufferSize:] {
+		assert.Equal(t, expected, <-d.Pubs)
+	}
+	assert.Nil(t, d.Err())
+
+	// check ends when subscriber is done
+	close(d.Done)
+	assert.True(t, d.deliver(pubs[0]))
+}
+
+func TestDelivery_deliver_error(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	d := newDelivery(&DeliveryParameters{BufferSize: 1, Policy: DeliverError})
+	pub1 := newKeyedPub(t, api.NewTestPublication(rng))
+	pub2 := newKeyedPub(t, api.NewTestPublication(rng))
+
+	assert.False(t, d.deliver(pub1))
+	assert.Nil(t, d.Err())
+
+	// check full buffer ends subscription, keeping buffered pub
+	assert.True(t, d.deliver(pub2))
+	assert.Equal(t, ErrSubscriberTooSlow, d.Err())
+	assert.Equal(t, uint64(1), d.NDropped())
+	assert.Equal(t, pub1, <-d.Pubs)
+}
+
+func TestFrom_NewWithDelivery(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	params := NewDefaultFromParameters()
+	params.EndSubscriptionProb = 0.0 // never end
+	out := make(chan *KeyedPub)
+	lg := clogging.NewDevInfoLogger()
+	f := NewFrom(params, lg, out).(*from)
+	go f.Fanout()
+
+	// check bad delivery params error
+	d, err := f.NewWithDelivery(&DeliveryParameters{Policy: DeliverError})
+	assert.Equal(t, ErrZeroDeliveryBuffer, err)
+	assert.Nil(t, d)
+
+	slow, err := f.NewWithDelivery(&DeliveryParameters{BufferSize: 1, Policy: DeliverError})
+	assert.Nil(t, err)
+	dropping, err := f.NewWithDelivery(&DeliveryParameters{
+		BufferSize: 1,
+		Policy:     DeliverDropOldest,
+	})
+	assert.Nil(t, err)
+
+	// check slow subscription is ended while the other keeps receiving newest pubs
+	var lastPub *KeyedPub
+	for i := 0; i < 3; i++ {
+		lastPub = newKeyedPub(t, api.NewTestPublication(rng))
+		out <- lastPub
+	}
+	close(out)
+
+	nSlowPubs := 0
+	for range slow.Pubs {
+		nSlowPubs++
+	}
+	assert.Equal(t, 1, nSlowPubs)
+	assert.Equal(t, ErrSubscriberTooSlow, slow.Err())
+
+	var droppingPub *KeyedPub
+	for pub := range dropping.Pubs {
+		droppingPub = pub
+	}
+	assert.Equal(t, lastPub, droppingPub)
+	assert.Equal(t, uint64(2), dropping.NDropped())
+	assert.Nil(t, dropping.Err())
+}
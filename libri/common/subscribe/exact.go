@@ -0,0 +1,93 @@
+package subscribe
+
+import (
+	"sync/atomic"
+
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/willf/bloom"
+)
+
+// ExactFilter audits publications delivered for a subscription, re-checking those matching its
+// (bloom) filters against the exact author and reader public keys the subscriber has locally to
+// find false positives, which it may also suppress.
+type ExactFilter struct {
+	// Suppress indicates whether Keep rejects false positives, which are otherwise only counted.
+	Suppress bool
+
+	authorFilter *bloom.BloomFilter
+	readerFilter *bloom.BloomFilter
+	authorPubs   map[string]struct{}
+	readerPubs   map[string]struct{}
+	nFPs         uint64
+}
+
+// NewExactFilter creates a new *ExactFilter for the subscription with the exact author and reader
+// public keys its filters were created from. Nil public keys mean the subscriber doesn't have
+// the exact keys locally (e.g., when the subscription doesn't filter on them), in which case all
+// matches of that filter are assumed to be true positives.
+func NewExactFilter(sub *api.Subscription, authorPubs [][]byte, readerPubs [][]byte) (
+	*ExactFilter, error) {
+	if sub == nil || sub.AuthorPublicKeys == nil || sub.ReaderPublicKeys == nil {
+		return nil, ErrNilFilter
+	}
+	authorFilter, err := FromAPI(sub.AuthorPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	readerFilter, err := FromAPI(sub.ReaderPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &ExactFilter{
+		authorFilter: authorFilter,
+		readerFilter: readerFilter,
+		authorPubs:   newElementSet(authorPubs),
+		readerPubs:   newElementSet(readerPubs),
+	}, nil
+}
+
+// Audit returns whether the publication matches the subscription's filters and, if so, whether
+// that match is a false positive.
+func (f *ExactFilter) Audit(pub *api.Publication) (bool, bool) {
+	if !f.authorFilter.Test(pub.AuthorPublicKey) || !f.readerFilter.Test(pub.ReaderPublicKey) {
+		return false, false
+	}
+	fp := !inElementSet(f.authorPubs, pub.AuthorPublicKey) ||
+		!inElementSet(f.readerPubs, pub.ReaderPublicKey)
+	if fp {
+		atomic.AddUint64(&f.nFPs, 1)
+	}
+	return true, fp
+}
+
+// Keep returns whether the publication should be handed to the subscriber, i.e., whether it
+// matches the subscription's filters and, if suppressing false positives, isn't one.
+func (f *ExactFilter) Keep(pub *api.Publication) bool {
+	matches, fp := f.Audit(pub)
+	return matches && !(fp && f.Suppress)
+}
+
+// NFalsePositives returns the number of false positives found by Audit (and Keep).
+func (f *ExactFilter) NFalsePositives() uint64 {
+	return atomic.LoadUint64(&f.nFPs)
+}
+
+func newElementSet(elements [][]byte) map[string]struct{} {
+	if elements == nil {
+		return nil
+	}
+	set := make(map[string]struct{}, len(elements))
+	for _, e := range elements {
+		set[string(e)] = struct{}{}
+	}
+	return set
+}
+
+// inElementSet returns whether the element is in the set, which is always true for a nil set.
+func inElementSet(set map[string]struct{}, element []byte) bool {
+	if set == nil {
+		return true
+	}
+	_, in := set[string(element)]
+	return in
+}
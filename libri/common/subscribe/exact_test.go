@@ -0,0 +1,109 @@
+package subscribe
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExactFilter_falsePositive(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPubs := [][]byte{
+		api.RandBytes(rng, api.ECPubKeyLength),
+		api.RandBytes(rng, api.ECPubKeyLength),
+	}
+	sub, err := NewSubscription(authorPubs, 0.5, [][]byte{}, 1.0, rng)
+	assert.Nil(t, err)
+	f, err := NewExactFilter(sub, authorPubs, nil)
+	assert.Nil(t, err)
+
+	// find a publication from an author not subscribed to that still matches the filter
+	authorFilter, err := FromAPI(sub.AuthorPublicKeys)
+	assert.Nil(t, err)
+	var fpPub *api.Publication
+	for c := 0; c < 1000 && fpPub == nil; c++ {
+		pub := api.NewTestPublication(rng)
+		if authorFilter.Test(pub.AuthorPublicKey) {
+			fpPub = pub
+		}
+	}
+	assert.NotNil(t, fpPub)
+
+	// check false positive is kept but counted when not suppressing
+	matches, fp := f.Audit(fpPub)
+	assert.True(t, matches)
+	assert.True(t, fp)
+	assert.True(t, f.Keep(fpPub))
+	assert.Equal(t, uint64(2), f.NFalsePositives())
+
+	// check false positive is rejected when suppressing
+	f.Suppress = true
+	assert.False(t, f.Keep(fpPub))
+	assert.Equal(t, uint64(3), f.NFalsePositives())
+
+	// check true positive is kept when suppressing
+	tpPub := api.NewTestPublication(rng)
+	tpPub.AuthorPublicKey = authorPubs[1]
+	matches, fp = f.Audit(tpPub)
+	assert.True(t, matches)
+	assert.False(t, fp)
+	assert.True(t, f.Keep(tpPub))
+	assert.Equal(t, uint64(3), f.NFalsePositives())
+}
+
+func TestExactFilter_noMatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	readerPubs := [][]byte{api.RandBytes(rng, api.ECPubKeyLength)}
+	sub, err := NewSubscription([][]byte{}, 1.0, readerPubs, 1e-6, rng)
+	assert.Nil(t, err)
+	f, err := NewExactFilter(sub, nil, readerPubs)
+	assert.Nil(t, err)
+
+	// check publication to another reader neither matches nor is kept
+	pub := api.NewTestPublication(rng)
+	matches, fp := f.Audit(pub)
+	assert.False(t, matches)
+	assert.False(t, fp)
+	assert.False(t, f.Keep(pub))
+
+	// check publication to subscribed reader from any author is kept
+	pub.ReaderPublicKey = readerPubs[0]
+	matches, fp = f.Audit(pub)
+	assert.True(t, matches)
+	assert.False(t, fp)
+	assert.True(t, f.Keep(pub))
+	assert.Zero(t, f.NFalsePositives())
+}
+
+func TestNewExactFilter_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	sub, err := NewFPSubscription(0.5, rng)
+	assert.Nil(t, err)
+
+	f, err := NewExactFilter(nil, nil, nil)
+	assert.Equal(t, ErrNilFilter, err)
+	assert.Nil(t, f)
+
+	f, err = NewExactFilter(&api.Subscription{AuthorPublicKeys: sub.AuthorPublicKeys}, nil, nil)
+	assert.Equal(t, ErrNilFilter, err)
+	assert.Nil(t, f)
+
+	// check bad encoded filters error
+	badSub := &api.Subscription{
+		AuthorPublicKeys: &api.BloomFilter{Encoded: []byte{1, 2, 3}},
+		ReaderPublicKeys: sub.ReaderPublicKeys,
+	}
+	f, err = NewExactFilter(badSub, nil, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, f)
+
+	badSub = &api.Subscription{
+		AuthorPublicKeys: sub.AuthorPublicKeys,
+		ReaderPublicKeys: &api.BloomFilter{Encoded: []byte{1, 2, 3}},
+	}
+	f, err = NewExactFilter(badSub, nil, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, f)
+}
@@ -1,17 +1,57 @@
 package subscribe
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/gob"
+	"errors"
+	"math"
 	"math/rand"
 
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/willf/bitset"
 	"github.com/willf/bloom"
 )
 
-var minFilterElements = 10
+// Padding determines how filters with fewer than the minimum number of elements are padded.
+type Padding int
 
-// ToAPI converts a *bloom.BloomFilter (via narrower gob.GobEncoder) to an *api.BloomFilter.
+const (
+	// RandomPadding pads filters with random elements, which obscures the true number of
+	// elements in a filter.
+	RandomPadding Padding = iota
+
+	// DeterministicPadding pads filters with fixed sentinel elements, so filters of the same
+	// elements always have identical encodings, e.g., for reproducible tests or content-addressed
+	// filters. The true number of elements may be inferred from these filters.
+	DeterministicPadding
+)
+
+// DefaultMinFilterElements is the default minimum number of elements in a filter, below which it
+// is padded (c.f., Padding).
+const DefaultMinFilterElements = 10
+
+var (
+	// ErrZeroMinFilterElements indicates when the minimum number of filter elements is zero.
+	ErrZeroMinFilterElements = errors.New("minimum filter elements must be at least 1")
+
+	// ErrNoFilters indicates when a union is attempted over no filters.
+	ErrNoFilters = errors.New("no filters to union")
+
+	// ErrIncompatibleFilters indicates when filters with different numbers of bits (m) or hash
+	// functions (k) are unioned.
+	ErrIncompatibleFilters = errors.New("filters have different m or k parameters")
+)
+
+// ToAPI converts a *bloom.BloomFilter or *CountingFilter (via narrower gob.GobEncoder) to an
+// *api.BloomFilter. A *bloom.BloomFilter's bits are given explicitly (api.BloomFilterVersionBits)
+// so the filter doesn't depend on its Go gob encoding, while other filters are gob encoded
+// (api.BloomFilterVersionGob). Until all librarians read explicit bits, a *bloom.BloomFilter is
+// also gob encoded in Encoded, which librarians that only read the legacy encoding use instead.
 func ToAPI(f gob.GobEncoder) (*api.BloomFilter, error) {
+	if bf, ok := f.(*bloom.BloomFilter); ok {
+		return toAPIBits(bf)
+	}
 	encoded, err := f.GobEncode()
 	if err != nil {
 		// should never happen
@@ -22,22 +62,206 @@ func ToAPI(f gob.GobEncoder) (*api.BloomFilter, error) {
 	}, nil
 }
 
-// FromAPI converts an *api.BloomFilter to a *bloom.BloomFilter.
+// FromAPI converts an *api.BloomFilter to a *bloom.BloomFilter. Both explicit and (legacy) gob
+// encoded filters are converted, and encoded CountingFilters are converted to their equivalent
+// *bloom.BloomFilter.
 func FromAPI(f *api.BloomFilter) (*bloom.BloomFilter, error) {
+	switch f.Version {
+	case api.BloomFilterVersionGob:
+		return fromAPIGob(f.Encoded)
+	case api.BloomFilterVersionBits:
+		return fromAPIBits(f)
+	default:
+		return nil, api.ErrUnknownBloomFilterVersion
+	}
+}
+
+func toAPIBits(f *bloom.BloomFilter) (*api.BloomFilter, error) {
+	bits, err := filterBits(f)
+	if err != nil {
+		// should never happen since we're encoding to an in-memory buffer
+		return nil, err
+	}
+	encoded, err := f.GobEncode()
+	if err != nil {
+		// should never happen
+		return nil, err
+	}
+	m := f.Cap()
+	packed := make([]byte, (m+7)/8)
+	for i := uint(0); i < m; i++ {
+		if bits.Test(i) {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return &api.BloomFilter{
+		Encoded: encoded,
+		Version: api.BloomFilterVersionBits,
+		M:       uint64(m),
+		K:       uint32(f.K()),
+		Bits:    packed,
+	}, nil
+}
+
+func fromAPIBits(f *api.BloomFilter) (*bloom.BloomFilter, error) {
+	if err := api.ValidateBloomFilter(f); err != nil {
+		return nil, err
+	}
+	m := uint(f.M)
+	bits := bitset.New(m)
+	for i := uint(0); i < m; i++ {
+		if f.Bits[i/8]&(1<<(i%8)) != 0 {
+			bits.Set(i)
+		}
+	}
+	return newBloomFilter(m, uint(f.K), bits)
+}
+
+func fromAPIGob(encoded []byte) (*bloom.BloomFilter, error) {
+	if isCountingFilter(encoded) {
+		cf := &CountingFilter{}
+		if err := cf.GobDecode(encoded); err != nil {
+			return nil, err
+		}
+		return cf.BloomFilter(), nil
+	}
 	decoded := bloom.New(1, 1)
-	err := decoded.GobDecode(f.Encoded)
+	err := decoded.GobDecode(encoded)
 	if err != nil {
 		return nil, err
 	}
 	return decoded, nil
 }
 
-func newFilter(elements [][]byte, fp float64, rng *rand.Rand) *bloom.BloomFilter {
+// newBloomFilter creates a *bloom.BloomFilter with m bits, set as in the given bits, and k hash
+// functions, which it gets by decoding since bloom.BloomFilter doesn't expose its bits.
+func newBloomFilter(m uint, k uint, bits *bitset.BitSet) (*bloom.BloomFilter, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, [2]uint64{uint64(m), uint64(k)}); err != nil {
+		return nil, err
+	}
+	if _, err := bits.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	decoded := bloom.New(m, k)
+	if err := decoded.GobDecode(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// FromAPIMulti converts and unions multiple *api.BloomFilters into a single *bloom.BloomFilter.
+func FromAPIMulti(fs []*api.BloomFilter) (*bloom.BloomFilter, error) {
+	decoded := make([]*bloom.BloomFilter, len(fs))
+	for i, f := range fs {
+		var err error
+		if decoded[i], err = FromAPI(f); err != nil {
+			return nil, err
+		}
+	}
+	return Union(decoded...)
+}
+
+// Union returns a new filter containing the elements of all the given filters, which must have
+// the same number of bits (m) and hash functions (k). The given filters are not modified.
+func Union(filters ...*bloom.BloomFilter) (*bloom.BloomFilter, error) {
+	if len(filters) == 0 {
+		return nil, ErrNoFilters
+	}
+	m, k := filters[0].Cap(), filters[0].K()
+	union := bloom.New(m, k)
+	for _, f := range filters {
+		if f.Cap() != m || f.K() != k {
+			return nil, ErrIncompatibleFilters
+		}
+		if err := union.Merge(f); err != nil {
+			// should never happen since we've already checked m & k
+			return nil, err
+		}
+	}
+	return union, nil
+}
+
+// FilterStats returns the number of bits (m) and hash functions (k) of a filter along with its
+// estimated false positive rate, (X/m)^k, where X is the number of bits set. Unlike the rate
+// targeted when the filter was created, this reflects the actual number of elements added.
+func FilterStats(f *bloom.BloomFilter) (m, k uint, estimatedFP float64) {
+	m, k = f.Cap(), f.K()
+	nSet, err := nBitsSet(f)
+	if err != nil {
+		// should never happen since we're encoding to an in-memory buffer
+		panic(err)
+	}
+	return m, k, math.Pow(float64(nSet)/float64(m), float64(k))
+}
+
+// nBitsSet returns the number of bits set in the filter.
+func nBitsSet(f *bloom.BloomFilter) (uint, error) {
+	bits, err := filterBits(f)
+	if err != nil {
+		return 0, err
+	}
+	return bits.Count(), nil
+}
+
+// filterBits returns the bits of the filter, which it gets from the encoded filter since
+// bloom.BloomFilter doesn't expose them.
+func filterBits(f *bloom.BloomFilter) (*bitset.BitSet, error) {
+	encoded, err := f.GobEncode()
+	if err != nil {
+		return nil, err
+	}
+	bits := &bitset.BitSet{}
+	encodedBits := bytes.NewBuffer(encoded[16:]) // skip m and k uint64s
+	if _, err := bits.ReadFrom(encodedBits); err != nil {
+		return nil, err
+	}
+	return bits, nil
+}
+
+// FilterParameters define how the filters of a subscription are created.
+type FilterParameters struct {
+	// Padding determines the elements added to filters with fewer than MinElements elements.
+	Padding Padding
+
+	// MinElements is the minimum number of elements in a filter, including padding. Since the
+	// size of a filter is proportional to its number of elements, filters with fewer real
+	// elements than this all look alike. A higher minimum thus better obscures how many keys a
+	// subscription is for, at the cost of larger filters (about -1.44 * log2(fp) bits per
+	// element) and so larger Subscribe requests. Padding also changes the false positive math,
+	// since a filter's false positive rate is for MinElements elements rather than the (fewer)
+	// real ones.
+	MinElements uint32
+}
+
+// NewDefaultFilterParameters returns a *FilterParameters object with default values.
+func NewDefaultFilterParameters() *FilterParameters {
+	return &FilterParameters{
+		Padding:     RandomPadding,
+		MinElements: DefaultMinFilterElements,
+	}
+}
+
+// Validate returns an error if the filter parameters are invalid.
+func (p *FilterParameters) Validate() error {
+	if p.MinElements < 1 {
+		return ErrZeroMinFilterElements
+	}
+	return nil
+}
+
+func newFilter(
+	elements [][]byte, fp float64, params *FilterParameters, rng *rand.Rand,
+) *bloom.BloomFilter {
 	if fp == 1.0 {
 		return alwaysInFilter()
 	}
-	for len(elements) < minFilterElements {
-		elements = append(elements, api.RandBytes(rng, api.ECPubKeyLength))
+	for i := 0; uint32(len(elements)) < params.MinElements; i++ {
+		if params.Padding == DeterministicPadding {
+			elements = append(elements, sentinelElement(i))
+		} else {
+			elements = append(elements, api.RandBytes(rng, api.ECPubKeyLength))
+		}
 	}
 	filter := bloom.NewWithEstimates(uint(len(elements)), fp)
 	for _, e := range elements {
@@ -46,6 +270,14 @@ func newFilter(elements [][]byte, fp float64, rng *rand.Rand) *bloom.BloomFilter
 	return filter
 }
 
+// sentinelElement returns the ith deterministic padding element. Its zero first byte ensures it
+// never equals an uncompressed public key, which always starts with 0x04.
+func sentinelElement(i int) []byte {
+	sentinel := make([]byte, api.ECPubKeyLength)
+	binary.BigEndian.PutUint32(sentinel[1:], uint32(i))
+	return sentinel
+}
+
 func alwaysInFilter() *bloom.BloomFilter {
 	filter := bloom.New(1, 1)
 	filter.Add([]byte{1}) // could be anything
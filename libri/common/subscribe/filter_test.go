@@ -2,16 +2,18 @@ package subscribe
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"testing"
 
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"github.com/willf/bloom"
 )
 
 func TestToFromAPI(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
-	f1 := newFilter([][]byte{}, 0.75, rng)
+	f1 := newFilter([][]byte{}, 0.75, NewDefaultFilterParameters(), rng)
 	a, err := ToAPI(f1)
 	assert.Nil(t, err)
 	f2, err := FromAPI(a)
@@ -19,10 +21,188 @@ func TestToFromAPI(t *testing.T) {
 	assert.Equal(t, f1, f2)
 }
 
+func TestToAPI_bits(t *testing.T) {
+	f := bloom.New(10, 3)
+	f.Add([]byte("some element"))
+	bits, err := filterBits(f)
+	assert.Nil(t, err)
+
+	a, err := ToAPI(f)
+	assert.Nil(t, err)
+	assert.Equal(t, api.BloomFilterVersionBits, a.Version)
+	assert.Equal(t, uint64(10), a.M)
+	assert.Equal(t, uint32(3), a.K)
+	assert.Len(t, a.Bits, 2)
+
+	// check legacy encoding is also written for librarians that only read it
+	legacy, err := fromAPIGob(a.Encoded)
+	assert.Nil(t, err)
+	assert.True(t, f.Equal(legacy))
+
+	// check each bit i is bit i % 8 of byte i / 8
+	for i := uint(0); i < 10; i++ {
+		assert.Equal(t, bits.Test(i), a.Bits[i/8]&(1<<(i%8)) != 0)
+	}
+}
+
+func TestFromAPI_legacy(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	f1 := newFilter([][]byte{}, 0.75, NewDefaultFilterParameters(), rng)
+	encoded, err := f1.GobEncode()
+	assert.Nil(t, err)
+
+	// check legacy gob-encoded filter is still decoded
+	f2, err := FromAPI(&api.BloomFilter{Encoded: encoded})
+	assert.Nil(t, err)
+	assert.Equal(t, f1, f2)
+
+	// check same filter from both versions
+	a, err := ToAPI(f1)
+	assert.Nil(t, err)
+	f3, err := FromAPI(a)
+	assert.Nil(t, err)
+	assert.True(t, f2.Equal(f3))
+}
+
 func TestFromAPI_err(t *testing.T) {
 	f, err := FromAPI(&api.BloomFilter{Encoded: []byte{}})
 	assert.NotNil(t, err)
 	assert.Nil(t, f)
+
+	f, err = FromAPI(&api.BloomFilter{Version: 2})
+	assert.Equal(t, api.ErrUnknownBloomFilterVersion, err)
+	assert.Nil(t, f)
+
+	f, err = FromAPI(&api.BloomFilter{Version: api.BloomFilterVersionBits, M: 10, K: 3,
+		Bits: []byte{1}})
+	assert.Equal(t, api.ErrInvalidBloomFilterBits, err)
+	assert.Nil(t, f)
+}
+
+func TestUnion_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	n, fp := uint(16), 0.01
+	elements := make([][]byte, 3*n)
+	filters := make([]*bloom.BloomFilter, 3)
+	for i := range filters {
+		filters[i] = bloom.NewWithEstimates(n, fp)
+		for j := uint(0); j < n; j++ {
+			elements[uint(i)*n+j] = api.RandBytes(rng, api.ECPubKeyLength)
+			filters[i].Add(elements[uint(i)*n+j])
+		}
+	}
+	f1Copy := bloom.New(filters[0].Cap(), filters[0].K())
+	assert.Nil(t, f1Copy.Merge(filters[0]))
+
+	union, err := Union(filters...)
+	assert.Nil(t, err)
+
+	// check union contains elements from all filters
+	for _, e := range elements {
+		assert.True(t, union.Test(e))
+	}
+	assert.Equal(t, filters[0].Cap(), union.Cap())
+	assert.Equal(t, filters[0].K(), union.K())
+
+	// check given filters aren't modified
+	assert.Equal(t, f1Copy, filters[0])
+
+	// check union of a single filter equals that filter
+	union, err = Union(filters[0])
+	assert.Nil(t, err)
+	assert.Equal(t, filters[0], union)
+}
+
+func TestUnion_err(t *testing.T) {
+	// check no filters
+	union, err := Union()
+	assert.Equal(t, ErrNoFilters, err)
+	assert.Nil(t, union)
+
+	// check different m
+	union, err = Union(bloom.New(128, 4), bloom.New(256, 4))
+	assert.Equal(t, ErrIncompatibleFilters, err)
+	assert.Nil(t, union)
+
+	// check different k
+	union, err = Union(bloom.New(128, 4), bloom.New(128, 5))
+	assert.Equal(t, ErrIncompatibleFilters, err)
+	assert.Nil(t, union)
+}
+
+func TestFromAPIMulti_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	e1, e2 := api.RandBytes(rng, api.ECPubKeyLength), api.RandBytes(rng, api.ECPubKeyLength)
+	f1, f2 := bloom.NewWithEstimates(16, 0.01), bloom.NewWithEstimates(16, 0.01)
+	f1.Add(e1)
+	f2.Add(e2)
+	a1, err := ToAPI(f1)
+	assert.Nil(t, err)
+	a2, err := ToAPI(f2)
+	assert.Nil(t, err)
+
+	union, err := FromAPIMulti([]*api.BloomFilter{a1, a2})
+	assert.Nil(t, err)
+	assert.True(t, union.Test(e1))
+	assert.True(t, union.Test(e2))
+}
+
+func TestFromAPIMulti_err(t *testing.T) {
+	a1, err := ToAPI(bloom.New(128, 4))
+	assert.Nil(t, err)
+	a2, err := ToAPI(bloom.New(256, 4))
+	assert.Nil(t, err)
+
+	// check decode error bubbles up
+	union, err := FromAPIMulti([]*api.BloomFilter{a1, {Encoded: []byte{}}})
+	assert.NotNil(t, err)
+	assert.Nil(t, union)
+
+	// check parameter mismatch bubbles up
+	union, err = FromAPIMulti([]*api.BloomFilter{a1, a2})
+	assert.Equal(t, ErrIncompatibleFilters, err)
+	assert.Nil(t, union)
+
+	// check no filters
+	union, err = FromAPIMulti(nil)
+	assert.Equal(t, ErrNoFilters, err)
+	assert.Nil(t, union)
+}
+
+func TestFilterStats(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	m, k := uint(1024), uint(4)
+
+	// check empty filter has zero FP rate
+	f := bloom.New(m, k)
+	m1, k1, fp := FilterStats(f)
+	assert.Equal(t, m, m1)
+	assert.Equal(t, k, k1)
+	assert.Zero(t, fp)
+
+	for _, n := range []int{64, 128, 256} {
+		f = bloom.New(m, k)
+		for c := 0; c < n; c++ {
+			f.Add(api.RandBytes(rng, api.ECPubKeyLength))
+		}
+
+		// check estimate is close to theoretical (1 - e^(-kn/m))^k
+		expected := math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+		_, _, fp = FilterStats(f)
+		assert.InDelta(t, expected, fp, expected/2, fmt.Sprintf("n: %d", n))
+	}
+
+	// check FP rate grows above target as more elements are added than estimated for
+	f = bloom.NewWithEstimates(64, 0.01)
+	for c := 0; c < 256; c++ {
+		f.Add(api.RandBytes(rng, api.ECPubKeyLength))
+	}
+	_, _, fp = FilterStats(f)
+	assert.True(t, fp > 0.1)
+
+	// check always-in filter has FP rate of 1
+	_, _, fp = FilterStats(alwaysInFilter())
+	assert.Equal(t, 1.0, fp)
 }
 
 /*
@@ -59,7 +239,7 @@ func TestNewFPFilter(t *testing.T) {
 	// check that for the actual FP rate is >= target FP rate - tolerance
 	tolerance := 0.05
 	for _, targetFP := range []float64{0.3, 0.5, 0.75, 0.9, 1.0} {
-		filter := newFilter([][]byte{}, targetFP, rng)
+		filter := newFilter([][]byte{}, targetFP, NewDefaultFilterParameters(), rng)
 
 		// measure FP rate
 		fpCount := 0
@@ -75,6 +255,70 @@ func TestNewFPFilter(t *testing.T) {
 
 }
 
+func TestNewFilter_padding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	elements := [][]byte{
+		api.RandBytes(rng, api.ECPubKeyLength),
+		api.RandBytes(rng, api.ECPubKeyLength),
+	}
+
+	deterministic := &FilterParameters{
+		Padding:     DeterministicPadding,
+		MinElements: DefaultMinFilterElements,
+	}
+	random := NewDefaultFilterParameters()
+
+	// check deterministic padding gives identical encodings across runs
+	encoded := make([]*api.BloomFilter, 3)
+	for i := range encoded {
+		elementsCopy := append([][]byte{}, elements...)
+		f, err := ToAPI(newFilter(elementsCopy, 0.1, deterministic, nil))
+		assert.Nil(t, err)
+		encoded[i] = f
+	}
+	assert.Equal(t, encoded[0], encoded[1])
+	assert.Equal(t, encoded[0], encoded[2])
+
+	// check random padding doesn't
+	f1, err := ToAPI(newFilter(append([][]byte{}, elements...), 0.1, random, rng))
+	assert.Nil(t, err)
+	f2, err := ToAPI(newFilter(append([][]byte{}, elements...), 0.1, random, rng))
+	assert.Nil(t, err)
+	assert.NotEqual(t, f1, f2)
+
+	// check both contain the real elements
+	for _, f := range []*api.BloomFilter{encoded[0], f1} {
+		decoded, err := FromAPI(f)
+		assert.Nil(t, err)
+		for _, e := range elements {
+			assert.True(t, decoded.Test(e))
+		}
+	}
+}
+
+func TestNewFilter_minElements(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	elements := [][]byte{api.RandBytes(rng, api.ECPubKeyLength)}
+
+	// check higher minimum gives larger filters for the same elements
+	prevM := uint(0)
+	for _, minElements := range []uint32{1, 10, 100} {
+		params := &FilterParameters{Padding: RandomPadding, MinElements: minElements}
+		f := newFilter(append([][]byte{}, elements...), 0.1, params, rng)
+		expectedM, _ := bloom.EstimateParameters(uint(minElements), 0.1)
+		assert.Equal(t, expectedM, f.Cap())
+		assert.True(t, f.Cap() > prevM)
+		assert.True(t, f.Test(elements[0]))
+		prevM = f.Cap()
+	}
+}
+
+func TestFilterParameters_Validate(t *testing.T) {
+	assert.Nil(t, NewDefaultFilterParameters().Validate())
+	assert.Nil(t, (&FilterParameters{MinElements: 1}).Validate())
+	assert.Equal(t, ErrZeroMinFilterElements, (&FilterParameters{}).Validate())
+}
+
 func TestAlwaysInFilter(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	f := alwaysInFilter()
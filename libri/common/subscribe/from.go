@@ -46,33 +46,49 @@ type From interface {
 	// of each subscriber.
 	Fanout()
 
-	// New creates a new subscriber channel, adds it to the fan-out, and returns it. If
+	// New creates a new subscriber channel, adds it to the fan-out, and returns it along with
+	// the channel the subscriber closes when it's finished. Delivery blocks when the
+	// subscriber's small buffer is full.
 	New() (chan *KeyedPub, chan struct{}, error)
+
+	// NewWithDelivery is like New but with the subscriber's buffer size and the policy for
+	// when it's full given by the delivery parameters.
+	NewWithDelivery(params *DeliveryParameters) (*Delivery, error)
 }
 
 type from struct {
 	params       *FromParameters
 	logger       *zap.Logger
 	out          chan *KeyedPub
-	fanout       map[uint64]chan *KeyedPub
+	fanout       map[uint64]*Delivery
 	done         map[uint64]chan struct{}
 	nextFanIndex uint64
 	ender        ender
+	metrics      *Metrics
 	mu           sync.Mutex
 }
 
 // NewFrom creates a new From instance that fans out from the given output channel.
 func NewFrom(params *FromParameters, logger *zap.Logger, out chan *KeyedPub) From {
+	return NewFromWithMetrics(params, logger, out, nil)
+}
+
+// NewFromWithMetrics creates a new From instance like NewFrom that also records its deliveries in
+// the given metrics.
+func NewFromWithMetrics(
+	params *FromParameters, logger *zap.Logger, out chan *KeyedPub, m *Metrics,
+) From {
 	return &from{
 		params: params,
 		logger: logger,
 		out:    out,
-		fanout: make(map[uint64]chan *KeyedPub),
+		fanout: make(map[uint64]*Delivery),
 		done:   make(map[uint64]chan struct{}),
 		ender: &bernoulliEnder{
 			p:   params.EndSubscriptionProb,
 			rng: rand.New(rand.NewSource(0)),
 		},
+		metrics: m,
 	}
 }
 
@@ -83,11 +99,8 @@ func (f *from) Fanout() {
 				f.endSubscription(i)
 				continue
 			}
-			select {
-			// TODO (drausin) add timeout here for channel accepting value (?)
-			case <-f.done[i]:
+			if f.fanout[i].deliver(pub) {
 				f.endSubscription(i)
-			case f.fanout[i] <- pub:
 			}
 		}
 	}
@@ -98,17 +111,28 @@ func (f *from) Fanout() {
 }
 
 func (f *from) New() (chan *KeyedPub, chan struct{}, error) {
+	d, err := f.NewWithDelivery(NewDefaultDeliveryParameters())
+	if err != nil {
+		return nil, nil, err
+	}
+	return d.Pubs, d.Done, nil
+}
+
+func (f *from) NewWithDelivery(params *DeliveryParameters) (*Delivery, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if uint32(len(f.fanout)) == f.params.NMaxSubscriptions {
-		return nil, nil, ErrNotAcceptingNewSubscriptions
+		return nil, ErrNotAcceptingNewSubscriptions
 	}
-	out := make(chan *KeyedPub, fanSlack)
-	done := make(chan struct{})
-	f.fanout[f.nextFanIndex] = out
-	f.done[f.nextFanIndex] = done
+	d := newDelivery(params)
+	d.metrics = f.metrics
+	f.fanout[f.nextFanIndex] = d
+	f.done[f.nextFanIndex] = d.Done
 	f.nextFanIndex++
-	return out, done, nil
+	return d, nil
 }
 
 func (f *from) endSubscription(i uint64) {
@@ -119,7 +143,10 @@ func (f *from) endSubscription(i uint64) {
 		// close done[i] if it's not already closed
 		close(f.done[i])
 	}
-	close(f.fanout[i])
+	if err := f.fanout[i].Err(); err != nil {
+		f.logger.Debug("ending subscription", zap.Error(err))
+	}
+	close(f.fanout[i].Pubs)
 	delete(f.fanout, i)
 	delete(f.done, i)
 	f.mu.Unlock()
@@ -13,11 +13,15 @@ var (
 
 	// ErrNilPublicKeys indicates when either the author or reader public keys are nil.
 	ErrNilPublicKeys = errors.New("nil public keys")
+
+	// ErrNilFilter indicates when a subscription filter is nil.
+	ErrNilFilter = errors.New("nil filter")
 )
 
 // NewSubscription returns a new subscription with filters for the given author and reader public
 // keys and false positive rates. Users should recall that the overall subscription false positive
-// rate will be the product of the author and reader false positive rates.
+// rate will be the product of the author and reader false positive rates. The filters are padded
+// with random elements.
 func NewSubscription(
 	authorPubs [][]byte,
 	authorFp float64,
@@ -25,6 +29,36 @@ func NewSubscription(
 	readerFp float64,
 	rng *rand.Rand,
 ) (*api.Subscription, error) {
+	return NewSubscriptionWithPadding(authorPubs, authorFp, readerPubs, readerFp, RandomPadding,
+		rng)
+}
+
+// NewSubscriptionWithPadding returns a new subscription like NewSubscription but with the given
+// filter padding. The rng is unused with DeterministicPadding and may be nil.
+func NewSubscriptionWithPadding(
+	authorPubs [][]byte,
+	authorFp float64,
+	readerPubs [][]byte,
+	readerFp float64,
+	padding Padding,
+	rng *rand.Rand,
+) (*api.Subscription, error) {
+	params := NewDefaultFilterParameters()
+	params.Padding = padding
+	return NewSubscriptionWithParams(authorPubs, authorFp, readerPubs, readerFp, params, rng)
+}
+
+// NewSubscriptionWithParams returns a new subscription like NewSubscription but with filters
+// created according to the given parameters. The rng is unused with DeterministicPadding and may
+// be nil.
+func NewSubscriptionWithParams(
+	authorPubs [][]byte,
+	authorFp float64,
+	readerPubs [][]byte,
+	readerFp float64,
+	params *FilterParameters,
+	rng *rand.Rand,
+) (*api.Subscription, error) {
 
 	if readerFp <= 0.0 || readerFp > 1.0 || authorFp <= 0.0 || authorFp > 1.0 {
 		return nil, ErrOutOfBoundsFPRate
@@ -32,11 +66,14 @@ func NewSubscription(
 	if authorPubs == nil || readerPubs == nil {
 		return nil, ErrNilPublicKeys
 	}
-	authorFilter, err := ToAPI(newFilter(authorPubs, authorFp, rng))
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	authorFilter, err := ToAPI(newFilter(authorPubs, authorFp, params, rng))
 	if err != nil {
 		return nil, err
 	}
-	readerFilter, err := ToAPI(newFilter(readerPubs, readerFp, rng))
+	readerFilter, err := ToAPI(newFilter(readerPubs, readerFp, params, rng))
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +99,22 @@ func NewReaderSubscription(readerPubs [][]byte, fp float64, rng *rand.Rand) (
 	return NewSubscription([][]byte{}, 1.0, readerPubs, fp, rng)
 }
 
+// NewReaderFilterSubscription creates an *api.Subscription using the given (already built) reader
+// public key filter with a 1.0 false positive rate for author keys.
+func NewReaderFilterSubscription(readerFilter *api.BloomFilter) (*api.Subscription, error) {
+	if readerFilter == nil {
+		return nil, ErrNilFilter
+	}
+	authorFilter, err := ToAPI(alwaysInFilter())
+	if err != nil {
+		return nil, err
+	}
+	return &api.Subscription{
+		AuthorPublicKeys: authorFilter,
+		ReaderPublicKeys: readerFilter,
+	}, nil
+}
+
 // NewFPSubscription creates an *api.Subscription with the given false positive rate on the author
 // keys and a 1.0 false positive rate on the reader keys.
 func NewFPSubscription(fp float64, rng *rand.Rand) (*api.Subscription, error) {
@@ -5,7 +5,9 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"github.com/willf/bloom"
 )
 
 func TestNewSubscription_ok(t *testing.T) {
@@ -17,6 +19,47 @@ func TestNewSubscription_ok(t *testing.T) {
 	assert.NotNil(t, s.ReaderPublicKeys)
 }
 
+func TestNewSubscriptionWithPadding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPubs := [][]byte{api.RandBytes(rng, api.ECPubKeyLength)}
+	readerPubs := [][]byte{api.RandBytes(rng, api.ECPubKeyLength)}
+
+	// check deterministic padding gives byte-identical filters across runs
+	s1, err := NewSubscriptionWithPadding(authorPubs, 0.5, readerPubs, 0.5,
+		DeterministicPadding, nil)
+	assert.Nil(t, err)
+	s2, err := NewSubscriptionWithPadding(authorPubs, 0.5, readerPubs, 0.5,
+		DeterministicPadding, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, s1.AuthorPublicKeys.Bits, s2.AuthorPublicKeys.Bits)
+	assert.Equal(t, s1.ReaderPublicKeys.Bits, s2.ReaderPublicKeys.Bits)
+
+	// check random padding gives different filters
+	s3, err := NewSubscriptionWithPadding(authorPubs, 0.5, readerPubs, 0.5, RandomPadding, rng)
+	assert.Nil(t, err)
+	assert.NotEqual(t, s1.AuthorPublicKeys.Bits, s3.AuthorPublicKeys.Bits)
+}
+
+func TestNewSubscriptionWithParams(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	authorPubs := [][]byte{api.RandBytes(rng, api.ECPubKeyLength)}
+	readerPubs := [][]byte{api.RandBytes(rng, api.ECPubKeyLength)}
+
+	params := &FilterParameters{Padding: RandomPadding, MinElements: 100}
+	s, err := NewSubscriptionWithParams(authorPubs, 0.5, readerPubs, 0.5, params, rng)
+	assert.Nil(t, err)
+	authorFilter, err := FromAPI(s.AuthorPublicKeys)
+	assert.Nil(t, err)
+	expectedM, _ := bloom.EstimateParameters(100, 0.5)
+	assert.Equal(t, expectedM, authorFilter.Cap())
+
+	// check invalid params error
+	params.MinElements = 0
+	s, err = NewSubscriptionWithParams(authorPubs, 0.5, readerPubs, 0.5, params, rng)
+	assert.Equal(t, ErrZeroMinFilterElements, err)
+	assert.Nil(t, s)
+}
+
 func TestNewSubscription_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 
@@ -61,6 +104,26 @@ func TestNewReaderSubscription(t *testing.T) {
 	assert.NotNil(t, s.ReaderPublicKeys)
 }
 
+func TestNewReaderFilterSubscription(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	readerPub := api.RandBytes(rng, api.ECPubKeyLength)
+	readerFilter, err := ToAPI(newFilter([][]byte{readerPub}, 0.5, NewDefaultFilterParameters(),
+		rng))
+	assert.Nil(t, err)
+
+	s, err := NewReaderFilterSubscription(readerFilter)
+	assert.Nil(t, err)
+	assert.Equal(t, readerFilter, s.ReaderPublicKeys)
+	authorFilter, err := FromAPI(s.AuthorPublicKeys)
+	assert.Nil(t, err)
+	assert.True(t, authorFilter.Test(api.RandBytes(rng, api.ECPubKeyLength)))
+
+	// check nil filter triggers error
+	s, err = NewReaderFilterSubscription(nil)
+	assert.Equal(t, ErrNilFilter, err)
+	assert.Nil(t, s)
+}
+
 func TestNewFPSubscription(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	s, err := NewFPSubscription(0.5, rng)
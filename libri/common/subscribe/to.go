@@ -82,19 +82,33 @@ type ToParameters struct {
 	// RecentCacheSize is the size of the LRU cache used in deduplicating and grouping
 	// publications.
 	RecentCacheSize uint32
+
+	// MinFilterElements is the minimum number of elements in each subscription's (randomly
+	// padded) filters (c.f., FilterParameters.MinElements). Zero means
+	// DefaultMinFilterElements.
+	MinFilterElements uint32
 }
 
 // NewDefaultToParameters returns a *ToParameters object with default values.
 func NewDefaultToParameters() *ToParameters {
 	return &ToParameters{
-		NSubscriptions:  DefaultNSubscriptionsTo,
-		FPRate:          DefaultFPRate,
-		Timeout:         DefaultTimeout,
-		MaxErrRate:      DefaultMaxErrRate,
-		RecentCacheSize: DefaultRecentCacheSize,
+		NSubscriptions:    DefaultNSubscriptionsTo,
+		FPRate:            DefaultFPRate,
+		Timeout:           DefaultTimeout,
+		MaxErrRate:        DefaultMaxErrRate,
+		RecentCacheSize:   DefaultRecentCacheSize,
+		MinFilterElements: DefaultMinFilterElements,
 	}
 }
 
+// minFilterElements returns MinFilterElements, or DefaultMinFilterElements if it is zero.
+func (p *ToParameters) minFilterElements() uint32 {
+	if p.MinFilterElements == 0 {
+		return DefaultMinFilterElements
+	}
+	return p.MinFilterElements
+}
+
 // To maintains active subscriptions to a collection of peers, merging their publications into a
 // single, deduplicated stream.
 type To interface {
@@ -169,13 +183,18 @@ func (t *to) Begin() error {
 	for c := uint32(0); c < t.params.NSubscriptions; c++ {
 		go func(i uint32) {
 			rng, fp := rand.New(rand.NewSource(int64(i))), float64(t.params.FPRate)
+			filterParams := &FilterParameters{
+				Padding:     RandomPadding,
+				MinElements: t.params.minFilterElements(),
+			}
 			for {
 				lc, peerID, err := t.csb.AddNext()
 				if err != nil {
 					fatal <- err
 					return
 				}
-				sub, err := NewFPSubscription(fp, rng)
+				sub, err := NewSubscriptionWithParams([][]byte{}, fp, [][]byte{}, 1.0,
+					filterParams, rng)
 				if err != nil {
 					fatal <- err
 					return
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/common/ecid"
 	clogging "github.com/drausin/libri/libri/common/logging"
@@ -207,6 +208,16 @@ func TestFrom_Send(t *testing.T) {
 	wg.Wait()
 }
 
+func TestToParameters_minFilterElements(t *testing.T) {
+	params := NewDefaultToParameters()
+	params.MinFilterElements = 3
+	assert.Equal(t, uint32(3), params.minFilterElements())
+
+	// check zero is replaced by the default
+	params.MinFilterElements = 0
+	assert.Equal(t, uint32(DefaultMinFilterElements), params.minFilterElements())
+}
+
 func TestSubscriptionBeginnerImpl_Begin_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	clientID := ecid.NewPseudoRandom(rng)
@@ -495,6 +506,10 @@ func (f *fixedSigner) Sign(m proto.Message) (string, error) {
 	return f.signature, f.err
 }
 
+func (f *fixedSigner) SignUntil(m proto.Message, notAfter time.Time) (string, error) {
+	return f.signature, f.err
+}
+
 type fixedLibrarianSubscribeClient struct {
 	responses chan *api.SubscribeResponse
 	err       chan error
@@ -2,15 +2,34 @@ package api
 
 import (
 	"errors"
+	"math"
 	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/drausin/libri/libri/common/id"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// DefaultLatencyHalfLife is the default duration after which a latency-aware balancer gives
+	// an observed latency half its original influence on selection.
+	DefaultLatencyHalfLife = 10 * time.Minute
+
+	// latencySmoothing is the weight of each new observation in the exponentially weighted
+	// moving average of a librarian's latency
+	latencySmoothing = 0.3
 )
 
 // ErrEmptyLibrarianAddresses indicates that the librarian addresses is empty.
 var ErrEmptyLibrarianAddresses = errors.New("empty librarian addresses")
 
+// ErrOnlySelfLibrarianAddress indicates that the only librarian address is self's own.
+var ErrOnlySelfLibrarianAddress = errors.New("only librarian address is self")
+
 // ClientBalancer load balances between a collection of LibrarianClients.
 type ClientBalancer interface {
 	// Next selects the next LibrarianClient.
@@ -31,6 +50,36 @@ type ClientSetBalancer interface {
 	Remove(peerID id.ID) error
 }
 
+// ExcludeSelf returns the librarian addresses other than self, e.g., so that a balancer created
+// from a librarian's bootstrap addresses never selects (and sends requests back to) the librarian
+// itself. An unspecified self IP (e.g., 0.0.0.0) also excludes loopback addresses with the same
+// port. It returns ErrOnlySelfLibrarianAddress if self is the only address.
+func ExcludeSelf(libAddrs []*net.TCPAddr, self *net.TCPAddr) ([]*net.TCPAddr, error) {
+	if len(libAddrs) == 0 {
+		return nil, ErrEmptyLibrarianAddresses
+	}
+	others := make([]*net.TCPAddr, 0, len(libAddrs))
+	for _, la := range libAddrs {
+		if !isSelf(la, self) {
+			others = append(others, la)
+		}
+	}
+	if len(others) == 0 {
+		return nil, ErrOnlySelfLibrarianAddress
+	}
+	return others, nil
+}
+
+func isSelf(addr *net.TCPAddr, self *net.TCPAddr) bool {
+	if self == nil || addr.Port != self.Port {
+		return false
+	}
+	if self.IP.IsUnspecified() {
+		return addr.IP.IsUnspecified() || addr.IP.IsLoopback()
+	}
+	return addr.IP.Equal(self.IP)
+}
+
 type uniformRandBalancer struct {
 	rng   *rand.Rand
 	mu    sync.Mutex
@@ -38,30 +87,186 @@ type uniformRandBalancer struct {
 }
 
 // NewUniformRandomClientBalancer creates a new ClientBalancer that selects the next client
-// uniformly at random.
-func NewUniformRandomClientBalancer(libAddrs []*net.TCPAddr) (ClientBalancer, error) {
+// uniformly at random, seeded by the current time. Connections are secured with the given
+// transport credentials and dialed with the given gRPC dial options, as in Dial.
+func NewUniformRandomClientBalancer(
+	libAddrs []*net.TCPAddr, creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+) (ClientBalancer, error) {
+	return NewUniformRandomClientBalancerWithSeed(libAddrs, time.Now().UnixNano(), creds,
+		dialOpts...)
+}
+
+// NewUniformRandomClientBalancerWithSeed creates a new ClientBalancer like
+// NewUniformRandomClientBalancer but seeded with the given value, so balancers with the same seed
+// and librarian addresses select the same sequence of clients, e.g., for reproducible tests.
+func NewUniformRandomClientBalancerWithSeed(
+	libAddrs []*net.TCPAddr, seed int64, creds credentials.TransportCredentials,
+	dialOpts ...grpc.DialOption,
+) (ClientBalancer, error) {
 	conns := make([]Connector, len(libAddrs))
 	if libAddrs == nil || len(libAddrs) == 0 {
 		return nil, ErrEmptyLibrarianAddresses
 	}
 	for i, la := range libAddrs {
-		conns[i] = NewConnector(la)
+		conns[i] = NewSecureConnector(la, creds, dialOpts...)
 	}
 	return &uniformRandBalancer{
-		rng:   rand.New(rand.NewSource(int64(len(conns)))),
+		rng:   rand.New(rand.NewSource(seed)),
 		conns: conns,
 	}, nil
 }
 
 // Next selects the next librarian client uniformly at random.
 func (b *uniformRandBalancer) Next() (LibrarianClient, error) {
+	return b.conns[b.nextIndex()].Connect()
+}
+
+func (b *uniformRandBalancer) CloseAll() error {
+	for _, conn := range b.conns {
+		err := conn.Disconnect()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *uniformRandBalancer) nextIndex() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	i := b.rng.Int31n(int32(len(b.conns)))
+	return int(b.rng.Int31n(int32(len(b.conns))))
+}
+
+type roundRobinBalancer struct {
+	next  uint64
+	conns []Connector
+}
+
+// NewRoundRobinClientBalancer creates a new ClientBalancer that cycles through the librarians in
+// order, so n*len(libAddrs) consecutive calls to Next select each librarian exactly n times. It is
+// safe for concurrent use. Connections are secured with the given transport credentials and
+// dialed with the given gRPC dial options, as in Dial.
+func NewRoundRobinClientBalancer(
+	libAddrs []*net.TCPAddr, creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+) (ClientBalancer, error) {
+	if len(libAddrs) == 0 {
+		return nil, ErrEmptyLibrarianAddresses
+	}
+	conns := make([]Connector, len(libAddrs))
+	for i, la := range libAddrs {
+		conns[i] = NewSecureConnector(la, creds, dialOpts...)
+	}
+	return &roundRobinBalancer{conns: conns}, nil
+}
+
+// Next selects the librarian client after the one previously selected.
+func (b *roundRobinBalancer) Next() (LibrarianClient, error) {
+	return b.conns[b.nextIndex()].Connect()
+}
+
+func (b *roundRobinBalancer) CloseAll() error {
+	for _, conn := range b.conns {
+		err := conn.Disconnect()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *roundRobinBalancer) nextIndex() int {
+	i := atomic.AddUint64(&b.next, 1) - 1
+	return int(i % uint64(len(b.conns)))
+}
+
+// LatencyObserver records round-trip latencies to librarians.
+type LatencyObserver interface {
+	// ObserveLatency records a round-trip latency to the librarian with the given address
+	// string, as given by (*net.TCPAddr).String().
+	ObserveLatency(addr string, rtt time.Duration)
+}
+
+// LatencyAwareClientBalancer is a ClientBalancer that favors librarians with lower observed
+// latencies.
+type LatencyAwareClientBalancer interface {
+	ClientBalancer
+	LatencyObserver
+}
+
+type latencyEstimate struct {
+	// exponentially weighted moving average of observed latencies
+	mean time.Duration
+
+	// time of the latest observation
+	observed time.Time
+}
+
+type latencyAwareBalancer struct {
+	rng       *rand.Rand
+	mu        sync.Mutex
+	conns     []Connector
+	latencies map[string]*latencyEstimate
+	halfLife  time.Duration
+	now       func() time.Time
+}
+
+// NewLatencyAwareClientBalancer creates a new LatencyAwareClientBalancer that selects the next
+// client at random with probability inversely proportional to its observed latency. Librarians
+// without observations are selected as if they had the lowest observed latency, and older
+// observations decay toward this with DefaultLatencyHalfLife, so slow librarians are eventually
+// tried again. Selection is seeded by the current time. Connections are secured with the given
+// transport credentials and dialed with the given gRPC dial options, as in Dial.
+func NewLatencyAwareClientBalancer(
+	libAddrs []*net.TCPAddr, creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+) (LatencyAwareClientBalancer, error) {
+	return NewLatencyAwareClientBalancerWithSeed(libAddrs, time.Now().UnixNano(), creds,
+		dialOpts...)
+}
+
+// NewLatencyAwareClientBalancerWithSeed creates a new LatencyAwareClientBalancer like
+// NewLatencyAwareClientBalancer but seeded with the given value, e.g., for reproducible tests.
+func NewLatencyAwareClientBalancerWithSeed(
+	libAddrs []*net.TCPAddr, seed int64, creds credentials.TransportCredentials,
+	dialOpts ...grpc.DialOption,
+) (LatencyAwareClientBalancer, error) {
+	if len(libAddrs) == 0 {
+		return nil, ErrEmptyLibrarianAddresses
+	}
+	conns := make([]Connector, len(libAddrs))
+	for i, la := range libAddrs {
+		conns[i] = NewSecureConnector(la, creds, dialOpts...)
+	}
+	return &latencyAwareBalancer{
+		rng:       rand.New(rand.NewSource(seed)),
+		conns:     conns,
+		latencies: make(map[string]*latencyEstimate),
+		halfLife:  DefaultLatencyHalfLife,
+		now:       time.Now,
+	}, nil
+}
+
+// Next selects the next librarian client, favoring those with lower latencies.
+func (b *latencyAwareBalancer) Next() (LibrarianClient, error) {
+	b.mu.Lock()
+	i := b.sample()
+	b.mu.Unlock()
 	return b.conns[i].Connect()
 }
 
-func (b *uniformRandBalancer) CloseAll() error {
+func (b *latencyAwareBalancer) ObserveLatency(addr string, rtt time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	est, in := b.latencies[addr]
+	if !in {
+		b.latencies[addr] = &latencyEstimate{mean: rtt, observed: b.now()}
+		return
+	}
+	est.mean = time.Duration(latencySmoothing*float64(rtt) +
+		(1-latencySmoothing)*float64(est.mean))
+	est.observed = b.now()
+}
+
+func (b *latencyAwareBalancer) CloseAll() error {
 	for _, conn := range b.conns {
 		err := conn.Disconnect()
 		if err != nil {
@@ -70,3 +275,40 @@ func (b *uniformRandBalancer) CloseAll() error {
 	}
 	return nil
 }
+
+// sample returns the index of the next connector, weighting each by its inverse latency.
+func (b *latencyAwareBalancer) sample() int {
+	now := b.now()
+	weights := make([]float64, len(b.conns))
+	confidences := make([]float64, len(b.conns))
+	maxWeight := 0.0
+	for i, conn := range b.conns {
+		est, in := b.latencies[conn.Address().String()]
+		if !in {
+			continue
+		}
+		weights[i] = 1 / math.Max(est.mean.Seconds(), 1e-6)
+		age := now.Sub(est.observed).Seconds()
+		confidences[i] = math.Pow(2, -age/b.halfLife.Seconds())
+		maxWeight = math.Max(maxWeight, weights[i])
+	}
+	if maxWeight == 0 {
+		// no observations, so fall back to uniform random
+		return b.rng.Intn(len(b.conns))
+	}
+
+	// unobserved librarians get the max weight, and observed ones decay toward it
+	total := 0.0
+	for i := range weights {
+		weights[i] = confidences[i]*weights[i] + (1-confidences[i])*maxWeight
+		total += weights[i]
+	}
+	x := b.rng.Float64() * total
+	for i, weight := range weights {
+		if x < weight {
+			return i
+		}
+		x -= weight
+	}
+	return len(weights) - 1
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniformRandomBalancer_Next_ipv6(t *testing.T) {
+	addrs := make([]*net.TCPAddr, 0)
+	for _, a := range []string{"[::1]:20100", "127.0.0.1:20101", "[::1]:20102"} {
+		addr, err := net.ResolveTCPAddr("tcp", a)
+		assert.Nil(t, err)
+		addrs = append(addrs, addr)
+	}
+	b, err := NewUniformRandomClientBalancer(addrs, nil)
+	assert.Nil(t, err)
+	for range addrs {
+		lc, err := b.Next()
+		assert.Nil(t, err)
+		assert.NotNil(t, lc)
+	}
+	err = b.CloseAll()
+	assert.Nil(t, err)
+}
+
+func TestNewUniformRandomClientBalancer_err(t *testing.T) {
+	b, err := NewUniformRandomClientBalancer(nil, nil)
+	assert.Equal(t, ErrEmptyLibrarianAddresses, err)
+	assert.Nil(t, b)
+
+	b, err = NewUniformRandomClientBalancerWithSeed([]*net.TCPAddr{}, 0, nil)
+	assert.Equal(t, ErrEmptyLibrarianAddresses, err)
+	assert.Nil(t, b)
+}
+
+func TestUniformRandomBalancer_nextIndex_seed(t *testing.T) {
+	nAddrs, nSelections := 8, 64
+	selections := make([][]int, 3)
+	for i, seed := range []int64{0, 0, 1} {
+		b, err := NewUniformRandomClientBalancerWithSeed(newTestAddrs(nAddrs), seed, nil)
+		assert.Nil(t, err)
+		urb := b.(*uniformRandBalancer)
+		selections[i] = make([]int, nSelections)
+		for j := range selections[i] {
+			selections[i][j] = urb.nextIndex()
+			assert.True(t, selections[i][j] >= 0 && selections[i][j] < nAddrs)
+		}
+	}
+
+	// check same seeds give the same selections and different seeds (very likely) don't
+	assert.Equal(t, selections[0], selections[1])
+	assert.NotEqual(t, selections[0], selections[2])
+}
+
+func TestExcludeSelf_ok(t *testing.T) {
+	addrs := newTestAddrs(3)
+	self, err := net.ResolveTCPAddr("tcp4", "127.0.0.1:20101")
+	assert.Nil(t, err)
+
+	others, err := ExcludeSelf(addrs, self)
+	assert.Nil(t, err)
+	assert.Equal(t, []*net.TCPAddr{addrs[0], addrs[2]}, others)
+
+	// check unspecified self IP excludes loopback address with same port
+	self, err = net.ResolveTCPAddr("tcp4", "0.0.0.0:20102")
+	assert.Nil(t, err)
+	others, err = ExcludeSelf(addrs, self)
+	assert.Nil(t, err)
+	assert.Equal(t, []*net.TCPAddr{addrs[0], addrs[1]}, others)
+
+	// check nil or absent self excludes nothing
+	others, err = ExcludeSelf(addrs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, addrs, others)
+	self, err = net.ResolveTCPAddr("tcp4", "127.0.0.1:30100")
+	assert.Nil(t, err)
+	others, err = ExcludeSelf(addrs, self)
+	assert.Nil(t, err)
+	assert.Equal(t, addrs, others)
+}
+
+func TestExcludeSelf_err(t *testing.T) {
+	self := newTestAddrs(1)[0]
+
+	// check only self errors
+	others, err := ExcludeSelf([]*net.TCPAddr{self}, self)
+	assert.Equal(t, ErrOnlySelfLibrarianAddress, err)
+	assert.Nil(t, others)
+
+	// check self given multiple times errors
+	others, err = ExcludeSelf([]*net.TCPAddr{self, newTestAddrs(1)[0]}, self)
+	assert.Equal(t, ErrOnlySelfLibrarianAddress, err)
+	assert.Nil(t, others)
+
+	others, err = ExcludeSelf(nil, self)
+	assert.Equal(t, ErrEmptyLibrarianAddresses, err)
+	assert.Nil(t, others)
+}
+
+func TestNewRoundRobinClientBalancer_err(t *testing.T) {
+	b, err := NewRoundRobinClientBalancer(nil, nil)
+	assert.Equal(t, ErrEmptyLibrarianAddresses, err)
+	assert.Nil(t, b)
+}
+
+func TestRoundRobinBalancer_Next(t *testing.T) {
+	b, err := NewRoundRobinClientBalancer(newTestAddrs(3), nil)
+	assert.Nil(t, err)
+	lc, err := b.Next()
+	assert.Nil(t, err)
+	assert.NotNil(t, lc)
+	err = b.CloseAll()
+	assert.Nil(t, err)
+}
+
+func TestRoundRobinBalancer_nextIndex(t *testing.T) {
+	for _, nAddrs := range []int{1, 2, 3, 8} {
+		b, err := NewRoundRobinClientBalancer(newTestAddrs(nAddrs), nil)
+		assert.Nil(t, err)
+		rrb := b.(*roundRobinBalancer)
+
+		// check even distribution across concurrent callers
+		nCallers, nPerCaller := 4, 5*nAddrs
+		counts := make([]int, nAddrs)
+		mu := new(sync.Mutex)
+		wg := new(sync.WaitGroup)
+		for c := 0; c < nCallers; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < nPerCaller; i++ {
+					j := rrb.nextIndex()
+					mu.Lock()
+					counts[j]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		for _, count := range counts {
+			assert.Equal(t, nCallers*nPerCaller/nAddrs, count)
+		}
+
+		// check order cycles
+		for i := 0; i < 2*nAddrs; i++ {
+			assert.Equal(t, i%nAddrs, rrb.nextIndex())
+		}
+	}
+}
+
+func TestNewLatencyAwareClientBalancer_err(t *testing.T) {
+	b, err := NewLatencyAwareClientBalancer(nil, nil)
+	assert.Equal(t, ErrEmptyLibrarianAddresses, err)
+	assert.Nil(t, b)
+
+	b, err = NewLatencyAwareClientBalancerWithSeed([]*net.TCPAddr{}, 0, nil)
+	assert.Equal(t, ErrEmptyLibrarianAddresses, err)
+	assert.Nil(t, b)
+}
+
+func TestLatencyAwareBalancer_sample_seed(t *testing.T) {
+	nAddrs, nSelections := 8, 64
+	selections := make([][]int, 3)
+	for i, seed := range []int64{0, 0, 1} {
+		b, err := NewLatencyAwareClientBalancerWithSeed(newTestAddrs(nAddrs), seed, nil)
+		assert.Nil(t, err)
+		lab := b.(*latencyAwareBalancer)
+		selections[i] = make([]int, nSelections)
+		for j := range selections[i] {
+			selections[i][j] = lab.sample()
+			assert.True(t, selections[i][j] >= 0 && selections[i][j] < nAddrs)
+		}
+	}
+
+	// check same seeds give the same selections and different seeds (very likely) don't
+	assert.Equal(t, selections[0], selections[1])
+	assert.NotEqual(t, selections[0], selections[2])
+}
+
+func TestLatencyAwareBalancer_Next(t *testing.T) {
+	b, err := NewLatencyAwareClientBalancer(newTestAddrs(3), nil)
+	assert.Nil(t, err)
+	lc, err := b.Next()
+	assert.Nil(t, err)
+	assert.NotNil(t, lc)
+	err = b.CloseAll()
+	assert.Nil(t, err)
+}
+
+func TestLatencyAwareBalancer_sample(t *testing.T) {
+	addrs := newTestAddrs(3)
+	b, err := NewLatencyAwareClientBalancerWithSeed(addrs, 0, nil)
+	assert.Nil(t, err)
+	lab := b.(*latencyAwareBalancer)
+	now := time.Now()
+	lab.now = func() time.Time { return now }
+	nSamples := 3000
+
+	// check no observations gives roughly uniform selection
+	counts := sampleCounts(lab, nSamples)
+	for _, count := range counts {
+		assert.InDelta(t, nSamples/3, count, float64(nSamples/10))
+	}
+
+	// check faster and unobserved librarians are favored over slower ones
+	b.ObserveLatency(addrs[0].String(), 10*time.Millisecond)
+	b.ObserveLatency(addrs[1].String(), 100*time.Millisecond)
+	counts = sampleCounts(lab, nSamples)
+	assert.True(t, counts[0] > 4*counts[1])
+	assert.True(t, counts[2] > 4*counts[1])
+
+	// check new observations move the latency estimate
+	for c := 0; c < 32; c++ {
+		b.ObserveLatency(addrs[1].String(), 10*time.Millisecond)
+	}
+	mean := lab.latencies[addrs[1].String()].mean
+	assert.InDelta(t, float64(10*time.Millisecond), float64(mean), float64(time.Millisecond))
+
+	// check old observations decay so slow librarians get another chance
+	b.ObserveLatency(addrs[1].String(), time.Second)
+	counts = sampleCounts(lab, nSamples)
+	assert.True(t, counts[0] > 2*counts[1])
+	now = now.Add(10 * DefaultLatencyHalfLife)
+	counts = sampleCounts(lab, nSamples)
+	for _, count := range counts {
+		assert.InDelta(t, nSamples/3, count, float64(nSamples/10))
+	}
+}
+
+func sampleCounts(b *latencyAwareBalancer, n int) []int {
+	counts := make([]int, len(b.conns))
+	for c := 0; c < n; c++ {
+		counts[b.sample()]++
+	}
+	return counts
+}
+
+func newTestAddrs(n int) []*net.TCPAddr {
+	addrs := make([]*net.TCPAddr, n)
+	for i := range addrs {
+		addr, err := net.ResolveTCPAddr("tcp4", fmt.Sprintf("127.0.0.1:%d", 20100+i))
+		if err != nil {
+			panic(err)
+		}
+		addrs[i] = addr
+	}
+	return addrs
+}
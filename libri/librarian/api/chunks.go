@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// DefaultPutChunkSize is the default max number of value bytes in each PutChunk.
+const DefaultPutChunkSize = 1024 * 1024 // 1 MB
+
+// ErrZeroPutChunkSize indicates when Put request values are split into chunks of zero size.
+var ErrZeroPutChunkSize = errors.New("put chunk size must be greater than zero")
+
+// NewPutChunks splits a Put request into the chunks streamed to PutStream. The first chunk
+// carries the request without its value, and each subsequent chunk carries the next (at most)
+// chunkSize bytes of the marshaled value.
+func NewPutChunks(rq *PutRequest, chunkSize int) ([]*PutChunk, error) {
+	if chunkSize <= 0 {
+		return nil, ErrZeroPutChunkSize
+	}
+	valueBytes, err := proto.Marshal(rq.Value)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]*PutChunk, 1, len(valueBytes)/chunkSize+2)
+	chunks[0] = &PutChunk{
+		Request: &PutRequest{
+			Metadata:     rq.Metadata,
+			Key:          rq.Key,
+			NReplicas:    rq.NReplicas,
+			MinNReplicas: rq.MinNReplicas,
+		},
+	}
+	for start := 0; start < len(valueBytes); start += chunkSize {
+		end := start + chunkSize
+		if end > len(valueBytes) {
+			end = len(valueBytes)
+		}
+		chunks = append(chunks, &PutChunk{ValueChunk: valueBytes[start:end]})
+	}
+	return chunks, nil
+}
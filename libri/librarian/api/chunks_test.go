@@ -0,0 +1,56 @@
+package api
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPutChunks_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	value, key := NewTestDocument(rng)
+	rq := &PutRequest{
+		Metadata:     &RequestMetadata{PubKey: ecid.NewPseudoRandom(rng).PublicKeyBytes()},
+		Key:          key.Bytes(),
+		Value:        value,
+		NReplicas:    3,
+		MinNReplicas: 2,
+	}
+	valueBytes, err := proto.Marshal(value)
+	assert.Nil(t, err)
+
+	for _, chunkSize := range []int{1, 7, 64, len(valueBytes), len(valueBytes) + 1} {
+		chunks, err := NewPutChunks(rq, chunkSize)
+		assert.Nil(t, err)
+
+		// check first chunk has request without value
+		assert.Equal(t, rq.Metadata, chunks[0].Request.Metadata)
+		assert.Equal(t, rq.Key, chunks[0].Request.Key)
+		assert.Equal(t, rq.NReplicas, chunks[0].Request.NReplicas)
+		assert.Equal(t, rq.MinNReplicas, chunks[0].Request.MinNReplicas)
+		assert.Nil(t, chunks[0].Request.Value)
+		assert.NotNil(t, rq.Value)
+
+		// check subsequent chunks reassemble value
+		reassembled := make([]byte, 0, len(valueBytes))
+		for _, chunk := range chunks[1:] {
+			assert.Nil(t, chunk.Request)
+			assert.True(t, len(chunk.ValueChunk) <= chunkSize)
+			reassembled = append(reassembled, chunk.ValueChunk...)
+		}
+		assert.Equal(t, valueBytes, reassembled)
+	}
+}
+
+func TestNewPutChunks_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	value, key := NewTestDocument(rng)
+	rq := &PutRequest{Key: key.Bytes(), Value: value}
+
+	chunks, err := NewPutChunks(rq, 0)
+	assert.Equal(t, ErrZeroPutChunkSize, err)
+	assert.Nil(t, chunks)
+}
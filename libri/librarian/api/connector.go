@@ -4,8 +4,14 @@ import (
 	"net"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// DefaultMaxMessageBytes is the default maximum size (in bytes) of gRPC messages librarians and
+// their clients send and receive. It exceeds gRPC's own 4 MB default to leave room for request
+// and response metadata around the largest documents librarians store by default.
+const DefaultMaxMessageBytes = 5 * 1024 * 1024 // 5 MB
+
 // Connector creates and destroys connections with a peer.
 type Connector interface {
 	// Connect establishes the TCP connection with the peer if it doesn't already exist
@@ -34,11 +40,20 @@ type connector struct {
 	dialer dialer
 }
 
-// NewConnector creates a Connector instance from an address.
-func NewConnector(address *net.TCPAddr) Connector {
+// NewConnector creates a Connector instance from an address. Its connection has no transport
+// security and is dialed with the given gRPC dial options, as in Dial.
+func NewConnector(address *net.TCPAddr, dialOpts ...grpc.DialOption) Connector {
+	return NewSecureConnector(address, nil, dialOpts...)
+}
+
+// NewSecureConnector creates a Connector instance from an address. Its connection is secured with
+// the given transport credentials and dialed with the given gRPC dial options, as in Dial.
+func NewSecureConnector(
+	address *net.TCPAddr, creds credentials.TransportCredentials, dialOpts ...grpc.DialOption,
+) Connector {
 	return &connector{
 		publicAddress: address,
-		dialer:        insecureDialer{},
+		dialer:        grpcDialer{creds: creds, opts: dialOpts},
 	}
 }
 
@@ -73,8 +88,36 @@ type dialer interface {
 	Dial(addr *net.TCPAddr) (*grpc.ClientConn, error)
 }
 
-type insecureDialer struct{}
+type grpcDialer struct {
+	creds credentials.TransportCredentials
+	opts  []grpc.DialOption
+}
+
+func (d grpcDialer) Dial(addr *net.TCPAddr) (*grpc.ClientConn, error) {
+	return Dial(addr, d.creds, d.opts...)
+}
+
+// Dial creates a client connection to the given address secured with the given transport
+// credentials, or without transport security if they are nil. The gRPC dial options, e.g.,
+// keepalive parameters, should not set transport security themselves. Messages up to
+// DefaultMaxMessageBytes are sent and received unless the options include WithMaxMessageBytes.
+func Dial(addr *net.TCPAddr, creds credentials.TransportCredentials,
+	dialOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	transportOpt := grpc.WithInsecure()
+	if creds != nil {
+		transportOpt = grpc.WithTransportCredentials(creds)
+	}
+	// given options follow the default max message size so they can override it
+	dialOpts = append([]grpc.DialOption{transportOpt, WithMaxMessageBytes(DefaultMaxMessageBytes)},
+		dialOpts...)
+	return grpc.Dial(addr.String(), dialOpts...)
+}
 
-func (insecureDialer) Dial(addr *net.TCPAddr) (*grpc.ClientConn, error) {
-	return grpc.Dial(addr.String(), grpc.WithInsecure())
+// WithMaxMessageBytes returns a dial option setting the maximum size (in bytes) of the messages a
+// client connection sends and receives.
+func WithMaxMessageBytes(maxBytes int) grpc.DialOption {
+	return grpc.WithDefaultCallOptions(
+		grpc.MaxCallRecvMsgSize(maxBytes),
+		grpc.MaxCallSendMsgSize(maxBytes),
+	)
 }
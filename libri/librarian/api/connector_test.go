@@ -1,13 +1,17 @@
 package api
 
 import (
+	"crypto/tls"
 	"net"
 	"testing"
+	"time"
 
 	"errors"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 func TestConnector_Connect_ok(t *testing.T) {
@@ -45,6 +49,49 @@ func TestConnector_Address(t *testing.T) {
 	assert.Equal(t, conn.Address(), addr)
 }
 
+func TestNewSecureConnector(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 20100}
+	creds := credentials.NewTLS(&tls.Config{})
+	conn := NewSecureConnector(addr, creds)
+	assert.Equal(t, creds, conn.(*connector).dialer.(grpcDialer).creds)
+
+	lc, err := conn.Connect()
+	assert.Nil(t, err)
+	assert.NotNil(t, lc)
+	assert.Nil(t, conn.Disconnect())
+}
+
+func TestDial(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 20100}
+
+	// check no credentials defaults to no transport security
+	conn, err := Dial(addr, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	assert.Nil(t, conn.Close())
+
+	// check dial options without transport security, e.g., keepalive parameters, are ok
+	kaParams := keepalive.ClientParameters{Time: 30 * time.Second}
+	conn, err = Dial(addr, nil, grpc.WithKeepaliveParams(kaParams),
+		grpc.WithUserAgent("some user agent"))
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	assert.Nil(t, conn.Close())
+
+	// check transport credentials are used
+	creds := credentials.NewTLS(&tls.Config{})
+	conn, err = Dial(addr, creds, grpc.WithKeepaliveParams(kaParams))
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	assert.Nil(t, conn.Close())
+
+	// check max message size option overrides default
+	conn, err = Dial(addr, nil, WithMaxMessageBytes(2*DefaultMaxMessageBytes))
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	assert.Nil(t, conn.Close())
+}
+
 type fixedDialer struct {
 	clientConn *grpc.ClientConn
 	dialErr    error
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"errors"
+	"time"
 
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/golang/protobuf/proto"
@@ -62,6 +63,9 @@ var (
 	// ErrUnexpectedKey indicates when a key does not match the expected key (from GetKey)
 	// for a given value.
 	ErrUnexpectedKey = errors.New("unexpected key for value")
+
+	// ErrExpired indicates when a document is an Entry whose expiry time has passed.
+	ErrExpired = errors.New("document has expired")
 )
 
 // GetKey calculates the key from the has of the proto.Message.
@@ -164,6 +168,16 @@ func ValidateEnvelope(e *Envelope) error {
 	return nil
 }
 
+// IsExpired returns whether the document is an Entry whose expiry time is at or before the given
+// time. Other documents and Entries without an expiry time never expire.
+func IsExpired(doc *Document, now time.Time) bool {
+	entry, ok := doc.Contents.(*Document_Entry)
+	if !ok || entry.Entry.ExpiryTime == 0 {
+		return false
+	}
+	return entry.Entry.ExpiryTime <= now.Unix()
+}
+
 // ValidateEntry checks that all fields of an Entry are populated and have the expected byte
 // lengths.
 func ValidateEntry(e *Entry) error {
@@ -176,6 +190,9 @@ func ValidateEntry(e *Entry) error {
 	if e.CreatedTime == 0 {
 		return errors.New("CreateTime must be populated")
 	}
+	if e.ExpiryTime != 0 && e.ExpiryTime <= e.CreatedTime {
+		return errors.New("ExpiryTime must be after CreatedTime")
+	}
 	if err := ValidateHMAC256(e.MetadataCiphertextMac); err != nil {
 		return err
 	}
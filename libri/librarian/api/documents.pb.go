@@ -290,6 +290,12 @@ type Entry struct {
 	// 32-byte MAC of metatadata ciphertext, encrypted with the 32-byte Entry AES-256 key and
 	// 12-byte metadata block cipher IV
 	MetadataCiphertextMac []byte `protobuf:"bytes,6,opt,name=metadata_ciphertext_mac,json=metadataCiphertextMac,proto3" json:"metadata_ciphertext_mac,omitempty"`
+	// expiry epoch time (seconds since 1970-01-01), after which librarians delete the entry; zero
+	// means the entry never expires
+	ExpiryTime int64 `protobuf:"varint,7,opt,name=expiry_time,json=expiryTime" json:"expiry_time,omitempty"`
+	// identifier of the scheme used to encrypt the metadata and pages; zero is the default
+	// AES-256-GCM scheme
+	EncryptionScheme uint32 `protobuf:"varint,8,opt,name=encryption_scheme,json=encryptionScheme" json:"encryption_scheme,omitempty"`
 }
 
 func (m *Entry) Reset()                    { *m = Entry{} }
@@ -360,6 +366,20 @@ func (m *Entry) GetMetadataCiphertextMac() []byte {
 	return nil
 }
 
+func (m *Entry) GetExpiryTime() int64 {
+	if m != nil {
+		return m.ExpiryTime
+	}
+	return 0
+}
+
+func (m *Entry) GetEncryptionScheme() uint32 {
+	if m != nil {
+		return m.EncryptionScheme
+	}
+	return 0
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Entry) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _Entry_OneofMarshaler, _Entry_OneofUnmarshaler, _Entry_OneofSizer, []interface{}{
@@ -526,36 +546,38 @@ func init() {
 func init() { proto.RegisterFile("libri/librarian/api/documents.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 493 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x93, 0xdf, 0x6b, 0xdb, 0x30,
-	0x10, 0xc7, 0x6b, 0x3b, 0x2e, 0xce, 0x25, 0x59, 0x3b, 0xad, 0x63, 0x66, 0x63, 0x5d, 0xe7, 0x31,
-	0x28, 0x5b, 0x49, 0xa0, 0x83, 0x31, 0x06, 0x7d, 0xd9, 0x0f, 0x28, 0x94, 0x40, 0x30, 0x7b, 0x37,
-	0x8a, 0x7c, 0xb4, 0x22, 0xb1, 0x2d, 0x14, 0xa5, 0xd4, 0xff, 0xc1, 0xde, 0xf6, 0xbe, 0xbf, 0x6b,
-	0x7f, 0xd0, 0xd0, 0x59, 0xce, 0x9c, 0x2e, 0x7b, 0xd8, 0x4b, 0x22, 0xdd, 0xf7, 0x73, 0xd2, 0xe9,
-	0x7b, 0x67, 0x78, 0xb5, 0x94, 0x73, 0x2d, 0x27, 0xf6, 0x97, 0x6b, 0xc9, 0xcb, 0x09, 0x57, 0x72,
-	0x92, 0x57, 0x62, 0x5d, 0x60, 0x69, 0x56, 0x63, 0xa5, 0x2b, 0x53, 0xb1, 0x80, 0x2b, 0x99, 0x7c,
-	0xf7, 0x20, 0xfa, 0xe2, 0x04, 0xf6, 0x16, 0x22, 0x2c, 0x6f, 0x71, 0x59, 0x29, 0x8c, 0xbd, 0x13,
-	0xef, 0x74, 0x70, 0x3e, 0x1a, 0x73, 0x25, 0xc7, 0x5f, 0x5d, 0xf0, 0x72, 0x2f, 0xdd, 0x00, 0x2c,
-	0x81, 0x10, 0x4b, 0xa3, 0xeb, 0xd8, 0x27, 0x12, 0x1c, 0x69, 0x74, 0x7d, 0xb9, 0x97, 0x36, 0x12,
-	0x7b, 0x01, 0x3d, 0xc5, 0xaf, 0x31, 0x0e, 0x08, 0xe9, 0x13, 0x32, 0xe3, 0xd7, 0xf6, 0x20, 0x12,
-	0x3e, 0x01, 0x44, 0xa2, 0x2a, 0x8d, 0xad, 0x2a, 0xf9, 0xe5, 0x41, 0xd4, 0xde, 0xc4, 0x9e, 0x41,
-	0x9f, 0x8e, 0xc8, 0x16, 0x58, 0x53, 0x2d, 0x43, 0x7b, 0xb5, 0xd1, 0xf5, 0x15, 0xd6, 0xec, 0x0d,
-	0x3c, 0xe4, 0x6b, 0x73, 0x53, 0xe9, 0x4c, 0xad, 0xe7, 0x4b, 0x29, 0x08, 0xf2, 0x09, 0x3a, 0x68,
-	0x84, 0x19, 0xc5, 0x1d, 0xab, 0x91, 0xe7, 0xb8, 0xc5, 0x06, 0x0d, 0xdb, 0x08, 0x7f, 0xd8, 0xd7,
-	0xf0, 0x00, 0x71, 0x91, 0x09, 0xa9, 0x6e, 0x50, 0x1b, 0xbc, 0x33, 0x71, 0x8f, 0xc0, 0x11, 0xe2,
-	0xe2, 0xf3, 0x26, 0xc8, 0xce, 0x80, 0x6d, 0x63, 0x59, 0xc1, 0x45, 0x1c, 0x12, 0x7a, 0xb8, 0x85,
-	0x4e, 0xb9, 0x48, 0x7e, 0xfa, 0x10, 0x92, 0x2d, 0xbb, 0xcb, 0xf6, 0x76, 0x97, 0xdd, 0x3a, 0xe7,
-	0xff, 0xc3, 0x39, 0x76, 0x06, 0x7d, 0xfb, 0x6f, 0xcf, 0x58, 0x39, 0x7f, 0x47, 0x1b, 0xea, 0x0a,
-	0xeb, 0x95, 0x6d, 0x96, 0x72, 0x6b, 0xf6, 0x12, 0x86, 0x42, 0x23, 0x37, 0x98, 0x67, 0x46, 0x16,
-	0x48, 0xef, 0x0a, 0xd2, 0x81, 0x8b, 0x7d, 0x93, 0x05, 0xb2, 0x09, 0x3c, 0x2a, 0xd0, 0xf0, 0x9c,
-	0x1b, 0xde, 0x75, 0xa0, 0x79, 0x16, 0x6b, 0xa5, 0x8e, 0x0d, 0xef, 0xe1, 0xc9, 0x8e, 0x04, 0xf2,
-	0x62, 0x9f, 0x92, 0x1e, 0xff, 0x9d, 0x34, 0xe5, 0x62, 0xab, 0xe7, 0x76, 0xfc, 0xa6, 0x8e, 0x62,
-	0x17, 0x00, 0x4a, 0x57, 0x0a, 0xb5, 0x91, 0xb8, 0x8a, 0xbd, 0x93, 0xe0, 0x74, 0x70, 0xfe, 0x9c,
-	0xde, 0xd4, 0x22, 0xe3, 0xd9, 0x46, 0x27, 0x4b, 0xd3, 0x4e, 0xc2, 0xd3, 0x0b, 0x38, 0xb8, 0x27,
-	0xb3, 0x43, 0x08, 0x5a, 0x8f, 0xfb, 0xa9, 0x5d, 0xb2, 0x23, 0x08, 0x6f, 0xf9, 0x72, 0x8d, 0x6e,
-	0x5c, 0x9a, 0xcd, 0x47, 0xff, 0x83, 0x97, 0x1c, 0x43, 0xd4, 0x5a, 0xc7, 0x18, 0xf4, 0xc8, 0x57,
-	0x5b, 0xc3, 0x30, 0xa5, 0x75, 0xf2, 0xc3, 0x83, 0x9e, 0x05, 0xfe, 0xab, 0x8d, 0x47, 0x10, 0xca,
-	0x32, 0xc7, 0x3b, 0xba, 0x6e, 0x94, 0x36, 0x1b, 0x76, 0x0c, 0xd0, 0x71, 0xb8, 0x19, 0xc6, 0x4e,
-	0xc4, 0xce, 0xe1, 0x3d, 0x43, 0xdd, 0x1c, 0x8a, 0xae, 0x91, 0xf3, 0x7d, 0xfa, 0x8e, 0xdf, 0xfd,
-	0x0e, 0x00, 0x00, 0xff, 0xff, 0xe8, 0x54, 0x8a, 0x40, 0xee, 0x03, 0x00, 0x00,
+	// 522 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x95, 0x94, 0xdd, 0x8a, 0xd3, 0x40,
+	0x14, 0xc7, 0x4d, 0xd2, 0xae, 0xe9, 0x69, 0xe3, 0xee, 0x8e, 0x8a, 0x45, 0x71, 0xd5, 0x88, 0x20,
+	0xae, 0xb4, 0xa0, 0x20, 0x22, 0xec, 0x8d, 0x1f, 0x20, 0xc8, 0x42, 0x89, 0xde, 0x87, 0xe9, 0xe4,
+	0xb0, 0x1d, 0xb6, 0x4d, 0x42, 0x32, 0x5d, 0xda, 0x37, 0xf0, 0xce, 0x97, 0xf3, 0x21, 0x7c, 0x0c,
+	0xcf, 0x9c, 0x7c, 0x98, 0xae, 0xf5, 0xc2, 0x9b, 0x64, 0xf2, 0x3f, 0xbf, 0x99, 0x73, 0xe6, 0x7f,
+	0x0e, 0x81, 0xa7, 0x4b, 0x3d, 0x2f, 0xf4, 0xd4, 0x3e, 0x65, 0xa1, 0x65, 0x3a, 0x95, 0xb9, 0x9e,
+	0x26, 0x99, 0x5a, 0xaf, 0x30, 0x35, 0xe5, 0x24, 0x2f, 0x32, 0x93, 0x09, 0x8f, 0xc4, 0xf0, 0xbb,
+	0x03, 0xfe, 0xc7, 0x3a, 0x20, 0x4e, 0xc1, 0xc7, 0xf4, 0x0a, 0x97, 0x59, 0x8e, 0x63, 0xe7, 0xb1,
+	0xf3, 0x7c, 0xf8, 0x2a, 0x98, 0x10, 0x34, 0xf9, 0x54, 0x8b, 0x9f, 0x6f, 0x44, 0x2d, 0x20, 0x42,
+	0xe8, 0xd3, 0x9e, 0x62, 0x3b, 0x76, 0x99, 0x84, 0x9a, 0x24, 0x85, 0xb0, 0x2a, 0x24, 0x1e, 0x41,
+	0x2f, 0x97, 0x17, 0x38, 0xf6, 0x18, 0x19, 0x30, 0x32, 0x23, 0x81, 0x08, 0x0e, 0xbc, 0x07, 0xf0,
+	0x55, 0x96, 0x1a, 0x5b, 0x55, 0xf8, 0x93, 0x4a, 0x69, 0x32, 0x89, 0x07, 0x30, 0xe0, 0x23, 0xe2,
+	0x4b, 0xdc, 0x72, 0x2d, 0x23, 0x9b, 0x9a, 0x84, 0x2f, 0xb8, 0x15, 0x2f, 0xe0, 0x58, 0xae, 0xcd,
+	0x22, 0x2b, 0xe2, 0x7c, 0x3d, 0x5f, 0x6a, 0xc5, 0x90, 0xcb, 0xd0, 0x61, 0x15, 0x98, 0xb1, 0x5e,
+	0xb3, 0x05, 0xca, 0x04, 0x77, 0x58, 0xaf, 0x62, 0xab, 0xc0, 0x1f, 0xf6, 0x19, 0xdc, 0x42, 0xbc,
+	0x8c, 0x95, 0xce, 0x17, 0x58, 0x18, 0xdc, 0x98, 0x71, 0x8f, 0xc1, 0x80, 0xd4, 0x0f, 0xad, 0x28,
+	0x5e, 0x82, 0xd8, 0xc5, 0xe2, 0x95, 0x54, 0xe3, 0x3e, 0xa3, 0x47, 0x3b, 0xe8, 0xb9, 0x54, 0xe1,
+	0x2f, 0x17, 0xfa, 0x6c, 0xcb, 0xfe, 0xb2, 0x9d, 0xfd, 0x65, 0x37, 0xce, 0xb9, 0xff, 0x70, 0x8e,
+	0x8a, 0x18, 0xd8, 0xb7, 0x3d, 0xa3, 0xac, 0xfd, 0x0d, 0x5a, 0x8a, 0x4e, 0x28, 0x6d, 0xb3, 0xf2,
+	0x7a, 0x2d, 0x9e, 0xc0, 0x48, 0xd1, 0x6d, 0x0d, 0x26, 0xb1, 0xd1, 0x2b, 0xe4, 0x7b, 0x79, 0xd1,
+	0xb0, 0xd6, 0xbe, 0x91, 0x24, 0xa6, 0x70, 0x7b, 0x85, 0x46, 0x26, 0xd2, 0xc8, 0xae, 0x03, 0xd5,
+	0xb5, 0x44, 0x13, 0xea, 0xd8, 0xf0, 0x06, 0xee, 0xed, 0xd9, 0xc0, 0x5e, 0x1c, 0xf0, 0xa6, 0xbb,
+	0x7f, 0x6f, 0x22, 0x43, 0xe8, 0x6a, 0x43, 0xdc, 0xe4, 0x9a, 0x7a, 0xcb, 0xa5, 0xdc, 0xe4, 0x52,
+	0xa0, 0x92, 0xb8, 0x92, 0x53, 0x38, 0xc6, 0x54, 0x15, 0xdb, 0xdc, 0xe8, 0x2c, 0x8d, 0x4b, 0xb5,
+	0x40, 0xc2, 0x7c, 0xc2, 0x02, 0xb2, 0xb7, 0x0d, 0x7c, 0x65, 0x7d, 0x67, 0x82, 0xec, 0x30, 0x9f,
+	0xd7, 0x39, 0xc5, 0x19, 0x00, 0xcd, 0x79, 0x4e, 0x79, 0x35, 0x96, 0x64, 0xb3, 0x47, 0x0e, 0x3d,
+	0x64, 0x87, 0x1a, 0x64, 0x32, 0x6b, 0xe3, 0xdc, 0xa0, 0xa8, 0xb3, 0xe1, 0xfe, 0x19, 0x1c, 0x5e,
+	0x0b, 0x8b, 0x23, 0xf0, 0x9a, 0x8e, 0x0d, 0x22, 0xbb, 0x14, 0x77, 0xa0, 0x7f, 0x25, 0x97, 0x6b,
+	0xac, 0x87, 0xaf, 0xfa, 0x78, 0xe7, 0xbe, 0x75, 0xc2, 0x13, 0xf0, 0x9b, 0x46, 0x08, 0x01, 0x3d,
+	0xee, 0x92, 0xad, 0x61, 0x14, 0xf1, 0x3a, 0xfc, 0xe1, 0x40, 0xcf, 0x02, 0xff, 0x35, 0x14, 0x94,
+	0x4e, 0xa7, 0x09, 0x6e, 0x38, 0x5d, 0x10, 0x55, 0x1f, 0xe2, 0x04, 0xa0, 0xd3, 0xaf, 0x6a, 0xb4,
+	0x3b, 0x8a, 0x9d, 0xea, 0x6b, 0xed, 0xa9, 0xa7, 0x5a, 0x75, 0xdb, 0x32, 0x3f, 0xe0, 0xbf, 0xc2,
+	0xeb, 0xdf, 0x3e, 0xd5, 0x6c, 0xa0, 0x3c, 0x04, 0x00, 0x00,
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/common/ecid"
 	"github.com/stretchr/testify/assert"
@@ -178,6 +179,7 @@ func TestValidateEntry_err(t *testing.T) {
 		func(e *Entry) { e.MetadataCiphertext = empty },     // 10) can't be zero-length
 		func(e *Entry) { e.MetadataCiphertext = zeros },     // 11) can't be all zeros
 		func(e *Entry) { e.AuthorPublicKey = diffPK },       // 12) different PK from Page
+		func(e *Entry) { e.ExpiryTime = e.CreatedTime },     // 13) must be after CreatedTime
 	}
 
 	assert.NotNil(t, ValidateEntry(nil))
@@ -188,6 +190,26 @@ func TestValidateEntry_err(t *testing.T) {
 	}
 }
 
+func TestIsExpired(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	now := time.Unix(100, 0)
+	doc, _ := NewTestDocument(rng)
+	entry := doc.Contents.(*Document_Entry).Entry
+
+	// check entries without expiry never expire
+	assert.False(t, IsExpired(doc, now))
+
+	entry.ExpiryTime = now.Unix() + 1
+	assert.False(t, IsExpired(doc, now))
+
+	entry.ExpiryTime = now.Unix()
+	assert.True(t, IsExpired(doc, now))
+
+	// check other documents never expire
+	page := &Document{Contents: &Document_Page{Page: NewTestPage(rng)}}
+	assert.False(t, IsExpired(page, now))
+}
+
 func TestValidatePage_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	p := NewTestPage(rng)
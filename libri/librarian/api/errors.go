@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrExpiredRPC is ErrExpired as librarians return it from RPCs, with its own gRPC code and a
+// fixed description so clients can map it back to ErrExpired with FromRPCError.
+var ErrExpiredRPC = grpc.Errorf(codes.FailedPrecondition, "document has expired")
+
+// ErrorCode classifies an error so callers can decide how to handle it (e.g., whether to retry)
+// without matching its message.
+type ErrorCode int
+
+const (
+	// UnknownErrorCode is the code of errors that aren't CodedErrors.
+	UnknownErrorCode ErrorCode = iota
+
+	// NotFoundErrorCode indicates when a document isn't stored in the libri network.
+	NotFoundErrorCode
+
+	// InsufficientReplicasErrorCode indicates when fewer replicas of a document were stored than
+	// the minimum acceptable.
+	InsufficientReplicasErrorCode
+
+	// InvalidSignatureErrorCode indicates when a signature or MAC attributing content to its
+	// creator doesn't verify.
+	InvalidSignatureErrorCode
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	UnknownErrorCode:              "UNKNOWN",
+	NotFoundErrorCode:             "NOT_FOUND",
+	InsufficientReplicasErrorCode: "INSUFFICIENT_REPLICAS",
+	InvalidSignatureErrorCode:     "INVALID_SIGNATURE",
+}
+
+func (c ErrorCode) String() string {
+	if name, in := errorCodeNames[c]; in {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// CodedError is an error with an ErrorCode.
+type CodedError interface {
+	error
+
+	// Code returns the code classifying the error.
+	Code() ErrorCode
+
+	// Retryable returns whether the operation giving the error might succeed if retried.
+	Retryable() bool
+}
+
+// ErrorCodeOf returns the code of the given error, or of its cause if it was wrapped with
+// github.com/pkg/errors, if it is a CodedError and UnknownErrorCode otherwise.
+func ErrorCodeOf(err error) ErrorCode {
+	if coded, ok := errors.Cause(err).(CodedError); ok {
+		return coded.Code()
+	}
+	return UnknownErrorCode
+}
+
+// FromRPCError returns the error a librarian RPC error stands for (e.g., ErrExpired for
+// ErrExpiredRPC) or the RPC error itself if it doesn't stand for any other.
+func FromRPCError(err error) error {
+	if grpc.Code(err) == grpc.Code(ErrExpiredRPC) &&
+		grpc.ErrorDesc(err) == grpc.ErrorDesc(ErrExpiredRPC) {
+		return ErrExpired
+	}
+	return err
+}
+
+// DocumentNotFoundError indicates when a document isn't stored in the libri network.
+type DocumentNotFoundError struct {
+	// Key is the key of the missing document.
+	Key cid.ID
+}
+
+func (e *DocumentNotFoundError) Error() string {
+	return fmt.Sprintf("document %s not found", e.Key)
+}
+
+// Code returns NotFoundErrorCode.
+func (e *DocumentNotFoundError) Code() ErrorCode {
+	return NotFoundErrorCode
+}
+
+// Retryable returns true since the document may be found once it has finished replicating.
+func (e *DocumentNotFoundError) Retryable() bool {
+	return true
+}
+
+// InsufficientReplicasError indicates when a librarian stored fewer replicas of a document than
+// the minimum acceptable.
+type InsufficientReplicasError struct {
+	// NReplicas is the number of replicas stored.
+	NReplicas uint32
+
+	// MinNReplicas is the minimum acceptable number of replicas.
+	MinNReplicas uint32
+}
+
+func (e *InsufficientReplicasError) Error() string {
+	return fmt.Sprintf("librarian stored too few replicas: %d < minimum %d", e.NReplicas,
+		e.MinNReplicas)
+}
+
+// Code returns InsufficientReplicasErrorCode.
+func (e *InsufficientReplicasError) Code() ErrorCode {
+	return InsufficientReplicasErrorCode
+}
+
+// Retryable returns true since other peers may be able to store the missing replicas.
+func (e *InsufficientReplicasError) Retryable() bool {
+	return true
+}
+
+// InvalidSignatureError indicates when a signature or MAC attributing content to its creator
+// doesn't verify.
+type InvalidSignatureError struct {
+	// Reason describes what failed to verify.
+	Reason string
+}
+
+func (e *InvalidSignatureError) Error() string {
+	return e.Reason
+}
+
+// Code returns InvalidSignatureErrorCode.
+func (e *InvalidSignatureError) Code() ErrorCode {
+	return InvalidSignatureErrorCode
+}
+
+// Retryable returns false since the same content will fail verification again.
+func (e *InvalidSignatureError) Retryable() bool {
+	return false
+}
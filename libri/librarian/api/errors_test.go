@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrorCode_String(t *testing.T) {
+	assert.Equal(t, "NOT_FOUND", NotFoundErrorCode.String())
+	assert.Equal(t, "INSUFFICIENT_REPLICAS", InsufficientReplicasErrorCode.String())
+	assert.Equal(t, "ErrorCode(100)", ErrorCode(100).String())
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	notFoundErr := &DocumentNotFoundError{Key: cid.NewPseudoRandom(rng)}
+	replicasErr := &InsufficientReplicasError{NReplicas: 2, MinNReplicas: 3}
+	sigErr := &InvalidSignatureError{Reason: "some invalid signature"}
+
+	cases := []struct {
+		err       error
+		code      ErrorCode
+		retryable bool
+	}{
+		{notFoundErr, NotFoundErrorCode, true},
+		{replicasErr, InsufficientReplicasErrorCode, true},
+		{sigErr, InvalidSignatureErrorCode, false},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.code, ErrorCodeOf(c.err), i)
+		assert.Equal(t, c.code, ErrorCodeOf(pkgerrors.Wrap(c.err, "some context")), i)
+		assert.Equal(t, c.retryable, c.err.(CodedError).Retryable(), i)
+		assert.NotEmpty(t, c.err.Error(), i)
+	}
+	assert.Contains(t, notFoundErr.Error(), notFoundErr.Key.String())
+	assert.Contains(t, replicasErr.Error(), "2 < minimum 3")
+
+	assert.Equal(t, UnknownErrorCode, ErrorCodeOf(errors.New("some error")))
+	assert.Equal(t, UnknownErrorCode, ErrorCodeOf(nil))
+}
+
+func TestFromRPCError(t *testing.T) {
+	assert.Equal(t, ErrExpired, FromRPCError(ErrExpiredRPC))
+
+	// check other errors pass through unchanged
+	otherErrs := []error{
+		nil,
+		errors.New("some error"),
+		grpc.Errorf(codes.FailedPrecondition, "some other precondition"),
+		grpc.Errorf(codes.Unknown, ErrExpired.Error()),
+	}
+	for _, err := range otherErrs {
+		assert.Equal(t, err, FromRPCError(err))
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"google.golang.org/grpc"
 )
 
+// MaxSearchConcurrency is the largest number of peers a Get request may ask a librarian to query
+// in parallel when searching for a value.
+const MaxSearchConcurrency = 32
+
 // These interfaces split up the methods of LibrarianClient, mostly to allow for narrow interface
 // usage and testing.
 
@@ -40,12 +44,32 @@ type Getter interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 }
 
+// Locator issues Locate queries.
+type Locator interface {
+	// Locate returns the peers storing a value without retrieving it.
+	Locate(ctx context.Context, in *LocateRequest, opts ...grpc.CallOption) (*LocateResponse,
+		error)
+}
+
+// Informer issues Info queries.
+type Informer interface {
+	// Info returns the librarian's public ID and routing table stats.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+}
+
 // Putter issues Put queries.
 type Putter interface {
 	// Put stores a value.
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
 }
 
+// PutStreamer issues PutStream queries.
+type PutStreamer interface {
+	// PutStream stores a value streamed in chunks, which may together exceed the max message
+	// size.
+	PutStream(ctx context.Context, opts ...grpc.CallOption) (Librarian_PutStreamClient, error)
+}
+
 // PutterGetter issues Put and Get queries.
 type PutterGetter interface {
 	Getter
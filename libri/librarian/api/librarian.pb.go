@@ -192,6 +192,8 @@ type FindRequest struct {
 	Key []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
 	// the number of closests peers to return
 	NumPeers uint32 `protobuf:"varint,3,opt,name=num_peers,json=numPeers" json:"num_peers,omitempty"`
+	// whether to respond with whether the value is stored instead of with the value itself
+	Presence bool `protobuf:"varint,4,opt,name=presence" json:"presence,omitempty"`
 }
 
 func (m *FindRequest) Reset()                    { *m = FindRequest{} }
@@ -220,12 +222,21 @@ func (m *FindRequest) GetNumPeers() uint32 {
 	return 0
 }
 
+func (m *FindRequest) GetPresence() bool {
+	if m != nil {
+		return m.Presence
+	}
+	return false
+}
+
 type FindResponse struct {
 	Metadata *ResponseMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// list of peers closest to target
 	Peers []*PeerAddress `protobuf:"bytes,2,rep,name=peers" json:"peers,omitempty"`
 	// value, if found
 	Value *Document `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
+	// whether the value is stored, for presence requests
+	HasValue bool `protobuf:"varint,4,opt,name=has_value,json=hasValue" json:"has_value,omitempty"`
 }
 
 func (m *FindResponse) Reset()                    { *m = FindResponse{} }
@@ -254,6 +265,13 @@ func (m *FindResponse) GetValue() *Document {
 	return nil
 }
 
+func (m *FindResponse) GetHasValue() bool {
+	if m != nil {
+		return m.HasValue
+	}
+	return false
+}
+
 type PeerAddress struct {
 	// 32-byte peer ID
 	PeerId []byte `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
@@ -352,6 +370,11 @@ type GetRequest struct {
 	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// 32-byte
 	Key []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// number of peers to query in parallel when searching for the value; if zero, the
+	// librarian's default is used
+	SearchConcurrency uint32 `protobuf:"varint,3,opt,name=search_concurrency,json=searchConcurrency" json:"search_concurrency,omitempty"`
+	// whether to only confirm the value exists rather than return it
+	ExistsOnly bool `protobuf:"varint,4,opt,name=exists_only,json=existsOnly" json:"exists_only,omitempty"`
 }
 
 func (m *GetRequest) Reset()                    { *m = GetRequest{} }
@@ -373,10 +396,28 @@ func (m *GetRequest) GetKey() []byte {
 	return nil
 }
 
+func (m *GetRequest) GetSearchConcurrency() uint32 {
+	if m != nil {
+		return m.SearchConcurrency
+	}
+	return 0
+}
+
+func (m *GetRequest) GetExistsOnly() bool {
+	if m != nil {
+		return m.ExistsOnly
+	}
+	return false
+}
+
 type GetResponse struct {
 	Metadata *ResponseMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// value to store for key
 	Value *Document `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	// whether the value exists, set only for exists_only requests
+	Exists bool `protobuf:"varint,3,opt,name=exists" json:"exists,omitempty"`
+	// whether the search timed out before finding the value
+	TimedOut bool `protobuf:"varint,4,opt,name=timed_out,json=timedOut" json:"timed_out,omitempty"`
 }
 
 func (m *GetResponse) Reset()                    { *m = GetResponse{} }
@@ -398,18 +439,154 @@ func (m *GetResponse) GetValue() *Document {
 	return nil
 }
 
+func (m *GetResponse) GetExists() bool {
+	if m != nil {
+		return m.Exists
+	}
+	return false
+}
+
+func (m *GetResponse) GetTimedOut() bool {
+	if m != nil {
+		return m.TimedOut
+	}
+	return false
+}
+
+type LocateRequest struct {
+	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	// 32-byte key of the value to locate
+	Key []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (m *LocateRequest) Reset()                    { *m = LocateRequest{} }
+func (m *LocateRequest) String() string            { return proto.CompactTextString(m) }
+func (*LocateRequest) ProtoMessage()               {}
+func (*LocateRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{13} }
+
+func (m *LocateRequest) GetMetadata() *RequestMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *LocateRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+type LocateResponse struct {
+	Metadata *ResponseMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	// peers reporting they store the value
+	Peers []*PeerAddress `protobuf:"bytes,2,rep,name=peers" json:"peers,omitempty"`
+}
+
+func (m *LocateResponse) Reset()                    { *m = LocateResponse{} }
+func (m *LocateResponse) String() string            { return proto.CompactTextString(m) }
+func (*LocateResponse) ProtoMessage()               {}
+func (*LocateResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{14} }
+
+func (m *LocateResponse) GetMetadata() *ResponseMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *LocateResponse) GetPeers() []*PeerAddress {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
+}
+
+type InfoRequest struct {
+	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *InfoRequest) Reset()                    { *m = InfoRequest{} }
+func (m *InfoRequest) String() string            { return proto.CompactTextString(m) }
+func (*InfoRequest) ProtoMessage()               {}
+func (*InfoRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{15} }
+
+func (m *InfoRequest) GetMetadata() *RequestMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+type InfoResponse struct {
+	Metadata *ResponseMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	// 32-byte public ID of the librarian
+	PeerId []byte `protobuf:"bytes,2,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	// number of peers in the routing table
+	NumPeers uint32 `protobuf:"varint,3,opt,name=num_peers,json=numPeers" json:"num_peers,omitempty"`
+	// number of buckets in the routing table
+	NumBuckets uint32 `protobuf:"varint,4,opt,name=num_buckets,json=numBuckets" json:"num_buckets,omitempty"`
+	// number of peers in each routing table bucket, ordered by the buckets' ID lower bounds
+	BucketPeers []uint32 `protobuf:"varint,5,rep,packed,name=bucket_peers,json=bucketPeers" json:"bucket_peers,omitempty"`
+}
+
+func (m *InfoResponse) Reset()                    { *m = InfoResponse{} }
+func (m *InfoResponse) String() string            { return proto.CompactTextString(m) }
+func (*InfoResponse) ProtoMessage()               {}
+func (*InfoResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{16} }
+
+func (m *InfoResponse) GetMetadata() *ResponseMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *InfoResponse) GetPeerId() []byte {
+	if m != nil {
+		return m.PeerId
+	}
+	return nil
+}
+
+func (m *InfoResponse) GetNumPeers() uint32 {
+	if m != nil {
+		return m.NumPeers
+	}
+	return 0
+}
+
+func (m *InfoResponse) GetNumBuckets() uint32 {
+	if m != nil {
+		return m.NumBuckets
+	}
+	return 0
+}
+
+func (m *InfoResponse) GetBucketPeers() []uint32 {
+	if m != nil {
+		return m.BucketPeers
+	}
+	return nil
+}
+
 type PutRequest struct {
 	Metadata *RequestMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// key to store value under
 	Key []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
 	// value to store for key
 	Value *Document `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
+	// number of replicas to store; if zero, the librarian's default is used
+	NReplicas uint32 `protobuf:"varint,4,opt,name=n_replicas,json=nReplicas" json:"n_replicas,omitempty"`
+	// if non-zero, the fewest replicas acceptable when the librarian can't store all of them
+	MinNReplicas uint32 `protobuf:"varint,5,opt,name=min_n_replicas,json=minNReplicas" json:"min_n_replicas,omitempty"`
 }
 
 func (m *PutRequest) Reset()                    { *m = PutRequest{} }
 func (m *PutRequest) String() string            { return proto.CompactTextString(m) }
 func (*PutRequest) ProtoMessage()               {}
-func (*PutRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{13} }
+func (*PutRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{17} }
 
 func (m *PutRequest) GetMetadata() *RequestMetadata {
 	if m != nil {
@@ -432,18 +609,36 @@ func (m *PutRequest) GetValue() *Document {
 	return nil
 }
 
+func (m *PutRequest) GetNReplicas() uint32 {
+	if m != nil {
+		return m.NReplicas
+	}
+	return 0
+}
+
+func (m *PutRequest) GetMinNReplicas() uint32 {
+	if m != nil {
+		return m.MinNReplicas
+	}
+	return 0
+}
+
 type PutResponse struct {
 	Metadata *ResponseMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// result of the put operation
 	Operation PutOperation `protobuf:"varint,2,opt,name=operation,enum=api.PutOperation" json:"operation,omitempty"`
 	// number of replicas of the stored value; only populated for operation = STORED
 	NReplicas uint32 `protobuf:"varint,3,opt,name=n_replicas,json=nReplicas" json:"n_replicas,omitempty"`
+	// IDs of the peers storing replicas of the value
+	ReplicaPeerIds [][]byte `protobuf:"bytes,4,rep,name=replica_peer_ids,json=replicaPeerIds,proto3" json:"replica_peer_ids,omitempty"`
+	// whether the store timed out, leaving only the replicas above
+	TimedOut bool `protobuf:"varint,5,opt,name=timed_out,json=timedOut" json:"timed_out,omitempty"`
 }
 
 func (m *PutResponse) Reset()                    { *m = PutResponse{} }
 func (m *PutResponse) String() string            { return proto.CompactTextString(m) }
 func (*PutResponse) ProtoMessage()               {}
-func (*PutResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{14} }
+func (*PutResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{18} }
 
 func (m *PutResponse) GetMetadata() *ResponseMetadata {
 	if m != nil {
@@ -466,6 +661,47 @@ func (m *PutResponse) GetNReplicas() uint32 {
 	return 0
 }
 
+func (m *PutResponse) GetReplicaPeerIds() [][]byte {
+	if m != nil {
+		return m.ReplicaPeerIds
+	}
+	return nil
+}
+
+func (m *PutResponse) GetTimedOut() bool {
+	if m != nil {
+		return m.TimedOut
+	}
+	return false
+}
+
+// PutChunk is part of a Put request streamed to PutStream.
+type PutChunk struct {
+	// request whose value is streamed; only populated in the first chunk, without its value
+	Request *PutRequest `protobuf:"bytes,1,opt,name=request" json:"request,omitempty"`
+	// next part of the marshaled value
+	ValueChunk []byte `protobuf:"bytes,2,opt,name=value_chunk,json=valueChunk,proto3" json:"value_chunk,omitempty"`
+}
+
+func (m *PutChunk) Reset()                    { *m = PutChunk{} }
+func (m *PutChunk) String() string            { return proto.CompactTextString(m) }
+func (*PutChunk) ProtoMessage()               {}
+func (*PutChunk) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{19} }
+
+func (m *PutChunk) GetRequest() *PutRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *PutChunk) GetValueChunk() []byte {
+	if m != nil {
+		return m.ValueChunk
+	}
+	return nil
+}
+
 type SubscribeRequest struct {
 	Metadata     *RequestMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	Subscription *Subscription    `protobuf:"bytes,2,opt,name=subscription" json:"subscription,omitempty"`
@@ -474,7 +710,7 @@ type SubscribeRequest struct {
 func (m *SubscribeRequest) Reset()                    { *m = SubscribeRequest{} }
 func (m *SubscribeRequest) String() string            { return proto.CompactTextString(m) }
 func (*SubscribeRequest) ProtoMessage()               {}
-func (*SubscribeRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{15} }
+func (*SubscribeRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{20} }
 
 func (m *SubscribeRequest) GetMetadata() *RequestMetadata {
 	if m != nil {
@@ -499,7 +735,7 @@ type SubscribeResponse struct {
 func (m *SubscribeResponse) Reset()                    { *m = SubscribeResponse{} }
 func (m *SubscribeResponse) String() string            { return proto.CompactTextString(m) }
 func (*SubscribeResponse) ProtoMessage()               {}
-func (*SubscribeResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{16} }
+func (*SubscribeResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{21} }
 
 func (m *SubscribeResponse) GetMetadata() *ResponseMetadata {
 	if m != nil {
@@ -532,7 +768,7 @@ type Publication struct {
 func (m *Publication) Reset()                    { *m = Publication{} }
 func (m *Publication) String() string            { return proto.CompactTextString(m) }
 func (*Publication) ProtoMessage()               {}
-func (*Publication) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{17} }
+func (*Publication) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{22} }
 
 func (m *Publication) GetEnvelopeKey() []byte {
 	if m != nil {
@@ -570,7 +806,7 @@ type Subscription struct {
 func (m *Subscription) Reset()                    { *m = Subscription{} }
 func (m *Subscription) String() string            { return proto.CompactTextString(m) }
 func (*Subscription) ProtoMessage()               {}
-func (*Subscription) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{18} }
+func (*Subscription) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{23} }
 
 func (m *Subscription) GetAuthorPublicKeys() *BloomFilter {
 	if m != nil {
@@ -586,15 +822,30 @@ func (m *Subscription) GetReaderPublicKeys() *BloomFilter {
 	return nil
 }
 
+// BloomFilter is a Bloom filter of m bits and k hash functions. The ith (of k) location of an
+// element is (h[i % 2] + i * h[2 + ((i + (i % 2)) % 4) / 2]) % m, where h[0] and h[1] are the
+// 128-bit MurmurHash3 (x64) of the element and h[2] and h[3] that of the element followed by a
+// 0x01 byte, as in https://godoc.org/github.com/willf/bloom.
 type BloomFilter struct {
-	// using https://godoc.org/github.com/willf/bloom#BloomFilter.GobEncode
+	// legacy (version 0) encoding, using
+	// https://godoc.org/github.com/willf/bloom#BloomFilter.GobEncode; also set for version 1
+	// filters until all librarians read their bits
 	Encoded []byte `protobuf:"bytes,1,opt,name=encoded,proto3" json:"encoded,omitempty"`
+	// encoding version, either 0 for the legacy encoded filter or 1 for m, k, and bits
+	Version uint32 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+	// number of bits
+	M uint64 `protobuf:"varint,3,opt,name=m" json:"m,omitempty"`
+	// number of hash functions
+	K uint32 `protobuf:"varint,4,opt,name=k" json:"k,omitempty"`
+	// ceil(m / 8) bytes of the filter, with bit i in bit i % 8 (least significant first) of byte
+	// i / 8
+	Bits []byte `protobuf:"bytes,5,opt,name=bits,proto3" json:"bits,omitempty"`
 }
 
 func (m *BloomFilter) Reset()                    { *m = BloomFilter{} }
 func (m *BloomFilter) String() string            { return proto.CompactTextString(m) }
 func (*BloomFilter) ProtoMessage()               {}
-func (*BloomFilter) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{19} }
+func (*BloomFilter) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{24} }
 
 func (m *BloomFilter) GetEncoded() []byte {
 	if m != nil {
@@ -603,6 +854,34 @@ func (m *BloomFilter) GetEncoded() []byte {
 	return nil
 }
 
+func (m *BloomFilter) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *BloomFilter) GetM() uint64 {
+	if m != nil {
+		return m.M
+	}
+	return 0
+}
+
+func (m *BloomFilter) GetK() uint32 {
+	if m != nil {
+		return m.K
+	}
+	return 0
+}
+
+func (m *BloomFilter) GetBits() []byte {
+	if m != nil {
+		return m.Bits
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*RequestMetadata)(nil), "api.RequestMetadata")
 	proto.RegisterType((*ResponseMetadata)(nil), "api.ResponseMetadata")
@@ -617,8 +896,13 @@ func init() {
 	proto.RegisterType((*StoreResponse)(nil), "api.StoreResponse")
 	proto.RegisterType((*GetRequest)(nil), "api.GetRequest")
 	proto.RegisterType((*GetResponse)(nil), "api.GetResponse")
+	proto.RegisterType((*LocateRequest)(nil), "api.LocateRequest")
+	proto.RegisterType((*LocateResponse)(nil), "api.LocateResponse")
+	proto.RegisterType((*InfoRequest)(nil), "api.InfoRequest")
+	proto.RegisterType((*InfoResponse)(nil), "api.InfoResponse")
 	proto.RegisterType((*PutRequest)(nil), "api.PutRequest")
 	proto.RegisterType((*PutResponse)(nil), "api.PutResponse")
+	proto.RegisterType((*PutChunk)(nil), "api.PutChunk")
 	proto.RegisterType((*SubscribeRequest)(nil), "api.SubscribeRequest")
 	proto.RegisterType((*SubscribeResponse)(nil), "api.SubscribeResponse")
 	proto.RegisterType((*Publication)(nil), "api.Publication")
@@ -648,8 +932,14 @@ type LibrarianClient interface {
 	Store(ctx context.Context, in *StoreRequest, opts ...grpc.CallOption) (*StoreResponse, error)
 	// Get retrieves a value, if it exists.
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Locate returns the peers storing a value without retrieving it.
+	Locate(ctx context.Context, in *LocateRequest, opts ...grpc.CallOption) (*LocateResponse, error)
+	// Info returns the librarian's public ID and routing table stats.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
 	// Put stores a value.
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
+	// PutStream stores a value streamed in chunks, which may together exceed the max message size.
+	PutStream(ctx context.Context, opts ...grpc.CallOption) (Librarian_PutStreamClient, error)
 	// Subscribe streams Publications to the client per a subscription filter.
 	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Librarian_SubscribeClient, error)
 }
@@ -707,6 +997,24 @@ func (c *librarianClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *librarianClient) Locate(ctx context.Context, in *LocateRequest, opts ...grpc.CallOption) (*LocateResponse, error) {
+	out := new(LocateResponse)
+	err := grpc.Invoke(ctx, "/api.Librarian/Locate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *librarianClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := grpc.Invoke(ctx, "/api.Librarian/Info", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *librarianClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error) {
 	out := new(PutResponse)
 	err := grpc.Invoke(ctx, "/api.Librarian/Put", in, out, c.cc, opts...)
@@ -716,8 +1024,42 @@ func (c *librarianClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *librarianClient) PutStream(ctx context.Context, opts ...grpc.CallOption) (Librarian_PutStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Librarian_serviceDesc.Streams[0], c.cc, "/api.Librarian/PutStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &librarianPutStreamClient{stream}
+	return x, nil
+}
+
+type Librarian_PutStreamClient interface {
+	Send(*PutChunk) error
+	CloseAndRecv() (*PutResponse, error)
+	grpc.ClientStream
+}
+
+type librarianPutStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *librarianPutStreamClient) Send(m *PutChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *librarianPutStreamClient) CloseAndRecv() (*PutResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PutResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *librarianClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Librarian_SubscribeClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Librarian_serviceDesc.Streams[0], c.cc, "/api.Librarian/Subscribe", opts...)
+	stream, err := grpc.NewClientStream(ctx, &_Librarian_serviceDesc.Streams[1], c.cc, "/api.Librarian/Subscribe", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -761,8 +1103,14 @@ type LibrarianServer interface {
 	Store(context.Context, *StoreRequest) (*StoreResponse, error)
 	// Get retrieves a value, if it exists.
 	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Locate returns the peers storing a value without retrieving it.
+	Locate(context.Context, *LocateRequest) (*LocateResponse, error)
+	// Info returns the librarian's public ID and routing table stats.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
 	// Put stores a value.
 	Put(context.Context, *PutRequest) (*PutResponse, error)
+	// PutStream stores a value streamed in chunks, which may together exceed the max message size.
+	PutStream(Librarian_PutStreamServer) error
 	// Subscribe streams Publications to the client per a subscription filter.
 	Subscribe(*SubscribeRequest, Librarian_SubscribeServer) error
 }
@@ -861,6 +1209,42 @@ func _Librarian_Get_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Librarian_Locate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LocateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LibrarianServer).Locate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Librarian/Locate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LibrarianServer).Locate(ctx, req.(*LocateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Librarian_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LibrarianServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Librarian/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LibrarianServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Librarian_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PutRequest)
 	if err := dec(in); err != nil {
@@ -879,6 +1263,32 @@ func _Librarian_Put_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Librarian_PutStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LibrarianServer).PutStream(&librarianPutStreamServer{stream})
+}
+
+type Librarian_PutStreamServer interface {
+	SendAndClose(*PutResponse) error
+	Recv() (*PutChunk, error)
+	grpc.ServerStream
+}
+
+type librarianPutStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *librarianPutStreamServer) SendAndClose(m *PutResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *librarianPutStreamServer) Recv() (*PutChunk, error) {
+	m := new(PutChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _Librarian_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SubscribeRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -924,12 +1334,25 @@ var _Librarian_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _Librarian_Get_Handler,
 		},
+		{
+			MethodName: "Locate",
+			Handler:    _Librarian_Locate_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _Librarian_Info_Handler,
+		},
 		{
 			MethodName: "Put",
 			Handler:    _Librarian_Put_Handler,
 		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PutStream",
+			Handler:       _Librarian_PutStream_Handler,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "Subscribe",
 			Handler:       _Librarian_Subscribe_Handler,
@@ -942,58 +1365,80 @@ var _Librarian_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("libri/librarian/api/librarian.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 841 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xbc, 0x56, 0xcd, 0x8e, 0x23, 0x35,
-	0x10, 0x9e, 0x4e, 0x32, 0xb3, 0xe9, 0xea, 0x64, 0xa7, 0x63, 0xf1, 0x13, 0x05, 0x21, 0x2d, 0x5e,
-	0xb4, 0x8c, 0x46, 0x9a, 0x1f, 0x82, 0xb8, 0xa1, 0x95, 0x58, 0xed, 0xcc, 0x28, 0xec, 0xb2, 0x1b,
-	0x75, 0xe6, 0xc0, 0x2d, 0x72, 0xd2, 0xc5, 0xd0, 0x22, 0xed, 0x36, 0xb6, 0x7b, 0xd1, 0x88, 0x0b,
-	0x37, 0x6e, 0x88, 0x03, 0xaf, 0xc0, 0x03, 0xf2, 0x06, 0xa8, 0x6d, 0xf7, 0x4f, 0x92, 0xd5, 0x08,
-	0xb2, 0x23, 0x2e, 0x51, 0x5c, 0xf5, 0xd9, 0xf5, 0x7d, 0xe5, 0x72, 0x55, 0xc3, 0xe3, 0x55, 0xb2,
-	0x90, 0xc9, 0x59, 0xf1, 0xcb, 0x64, 0xc2, 0xf8, 0x19, 0x13, 0x8d, 0xd5, 0xa9, 0x90, 0x99, 0xce,
-	0x48, 0x9b, 0x89, 0x64, 0xf4, 0x56, 0x64, 0x9c, 0x2d, 0xf3, 0x14, 0xb9, 0x56, 0x16, 0x49, 0x27,
-	0x70, 0x18, 0xe1, 0x4f, 0x39, 0x2a, 0xfd, 0x2d, 0x6a, 0x16, 0x33, 0xcd, 0xc8, 0xc7, 0x00, 0xd2,
-	0x9a, 0xe6, 0x49, 0x3c, 0xf4, 0x1e, 0x79, 0x47, 0xbd, 0xc8, 0x77, 0x96, 0x49, 0x4c, 0x3e, 0x84,
-	0x07, 0x22, 0x5f, 0xcc, 0x7f, 0xc4, 0xdb, 0x61, 0xcb, 0xf8, 0x0e, 0x44, 0xbe, 0x78, 0x81, 0xb7,
-	0xf4, 0x1b, 0x08, 0x23, 0x54, 0x22, 0xe3, 0x0a, 0xdf, 0xf9, 0xac, 0x3e, 0x04, 0xd3, 0x84, 0xdf,
-	0x38, 0x6a, 0xf4, 0x08, 0x7a, 0x76, 0x69, 0x8f, 0x27, 0x43, 0x78, 0x90, 0xa2, 0x52, 0xec, 0x06,
-	0xcd, 0x99, 0x7e, 0x54, 0x2e, 0xe9, 0x6f, 0x1e, 0x84, 0x13, 0xae, 0x65, 0x16, 0xe7, 0x4b, 0x74,
-	0xdb, 0xc9, 0x39, 0x74, 0x53, 0xc7, 0xc8, 0xe0, 0x83, 0xf1, 0x7b, 0xa7, 0x4c, 0x24, 0xa7, 0x1b,
-	0xca, 0xa3, 0x0a, 0x45, 0x3e, 0x85, 0x8e, 0xc2, 0xd5, 0xf7, 0x86, 0x55, 0x30, 0x0e, 0x0d, 0x7a,
-	0x8a, 0x28, 0xbf, 0x8e, 0x63, 0x89, 0x4a, 0x45, 0xc6, 0x4b, 0x3e, 0x02, 0x9f, 0xe7, 0xe9, 0x5c,
-	0x20, 0x4a, 0x35, 0x6c, 0x3f, 0xf2, 0x8e, 0xfa, 0x51, 0x97, 0xe7, 0x69, 0x01, 0x54, 0xf4, 0x4f,
-	0x0f, 0x06, 0x0d, 0x26, 0x8e, 0xf9, 0xe7, 0x5b, 0x54, 0xde, 0x77, 0x54, 0xd6, 0x33, 0xf7, 0x9f,
-	0xb9, 0x3c, 0x81, 0xfd, 0x92, 0x47, 0xfb, 0xad, 0x30, 0xeb, 0xa6, 0x1c, 0x82, 0xcb, 0x84, 0xc7,
-	0xbb, 0xa7, 0x26, 0x84, 0x76, 0x7d, 0x5f, 0xc5, 0xdf, 0xbb, 0xd3, 0xf0, 0xbb, 0x07, 0x3d, 0x1b,
-	0x70, 0xf7, 0x0c, 0x54, 0xda, 0x5a, 0x77, 0x6a, 0x23, 0x8f, 0x61, 0xff, 0x0d, 0x5b, 0xe5, 0x68,
-	0x48, 0x04, 0xe3, 0xbe, 0xc1, 0x3d, 0x77, 0x15, 0x1f, 0x59, 0x1f, 0xbd, 0x81, 0xa0, 0xb1, 0xd5,
-	0x94, 0x20, 0xa2, 0xac, 0xcb, 0xf3, 0xa0, 0x58, 0x4e, 0xe2, 0x42, 0x95, 0x71, 0x70, 0x96, 0xa2,
-	0x51, 0xeb, 0x47, 0xdd, 0xc2, 0xf0, 0x8a, 0xa5, 0x48, 0x1e, 0x42, 0x2b, 0x11, 0x26, 0x8c, 0x1f,
-	0xb5, 0x12, 0x41, 0x08, 0x74, 0x44, 0x26, 0xf5, 0xb0, 0x63, 0xd4, 0x9b, 0xff, 0xf4, 0x67, 0xe8,
-	0xcd, 0x74, 0x26, 0xf1, 0x3e, 0x53, 0xfd, 0xaf, 0x14, 0x3e, 0x83, 0xbe, 0x0b, 0xbc, 0x73, 0xca,
-	0xe9, 0x14, 0xe0, 0x0a, 0xf5, 0x3d, 0x52, 0xa7, 0x08, 0x81, 0x39, 0x71, 0xf7, 0x32, 0xa8, 0xc4,
-	0xb7, 0xee, 0x10, 0x9f, 0x03, 0x4c, 0x73, 0xfd, 0xbf, 0xe7, 0xfc, 0x0f, 0x0f, 0x02, 0x13, 0x77,
-	0x77, 0x79, 0x67, 0xe0, 0x67, 0x02, 0x25, 0xd3, 0x49, 0xc6, 0x4d, 0xfc, 0x87, 0xe3, 0x81, 0xad,
-	0xf4, 0x5c, 0xbf, 0x2e, 0x1d, 0x51, 0x8d, 0x29, 0x9a, 0x2b, 0x9f, 0x4b, 0x14, 0xab, 0x64, 0xc9,
-	0xca, 0x87, 0xe7, 0xf3, 0xc8, 0x19, 0xe8, 0x2f, 0x10, 0xce, 0xf2, 0x85, 0x5a, 0xca, 0x64, 0xf1,
-	0x0e, 0x35, 0xf8, 0x25, 0xf4, 0x94, 0x3d, 0x45, 0x54, 0xc4, 0x02, 0x47, 0x6c, 0xd6, 0x70, 0x44,
-	0x6b, 0x30, 0xfa, 0xab, 0x07, 0x83, 0x46, 0xf4, 0xdd, 0xb3, 0xb2, 0x7d, 0x1f, 0x4f, 0xd6, 0xef,
-	0xc3, 0x75, 0x83, 0x7c, 0x51, 0xa8, 0x36, 0x4c, 0xdc, 0x95, 0xfc, 0x65, 0xae, 0xa4, 0x32, 0x93,
-	0x4f, 0xa0, 0x87, 0xfc, 0x0d, 0xae, 0x32, 0x81, 0x66, 0xe2, 0xd8, 0xe7, 0x1e, 0x94, 0xb6, 0x17,
-	0xb6, 0x93, 0x21, 0xd7, 0xf2, 0xb6, 0x31, 0x91, 0xba, 0xc6, 0x50, 0x38, 0x8f, 0x61, 0xc0, 0x72,
-	0xfd, 0x43, 0x26, 0xe7, 0xc2, 0x9c, 0x6a, 0x40, 0x6d, 0x03, 0x3a, 0xb4, 0x0e, 0x1b, 0xcd, 0x61,
-	0x25, 0xb2, 0x18, 0xd7, 0xb0, 0x1d, 0x8b, 0xb5, 0x8e, 0x0a, 0x6b, 0x3a, 0x64, 0x33, 0x93, 0xe4,
-	0x29, 0x90, 0xad, 0x40, 0xca, 0xe5, 0xcb, 0xaa, 0x7d, 0xb6, 0xca, 0xb2, 0xf4, 0x32, 0x59, 0x69,
-	0x94, 0x51, 0xb8, 0x11, 0x5b, 0x15, 0xfb, 0xb7, 0x82, 0xab, 0xb5, 0xf1, 0xb1, 0xb6, 0x7f, 0x83,
-	0x8f, 0xa2, 0x9f, 0x41, 0xd0, 0x00, 0x14, 0xc3, 0x16, 0xf9, 0x32, 0x8b, 0xb1, 0xec, 0x90, 0xe5,
-	0xf2, 0xf8, 0x04, 0x7a, 0xcd, 0xda, 0x24, 0x00, 0x07, 0xb3, 0xeb, 0xd7, 0xd1, 0xc5, 0xf3, 0x70,
-	0x8f, 0x0c, 0xa0, 0xff, 0xf2, 0xe2, 0xf2, 0x7a, 0x7e, 0xf1, 0xdd, 0x64, 0x76, 0x3d, 0x79, 0x75,
-	0x15, 0x7a, 0xe3, 0xbf, 0x5b, 0xe0, 0xbf, 0x2c, 0xbf, 0x46, 0xc8, 0x09, 0x74, 0x8a, 0x99, 0x4e,
-	0xdc, 0xfd, 0xd5, 0xd3, 0x7e, 0x34, 0x68, 0x58, 0x6c, 0x5d, 0xd0, 0x3d, 0xf2, 0x15, 0xf8, 0xd5,
-	0x34, 0x25, 0xb6, 0x6a, 0x36, 0xe7, 0xfc, 0xe8, 0x83, 0x4d, 0x73, 0xb5, 0xfb, 0x04, 0x3a, 0xc5,
-	0x10, 0x72, 0xc1, 0x1a, 0x03, 0xd0, 0x05, 0x6b, 0x4e, 0x28, 0xba, 0x47, 0xce, 0x61, 0xdf, 0x74,
-	0x50, 0xe2, 0xea, 0xbc, 0xd1, 0xc6, 0x47, 0xa4, 0x69, 0xaa, 0x76, 0x1c, 0x43, 0xfb, 0x0a, 0x35,
-	0x39, 0x34, 0xce, 0xba, 0x73, 0x8e, 0xc2, 0xda, 0xd0, 0xc4, 0x4e, 0xf3, 0x12, 0x5b, 0x37, 0xab,
-	0x51, 0x58, 0x1b, 0x2a, 0xec, 0x53, 0xf0, 0xab, 0x67, 0xe4, 0x64, 0x6f, 0x3e, 0x6a, 0x27, 0x7b,
-	0xeb, 0xb5, 0xd1, 0xbd, 0x73, 0x6f, 0x71, 0x60, 0x3e, 0xf3, 0xbe, 0xf8, 0x27, 0x00, 0x00, 0xff,
-	0xff, 0x29, 0xb8, 0x98, 0x95, 0x37, 0x0a, 0x00, 0x00,
+	// 1186 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xbd, 0x57, 0x4b, 0x6f, 0x23, 0x45,
+	0x10, 0xce, 0xc4, 0x8f, 0x78, 0xca, 0x76, 0x32, 0x6e, 0x60, 0x89, 0x8c, 0x10, 0xd0, 0x8b, 0x56,
+	0x21, 0xd2, 0x26, 0x21, 0x2b, 0x6e, 0x08, 0x44, 0x76, 0x93, 0x95, 0xd9, 0x65, 0x13, 0xb5, 0xa3,
+	0x15, 0xb7, 0xd1, 0x78, 0xa6, 0x37, 0x19, 0xd9, 0xf3, 0xd8, 0x79, 0x04, 0x22, 0x2e, 0xdc, 0x56,
+	0xe2, 0xc0, 0x89, 0x13, 0x27, 0x2e, 0x9c, 0xf8, 0x07, 0xf0, 0x43, 0xf8, 0x3b, 0x54, 0x3f, 0x66,
+	0x3c, 0x9e, 0x84, 0x08, 0xbc, 0x81, 0x8b, 0x35, 0xf5, 0x55, 0x75, 0xf7, 0x57, 0xaf, 0xee, 0x32,
+	0xdc, 0x9d, 0xf9, 0x93, 0xc4, 0xdf, 0x15, 0xbf, 0x4e, 0xe2, 0x3b, 0xe1, 0xae, 0x13, 0x57, 0xa4,
+	0x9d, 0x38, 0x89, 0xb2, 0x88, 0x34, 0x10, 0x1c, 0x5e, 0x6b, 0xe9, 0x45, 0x6e, 0x1e, 0xf0, 0x30,
+	0x4b, 0x95, 0x25, 0x1d, 0xc1, 0x06, 0xe3, 0x2f, 0x73, 0x9e, 0x66, 0x5f, 0xf1, 0xcc, 0xf1, 0x9c,
+	0xcc, 0x21, 0xef, 0x02, 0x24, 0x0a, 0xb2, 0x7d, 0x6f, 0xd3, 0x78, 0xdf, 0xd8, 0xea, 0x31, 0x53,
+	0x23, 0x23, 0x8f, 0xbc, 0x0d, 0x6b, 0x71, 0x3e, 0xb1, 0xa7, 0xfc, 0x72, 0x73, 0x55, 0xea, 0xda,
+	0x28, 0x3e, 0xe1, 0x97, 0xf4, 0x4b, 0xb0, 0x18, 0x4f, 0xe3, 0x28, 0x4c, 0xf9, 0x6b, 0xef, 0xd5,
+	0x87, 0xee, 0x89, 0x1f, 0x9e, 0x69, 0x6a, 0x74, 0x0b, 0x7a, 0x4a, 0x54, 0xdb, 0x93, 0x4d, 0x58,
+	0x0b, 0x78, 0x9a, 0x3a, 0x67, 0x5c, 0xee, 0x69, 0xb2, 0x42, 0xa4, 0xaf, 0x0c, 0xb0, 0x46, 0x61,
+	0x96, 0x44, 0x5e, 0xee, 0x72, 0xbd, 0x9c, 0xec, 0x41, 0x27, 0xd0, 0x8c, 0xa4, 0x7d, 0x77, 0xff,
+	0xcd, 0x1d, 0x0c, 0xc6, 0x4e, 0xcd, 0x73, 0x56, 0x5a, 0x91, 0x0f, 0xa1, 0x99, 0xf2, 0xd9, 0x0b,
+	0xc9, 0xaa, 0xbb, 0x6f, 0x49, 0xeb, 0x13, 0xce, 0x93, 0x2f, 0x3c, 0x2f, 0xc1, 0x93, 0x98, 0xd4,
+	0x92, 0x77, 0xc0, 0x0c, 0xf3, 0xc0, 0x8e, 0x51, 0x91, 0x6e, 0x36, 0xd0, 0xb4, 0xcf, 0x3a, 0x08,
+	0x08, 0xc3, 0x94, 0xfe, 0x64, 0xc0, 0xa0, 0xc2, 0x44, 0x33, 0xff, 0xf8, 0x0a, 0x95, 0xb7, 0x34,
+	0x95, 0xc5, 0xc8, 0xfd, 0x6b, 0x2e, 0xf7, 0xa0, 0x55, 0xf0, 0x68, 0x5c, 0x6b, 0xa6, 0xd4, 0xf4,
+	0x07, 0x03, 0xba, 0x47, 0x7e, 0xe8, 0x2d, 0x1f, 0x1b, 0x0b, 0x1a, 0xf3, 0x84, 0x89, 0xcf, 0x1b,
+	0xe3, 0x40, 0x86, 0xd0, 0x89, 0x91, 0x00, 0x0f, 0x5d, 0xbe, 0xd9, 0x44, 0x5d, 0x87, 0x95, 0x32,
+	0xfd, 0xcd, 0x80, 0x9e, 0x22, 0xb3, 0x7c, 0x78, 0x4a, 0xc7, 0x57, 0x6f, 0x74, 0x9c, 0xdc, 0x85,
+	0xd6, 0x85, 0x33, 0xcb, 0xb9, 0x24, 0xd8, 0xdd, 0xef, 0x4b, 0xbb, 0x47, 0xba, 0x1d, 0x98, 0xd2,
+	0x09, 0x4f, 0xce, 0x9d, 0xd4, 0x56, 0x86, 0x9a, 0x2d, 0x02, 0xcf, 0x85, 0x4c, 0xcf, 0xb0, 0x28,
+	0xe7, 0xfb, 0xca, 0xe2, 0x45, 0x71, 0x5e, 0xd8, 0x6d, 0x21, 0x62, 0x55, 0xe3, 0x26, 0x52, 0x11,
+	0x3a, 0x01, 0x97, 0x61, 0x32, 0xd1, 0x65, 0x04, 0x9e, 0xa1, 0x4c, 0xd6, 0x61, 0xd5, 0x8f, 0x25,
+	0x07, 0x93, 0xe1, 0x17, 0x21, 0xd0, 0x8c, 0xa3, 0x24, 0x93, 0x87, 0xf5, 0x99, 0xfc, 0xa6, 0xdf,
+	0x40, 0x6f, 0x9c, 0x45, 0x09, 0xbf, 0xcd, 0x1c, 0xfd, 0x13, 0xf7, 0xe9, 0x01, 0xf4, 0xf5, 0xc1,
+	0x4b, 0xe7, 0x83, 0xfe, 0x62, 0x00, 0x3c, 0xe6, 0xd9, 0x6d, 0x72, 0xbf, 0x0f, 0x24, 0xe5, 0x4e,
+	0xe2, 0x9e, 0xdb, 0x6e, 0x14, 0xba, 0x79, 0x92, 0x60, 0xf1, 0x5c, 0xea, 0x42, 0x1b, 0x28, 0xcd,
+	0xc3, 0xb9, 0x82, 0xbc, 0x07, 0x5d, 0xfe, 0xad, 0x9f, 0x66, 0xa9, 0x1d, 0x85, 0xb3, 0x4b, 0x9d,
+	0x46, 0x50, 0xd0, 0x31, 0x22, 0xf4, 0x67, 0xec, 0x01, 0x49, 0x71, 0xf9, 0xaa, 0x2b, 0xc3, 0xb9,
+	0x7a, 0x43, 0x35, 0xdd, 0x81, 0xb6, 0x3a, 0x55, 0x72, 0xed, 0x30, 0x2d, 0x89, 0x02, 0xc9, 0xfc,
+	0x80, 0x7b, 0x76, 0x94, 0x67, 0x45, 0x95, 0x49, 0xe0, 0x38, 0xcf, 0xe8, 0x18, 0xfa, 0x4f, 0x23,
+	0xd7, 0xc9, 0x6e, 0x33, 0xfb, 0x74, 0x0a, 0xeb, 0xc5, 0xa6, 0xff, 0x79, 0xa7, 0xd1, 0xcf, 0xa1,
+	0x3b, 0x0a, 0x5f, 0x44, 0x4b, 0xf3, 0xa7, 0x7f, 0xe0, 0xb5, 0xa0, 0x76, 0x58, 0x9e, 0x6c, 0xa5,
+	0x3b, 0x57, 0xeb, 0xdd, 0xf9, 0xf7, 0x97, 0x15, 0x96, 0x8e, 0x50, 0x4e, 0x72, 0x77, 0xca, 0x31,
+	0x6d, 0xaa, 0x29, 0x01, 0xa1, 0x03, 0x85, 0x90, 0x0f, 0xa0, 0xa7, 0x94, 0x7a, 0x83, 0x16, 0x86,
+	0xa2, 0xcf, 0xba, 0x0a, 0x53, 0x17, 0xff, 0xef, 0xd8, 0x00, 0x27, 0x79, 0xf6, 0x7f, 0x37, 0xaf,
+	0x78, 0x6b, 0x43, 0x3b, 0xe1, 0xf1, 0xcc, 0x77, 0x9d, 0x82, 0xba, 0x19, 0x32, 0x0d, 0xe0, 0x33,
+	0xb2, 0x1e, 0xf8, 0xa1, 0x5d, 0x31, 0x69, 0x49, 0x93, 0x1e, 0xa2, 0xcf, 0x0a, 0x2b, 0xfa, 0x27,
+	0xb6, 0x86, 0x24, 0xbf, 0x7c, 0xe4, 0x77, 0xc1, 0x8c, 0x62, 0x9e, 0x38, 0x99, 0x1f, 0x85, 0xd2,
+	0x89, 0xf5, 0xfd, 0x81, 0x2a, 0x95, 0x3c, 0x3b, 0x2e, 0x14, 0x6c, 0x6e, 0x53, 0x23, 0xde, 0xa8,
+	0x13, 0xdf, 0x02, 0x4b, 0x2b, 0x6d, 0x9d, 0x51, 0xe1, 0x5d, 0x03, 0x63, 0xb3, 0xae, 0xf1, 0x13,
+	0x99, 0xd9, 0x5a, 0x5f, 0xb5, 0x6a, 0x7d, 0xf5, 0x1c, 0x3a, 0x48, 0xe0, 0xe1, 0x79, 0x1e, 0x4e,
+	0xc9, 0x47, 0xb0, 0xa6, 0x87, 0x10, 0xed, 0xd4, 0x46, 0x41, 0x50, 0x67, 0x85, 0x15, 0x7a, 0x51,
+	0x11, 0x32, 0xbc, 0xb6, 0x2b, 0x56, 0xea, 0xa4, 0x80, 0x84, 0xe4, 0x5e, 0xf4, 0x3b, 0xb0, 0xc6,
+	0xf9, 0x24, 0x75, 0x13, 0x7f, 0xf2, 0x1a, 0x2d, 0xfb, 0x09, 0xf4, 0x52, 0xb5, 0x4b, 0x5c, 0xc6,
+	0xad, 0xab, 0xe3, 0x36, 0xae, 0x28, 0xd8, 0x82, 0x19, 0xfd, 0x1e, 0x87, 0x8c, 0xca, 0xe9, 0xcb,
+	0x27, 0xed, 0x6a, 0xcd, 0xdd, 0x5b, 0xac, 0x39, 0xdd, 0xed, 0xf9, 0x44, 0x04, 0x5c, 0x32, 0xd1,
+	0x6f, 0xc6, 0xaf, 0xb2, 0x62, 0x4a, 0x58, 0x74, 0x08, 0x0f, 0x2f, 0xf8, 0x0c, 0xf3, 0x2b, 0x07,
+	0x3b, 0xf5, 0x36, 0x76, 0x0b, 0xec, 0x89, 0x9a, 0x17, 0xb0, 0x6e, 0x93, 0xcb, 0xca, 0xe0, 0xd7,
+	0x91, 0x80, 0x50, 0x6e, 0xc3, 0xc0, 0xc9, 0xb3, 0xf3, 0x28, 0xb1, 0x63, 0xb9, 0xab, 0x34, 0x6a,
+	0x48, 0xa3, 0x0d, 0xa5, 0x50, 0xa7, 0x69, 0xdb, 0x84, 0x3b, 0x1e, 0x5f, 0xb0, 0x6d, 0x2a, 0x5b,
+	0xa5, 0x28, 0x6d, 0xe9, 0x8f, 0x78, 0xa9, 0x54, 0x23, 0x49, 0x3e, 0x03, 0x72, 0xe5, 0xa0, 0x54,
+	0xc7, 0x4b, 0x79, 0x7b, 0x30, 0x8b, 0xa2, 0xe0, 0xc8, 0x9f, 0x65, 0x3c, 0x61, 0x56, 0xed, 0xec,
+	0x54, 0xac, 0xbf, 0x72, 0x78, 0xba, 0x30, 0xa5, 0x2d, 0xac, 0xaf, 0xf1, 0x49, 0xe9, 0x4b, 0xe8,
+	0x56, 0x0c, 0xc4, 0x4c, 0x8b, 0xaf, 0x57, 0xe4, 0xf1, 0x62, 0x9c, 0x28, 0x44, 0xa1, 0xb9, 0xc0,
+	0x8b, 0xa5, 0x28, 0x8b, 0x3e, 0x2b, 0x44, 0xd2, 0x03, 0x23, 0x90, 0xb1, 0x69, 0x32, 0x23, 0x10,
+	0xd2, 0x54, 0xf7, 0xbd, 0x31, 0x15, 0x83, 0xc5, 0xc4, 0xcf, 0x54, 0x97, 0xf7, 0x98, 0xfc, 0xde,
+	0xbe, 0x8f, 0x73, 0x74, 0xa5, 0x09, 0x09, 0x40, 0x7b, 0x7c, 0x7a, 0xcc, 0x0e, 0x1f, 0x59, 0x2b,
+	0x64, 0x80, 0xef, 0xce, 0xe1, 0xd1, 0xa9, 0x7d, 0xf8, 0xf5, 0x68, 0x7c, 0x3a, 0x7a, 0xf6, 0xd8,
+	0x32, 0xf6, 0x5f, 0x35, 0xc1, 0x7c, 0x5a, 0xfc, 0x7d, 0xc0, 0x57, 0xb8, 0x29, 0x86, 0x70, 0xa2,
+	0x2b, 0x61, 0x3e, 0x9e, 0x0f, 0x07, 0x15, 0x44, 0x55, 0x18, 0x5d, 0x21, 0x9f, 0x82, 0x59, 0x8e,
+	0xbf, 0x44, 0xd5, 0x5f, 0x7d, 0x30, 0x1f, 0xde, 0xa9, 0xc3, 0xe5, 0x6a, 0x3c, 0x4c, 0x0c, 0x86,
+	0xfa, 0xb0, 0xca, 0xc0, 0xaa, 0x0f, 0xab, 0x4e, 0x8d, 0x68, 0xbe, 0x07, 0x2d, 0x39, 0xb8, 0x10,
+	0xdd, 0x31, 0x95, 0xe9, 0x69, 0x48, 0xaa, 0x50, 0xb9, 0x62, 0x1b, 0x1a, 0x38, 0x02, 0x10, 0xd5,
+	0xf8, 0xf3, 0x79, 0x65, 0x68, 0xcd, 0x81, 0xd2, 0xf6, 0x01, 0xb4, 0xd5, 0xeb, 0x49, 0xd4, 0x5e,
+	0x0b, 0xef, 0xf3, 0xf0, 0x8d, 0x05, 0xac, 0xea, 0x81, 0x78, 0xc3, 0xb4, 0x07, 0x95, 0x07, 0x51,
+	0x7b, 0x50, 0x7d, 0xe0, 0x14, 0x1f, 0x4c, 0x0d, 0xa9, 0x5f, 0x44, 0x43, 0x6b, 0x0e, 0x54, 0xbc,
+	0x35, 0x11, 0x18, 0x67, 0x58, 0x52, 0x01, 0xe9, 0x17, 0x06, 0xf2, 0x3a, 0xba, 0xce, 0x7e, 0xcb,
+	0xc0, 0x5a, 0x35, 0xcb, 0x6b, 0x42, 0x27, 0xa3, 0x7e, 0x69, 0xe9, 0x64, 0x5c, 0xb9, 0x4d, 0xe8,
+	0xca, 0x9e, 0x31, 0x69, 0xcb, 0x7f, 0x8b, 0x0f, 0xfe, 0x02, 0x75, 0x86, 0x34, 0x27, 0x7e, 0x0e,
+	0x00, 0x00,
 }
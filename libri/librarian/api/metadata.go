@@ -35,6 +35,18 @@ const (
 	// MetadataEntrySchema indicates the schema (however defined) of the data contained in the
 	// entry.
 	MetadataEntrySchema = metadataEntryPrefix + "schema"
+
+	// MetadataEntryCompressionCodec indicates the compression codec applied to the entry
+	// content before encryption. When absent, the codec is implied by the media type.
+	MetadataEntryCompressionCodec = metadataEntryPrefix + "compression_codec"
+
+	// MetadataEntryContentHash indicates the SHA-256 hash of the entire uncompressed entry.
+	MetadataEntryContentHash = metadataEntryPrefix + "content_hash"
+
+	// MetadataEntryPageBinding indicates the random value each page ciphertext of the entry is
+	// bound to (with its page index) as associated data. When absent, pages have no associated
+	// data.
+	MetadataEntryPageBinding = metadataEntryPrefix + "page_binding"
 )
 
 var (
@@ -110,6 +122,21 @@ func (m *Metadata) GetUncompressedMAC() ([]byte, bool) {
 	return m.GetBytes(MetadataEntryUncompressedMAC)
 }
 
+// GetCompressionCodec returns the compression codec.
+func (m *Metadata) GetCompressionCodec() (string, bool) {
+	return m.GetString(MetadataEntryCompressionCodec)
+}
+
+// GetContentHash returns the SHA-256 hash of the uncompressed data.
+func (m *Metadata) GetContentHash() ([]byte, bool) {
+	return m.GetBytes(MetadataEntryContentHash)
+}
+
+// GetPageBinding returns the random value the entry's page ciphertexts are bound to.
+func (m *Metadata) GetPageBinding() ([]byte, bool) {
+	return m.GetBytes(MetadataEntryPageBinding)
+}
+
 // GetBytes returns the byte slice value for a given key.
 func (m *Metadata) GetBytes(key string) ([]byte, bool) {
 	value, in := m.Properties[key]
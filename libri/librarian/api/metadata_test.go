@@ -90,6 +90,56 @@ func TestMetadata_GetUncompressedMAC(t *testing.T) {
 	assert.True(t, in)
 }
 
+func TestMetadata_GetCompressionCodec(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	m, err := NewEntryMetadata("application/x-pdf", 1, RandBytes(rng, 32), 2,
+		RandBytes(rng, 32))
+	assert.Nil(t, err)
+
+	// check optional codec absent by default
+	_, in := m.GetCompressionCodec()
+	assert.False(t, in)
+
+	m.SetString(MetadataEntryCompressionCodec, "none")
+	value, in := m.GetCompressionCodec()
+	assert.Equal(t, "none", value)
+	assert.True(t, in)
+}
+
+func TestMetadata_GetContentHash(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	m, err := NewEntryMetadata("application/x-pdf", 1, RandBytes(rng, 32), 2,
+		RandBytes(rng, 32))
+	assert.Nil(t, err)
+
+	// check optional hash absent by default
+	_, in := m.GetContentHash()
+	assert.False(t, in)
+
+	hash := RandBytes(rng, 32)
+	m.SetBytes(MetadataEntryContentHash, hash)
+	value, in := m.GetContentHash()
+	assert.Equal(t, hash, value)
+	assert.True(t, in)
+}
+
+func TestMetadata_GetPageBinding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	m, err := NewEntryMetadata("application/x-pdf", 1, RandBytes(rng, 32), 2,
+		RandBytes(rng, 32))
+	assert.Nil(t, err)
+
+	// check optional page binding absent by default
+	_, in := m.GetPageBinding()
+	assert.False(t, in)
+
+	binding := RandBytes(rng, 32)
+	m.SetBytes(MetadataEntryPageBinding, binding)
+	value, in := m.GetPageBinding()
+	assert.Equal(t, binding, value)
+	assert.True(t, in)
+}
+
 func TestSetGetBytes(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	mediaType := "application/x-pdf"
@@ -2,6 +2,16 @@ package api
 
 import "errors"
 
+const (
+	// BloomFilterVersionGob is the legacy BloomFilter encoding version, whose filter is gob
+	// encoded in Encoded.
+	BloomFilterVersionGob uint32 = 0
+
+	// BloomFilterVersionBits is the BloomFilter encoding version whose filter is given
+	// explicitly by M, K, and Bits.
+	BloomFilterVersionBits uint32 = 1
+)
+
 // ErrEmptySubscriptionFilters indicates when a *Subscription has an empty author or reader public
 // key filter.
 var ErrEmptySubscriptionFilters = errors.New("subscription has empty filters")
@@ -9,18 +19,46 @@ var ErrEmptySubscriptionFilters = errors.New("subscription has empty filters")
 // ErrUnexpectedNilValue indicates when a value is unexpectedly nil.
 var ErrUnexpectedNilValue = errors.New("unexpected nil value")
 
+// ErrUnknownBloomFilterVersion indicates when a *BloomFilter has an unknown encoding version.
+var ErrUnknownBloomFilterVersion = errors.New("unknown bloom filter version")
+
+// ErrInvalidBloomFilterBits indicates when a *BloomFilter's bits are inconsistent with its number
+// of bits or hash functions.
+var ErrInvalidBloomFilterBits = errors.New("invalid bloom filter bits")
+
 // ValidateSubscription validates that a subscription is not missing any required fields. It returns
 // nil if the subscription is valid.
 func ValidateSubscription(s *Subscription) error {
 	if s == nil {
 		return ErrUnexpectedNilValue
 	}
-	if s.AuthorPublicKeys == nil || s.AuthorPublicKeys.Encoded == nil {
-		return ErrEmptySubscriptionFilters
+	if err := ValidateBloomFilter(s.AuthorPublicKeys); err != nil {
+		return err
 	}
-	if s.ReaderPublicKeys == nil || s.ReaderPublicKeys.Encoded == nil {
+	return ValidateBloomFilter(s.ReaderPublicKeys)
+}
+
+// ValidateBloomFilter validates that a Bloom filter has the fields required by its encoding
+// version. It returns nil if the filter is valid.
+func ValidateBloomFilter(f *BloomFilter) error {
+	if f == nil {
 		return ErrEmptySubscriptionFilters
 	}
+	switch f.Version {
+	case BloomFilterVersionGob:
+		if f.Encoded == nil {
+			return ErrEmptySubscriptionFilters
+		}
+	case BloomFilterVersionBits:
+		if f.Bits == nil {
+			return ErrEmptySubscriptionFilters
+		}
+		if f.M == 0 || f.K == 0 || uint64(len(f.Bits)) != (f.M+7)/8 {
+			return ErrInvalidBloomFilterBits
+		}
+	default:
+		return ErrUnknownBloomFilterVersion
+	}
 	return nil
 }
 
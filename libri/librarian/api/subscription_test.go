@@ -70,6 +70,27 @@ func TestValidateSubscription(t *testing.T) {
 	}
 }
 
+func TestValidateBloomFilter(t *testing.T) {
+	v1 := BloomFilterVersionBits
+	cases := []struct {
+		f        *BloomFilter
+		expected error
+	}{
+		{&BloomFilter{Encoded: []byte{1, 2, 3}}, nil},
+		{&BloomFilter{Version: v1, M: 9, K: 2, Bits: []byte{1, 2}}, nil},
+		{nil, ErrEmptySubscriptionFilters},
+		{&BloomFilter{Encoded: nil}, ErrEmptySubscriptionFilters},
+		{&BloomFilter{Version: v1, M: 8, K: 2}, ErrEmptySubscriptionFilters},
+		{&BloomFilter{Version: v1, M: 0, K: 2, Bits: []byte{}}, ErrInvalidBloomFilterBits},
+		{&BloomFilter{Version: v1, M: 8, K: 0, Bits: []byte{1}}, ErrInvalidBloomFilterBits},
+		{&BloomFilter{Version: v1, M: 9, K: 2, Bits: []byte{1}}, ErrInvalidBloomFilterBits},
+		{&BloomFilter{Version: 2, Encoded: []byte{1, 2, 3}}, ErrUnknownBloomFilterVersion},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.expected, ValidateBloomFilter(c.f), fmt.Sprintf("i: %d", i))
+	}
+}
+
 func TestValidatePublication_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	p := NewTestPublication(rng)
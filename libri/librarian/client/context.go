@@ -43,13 +43,20 @@ func FromSignatureContext(ctx context.Context) (string, error) {
 	return signedJWTs[0], nil
 }
 
-// NewSignedContext creates a new context with a request signature.
+// NewSignedContext creates a new context with a request signature, which expires after the
+// DefaultSignatureLifetime. It suits requests like subscriptions whose signature is only checked
+// when they begin.
 func NewSignedContext(signer Signer, request proto.Message) (context.Context, error) {
+	return newSignedContext(context.Background(), signer, request,
+		time.Now().Add(DefaultSignatureLifetime))
+}
 
-	ctx := context.Background()
+func newSignedContext(
+	ctx context.Context, signer Signer, request proto.Message, notAfter time.Time,
+) (context.Context, error) {
 
 	// sign the message
-	signedJWT, err := signer.Sign(request)
+	signedJWT, err := signer.SignUntil(request, notAfter)
 	if err != nil {
 		return nil, err
 	}
@@ -57,11 +64,22 @@ func NewSignedContext(signer Signer, request proto.Message) (context.Context, er
 	return ctx, nil
 }
 
-// NewSignedTimeoutContext creates a new context with a timeout and request signature.
+// NewSignedTimeoutContext creates a new context with a timeout and request signature, which
+// expires after the timeout.
 func NewSignedTimeoutContext(signer Signer, request proto.Message, timeout time.Duration) (
 	context.Context, context.CancelFunc, error) {
 
-	ctx, err := NewSignedContext(signer, request)
+	return NewSignedParentTimeoutContext(context.Background(), signer, request, timeout)
+}
+
+// NewSignedParentTimeoutContext creates a new context with a timeout and request signature from
+// a parent context, so it ends at the earlier of the timeout and the parent's deadline. The
+// signature expires after the timeout.
+func NewSignedParentTimeoutContext(
+	parent context.Context, signer Signer, request proto.Message, timeout time.Duration,
+) (context.Context, context.CancelFunc, error) {
+
+	ctx, err := newSignedContext(parent, signer, request, time.Now().Add(timeout))
 	if err != nil {
 		return nil, func() {}, err
 	}
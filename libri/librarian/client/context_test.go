@@ -9,6 +9,7 @@ import (
 
 	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/metadata"
 )
@@ -73,3 +74,69 @@ func TestNewSignedTimeoutContext_err(t *testing.T) {
 	assert.NotNil(t, cancel)
 	assert.NotNil(t, err)
 }
+
+func TestNewSignedParentTimeoutContext(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	rq := NewFindRequest(ecid.NewPseudoRandom(rng), cid.NewPseudoRandom(rng), 20)
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Second)
+	defer parentCancel()
+
+	// check parent's earlier deadline is kept
+	ctx, cancel, err := NewSignedParentTimeoutContext(parent, &TestNoOpSigner{}, rq,
+		5*time.Second)
+	assert.Nil(t, err)
+	assert.NotNil(t, cancel)
+	parentDeadline, _ := parent.Deadline()
+	deadline, in := ctx.Deadline()
+	assert.True(t, in)
+	assert.Equal(t, parentDeadline, deadline)
+	md, in := metadata.FromOutgoingContext(ctx)
+	assert.True(t, in)
+	assert.NotNil(t, md[signatureKey])
+
+	// check context ends when parent is canceled
+	parentCancel()
+	assert.NotNil(t, ctx.Err())
+	cancel()
+
+	ctx, cancel, err = NewSignedParentTimeoutContext(parent, &TestErrSigner{}, rq, time.Second)
+	assert.Nil(t, ctx)
+	assert.NotNil(t, cancel)
+	assert.NotNil(t, err)
+}
+
+func TestNewSignedTimeoutContext_notAfter(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	ctx, cancel, err := NewSignedTimeoutContext(NewSigner(peerID.Key()), rq, time.Minute)
+	defer cancel()
+	assert.Nil(t, err)
+
+	// check signature expires after timeout
+	md, _ := metadata.FromOutgoingContext(ctx)
+	verifier := NewVerifier().(*schemeVerifier)
+	verifier.now = func() time.Time { return time.Now().Add(time.Minute - MaxClockSkew) }
+	assert.Nil(t, verifier.Verify(md[signatureKey][0], peerID.PublicKeyBytes(), rq))
+	verifier.now = func() time.Time { return time.Now().Add(time.Minute + 2*MaxClockSkew) }
+	assert.Equal(t, ErrSignatureExpired,
+		verifier.Verify(md[signatureKey][0], peerID.PublicKeyBytes(), rq))
+}
+
+func TestNewSignedContext_notAfter(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewSubscribeRequest(peerID, &api.Subscription{})
+	ctx, err := NewSignedContext(NewSigner(peerID.Key()), rq)
+	assert.Nil(t, err)
+
+	// check signature expires after the default lifetime
+	md, _ := metadata.FromOutgoingContext(ctx)
+	verifier := NewExpiringVerifier(DefaultSignatureLifetime, ECDSAScheme).(*schemeVerifier)
+	assert.Nil(t, verifier.Verify(md[signatureKey][0], peerID.PublicKeyBytes(), rq))
+	verifier.now = func() time.Time {
+		return time.Now().Add(DefaultSignatureLifetime + 2*MaxClockSkew)
+	}
+	assert.Equal(t, ErrSignatureExpired,
+		verifier.Verify(md[signatureKey][0], peerID.PublicKeyBytes(), rq))
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// DefaultReplayCacheSize is the default maximum number of recently seen request IDs a
+// ReplayCache remembers.
+const DefaultReplayCacheSize = uint(1 << 16)
+
+// ReplayCache remembers (at most) size recently seen request IDs, each for the replay window, so
+// its memory is bounded no matter the request rate. Only expired IDs are ever evicted, so when
+// it is full of unexpired IDs it fails closed, rejecting new requests until the oldest expire. It
+// is concurrency safe, so one ReplayCache may be shared by everything verifying requests for the
+// same server.
+type ReplayCache struct {
+	seen   *simplelru.LRU
+	size   int
+	window time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewReplayCache creates a new *ReplayCache remembering at most size request IDs, each for the
+// given replay window.
+func NewReplayCache(size uint, window time.Duration) (*ReplayCache, error) {
+	seen, err := simplelru.NewLRU(int(size), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayCache{
+		seen:   seen,
+		size:   int(size),
+		window: window,
+		now:    time.Now,
+	}, nil
+}
+
+// Add records the request ID as seen now, returning ErrReplayedRequest if it has already been
+// seen within the replay window or ErrReplayCacheFull if there is no room to remember it without
+// forgetting an ID still within the replay window.
+func (c *ReplayCache) Add(requestID []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, now := string(requestID), c.now()
+	if value, in := c.seen.Peek(key); in {
+		if now.Sub(value.(time.Time)) <= c.window {
+			return ErrReplayedRequest
+		}
+		c.seen.Remove(key)
+	}
+
+	// IDs are only ever added, never touched, so the oldest is also the earliest seen
+	for c.seen.Len() >= c.size {
+		_, value, _ := c.seen.GetOldest()
+		if now.Sub(value.(time.Time)) <= c.window {
+			return ErrReplayCacheFull
+		}
+		c.seen.RemoveOldest()
+	}
+	c.seen.Add(key, now)
+	return nil
+}
+
+// Len returns the number of request IDs remembered.
+func (c *ReplayCache) Len() int {
+	return c.seen.Len()
+}
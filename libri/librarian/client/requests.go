@@ -57,6 +57,21 @@ func NewGetRequest(peerID ecid.ID, key cid.ID) *api.GetRequest {
 	}
 }
 
+// NewLocateRequest creates a LocateRequest object.
+func NewLocateRequest(peerID ecid.ID, key cid.ID) *api.LocateRequest {
+	return &api.LocateRequest{
+		Metadata: NewRequestMetadata(peerID),
+		Key:      key.Bytes(),
+	}
+}
+
+// NewInfoRequest creates an InfoRequest object.
+func NewInfoRequest(peerID ecid.ID) *api.InfoRequest {
+	return &api.InfoRequest{
+		Metadata: NewRequestMetadata(peerID),
+	}
+}
+
 // NewPutRequest creates a PutRequest object.
 func NewPutRequest(peerID ecid.ID, key cid.ID, value *api.Document) *api.PutRequest {
 	return &api.PutRequest{
@@ -59,6 +59,20 @@ func TestNewGetRequest(t *testing.T) {
 	assert.Equal(t, key.Bytes(), rq.Key)
 }
 
+func TestNewLocateRequest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID, key := ecid.NewPseudoRandom(rng), cid.NewPseudoRandom(rng)
+	rq := NewLocateRequest(peerID, key)
+	assert.NotNil(t, rq.Metadata)
+	assert.Equal(t, key.Bytes(), rq.Key)
+}
+
+func TestNewInfoRequest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rq := NewInfoRequest(ecid.NewPseudoRandom(rng))
+	assert.NotNil(t, rq.Metadata)
+}
+
 func TestNewPutRequest(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	peerID := ecid.NewPseudoRandom(rng)
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"crypto"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/drausin/libri/libri/common/ecid"
+)
+
+// SigningScheme is an algorithm for signing and verifying messages, which lets the signature
+// algorithm change without changing Signer and Verifier call sites. Verifiers give a scheme the
+// sender's public key from its peer ID, which the scheme parses into its own key type.
+type SigningScheme interface {
+	// ID identifies the scheme in the header of each token it signs, so verifiers can dispatch
+	// to the scheme that made the signature.
+	ID() string
+
+	// PublicKey parses the sender's public key bytes into the key type Verify takes.
+	PublicKey(pubKeyBytes []byte) (crypto.PublicKey, error)
+
+	// Sign returns the encoded signature on the signing string with the private key.
+	Sign(signingString string, key crypto.PrivateKey) (string, error)
+
+	// Verify checks the encoded signature on the signing string with the public key.
+	Verify(signingString, signature string, key crypto.PublicKey) error
+}
+
+// ECDSAScheme is the default SigningScheme, using ECDSA over the P-256 curve with SHA-256.
+var ECDSAScheme SigningScheme = &jwtScheme{
+	method:    jwt.SigningMethodES256,
+	publicKey: ecdsaPublicKey,
+}
+
+func ecdsaPublicKey(pubKeyBytes []byte) (crypto.PublicKey, error) {
+	return ecid.FromPublicKeyBytes(pubKeyBytes)
+}
+
+// jwtScheme is a SigningScheme backed by a jwt.SigningMethod.
+type jwtScheme struct {
+	method    jwt.SigningMethod
+	publicKey func(pubKeyBytes []byte) (crypto.PublicKey, error)
+}
+
+func (s *jwtScheme) ID() string {
+	return s.method.Alg()
+}
+
+func (s *jwtScheme) PublicKey(pubKeyBytes []byte) (crypto.PublicKey, error) {
+	return s.publicKey(pubKeyBytes)
+}
+
+func (s *jwtScheme) Sign(signingString string, key crypto.PrivateKey) (string, error) {
+	return s.method.Sign(signingString, key)
+}
+
+func (s *jwtScheme) Verify(signingString, signature string, key crypto.PublicKey) error {
+	return s.method.Verify(signingString, signature, key)
+}
+
+// schemeMethod is a jwt.SigningMethod backed by a SigningScheme, used to sign tokens. It is never
+// registered with jwt, so the process-wide jwt signing methods are left as they are.
+type schemeMethod struct {
+	scheme SigningScheme
+}
+
+func (m *schemeMethod) Alg() string {
+	return m.scheme.ID()
+}
+
+func (m *schemeMethod) Sign(signingString string, key interface{}) (string, error) {
+	return m.scheme.Sign(signingString, key)
+}
+
+func (m *schemeMethod) Verify(signingString, signature string, key interface{}) error {
+	return m.scheme.Verify(signingString, signature, key)
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemeSigner_Sign_schemeID(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+
+	// check default and other schemes are identified in token header
+	for _, scheme := range []SigningScheme{ECDSAScheme, &hashScheme{id: "TEST-HASH"}} {
+		encToken, err := NewSchemeSigner(scheme, peerID.Key()).Sign(rq)
+		assert.Nil(t, err)
+		encHeader := strings.Split(encToken, ".")[0]
+		header, err := base64.RawURLEncoding.DecodeString(encHeader)
+		assert.Nil(t, err)
+		var fields map[string]interface{}
+		assert.Nil(t, json.Unmarshal(header, &fields))
+		assert.Equal(t, scheme.ID(), fields["alg"])
+	}
+}
+
+func TestSchemeVerifier_Verify_mixedSchemes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	hs := &hashScheme{id: "TEST-HASH-MIXED"}
+	verifier := NewSchemeVerifier(ECDSAScheme, hs)
+
+	// check tokens signed with either of the verifier's schemes verify
+	ecdsaToken, err := NewSigner(peerID.Key()).Sign(rq)
+	assert.Nil(t, err)
+	assert.Nil(t, verifier.Verify(ecdsaToken, peerID.PublicKeyBytes(), rq))
+
+	hashToken, err := NewSchemeSigner(hs, nil).Sign(rq)
+	assert.Nil(t, err)
+	assert.Nil(t, verifier.Verify(hashToken, peerID.PublicKeyBytes(), rq))
+
+	// check token signature must still verify with its scheme
+	parts := strings.Split(hashToken, ".")
+	parts[2] = jwt.EncodeSegment([]byte("bad signature"))
+	assert.NotNil(t, verifier.Verify(strings.Join(parts, "."), peerID.PublicKeyBytes(), rq))
+
+	// check schemes don't replace the process-wide jwt signing methods
+	assert.Equal(t, jwt.SigningMethodES256, jwt.GetSigningMethod(ECDSAScheme.ID()))
+	assert.Nil(t, jwt.GetSigningMethod(hs.ID()))
+}
+
+func TestExpiringVerifier_Verify_schemes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	hs := &hashScheme{id: "TEST-HASH-EXPIRING"}
+	notAfter := time.Now().Add(time.Minute)
+	ecdsaToken, err := NewSigner(peerID.Key()).SignUntil(rq, notAfter)
+	assert.Nil(t, err)
+	hashToken, err := NewSchemeSigner(hs, nil).SignUntil(rq, notAfter)
+	assert.Nil(t, err)
+
+	// check only tokens signed with the given schemes verify
+	verifier := NewExpiringVerifier(time.Hour, hs)
+	assert.Nil(t, verifier.Verify(hashToken, peerID.PublicKeyBytes(), rq))
+	assert.NotNil(t, verifier.Verify(ecdsaToken, peerID.PublicKeyBytes(), rq))
+
+	verifier = NewExpiringVerifier(time.Hour, ECDSAScheme, hs)
+	assert.Nil(t, verifier.Verify(hashToken, peerID.PublicKeyBytes(), rq))
+	assert.Nil(t, verifier.Verify(ecdsaToken, peerID.PublicKeyBytes(), rq))
+}
+
+func TestSchemeVerifier_Verify_unregisteredScheme(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	verifier := NewVerifier()
+
+	// check token from a scheme the verifier doesn't have is rejected
+	encToken, err := NewSchemeSigner(&hashScheme{id: "TEST-UNREGISTERED"}, nil).Sign(rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, verifier.Verify(encToken, peerID.PublicKeyBytes(), rq))
+
+	// check token from a jwt method that isn't one of the verifier's schemes is rejected
+	hash, err := hashMessage(rq)
+	assert.Nil(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, NewSignatureClaims(hash))
+	encToken, err = token.SignedString([]byte("some secret"))
+	assert.Nil(t, err)
+	assert.NotNil(t, verifier.Verify(encToken, peerID.PublicKeyBytes(), rq))
+}
+
+func TestSchemeVerifier_getScheme(t *testing.T) {
+	verifier := NewVerifier().(*schemeVerifier)
+	scheme, err := verifier.getScheme(ECDSAScheme.ID())
+	assert.Nil(t, err)
+	assert.Equal(t, ECDSAScheme, scheme)
+
+	scheme, err = verifier.getScheme("some unknown scheme")
+	assert.NotNil(t, err)
+	assert.Nil(t, scheme)
+}
+
+// hashScheme is a keyless SigningScheme whose signature is just the hash of the signing string.
+type hashScheme struct {
+	id string
+}
+
+func (s *hashScheme) ID() string {
+	return s.id
+}
+
+func (s *hashScheme) PublicKey(pubKeyBytes []byte) (crypto.PublicKey, error) {
+	return pubKeyBytes, nil
+}
+
+func (s *hashScheme) Sign(signingString string, key crypto.PrivateKey) (string, error) {
+	hash := sha256.Sum256([]byte(signingString))
+	return jwt.EncodeSegment(hash[:]), nil
+}
+
+func (s *hashScheme) Verify(signingString, signature string, key crypto.PublicKey) error {
+	expected, err := s.Sign(signingString, nil)
+	if err != nil {
+		return err
+	}
+	if signature != expected {
+		return errors.New("signature is not hash of signing string")
+	}
+	return nil
+}
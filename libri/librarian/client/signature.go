@@ -2,16 +2,48 @@ package client
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/golang/protobuf/proto"
 )
 
+const (
+	// MaxClockSkew is the maximum difference between the signer's and verifier's clocks
+	// tolerated when checking whether a signature has expired.
+	MaxClockSkew = 30 * time.Second
+
+	// DefaultSignatureLifetime is the default duration after which signatures on requests
+	// without a timeout expire.
+	DefaultSignatureLifetime = 1 * time.Minute
+)
+
+var (
+	// ErrSignatureExpired indicates when a signature's not-after time has passed.
+	ErrSignatureExpired = errors.New("signature has expired")
+
+	// ErrMalformedToken indicates when a token doesn't have the three dot-separated segments of
+	// a json web token.
+	ErrMalformedToken = errors.New("token must have three dot-separated segments")
+
+	// ErrSignatureMissingNotAfter indicates when a verifier requiring signatures to expire gets
+	// one without a not-after time.
+	ErrSignatureMissingNotAfter = errors.New("signature missing not-after time")
+
+	// ErrSignatureLifetimeTooLong indicates when a signature's not-after time is further in the
+	// future than the verifier's maximum signature lifetime.
+	ErrSignatureLifetimeTooLong = errors.New("signature not-after time too far in the future")
+)
+
 // regex pattern for a base-64 url-encoded string for a 256-bit number
 var b64url256bit *regexp.Regexp
 
@@ -28,6 +60,10 @@ func init() {
 type Claims struct {
 	// base-64-url encoded string of the hash of the message being signed
 	Hash string `json:"hash"`
+
+	// Unix time (in seconds) after which the signature is no longer valid, or zero if it doesn't
+	// expire
+	NotAfter int64 `json:"not_after,omitempty"`
 }
 
 // Valid returns whether the claim is valid or invalid via an error.
@@ -37,6 +73,9 @@ func (c *Claims) Valid() error {
 		return fmt.Errorf("%v does not looks like a base-64-url encoded 32-byte number",
 			c.Hash)
 	}
+	if c.NotAfter < 0 {
+		return fmt.Errorf("not-after time %d must be non-negative", c.NotAfter)
+	}
 	return nil
 }
 
@@ -51,25 +90,44 @@ func NewSignatureClaims(hash [sha256.Size]byte) *Claims {
 type Signer interface {
 	// Sign returns the signature (in the form of an encoded json web token) on the message.
 	Sign(m proto.Message) (string, error)
+
+	// SignUntil returns the signature on the message, which expires after the not-after time.
+	SignUntil(m proto.Message, notAfter time.Time) (string, error)
 }
 
-type ecdsaSigner struct {
-	key *ecdsa.PrivateKey
+type schemeSigner struct {
+	scheme SigningScheme
+	key    crypto.PrivateKey
 }
 
-// NewSigner returns a new Signer instance using the given private key.
+// NewSigner returns a new Signer instance using the given private key with the default
+// ECDSAScheme.
 func NewSigner(key *ecdsa.PrivateKey) Signer {
-	return &ecdsaSigner{key}
+	return NewSchemeSigner(ECDSAScheme, key)
+}
+
+// NewSchemeSigner returns a new Signer instance using the given SigningScheme and private key.
+func NewSchemeSigner(scheme SigningScheme, key crypto.PrivateKey) Signer {
+	return &schemeSigner{scheme: scheme, key: key}
+}
+
+func (s *schemeSigner) Sign(m proto.Message) (string, error) {
+	return s.SignUntil(m, time.Time{})
 }
 
-func (s *ecdsaSigner) Sign(m proto.Message) (string, error) {
+func (s *schemeSigner) SignUntil(m proto.Message, notAfter time.Time) (string, error) {
 	hash, err := hashMessage(m)
 	if err != nil {
 		return "", err
 	}
+	claims := NewSignatureClaims(hash)
+	if !notAfter.IsZero() {
+		// round up so the signature is valid for at least as long as requested
+		claims.NotAfter = notAfter.Add(time.Second - 1).Unix()
+	}
 
-	// create token
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, NewSignatureClaims(hash))
+	// create token, whose header identifies the signing scheme
+	token := jwt.NewWithClaims(&schemeMethod{scheme: s.scheme}, claims)
 
 	// sign with key, yield encoded token string like XXXXXX.YYYYYY.ZZZZZZ
 	return token.SignedString(s.key)
@@ -77,36 +135,118 @@ func (s *ecdsaSigner) Sign(m proto.Message) (string, error) {
 
 // Verifier verifies the signature on a message.
 type Verifier interface {
-	// Verify verifies that the encoded token is well formed and has been signed by the peer.
-	Verify(encToken string, fromPubKey *ecdsa.PublicKey, m proto.Message) error
+	// Verify verifies that the encoded token is well formed, has been signed by the peer with
+	// the given public key bytes, and hasn't expired.
+	Verify(encToken string, fromPubKey []byte, m proto.Message) error
 }
 
-type ecsdaVerifier struct{}
+type schemeVerifier struct {
+	// schemes maps IDs to the SigningSchemes whose tokens are accepted
+	schemes map[string]SigningScheme
+
+	// maximum duration from now until a signature's not-after time, or zero if signatures need
+	// not expire
+	maxLifetime time.Duration
+	now         func() time.Time
+}
 
-// NewVerifier creates a new Verifier instance.
+// NewVerifier creates a new Verifier instance, which verifies tokens signed with the default
+// ECDSAScheme.
 func NewVerifier() Verifier {
-	return &ecsdaVerifier{}
+	return NewSchemeVerifier(ECDSAScheme)
+}
+
+// NewSchemeVerifier creates a new Verifier instance, which only verifies tokens signed with one of
+// the given SigningSchemes. Networks migrating between schemes should give all of them.
+func NewSchemeVerifier(schemes ...SigningScheme) Verifier {
+	v := &schemeVerifier{
+		schemes: make(map[string]SigningScheme),
+		now:     time.Now,
+	}
+	for _, scheme := range schemes {
+		v.schemes[scheme.ID()] = scheme
+	}
+	return v
+}
+
+// NewExpiringVerifier creates a new Verifier instance like NewSchemeVerifier that also requires
+// each signature to have a not-after time at most maxLifetime from now, so a captured token can
+// only be used for a bounded time.
+func NewExpiringVerifier(maxLifetime time.Duration, schemes ...SigningScheme) Verifier {
+	v := NewSchemeVerifier(schemes...).(*schemeVerifier)
+	v.maxLifetime = maxLifetime
+	return v
 }
 
-func (v *ecsdaVerifier) Verify(encToken string, fromPubKey *ecdsa.PublicKey,
-	m proto.Message) error {
-	token, err := jwt.ParseWithClaims(encToken, &Claims{}, func(token *jwt.Token) (
-		interface{}, error) {
-		return fromPubKey, nil
-	})
+func (v *schemeVerifier) Verify(encToken string, fromPubKey []byte, m proto.Message) error {
+	parts := strings.Split(encToken, ".")
+	if len(parts) != 3 {
+		return ErrMalformedToken
+	}
+	header := &tokenHeader{}
+	if err := decodeSegment(parts[0], header); err != nil {
+		return err
+	}
+
+	// only accept tokens signed with this verifier's schemes
+	scheme, err := v.getScheme(header.Alg)
+	if err != nil {
+		return err
+	}
+	pubKey, err := scheme.PublicKey(fromPubKey)
 	if err != nil {
-		// received error when parsing claims or verifying signature
+		return err
+	}
+	if err := scheme.Verify(parts[0]+"."+parts[1], parts[2], pubKey); err != nil {
 		return err
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok {
-		return fmt.Errorf("token claims %v are not expected SignatureClaims", token.Claims)
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return err
+	}
+	if err := claims.Valid(); err != nil {
+		return err
+	}
+	now, notAfter := v.now(), time.Unix(claims.NotAfter, 0)
+	if v.maxLifetime > 0 {
+		if claims.NotAfter == 0 {
+			return ErrSignatureMissingNotAfter
+		}
+		if notAfter.After(now.Add(v.maxLifetime + MaxClockSkew)) {
+			return ErrSignatureLifetimeTooLong
+		}
+	}
+	if claims.NotAfter != 0 && now.Add(-MaxClockSkew).After(notAfter) {
+		return ErrSignatureExpired
 	}
 
 	return verifyMessageHash(m, claims.Hash)
 }
 
+// getScheme returns the verifier's SigningScheme with the given ID.
+func (v *schemeVerifier) getScheme(id string) (SigningScheme, error) {
+	scheme, in := v.schemes[id]
+	if !in {
+		return nil, fmt.Errorf("unknown signing scheme %q", id)
+	}
+	return scheme, nil
+}
+
+// tokenHeader is the part of a token header identifying the scheme that signed it.
+type tokenHeader struct {
+	Alg string `json:"alg"`
+}
+
+// decodeSegment decodes the base-64-url encoded JSON token segment into v.
+func decodeSegment(seg string, v interface{}) error {
+	buf, err := jwt.DecodeSegment(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
 func verifyMessageHash(m proto.Message, encClaimedHash string) error {
 	messageHash, err := hashMessage(m)
 	if err != nil {
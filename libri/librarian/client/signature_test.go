@@ -3,6 +3,7 @@ package client
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
@@ -14,10 +15,10 @@ import (
 func TestSignatureClaims_Valid_ok(t *testing.T) {
 	// all of these should be considered valid hashes
 	cases := []*Claims{
-		{"n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg="},
-		{"9nITsSKl1ELSuTvajMRcVkpw7F0qTg6Vu1hc8ZmGnJg="},
-		{"-MAqRWZ-E5DpcCh23U3GwAZuSbXNqm7ByD59iL6S4uI="},
-		{"47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU="},
+		{Hash: "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg="},
+		{Hash: "9nITsSKl1ELSuTvajMRcVkpw7F0qTg6Vu1hc8ZmGnJg="},
+		{Hash: "-MAqRWZ-E5DpcCh23U3GwAZuSbXNqm7ByD59iL6S4uI="},
+		{Hash: "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU="},
 	}
 	for _, c := range cases {
 		assert.Nil(t, c.Valid())
@@ -27,12 +28,13 @@ func TestSignatureClaims_Valid_ok(t *testing.T) {
 func TestSignatureClaims_Valid_err(t *testing.T) {
 	// none of these is valid
 	cases := []*Claims{
-		{"n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgga"},       // missing last =
-		{"n4bQgYhMfWWaL+qgxVrQFaO_TxsrC4Is0V1sFbDwCgga"},       // + part of non-url base-64
-		{"9nITsSKl1ELSuTvajMRcVkpw7F0qTg6Vu1hc8ZmGnJg"},        // too short
-		{"9nITsSKl1ELSuTvajMRcVkpw7F0qTg6Vu1hc8ZmGnJgggggggg"}, // too long
-		{""},            // too short
-		{"test *&*&*&"}, // invalid chars
+		{Hash: "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgga"},       // missing last =
+		{Hash: "n4bQgYhMfWWaL+qgxVrQFaO_TxsrC4Is0V1sFbDwCgga"},       // + part of non-url base-64
+		{Hash: "9nITsSKl1ELSuTvajMRcVkpw7F0qTg6Vu1hc8ZmGnJg"},        // too short
+		{Hash: "9nITsSKl1ELSuTvajMRcVkpw7F0qTg6Vu1hc8ZmGnJgggggggg"}, // too long
+		{Hash: ""},            // too short
+		{Hash: "test *&*&*&"}, // invalid chars
+		{Hash: "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU=", NotAfter: -1}, // negative
 	}
 	for _, c := range cases {
 		assert.NotNil(t, c.Valid())
@@ -55,7 +57,7 @@ func TestEcdsaSignerVerifer_SignVerify_ok(t *testing.T) {
 	for _, c := range cases {
 		encToken, err := signer.Sign(c)
 		assert.Nil(t, err)
-		err = verifier.Verify(encToken, &peerID.Key().PublicKey, c)
+		err = verifier.Verify(encToken, peerID.PublicKeyBytes(), c)
 		assert.Nil(t, err)
 	}
 }
@@ -98,15 +100,45 @@ func TestEcdsaVerifer_Verify_err(t *testing.T) {
 		{encToken, ecid.NewPseudoRandom(rng), message},
 	}
 	for _, c := range errCases {
-		assert.NotNil(t, verifier.Verify(c.encToken, &c.peerID.Key().PublicKey, c.m))
+		assert.NotNil(t, verifier.Verify(c.encToken, c.peerID.PublicKeyBytes(), c.m))
 	}
 
-	assert.Panics(t, func() {
-		err := verifier.Verify(encToken, nil, message) // can't have nil key
-		if err != nil {
-			panic(err)
-		}
-	})
+	// can't have nil key
+	assert.Equal(t, ecid.ErrKeyPointOffCurve, verifier.Verify(encToken, nil, message))
+
+	// malformed token
+	assert.Equal(t, ErrMalformedToken, verifier.Verify("not a token", peerID.PublicKeyBytes(),
+		message))
+}
+
+func TestSchemeVerifier_Verify_notAfter(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	signer, verifier := NewSigner(peerID.Key()), NewVerifier()
+	notAfter := time.Unix(1000, 0)
+	encToken, err := signer.SignUntil(rq, notAfter)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		now      time.Time
+		expected error
+	}{
+		{notAfter.Add(-time.Minute), nil},                               // valid
+		{notAfter, nil},                                                 // valid up to not-after
+		{notAfter.Add(MaxClockSkew), nil},                               // tolerated clock skew
+		{notAfter.Add(MaxClockSkew + time.Second), ErrSignatureExpired}, // expired
+	}
+	for _, c := range cases {
+		verifier.(*schemeVerifier).now = func() time.Time { return c.now }
+		assert.Equal(t, c.expected, verifier.Verify(encToken, peerID.PublicKeyBytes(), rq))
+	}
+
+	// check signatures without not-after times never expire
+	encToken, err = signer.Sign(rq)
+	assert.Nil(t, err)
+	verifier.(*schemeVerifier).now = func() time.Time { return notAfter.Add(24 * time.Hour) }
+	assert.Nil(t, verifier.Verify(encToken, peerID.PublicKeyBytes(), rq))
 }
 
 func TestTestNoOpSigner_Sign(t *testing.T) {
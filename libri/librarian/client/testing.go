@@ -2,6 +2,7 @@ package client
 
 import (
 	"errors"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 )
@@ -14,6 +15,11 @@ func (s *TestNoOpSigner) Sign(m proto.Message) (string, error) {
 	return "noop.token.sig", nil
 }
 
+// SignUntil returns a dummy token.
+func (s *TestNoOpSigner) SignUntil(m proto.Message, notAfter time.Time) (string, error) {
+	return s.Sign(m)
+}
+
 // TestErrSigner implements the signature.Signer interface but always returns an error.
 type TestErrSigner struct{}
 
@@ -21,3 +27,8 @@ type TestErrSigner struct{}
 func (s *TestErrSigner) Sign(m proto.Message) (string, error) {
 	return "", errors.New("some sign error")
 }
+
+// SignUntil returns an error.
+func (s *TestErrSigner) SignUntil(m proto.Message, notAfter time.Time) (string, error) {
+	return s.Sign(m)
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// DefaultReplayWindow is the default duration for which a RequestVerifier remembers request IDs
+// in order to reject replayed requests.
+const DefaultReplayWindow = 10 * time.Minute
+
+var (
+	// ErrMissingRequestMetadata indicates when a request is missing its metadata.
+	ErrMissingRequestMetadata = errors.New("request missing metadata")
+
+	// ErrMissingRequestID indicates when a request's metadata is missing its request ID.
+	ErrMissingRequestID = errors.New("RequestId must not be nil")
+
+	// ErrReplayedRequest indicates when a request's ID has already been seen within the replay
+	// window.
+	ErrReplayedRequest = errors.New("request ID already seen within replay window")
+
+	// ErrReplayCacheFull indicates when a request's ID can't be remembered because the replay
+	// cache is full of IDs still within the replay window.
+	ErrReplayCacheFull = errors.New("too many requests seen within replay window")
+)
+
+// metadataRequest is a request with api.RequestMetadata, which all librarian requests have.
+type metadataRequest interface {
+	GetMetadata() *api.RequestMetadata
+}
+
+// RequestVerifier authenticates incoming requests.
+type RequestVerifier interface {
+	// Verify checks that the signature in the context was made over the request by the sender
+	// declared in the request metadata and that the request ID hasn't been seen within the replay
+	// window. It returns the sender's public key.
+	Verify(ctx context.Context, rq proto.Message) ([]byte, error)
+}
+
+type requestVerifier struct {
+	sigVerifier Verifier
+	seen        *ReplayCache
+}
+
+// NewRequestVerifier creates a new RequestVerifier that rejects requests with IDs already seen
+// within the replay window, remembering at most DefaultReplayCacheSize of them. It accepts
+// signatures from the default ECDSAScheme.
+func NewRequestVerifier(replayWindow time.Duration) RequestVerifier {
+	seen, err := NewReplayCache(DefaultReplayCacheSize, replayWindow)
+	if err != nil {
+		panic(err) // should never happen
+	}
+	return NewRequestVerifierWithCache(seen, ECDSAScheme)
+}
+
+// NewRequestVerifierWithCache creates a new RequestVerifier that rejects requests with IDs
+// already seen by the given ReplayCache, which other verifiers may share, and requests not signed
+// with one of the given SigningSchemes. Request signatures must expire within the cache's replay
+// window, so they can't be replayed once their IDs are forgotten.
+func NewRequestVerifierWithCache(seen *ReplayCache, schemes ...SigningScheme) RequestVerifier {
+	return &requestVerifier{
+		sigVerifier: NewExpiringVerifier(seen.window, schemes...),
+		seen:        seen,
+	}
+}
+
+func (rv *requestVerifier) Verify(ctx context.Context, rq proto.Message) ([]byte, error) {
+	encToken, err := FromSignatureContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mrq, ok := rq.(metadataRequest)
+	if !ok || mrq.GetMetadata() == nil {
+		return nil, ErrMissingRequestMetadata
+	}
+	meta := mrq.GetMetadata()
+	if meta.RequestId == nil {
+		return nil, ErrMissingRequestID
+	}
+	if len(meta.RequestId) != cid.Length {
+		return nil, fmt.Errorf("invalid RequestId length: %v; expected length %v",
+			len(meta.RequestId), cid.Length)
+	}
+
+	// signature only verifies if made with the key of the declared sender
+	if err := rv.sigVerifier.Verify(encToken, meta.PubKey, rq); err != nil {
+		return nil, err
+	}
+
+	// only record request IDs after verifying signature so others can't preempt them
+	if err := rv.seen.Add(meta.RequestId); err != nil {
+		return nil, err
+	}
+	return meta.PubKey, nil
+}
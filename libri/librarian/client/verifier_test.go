@@ -0,0 +1,174 @@
+package client
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRequestVerifier_Verify_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	ctx := newTestIncomingSignedContext(t, NewSigner(peerID.Key()), rq)
+
+	rv := NewRequestVerifier(DefaultReplayWindow)
+	pubKey, err := rv.Verify(ctx, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, peerID.PublicKeyBytes(), pubKey)
+}
+
+func TestRequestVerifier_Verify_replay(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	ctx := newTestIncomingSignedContext(t, NewSigner(peerID.Key()), rq)
+
+	rv := NewRequestVerifier(time.Minute)
+	now := time.Unix(0, 0)
+	rv.(*requestVerifier).seen.now = func() time.Time { return now }
+	_, err := rv.Verify(ctx, rq)
+	assert.Nil(t, err)
+
+	// check replay within window is rejected
+	now = now.Add(time.Minute)
+	pubKey, err := rv.Verify(ctx, rq)
+	assert.Equal(t, ErrReplayedRequest, err)
+	assert.Nil(t, pubKey)
+
+	// check other requests are still fine
+	rq2 := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	ctx2 := newTestIncomingSignedContext(t, NewSigner(peerID.Key()), rq2)
+	_, err = rv.Verify(ctx2, rq2)
+	assert.Nil(t, err)
+
+	// check request is forgotten after window
+	now = now.Add(time.Second)
+	_, err = rv.Verify(ctx, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rv.(*requestVerifier).seen.Len())
+}
+
+func TestReplayCache_Add_bounded(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	c, err := NewReplayCache(2, time.Minute)
+	assert.Nil(t, err)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	ids := [][]byte{
+		cid.NewPseudoRandom(rng).Bytes(),
+		cid.NewPseudoRandom(rng).Bytes(),
+		cid.NewPseudoRandom(rng).Bytes(),
+	}
+	assert.Nil(t, c.Add(ids[0]))
+	now = now.Add(time.Second)
+	assert.Nil(t, c.Add(ids[1]))
+
+	// check full cache rejects new IDs rather than forgetting unexpired ones
+	assert.Equal(t, ErrReplayCacheFull, c.Add(ids[2]))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, ErrReplayedRequest, c.Add(ids[0]))
+
+	// check only expired IDs are evicted to make room
+	now = now.Add(time.Minute)
+	assert.Nil(t, c.Add(ids[2]))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, ErrReplayedRequest, c.Add(ids[1]))
+	now = now.Add(time.Second)
+	assert.Nil(t, c.Add(ids[0]))
+}
+
+func TestNewReplayCache_err(t *testing.T) {
+	c, err := NewReplayCache(0, time.Minute)
+	assert.NotNil(t, err)
+	assert.Nil(t, c)
+}
+
+func TestRequestVerifier_Verify_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID, otherID := ecid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+	rv := NewRequestVerifier(DefaultReplayWindow)
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+
+	// no signature in context
+	_, err := rv.Verify(context.Background(), rq)
+	assert.NotNil(t, err)
+
+	// signature from key other than declared sender's
+	ctx := newTestIncomingSignedContext(t, NewSigner(otherID.Key()), rq)
+	_, err = rv.Verify(ctx, rq)
+	assert.NotNil(t, err)
+
+	// signature over a different request
+	ctx = newTestIncomingSignedContext(t, NewSigner(peerID.Key()),
+		NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20))
+	_, err = rv.Verify(ctx, rq)
+	assert.NotNil(t, err)
+
+	// bad metadata
+	ctx = NewIncomingSignatureContext(context.Background(), "dummy.signed.token")
+	_, err = rv.Verify(ctx, &api.FindRequest{})
+	assert.Equal(t, ErrMissingRequestMetadata, err)
+
+	_, err = rv.Verify(ctx, &api.FindRequest{Metadata: &api.RequestMetadata{
+		PubKey: []byte{255, 254, 253}, // bad pub key
+	}})
+	assert.NotNil(t, err)
+
+	_, err = rv.Verify(ctx, &api.FindRequest{Metadata: &api.RequestMetadata{
+		PubKey:    peerID.PublicKeyBytes(),
+		RequestId: nil, // can't be nil
+	}})
+	assert.Equal(t, ErrMissingRequestID, err)
+
+	_, err = rv.Verify(ctx, &api.FindRequest{Metadata: &api.RequestMetadata{
+		PubKey:    peerID.PublicKeyBytes(),
+		RequestId: []byte{1, 2, 3}, // not 32 bytes
+	}})
+	assert.NotNil(t, err)
+
+	// check failed requests weren't recorded
+	assert.Zero(t, rv.(*requestVerifier).seen.Len())
+}
+
+func TestRequestVerifier_Verify_notAfter(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	signer := NewSigner(peerID.Key())
+	rv := NewRequestVerifier(DefaultReplayWindow)
+
+	// check signatures that never expire are rejected
+	rq := NewFindRequest(peerID, cid.NewPseudoRandom(rng), 20)
+	encToken, err := signer.Sign(rq)
+	assert.Nil(t, err)
+	ctx := NewIncomingSignatureContext(context.Background(), encToken)
+	_, err = rv.Verify(ctx, rq)
+	assert.Equal(t, ErrSignatureMissingNotAfter, err)
+
+	// check signatures expiring after the replay window are rejected
+	encToken, err = signer.SignUntil(rq, time.Now().Add(DefaultReplayWindow+time.Hour))
+	assert.Nil(t, err)
+	ctx = NewIncomingSignatureContext(context.Background(), encToken)
+	_, err = rv.Verify(ctx, rq)
+	assert.Equal(t, ErrSignatureLifetimeTooLong, err)
+
+	// check signatures expiring within the replay window are accepted
+	encToken, err = signer.SignUntil(rq, time.Now().Add(DefaultReplayWindow))
+	assert.Nil(t, err)
+	ctx = NewIncomingSignatureContext(context.Background(), encToken)
+	_, err = rv.Verify(ctx, rq)
+	assert.Nil(t, err)
+}
+
+func newTestIncomingSignedContext(t *testing.T, signer Signer, rq proto.Message) context.Context {
+	encToken, err := signer.SignUntil(rq, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	return NewIncomingSignatureContext(context.Background(), encToken)
+}
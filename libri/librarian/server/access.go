@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// access logger keys
+const (
+	// LoggerMethod is the RPC method of a request.
+	LoggerMethod = "method"
+
+	// LoggerRequestID is the ID of a request.
+	LoggerRequestID = "request_id"
+
+	// LoggerPeerPubKey is the public key of the peer making a request.
+	LoggerPeerPubKey = "peer_pub_key"
+
+	// LoggerKey is the key a request operates on.
+	LoggerKey = "key"
+
+	// LoggerSigned indicates whether a request had a signature in its context.
+	LoggerSigned = "signed"
+
+	// LoggerDuration is the duration of a request.
+	LoggerDuration = "duration"
+
+	// LoggerOutcome is the outcome ("success" or "error") of a request.
+	LoggerOutcome = "outcome"
+)
+
+// metadataRequest is a request with metadata, e.g., a StoreRequest.
+type metadataRequest interface {
+	GetMetadata() *api.RequestMetadata
+}
+
+// keyRequest is a request operating on a key, e.g., a StoreRequest.
+type keyRequest interface {
+	GetKey() []byte
+}
+
+// accessLogger logs a sample of the unary RPC requests a Librarian handles.
+type accessLogger struct {
+	logger     *zap.Logger
+	level      zapcore.Level
+	sampleRate float32
+
+	// returns a float in [0, 1), used to sample requests for logging
+	sample func() float32
+}
+
+func newAccessLogger(logger *zap.Logger, level zapcore.Level, sampleRate float32) *accessLogger {
+	return &accessLogger{
+		logger:     logger,
+		level:      level,
+		sampleRate: sampleRate,
+		sample:     rand.Float32,
+	}
+}
+
+// unaryInterceptor logs the request ID, peer public key, key, duration, and outcome of a sample
+// of unary RPC requests.
+func (al *accessLogger) unaryInterceptor(
+	ctx context.Context,
+	rq interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	rp, err := handler(ctx, rq)
+	if al.sampleRate <= 0 || al.sample() >= al.sampleRate {
+		return rp, err
+	}
+	ce := al.logger.Check(al.level, "handled request")
+	if ce == nil {
+		// logger not enabled at the access log level
+		return rp, err
+	}
+	ce.Write(accessLogFields(ctx, rq, info, time.Since(start), err)...)
+	return rp, err
+}
+
+func accessLogFields(
+	ctx context.Context,
+	rq interface{},
+	info *grpc.UnaryServerInfo,
+	duration time.Duration,
+	err error,
+) []zapcore.Field {
+	_, sigErr := client.FromSignatureContext(ctx)
+	fields := []zapcore.Field{
+		zap.String(LoggerMethod, methodName(info.FullMethod)),
+		zap.Bool(LoggerSigned, sigErr == nil),
+	}
+	if mrq, ok := rq.(metadataRequest); ok && mrq.GetMetadata() != nil {
+		fields = append(fields,
+			zap.String(LoggerRequestID, fmt.Sprintf("%x", mrq.GetMetadata().RequestId)),
+			zap.String(LoggerPeerPubKey, fmt.Sprintf("%x", mrq.GetMetadata().PubKey)),
+		)
+	}
+	if krq, ok := rq.(keyRequest); ok {
+		fields = append(fields, zap.String(LoggerKey, fmt.Sprintf("%x", krq.GetKey())))
+	}
+	fields = append(fields, zap.Duration(LoggerDuration, duration))
+	if err != nil {
+		return append(fields, zap.String(LoggerOutcome, "error"), zap.Error(err))
+	}
+	return append(fields, zap.String(LoggerOutcome, "success"))
+}
+
+// chainUnaryInterceptors combines multiple interceptors into one, since a gRPC server accepts only
+// a single unary interceptor. The first interceptor is the outermost.
+func chainUnaryInterceptors(
+	interceptors ...grpc.UnaryServerInterceptor,
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		rq interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, rq interface{}) (interface{}, error) {
+				return interceptor(ctx, rq, info, next)
+			}
+		}
+		return chained(ctx, rq)
+	}
+}
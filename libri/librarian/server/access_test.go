@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestAccessLogger_unaryInterceptor(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	value, key := api.NewTestDocument(rng)
+	rq := client.NewStoreRequest(peerID, key, value)
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Librarian/Store"}
+	ctx := client.NewIncomingSignatureContext(context.Background(), "some signed JWT")
+	errTest := errors.New("some handler error")
+	ok := func(ctx context.Context, rq interface{}) (interface{}, error) { return rq, nil }
+	fail := func(ctx context.Context, rq interface{}) (interface{}, error) {
+		return nil, errTest
+	}
+
+	// check successful request is logged with its request info
+	buf := new(bytes.Buffer)
+	al := newAccessLogger(newBufferLogger(buf), zapcore.InfoLevel, 1.0)
+	rp, err := al.unaryInterceptor(ctx, rq, info, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, rq, rp)
+	line := buf.String()
+	assert.Contains(t, line, `"method":"Store"`)
+	assert.Contains(t, line, `"signed":true`)
+	assert.Contains(t, line, `"outcome":"success"`)
+	assert.Contains(t, line, cid.FromBytes(rq.Metadata.RequestId).String())
+	assert.Contains(t, line, cid.FromBytes(rq.Key).String())
+
+	// check failed request is logged with its error
+	buf.Reset()
+	rp, err = al.unaryInterceptor(context.Background(), rq, info, fail)
+	assert.Equal(t, errTest, err)
+	assert.Nil(t, rp)
+	line = buf.String()
+	assert.Contains(t, line, `"signed":false`)
+	assert.Contains(t, line, `"outcome":"error"`)
+	assert.Contains(t, line, errTest.Error())
+
+	// check nothing logged when logger isn't enabled at access log level
+	buf.Reset()
+	al = newAccessLogger(newBufferLogger(buf), zapcore.DebugLevel-1, 1.0)
+	_, err = al.unaryInterceptor(ctx, rq, info, ok)
+	assert.Nil(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestAccessLogger_unaryInterceptor_sampling(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rq := &api.PingRequest{}
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Librarian/Ping"}
+	ok := func(ctx context.Context, rq interface{}) (interface{}, error) { return rq, nil }
+	nRequests := 1000
+
+	for _, sampleRate := range []float32{0, 0.25, 1.0} {
+		buf := new(bytes.Buffer)
+		al := newAccessLogger(newBufferLogger(buf), zapcore.InfoLevel, sampleRate)
+		al.sample = rng.Float32
+		for c := 0; c < nRequests; c++ {
+			_, err := al.unaryInterceptor(context.Background(), rq, info, ok)
+			assert.Nil(t, err)
+		}
+		nLogged := strings.Count(buf.String(), "\n")
+		assert.InDelta(t, sampleRate*float32(nRequests), nLogged, float64(nRequests/20))
+	}
+}
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	calls := make([]string, 0)
+	newInterceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, rq interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, rq)
+		}
+	}
+	handler := func(ctx context.Context, rq interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return rq, nil
+	}
+	chained := chainUnaryInterceptors(newInterceptor("first"), newInterceptor("second"))
+	rp, err := chained(context.Background(), "rq", &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "rq", rp)
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
+
+func newBufferLogger(buf *bytes.Buffer) *zap.Logger {
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.DebugLevel))
+}
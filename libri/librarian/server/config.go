@@ -2,11 +2,20 @@ package server
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drausin/libri/libri/common/clock"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/subscribe"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/introduce"
 	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/drausin/libri/libri/librarian/server/search"
@@ -20,12 +29,93 @@ const (
 	// DefaultPort is the default port of both local and public addresses.
 	DefaultPort = 20100
 
+	// DefaultMetricsPort is the default port of the local metrics address.
+	DefaultMetricsPort = 20200
+
 	// DefaultIP is the default IP of both local and public addresses.
 	DefaultIP = "localhost"
 
 	// DefaultLogLevel is the default log level to use.
 	DefaultLogLevel = zap.InfoLevel
 
+	// DefaultAccessLogLevel is the default log level of RPC access logs.
+	DefaultAccessLogLevel = zap.DebugLevel
+
+	// DefaultAccessLogSampleRate is the default fraction of RPC requests to access log.
+	DefaultAccessLogSampleRate = float32(1.0)
+
+	// DefaultExpirySweepInterval is the default interval between sweeps for expired documents.
+	DefaultExpirySweepInterval = 1 * time.Hour
+
+	// DefaultRequestReplayWindow is the default window within which replayed requests are rejected.
+	DefaultRequestReplayWindow = client.DefaultReplayWindow
+
+	// DefaultRequestReplayCacheSize is the default maximum number of recently seen request IDs
+	// remembered to reject replayed requests.
+	DefaultRequestReplayCacheSize = client.DefaultReplayCacheSize
+
+	// DefaultStoreRequestRate is the default rate (per second) of Store requests allowed from each
+	// peer.
+	DefaultStoreRequestRate = float32(100)
+
+	// DefaultStoreRequestBurst is the default maximum number of Store requests allowed from each
+	// peer in a burst above the rate.
+	DefaultStoreRequestBurst = uint(500)
+
+	// DefaultBucketRefreshInterval is the default interval between refreshes of routing table
+	// buckets without a recent lookup.
+	DefaultBucketRefreshInterval = 1 * time.Hour
+
+	// DefaultReplicationEnabled is the default of whether to periodically re-store stored
+	// documents with fewer reachable replicas than the target.
+	DefaultReplicationEnabled = true
+
+	// DefaultReplicationCheckInterval is the default interval between checks for stored
+	// documents with fewer reachable replicas than the target.
+	DefaultReplicationCheckInterval = 1 * time.Hour
+
+	// DefaultReplicationMaxStores is the default maximum number of under-replicated documents
+	// re-stored per replication check.
+	DefaultReplicationMaxStores = uint(64)
+
+	// DefaultDBBackend is the default KVDB backend.
+	DefaultDBBackend = db.RocksDBBackend
+
+	// DefaultDataDirQuota is the default maximum number of bytes stored in the data directory,
+	// where zero means no maximum.
+	DefaultDataDirQuota = uint64(0)
+
+	// DefaultMaxStoredDocumentBytes is the default maximum total size of stored documents before
+	// evicting those the librarian isn't responsible for, where zero means no maximum.
+	DefaultMaxStoredDocumentBytes = uint64(0)
+
+	// DefaultMaxDocumentBytes is the default maximum size of a stored document, which matches
+	// gRPC's own default maximum message size.
+	DefaultMaxDocumentBytes = uint64(4 * 1024 * 1024) // 4 MB
+
+	// DefaultMaxMessageBytes is the default maximum size of gRPC messages librarians send and
+	// receive, which leaves room for request metadata around the largest stored documents.
+	DefaultMaxMessageBytes = uint64(api.DefaultMaxMessageBytes)
+
+	// DefaultBootstrapRetryInitialInterval is the default interval before the first retry of a
+	// failed bootstrap.
+	DefaultBootstrapRetryInitialInterval = 500 * time.Millisecond
+
+	// DefaultBootstrapRetryMaxInterval is the default maximum interval between bootstrap retries.
+	DefaultBootstrapRetryMaxInterval = 5 * time.Second
+
+	// DefaultBootstrapRetryMaxElapsedTime is the default maximum time spent retrying a bootstrap
+	// before giving up.
+	DefaultBootstrapRetryMaxElapsedTime = 5 * time.Second
+
+	// DefaultBootstrapInBackground is the default of whether to keep retrying a failed bootstrap
+	// in the background while serving requests.
+	DefaultBootstrapInBackground = false
+
+	// DefaultMinHealthyPeers is the default minimum number of peers in the routing table for the
+	// librarian to report itself as serving, where zero means it always does once listening.
+	DefaultMinHealthyPeers = uint(0)
+
 	// DataSubdir is the name of the data directory.
 	DataSubdir = "librarian-data"
 
@@ -33,11 +123,57 @@ const (
 	DBSubDir = "db"
 )
 
+const maxPort = 65535
+
+var (
+	// ErrInvalidLocalAddr indicates when the local address is missing or its port is out of range.
+	ErrInvalidLocalAddr = errors.New("local address missing or port out of [1, 65535] range")
+
+	// ErrInvalidLocalMetricsAddr indicates when the local metrics address port is out of range.
+	ErrInvalidLocalMetricsAddr = errors.New("local metrics address port out of [1, 65535] range")
+
+	// ErrInvalidPublicAddr indicates when the public address is missing or its port is out of
+	// range.
+	ErrInvalidPublicAddr = errors.New("public address missing or port out of [1, 65535] range")
+
+	// ErrEmptyDataDir indicates when the data directory is empty.
+	ErrEmptyDataDir = errors.New("data directory is empty")
+
+	// ErrEmptyDBDir indicates when the DB directory is empty.
+	ErrEmptyDBDir = errors.New("DB directory is empty")
+
+	// ErrOutOfBoundsFPRate indicates when the subscription false positive rate is not in (0, 1].
+	ErrOutOfBoundsFPRate = errors.New("subscription false positive rate out of (0, 1] bounds")
+
+	// ErrOutOfBoundsAccessLogSampleRate indicates when the access log sample rate is not in
+	// [0, 1].
+	ErrOutOfBoundsAccessLogSampleRate = errors.New("access log sample rate out of [0, 1] bounds")
+
+	// ErrMaxMessageBytesTooSmall indicates when the maximum gRPC message size is zero or smaller
+	// than the maximum stored document size.
+	ErrMaxMessageBytesTooSmall = errors.New("max message size zero or smaller than max " +
+		"document size")
+
+	// ErrIncompleteTLS indicates when only one of the TLS cert and key files is given.
+	ErrIncompleteTLS = errors.New("TLS cert and key files must be given together")
+
+	// ErrTLSClientCAWithoutCert indicates when the TLS client CA file is given without the TLS
+	// cert and key files.
+	ErrTLSClientCAWithoutCert = errors.New("TLS client CA file given without TLS cert and key")
+
+	// ErrTLSPeerCAWithoutCert indicates when the TLS peer CA file is given without the TLS cert
+	// and key files.
+	ErrTLSPeerCAWithoutCert = errors.New("TLS peer CA file given without TLS cert and key")
+)
+
 // Config is used to configure a Librarian server
 type Config struct {
 	// LocalAddr is the local address the server listens to.
 	LocalAddr *net.TCPAddr
 
+	// LocalMetricsAddr is the local address the Prometheus metrics HTTP server listens to.
+	LocalMetricsAddr *net.TCPAddr
+
 	// PublicAddr is the public address clients make requests to.
 	PublicAddr *net.TCPAddr
 
@@ -51,9 +187,47 @@ type Config struct {
 	// DbDir is the local directory where this node's DB state is stored.
 	DbDir string
 
+	// DBBackend is the KVDB backend storing the DB state, e.g., db.RocksDBBackend or the pure-Go
+	// db.BadgerDBBackend, which doesn't require cgo.
+	DBBackend string
+
+	// DataDirQuota is the maximum number of bytes stored in the DB, beyond which new documents
+	// are rejected. Zero means no maximum.
+	DataDirQuota uint64
+
+	// MaxStoredDocumentBytes is the maximum total number of bytes stored documents take in the DB,
+	// beyond which the least recently accessed documents the librarian isn't responsible for
+	// replicating are evicted. Zero means no maximum.
+	MaxStoredDocumentBytes uint64
+
+	// MaxDocumentBytes is the maximum size (in bytes) of a stored document, beyond which Store
+	// requests are rejected. Zero means no maximum.
+	MaxDocumentBytes uint64
+
+	// MaxMessageBytes is the maximum size (in bytes) of gRPC messages the server sends and
+	// receives, which must be at least MaxDocumentBytes. Connections to other librarians use the
+	// api.DefaultMaxMessageBytes.
+	MaxMessageBytes uint64
+
 	// BootstrapAddrs is a list of addresses for bootstrap peers.
 	BootstrapAddrs []*net.TCPAddr
 
+	// BootstrapRetryInitialInterval is the interval before the first retry of a failed
+	// bootstrap, which grows exponentially with each subsequent retry.
+	BootstrapRetryInitialInterval time.Duration
+
+	// BootstrapRetryMaxInterval is the maximum interval between bootstrap retries.
+	BootstrapRetryMaxInterval time.Duration
+
+	// BootstrapRetryMaxElapsedTime is the maximum time spent retrying a bootstrap before giving
+	// up.
+	BootstrapRetryMaxElapsedTime time.Duration
+
+	// BootstrapInBackground is whether to keep retrying a bootstrap in the background, serving
+	// requests in the meantime, rather than failing to start once the BootstrapAddrs can't be
+	// reached within the BootstrapRetryMaxElapsedTime.
+	BootstrapInBackground bool
+
 	// Routing defines parameters for the server's routing table.
 	Routing *routing.Parameters
 
@@ -74,6 +248,75 @@ type Config struct {
 
 	// LogLevel is the log level
 	LogLevel zapcore.Level
+
+	// AccessLogLevel is the log level of RPC access logs.
+	AccessLogLevel zapcore.Level
+
+	// AccessLogSampleRate is the fraction of RPC requests to access log, in [0, 1].
+	AccessLogSampleRate float32
+
+	// ExpirySweepInterval is the interval between sweeps deleting expired documents.
+	ExpirySweepInterval time.Duration
+
+	// RequestReplayWindow is the window within which requests with already seen IDs are rejected.
+	RequestReplayWindow time.Duration
+
+	// RequestReplayCacheSize is the maximum number of recently seen request IDs remembered to
+	// reject replayed requests.
+	RequestReplayCacheSize uint
+
+	// SigningSchemes are the schemes whose request signatures are accepted. Networks migrating
+	// between schemes should give all of them.
+	SigningSchemes []client.SigningScheme
+
+	// StoreRequestRate is the rate (per second) of Store requests allowed from each peer, beyond
+	// which its requests are rejected.
+	StoreRequestRate float32
+
+	// StoreRequestBurst is the maximum number of Store requests allowed from each peer in a burst
+	// above the StoreRequestRate.
+	StoreRequestBurst uint
+
+	// BucketRefreshInterval is the interval between refreshes of routing table buckets, each
+	// refreshing the buckets without a lookup in the previous interval.
+	BucketRefreshInterval time.Duration
+
+	// ReplicationEnabled is whether to periodically re-store stored documents with fewer
+	// reachable replicas than the target.
+	ReplicationEnabled bool
+
+	// ReplicationCheckInterval is the interval between checks for stored documents with fewer
+	// reachable replicas than the target.
+	ReplicationCheckInterval time.Duration
+
+	// ReplicationMaxStores is the maximum number of under-replicated documents re-stored per
+	// replication check, which limits the load re-replication puts on the network.
+	ReplicationMaxStores uint
+
+	// MinHealthyPeers is the minimum number of peers in the routing table for the librarian to
+	// report a SERVING health status. Zero means it always does once listening.
+	MinHealthyPeers uint
+
+	// TLSCertFile is the PEM-encoded certificate file the server presents to its clients. If it
+	// and TLSKeyFile are empty, the server listens in plaintext. Otherwise, the librarian also
+	// dials its peers over TLS, presenting the certificate as its client certificate, so all
+	// librarians in a cluster should enable TLS together. Since peers are dialed by IP address,
+	// the certificate should include the librarian's public IP address.
+	TLSCertFile string
+
+	// TLSKeyFile is the PEM-encoded private key file of the TLSCertFile.
+	TLSKeyFile string
+
+	// TLSClientCAFile is the PEM-encoded file of CA certificates used to verify client
+	// certificates. If empty, clients aren't asked for a certificate.
+	TLSClientCAFile string
+
+	// TLSPeerCAFile is the PEM-encoded file of CA certificates used to verify peer certificates
+	// when dialing peers over TLS. If empty, the system's CAs are used.
+	TLSPeerCAFile string
+
+	// Clock tells the time for search timeouts, which tests may replace with a fake.
+	Clock clock.Clock
 }
 
 // NewDefaultConfig returns a reasonable default server configuration.
@@ -83,11 +326,21 @@ func NewDefaultConfig() *Config {
 	// set defaults via zero values; in cases where the config B depends on config A, config A
 	// should be set before config B
 	config.WithDefaultLocalAddr()
+	config.WithDefaultLocalMetricsAddr()
 	config.WithDefaultPublicAddr()
 	config.WithDefaultPublicName()
 	config.WithDefaultDataDir()
 	config.WithDefaultDBDir()
+	config.WithDefaultDBBackend()
+	config.WithDefaultDataDirQuota()
+	config.WithDefaultMaxStoredDocumentBytes()
+	config.WithDefaultMaxDocumentBytes()
+	config.WithDefaultMaxMessageBytes()
 	config.WithDefaultBootstrapAddrs()
+	config.WithDefaultBootstrapRetryInitialInterval()
+	config.WithDefaultBootstrapRetryMaxInterval()
+	config.WithDefaultBootstrapRetryMaxElapsedTime()
+	config.WithDefaultBootstrapInBackground()
 	config.WithDefaultRouting()
 	config.WithDefaultIntroduce()
 	config.WithDefaultSearch()
@@ -95,6 +348,20 @@ func NewDefaultConfig() *Config {
 	config.WithDefaultSubscribeTo()
 	config.WithDefaultSubscribeFrom()
 	config.WithDefaultLogLevel()
+	config.WithDefaultAccessLogLevel()
+	config.WithDefaultAccessLogSampleRate()
+	config.WithDefaultExpirySweepInterval()
+	config.WithDefaultRequestReplayWindow()
+	config.WithDefaultRequestReplayCacheSize()
+	config.WithDefaultSigningSchemes()
+	config.WithDefaultStoreRequestRate()
+	config.WithDefaultStoreRequestBurst()
+	config.WithDefaultBucketRefreshInterval()
+	config.WithDefaultReplicationEnabled()
+	config.WithDefaultReplicationCheckInterval()
+	config.WithDefaultReplicationMaxStores()
+	config.WithDefaultMinHealthyPeers()
+	config.WithDefaultClock()
 
 	return config
 }
@@ -120,6 +387,27 @@ func (c *Config) WithDefaultLocalAddr() *Config {
 	return c
 }
 
+// WithLocalMetricsAddr sets the local metrics address to the given value or to the default if
+// the given value is nil.
+func (c *Config) WithLocalMetricsAddr(localMetricsAddr *net.TCPAddr) *Config {
+	if localMetricsAddr == nil {
+		return c.WithDefaultLocalMetricsAddr()
+	}
+	c.LocalMetricsAddr = localMetricsAddr
+	return c
+}
+
+// WithDefaultLocalMetricsAddr sets the local metrics address to the local address IP with its port
+// offset by the difference between the default metrics and local ports, so librarians running on
+// the same host get distinct metrics ports.
+func (c *Config) WithDefaultLocalMetricsAddr() *Config {
+	c.LocalMetricsAddr = &net.TCPAddr{
+		IP:   c.LocalAddr.IP,
+		Port: c.LocalAddr.Port + DefaultMetricsPort - DefaultPort,
+	}
+	return c
+}
+
 // WithPublicAddr sets the public address to the given value or to the default if the given value
 // is nil.
 func (c *Config) WithPublicAddr(publicAddr *net.TCPAddr) *Config {
@@ -188,6 +476,75 @@ func (c *Config) WithDefaultDBDir() *Config {
 	return c
 }
 
+// WithDBBackend sets the DB backend to the given value or the default if the given value is empty.
+func (c *Config) WithDBBackend(backend string) *Config {
+	if backend == "" {
+		return c.WithDefaultDBBackend()
+	}
+	c.DBBackend = backend
+	return c
+}
+
+// WithDefaultDBBackend sets the DB backend to the default.
+func (c *Config) WithDefaultDBBackend() *Config {
+	c.DBBackend = DefaultDBBackend
+	return c
+}
+
+// WithDataDirQuota sets the data directory quota to the given value. Zero means no maximum.
+func (c *Config) WithDataDirQuota(quota uint64) *Config {
+	c.DataDirQuota = quota
+	return c
+}
+
+// WithDefaultDataDirQuota sets the data directory quota to the default.
+func (c *Config) WithDefaultDataDirQuota() *Config {
+	c.DataDirQuota = DefaultDataDirQuota
+	return c
+}
+
+// WithMaxStoredDocumentBytes sets the max total size of stored documents to the given value.
+// Zero means no maximum.
+func (c *Config) WithMaxStoredDocumentBytes(maxBytes uint64) *Config {
+	c.MaxStoredDocumentBytes = maxBytes
+	return c
+}
+
+// WithDefaultMaxStoredDocumentBytes sets the max total size of stored documents to the default.
+func (c *Config) WithDefaultMaxStoredDocumentBytes() *Config {
+	c.MaxStoredDocumentBytes = DefaultMaxStoredDocumentBytes
+	return c
+}
+
+// WithMaxDocumentBytes sets the maximum stored document size to the given value. Zero means no
+// maximum.
+func (c *Config) WithMaxDocumentBytes(maxBytes uint64) *Config {
+	c.MaxDocumentBytes = maxBytes
+	return c
+}
+
+// WithDefaultMaxDocumentBytes sets the maximum stored document size to the default.
+func (c *Config) WithDefaultMaxDocumentBytes() *Config {
+	c.MaxDocumentBytes = DefaultMaxDocumentBytes
+	return c
+}
+
+// WithMaxMessageBytes sets the maximum gRPC message size to the given value or the default if the
+// given value is zero.
+func (c *Config) WithMaxMessageBytes(maxBytes uint64) *Config {
+	if maxBytes == 0 {
+		return c.WithDefaultMaxMessageBytes()
+	}
+	c.MaxMessageBytes = maxBytes
+	return c
+}
+
+// WithDefaultMaxMessageBytes sets the maximum gRPC message size to the default.
+func (c *Config) WithDefaultMaxMessageBytes() *Config {
+	c.MaxMessageBytes = DefaultMaxMessageBytes
+	return c
+}
+
 // WithBootstrapAddrs sets the bootstrap addresses to the given value or the default if the given
 // value is empty.
 func (c *Config) WithBootstrapAddrs(bootstrapAddrs []*net.TCPAddr) *Config {
@@ -211,6 +568,69 @@ func (c *Config) WithDefaultBootstrapAddrs() *Config {
 	return c
 }
 
+// WithBootstrapRetryInitialInterval sets the bootstrap retry initial interval to the given value
+// or the default if the given value is not positive.
+func (c *Config) WithBootstrapRetryInitialInterval(interval time.Duration) *Config {
+	if interval <= 0 {
+		return c.WithDefaultBootstrapRetryInitialInterval()
+	}
+	c.BootstrapRetryInitialInterval = interval
+	return c
+}
+
+// WithDefaultBootstrapRetryInitialInterval sets the bootstrap retry initial interval to the
+// default.
+func (c *Config) WithDefaultBootstrapRetryInitialInterval() *Config {
+	c.BootstrapRetryInitialInterval = DefaultBootstrapRetryInitialInterval
+	return c
+}
+
+// WithBootstrapRetryMaxInterval sets the bootstrap retry max interval to the given value or the
+// default if the given value is not positive.
+func (c *Config) WithBootstrapRetryMaxInterval(interval time.Duration) *Config {
+	if interval <= 0 {
+		return c.WithDefaultBootstrapRetryMaxInterval()
+	}
+	c.BootstrapRetryMaxInterval = interval
+	return c
+}
+
+// WithDefaultBootstrapRetryMaxInterval sets the bootstrap retry max interval to the default.
+func (c *Config) WithDefaultBootstrapRetryMaxInterval() *Config {
+	c.BootstrapRetryMaxInterval = DefaultBootstrapRetryMaxInterval
+	return c
+}
+
+// WithBootstrapRetryMaxElapsedTime sets the bootstrap retry max elapsed time to the given value
+// or the default if the given value is not positive.
+func (c *Config) WithBootstrapRetryMaxElapsedTime(elapsed time.Duration) *Config {
+	if elapsed <= 0 {
+		return c.WithDefaultBootstrapRetryMaxElapsedTime()
+	}
+	c.BootstrapRetryMaxElapsedTime = elapsed
+	return c
+}
+
+// WithDefaultBootstrapRetryMaxElapsedTime sets the bootstrap retry max elapsed time to the
+// default.
+func (c *Config) WithDefaultBootstrapRetryMaxElapsedTime() *Config {
+	c.BootstrapRetryMaxElapsedTime = DefaultBootstrapRetryMaxElapsedTime
+	return c
+}
+
+// WithBootstrapInBackground sets whether to keep retrying a failed bootstrap in the background.
+func (c *Config) WithBootstrapInBackground(inBackground bool) *Config {
+	c.BootstrapInBackground = inBackground
+	return c
+}
+
+// WithDefaultBootstrapInBackground sets whether to keep retrying a failed bootstrap in the
+// background to the default.
+func (c *Config) WithDefaultBootstrapInBackground() *Config {
+	c.BootstrapInBackground = DefaultBootstrapInBackground
+	return c
+}
+
 // WithRouting sets the routing parameters to the given value or the default if it is nil.
 func (c *Config) WithRouting(params *routing.Parameters) *Config {
 	if params == nil {
@@ -307,8 +727,8 @@ func (c *Config) WithDefaultSubscribeFrom() *Config {
 	return c
 }
 
-// WithLogLevel sets the log level to the given value, though this doesn't have any direct effect
-// on the creation of the logger instance.
+// WithLogLevel sets the log level to the given value, below which NewLibrarian drops the entries
+// of the logger it's given.
 func (c *Config) WithLogLevel(logLevel zapcore.Level) *Config {
 	if logLevel == 0 {
 		return c.WithDefaultLogLevel()
@@ -323,6 +743,201 @@ func (c *Config) WithDefaultLogLevel() *Config {
 	return c
 }
 
+// WithAccessLogLevel sets the access log level to the given value.
+func (c *Config) WithAccessLogLevel(accessLogLevel zapcore.Level) *Config {
+	c.AccessLogLevel = accessLogLevel
+	return c
+}
+
+// WithDefaultAccessLogLevel sets the access log level to DEBUG.
+func (c *Config) WithDefaultAccessLogLevel() *Config {
+	c.AccessLogLevel = DefaultAccessLogLevel
+	return c
+}
+
+// WithAccessLogSampleRate sets the access log sample rate to the given value or the default if
+// the given value is not in [0, 1].
+func (c *Config) WithAccessLogSampleRate(sampleRate float32) *Config {
+	if sampleRate < 0 || sampleRate > 1 {
+		return c.WithDefaultAccessLogSampleRate()
+	}
+	c.AccessLogSampleRate = sampleRate
+	return c
+}
+
+// WithDefaultAccessLogSampleRate sets the access log sample rate to the default, which logs every
+// request.
+func (c *Config) WithDefaultAccessLogSampleRate() *Config {
+	c.AccessLogSampleRate = DefaultAccessLogSampleRate
+	return c
+}
+
+// WithExpirySweepInterval sets the expiry sweep interval to the given value or the default if the
+// given value is not positive.
+func (c *Config) WithExpirySweepInterval(interval time.Duration) *Config {
+	if interval <= 0 {
+		return c.WithDefaultExpirySweepInterval()
+	}
+	c.ExpirySweepInterval = interval
+	return c
+}
+
+// WithDefaultExpirySweepInterval sets the expiry sweep interval to the default.
+func (c *Config) WithDefaultExpirySweepInterval() *Config {
+	c.ExpirySweepInterval = DefaultExpirySweepInterval
+	return c
+}
+
+// WithRequestReplayWindow sets the request replay window to the given value or the default if the
+// given value is not positive.
+func (c *Config) WithRequestReplayWindow(window time.Duration) *Config {
+	if window <= 0 {
+		return c.WithDefaultRequestReplayWindow()
+	}
+	c.RequestReplayWindow = window
+	return c
+}
+
+// WithDefaultRequestReplayWindow sets the request replay window to the default.
+func (c *Config) WithDefaultRequestReplayWindow() *Config {
+	c.RequestReplayWindow = DefaultRequestReplayWindow
+	return c
+}
+
+// WithRequestReplayCacheSize sets the request replay cache size to the given value or the default
+// if the given value is zero.
+func (c *Config) WithRequestReplayCacheSize(size uint) *Config {
+	if size == 0 {
+		return c.WithDefaultRequestReplayCacheSize()
+	}
+	c.RequestReplayCacheSize = size
+	return c
+}
+
+// WithDefaultRequestReplayCacheSize sets the request replay cache size to the default.
+func (c *Config) WithDefaultRequestReplayCacheSize() *Config {
+	c.RequestReplayCacheSize = DefaultRequestReplayCacheSize
+	return c
+}
+
+// WithSigningSchemes sets the accepted request signing schemes to the given value or the default
+// if the given value is empty.
+func (c *Config) WithSigningSchemes(schemes ...client.SigningScheme) *Config {
+	if len(schemes) == 0 {
+		return c.WithDefaultSigningSchemes()
+	}
+	c.SigningSchemes = schemes
+	return c
+}
+
+// WithDefaultSigningSchemes sets the accepted request signing schemes to just the default
+// ECDSAScheme.
+func (c *Config) WithDefaultSigningSchemes() *Config {
+	c.SigningSchemes = []client.SigningScheme{client.ECDSAScheme}
+	return c
+}
+
+// WithStoreRequestRate sets the Store request rate to the given value or the default if the given
+// value is not positive.
+func (c *Config) WithStoreRequestRate(rate float32) *Config {
+	if rate <= 0 {
+		return c.WithDefaultStoreRequestRate()
+	}
+	c.StoreRequestRate = rate
+	return c
+}
+
+// WithDefaultStoreRequestRate sets the Store request rate to the default.
+func (c *Config) WithDefaultStoreRequestRate() *Config {
+	c.StoreRequestRate = DefaultStoreRequestRate
+	return c
+}
+
+// Validate returns an error describing the first config field with a value the librarian
+// can't sensibly use, or nil if all are ok.
+func (c *Config) Validate() error {
+	if !validPortAddr(c.LocalAddr) {
+		return ErrInvalidLocalAddr
+	}
+	if c.LocalMetricsAddr != nil && !validPortAddr(c.LocalMetricsAddr) {
+		return ErrInvalidLocalMetricsAddr
+	}
+	if !validPortAddr(c.PublicAddr) {
+		return ErrInvalidPublicAddr
+	}
+	if c.DataDir == "" {
+		return ErrEmptyDataDir
+	}
+	if c.DbDir == "" {
+		return ErrEmptyDBDir
+	}
+	if c.SubscribeTo != nil && !(c.SubscribeTo.FPRate > 0 && c.SubscribeTo.FPRate <= 1) {
+		return ErrOutOfBoundsFPRate
+	}
+	if !(c.AccessLogSampleRate >= 0 && c.AccessLogSampleRate <= 1) {
+		return ErrOutOfBoundsAccessLogSampleRate
+	}
+	if c.MaxMessageBytes == 0 || c.MaxDocumentBytes > c.MaxMessageBytes {
+		return ErrMaxMessageBytesTooSmall
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return ErrIncompleteTLS
+	}
+	if c.TLSClientCAFile != "" && !c.tlsEnabled() {
+		return ErrTLSClientCAWithoutCert
+	}
+	if c.TLSPeerCAFile != "" && !c.tlsEnabled() {
+		return ErrTLSPeerCAWithoutCert
+	}
+	return nil
+}
+
+func validPortAddr(addr *net.TCPAddr) bool {
+	return addr != nil && addr.Port > 0 && addr.Port <= maxPort
+}
+
+// WithStoreRequestBurst sets the Store request burst to the given value or the default if the
+// given value is zero.
+func (c *Config) WithStoreRequestBurst(burst uint) *Config {
+	if burst == 0 {
+		return c.WithDefaultStoreRequestBurst()
+	}
+	c.StoreRequestBurst = burst
+	return c
+}
+
+// WithDefaultStoreRequestBurst sets the Store request burst to the default.
+func (c *Config) WithDefaultStoreRequestBurst() *Config {
+	c.StoreRequestBurst = DefaultStoreRequestBurst
+	return c
+}
+
+// WithTLS sets the TLS cert and key files the server uses to terminate TLS. Empty values mean
+// the server listens in plaintext.
+func (c *Config) WithTLS(certFile, keyFile string) *Config {
+	c.TLSCertFile = certFile
+	c.TLSKeyFile = keyFile
+	return c
+}
+
+// WithTLSClientCAFile sets the file of CA certificates used to verify client certificates. An
+// empty value means client certificates aren't verified.
+func (c *Config) WithTLSClientCAFile(clientCAFile string) *Config {
+	c.TLSClientCAFile = clientCAFile
+	return c
+}
+
+// WithTLSPeerCAFile sets the file of CA certificates used to verify peer certificates. An empty
+// value means the system's CAs are used.
+func (c *Config) WithTLSPeerCAFile(peerCAFile string) *Config {
+	c.TLSPeerCAFile = peerCAFile
+	return c
+}
+
+func (c *Config) tlsEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
 func (c *Config) isBootstrap() bool {
 	for _, a := range c.BootstrapAddrs {
 		if c.PublicAddr.String() == a.String() {
@@ -332,20 +947,30 @@ func (c *Config) isBootstrap() bool {
 	return false
 }
 
-// ParseAddr parses a net.TCPAddr from a host address and port.
+// ParseAddr parses a net.TCPAddr from a host address and port. The host may be a name or an IPv4
+// or IPv6 address, the latter with or without brackets. Names resolve to IPv4 addresses when
+// they have one.
 func ParseAddr(host string, port int) (*net.TCPAddr, error) {
-	return net.ResolveTCPAddr("tcp4", fmt.Sprintf("%s:%d", host, port))
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.ResolveTCPAddr("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 }
 
-// ParseAddrs parses an array of net.TCPAddrs from an array of IPv4:Port address strings.
+// ParseAddrs parses an array of net.TCPAddrs from an array of Host:Port address strings, where
+// IPv6 hosts are bracketed, e.g., [2001:db8::1]:20100.
 func ParseAddrs(addrs []string) ([]*net.TCPAddr, error) {
 	netAddrs := make([]*net.TCPAddr, len(addrs))
 	for i, a := range addrs {
-		netAddr, err := net.ResolveTCPAddr("tcp4", a)
-		netAddrs[i] = netAddr
+		host, portStr, err := net.SplitHostPort(a)
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.Atoi(portStr)
 		if err != nil {
 			return nil, err
 		}
+		if netAddrs[i], err = ParseAddr(host, port); err != nil {
+			return nil, err
+		}
 	}
 	return netAddrs, nil
 }
@@ -363,3 +988,93 @@ func NameFromAddr(localAddr fmt.Stringer) string {
 	addrHash := md5.Sum([]byte(localAddr.String()))
 	return fmt.Sprintf("peer-%x", addrHash[:4])
 }
+
+// WithBucketRefreshInterval sets the bucket refresh interval to the given value or the default if
+// the given value is not positive.
+func (c *Config) WithBucketRefreshInterval(interval time.Duration) *Config {
+	if interval <= 0 {
+		return c.WithDefaultBucketRefreshInterval()
+	}
+	c.BucketRefreshInterval = interval
+	return c
+}
+
+// WithDefaultBucketRefreshInterval sets the bucket refresh interval to the default.
+func (c *Config) WithDefaultBucketRefreshInterval() *Config {
+	c.BucketRefreshInterval = DefaultBucketRefreshInterval
+	return c
+}
+
+// WithReplicationEnabled sets whether to periodically re-store under-replicated documents.
+func (c *Config) WithReplicationEnabled(enabled bool) *Config {
+	c.ReplicationEnabled = enabled
+	return c
+}
+
+// WithDefaultReplicationEnabled sets whether to periodically re-store under-replicated documents
+// to the default.
+func (c *Config) WithDefaultReplicationEnabled() *Config {
+	c.ReplicationEnabled = DefaultReplicationEnabled
+	return c
+}
+
+// WithReplicationCheckInterval sets the replication check interval to the given value or the
+// default if the given value is not positive.
+func (c *Config) WithReplicationCheckInterval(interval time.Duration) *Config {
+	if interval <= 0 {
+		return c.WithDefaultReplicationCheckInterval()
+	}
+	c.ReplicationCheckInterval = interval
+	return c
+}
+
+// WithDefaultReplicationCheckInterval sets the replication check interval to the default.
+func (c *Config) WithDefaultReplicationCheckInterval() *Config {
+	c.ReplicationCheckInterval = DefaultReplicationCheckInterval
+	return c
+}
+
+// WithReplicationMaxStores sets the maximum number of stores per replication check to the given
+// value or the default if the given value is zero.
+func (c *Config) WithReplicationMaxStores(maxStores uint) *Config {
+	if maxStores == 0 {
+		return c.WithDefaultReplicationMaxStores()
+	}
+	c.ReplicationMaxStores = maxStores
+	return c
+}
+
+// WithDefaultReplicationMaxStores sets the maximum number of stores per replication check to the
+// default.
+func (c *Config) WithDefaultReplicationMaxStores() *Config {
+	c.ReplicationMaxStores = DefaultReplicationMaxStores
+	return c
+}
+
+// WithMinHealthyPeers sets the minimum number of healthy peers to the given value. Zero means the
+// librarian always reports itself as serving once listening.
+func (c *Config) WithMinHealthyPeers(minPeers uint) *Config {
+	c.MinHealthyPeers = minPeers
+	return c
+}
+
+// WithDefaultMinHealthyPeers sets the minimum number of healthy peers to the default.
+func (c *Config) WithDefaultMinHealthyPeers() *Config {
+	c.MinHealthyPeers = DefaultMinHealthyPeers
+	return c
+}
+
+// WithClock sets the clock to the given value or the default if it is nil.
+func (c *Config) WithClock(clk clock.Clock) *Config {
+	if clk == nil {
+		return c.WithDefaultClock()
+	}
+	c.Clock = clk
+	return c
+}
+
+// WithDefaultClock sets the clock to the wall clock.
+func (c *Config) WithDefaultClock() *Config {
+	c.Clock = clock.New()
+	return c
+}
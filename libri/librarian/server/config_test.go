@@ -3,8 +3,12 @@ package server
 import (
 	"net"
 	"testing"
+	"time"
 
+	"github.com/drausin/libri/libri/common/clock"
+	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/subscribe"
+	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/introduce"
 	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/drausin/libri/libri/librarian/server/search"
@@ -16,10 +20,12 @@ import (
 func TestDefaultConfig(t *testing.T) {
 	c := NewDefaultConfig()
 	assert.NotEmpty(t, c.LocalAddr)
+	assert.NotEmpty(t, c.LocalMetricsAddr)
 	assert.NotEmpty(t, c.PublicAddr)
 	assert.NotEmpty(t, c.PublicName)
 	assert.NotEmpty(t, c.DataDir)
 	assert.NotEmpty(t, c.DbDir)
+	assert.NotEmpty(t, c.DBBackend)
 	assert.NotEmpty(t, c.BootstrapAddrs)
 	assert.NotEmpty(t, c.Routing)
 	assert.NotEmpty(t, c.Introduce)
@@ -28,6 +34,53 @@ func TestDefaultConfig(t *testing.T) {
 	assert.NotEmpty(t, c.SubscribeTo)
 	assert.NotEmpty(t, c.SubscribeFrom)
 	assert.NotEmpty(t, c.LogLevel)
+	assert.NotEmpty(t, c.AccessLogLevel)
+	assert.NotEmpty(t, c.AccessLogSampleRate)
+	assert.NotEmpty(t, c.ExpirySweepInterval)
+	assert.NotEmpty(t, c.RequestReplayWindow)
+	assert.NotEmpty(t, c.RequestReplayCacheSize)
+	assert.NotEmpty(t, c.SigningSchemes)
+	assert.NotEmpty(t, c.StoreRequestRate)
+	assert.NotEmpty(t, c.BootstrapRetryInitialInterval)
+	assert.NotEmpty(t, c.BootstrapRetryMaxInterval)
+	assert.NotEmpty(t, c.BootstrapRetryMaxElapsedTime)
+	assert.NotEmpty(t, c.StoreRequestBurst)
+	assert.NotEmpty(t, c.BucketRefreshInterval)
+	assert.NotEmpty(t, c.ReplicationCheckInterval)
+	assert.NotEmpty(t, c.ReplicationMaxStores)
+	assert.NotEmpty(t, c.MaxMessageBytes)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.Nil(t, NewDefaultConfig().Validate())
+
+	badPortAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 70000}
+	cases := map[error]func(c *Config){
+		ErrInvalidLocalAddr:        func(c *Config) { c.LocalAddr = nil },
+		ErrInvalidLocalMetricsAddr: func(c *Config) { c.LocalMetricsAddr = badPortAddr },
+		ErrInvalidPublicAddr:       func(c *Config) { c.PublicAddr.Port = 0 },
+		ErrEmptyDataDir:            func(c *Config) { c.DataDir = "" },
+		ErrEmptyDBDir:              func(c *Config) { c.DbDir = "" },
+		ErrOutOfBoundsFPRate:       func(c *Config) { c.SubscribeTo.FPRate = 2.0 },
+		ErrOutOfBoundsAccessLogSampleRate: func(c *Config) {
+			c.AccessLogSampleRate = -0.5
+		},
+		ErrMaxMessageBytesTooSmall: func(c *Config) {
+			c.MaxMessageBytes = c.MaxDocumentBytes - 1
+		},
+		ErrIncompleteTLS: func(c *Config) { c.TLSCertFile = "cert.pem" },
+		ErrTLSClientCAWithoutCert: func(c *Config) {
+			c.TLSClientCAFile = "ca.pem"
+		},
+		ErrTLSPeerCAWithoutCert: func(c *Config) {
+			c.TLSPeerCAFile = "ca.pem"
+		},
+	}
+	for expected, invalidate := range cases {
+		c := NewDefaultConfig()
+		invalidate(c)
+		assert.Equal(t, expected, c.Validate())
+	}
 }
 
 func TestConfig_WithLocalAddr(t *testing.T) {
@@ -39,6 +92,23 @@ func TestConfig_WithLocalAddr(t *testing.T) {
 	assert.NotEqual(t, c1.LocalAddr, c3.WithLocalAddr(c3Addr).LocalAddr)
 }
 
+func TestConfig_WithLocalMetricsAddr(t *testing.T) {
+	c1, c2, c3 := NewDefaultConfig(), NewDefaultConfig(), NewDefaultConfig()
+	c1.WithDefaultLocalMetricsAddr()
+	assert.Equal(t, c1.LocalMetricsAddr, c2.WithLocalMetricsAddr(nil).LocalMetricsAddr)
+	assert.Equal(t, DefaultMetricsPort, c1.LocalMetricsAddr.Port)
+	c3Addr, err := ParseAddr("localhost", 1234)
+	assert.Nil(t, err)
+	assert.NotEqual(t, c1.LocalMetricsAddr, c3.WithLocalMetricsAddr(c3Addr).LocalMetricsAddr)
+
+	// check default metrics port follows local port
+	c4 := NewDefaultConfig()
+	c4LocalAddr, err := ParseAddr("localhost", DefaultPort+1)
+	assert.Nil(t, err)
+	c4.WithLocalAddr(c4LocalAddr).WithDefaultLocalMetricsAddr()
+	assert.Equal(t, DefaultMetricsPort+1, c4.LocalMetricsAddr.Port)
+}
+
 func TestConfig_WithPublicAddr(t *testing.T) {
 	c1, c2, c3 := &Config{}, &Config{}, &Config{}
 	c1.WithDefaultPublicAddr()
@@ -72,6 +142,43 @@ func TestConfig_WithDBDir(t *testing.T) {
 	assert.NotEqual(t, c1.DbDir, c3.WithDBDir("/some/other/dir").DbDir)
 }
 
+func TestConfig_WithDBBackend(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultDBBackend()
+	assert.Equal(t, c1.DBBackend, c2.WithDBBackend("").DBBackend)
+	assert.NotEqual(t, c1.DBBackend, c3.WithDBBackend(db.BadgerDBBackend).DBBackend)
+}
+
+func TestConfig_WithDataDirQuota(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultDataDirQuota()
+	assert.Equal(t, c1.DataDirQuota, c2.WithDataDirQuota(0).DataDirQuota)
+	assert.Equal(t, uint64(1<<30), c3.WithDataDirQuota(1<<30).DataDirQuota)
+}
+
+func TestConfig_WithMaxStoredDocumentBytes(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultMaxStoredDocumentBytes()
+	assert.Equal(t, c1.MaxStoredDocumentBytes,
+		c2.WithMaxStoredDocumentBytes(0).MaxStoredDocumentBytes)
+	assert.Equal(t, uint64(1<<30), c3.WithMaxStoredDocumentBytes(1<<30).MaxStoredDocumentBytes)
+}
+
+func TestConfig_WithMaxDocumentBytes(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultMaxDocumentBytes()
+	assert.Equal(t, c1.MaxDocumentBytes, c2.WithMaxDocumentBytes(4*1024*1024).MaxDocumentBytes)
+	assert.Equal(t, uint64(0), c3.WithMaxDocumentBytes(0).MaxDocumentBytes)
+}
+
+func TestConfig_WithMaxMessageBytes(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultMaxMessageBytes()
+	assert.Equal(t, DefaultMaxMessageBytes, c1.MaxMessageBytes)
+	assert.Equal(t, c1.MaxMessageBytes, c2.WithMaxMessageBytes(0).MaxMessageBytes)
+	assert.Equal(t, uint64(8<<20), c3.WithMaxMessageBytes(8<<20).MaxMessageBytes)
+}
+
 func TestConfig_WithBootstrapAddrs(t *testing.T) {
 	c1, c2, c3 := &Config{}, &Config{}, &Config{}
 	c1.WithDefaultBootstrapAddrs()
@@ -84,6 +191,46 @@ func TestConfig_WithBootstrapAddrs(t *testing.T) {
 	)
 }
 
+func TestConfig_WithBootstrapRetryInitialInterval(t *testing.T) {
+	c1, c2, c3, c4 := &Config{}, &Config{}, &Config{}, &Config{}
+	c1.WithDefaultBootstrapRetryInitialInterval()
+	assert.Equal(t, c1.BootstrapRetryInitialInterval,
+		c2.WithBootstrapRetryInitialInterval(0).BootstrapRetryInitialInterval)
+	assert.Equal(t, c1.BootstrapRetryInitialInterval,
+		c4.WithBootstrapRetryInitialInterval(-time.Second).BootstrapRetryInitialInterval)
+	assert.Equal(t, time.Second,
+		c3.WithBootstrapRetryInitialInterval(time.Second).BootstrapRetryInitialInterval)
+}
+
+func TestConfig_WithBootstrapRetryMaxInterval(t *testing.T) {
+	c1, c2, c3, c4 := &Config{}, &Config{}, &Config{}, &Config{}
+	c1.WithDefaultBootstrapRetryMaxInterval()
+	assert.Equal(t, c1.BootstrapRetryMaxInterval,
+		c2.WithBootstrapRetryMaxInterval(0).BootstrapRetryMaxInterval)
+	assert.Equal(t, c1.BootstrapRetryMaxInterval,
+		c4.WithBootstrapRetryMaxInterval(-time.Minute).BootstrapRetryMaxInterval)
+	assert.Equal(t, time.Minute,
+		c3.WithBootstrapRetryMaxInterval(time.Minute).BootstrapRetryMaxInterval)
+}
+
+func TestConfig_WithBootstrapRetryMaxElapsedTime(t *testing.T) {
+	c1, c2, c3, c4 := &Config{}, &Config{}, &Config{}, &Config{}
+	c1.WithDefaultBootstrapRetryMaxElapsedTime()
+	assert.Equal(t, c1.BootstrapRetryMaxElapsedTime,
+		c2.WithBootstrapRetryMaxElapsedTime(0).BootstrapRetryMaxElapsedTime)
+	assert.Equal(t, c1.BootstrapRetryMaxElapsedTime,
+		c4.WithBootstrapRetryMaxElapsedTime(-time.Minute).BootstrapRetryMaxElapsedTime)
+	assert.Equal(t, time.Minute,
+		c3.WithBootstrapRetryMaxElapsedTime(time.Minute).BootstrapRetryMaxElapsedTime)
+}
+
+func TestConfig_WithBootstrapInBackground(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	c1.WithDefaultBootstrapInBackground()
+	assert.Equal(t, DefaultBootstrapInBackground, c1.BootstrapInBackground)
+	assert.True(t, c2.WithBootstrapInBackground(true).BootstrapInBackground)
+}
+
 func TestConfig_WithRouting(t *testing.T) {
 	c1, c2, c3 := &Config{}, &Config{}, &Config{}
 	c1.WithDefaultRouting()
@@ -173,6 +320,9 @@ func TestParseAddr(t *testing.T) {
 		{"192.168.1.1", 20100, &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 20100}},
 		{"192.168.1.1", 11001, &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 11001}},
 		{"localhost", 20100, &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 20100}},
+		{"2001:db8::1", 20100, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 20100}},
+		{"[2001:db8::1]", 20100, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 20100}},
+		{"::1", 11001, &net.TCPAddr{IP: net.ParseIP("::1"), Port: 11001}},
 	}
 	for _, c := range cases {
 		actual, err := ParseAddr(c.ip, c.port)
@@ -186,15 +336,20 @@ func TestParseAddrs_ok(t *testing.T) {
 		"192.168.1.1:20100",
 		"192.168.1.1:11001",
 		"localhost:20100",
+		"[2001:db8::1]:20100",
+		"[::1]:11001",
 	}
 	expectedNetAddrs := []*net.TCPAddr{
 		{IP: net.ParseIP("192.168.1.1"), Port: 20100},
 		{IP: net.ParseIP("192.168.1.1"), Port: 11001},
 		{IP: net.ParseIP("127.0.0.1"), Port: 20100},
+		{IP: net.ParseIP("2001:db8::1"), Port: 20100},
+		{IP: net.ParseIP("::1"), Port: 11001},
 	}
 	actualNetAddrs, err := ParseAddrs(addrs)
 
 	assert.Nil(t, err)
+	assert.Len(t, actualNetAddrs, len(expectedNetAddrs))
 	for i, a := range actualNetAddrs {
 		assert.Equal(t, expectedNetAddrs[i], a)
 	}
@@ -204,8 +359,10 @@ func TestParseAddrs_err(t *testing.T) {
 	addrs := []string{
 		"192.168.1.1",         // no port
 		"192.168.1.1:A",       // bad port
-		"192::168::1:1:11001", // IPv6 instead of IPv4
+		"192::168::1:1:11001", // unbracketed IPv6
 		"192.168.1.1.11001",   // bad port delimiter
+		"[2001:db8::1]",       // IPv6 without port
+		"[2001:db8::1]:A",     // IPv6 with bad port
 	}
 
 	// test individually
@@ -215,3 +372,119 @@ func TestParseAddrs_err(t *testing.T) {
 		assert.NotNil(t, err, a)
 	}
 }
+
+func TestConfig_WithAccessLogLevel(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	c1.WithDefaultAccessLogLevel()
+	assert.Equal(t, DefaultAccessLogLevel, c1.AccessLogLevel)
+	assert.Equal(t, zapcore.InfoLevel, c2.WithAccessLogLevel(zapcore.InfoLevel).AccessLogLevel)
+}
+
+func TestConfig_WithAccessLogSampleRate(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultAccessLogSampleRate()
+	assert.Equal(t, c1.AccessLogSampleRate, c2.WithAccessLogSampleRate(2).AccessLogSampleRate)
+	assert.Equal(t, float32(0.1), c3.WithAccessLogSampleRate(0.1).AccessLogSampleRate)
+	assert.Equal(t, float32(0), c3.WithAccessLogSampleRate(0).AccessLogSampleRate)
+}
+
+func TestConfig_WithExpirySweepInterval(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultExpirySweepInterval()
+	assert.Equal(t, c1.ExpirySweepInterval, c2.WithExpirySweepInterval(0).ExpirySweepInterval)
+	assert.Equal(t, time.Minute, c3.WithExpirySweepInterval(time.Minute).ExpirySweepInterval)
+}
+
+func TestConfig_WithRequestReplayWindow(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultRequestReplayWindow()
+	assert.Equal(t, c1.RequestReplayWindow, c2.WithRequestReplayWindow(0).RequestReplayWindow)
+	assert.Equal(t, time.Minute, c3.WithRequestReplayWindow(time.Minute).RequestReplayWindow)
+}
+
+func TestConfig_WithRequestReplayCacheSize(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultRequestReplayCacheSize()
+	assert.Equal(t, c1.RequestReplayCacheSize,
+		c2.WithRequestReplayCacheSize(0).RequestReplayCacheSize)
+	assert.Equal(t, uint(16), c3.WithRequestReplayCacheSize(16).RequestReplayCacheSize)
+}
+
+func TestConfig_WithSigningSchemes(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultSigningSchemes()
+	assert.Equal(t, c1.SigningSchemes, c2.WithSigningSchemes().SigningSchemes)
+	schemes := []client.SigningScheme{client.ECDSAScheme, client.ECDSAScheme}
+	assert.Equal(t, schemes, c3.WithSigningSchemes(schemes...).SigningSchemes)
+}
+
+func TestConfig_WithStoreRequestRate(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultStoreRequestRate()
+	assert.Equal(t, c1.StoreRequestRate, c2.WithStoreRequestRate(0).StoreRequestRate)
+	assert.Equal(t, float32(10), c3.WithStoreRequestRate(10).StoreRequestRate)
+}
+
+func TestConfig_WithStoreRequestBurst(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultStoreRequestBurst()
+	assert.Equal(t, c1.StoreRequestBurst, c2.WithStoreRequestBurst(0).StoreRequestBurst)
+	assert.Equal(t, uint(16), c3.WithStoreRequestBurst(16).StoreRequestBurst)
+}
+
+func TestConfig_WithBucketRefreshInterval(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultBucketRefreshInterval()
+	assert.Equal(t, c1.BucketRefreshInterval,
+		c2.WithBucketRefreshInterval(0).BucketRefreshInterval)
+	assert.Equal(t, time.Minute, c3.WithBucketRefreshInterval(time.Minute).BucketRefreshInterval)
+}
+
+func TestConfig_WithReplicationEnabled(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	c1.WithDefaultReplicationEnabled()
+	assert.Equal(t, DefaultReplicationEnabled, c1.ReplicationEnabled)
+	assert.False(t, c2.WithReplicationEnabled(false).ReplicationEnabled)
+}
+
+func TestConfig_WithReplicationCheckInterval(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultReplicationCheckInterval()
+	assert.Equal(t, c1.ReplicationCheckInterval,
+		c2.WithReplicationCheckInterval(0).ReplicationCheckInterval)
+	assert.Equal(t, time.Minute,
+		c3.WithReplicationCheckInterval(time.Minute).ReplicationCheckInterval)
+}
+
+func TestConfig_WithReplicationMaxStores(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultReplicationMaxStores()
+	assert.Equal(t, c1.ReplicationMaxStores, c2.WithReplicationMaxStores(0).ReplicationMaxStores)
+	assert.Equal(t, uint(16), c3.WithReplicationMaxStores(16).ReplicationMaxStores)
+}
+
+func TestConfig_WithMinHealthyPeers(t *testing.T) {
+	c1, c2 := &Config{}, &Config{}
+	c1.WithDefaultMinHealthyPeers()
+	assert.Equal(t, DefaultMinHealthyPeers, c1.MinHealthyPeers)
+	assert.Equal(t, uint(4), c2.WithMinHealthyPeers(4).MinHealthyPeers)
+}
+
+func TestConfig_WithClock(t *testing.T) {
+	c1, c2, c3 := &Config{}, &Config{}, &Config{}
+	c1.WithDefaultClock()
+	assert.Equal(t, c1.Clock, c2.WithClock(nil).Clock)
+	fake := clock.NewFake(time.Unix(0, 0))
+	assert.Equal(t, fake, c3.WithClock(fake).Clock)
+}
+
+func TestConfig_WithTLS(t *testing.T) {
+	c := &Config{}
+	assert.False(t, c.tlsEnabled())
+	c.WithTLS("cert.pem", "key.pem").WithTLSClientCAFile("ca.pem").WithTLSPeerCAFile("peer.pem")
+	assert.Equal(t, "cert.pem", c.TLSCertFile)
+	assert.Equal(t, "key.pem", c.TLSKeyFile)
+	assert.Equal(t, "ca.pem", c.TLSClientCAFile)
+	assert.Equal(t, "peer.pem", c.TLSPeerCAFile)
+	assert.True(t, c.tlsEnabled())
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"go.uber.org/zap"
+)
+
+// LoggerNExpired is the logger key used for the number of expired documents deleted in a sweep.
+const LoggerNExpired = "n_expired"
+
+// ExpirySweeper periodically deletes expired documents from storage.
+type ExpirySweeper interface {
+	// Sweep deletes all documents expired as of now, along with the pages and envelopes of
+	// expired entries, returning the number deleted.
+	Sweep() (int, error)
+
+	// Start begins sweeping in the background every interval until Stop is called.
+	Start()
+
+	// Stop ends background sweeping, waiting for any sweep in progress to finish.
+	Stop()
+}
+
+type expirySweeper struct {
+	docs     storage.DocumentIterator
+	docD     storage.DocumentDeleter
+	interval time.Duration
+	logger   *zap.Logger
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewExpirySweeper creates a new ExpirySweeper that deletes expired documents found by the
+// storage.DocumentIterator with the storage.DocumentDeleter every interval.
+func NewExpirySweeper(
+	docs storage.DocumentIterator,
+	docD storage.DocumentDeleter,
+	interval time.Duration,
+	logger *zap.Logger,
+) ExpirySweeper {
+	return &expirySweeper{
+		docs:     docs,
+		docD:     docD,
+		interval: interval,
+		logger:   logger,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *expirySweeper) Sweep() (int, error) {
+	now := s.now()
+	expired := make([]cid.ID, 0)
+	expiredEntries := make(map[string]struct{})
+	expiredPages := make(map[string]struct{})
+	pages := make(map[string]cid.ID)
+	envelopes := make(map[string][]cid.ID)
+	err := s.docs.Iterate(func(key cid.ID, value *api.Document) error {
+		switch c := value.Contents.(type) {
+		case *api.Document_Entry:
+			if !api.IsExpired(value, now) {
+				return nil
+			}
+			expired = append(expired, key)
+			expiredEntries[key.String()] = struct{}{}
+			if pageKeys, ok := c.Entry.Contents.(*api.Entry_PageKeys); ok {
+				for _, pageKey := range pageKeys.PageKeys.Keys {
+					expiredPages[cid.FromBytes(pageKey).String()] = struct{}{}
+				}
+			}
+		case *api.Document_Page:
+			pages[key.String()] = key
+		case *api.Document_Envelope:
+			entryKey := cid.FromBytes(c.Envelope.EntryKey).String()
+			envelopes[entryKey] = append(envelopes[entryKey], key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// pages and envelopes of an expired entry are useless without it, so expire them too
+	for pageKey, key := range pages {
+		if _, in := expiredPages[pageKey]; in {
+			expired = append(expired, key)
+		}
+	}
+	for entryKey := range expiredEntries {
+		expired = append(expired, envelopes[entryKey]...)
+	}
+
+	// delete after iterating so deletes don't interleave with the snapshot iteration
+	for i, key := range expired {
+		if err := s.docD.Delete(key); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *expirySweeper) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				nExpired, err := s.Sweep()
+				if err != nil {
+					s.logger.Error("expiry sweep error", zap.Error(err))
+				}
+				if nExpired > 0 {
+					s.logger.Info("deleted expired documents", zap.Int(LoggerNExpired, nExpired))
+				}
+			}
+		}
+	}()
+}
+
+func (s *expirySweeper) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
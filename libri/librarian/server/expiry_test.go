@@ -0,0 +1,179 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/common/db"
+	cid "github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpirySweeper_Sweep_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	docSLD := storage.NewDocumentSLD(kvdb)
+	s := NewExpirySweeper(storage.NewDocumentIterator(kvdb), docSLD, time.Hour,
+		clogging.NewDevInfoLogger())
+	s.(*expirySweeper).now = func() time.Time { return time.Unix(100, 0) }
+
+	// store documents that don't expire, haven't expired yet, and have expired
+	expiryTimes := []int64{0, 101, 100, 50}
+	keys := make([]cid.ID, len(expiryTimes))
+	for i, expiryTime := range expiryTimes {
+		value, _ := api.NewTestDocument(rng)
+		value.Contents.(*api.Document_Entry).Entry.ExpiryTime = expiryTime
+		keys[i], err = api.GetKey(value)
+		assert.Nil(t, err)
+		assert.Nil(t, docSLD.Store(keys[i], value))
+	}
+
+	// check only expired documents are deleted
+	nExpired, err := s.Sweep()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, nExpired)
+	for i, expectedPresent := range []bool{true, true, false, false} {
+		value, err := docSLD.Load(keys[i])
+		assert.Nil(t, err)
+		assert.Equal(t, expectedPresent, value != nil)
+	}
+
+	// check nothing more to delete
+	nExpired, err = s.Sweep()
+	assert.Nil(t, err)
+	assert.Zero(t, nExpired)
+}
+
+func TestExpirySweeper_Sweep_pagesEnvelopes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	docSLD := storage.NewDocumentSLD(kvdb)
+	s := NewExpirySweeper(storage.NewDocumentIterator(kvdb), docSLD, time.Hour,
+		clogging.NewDevInfoLogger())
+	s.(*expirySweeper).now = func() time.Time { return time.Unix(100, 0) }
+
+	// store an expired and an unexpired entry, each with two pages and an envelope
+	keys := make([]cid.ID, 0)
+	for _, expiryTime := range []int64{50, 101} {
+		pageKeys := make([][]byte, 2)
+		for i := range pageKeys {
+			page := &api.Document{Contents: &api.Document_Page{Page: api.NewTestPage(rng)}}
+			pageKey, err := api.GetKey(page)
+			assert.Nil(t, err)
+			assert.Nil(t, docSLD.Store(pageKey, page))
+			pageKeys[i] = pageKey.Bytes()
+			keys = append(keys, pageKey)
+		}
+		entry := api.NewTestMultiPageEntry(rng)
+		entry.Contents = &api.Entry_PageKeys{PageKeys: &api.PageKeys{Keys: pageKeys}}
+		entry.ExpiryTime = expiryTime
+		entryDoc := &api.Document{Contents: &api.Document_Entry{Entry: entry}}
+		entryKey, err := api.GetKey(entryDoc)
+		assert.Nil(t, err)
+		assert.Nil(t, docSLD.Store(entryKey, entryDoc))
+		keys = append(keys, entryKey)
+
+		envelope := api.NewTestEnvelope(rng)
+		envelope.EntryKey = entryKey.Bytes()
+		envelopeDoc := &api.Document{Contents: &api.Document_Envelope{Envelope: envelope}}
+		envelopeKey, err := api.GetKey(envelopeDoc)
+		assert.Nil(t, err)
+		assert.Nil(t, docSLD.Store(envelopeKey, envelopeDoc))
+		keys = append(keys, envelopeKey)
+	}
+
+	// check the expired entry's pages and envelope are deleted along with it
+	nExpired, err := s.Sweep()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, nExpired)
+	for i, key := range keys {
+		value, err := docSLD.Load(key)
+		assert.Nil(t, err)
+		assert.Equal(t, i >= 4, value != nil, "doc %d", i)
+	}
+}
+
+func TestExpirySweeper_Sweep_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	value, key := newExpiredTestDocument(rng)
+
+	// check Iterate error bubbles up
+	s := NewExpirySweeper(&fixedDocIterator{err: errors.New("some Iterate error")},
+		&fixedDocDeleter{}, time.Hour, clogging.NewDevInfoLogger())
+	nExpired, err := s.Sweep()
+	assert.NotNil(t, err)
+	assert.Zero(t, nExpired)
+
+	// check Delete error bubbles up
+	s = NewExpirySweeper(&fixedDocIterator{keys: []cid.ID{key}, values: []*api.Document{value}},
+		&fixedDocDeleter{err: errors.New("some Delete error")}, time.Hour,
+		clogging.NewDevInfoLogger())
+	nExpired, err = s.Sweep()
+	assert.NotNil(t, err)
+	assert.Zero(t, nExpired)
+}
+
+func TestExpirySweeper_StartStop(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	value, key := newExpiredTestDocument(rng)
+	docD := &fixedDocDeleter{deleted: make(chan cid.ID, 1)}
+	s := NewExpirySweeper(&fixedDocIterator{keys: []cid.ID{key}, values: []*api.Document{value}},
+		docD, time.Millisecond, clogging.NewDevInfoLogger())
+
+	// check sweeps happen in the background
+	s.Start()
+	select {
+	case deleted := <-docD.deleted:
+		assert.Equal(t, key, deleted)
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected expired document to be deleted")
+	}
+
+	// check Stop returns and may be called more than once
+	s.Stop()
+	s.Stop()
+}
+
+type fixedDocIterator struct {
+	keys   []cid.ID
+	values []*api.Document
+	err    error
+}
+
+func (f *fixedDocIterator) Iterate(fn func(key cid.ID, value *api.Document) error) error {
+	if f.err != nil {
+		return f.err
+	}
+	for i, key := range f.keys {
+		if err := fn(key, f.values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type fixedDocDeleter struct {
+	err     error
+	deleted chan cid.ID
+}
+
+func (f *fixedDocDeleter) Delete(key cid.ID) error {
+	if f.deleted != nil {
+		select {
+		case f.deleted <- key:
+		default:
+		}
+	}
+	return f.err
+}
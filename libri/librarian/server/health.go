@@ -0,0 +1,105 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// LoggerHealthStatus is the logger key used for a health serving status.
+const LoggerHealthStatus = "health_status"
+
+// healthReportInterval is the interval between health reports once started.
+var healthReportInterval = 1 * time.Second
+
+// HealthReporter reports the librarian as serving only once its routing table has enough peers
+// to satisfy Find requests, so load balancers don't route requests to a librarian that has yet
+// to bootstrap or has lost its peers.
+type HealthReporter interface {
+	// Report sets the health serving status from the current number of peers in the routing
+	// table, returning the status.
+	Report() healthpb.HealthCheckResponse_ServingStatus
+
+	// Start begins reporting in the background every interval until Stop is called.
+	Start()
+
+	// Stop ends background reporting.
+	Stop()
+}
+
+type healthReporter struct {
+	health   *health.Server
+	rt       routing.Table
+	minPeers uint
+	interval time.Duration
+	logger   *zap.Logger
+	status   healthpb.HealthCheckResponse_ServingStatus
+	mu       sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewHealthReporter creates a new HealthReporter setting the top-level status of the health
+// server to SERVING while the routing table has at least minPeers peers and NOT_SERVING
+// otherwise.
+func NewHealthReporter(
+	health *health.Server, rt routing.Table, minPeers uint, logger *zap.Logger,
+) HealthReporter {
+	return &healthReporter{
+		health:   health,
+		rt:       rt,
+		minPeers: minPeers,
+		interval: healthReportInterval,
+		logger:   logger,
+		status:   healthpb.HealthCheckResponse_UNKNOWN,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (r *healthReporter) Report() healthpb.HealthCheckResponse_ServingStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nPeers := r.rt.NumPeers()
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if uint(nPeers) >= r.minPeers {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	if status != r.status {
+		r.logger.Info("setting health status",
+			zap.Stringer(LoggerHealthStatus, status),
+			zap.Int("routing_table_n_peers", nPeers),
+			zap.Uint("min_peers", r.minPeers),
+		)
+		r.status = status
+	}
+	r.health.SetServingStatus("", status)
+	return status
+}
+
+func (r *healthReporter) Start() {
+	r.Report()
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.Report()
+			}
+		}
+	}()
+}
+
+func (r *healthReporter) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	r.wg.Wait()
+}
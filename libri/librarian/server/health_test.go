@@ -0,0 +1,66 @@
+package server
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthReporter_Report(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt := routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters())
+	hs := health.NewServer()
+	minPeers := 3
+	r := NewHealthReporter(hs, rt, uint(minPeers), clogging.NewDevInfoLogger())
+
+	// check not serving until routing table has min peers
+	for _, p := range peer.NewTestPeers(rng, minPeers) {
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, r.Report())
+		checkHealthStatus(t, hs, healthpb.HealthCheckResponse_NOT_SERVING)
+		rt.Push(p)
+	}
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, r.Report())
+	checkHealthStatus(t, hs, healthpb.HealthCheckResponse_SERVING)
+
+	// check zero min peers always serves
+	r = NewHealthReporter(hs, routing.NewEmpty(cid.NewPseudoRandom(rng),
+		routing.NewDefaultParameters()), 0, clogging.NewDevInfoLogger())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, r.Report())
+}
+
+func TestHealthReporter_StartStop(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	origInterval := healthReportInterval
+	healthReportInterval = 10 * time.Millisecond
+	defer func() { healthReportInterval = origInterval }()
+
+	rt := routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters())
+	hs := health.NewServer()
+	r := NewHealthReporter(hs, rt, 1, clogging.NewDevInfoLogger())
+
+	// check status is reported on start and then as routing table changes
+	r.Start()
+	checkHealthStatus(t, hs, healthpb.HealthCheckResponse_NOT_SERVING)
+	rt.Push(peer.NewTestPeer(rng, 0))
+	time.Sleep(50 * time.Millisecond)
+	checkHealthStatus(t, hs, healthpb.HealthCheckResponse_SERVING)
+	r.Stop()
+	r.Stop() // check second Stop is no-op
+}
+
+func checkHealthStatus(
+	t *testing.T, hs *health.Server, expected healthpb.HealthCheckResponse_ServingStatus,
+) {
+	rp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, rp.Status)
+}
@@ -5,8 +5,12 @@ import (
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/search"
+	"github.com/drausin/libri/libri/librarian/server/store"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // newStubPeerFromPublicKeyBytes creates a new stub peer with an ID coming from an ECDSA public key.
@@ -78,6 +82,50 @@ func (l *Librarian) checkRequestAndKeyValue(ctx context.Context, rq proto.Messag
 	return requester, nil
 }
 
+// putStoreParams returns the store parameters for a Put request with the given number of
+// replicas, which overrides the configured (or adaptive) number if non-zero. It returns an
+// InvalidArgument error if more replicas are requested than the configured max or than there are
+// peers in the routing table to store them, so one Put can't fan out to every peer.
+func (l *Librarian) putStoreParams(nReplicas uint32) (*store.Parameters, error) {
+	if nReplicas == 0 {
+		if l.config.Store.TargetReplicaFraction <= 0 {
+			return l.config.Store, nil
+		}
+		params := *l.config.Store
+		params.NReplicas = store.AdaptiveNReplicas(l.rt, l.config.Store)
+		return &params, nil
+	}
+	if maxNReplicas := l.config.Store.MaxNReplicas; uint(nReplicas) > maxNReplicas {
+		return nil, grpc.Errorf(codes.InvalidArgument,
+			"requested %d replicas exceeds max of %d", nReplicas, maxNReplicas)
+	}
+	if nPeers := l.rt.NumPeers(); int(nReplicas) > nPeers {
+		return nil, grpc.Errorf(codes.InvalidArgument,
+			"requested %d replicas exceeds %d reachable peers", nReplicas, nPeers)
+	}
+	params := *l.config.Store
+	params.NReplicas = uint(nReplicas)
+	return &params, nil
+}
+
+// getSearchParams returns the search parameters for a Get request with the given search
+// concurrency, where zero uses the configured search parameters. Higher concurrency finds values
+// faster on high-latency networks at the cost of more queries to peers, so it is bounded by
+// api.MaxSearchConcurrency.
+func (l *Librarian) getSearchParams(concurrency uint32) (*search.Parameters, error) {
+	if concurrency == 0 {
+		return l.config.Search, nil
+	}
+	if concurrency > api.MaxSearchConcurrency {
+		return nil, grpc.Errorf(codes.InvalidArgument,
+			"requested search concurrency %d exceeds max of %d", concurrency,
+			api.MaxSearchConcurrency)
+	}
+	params := *l.config.Search
+	params.Concurrency = uint(concurrency)
+	return &params, nil
+}
+
 // record records query outcome for a particular peer if that peer is in the routing table.
 func (l *Librarian) record(fromPeerID cid.ID, t peer.QueryType, o peer.Outcome) {
 	if peer, exists := l.rt.Get(fromPeerID); exists {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/drausin/libri/libri/librarian/server/introduce"
 	cbackoff "github.com/cenkalti/backoff"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
@@ -23,7 +25,6 @@ import (
 
 const (
 	postListenNotifyWait = 100 * time.Millisecond
-	backoffMaxElapsedTime = 5 * time.Second
 )
 
 const (
@@ -36,13 +37,20 @@ const (
 	// LoggerNBootstrappedPeers is the logger key used for the number of peers found
 	// during a bootstrap operation.
 	LoggerNBootstrappedPeers = "n_peers"
+
+	// LoggerBootstrapAttempt is the logger key used for the number of a bootstrap attempt.
+	LoggerBootstrapAttempt = "attempt"
+
+	// LoggerRetryWait is the logger key used for the wait before the next retry.
+	LoggerRetryWait = "retry_wait"
 )
 
 var errNoBootstrappedPeers = errors.New("failed to bootstrap any other peers")
 
 // Start is the entry point for a Librarian server. It bootstraps peers for the Librarians's
 // routing table and then begins listening for and handling requests. It notifies the up channel
-// just before
+// just before. If the bootstrap fails and config.BootstrapInBackground is set, it keeps retrying
+// the bootstrap in the background while serving requests.
 func Start(logger *zap.Logger, config *Config, up chan *Librarian) error {
 	// create librarian
 	l, err := NewLibrarian(config, logger)
@@ -50,9 +58,25 @@ func Start(logger *zap.Logger, config *Config, up chan *Librarian) error {
 		return err
 	}
 
+	// drop any peers loaded from a previous run that are no longer live, in the background so
+	// the pings don't hold up startup
+	go recheckPeers(l.logger, l.rt, pingPeer)
+
 	// populate routing table
 	if err := l.bootstrapPeers(config.BootstrapAddrs); err != nil {
-		return err
+		if !config.BootstrapInBackground {
+			return err
+		}
+		l.logger.Warn("serving requests while retrying bootstrap in background",
+			zap.Error(err))
+		l.stopMu.Lock()
+		if !l.stopped() {
+			l.wg.Add(1)
+			go l.bootstrapPeersInBackground(config.BootstrapAddrs)
+		}
+		l.stopMu.Unlock()
+	} else {
+		l.startPeerWork()
 	}
 
 	// start main listening thread
@@ -64,7 +88,8 @@ func Start(logger *zap.Logger, config *Config, up chan *Librarian) error {
 }
 
 func (l *Librarian) bootstrapPeers(bootstrapAddrs []*net.TCPAddr) error {
-	bootstraps, bootstrapAddrStrs := makeBootstrapPeers(bootstrapAddrs, l.config.PublicAddr)
+	selfAddrs := []*net.TCPAddr{l.config.LocalAddr, l.config.PublicAddr}
+	bootstraps, bootstrapAddrStrs := makeBootstrapPeers(bootstrapAddrs, selfAddrs, l.fromer)
 	l.logger.Info("beginning peer bootstrap", zap.Strings(LoggerSeeds, bootstrapAddrStrs))
 
 	var intro *introduce.Introduction
@@ -84,10 +109,21 @@ func (l *Librarian) bootstrapPeers(bootstrapAddrs []*net.TCPAddr) error {
 		return nil
 	}
 
+	attempt := 0
+	notify := func(err error, wait time.Duration) {
+		attempt++
+		l.logger.Info("bootstrap attempt failed, retrying",
+			zap.Int(LoggerBootstrapAttempt, attempt),
+			zap.Duration(LoggerRetryWait, wait),
+			zap.Error(err),
+		)
+	}
 	backoff := cbackoff.NewExponentialBackOff()
-	backoff.MaxElapsedTime = backoffMaxElapsedTime
-	if err := cbackoff.Retry(operation, backoff); err != nil {
-		l.logger.Error("encountered fatal error while bootstrapping",
+	backoff.InitialInterval = l.config.BootstrapRetryInitialInterval
+	backoff.MaxInterval = l.config.BootstrapRetryMaxInterval
+	backoff.MaxElapsedTime = l.config.BootstrapRetryMaxElapsedTime
+	if err := cbackoff.RetryNotify(operation, backoff, notify); err != nil {
+		l.logger.Error("failed to bootstrap peers",
 			zap.Error(err),
 			zap.Stringer("self_id", l.selfID),
 			zap.String("public_name", l.config.PublicName),
@@ -121,16 +157,84 @@ func (l *Librarian) bootstrapPeers(bootstrapAddrs []*net.TCPAddr) error {
 	return nil
 }
 
-func makeBootstrapPeers(bootstrapAddrs []*net.TCPAddr, selfPublicAddr fmt.Stringer) (
-	[]peer.Peer, []string) {
-	peers, addrStrs := make([]peer.Peer, 0), make([]string, 0)
-	for i, bootstrap := range bootstrapAddrs {
-		if bootstrap.String() != selfPublicAddr.String() {
-			dummyIDStr := fmt.Sprintf("bootstrap-seed%02d", i)
-			conn := api.NewConnector(bootstrap)
-			peers = append(peers, peer.New(nil, dummyIDStr, conn))
-			addrStrs = append(addrStrs, bootstrap.String())
+// bootstrapPeersInBackground retries bootstrapping peers every BootstrapRetryMaxInterval until it
+// succeeds, at which point it starts the background work that needs them, or until the librarian
+// is closed.
+func (l *Librarian) bootstrapPeersInBackground(bootstrapAddrs []*net.TCPAddr) {
+	defer l.wg.Done()
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-l.stop:
+			return
+		case <-time.After(l.config.BootstrapRetryMaxInterval):
+		}
+		l.logger.Info("retrying bootstrap in background",
+			zap.Int(LoggerBootstrapAttempt, attempt))
+		if err := l.bootstrapPeers(bootstrapAddrs); err != nil {
+			// error already logged in bootstrapPeers
+			continue
 		}
+		// check and start under the lock so Close can't stop the background work while it's
+		// starting
+		l.stopMu.Lock()
+		if !l.stopped() {
+			l.startPeerWork()
+		}
+		l.stopMu.Unlock()
+		return
+	}
+}
+
+// stopped returns whether the librarian has been closed.
+func (l *Librarian) stopped() bool {
+	select {
+	case <-l.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// startPeerWork starts the long-running background work that needs peers in the routing table.
+func (l *Librarian) startPeerWork() {
+	// keep buckets without recent lookups fresh now that we have peers to search from
+	l.bucketRefresher.Start()
+
+	// top up replication of stored documents whose replica peers have left
+	if l.config.ReplicationEnabled {
+		l.replicationMaintainer.Start()
+	}
+
+	// long-running goroutine managing subscriptions to other peers
+	go func() {
+		if err := l.subscribeTo.Begin(); err != nil && !l.config.isBootstrap() {
+			l.logger.Error("fatal subscriptionTo error", zap.Error(err))
+			if err := l.Close(); err != nil {
+				panic(err) // don't try to recover from Close error
+			}
+
+		}
+	}()
+}
+
+// makeBootstrapPeers creates peers for the bootstrap addresses other than the librarian's own
+// (local or public) addresses, so a librarian never introduces itself to itself.
+func makeBootstrapPeers(bootstrapAddrs []*net.TCPAddr, selfAddrs []*net.TCPAddr,
+	f peer.Fromer) ([]peer.Peer, []string) {
+	others := bootstrapAddrs
+	for _, self := range selfAddrs {
+		var err error
+		if others, err = api.ExcludeSelf(others, self); err != nil {
+			// no bootstrap addresses besides self, e.g., for the first bootstrap librarian
+			others = nil
+			break
+		}
+	}
+	peers, addrStrs := make([]peer.Peer, 0), make([]string, 0)
+	for i, bootstrap := range others {
+		dummyIDStr := fmt.Sprintf("bootstrap-seed%02d", i)
+		peers = append(peers, f.FromAddress(nil, dummyIDStr, bootstrap))
+		addrStrs = append(addrStrs, bootstrap.String())
 	}
 	return peers, addrStrs
 }
@@ -142,17 +246,42 @@ func (l *Librarian) listenAndServe(up chan *Librarian) error {
 		return err
 	}
 
-	s := grpc.NewServer()
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(chainUnaryInterceptors(
+			l.metrics.unaryInterceptor,
+			l.accessLogger.unaryInterceptor,
+		)),
+		grpc.MaxRecvMsgSize(int(l.config.MaxMessageBytes)),
+		grpc.MaxSendMsgSize(int(l.config.MaxMessageBytes)),
+	}
+	if l.creds != nil {
+		opts = append(opts, grpc.Creds(l.creds))
+	}
+	s := grpc.NewServer(opts...)
 	api.RegisterLibrarianServer(s, l)
 	healthpb.RegisterHealthServer(s, l.health)
 	reflection.Register(s)
 
+	// serve Prometheus metrics alongside gRPC requests
+	metricsServer := &http.Server{
+		Addr:    l.config.LocalMetricsAddr.String(),
+		Handler: promhttp.HandlerFor(l.metrics.registry, promhttp.HandlerOpts{}),
+	}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.logger.Error("failed to serve metrics", zap.Error(err))
+		}
+	}()
+
 	// handle stop signal
 	go func() {
 		<-l.stop
 		l.logger.Info("gracefully stopping server", zap.Int(LoggerPortKey,
 			l.config.LocalAddr.Port))
 		s.GracefulStop()
+		if err := metricsServer.Close(); err != nil {
+			l.logger.Error("failed to close metrics server", zap.Error(err))
+		}
 	}()
 
 	// handle stop stopSignals from outside world
@@ -169,25 +298,14 @@ func (l *Librarian) listenAndServe(up chan *Librarian) error {
 	// long-running goroutine managing subscriptions from other peers
 	go l.subscribeFrom.Fanout()
 
-	// long-running goroutine managing subscriptions to other peers
-	go func() {
-		if err := l.subscribeTo.Begin(); err != nil && !l.config.isBootstrap() {
-			l.logger.Error("fatal subscriptionTo error", zap.Error(err))
-			if err := l.Close(); err != nil {
-				panic(err) // don't try to recover from Close error
-			}
-
-		}
-	}()
-
 	// notify up channel shortly after starting to serve requests
 	go func() {
 		time.Sleep(postListenNotifyWait)
 		l.logger.Info("listening for requests", zap.Int(LoggerPortKey,
 			l.config.LocalAddr.Port))
 
-		// set top-level health status
-		l.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		// report top-level health status from the number of peers in the routing table
+		l.healthReporter.Start()
 
 		up <- l
 	}()
@@ -212,12 +330,18 @@ func (l *Librarian) Close() error {
 
 	l.EndSubscriptions()
 
-	// send stop signal to listener
-	select {
-	case <-l.stop: // already closed
-	default:
+	// send stop signal to listener and background bootstrap retries
+	l.stopMu.Lock()
+	if !l.stopped() {
 		close(l.stop)
 	}
+	l.stopMu.Unlock()
+	l.wg.Wait()
+
+	// stop background work using the routing table before it's disconnected and saved
+	l.healthReporter.Stop()
+	l.bucketRefresher.Stop()
+	l.replicationMaintainer.Stop()
 
 	// disconnect from peers in routing table
 	if err := l.rt.Disconnect(); err != nil {
@@ -229,6 +353,11 @@ func (l *Librarian) Close() error {
 		return err
 	}
 
+	// stop deleting expired documents before closing the DB they're in
+	if l.expirySweeper != nil {
+		l.expirySweeper.Stop()
+	}
+
 	// close the DB
 	l.db.Close()
 
@@ -1,25 +1,27 @@
 package server
 
 import (
+	"errors"
 	"io/ioutil"
 	"math/rand"
 	"net"
 	"testing"
-	"errors"
 	"time"
 
+	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
 	clogging "github.com/drausin/libri/libri/common/logging"
 	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/introduce"
 	"github.com/drausin/libri/libri/librarian/server/peer"
 	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
-	"github.com/drausin/libri/libri/common/ecid"
 )
 
 func TestStart_ok(t *testing.T) {
@@ -60,6 +62,75 @@ func TestStart_ok(t *testing.T) {
 	assert.Nil(t, librarian.CloseAndRemove())
 }
 
+func TestStart_maxMessageBytes(t *testing.T) {
+	// start a single librarian storing documents larger than gRPC's 4 MB default message size
+	maxDocBytes := uint64(6 * 1024 * 1024)
+	config := newTestConfig().
+		WithMaxDocumentBytes(maxDocBytes).
+		WithMaxMessageBytes(maxDocBytes + 64*1024)
+	up := make(chan *Librarian, 1)
+	go func() {
+		err := Start(clogging.NewDevInfoLogger(), config, up)
+		assert.Nil(t, err)
+	}()
+	librarian := <-up
+
+	// create document just under the max size
+	rng := rand.New(rand.NewSource(0))
+	page := api.NewTestPage(rng)
+	page.Ciphertext = api.RandBytes(rng, int(maxDocBytes)-1024)
+	doc := &api.Document{Contents: &api.Document_Page{Page: page}}
+	key, err := api.GetKey(doc)
+	assert.Nil(t, err)
+	clientID := ecid.NewPseudoRandom(rng)
+	signer := client.NewSigner(clientID.Key())
+
+	conn, err := api.Dial(config.LocalAddr, nil,
+		api.WithMaxMessageBytes(int(config.MaxMessageBytes)))
+	assert.Nil(t, err)
+	lc := api.NewLibrarianClient(conn)
+
+	// check document round-trips via Store and Find
+	storeRq := client.NewStoreRequest(clientID, key, doc)
+	ctx, err := client.NewSignedContext(signer, storeRq)
+	assert.Nil(t, err)
+	_, err = lc.Store(ctx, storeRq)
+	assert.Nil(t, err)
+
+	findRq := client.NewFindRequest(clientID, key, 1)
+	ctx, err = client.NewSignedContext(signer, findRq)
+	assert.Nil(t, err)
+	findRp, err := lc.Find(ctx, findRq)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(doc, findRp.Value))
+
+	// check client with default max message size can't receive it
+	conn2, err := api.Dial(config.LocalAddr, nil)
+	assert.Nil(t, err)
+	findRq = client.NewFindRequest(clientID, key, 1)
+	ctx, err = client.NewSignedContext(signer, findRq)
+	assert.Nil(t, err)
+	findRp, err = api.NewLibrarianClient(conn2).Find(ctx, findRq)
+	assert.NotNil(t, err)
+	assert.Nil(t, findRp)
+
+	// check librarian's own peer connections can receive it, as when replicating
+	p := librarian.fromer.FromAddress(nil, "self", config.LocalAddr)
+	plc, err := p.Connector().Connect()
+	assert.Nil(t, err)
+	findRq = client.NewFindRequest(clientID, key, 1)
+	ctx, err = client.NewSignedContext(signer, findRq)
+	assert.Nil(t, err)
+	findRp, err = plc.Find(ctx, findRq)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(doc, findRp.Value))
+	assert.Nil(t, p.Connector().Disconnect())
+
+	assert.Nil(t, conn.Close())
+	assert.Nil(t, conn2.Close())
+	assert.Nil(t, librarian.CloseAndRemove())
+}
+
 func TestStart_newLibrarianErr(t *testing.T) {
 	config := &Config{
 		DataDir: "some/nonexistant/path",
@@ -85,6 +156,97 @@ func TestStart_bootstrapPeersErr(t *testing.T) {
 	assert.NotNil(t, Start(clogging.NewDevInfoLogger(), config, make(chan *Librarian, 1)))
 }
 
+func TestStart_bootstrapInBackground(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "test-start")
+	assert.Nil(t, err)
+	config := NewDefaultConfig()
+	config.WithDataDir(dataDir).WithDefaultDBDir()
+	config.WithBootstrapAddrs(make([]*net.TCPAddr, 0))
+	config.WithBootstrapRetryMaxElapsedTime(100 * time.Millisecond)
+	config.WithBootstrapInBackground(true)
+
+	// configure bootstrap peer to be non-existent peer
+	publicAddr, err := ParseAddr(DefaultIP, DefaultPort+1)
+	assert.Nil(t, err)
+	config.BootstrapAddrs = append(config.BootstrapAddrs, publicAddr)
+
+	// check that librarian still serves requests despite bootstrap error
+	up := make(chan *Librarian, 1)
+	go func() {
+		err = Start(clogging.NewDevInfoLogger(), config, up)
+		assert.Nil(t, err)
+	}()
+	librarian := <-up
+
+	conn, err := grpc.Dial(config.LocalAddr.String(), grpc.WithInsecure())
+	assert.Nil(t, err)
+	client := api.NewLibrarianClient(conn)
+	rp, err := client.Ping(context.Background(), &api.PingRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, "pong", rp.Message)
+
+	// check closing stops the background bootstrap
+	assert.Nil(t, librarian.CloseAndRemove())
+}
+
+func TestLibrarian_bootstrapPeersInBackground_stop(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	l := &Librarian{
+		config: NewDefaultConfig().WithBootstrapRetryMaxInterval(10 * time.Millisecond),
+		selfID: ecid.NewPseudoRandom(rng),
+		introducer: &fixedIntroducer{
+			err: errors.New("some introduce error"),
+		},
+		rt:     routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters()),
+		fromer: peer.NewFromer(),
+		logger: clogging.NewDevInfoLogger(),
+		stop:   make(chan struct{}),
+	}
+	l.config.WithBootstrapRetryMaxElapsedTime(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	l.wg.Add(1)
+	go func() {
+		l.bootstrapPeersInBackground([]*net.TCPAddr{peer.NewTestPublicAddr(0)})
+		close(done)
+	}()
+
+	// check background retries end once the librarian is stopped
+	time.Sleep(50 * time.Millisecond)
+	close(l.stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "background bootstrap did not stop")
+	}
+	assert.Equal(t, 0, l.rt.NumPeers())
+}
+
+func TestLibrarian_bootstrapPeersInBackground_closedWhileBootstrapping(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	fixedResult := introduce.NewInitialResult()
+	for _, p := range peer.NewTestPeers(rng, 4) {
+		fixedResult.Responded[p.ID().String()] = p
+	}
+	l := &Librarian{
+		config: NewDefaultConfig().WithBootstrapRetryMaxInterval(10 * time.Millisecond),
+		selfID: ecid.NewPseudoRandom(rng),
+		rt:     routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters()),
+		fromer: peer.NewFromer(),
+		logger: clogging.NewDevInfoLogger(),
+		stop:   make(chan struct{}),
+	}
+	l.introducer = &closingIntroducer{l: l, result: fixedResult}
+
+	// check background work isn't started (which would panic on its nil workers) when closed
+	// during a successful bootstrap, and that Close waits for the bootstrap to end
+	l.wg.Add(1)
+	go l.bootstrapPeersInBackground([]*net.TCPAddr{peer.NewTestPublicAddr(0)})
+	<-l.stop
+	l.wg.Wait()
+	assert.Equal(t, 4, l.rt.NumPeers())
+}
+
 func TestLibrarian_bootstrapPeers_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	nSeeds, nPeers := 3, 8
@@ -107,6 +269,7 @@ func TestLibrarian_bootstrapPeers_ok(t *testing.T) {
 			result: fixedResult,
 		},
 		rt:     routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters()),
+		fromer: peer.NewFromer(),
 		logger: clogging.NewDevInfoLogger(),
 	}
 
@@ -122,6 +285,43 @@ func TestLibrarian_bootstrapPeers_ok(t *testing.T) {
 	}
 }
 
+func TestLibrarian_bootstrapPeers_excludeSelf(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	fixedResult := introduce.NewInitialResult()
+	for _, p := range peer.NewTestPeers(rng, 4) {
+		fixedResult.Responded[p.ID().String()] = p
+	}
+	introducer := &fixedIntroducer{result: fixedResult}
+	localAddr, err := ParseAddr("0.0.0.0", DefaultPort)
+	assert.Nil(t, err)
+	l := &Librarian{
+		config: NewDefaultConfig().
+			WithLocalAddr(localAddr).
+			WithPublicAddr(peer.NewTestPublicAddr(1)),
+		selfID:     ecid.NewPseudoRandom(rng),
+		introducer: introducer,
+		rt:         routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters()),
+		fromer:     peer.NewFromer(),
+		logger:     clogging.NewDevInfoLogger(),
+	}
+
+	// check loopback address on the local port and public address are excluded
+	seeds := []*net.TCPAddr{
+		peer.NewTestPublicAddr(0),
+		peer.NewTestPublicAddr(1),
+		peer.NewTestPublicAddr(2),
+	}
+	err = l.bootstrapPeers(seeds)
+	assert.Nil(t, err)
+	assert.Len(t, introducer.seeds, 1)
+	assert.Equal(t, peer.NewTestPublicAddr(2), introducer.seeds[0].Connector().Address())
+
+	// check no seeds are introduced to when self is the only bootstrap address
+	err = l.bootstrapPeers([]*net.TCPAddr{peer.NewTestPublicAddr(1)})
+	assert.Nil(t, err)
+	assert.Len(t, introducer.seeds, 0)
+}
+
 func TestLibrarian_bootstrapPeers_introduceErr(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	nSeeds := 3
@@ -139,6 +339,7 @@ func TestLibrarian_bootstrapPeers_introduceErr(t *testing.T) {
 			err: errors.New("some fatal introduce error"),
 		},
 		rt:     routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters()),
+		fromer: peer.NewFromer(),
 		logger: clogging.NewDevInfoLogger(),
 	}
 
@@ -170,6 +371,7 @@ func TestLibrarian_bootstrapPeers_noResponsesErr(t *testing.T) {
 			result: fixedResult,
 		},
 		rt:     routing.NewEmpty(cid.NewPseudoRandom(rng), routing.NewDefaultParameters()),
+		fromer: peer.NewFromer(),
 		logger: clogging.NewDevInfoLogger(),
 	}
 
@@ -180,9 +382,27 @@ func TestLibrarian_bootstrapPeers_noResponsesErr(t *testing.T) {
 type fixedIntroducer struct {
 	result *introduce.Result
 	err    error
+	seeds  []peer.Peer
 }
 
 func (fi *fixedIntroducer) Introduce(intro *introduce.Introduction, seeds []peer.Peer) error {
+	fi.seeds = seeds
 	intro.Result = fi.result
 	return fi.err
 }
+
+// closingIntroducer closes the librarian's stop channel (like Close) while introducing.
+type closingIntroducer struct {
+	l      *Librarian
+	result *introduce.Result
+}
+
+func (ci *closingIntroducer) Introduce(intro *introduce.Introduction, seeds []peer.Peer) error {
+	ci.l.stopMu.Lock()
+	if !ci.l.stopped() {
+		close(ci.l.stop)
+	}
+	ci.l.stopMu.Unlock()
+	intro.Result = ci.result
+	return nil
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/drausin/libri/libri/common/subscribe"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/drausin/libri/libri/librarian/server/search"
+	"github.com/drausin/libri/libri/librarian/server/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willf/bloom"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	metricsNamespace = "libri"
+	metricsSubsystem = "librarian"
+)
+
+// metrics holds the Prometheus metrics of a single Librarian, registered to its own registry so
+// multiple librarians may run in the same process.
+type metrics struct {
+	registry *prometheus.Registry
+
+	// counts handled RPC requests by method and outcome
+	requests *prometheus.CounterVec
+
+	// durations of handled RPC requests by method
+	requestDuration *prometheus.HistogramVec
+
+	// sizes (in bits) of the author and reader filters of subscriptions from other peers
+	subscriptionFilterBits *prometheus.HistogramVec
+
+	// metrics of the librarian's searcher, storer, and subscription deliveries
+	search    *search.Metrics
+	store     *store.Metrics
+	subscribe *subscribe.Metrics
+}
+
+func newMetrics(rt routing.Table) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "requests_total",
+			Help:      "Number of RPC requests handled, by method and outcome.",
+		}, []string{"method", "outcome"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of RPC requests handled, by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"method"}),
+		subscriptionFilterBits: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "subscription_filter_bits",
+			Help:      "Size of subscription filters from other peers, by filter.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+		}, []string{"filter"}),
+		search:    search.NewMetrics(),
+		store:     store.NewMetrics(),
+		subscribe: subscribe.NewMetrics(),
+	}
+	routingTablePeers := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "routing_table_peers",
+		Help:      "Number of peers in the routing table.",
+	}, func() float64 { return float64(rt.NumPeers()) })
+	routingTableBuckets := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "routing_table_buckets",
+		Help:      "Number of buckets in the routing table.",
+	}, func() float64 { return float64(rt.NumBuckets()) })
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.subscriptionFilterBits,
+		routingTablePeers,
+		routingTableBuckets,
+	)
+	m.search.Register(m.registry)
+	m.store.Register(m.registry)
+	m.subscribe.Register(m.registry)
+	return m
+}
+
+// unaryInterceptor records the count and duration of each unary RPC request.
+func (m *metrics) unaryInterceptor(
+	ctx context.Context,
+	rq interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	rp, err := handler(ctx, rq)
+	method := methodName(info.FullMethod)
+	m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.requests.WithLabelValues(method, outcome).Inc()
+	return rp, err
+}
+
+// observeSubscription records the sizes of a subscription's filters. It is a no-op for nil
+// metrics.
+func (m *metrics) observeSubscription(authorFilter, readerFilter *bloom.BloomFilter) {
+	if m == nil {
+		return
+	}
+	m.subscriptionFilterBits.WithLabelValues("author").Observe(float64(authorFilter.Cap()))
+	m.subscriptionFilterBits.WithLabelValues("reader").Observe(float64(readerFilter.Cap()))
+}
+
+// methodName returns the short method name (e.g., "Store") from the full gRPC method name (e.g.,
+// "/api.Librarian/Store").
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/willf/bloom"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestMetrics_unaryInterceptor(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt := routing.NewEmpty(ecid.NewPseudoRandom(rng), routing.NewDefaultParameters())
+	m := newMetrics(rt)
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Librarian/Store"}
+	errTest := errors.New("some handler error")
+
+	ok := func(ctx context.Context, rq interface{}) (interface{}, error) { return rq, nil }
+	rp, err := m.unaryInterceptor(context.Background(), "rq", info, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, "rq", rp)
+
+	fail := func(ctx context.Context, rq interface{}) (interface{}, error) {
+		return nil, errTest
+	}
+	rp, err = m.unaryInterceptor(context.Background(), "rq", info, fail)
+	assert.Equal(t, errTest, err)
+	assert.Nil(t, rp)
+
+	counts := gatheredCounts(t, m, "libri_librarian_requests_total")
+	assert.Equal(t, map[string]float64{"success": 1, "error": 1}, counts)
+}
+
+func TestMetrics_observeSubscription(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt := routing.NewEmpty(ecid.NewPseudoRandom(rng), routing.NewDefaultParameters())
+	m := newMetrics(rt)
+	m.observeSubscription(bloom.New(128, 2), bloom.New(256, 2))
+
+	mfs, err := m.registry.Gather()
+	assert.Nil(t, err)
+	var found bool
+	for _, mf := range mfs {
+		if mf.GetName() == "libri_librarian_subscription_filter_bits" {
+			found = true
+			assert.Len(t, mf.Metric, 2)
+		}
+	}
+	assert.True(t, found)
+
+	// check nil metrics is a no-op
+	var nilMetrics *metrics
+	nilMetrics.observeSubscription(bloom.New(128, 2), bloom.New(256, 2))
+}
+
+func TestMethodName(t *testing.T) {
+	assert.Equal(t, "Store", methodName("/api.Librarian/Store"))
+	assert.Equal(t, "Store", methodName("Store"))
+}
+
+// gatheredCounts returns the counter values of the named metric family by outcome label.
+func gatheredCounts(t *testing.T, m *metrics, name string) map[string]float64 {
+	mfs, err := m.registry.Gather()
+	assert.Nil(t, err)
+	counts := make(map[string]float64)
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, metric := range mf.Metric {
+			for _, label := range metric.Label {
+				if label.GetName() == "outcome" {
+					counts[label.GetValue()] = metric.Counter.GetValue()
+				}
+			}
+		}
+	}
+	return counts
+}
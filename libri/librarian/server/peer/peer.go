@@ -2,10 +2,14 @@ package peer
 
 import (
 	"fmt"
+	"net"
+	"sync"
 
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/librarian/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const (
@@ -25,6 +29,20 @@ type Peer interface {
 	// Recorder returns the Recorder instance for recording query outcomes.
 	Recorder() Recorder
 
+	// RecordSuccess records a successful query to the peer, increasing its reputation.
+	RecordSuccess()
+
+	// RecordFailure records a failed query to the peer, decreasing its reputation.
+	RecordFailure()
+
+	// WatchReputation sets the function called with the peer after each RecordSuccess or
+	// RecordFailure changes its reputation, replacing any previously set function.
+	WatchReputation(onChange func(Peer))
+
+	// Reputation returns the peer's reputation score from its query successes and failures,
+	// which decays toward zero over time. Positive scores indicate mostly successful queries.
+	Reputation() float64
+
 	// Before returns whether p should be ordered before q in the priority queue of peers to
 	// query. Currently, it just uses whether p's latest response time is before q's.
 	Before(other Peer) bool
@@ -52,15 +70,25 @@ type peer struct {
 
 	// tracks query outcomes from the peer
 	recorder Recorder
+
+	// score from query successes and failures
+	reputation *reputation
+
+	// called after each change to the reputation, e.g., to re-heap the peer's bucket
+	onReputationChange func(Peer)
+
+	// guards onReputationChange
+	mu sync.Mutex
 }
 
 // New creates a new Peer instance with empty response stats.
 func New(id cid.ID, name string, conn api.Connector) Peer {
 	return &peer{
-		id:       id,
-		name:     name,
-		conn:     conn,
-		recorder: newQueryRecorder(),
+		id:         id,
+		name:       name,
+		conn:       conn,
+		recorder:   newQueryRecorder(),
+		reputation: newReputation(),
 	}
 }
 
@@ -79,7 +107,11 @@ func (p *peer) ID() cid.ID {
 }
 
 func (p *peer) Before(q Peer) bool {
-	pr, qr := p.recorder.(*queryRecorder), q.(*peer).recorder.(*queryRecorder)
+	pr, ok1 := p.recorder.(*queryRecorder)
+	qr, ok2 := q.Recorder().(*queryRecorder)
+	if !ok1 || !ok2 {
+		return false
+	}
 	return pr.responses.latest.Before(qr.responses.latest)
 }
 
@@ -88,13 +120,20 @@ func (p *peer) Merge(other Peer) error {
 		return fmt.Errorf("attempting to merge two different peers with IDs %v and %v",
 			p.id, other.ID())
 	}
-	if other.(*peer).name != "" {
-		p.name = other.(*peer).name
-	}
 	if other.Connector() != nil {
 		p.conn = other.Connector()
 	}
 	p.recorder.Merge(other.Recorder())
+	op, ok := other.(*peer)
+	if !ok {
+		// other Peer implementations only expose their current reputation
+		p.reputation.Record(other.Reputation())
+		return nil
+	}
+	if op.name != "" {
+		p.name = op.name
+	}
+	p.reputation.Merge(op.reputation)
 	return nil
 }
 
@@ -106,6 +145,35 @@ func (p *peer) Recorder() Recorder {
 	return p.recorder
 }
 
+func (p *peer) RecordSuccess() {
+	p.reputation.Record(successScore)
+	p.notifyReputationChange()
+}
+
+func (p *peer) RecordFailure() {
+	p.reputation.Record(failureScore)
+	p.notifyReputationChange()
+}
+
+func (p *peer) WatchReputation(onChange func(Peer)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onReputationChange = onChange
+}
+
+func (p *peer) notifyReputationChange() {
+	p.mu.Lock()
+	onChange := p.onReputationChange
+	p.mu.Unlock()
+	if onChange != nil {
+		onChange(p)
+	}
+}
+
+func (p *peer) Reputation() float64 {
+	return p.reputation.Score()
+}
+
 func (p *peer) ToStored() *storage.Peer {
 	return &storage.Peer{
 		Id:            p.id.Bytes(),
@@ -133,23 +201,42 @@ func ToAPIs(peers []Peer) []*api.PeerAddress {
 	return addresses
 }
 
-// Fromer creates new Peer instances from api.PeerAddresses.
+// Fromer creates new Peer instances from api.PeerAddresses, storage.Peers, and addresses.
 type Fromer interface {
 	// New creates a new Peer instance.
 	FromAPI(address *api.PeerAddress) Peer
+
+	// FromStored creates a new Peer instance from a storage.Peer instance.
+	FromStored(stored *storage.Peer) Peer
+
+	// FromAddress creates a new Peer instance with the given ID and name at the given address.
+	FromAddress(id cid.ID, name string, address *net.TCPAddr) Peer
 }
 
-type fromer struct{}
+type fromer struct {
+	creds    credentials.TransportCredentials
+	dialOpts []grpc.DialOption
+}
 
-// NewFromer returns a new Fromer instance.
+// NewFromer returns a new Fromer instance whose peers' connections have no transport security.
 func NewFromer() Fromer {
 	return &fromer{}
 }
 
+// NewSecureFromer returns a new Fromer instance whose peers' connections are secured with the
+// given transport credentials and dialed with the given gRPC dial options, as in api.Dial.
+func NewSecureFromer(creds credentials.TransportCredentials, dialOpts ...grpc.DialOption) Fromer {
+	return &fromer{creds: creds, dialOpts: dialOpts}
+}
+
 func (f *fromer) FromAPI(apiAddress *api.PeerAddress) Peer {
-	return New(
+	return f.FromAddress(
 		cid.FromBytes(apiAddress.PeerId),
 		apiAddress.PeerName,
-		api.NewConnector(api.ToAddress(apiAddress)),
+		api.ToAddress(apiAddress),
 	)
 }
+
+func (f *fromer) FromAddress(id cid.ID, name string, address *net.TCPAddr) Peer {
+	return New(id, name, api.NewSecureConnector(address, f.creds, f.dialOpts...))
+}
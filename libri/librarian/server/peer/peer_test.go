@@ -1,6 +1,7 @@
 package peer
 
 import (
+	"crypto/tls"
 	"math/rand"
 	"net"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
 )
 
 func TestNew(t *testing.T) {
@@ -144,6 +146,69 @@ func TestPeer_Merge_ok(t *testing.T) {
 	assert.Equal(t, p2Conn, p1.Connector())
 }
 
+func TestPeer_Reputation(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	p := NewTestPeer(rng, 0)
+	assert.Zero(t, p.Reputation())
+
+	p.RecordSuccess()
+	assert.True(t, p.Reputation() > 0)
+	p.RecordFailure()
+	assert.True(t, p.Reputation() < 0)
+}
+
+func TestPeer_Merge_reputation(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	p1 := NewTestPeer(rng, 0)
+	p2 := New(p1.ID(), "", p1.Connector())
+	p1.RecordSuccess()
+	p2.RecordFailure()
+	p2.RecordFailure()
+
+	// check merged reputation has outcomes from both peers
+	err := p1.Merge(p2)
+	assert.Nil(t, err)
+	assert.InDelta(t, successScore+2*failureScore, p1.Reputation(), 1e-6)
+}
+
+// wrappedPeer is a Peer implementation other than *peer.
+type wrappedPeer struct {
+	Peer
+}
+
+func TestPeer_Merge_otherImpl(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	p1 := NewTestPeer(rng, 0)
+	p1Name := p1.(*peer).name
+	p2 := New(p1.ID(), "other", p1.Connector())
+	p2.RecordFailure()
+
+	// merging a different Peer implementation shouldn't panic and keeps its reputation
+	err := p1.Merge(&wrappedPeer{p2})
+	assert.Nil(t, err)
+	assert.Equal(t, p1Name, p1.(*peer).name)
+	assert.InDelta(t, failureScore, p1.Reputation(), 1e-6)
+	assert.False(t, p1.Before(&wrappedPeer{p2}))
+}
+
+func TestPeer_WatchReputation(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	p := NewTestPeer(rng, 0)
+	nChanges := 0
+	p.WatchReputation(func(changed Peer) {
+		assert.Equal(t, p, changed)
+		nChanges++
+	})
+	p.RecordSuccess()
+	p.RecordFailure()
+	assert.Equal(t, 2, nChanges)
+
+	// no longer called once unset
+	p.WatchReputation(nil)
+	p.RecordSuccess()
+	assert.Equal(t, 2, nChanges)
+}
+
 func TestPeer_Merge_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	var p1, p2 Peer
@@ -175,6 +240,18 @@ func TestFromer_FromAPI(t *testing.T) {
 	assert.Equal(t, p1.Connector().Address(), p2.Connector().Address())
 }
 
+func TestFromer_FromAddress(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	p1 := NewTestPeer(rng, 0)
+	f := NewSecureFromer(credentials.NewTLS(&tls.Config{}), api.WithMaxMessageBytes(1024))
+	assert.Len(t, f.(*fromer).dialOpts, 1)
+	p2 := f.FromAddress(p1.ID(), "some name", p1.Connector().Address())
+
+	assert.Equal(t, p1.ID(), p2.ID())
+	assert.Equal(t, p1.Connector().Address(), p2.Connector().Address())
+	assert.Equal(t, "some name", p2.(*peer).name)
+}
+
 func TestToAPIs(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	ns := []int{0, 1, 2, 4}
@@ -65,8 +65,12 @@ func (qr *queryRecorder) Record(t QueryType, o Outcome) {
 }
 
 func (qr *queryRecorder) Merge(other Recorder) {
-	qr.requests.Merge(other.(*queryRecorder).requests)
-	qr.responses.Merge(other.(*queryRecorder).responses)
+	oqr, ok := other.(*queryRecorder)
+	if !ok {
+		return
+	}
+	qr.requests.Merge(oqr.requests)
+	qr.responses.Merge(oqr.responses)
 }
 
 func (qr *queryRecorder) ToStored() *storage.QueryOutcomes {
@@ -0,0 +1,65 @@
+package peer
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ReputationHalfLife is the duration after which a peer's reputation from past query outcomes
+// has decayed by half, so recent outcomes matter more than old ones.
+const ReputationHalfLife = 1 * time.Hour
+
+const (
+	// successScore is the reputation score added for each successful query.
+	successScore = 1.0
+
+	// failureScore is the reputation score added for each failed query, which is weighted more
+	// heavily than success so that peers that often time out are quickly disfavored.
+	failureScore = -2.0
+)
+
+// reputation is an exponentially decaying score of a peer's query successes and failures.
+type reputation struct {
+	// score as of the updated time
+	score float64
+
+	// when the score was last updated
+	updated time.Time
+
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+func newReputation() *reputation {
+	return &reputation{now: time.Now}
+}
+
+// Record adds the given score to the (decayed) current score.
+func (r *reputation) Record(score float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.score = r.scoreAt(now) + score
+	r.updated = now
+}
+
+// Score returns the current (decayed) score.
+func (r *reputation) Score() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.scoreAt(r.now())
+}
+
+// Merge adds the other reputation's current score to this one's.
+func (r *reputation) Merge(other *reputation) {
+	r.Record(other.Score())
+}
+
+func (r *reputation) scoreAt(t time.Time) float64 {
+	if r.score == 0 {
+		return 0
+	}
+	elapsed := t.Sub(r.updated)
+	return r.score * math.Pow(0.5, float64(elapsed)/float64(ReputationHalfLife))
+}
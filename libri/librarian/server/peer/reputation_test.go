@@ -0,0 +1,57 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReputation_Record(t *testing.T) {
+	r := newReputation()
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+	assert.Zero(t, r.Score())
+
+	r.Record(successScore)
+	r.Record(successScore)
+	assert.Equal(t, 2*successScore, r.Score())
+
+	r.Record(failureScore)
+	assert.Equal(t, 2*successScore+failureScore, r.Score())
+}
+
+func TestReputation_Score_decay(t *testing.T) {
+	r := newReputation()
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+	r.Record(4 * successScore)
+
+	// check score halves every half-life
+	now = now.Add(ReputationHalfLife)
+	assert.InDelta(t, 2*successScore, r.Score(), 1e-9)
+	now = now.Add(ReputationHalfLife)
+	assert.InDelta(t, successScore, r.Score(), 1e-9)
+
+	// check new outcomes add to decayed score
+	r.Record(failureScore)
+	assert.InDelta(t, successScore+failureScore, r.Score(), 1e-9)
+
+	// check negative scores also decay toward zero
+	now = now.Add(10 * ReputationHalfLife)
+	assert.InDelta(t, 0.0, r.Score(), 1e-3)
+	assert.True(t, r.Score() < 0)
+}
+
+func TestReputation_Merge(t *testing.T) {
+	now := time.Unix(0, 0)
+	r1, r2 := newReputation(), newReputation()
+	r1.now = func() time.Time { return now }
+	r2.now = r1.now
+	r1.Record(successScore)
+	r2.Record(failureScore)
+	now = now.Add(ReputationHalfLife)
+
+	r1.Merge(r2)
+	assert.InDelta(t, (successScore+failureScore)/2, r1.Score(), 1e-9)
+}
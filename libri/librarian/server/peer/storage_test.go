@@ -12,7 +12,7 @@ import (
 
 func TestFromStored(t *testing.T) {
 	sp := NewTestStoredPeer(rand.New(rand.NewSource(0)), 0)
-	p := FromStored(sp)
+	p := NewFromer().FromStored(sp)
 	AssertPeersEqual(t, sp, p)
 }
 
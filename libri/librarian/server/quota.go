@@ -0,0 +1,80 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/drausin/libri/libri/common/db"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrStorageQuotaExceeded indicates when a new document can't be stored because the librarian's
+// storage quota has been reached.
+var ErrStorageQuotaExceeded = grpc.Errorf(codes.ResourceExhausted,
+	"librarian storage quota exceeded")
+
+// ErrDocumentTooLarge indicates when a document can't be stored because it's larger than the
+// librarian's maximum document size.
+var ErrDocumentTooLarge = grpc.Errorf(codes.InvalidArgument,
+	"document larger than librarian maximum size")
+
+// StorageQuota caps the total number of bytes a librarian stores. Usage is measured from the size
+// of the DB on disk rather than a running counter, so it can't drift from what is actually
+// stored, plus the bytes reserved by stores still in progress. Once the quota is reached, new
+// documents are rejected rather than replacing expired ones; the expiry sweeper deletes those,
+// making room for new documents once RocksDB compacts them away.
+type StorageQuota struct {
+	maxBytes uint64
+	sizer    db.Sizer
+	docs     storage.DocumentLoader
+	reserved uint64
+	mu       sync.Mutex
+}
+
+// NewStorageQuota creates a new *StorageQuota allowing at most maxBytes to be stored, as measured
+// by the sizer. A zero maxBytes allows an unlimited number of bytes.
+func NewStorageQuota(maxBytes uint64, sizer db.Sizer, docs storage.DocumentLoader) *StorageQuota {
+	return &StorageQuota{
+		maxBytes: maxBytes,
+		sizer:    sizer,
+		docs:     docs,
+	}
+}
+
+// Reserve reserves nBytes of the quota for storing the document with the given key, returning
+// ErrStorageQuotaExceeded if they don't fit and the document is not already stored. Checking and
+// reserving happen atomically, so concurrent stores can't all fit into the same free space. The
+// returned release function must be called once the document has been written (or not), after
+// which its bytes are counted by the DB size. A nil *StorageQuota allows every document.
+func (q *StorageQuota) Reserve(key cid.ID, nBytes uint64) (func(), error) {
+	if q == nil || q.maxBytes == 0 {
+		return func() {}, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	size, err := q.sizer.Size()
+	if err != nil {
+		return nil, err
+	}
+	if size+q.reserved+nBytes > q.maxBytes {
+		existing, err := q.docs.Load(key)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, ErrStorageQuotaExceeded
+		}
+		// replacing an existing document doesn't add to the usage
+	}
+	q.reserved += nBytes
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.reserved -= nBytes
+		})
+	}, nil
+}
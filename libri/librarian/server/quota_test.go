@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/db"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestStorageQuota_Reserve_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := cid.NewPseudoRandom(rng)
+	value, _ := api.NewTestDocument(rng)
+
+	// check no max, usage under the max, and replacing an existing document are all allowed
+	cases := []*StorageQuota{
+		nil, // nil allows everything
+		NewStorageQuota(0, &fixedSizer{size: 1 << 40}, &fixedDocLoader{}),
+		NewStorageQuota(1024, &fixedSizer{size: 512}, &fixedDocLoader{}),
+		NewStorageQuota(1024, &fixedSizer{size: 2048}, &fixedDocLoader{value: value}),
+	}
+	for i, q := range cases {
+		release, err := q.Reserve(key, 256)
+		assert.Nil(t, err, "case %d", i)
+		release()
+	}
+}
+
+func TestStorageQuota_Reserve_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := cid.NewPseudoRandom(rng)
+
+	// check quota reached for new document
+	q1 := NewStorageQuota(1024, &fixedSizer{size: 1024}, &fixedDocLoader{})
+	release, err := q1.Reserve(key, 1)
+	assert.Equal(t, ErrStorageQuotaExceeded, err)
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+	assert.Nil(t, release)
+
+	// check Size error bubbles up
+	q2 := NewStorageQuota(1024, &fixedSizer{err: errors.New("some Size error")},
+		&fixedDocLoader{})
+	_, err = q2.Reserve(key, 1)
+	assert.NotNil(t, err)
+
+	// check Load error bubbles up
+	q3 := NewStorageQuota(1024, &fixedSizer{size: 2048},
+		&fixedDocLoader{err: errors.New("some Load error")})
+	_, err = q3.Reserve(key, 1)
+	assert.NotNil(t, err)
+}
+
+func TestStorageQuota_Reserve_concurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	q := NewStorageQuota(1024, &fixedSizer{size: 512}, &fixedDocLoader{})
+
+	// check concurrent reservations can't together exceed the free space
+	nReserves := 8
+	errs := make(chan error, nReserves)
+	releases := make(chan func(), nReserves)
+	var wg sync.WaitGroup
+	for c := 0; c < nReserves; c++ {
+		wg.Add(1)
+		go func(key cid.ID) {
+			defer wg.Done()
+			release, err := q.Reserve(key, 128)
+			errs <- err
+			if err == nil {
+				releases <- release
+			}
+		}(cid.NewPseudoRandom(rng))
+	}
+	wg.Wait()
+	close(errs)
+	close(releases)
+	nExceeded := 0
+	for err := range errs {
+		if err != nil {
+			assert.Equal(t, ErrStorageQuotaExceeded, err)
+			nExceeded++
+		}
+	}
+	assert.Equal(t, nReserves-4, nExceeded)
+
+	// check released reservations free up the space again, even if released twice
+	for release := range releases {
+		release()
+		release()
+	}
+	release, err := q.Reserve(cid.NewPseudoRandom(rng), 512)
+	assert.Nil(t, err)
+	release()
+}
+
+func TestStorageQuota_Reserve_rocksDB(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	docs := storage.NewDocumentSLD(kvdb)
+	size, err := kvdb.Size()
+	assert.Nil(t, err)
+	q := NewStorageQuota(size+1, kvdb, docs)
+
+	// check documents are stored until the quota is reached
+	value1, key1 := api.NewTestDocument(rng)
+	release, err := q.Reserve(key1, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, docs.Store(key1, value1))
+	release()
+	_, key2 := api.NewTestDocument(rng)
+	_, err = q.Reserve(key2, 1)
+	assert.Equal(t, ErrStorageQuotaExceeded, err)
+
+	// check existing document can still be replaced
+	release, err = q.Reserve(key1, 1)
+	assert.Nil(t, err)
+	release()
+}
+
+type fixedSizer struct {
+	size uint64
+	err  error
+}
+
+func (f *fixedSizer) Size() (uint64, error) {
+	return f.size, f.err
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	lru "github.com/hashicorp/golang-lru"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// storeLimiterNPeers is the maximum number of peers whose token buckets the store limiter
+// remembers.
+const storeLimiterNPeers = 1 << 12
+
+// errStoreRateExceeded indicates when a peer has sent Store requests faster than its rate limit.
+var errStoreRateExceeded = grpc.Errorf(codes.ResourceExhausted, "store request rate exceeded")
+
+// tokenBucket holds the tokens available to a peer as of its last refill.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// storeLimiter throttles the Store requests of each peer with a token bucket refilled at the
+// rate (per second) up to the burst. It remembers the buckets of (at most) storeLimiterNPeers
+// peers, so its memory is bounded no matter how many peers send requests; an evicted peer
+// starts again with a full bucket.
+type storeLimiter struct {
+	buckets *lru.Cache
+	rate    float64
+	burst   float64
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+func newStoreLimiter(rate float32, burst uint) (*storeLimiter, error) {
+	buckets, err := lru.New(storeLimiterNPeers)
+	if err != nil {
+		return nil, err
+	}
+	return &storeLimiter{
+		buckets: buckets,
+		rate:    float64(rate),
+		burst:   float64(burst),
+		now:     time.Now,
+	}, nil
+}
+
+// allow takes a token from the peer's bucket, returning whether one was available. A nil
+// storeLimiter allows every request.
+func (sl *storeLimiter) allow(peerID cid.ID) bool {
+	if sl == nil {
+		return true
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	key, now := peerID.String(), sl.now()
+	bucket := &tokenBucket{tokens: sl.burst, last: now}
+	if value, in := sl.buckets.Get(key); in {
+		bucket = value.(*tokenBucket)
+		bucket.tokens += now.Sub(bucket.last).Seconds() * sl.rate
+		if bucket.tokens > sl.burst {
+			bucket.tokens = sl.burst
+		}
+		bucket.last = now
+	} else {
+		sl.buckets.Add(key, bucket)
+	}
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
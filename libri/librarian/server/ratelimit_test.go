@@ -0,0 +1,44 @@
+package server
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreLimiter_allow(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	sl, err := newStoreLimiter(2, 3)
+	assert.Nil(t, err)
+	now := time.Unix(0, 0)
+	sl.now = func() time.Time { return now }
+	peerID1, peerID2 := cid.NewPseudoRandom(rng), cid.NewPseudoRandom(rng)
+
+	// check burst is allowed but requests beyond it aren't
+	for c := 0; c < 3; c++ {
+		assert.True(t, sl.allow(peerID1))
+	}
+	assert.False(t, sl.allow(peerID1))
+
+	// check other peers have their own bucket
+	assert.True(t, sl.allow(peerID2))
+
+	// check bucket refills at the rate
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, sl.allow(peerID1))
+	assert.False(t, sl.allow(peerID1))
+
+	// check bucket refills to at most the burst
+	now = now.Add(time.Hour)
+	for c := 0; c < 3; c++ {
+		assert.True(t, sl.allow(peerID1))
+	}
+	assert.False(t, sl.allow(peerID1))
+
+	// check nil limiter allows everything
+	var nilSL *storeLimiter
+	assert.True(t, nilSL.allow(peerID1))
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/drausin/libri/libri/librarian/server/search"
+	"go.uber.org/zap"
+)
+
+const (
+	// LoggerNRefreshedBuckets is the logger key used for the number of buckets refreshed.
+	LoggerNRefreshedBuckets = "n_refreshed_buckets"
+
+	// LoggerNRefreshedPeers is the logger key used for the number of new peers found when
+	// refreshing buckets.
+	LoggerNRefreshedPeers = "n_refreshed_peers"
+)
+
+// BucketRefresher periodically refreshes routing table buckets that haven't had a recent lookup
+// by searching for a random target in each, which discovers new peers in otherwise cold regions
+// of the ID space.
+type BucketRefresher interface {
+	// Refresh searches for a random target in each stale bucket, returning the number of stale
+	// buckets and the number of new peers added to the routing table.
+	Refresh() (int, int, error)
+
+	// Start begins refreshing in the background every interval until Stop is called.
+	Start()
+
+	// Stop ends background refreshing, waiting for any refresh in progress to finish.
+	Stop()
+}
+
+type bucketRefresher struct {
+	selfID   ecid.ID
+	rt       routing.Table
+	searcher search.Searcher
+	params   *search.Parameters
+	interval time.Duration
+	rng      *rand.Rand
+	logger   *zap.Logger
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewBucketRefresher creates a new BucketRefresher that every interval searches for random
+// targets in the routing table buckets without a lookup in the previous interval.
+func NewBucketRefresher(
+	selfID ecid.ID,
+	rt routing.Table,
+	searcher search.Searcher,
+	params *search.Parameters,
+	interval time.Duration,
+	logger *zap.Logger,
+) BucketRefresher {
+	return &bucketRefresher{
+		selfID:   selfID,
+		rt:       rt,
+		searcher: searcher,
+		params:   params,
+		interval: interval,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (r *bucketRefresher) Refresh() (int, int, error) {
+	targets := r.rt.StaleTargets(r.interval, r.rng)
+	nAdded := 0
+	var firstErr error
+	for _, target := range targets {
+		s := search.NewSearch(r.selfID, target, r.params)
+		seeds := r.rt.Peak(target, r.params.Concurrency)
+		if err := r.searcher.Search(s, seeds); err != nil {
+			// keep refreshing the other buckets, leaving this one stale for the next refresh
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, p := range s.Result.Closest.Peers() {
+			if r.rt.Push(p) == routing.Added {
+				nAdded++
+			}
+		}
+		r.rt.MarkRefreshed(target)
+	}
+	return len(targets), nAdded, firstErr
+}
+
+func (r *bucketRefresher) Start() {
+	// spread the first refreshes over the interval rather than refreshing every bucket at the
+	// first tick
+	r.rt.StaggerRefreshes(r.interval, r.rng)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				nRefreshed, nAdded, err := r.Refresh()
+				if err != nil {
+					r.logger.Error("bucket refresh error", zap.Error(err))
+				}
+				if nRefreshed > 0 {
+					r.logger.Info("refreshed stale buckets",
+						zap.Int(LoggerNRefreshedBuckets, nRefreshed),
+						zap.Int(LoggerNRefreshedPeers, nAdded),
+					)
+				}
+			}
+		}
+	}()
+}
+
+func (r *bucketRefresher) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	r.wg.Wait()
+}
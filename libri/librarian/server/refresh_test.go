@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/drausin/libri/libri/librarian/server/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketRefresher_Refresh_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 0)
+	params := search.NewDefaultParameters()
+	newPeers := peer.NewTestPeers(rng, 4)
+	result := search.NewInitialResult(selfID, params)
+	assert.Nil(t, result.Closest.SafePushMany(newPeers))
+	r := NewBucketRefresher(selfID, rt, &fixedSearcher{result: result}, params, time.Hour,
+		clogging.NewDevInfoLogger())
+
+	// check stale bucket is refreshed, adding the peers found
+	nRefreshed, nAdded, err := r.Refresh()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, nRefreshed)
+	assert.Equal(t, len(newPeers), nAdded)
+	for _, p := range newPeers {
+		_, in := rt.Get(p.ID())
+		assert.True(t, in)
+	}
+
+	// check no buckets are stale just after refresh
+	nRefreshed, nAdded, err = r.Refresh()
+	assert.Nil(t, err)
+	assert.Zero(t, nRefreshed)
+	assert.Zero(t, nAdded)
+	assert.Empty(t, rt.StaleTargets(time.Hour, rng))
+}
+
+func TestBucketRefresher_Refresh_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 64)
+	nBuckets := rt.NumBuckets()
+	searcher := &fixedSearcher{err: errors.New("some Search error")}
+	r := NewBucketRefresher(selfID, rt, searcher, search.NewDefaultParameters(), time.Hour,
+		clogging.NewDevInfoLogger())
+
+	// check Search error bubbles up after trying all stale buckets, which remain stale
+	nRefreshed, nAdded, err := r.Refresh()
+	assert.NotNil(t, err)
+	assert.Equal(t, nBuckets, nRefreshed)
+	assert.Zero(t, nAdded)
+	assert.Len(t, rt.StaleTargets(time.Hour, rng), nBuckets)
+}
+
+func TestBucketRefresher_StartStop(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 0)
+	params := search.NewDefaultParameters()
+	result := search.NewInitialResult(selfID, params)
+	newPeer := peer.NewTestPeer(rng, 0)
+	assert.Nil(t, result.Closest.SafePush(newPeer))
+	r := NewBucketRefresher(selfID, rt, &fixedSearcher{result: result}, params,
+		time.Millisecond, clogging.NewDevInfoLogger())
+
+	// check refreshes happen in the background
+	r.Start()
+	_, in := rt.Get(newPeer.ID())
+	for c := 0; c < 100 && !in; c++ {
+		time.Sleep(10 * time.Millisecond)
+		_, in = rt.Get(newPeer.ID())
+	}
+	assert.True(t, in)
+
+	// check Stop returns and may be called more than once
+	r.Stop()
+	r.Stop()
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/drausin/libri/libri/librarian/server/search"
+	"github.com/drausin/libri/libri/librarian/server/store"
+	"go.uber.org/zap"
+)
+
+const (
+	// LoggerNUnderReplicated is the logger key used for the number of documents found held by
+	// fewer peers than the target.
+	LoggerNUnderReplicated = "n_under_replicated"
+
+	// LoggerNReplicated is the logger key used for the number of documents re-stored to top up
+	// their replication.
+	LoggerNReplicated = "n_replicated"
+)
+
+// replicationQueryAttempts is the number of presence queries a replica peer must fail to be
+// counted as not holding the document, so one dropped query doesn't trigger a re-store.
+const replicationQueryAttempts = 3
+
+// ReplicationMaintainer periodically re-stores documents whose replicas have been lost, so their
+// durability doesn't silently degrade as peers leave the network or drop what they store.
+type ReplicationMaintainer interface {
+	// Maintain counts the closest peers holding each stored document and re-stores the most
+	// under-replicated ones, up to the max number of stores per check. It returns the number
+	// of under-replicated documents and the number re-stored.
+	Maintain() (int, int, error)
+
+	// Start begins maintaining in the background every interval until Stop is called.
+	Start()
+
+	// Stop ends background maintenance, waiting for any check in progress to finish.
+	Stop()
+}
+
+type replicationMaintainer struct {
+	selfID       ecid.ID
+	docs         storage.DocumentIterator
+	docL         storage.DocumentLoader
+	pins         storage.DocumentPinner
+	rt           routing.Table
+	storer       store.Storer
+	signer       client.Signer
+	querier      client.FindQuerier
+	searchParams *search.Parameters
+	storeParams  *store.Parameters
+	hasValue     func(p peer.Peer, key cid.ID) (bool, error)
+	interval     time.Duration
+	maxStores    uint
+	storeGap     time.Duration
+	logger       *zap.Logger
+	now          func() time.Time
+	stop         chan struct{}
+	stopOnce     sync.Once
+	wg           sync.WaitGroup
+}
+
+// NewReplicationMaintainer creates a new ReplicationMaintainer that every interval checks the
+// documents found by the storage.DocumentIterator and re-stores at most maxStores of them,
+// spaced evenly over the interval to avoid storming the network. If pins is not nil, each check
+// also pins the documents this peer is responsible for replicating and unpins the rest.
+func NewReplicationMaintainer(
+	selfID ecid.ID,
+	docs storage.DocumentIterator,
+	docL storage.DocumentLoader,
+	pins storage.DocumentPinner,
+	rt routing.Table,
+	storer store.Storer,
+	signer client.Signer,
+	searchParams *search.Parameters,
+	storeParams *store.Parameters,
+	interval time.Duration,
+	maxStores uint,
+	logger *zap.Logger,
+) ReplicationMaintainer {
+	m := &replicationMaintainer{
+		selfID:       selfID,
+		docs:         docs,
+		docL:         docL,
+		pins:         pins,
+		rt:           rt,
+		storer:       storer,
+		signer:       signer,
+		querier:      client.NewFindQuerier(),
+		searchParams: searchParams,
+		storeParams:  storeParams,
+		interval:     interval,
+		maxStores:    maxStores,
+		storeGap:     interval / time.Duration(maxStores+1),
+		logger:       logger,
+		now:          time.Now,
+		stop:         make(chan struct{}),
+	}
+	m.hasValue = m.queryPresence
+	return m
+}
+
+// underReplicated is a document key held by fewer peers than the target.
+type underReplicated struct {
+	key      cid.ID
+	nMissing uint
+}
+
+// byMostMissing orders under-replicated documents by decreasing number of missing replicas.
+type byMostMissing []*underReplicated
+
+func (d byMostMissing) Len() int           { return len(d) }
+func (d byMostMissing) Less(i, j int) bool { return d[i].nMissing > d[j].nMissing }
+func (d byMostMissing) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func (m *replicationMaintainer) Maintain() (int, int, error) {
+	nReplicas := store.AdaptiveNReplicas(m.rt, m.storeParams)
+	under, err := m.findUnderReplicated(nReplicas)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// top up the most under-replicated documents first
+	sort.Stable(byMostMissing(under))
+	nStored := 0
+	for i, doc := range under {
+		if uint(nStored) >= m.maxStores {
+			break
+		}
+		if i > 0 && !m.wait() {
+			// stopped while waiting
+			break
+		}
+		value, err := m.docL.Load(doc.key)
+		if err != nil {
+			return len(under), nStored, err
+		}
+		if value == nil {
+			// deleted since we found it
+			continue
+		}
+		if err := m.replicate(doc.key, value, nReplicas); err != nil {
+			m.logger.Info("failed to replicate document", zap.Stringer("key", doc.key),
+				zap.Error(err))
+			continue
+		}
+		nStored++
+	}
+	return len(under), nStored, nil
+}
+
+// findUnderReplicated returns the stored documents held by fewer than nReplicas of the routing
+// table peers closest to their keys, as reported by presence queries to those peers. It skips
+// documents held by a peer closer to the key than this one, leaving that holder to do the repair
+// so every holder doesn't re-store the same document.
+//
+// Along the way, it pins the documents with fewer than nReplicas of those peers closer to their
+// keys than this one, which this peer is thus responsible for, and unpins the others.
+func (m *replicationMaintainer) findUnderReplicated(nReplicas uint) ([]*underReplicated, error) {
+	keys, err := m.unexpiredKeys()
+	if err != nil {
+		return nil, err
+	}
+	closest := make([][]peer.Peer, len(keys))
+	for i, key := range keys {
+		closest[i] = m.rt.Peak(key, nReplicas)
+	}
+	holds := m.queryHolders(keys, closest)
+
+	under := make([]*underReplicated, 0)
+	for i, key := range keys {
+		selfDist := m.selfID.Distance(key)
+		nHolders, nCloser, closerHolder := uint(0), uint(0), false
+		for j, p := range closest[i] {
+			closer := p.ID().Distance(key).Cmp(selfDist) < 0
+			if closer {
+				nCloser++
+			}
+			if !holds[i][j] {
+				continue
+			}
+			nHolders++
+			if closer {
+				closerHolder = true
+			}
+		}
+		if m.pins != nil {
+			if nCloser < nReplicas {
+				m.pins.Pin(key)
+			} else {
+				m.pins.Unpin(key)
+			}
+		}
+		if nHolders < nReplicas && !closerHolder {
+			under = append(under, &underReplicated{key: key, nMissing: nReplicas - nHolders})
+		}
+	}
+	return under, nil
+}
+
+// unexpiredKeys returns the keys of the stored documents that haven't expired.
+func (m *replicationMaintainer) unexpiredKeys() ([]cid.ID, error) {
+	now := m.now()
+	keys := make([]cid.ID, 0)
+	err := m.docs.Iterate(func(key cid.ID, value *api.Document) error {
+		if !api.IsExpired(value, now) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// queryHolders concurrently asks each of the closest peers of each key whether it holds the
+// value, retrying failed queries up to replicationQueryAttempts times. It returns whether each
+// peer said it holds the value, indexed like the closest peers.
+func (m *replicationMaintainer) queryHolders(keys []cid.ID, closest [][]peer.Peer) [][]bool {
+	holds := make([][]bool, len(keys))
+	sem := make(chan struct{}, peerRecheckConcurrency)
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		holds[i] = make([]bool, len(closest[i]))
+		for j, p := range closest[i] {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i, j int, key cid.ID, p peer.Peer) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				for c := 0; c < replicationQueryAttempts; c++ {
+					has, err := m.hasValue(p, key)
+					if err == nil {
+						holds[i][j] = has
+						return
+					}
+				}
+			}(i, j, key, p)
+		}
+	}
+	wg.Wait()
+	return holds
+}
+
+// queryPresence asks a peer whether it stores the value for a key with a presence Find request,
+// which gets its answer without transferring the value itself.
+func (m *replicationMaintainer) queryPresence(p peer.Peer, key cid.ID) (bool, error) {
+	rq := client.NewFindRequest(m.selfID, key, 0)
+	rq.Presence = true
+	ctx, cancel, err := client.NewSignedTimeoutContext(m.signer, rq, peerRecheckTimeout)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+	rp, err := m.querier.Query(ctx, p.Connector(), rq)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(rp.Metadata.RequestId, rq.Metadata.RequestId) {
+		return false, client.ErrUnexpectedRequestID
+	}
+	return rp.HasValue, nil
+}
+
+// replicate re-stores the value with its closest peers until nReplicas peers have it.
+func (m *replicationMaintainer) replicate(key cid.ID, value *api.Document, nReplicas uint) error {
+	storeParams := *m.storeParams // by value to avoid changing the original store params
+	storeParams.NReplicas = nReplicas
+	s := store.NewStore(m.selfID, key, value, m.searchParams, &storeParams)
+	s.ReplicateExisting = true
+	seeds := m.rt.Peak(key, s.Search.Params.Concurrency)
+	if err := m.storer.Store(s, seeds); err != nil {
+		return err
+	}
+	for _, p := range s.Result.Responded {
+		m.rt.Push(p)
+	}
+	return nil
+}
+
+// wait waits for the gap between stores, returning false if stopped in the meantime.
+func (m *replicationMaintainer) wait() bool {
+	select {
+	case <-m.stop:
+		return false
+	case <-time.After(m.storeGap):
+		return true
+	}
+}
+
+func (m *replicationMaintainer) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				nUnder, nStored, err := m.Maintain()
+				if err != nil {
+					m.logger.Error("replication maintenance error", zap.Error(err))
+				}
+				if nUnder > 0 {
+					m.logger.Info("replicated under-replicated documents",
+						zap.Int(LoggerNUnderReplicated, nUnder),
+						zap.Int(LoggerNReplicated, nStored),
+					)
+				}
+			}
+		}
+	}()
+}
+
+func (m *replicationMaintainer) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.wg.Wait()
+}
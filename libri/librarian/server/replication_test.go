@@ -0,0 +1,389 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drausin/libri/libri/common/db"
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
+	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/client"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"github.com/drausin/libri/libri/librarian/server/search"
+	"github.com/drausin/libri/libri/librarian/server/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestReplicationMaintainer_Maintain_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	docSLD := storage.NewDocumentSLD(kvdb)
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 32)
+	storeParams := store.NewDefaultParameters()
+	nReplicas := storeParams.NReplicas
+
+	// store documents and have some of the closest peers to each not hold them, along with any
+	// closer to it than the maintainer, which would otherwise do the repair instead; half of
+	// those are unreachable and half respond without the document
+	nOffline := []uint{nReplicas, 1, 0, 2}
+	keys := make([]cid.ID, len(nOffline))
+	offline, unreachable := make(map[string]bool), make(map[string]bool)
+	for i := range nOffline {
+		var value *api.Document
+		value, keys[i] = api.NewTestDocument(rng)
+		assert.Nil(t, docSLD.Store(keys[i], value))
+		for j, p := range rt.Peak(keys[i], nReplicas) {
+			closer := p.ID().Distance(keys[i]).Cmp(selfID.Distance(keys[i])) < 0
+			if (uint(j) < nOffline[i] || closer) && !offline[p.ID().String()] {
+				unreachable[p.ID().String()] = len(offline)%2 == 0
+				offline[p.ID().String()] = true
+			}
+		}
+	}
+
+	// closest peers may overlap, so count the missing replicas of each document
+	nMissing := make(map[string]uint)
+	maxMissing, nUnder := uint(0), 0
+	for _, key := range keys {
+		for _, p := range rt.Peak(key, nReplicas) {
+			if offline[p.ID().String()] {
+				nMissing[key.String()]++
+			}
+		}
+		if nMissing[key.String()] > maxMissing {
+			maxMissing = nMissing[key.String()]
+		}
+		if nMissing[key.String()] > 0 {
+			nUnder++
+		}
+	}
+	nQueries := make(map[string]int)
+	var mu sync.Mutex
+	hasValue := func(p peer.Peer, key cid.ID) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		nQueries[p.ID().String()]++
+		if unreachable[p.ID().String()] {
+			return false, errors.New("some Find error")
+		}
+		return !offline[p.ID().String()], nil
+	}
+
+	// check only the most under-replicated document is re-stored when limited to one store
+	s := &recordingStorer{}
+	m := newTestReplicationMaintainer(selfID, docSLD, kvdb, rt, s, hasValue, 1)
+	nFound, nStored, err := m.Maintain()
+	assert.Nil(t, err)
+	assert.Equal(t, nUnder, nFound)
+	assert.Equal(t, 1, nStored)
+	assert.Len(t, s.keys, 1)
+	assert.Equal(t, maxMissing, nMissing[s.keys[0].String()])
+	for idStr, n := range nQueries {
+		if unreachable[idStr] {
+			assert.Equal(t, 0, n%replicationQueryAttempts)
+		}
+	}
+
+	// check all under-replicated documents are re-stored, most under-replicated first
+	s = &recordingStorer{}
+	m = newTestReplicationMaintainer(selfID, docSLD, kvdb, rt, s, hasValue, 16)
+	nFound, nStored, err = m.Maintain()
+	assert.Nil(t, err)
+	assert.Equal(t, nUnder, nFound)
+	assert.Equal(t, nUnder, nStored)
+	assert.Len(t, s.keys, nUnder)
+	for i, key := range s.keys {
+		assert.True(t, nMissing[key.String()] > 0)
+		if i > 0 {
+			assert.True(t, nMissing[key.String()] <= nMissing[s.keys[i-1].String()])
+		}
+	}
+	for _, replicateExisting := range s.replicateExisting {
+		assert.True(t, replicateExisting)
+	}
+}
+
+func TestReplicationMaintainer_Maintain_closerHolder(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	docSLD := storage.NewDocumentSLD(kvdb)
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 32)
+	nReplicas := store.NewDefaultParameters().NReplicas
+
+	// store a document whose closest peer is closer to it than the maintainer
+	var value *api.Document
+	var key cid.ID
+	for {
+		value, key = api.NewTestDocument(rng)
+		closest := rt.Peak(key, 1)[0]
+		if closest.ID().Distance(key).Cmp(selfID.Distance(key)) < 0 {
+			break
+		}
+	}
+	assert.Nil(t, docSLD.Store(key, value))
+	farthest := rt.Peak(key, nReplicas)[nReplicas-1]
+	hasValue := func(p peer.Peer, key cid.ID) (bool, error) {
+		return p.ID().Cmp(farthest.ID()) != 0, nil
+	}
+
+	// check the under-replicated document is left for the closer holder to re-store
+	s := &recordingStorer{}
+	m := newTestReplicationMaintainer(selfID, docSLD, kvdb, rt, s, hasValue, 16)
+	nFound, nStored, err := m.Maintain()
+	assert.Nil(t, err)
+	assert.Zero(t, nFound)
+	assert.Zero(t, nStored)
+	assert.Empty(t, s.keys)
+}
+
+func TestReplicationMaintainer_Maintain_pins(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	docSLD := storage.NewDocumentSLD(kvdb)
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 32)
+	nReplicas := store.NewDefaultParameters().NReplicas
+
+	// store documents, noting which ones the maintainer is among the closest peers to
+	responsible := make(map[string]bool)
+	for i := 0; i < 16; i++ {
+		value, key := api.NewTestDocument(rng)
+		assert.Nil(t, docSLD.Store(key, value))
+		nCloser := uint(0)
+		for _, p := range rt.Peak(key, nReplicas) {
+			if p.ID().Distance(key).Cmp(selfID.Distance(key)) < 0 {
+				nCloser++
+			}
+		}
+		responsible[key.String()] = nCloser < nReplicas
+	}
+	pins := &recordingPinner{pinned: map[string]bool{"some other key": true}}
+	hasValue := func(p peer.Peer, key cid.ID) (bool, error) { return true, nil }
+	m := newTestReplicationMaintainer(selfID, docSLD, kvdb, rt, &recordingStorer{}, hasValue, 16)
+	m.pins = pins
+
+	// check only the documents the maintainer is responsible for are pinned
+	_, _, err = m.Maintain()
+	assert.Nil(t, err)
+	for keyStr, expected := range responsible {
+		assert.Equal(t, expected, pins.pinned[keyStr])
+	}
+	assert.True(t, pins.pinned["some other key"])
+}
+
+func TestReplicationMaintainer_Maintain_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 32)
+	value, key := api.NewTestDocument(rng)
+	offline := func(p peer.Peer, key cid.ID) (bool, error) {
+		return false, errors.New("some Find error")
+	}
+
+	// check Iterate error bubbles up
+	m := NewReplicationMaintainer(selfID,
+		&fixedDocIterator{err: errors.New("some Iterate error")}, &fixedDocLoader{}, nil, rt,
+		&recordingStorer{}, &client.TestNoOpSigner{}, search.NewDefaultParameters(),
+		store.NewDefaultParameters(), time.Hour, 16, clogging.NewDevInfoLogger())
+	m.(*replicationMaintainer).hasValue = offline
+	nFound, nStored, err := m.Maintain()
+	assert.NotNil(t, err)
+	assert.Zero(t, nFound)
+	assert.Zero(t, nStored)
+
+	// check Load error bubbles up
+	docs := &fixedDocIterator{keys: []cid.ID{key}, values: []*api.Document{value}}
+	m = NewReplicationMaintainer(selfID, docs,
+		&fixedDocLoader{err: errors.New("some Load error")}, nil, rt, &recordingStorer{},
+		&client.TestNoOpSigner{}, search.NewDefaultParameters(), store.NewDefaultParameters(),
+		time.Hour, 16, clogging.NewDevInfoLogger())
+	m.(*replicationMaintainer).hasValue = offline
+	nFound, nStored, err = m.Maintain()
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, nFound)
+	assert.Zero(t, nStored)
+
+	// check Store error just skips the document
+	m = NewReplicationMaintainer(selfID, docs, &fixedDocLoader{value: value}, nil, rt,
+		&recordingStorer{err: errors.New("some Store error")}, &client.TestNoOpSigner{},
+		search.NewDefaultParameters(), store.NewDefaultParameters(), time.Hour, 16,
+		clogging.NewDevInfoLogger())
+	m.(*replicationMaintainer).hasValue = offline
+	nFound, nStored, err = m.Maintain()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, nFound)
+	assert.Zero(t, nStored)
+}
+
+func TestReplicationMaintainer_StartStop(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 32)
+	value, key := api.NewTestDocument(rng)
+	docs := &fixedDocIterator{keys: []cid.ID{key}, values: []*api.Document{value}}
+	s := &recordingStorer{stored: make(chan cid.ID, 1)}
+	m := NewReplicationMaintainer(selfID, docs, &fixedDocLoader{value: value}, nil, rt, s,
+		&client.TestNoOpSigner{}, search.NewDefaultParameters(), store.NewDefaultParameters(),
+		time.Millisecond, 16, clogging.NewDevInfoLogger())
+	m.(*replicationMaintainer).hasValue = func(p peer.Peer, key cid.ID) (bool, error) {
+		return false, nil
+	}
+
+	// check under-replicated documents are re-stored in the background
+	m.Start()
+	select {
+	case stored := <-s.stored:
+		assert.Equal(t, key, stored)
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected under-replicated document to be stored")
+	}
+
+	// check Stop returns and may be called more than once
+	m.Stop()
+	m.Stop()
+}
+
+func newTestReplicationMaintainer(
+	selfID ecid.ID,
+	docL storage.DocumentLoader,
+	kvdb db.KVDB,
+	rt routing.Table,
+	s store.Storer,
+	hasValue func(peer.Peer, cid.ID) (bool, error),
+	maxStores uint,
+) *replicationMaintainer {
+	m := NewReplicationMaintainer(selfID, storage.NewDocumentIterator(kvdb), docL, nil, rt, s,
+		&client.TestNoOpSigner{}, search.NewDefaultParameters(), store.NewDefaultParameters(),
+		time.Hour, maxStores, clogging.NewDevInfoLogger()).(*replicationMaintainer)
+	m.hasValue = hasValue
+	m.storeGap = 0
+	return m
+}
+
+func TestReplicationMaintainer_queryPresence(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, selfID, _ := routing.NewTestWithPeers(rng, 8)
+	key := cid.NewPseudoRandom(rng)
+	p := rt.Peak(key, 1)[0]
+	m := NewReplicationMaintainer(selfID, &fixedDocIterator{}, &fixedDocLoader{}, nil, rt,
+		&recordingStorer{}, &client.TestNoOpSigner{}, search.NewDefaultParameters(),
+		store.NewDefaultParameters(), time.Hour, 16,
+		clogging.NewDevInfoLogger()).(*replicationMaintainer)
+
+	// check presence response is passed through
+	for _, hasValue := range []bool{true, false} {
+		q := &fixedPresenceQuerier{hasValue: hasValue}
+		m.querier = q
+		has, err := m.queryPresence(p, key)
+		assert.Nil(t, err)
+		assert.Equal(t, hasValue, has)
+		assert.True(t, q.request.Presence)
+		assert.Equal(t, key.Bytes(), q.request.Key)
+	}
+
+	// check Query error bubbles up
+	m.querier = &fixedPresenceQuerier{err: errors.New("some Query error")}
+	has, err := m.queryPresence(p, key)
+	assert.NotNil(t, err)
+	assert.False(t, has)
+
+	// check unexpected request ID errors
+	m.querier = &fixedPresenceQuerier{hasValue: true, requestID: []byte{1, 2, 3}}
+	has, err = m.queryPresence(p, key)
+	assert.Equal(t, client.ErrUnexpectedRequestID, err)
+	assert.False(t, has)
+}
+
+// recordingPinner records which keys are pinned.
+type recordingPinner struct {
+	pinned map[string]bool
+	mu     sync.Mutex
+}
+
+func (p *recordingPinner) Pin(key cid.ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pinned[key.String()] = true
+}
+
+func (p *recordingPinner) Unpin(key cid.ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pinned, key.String())
+}
+
+type fixedPresenceQuerier struct {
+	hasValue  bool
+	requestID []byte
+	err       error
+	request   *api.FindRequest
+}
+
+func (q *fixedPresenceQuerier) Query(
+	ctx context.Context, pConn api.Connector, rq *api.FindRequest, opts ...grpc.CallOption,
+) (*api.FindResponse, error) {
+	q.request = rq
+	if q.err != nil {
+		return nil, q.err
+	}
+	requestID := rq.Metadata.RequestId
+	if q.requestID != nil {
+		requestID = q.requestID
+	}
+	return &api.FindResponse{
+		Metadata: &api.ResponseMetadata{RequestId: requestID},
+		HasValue: q.hasValue,
+	}, nil
+}
+
+// recordingStorer records the keys of the stores it's given, in order.
+type recordingStorer struct {
+	err               error
+	keys              []cid.ID
+	replicateExisting []bool
+	stored            chan cid.ID
+	mu                sync.Mutex
+}
+
+func (s *recordingStorer) Store(st *store.Store, seeds []peer.Peer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	key := cid.FromBytes(st.Request.Key)
+	s.keys = append(s.keys, key)
+	s.replicateExisting = append(s.replicateExisting, st.ReplicateExisting)
+	st.Result = store.NewInitialResult(search.NewInitialResult(key, st.Search.Params))
+	if s.stored != nil {
+		select {
+		case s.stored <- key:
+		default:
+		}
+	}
+	return nil
+}
+
+type fixedDocLoader struct {
+	value *api.Document
+	err   error
+}
+
+func (f *fixedDocLoader) Load(key cid.ID) (*api.Document, error) {
+	return f.value, f.err
+}
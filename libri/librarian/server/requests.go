@@ -1,51 +1,62 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"time"
 
-	"errors"
-
-	"github.com/drausin/libri/libri/common/ecid"
-	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
+// errReplayedRequest indicates when a request ID has already been seen within the replay window.
+var errReplayedRequest = grpc.Errorf(codes.AlreadyExists, "request ID already seen")
+
 // RequestVerifier verifies requests by checking the signature in the context.
 type RequestVerifier interface {
 	Verify(ctx context.Context, msg proto.Message, meta *api.RequestMetadata) error
 }
 
 type verifier struct {
-	sigVerifier client.Verifier
+	rqVerifier client.RequestVerifier
+}
+
+// NewRequestVerifier creates a new RequestVerifier instance rejecting requests replayed within the
+// given window.
+func NewRequestVerifier(replayWindow time.Duration) RequestVerifier {
+	return &verifier{
+		rqVerifier: client.NewRequestVerifier(replayWindow),
+	}
 }
 
-// NewRequestVerifier creates a new RequestVerifier instance.
-func NewRequestVerifier() RequestVerifier {
+// NewRequestVerifierWithCache creates a new RequestVerifier instance rejecting requests whose IDs
+// are already in the given ReplayCache or that aren't signed with one of the given
+// SigningSchemes. Request IDs are only added once their signature verifies, so unsigned requests
+// can't claim the IDs of others' future requests.
+func NewRequestVerifierWithCache(
+	seen *client.ReplayCache, schemes ...client.SigningScheme,
+) RequestVerifier {
 	return &verifier{
-		sigVerifier: client.NewVerifier(),
+		rqVerifier: client.NewRequestVerifierWithCache(seen, schemes...),
 	}
 }
 
 func (rv *verifier) Verify(ctx context.Context, msg proto.Message,
 	meta *api.RequestMetadata) error {
-	encToken, err := client.FromSignatureContext(ctx)
-	if err != nil {
-		return err
+	pubKey, err := rv.rqVerifier.Verify(ctx, msg)
+	if err == client.ErrReplayedRequest {
+		return errReplayedRequest
 	}
-	pubKey, err := ecid.FromPublicKeyBytes(meta.PubKey)
 	if err != nil {
 		return err
 	}
-	if meta.RequestId == nil {
-		return errors.New("RequestId must not be nil")
+	if !bytes.Equal(pubKey, meta.PubKey) {
+		return fmt.Errorf("verified public key %x does not match metadata public key %x",
+			pubKey, meta.PubKey)
 	}
-	if len(meta.RequestId) != cid.Length {
-		return fmt.Errorf("invalid RequestId length: %v; expected length %v",
-			len(meta.RequestId), cid.Length)
-	}
-
-	return rv.sigVerifier.Verify(encToken, pubKey, msg)
+	return nil
 }
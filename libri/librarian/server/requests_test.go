@@ -1,61 +1,82 @@
 package server
 
 import (
-	"crypto/ecdsa"
+	"errors"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
-// alwaysSigVerifier implements the signature.Verifier interface but just blindly verifies
-// every signature.
-type alwaysSigVerifier struct{}
+// fixedClientRequestVerifier implements the client.RequestVerifier interface but just returns
+// fixed values.
+type fixedClientRequestVerifier struct {
+	pubKey []byte
+	err    error
+}
 
-func (asv *alwaysSigVerifier) Verify(encToken string, fromPubKey *ecdsa.PublicKey,
-	m proto.Message) error {
-	return nil
+func (f *fixedClientRequestVerifier) Verify(ctx context.Context, rq proto.Message) ([]byte,
+	error) {
+	return f.pubKey, f.err
 }
 
 func TestRequestVerifier_Verify_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	meta := client.NewRequestMetadata(ecid.NewPseudoRandom(rng))
 	rv := &verifier{
-		sigVerifier: &alwaysSigVerifier{},
+		rqVerifier: &fixedClientRequestVerifier{pubKey: meta.PubKey},
 	}
+	assert.Nil(t, rv.Verify(context.Background(), &api.FindRequest{Metadata: meta}, meta))
+}
 
+func TestRequestVerifier_Verify_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
-	ctx := client.NewIncomingSignatureContext(context.Background(), "dummy.signed.token")
 	meta := client.NewRequestMetadata(ecid.NewPseudoRandom(rng))
+	rq := &api.FindRequest{Metadata: meta}
 
-
-	assert.Nil(t, rv.Verify(ctx, nil, meta))
-}
-
-func TestRequestVerifier_Verify_err(t *testing.T) {
+	// check client verification error bubbles up
 	rv := &verifier{
-		sigVerifier: &alwaysSigVerifier{},
+		rqVerifier: &fixedClientRequestVerifier{err: errors.New("some Verify error")},
 	}
+	assert.NotNil(t, rv.Verify(context.Background(), rq, meta))
 
-	assert.NotNil(t, rv.Verify(context.Background(), nil, nil)) // no signature in context
+	// check verified pub key must match metadata's
+	rv = &verifier{
+		rqVerifier: &fixedClientRequestVerifier{
+			pubKey: ecid.NewPseudoRandom(rng).PublicKeyBytes(),
+		},
+	}
+	assert.NotNil(t, rv.Verify(context.Background(), rq, meta))
+}
 
+func TestRequestVerifier_Verify_replay(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
-	ctx := client.NewIncomingSignatureContext(context.Background(), "dummy.signed.token")
+	peerID := ecid.NewPseudoRandom(rng)
+	rq := client.NewGetRequest(peerID, cid.NewPseudoRandom(rng))
+	encToken, err := client.NewSigner(peerID.Key()).SignUntil(rq, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	ctx := client.NewIncomingSignatureContext(context.Background(), encToken)
 
-	assert.NotNil(t, rv.Verify(ctx, nil, &api.RequestMetadata{
-		PubKey: []byte{255, 254, 253}, // bad pub key
-	}))
+	seen, err := client.NewReplayCache(client.DefaultReplayCacheSize, client.DefaultReplayWindow)
+	assert.Nil(t, err)
+	rv := NewRequestVerifierWithCache(seen, client.ECDSAScheme)
 
-	assert.NotNil(t, rv.Verify(ctx, nil, &api.RequestMetadata{
-		PubKey:    ecid.NewPseudoRandom(rng).PublicKeyBytes(),
-		RequestId: nil, // can't be nil
-	}))
+	// check unsigned request doesn't claim the request ID
+	unsignedCtx := client.NewIncomingSignatureContext(context.Background(), "dummy.signed.token")
+	assert.NotNil(t, rv.Verify(unsignedCtx, rq, rq.Metadata))
+	assert.Zero(t, seen.Len())
 
-	assert.NotNil(t, rv.Verify(ctx, nil, &api.RequestMetadata{
-		PubKey:    ecid.NewPseudoRandom(rng).PublicKeyBytes(),
-		RequestId: []byte{1, 2, 3}, // not 32 bytes
-	}))
+	// check signed request is verified once, with replays rejected
+	assert.Nil(t, rv.Verify(ctx, rq, rq.Metadata))
+	assert.Equal(t, errReplayedRequest, rv.Verify(ctx, rq, rq.Metadata))
+	assert.Equal(t, codes.AlreadyExists, grpc.Code(rv.Verify(ctx, rq, rq.Metadata)))
 }
@@ -1,6 +1,10 @@
 package routing
 
 import (
+	"math/big"
+	"math/rand"
+	"time"
+
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/server/peer"
 )
@@ -33,6 +37,9 @@ type bucket struct {
 
 	// positions (i.e., indices) of each peer (keyed by ID string) in the heap.
 	positions map[string]int
+
+	// when a lookup for an ID in the bucket was last done, or zero if never
+	lastRefreshed time.Time
 }
 
 // newFirstBucket creates a new instance of the first bucket (spanning the entire ID range)
@@ -54,7 +61,13 @@ func (b *bucket) Len() int {
 	return len(b.activePeers)
 }
 
+// Less returns whether peer i has a higher reputation than peer j or, if their reputations are
+// equal, whether peer i is before peer j.
 func (b *bucket) Less(i, j int) bool {
+	ri, rj := b.activePeers[i].Reputation(), b.activePeers[j].Reputation()
+	if ri != rj {
+		return ri > rj
+	}
 	return b.activePeers[i].Before(b.activePeers[j])
 }
 
@@ -89,6 +102,30 @@ func (b *bucket) Before(c *bucket) bool {
 	return b.lowerBound.Cmp(c.lowerBound) < 0
 }
 
+// Worst returns the heap index of the peer with the lowest reputation, or -1 if the bucket is
+// empty.
+func (b *bucket) Worst() int {
+	worst := -1
+	for i, p := range b.activePeers {
+		if worst == -1 || p.Reputation() < b.activePeers[worst].Reputation() {
+			worst = i
+		}
+	}
+	return worst
+}
+
+// RandomID returns a random ID in the bucket's range.
+func (b *bucket) RandomID(rng *rand.Rand) cid.ID {
+	span := new(big.Int).Sub(b.upperBound.Int(), b.lowerBound.Int())
+	offset := new(big.Int).Rand(rng, span)
+	return cid.FromInt(offset.Add(offset, b.lowerBound.Int()))
+}
+
+// Splittable returns whether the bucket spans more than one ID and so can be split.
+func (b *bucket) Splittable() bool {
+	return b.depth < cid.Length*8
+}
+
 // Vacancy returns whether the bucket has room for more peers.
 func (b *bucket) Vacancy() bool {
 	return len(b.activePeers) < int(b.maxActivePeers)
@@ -41,3 +41,46 @@ func TestBucket_Peak(t *testing.T) {
 	assert.Equal(t, 4, len(b.Peak(4)))
 	assert.Equal(t, 4, len(b.Peak(8)))
 }
+
+func TestBucket_PushPop_reputation(t *testing.T) {
+	b := newFirstBucket(DefaultMaxActivePeers)
+	rng := rand.New(rand.NewSource(0))
+	peers := peer.NewTestPeers(rng, 4)
+	peers[1].RecordFailure()
+	peers[2].RecordSuccess()
+	peers[3].RecordSuccess()
+	peers[3].RecordSuccess()
+	for _, p := range peers {
+		heap.Push(b, p)
+	}
+
+	// check peers with higher reputations are popped first
+	for _, expected := range []peer.Peer{peers[3], peers[2], peers[0], peers[1]} {
+		assert.Equal(t, expected, heap.Pop(b).(peer.Peer))
+	}
+}
+
+func TestBucket_Worst(t *testing.T) {
+	b := newFirstBucket(DefaultMaxActivePeers)
+	assert.Equal(t, -1, b.Worst())
+
+	rng := rand.New(rand.NewSource(0))
+	peers := peer.NewTestPeers(rng, 4)
+	peers[2].RecordFailure()
+	for _, p := range peers {
+		heap.Push(b, p)
+	}
+	assert.Equal(t, peers[2], b.activePeers[b.Worst()])
+}
+
+func TestBucket_RandomID(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, _ := NewTestWithPeers(rng, 128)
+
+	// check random IDs are within each bucket's range
+	for _, b := range rt.(*table).buckets {
+		for c := 0; c < 8; c++ {
+			assert.True(t, b.Contains(b.RandomID(rng)))
+		}
+	}
+}
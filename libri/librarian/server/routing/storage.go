@@ -9,8 +9,11 @@ import (
 
 var tableKey = []byte("RoutingTable")
 
-// Load retrieves the routing table form the KV DB.
-func Load(nl storage.NamespaceLoader, params *Parameters) (Table, error) {
+// Load retrieves the routing table form the KV DB, creating its peers with the given Fromer.
+func Load(nl storage.NamespaceLoader, params *Parameters, f peer.Fromer) (Table, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
 	bytes, err := nl.Load(tableKey)
 	if bytes == nil || err != nil {
 		return nil, err
@@ -20,7 +23,7 @@ func Load(nl storage.NamespaceLoader, params *Parameters) (Table, error) {
 	if err != nil {
 		return nil, err
 	}
-	return fromStored(stored, params), nil
+	return fromStored(stored, params, f), nil
 }
 
 // Save stores a representation of the routing table to the KV DB.
@@ -33,10 +36,10 @@ func (rt *table) Save(ns storage.NamespaceStorer) error {
 }
 
 // fromStored returns a new Table instance from a StoredRoutingTable instance.
-func fromStored(stored *storage.RoutingTable, params *Parameters) Table {
+func fromStored(stored *storage.RoutingTable, params *Parameters, f peer.Fromer) Table {
 	peers := make([]peer.Peer, len(stored.Peers))
 	for i, sp := range stored.Peers {
-		peers[i] = peer.FromStored(sp)
+		peers[i] = f.FromStored(sp)
 	}
 	rt, _ := NewWithPeers(id.FromBytes(stored.SelfId), params, peers)
 	return rt
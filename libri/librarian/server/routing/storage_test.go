@@ -16,7 +16,7 @@ import (
 
 func TestFromStored(t *testing.T) {
 	srt := newTestStoredTable(rand.New(rand.NewSource(0)), 128)
-	rt := fromStored(srt, NewDefaultParameters())
+	rt := fromStored(srt, NewDefaultParameters(), peer.NewFromer())
 	assertRoutingTablesEqual(t, rt, srt)
 }
 
@@ -37,7 +37,7 @@ func TestRoutingTable_SaveLoad(t *testing.T) {
 	err = rt1.Save(ssl)
 	assert.Nil(t, err)
 
-	rt2, err := Load(ssl, NewDefaultParameters())
+	rt2, err := Load(ssl, NewDefaultParameters(), peer.NewFromer())
 	assert.Nil(t, err)
 
 	// check that routing tables are the same
@@ -99,7 +99,7 @@ func (l *fixedLoader) Load(key []byte) ([]byte, error) {
 func TestLoad_err(t *testing.T) {
 
 	// simulates missing/not stored table
-	rt1, err := Load(&fixedLoader{}, NewDefaultParameters())
+	rt1, err := Load(&fixedLoader{}, NewDefaultParameters(), peer.NewFromer())
 	assert.Nil(t, rt1)
 	assert.Nil(t, err)
 
@@ -110,6 +110,7 @@ func TestLoad_err(t *testing.T) {
 			err:   errors.New("some random error"),
 		},
 		NewDefaultParameters(),
+		peer.NewFromer(),
 	)
 	assert.Nil(t, rt2)
 	assert.NotNil(t, err)
@@ -121,6 +122,7 @@ func TestLoad_err(t *testing.T) {
 			err:   nil,
 		},
 		NewDefaultParameters(),
+		peer.NewFromer(),
 	)
 	assert.Nil(t, rt3)
 	assert.NotNil(t, err)
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"sync"
 	"fmt"
+	"time"
 
 	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
@@ -23,6 +24,14 @@ const (
 
 var (
 	DefaultMaxActivePeers = uint(20)
+
+	// ErrInvalidMaxBucketPeers indicates when the maximum number of peers in a bucket is not
+	// positive.
+	ErrInvalidMaxBucketPeers = errors.New("max bucket peers must be positive")
+
+	// DefaultMinReputation is the default reputation below which a peer is evicted from a full
+	// bucket to make room for a new peer.
+	DefaultMinReputation = -4.0
 )
 
 // PushStatus indicates different outcomes when adding a peer to the routing table.
@@ -61,6 +70,24 @@ type Table interface {
 	// indicator for whether the peer existed.
 	Get(peerID cid.ID) (peer.Peer, bool)
 
+	// Remove removes the peer with the given ID, returning whether it existed.
+	Remove(peerID cid.ID) bool
+
+	// Peers returns all the peers in the table.
+	Peers() []peer.Peer
+
+	// MarkRefreshed records that a lookup for the given target was just done, refreshing the
+	// bucket containing it.
+	MarkRefreshed(target cid.ID)
+
+	// StaleTargets returns a random target in the range of each bucket not refreshed within the
+	// given max age. Looking up these targets discovers new peers in cold regions of the ID space.
+	StaleTargets(maxAge time.Duration, rng *rand.Rand) []cid.ID
+
+	// StaggerRefreshes marks each never-refreshed bucket as last refreshed at a random time
+	// within the given max age, so they become stale at different times instead of all at once.
+	StaggerRefreshes(maxAge time.Duration, rng *rand.Rand)
+
 	// Sample returns k peers in the table sampled (approximately) uniformly from the ID space.
 	// Peers are sampled from buckets with probability proportional to the amount of ID
 	// space the bucket covers.
@@ -72,6 +99,10 @@ type Table interface {
 	// NumBuckets returns the number of buckets in the routing table.
 	NumBuckets() int
 
+	// BucketSizes returns the number of peers in each bucket, ordered by the buckets' ID lower
+	// bounds.
+	BucketSizes() []int
+
 	// Disconnect disconnects all client connections.
 	Disconnect() error
 
@@ -84,12 +115,28 @@ type Parameters struct {
 
 	// MaxBucketPeers is the maximum number of peers in a bucket.
 	MaxBucketPeers uint
+
+	// MinReputation is the reputation below which a peer is evicted from a full bucket to make
+	// room for a new peer.
+	MinReputation float64
+
+	// SplitAllBuckets is whether full buckets not containing the self ID are split to make room
+	// for new peers. Standard Kademlia only splits the bucket containing the self ID.
+	SplitAllBuckets bool
 }
 
 func NewDefaultParameters() *Parameters {
 	return &Parameters{
 		MaxBucketPeers: DefaultMaxActivePeers,
+		MinReputation:  DefaultMinReputation,
+	}
+}
+
+func (p *Parameters) validate() error {
+	if p.MaxBucketPeers == 0 {
+		return ErrInvalidMaxBucketPeers
 	}
+	return nil
 }
 
 type table struct {
@@ -105,10 +152,21 @@ type table struct {
 	// defines some aspects of behavior
 	params *Parameters
 
+	now func() time.Time
+
 	// manages pushes and pops
 	mu sync.Mutex
 }
 
+// NewTable creates a new routing table without peers, returning an error if the parameters are
+// invalid.
+func NewTable(selfID cid.ID, params *Parameters) (Table, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	return NewEmpty(selfID, params), nil
+}
+
 // NewEmpty creates a new routing table without peers.
 func NewEmpty(selfID cid.ID, params *Parameters) Table {
 	firstBucket := newFirstBucket(params.MaxBucketPeers)
@@ -117,6 +175,7 @@ func NewEmpty(selfID cid.ID, params *Parameters) Table {
 		peers:   make(map[string]peer.Peer),
 		buckets: []*bucket{firstBucket},
 		params:  params,
+		now:     time.Now,
 	}
 }
 
@@ -153,6 +212,16 @@ func (rt *table) NumBuckets() int {
 	return rt.Len()
 }
 
+func (rt *table) BucketSizes() []int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	sizes := make([]int, len(rt.buckets))
+	for i, b := range rt.buckets {
+		sizes[i] = b.Len()
+	}
+	return sizes
+}
+
 // Push adds the peer into the appropriate bucket and returns the status of the push. This method
 // is concurrency-safe.
 func (rt *table) Push(new peer.Peer) PushStatus {
@@ -193,22 +262,36 @@ func (rt *table) Push(new peer.Peer) PushStatus {
 
 	if insertBucket.Vacancy() {
 		// node isn't already in the bucket and there's vacancy, so add it
+		new.WatchReputation(rt.fix)
 		heap.Push(insertBucket, new)
 		rt.peers[new.ID().String()] = new
 		rt.mu.Unlock()
 		return Added
 	}
 
-	if insertBucket.containsSelf {
-		// no vacancy in the bucket and it contains the self ID, so split the bucket and
-		// insert via (single) recursive call
+	if insertBucket.containsSelf || (rt.params.SplitAllBuckets && insertBucket.Splittable()) {
+		// no vacancy in the bucket and it contains the self ID (or we split all buckets), so
+		// split the bucket and insert via recursive call
 		rt.splitBucket(bucketIdx)
 		rt.mu.Unlock()
 		return rt.Push(new)
 	}
 
-	// no vacancy in the bucket and it doesn't contain the self ID, so just drop new peer on
-	// the floor
+	worst := insertBucket.Worst()
+	if insertBucket.activePeers[worst].Reputation() < rt.params.MinReputation {
+		// no vacancy in the bucket and it can't be split, but it has a peer with a bad
+		// reputation, so replace that peer with the new one
+		evicted := heap.Remove(insertBucket, worst).(peer.Peer)
+		delete(rt.peers, evicted.ID().String())
+		evicted.WatchReputation(nil)
+		new.WatchReputation(rt.fix)
+		heap.Push(insertBucket, new)
+		rt.peers[new.ID().String()] = new
+		rt.mu.Unlock()
+		return Added
+	}
+
+	// no vacancy in the bucket and it can't be split, so just drop new peer on the floor
 	rt.mu.Unlock()
 	return Dropped
 }
@@ -270,6 +353,43 @@ func (rt *table) Get(peerID cid.ID) (peer.Peer, bool) {
 	return nil, false
 }
 
+// Remove removes the peer (if it exists) in the table with the given ID. This method is
+// concurrency safe.
+func (rt *table) Remove(peerID cid.ID) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	b := rt.buckets[rt.bucketIndex(peerID)]
+	pHeapIdx, exists := b.positions[peerID.String()]
+	if !exists {
+		return false
+	}
+	heap.Remove(b, pHeapIdx).(peer.Peer).WatchReputation(nil)
+	delete(rt.peers, peerID.String())
+	return true
+}
+
+// fix re-heaps the peer's bucket after a change to its reputation, since the bucket heap is
+// ordered by it. This method is concurrency safe.
+func (rt *table) fix(p peer.Peer) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	b := rt.buckets[rt.bucketIndex(p.ID())]
+	if pHeapIdx, exists := b.positions[p.ID().String()]; exists {
+		heap.Fix(b, pHeapIdx)
+	}
+}
+
+// Peers returns all the peers in the table. This method is concurrency safe.
+func (rt *table) Peers() []peer.Peer {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	peers := make([]peer.Peer, 0, len(rt.peers))
+	for _, p := range rt.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
 func (rt *table) Sample(k uint, rng *rand.Rand) []peer.Peer {
 	rt.mu.Lock()
 	defer rt.mu.Unlock()
@@ -296,6 +416,45 @@ func (rt *table) Sample(k uint, rng *rand.Rand) []peer.Peer {
 	return sample
 }
 
+// MarkRefreshed records that a lookup for the given target was just done. This method is
+// concurrency-safe.
+func (rt *table) MarkRefreshed(target cid.ID) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.buckets[rt.bucketIndex(target)].lastRefreshed = rt.now()
+}
+
+// StaleTargets returns a random target in each bucket not refreshed within the max age. This
+// method is concurrency-safe.
+func (rt *table) StaleTargets(maxAge time.Duration, rng *rand.Rand) []cid.ID {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	staleBefore := rt.now().Add(-maxAge)
+	targets := make([]cid.ID, 0)
+	for _, b := range rt.buckets {
+		if b.lastRefreshed.Before(staleBefore) {
+			targets = append(targets, b.RandomID(rng))
+		}
+	}
+	return targets
+}
+
+// StaggerRefreshes marks each never-refreshed bucket as refreshed at a random time within the
+// max age. This method is concurrency-safe.
+func (rt *table) StaggerRefreshes(maxAge time.Duration, rng *rand.Rand) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if maxAge <= 0 {
+		return
+	}
+	now := rt.now()
+	for _, b := range rt.buckets {
+		if b.lastRefreshed.IsZero() {
+			b.lastRefreshed = now.Add(-time.Duration(rng.Int63n(int64(maxAge))))
+		}
+	}
+}
+
 // Disconnect disconnects all client connections. This method is thread safe.
 func (rt *table) Disconnect() error {
 	rt.mu.Lock()
@@ -396,6 +555,7 @@ func (rt *table) splitBucket(bucketIdx int) {
 		maxActivePeers: current.maxActivePeers,
 		activePeers:    make([]peer.Peer, 0),
 		positions:      make(map[string]int),
+		lastRefreshed:  current.lastRefreshed,
 	}
 	left.containsSelf = left.Contains(rt.selfID)
 
@@ -408,6 +568,7 @@ func (rt *table) splitBucket(bucketIdx int) {
 		maxActivePeers: current.maxActivePeers,
 		activePeers:    make([]peer.Peer, 0),
 		positions:      make(map[string]int),
+		lastRefreshed:  current.lastRefreshed,
 	}
 	right.containsSelf = right.Contains(rt.selfID)
 
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/server/peer"
@@ -44,6 +45,18 @@ func TestTable_NewWithPeers_concurrent(t *testing.T) {
 	}
 }
 
+func TestNewTable(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, err := NewTable(cid.NewPseudoRandom(rng), NewDefaultParameters())
+	assert.Nil(t, err)
+	assert.NotNil(t, rt)
+
+	// check bucket size must be positive
+	rt, err = NewTable(cid.NewPseudoRandom(rng), &Parameters{MaxBucketPeers: 0})
+	assert.Equal(t, ErrInvalidMaxBucketPeers, err)
+	assert.Nil(t, rt)
+}
+
 func TestTable_NumPeers(t *testing.T) {
 	for s := 0; s < 16; s++ {
 		// make sure handles zero peers
@@ -71,6 +84,25 @@ func TestTable_NumBuckets(t *testing.T) {
 	}
 }
 
+func TestTable_BucketSizes(t *testing.T) {
+	// make sure handles single bucket case
+	rng := rand.New(rand.NewSource(0))
+	rt, _, _ := NewTestWithPeers(rng, 0)
+	assert.Equal(t, []int{0}, rt.BucketSizes())
+
+	for n := 1; n <= 256; n *= 2 {
+		rt, _, _ = NewTestWithPeers(rng, n)
+		sizes := rt.BucketSizes()
+		assert.Len(t, sizes, rt.NumBuckets())
+		nPeers := 0
+		for i, size := range sizes {
+			assert.Equal(t, rt.(*table).buckets[i].Len(), size)
+			nPeers += size
+		}
+		assert.Equal(t, rt.NumPeers(), nPeers)
+	}
+}
+
 func TestTable_Push(t *testing.T) {
 	// try pseudo-random split sequence with different selfIDs
 	for s := 0; s < 16; s++ {
@@ -148,6 +180,109 @@ func TestTable_Push_existing(t *testing.T) {
 	}
 }
 
+func TestTable_Push_evict(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, _ := NewTestWithPeers(rng, 0)
+	var dropped peer.Peer
+	nAdded := 0
+	for _, p := range peer.NewTestPeers(rng, 256) {
+		status := rt.Push(p)
+		if status == Added {
+			nAdded++
+		} else if status == Dropped {
+			dropped = p
+			break
+		}
+	}
+	assert.NotNil(t, dropped)
+
+	// check peer is still dropped when all peers in its full bucket have good reputations
+	insertBucket := rt.(*table).buckets[rt.(*table).bucketIndex(dropped.ID())]
+	assert.Equal(t, Dropped, rt.Push(dropped))
+
+	// check peer replaces one with a bad reputation
+	bad := insertBucket.activePeers[0]
+	for bad.Reputation() >= DefaultMinReputation {
+		bad.RecordFailure()
+	}
+	assert.Equal(t, Added, rt.Push(dropped))
+	_, exists := rt.Get(bad.ID())
+	assert.False(t, exists)
+	_, exists = rt.Get(dropped.ID())
+	assert.True(t, exists)
+	checkTableConsistent(t, rt, nAdded)
+}
+
+func TestTable_fix(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, _ := NewTestWithPeers(rng, 8)
+	b := rt.(*table).buckets[0]
+	assert.True(t, b.Len() > 1)
+
+	// check the bucket heap is re-ordered as soon as a peer's reputation changes
+	last := b.activePeers[b.Len()-1]
+	last.RecordSuccess()
+	assert.Equal(t, last, b.activePeers[0])
+	for last.Reputation() >= 0 {
+		last.RecordFailure()
+	}
+	assert.NotEqual(t, last, b.activePeers[0])
+	for i := 1; i < b.Len(); i++ {
+		assert.False(t, b.Less(i, (i-1)/2))
+	}
+
+	// check removed peers no longer re-heap the table
+	assert.True(t, rt.Remove(last.ID()))
+	last.RecordSuccess()
+	checkTableConsistent(t, rt, rt.NumPeers())
+}
+
+func TestTable_Push_maxBucketPeers(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	selfID := cid.NewPseudoRandom(rng)
+	peers := peer.NewTestPeers(rng, 256)
+	prevNumBuckets := len(peers)
+	for _, k := range []uint{4, 8, 16, 32} {
+		params := NewDefaultParameters()
+		params.MaxBucketPeers = k
+		rt, err := NewTable(selfID, params)
+		assert.Nil(t, err)
+
+		// check first k peers fill a single bucket before it splits
+		for _, p := range peers[:k] {
+			assert.Equal(t, Added, rt.Push(p))
+		}
+		assert.Equal(t, 1, rt.NumBuckets())
+
+		// check larger buckets hold more peers each, so the table has fewer buckets
+		for _, p := range peers[k:] {
+			rt.Push(p)
+		}
+		for _, b := range rt.(*table).buckets {
+			assert.True(t, uint(b.Len()) <= k)
+		}
+		assert.True(t, rt.NumBuckets() <= prevNumBuckets)
+		prevNumBuckets = rt.NumBuckets()
+	}
+}
+
+func TestTable_Push_splitAllBuckets(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	params := NewDefaultParameters()
+	params.SplitAllBuckets = true
+	rt, err := NewTable(cid.NewPseudoRandom(rng), params)
+	assert.Nil(t, err)
+
+	// check no peers are dropped when all full buckets are split
+	for i, p := range peer.NewTestPeers(rng, 256) {
+		assert.Equal(t, Added, rt.Push(p))
+		checkTableConsistent(t, rt, i+1)
+	}
+	for _, b := range rt.(*table).buckets {
+		assert.True(t, uint(b.Len()) <= params.MaxBucketPeers)
+	}
+}
+
 func TestTable_Pop(t *testing.T) {
 
 	// make sure we support poppping 0 peers
@@ -254,6 +389,86 @@ func TestTable_Peak_concurrent(t *testing.T) {
 	}
 }
 
+func TestTable_Remove(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, nAdded := NewTestWithPeers(rng, 128)
+	removed := rt.Peers()[:nAdded/2]
+
+	for _, p := range removed {
+		assert.True(t, rt.Remove(p.ID()))
+		_, exists := rt.Get(p.ID())
+		assert.False(t, exists)
+	}
+	checkTableConsistent(t, rt, nAdded-len(removed))
+
+	// check removing missing peers does nothing
+	assert.False(t, rt.Remove(removed[0].ID()))
+	assert.False(t, rt.Remove(cid.NewPseudoRandom(rng)))
+	checkTableConsistent(t, rt, nAdded-len(removed))
+}
+
+func TestTable_Peers(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, nAdded := NewTestWithPeers(rng, 128)
+	peers := rt.Peers()
+	assert.Len(t, peers, nAdded)
+	for _, p := range peers {
+		_, exists := rt.Get(p.ID())
+		assert.True(t, exists)
+	}
+}
+
+func TestTable_MarkRefreshed_StaleTargets(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, _ := NewTestWithPeers(rng, 128)
+	now := time.Unix(1000, 0)
+	rt.(*table).now = func() time.Time { return now }
+	buckets := rt.(*table).buckets
+	assert.True(t, len(buckets) > 1)
+
+	// check all buckets are stale before any have been refreshed
+	targets := rt.StaleTargets(time.Hour, rng)
+	assert.Equal(t, len(buckets), len(targets))
+	for i, target := range targets {
+		assert.True(t, buckets[i].Contains(target))
+	}
+
+	// check refreshed bucket is no longer stale
+	rt.MarkRefreshed(targets[0])
+	targets = rt.StaleTargets(time.Hour, rng)
+	assert.Equal(t, len(buckets)-1, len(targets))
+	for _, target := range targets {
+		assert.False(t, buckets[0].Contains(target))
+	}
+
+	// check refreshed bucket becomes stale again after max age
+	now = now.Add(time.Hour + time.Second)
+	assert.Equal(t, len(buckets), len(rt.StaleTargets(time.Hour, rng)))
+}
+
+func TestTable_StaggerRefreshes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, _ := NewTestWithPeers(rng, 128)
+	now := time.Unix(1000, 0)
+	rt.(*table).now = func() time.Time { return now }
+	buckets := rt.(*table).buckets
+	rt.MarkRefreshed(buckets[0].RandomID(rng))
+
+	// check no buckets are stale right after staggering
+	rt.StaggerRefreshes(time.Hour, rng)
+	assert.Empty(t, rt.StaleTargets(time.Hour, rng))
+	assert.Equal(t, now, buckets[0].lastRefreshed)
+
+	// check never-refreshed buckets become stale at different times over the next max age
+	nStale := make(map[int]struct{})
+	for c := 0; c <= 4; c++ {
+		nStale[len(rt.StaleTargets(time.Hour, rng))] = struct{}{}
+		now = now.Add(15 * time.Minute)
+	}
+	assert.True(t, len(nStale) > 2)
+	assert.Equal(t, len(buckets), len(rt.StaleTargets(time.Hour, rng)))
+}
+
 func TestTable_Sample(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	for n := 2; n <= 256; n *= 2 {
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"sync"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Cache is an LRU cache of the closest peers found by recent searches for a key, used to seed
+// repeated searches for the same key.
+type Cache interface {
+	// Get returns the cached closest peers for a key and whether they exist and haven't expired.
+	Get(key cid.ID) ([]peer.Peer, bool)
+
+	// Add caches the closest peers found for a key.
+	Add(key cid.ID, closest []peer.Peer)
+
+	// Len gives the number of keys in the cache, including any whose peers have expired.
+	Len() int
+}
+
+type cachedPeers struct {
+	peers []peer.Peer
+	added time.Time
+}
+
+type cache struct {
+	recent *lru.Cache
+	ttl    time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewCache creates a new Cache holding the closest peers of (at most) size keys, each expiring
+// after the given TTL. A zero size disables caching.
+func NewCache(size uint, ttl time.Duration) (Cache, error) {
+	if size == 0 {
+		return disabledCache{}, nil
+	}
+	recent, err := lru.New(int(size))
+	if err != nil {
+		return nil, err
+	}
+	return &cache{
+		recent: recent,
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
+}
+
+func (c *cache) Get(key cid.ID) ([]peer.Peer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, in := c.recent.Get(key.String())
+	if !in {
+		return nil, false
+	}
+	cached := value.(*cachedPeers)
+	if c.now().Sub(cached.added) > c.ttl {
+		// peers may have since left or moved, so don't use them
+		c.recent.Remove(key.String())
+		return nil, false
+	}
+	return cached.peers, true
+}
+
+func (c *cache) Add(key cid.ID, closest []peer.Peer) {
+	if len(closest) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// copy since closest may be a view of a heap still being modified
+	peers := make([]peer.Peer, len(closest))
+	copy(peers, closest)
+	c.recent.Add(key.String(), &cachedPeers{peers: peers, added: c.now()})
+}
+
+func (c *cache) Len() int {
+	return c.recent.Len()
+}
+
+// disabledCache is a Cache that never holds any peers.
+type disabledCache struct{}
+
+func (disabledCache) Get(key cid.ID) ([]peer.Peer, bool) {
+	return nil, false
+}
+
+func (disabledCache) Add(key cid.ID, closest []peer.Peer) {}
+
+func (disabledCache) Len() int {
+	return 0
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCache_disabled(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	c, err := NewCache(0, DefaultCacheTTL)
+	assert.Nil(t, err)
+	key := cid.NewPseudoRandom(rng)
+
+	// check zero size cache never holds peers
+	c.Add(key, peer.NewTestPeers(rng, 3))
+	peers, in := c.Get(key)
+	assert.False(t, in)
+	assert.Nil(t, peers)
+	assert.Zero(t, c.Len())
+}
+
+func TestCache_GetAdd(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	c, err := NewCache(2, time.Minute)
+	assert.Nil(t, err)
+	impl := c.(*cache)
+	now := time.Now()
+	impl.now = func() time.Time { return now }
+	key1, key2, key3 := cid.NewPseudoRandom(rng), cid.NewPseudoRandom(rng),
+		cid.NewPseudoRandom(rng)
+	peers := peer.NewTestPeers(rng, 3)
+
+	// check missing key
+	cached, in := c.Get(key1)
+	assert.False(t, in)
+	assert.Nil(t, cached)
+
+	// check empty peers aren't cached
+	c.Add(key1, []peer.Peer{})
+	assert.Equal(t, 0, c.Len())
+
+	// check added peers are a copy
+	closest := []peer.Peer{peers[0], peers[1]}
+	c.Add(key1, closest)
+	closest[0] = peers[2]
+	cached, in = c.Get(key1)
+	assert.True(t, in)
+	assert.Equal(t, []peer.Peer{peers[0], peers[1]}, cached)
+
+	// check least recently used key is evicted
+	c.Add(key2, peers[1:])
+	c.Get(key1)
+	c.Add(key3, peers[2:])
+	assert.Equal(t, 2, c.Len())
+	_, in = c.Get(key2)
+	assert.False(t, in)
+	_, in = c.Get(key1)
+	assert.True(t, in)
+
+	// check expired peers are removed
+	now = now.Add(2 * time.Minute)
+	cached, in = c.Get(key1)
+	assert.False(t, in)
+	assert.Nil(t, cached)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCache_concurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	c, err := NewCache(8, time.Minute)
+	assert.Nil(t, err)
+	keys := make([]cid.ID, 16)
+	for i := range keys {
+		keys[i] = cid.NewPseudoRandom(rng)
+	}
+	peers := peer.NewTestPeers(rng, 4)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := range keys {
+				key := keys[(i+j)%len(keys)]
+				c.Add(key, peers)
+				c.Get(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 8, c.Len())
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus metrics of a searcher, so each librarian in a process can record
+// its searches separately.
+type Metrics struct {
+	searchDuration    prometheus.Histogram
+	findQueryDuration *prometheus.HistogramVec
+}
+
+// NewMetrics creates a new *Metrics instance.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		searchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "libri",
+			Subsystem: "search",
+			Name:      "duration_seconds",
+			Help:      "Duration of searches for a key.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
+		findQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "libri",
+			Subsystem: "search",
+			Name:      "find_query_duration_seconds",
+			Help:      "Duration of Find queries to peers during searches.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+		}, []string{"outcome"}),
+	}
+}
+
+// Register registers the search metrics with the given registerer.
+func (m *Metrics) Register(r prometheus.Registerer) {
+	r.MustRegister(m.searchDuration, m.findQueryDuration)
+}
+
+// observeSearch records the duration of a search. It is a no-op for nil metrics.
+func (m *Metrics) observeSearch(start time.Time) {
+	if m == nil {
+		return
+	}
+	m.searchDuration.Observe(time.Since(start).Seconds())
+}
+
+// observeFindQuery records the duration and outcome of a Find query. It is a no-op for nil
+// metrics.
+func (m *Metrics) observeFindQuery(start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.findQueryDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_separate(t *testing.T) {
+	m1, m2 := NewMetrics(), NewMetrics()
+	r1, r2 := prometheus.NewRegistry(), prometheus.NewRegistry()
+	m1.Register(r1)
+	m2.Register(r2)
+
+	m1.observeSearch(time.Now())
+	m1.observeFindQuery(time.Now(), nil)
+	m1.observeFindQuery(time.Now(), errors.New("some Find error"))
+
+	// check observations of one instance aren't included in the other's
+	assert.Equal(t, uint64(1), gatheredSampleCount(t, r1, "libri_search_duration_seconds"))
+	assert.Equal(t, uint64(2),
+		gatheredSampleCount(t, r1, "libri_search_find_query_duration_seconds"))
+	assert.Zero(t, gatheredSampleCount(t, r2, "libri_search_duration_seconds"))
+	assert.Zero(t, gatheredSampleCount(t, r2, "libri_search_find_query_duration_seconds"))
+
+	// check nil metrics is a no-op
+	var nilMetrics *Metrics
+	nilMetrics.observeSearch(time.Now())
+	nilMetrics.observeFindQuery(time.Now(), nil)
+}
+
+func gatheredSampleCount(t *testing.T, r *prometheus.Registry, name string) uint64 {
+	mfs, err := r.Gather()
+	assert.Nil(t, err)
+	count := uint64(0)
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, metric := range mf.Metric {
+			count += metric.Histogram.GetSampleCount()
+		}
+	}
+	return count
+}
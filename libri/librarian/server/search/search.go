@@ -9,6 +9,7 @@ import (
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/golang/protobuf/proto"
 )
 
 const (
@@ -23,6 +24,19 @@ const (
 
 	// DefaultQueryTimeout is the timeout for each query to a peer.
 	DefaultQueryTimeout = 5 * time.Second
+
+	// DefaultNSeedGroups is the default number of seed groups to search from concurrently.
+	DefaultNSeedGroups = uint(1)
+
+	// DefaultOverallTimeout is the default timeout for an entire search, where zero means no
+	// timeout.
+	DefaultOverallTimeout = time.Duration(0)
+
+	// DefaultCacheSize is the default number of keys whose closest peers are cached.
+	DefaultCacheSize = uint(1024)
+
+	// DefaultCacheTTL is the default duration cached closest peers are used to seed searches.
+	DefaultCacheTTL = 5 * time.Minute
 )
 
 // Parameters defines the parameters of the search.
@@ -38,6 +52,20 @@ type Parameters struct {
 
 	// timeout for queries to individual peers
 	Timeout time.Duration
+
+	// timeout for the entire search, after which it ends with its partial result; zero means no
+	// timeout
+	OverallTimeout time.Duration
+
+	// number of disjoint groups of seeds from the routing table to search from concurrently
+	NSeedGroups uint
+
+	// number of keys whose closest peers are cached to seed repeated searches, where zero
+	// disables the cache
+	CacheSize uint
+
+	// duration after which cached closest peers expire
+	CacheTTL time.Duration
 }
 
 // NewDefaultParameters creates an instance with default parameters.
@@ -47,6 +75,10 @@ func NewDefaultParameters() *Parameters {
 		NMaxErrors:        DefaultNMaxErrors,
 		Concurrency:       DefaultConcurrency,
 		Timeout:           DefaultQueryTimeout,
+		OverallTimeout:    DefaultOverallTimeout,
+		NSeedGroups:       DefaultNSeedGroups,
+		CacheSize:         DefaultCacheSize,
+		CacheTTL:          DefaultCacheTTL,
 	}
 }
 
@@ -64,6 +96,9 @@ type Result struct {
 	// map of all peers that responded during search
 	Responded map[string]peer.Peer
 
+	// map of the peers that responded they store the value, when searching for its presence
+	Holders map[string]peer.Peer
+
 	// Errored contains the errors received by each peer (via string representation of peer ID)
 	Errored map[string]error
 
@@ -78,6 +113,7 @@ func NewInitialResult(key cid.ID, params *Parameters) *Result {
 		Closest:   newFarthestPeers(key, params.NClosestResponses),
 		Unqueried: newClosestPeers(key, params.NClosestResponses * params.Concurrency),
 		Responded: make(map[string]peer.Peer),
+		Holders:   make(map[string]peer.Peer),
 		Errored:   make(map[string]error),
 	}
 }
@@ -96,6 +132,12 @@ type Search struct {
 	// parameters defining the search
 	Params *Parameters
 
+	// whether the search's overall timeout passed before it finished
+	timedOut bool
+
+	// whether the search finishes as soon as a peer responds that it stores the value
+	existsOnly bool
+
 	// mutex used to synchronizes reads and writes to this instance
 	mu sync.Mutex
 }
@@ -110,6 +152,84 @@ func NewSearch(selfID ecid.ID, key cid.ID, params *Parameters) *Search {
 	}
 }
 
+// NewLocateSearch creates a new Search instance for the peers storing the value of a given key.
+// Its queries ask peers whether they store the value rather than for the value itself, so it
+// continues until it has found the closest peers.
+func NewLocateSearch(selfID ecid.ID, key cid.ID, params *Parameters) *Search {
+	s := NewSearch(selfID, key, params)
+	s.Request.Presence = true
+	return s
+}
+
+// NewExistsSearch creates a new Search instance confirming the value of a given key exists. Like
+// a locate search, its queries ask peers whether they store the value rather than for the value
+// itself, but it finishes as soon as one does.
+func NewExistsSearch(selfID ecid.ID, key cid.ID, params *Parameters) *Search {
+	s := NewLocateSearch(selfID, key, params)
+	s.existsOnly = true
+	return s
+}
+
+// newSubSearch creates a new search for the same key, request, and parameters but with its own
+// initial result. The sub-search's request has its own request ID since sub-searches may query
+// the same peers, which reject replayed request IDs.
+func (s *Search) newSubSearch() *Search {
+	rq := proto.Clone(s.Request).(*api.FindRequest)
+	rq.Metadata.RequestId = cid.NewRandom().Bytes()
+	return &Search{
+		Key:        s.Key,
+		Request:    rq,
+		Result:     NewInitialResult(s.Key, s.Params),
+		Params:     s.Params,
+		existsOnly: s.existsOnly,
+	}
+}
+
+// merge merges the results of finished sub-searches into the search's result. The merged closest
+// peers are the NClosestResponses closest of all the sub-searches' closest peers, and the merged
+// unqueried peers exclude any that responded in another sub-search. The merged result only has a
+// fatal error if every sub-search had one, but it has timed out if any sub-search did.
+func (s *Search) merge(subs []*Search) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nFatal := 0
+	for _, sub := range subs {
+		s.timedOut = s.timedOut || sub.timedOut
+		if s.Result.Value == nil {
+			s.Result.Value = sub.Result.Value
+		}
+		if err := s.Result.Closest.SafePushMany(sub.Result.Closest.Peers()); err != nil {
+			panic(err) // should never happen
+		}
+		for idStr, p := range sub.Result.Responded {
+			s.Result.Responded[idStr] = p
+		}
+		for idStr, p := range sub.Result.Holders {
+			s.Result.Holders[idStr] = p
+		}
+		for idStr, err := range sub.Result.Errored {
+			s.Result.Errored[idStr] = err
+		}
+		if sub.Result.FatalErr != nil {
+			nFatal++
+			s.Result.FatalErr = sub.Result.FatalErr
+		}
+	}
+	for _, sub := range subs {
+		for _, p := range sub.Result.Unqueried.Peers() {
+			if _, in := s.Result.Responded[p.ID().String()]; in {
+				continue
+			}
+			if err := s.Result.Unqueried.SafePush(p); err != nil {
+				panic(err) // should never happen
+			}
+		}
+	}
+	if nFatal < len(subs) {
+		s.Result.FatalErr = nil
+	}
+}
+
 // FoundClosestPeers returns whether the search has found the closest peers to a target. This event
 // occurs when it has received responses from the required number of peers, and the max distance of
 // those peers to the target is less than the min distance of the peers we haven't queried yet.
@@ -130,6 +250,11 @@ func (s *Search) FoundValue() bool {
 	return s.Result.Value != nil
 }
 
+// FoundHolder returns whether a peer has responded that it stores the value.
+func (s *Search) FoundHolder() bool {
+	return len(s.Result.Holders) > 0
+}
+
 // Errored returns whether the search has encountered too many errors when querying the peers.
 func (s *Search) Errored() bool {
 	return uint(len(s.Result.Errored)) > s.Params.NMaxErrors || s.Result.FatalErr != nil
@@ -140,11 +265,20 @@ func (s *Search) Exhausted() bool {
 	return s.Result.Unqueried.Len() == 0
 }
 
+// TimedOut returns whether the search's overall timeout passed before it otherwise finished, in
+// which case its result is partial. This operation is concurrency safe.
+func (s *Search) TimedOut() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timedOut
+}
+
 // Finished returns whether the search has finished, either because it has found the target or
-// closest peers or errored or exhausted the list of peers to query. This operation is concurrency
-// safe.
+// closest peers (or, for exists searches, a peer storing the value) or errored or exhausted the
+// list of peers to query or timed out. This operation is concurrency safe.
 func (s *Search) Finished() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.FoundValue() || s.FoundClosestPeers() || s.Errored() || s.Exhausted()
+	return s.FoundValue() || (s.existsOnly && s.FoundHolder()) || s.FoundClosestPeers() ||
+		s.Errored() || s.Exhausted() || s.timedOut
 }
@@ -20,6 +20,8 @@ func TestNewDefaultParameters(t *testing.T) {
 	assert.NotZero(t, p.NMaxErrors)
 	assert.NotZero(t, p.Concurrency)
 	assert.NotZero(t, p.Timeout)
+	assert.NotZero(t, p.CacheSize)
+	assert.NotZero(t, p.CacheTTL)
 }
 
 func TestSearch_FoundClosestPeers(t *testing.T) {
@@ -112,3 +114,58 @@ func TestSearch_Exhausted(t *testing.T) {
 	assert.Nil(t, err)
 	assert.False(t, search1.Exhausted())
 }
+
+func TestNewLocateSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	target, selfID := cid.FromInt64(0), ecid.NewPseudoRandom(rng)
+	search := NewLocateSearch(selfID, target, NewDefaultParameters())
+	assert.True(t, search.Request.Presence)
+	assert.Empty(t, search.Result.Holders)
+
+	// check sub-searches also query for presence
+	assert.True(t, search.newSubSearch().Request.Presence)
+}
+
+func TestNewExistsSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	target, selfID := cid.FromInt64(0), ecid.NewPseudoRandom(rng)
+	search := NewExistsSearch(selfID, target, NewDefaultParameters())
+	err := search.Result.Unqueried.SafePush(peer.New(cid.FromInt64(2), "", nil))
+	assert.Nil(t, err)
+	assert.True(t, search.Request.Presence)
+	assert.False(t, search.FoundHolder())
+	assert.False(t, search.Finished())
+
+	// check search finishes once a peer stores the value
+	holder := peer.New(cid.FromInt64(1), "", nil)
+	search.Result.Holders[holder.ID().String()] = holder
+	assert.True(t, search.FoundHolder())
+	assert.True(t, search.Finished())
+
+	// check sub-searches are also exists searches
+	sub := search.newSubSearch()
+	assert.True(t, sub.Request.Presence)
+	assert.True(t, sub.existsOnly)
+
+	// check locate searches continue after finding a peer storing the value
+	locate := NewLocateSearch(selfID, target, NewDefaultParameters())
+	err = locate.Result.Unqueried.SafePush(peer.New(cid.FromInt64(2), "", nil))
+	assert.Nil(t, err)
+	locate.Result.Holders[holder.ID().String()] = holder
+	assert.False(t, locate.Finished())
+}
+
+func TestSearch_newSubSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	target, selfID := cid.FromInt64(0), ecid.NewPseudoRandom(rng)
+	search := NewSearch(selfID, target, NewDefaultParameters())
+	sub := search.newSubSearch()
+
+	// check sub-search has same request except for its request ID
+	assert.Equal(t, search.Key, sub.Key)
+	assert.Equal(t, search.Request.Key, sub.Request.Key)
+	assert.Equal(t, search.Request.NumPeers, sub.Request.NumPeers)
+	assert.Equal(t, search.Request.Metadata.PubKey, sub.Request.Metadata.PubKey)
+	assert.NotEqual(t, search.Request.Metadata.RequestId, sub.Request.Metadata.RequestId)
+	assert.Equal(t, search.Params, sub.Params)
+}
@@ -3,12 +3,18 @@ package search
 import (
 	"bytes"
 	"container/heap"
-	"sync"
 	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/drausin/libri/libri/common/clock"
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
+	"golang.org/x/net/context"
 )
 
 // ErrTooManyFindErrors indicates when a search has encountered too many Find request errors.
@@ -20,6 +26,10 @@ var (
 type Searcher interface {
 	// Search executes a search from a list of seeds.
 	Search(search *Search, seeds []peer.Peer) error
+
+	// SearchMulti concurrently executes a search from each group of seeds and merges their
+	// results, so a search isn't stalled by all its seeds being in one part of the key space.
+	SearchMulti(search *Search, seedGroups [][]peer.Peer) error
 }
 
 type searcher struct {
@@ -31,40 +41,130 @@ type searcher struct {
 
 	// processes the find query responses from the peers
 	rp ResponseProcessor
+
+	// tells the time for search and query timeouts
+	clock clock.Clock
+
+	// records search and query durations, if non-nil
+	metrics *Metrics
 }
 
-// NewSearcher returns a new Searcher with the given Querier and ResponseProcessor.
+// NewSearcher returns a new Searcher with the given Querier and ResponseProcessor, using the wall
+// clock for timeouts.
 func NewSearcher(s client.Signer, q client.FindQuerier, rp ResponseProcessor) Searcher {
-	return &searcher{signer: s, querier: q, rp: rp}
+	return &searcher{signer: s, querier: q, rp: rp, clock: clock.New()}
 }
 
-// NewDefaultSearcher creates a new Searcher with default sub-object instantiations.
-func NewDefaultSearcher(signer client.Signer) Searcher {
-	return NewSearcher(
-		signer,
-		client.NewFindQuerier(),
-		NewResponseProcessor(peer.NewFromer()),
-	)
+// NewDefaultSearcher creates a new Searcher with default sub-object instantiations, using the
+// given clock for timeouts or the wall clock if it is nil.
+func NewDefaultSearcher(signer client.Signer, clk clock.Clock) Searcher {
+	if clk == nil {
+		clk = clock.New()
+	}
+	return &searcher{
+		signer:  signer,
+		querier: client.NewFindQuerier(),
+		rp:      NewResponseProcessor(peer.NewFromer()),
+		clock:   clk,
+	}
+}
+
+// NewDefaultSearcherWithMetrics creates a new Searcher like NewDefaultSearcher that also records
+// its searches in the given metrics and creates the peers it finds with the given Fromer.
+func NewDefaultSearcherWithMetrics(
+	signer client.Signer, clk clock.Clock, f peer.Fromer, m *Metrics,
+) Searcher {
+	s := NewDefaultSearcher(signer, clk)
+	s.(*searcher).rp = NewResponseProcessor(f)
+	s.(*searcher).metrics = m
+	return s
 }
 
 func (s *searcher) Search(search *Search, seeds []peer.Peer) error {
+	defer s.metrics.observeSearch(time.Now())
 	if err := search.Result.Unqueried.SafePushMany(seeds); err != nil {
 		panic(err)  // should never happen
 	}
 
+	// bound the whole search, independent of each query's timeout
+	ctx, cancel := context.Background(), func() {}
+	if search.Params.OverallTimeout > 0 {
+		ctx, cancel = clock.WithTimeout(ctx, s.clock, search.Params.OverallTimeout)
+	}
+	defer cancel()
+
 	var wg sync.WaitGroup
 	for c := uint(0); c < search.Params.Concurrency; c++ {
 		wg.Add(1)
-		go s.searchWork(search, &wg)
+		go s.searchWork(ctx, search, &wg)
 	}
 	wg.Wait()
 
 	return search.Result.FatalErr
 }
 
-func (s *searcher) searchWork(search *Search, wg *sync.WaitGroup) {
+func (s *searcher) SearchMulti(search *Search, seedGroups [][]peer.Peer) error {
+	if len(seedGroups) == 1 {
+		return s.Search(search, seedGroups[0])
+	}
+	subs := make([]*Search, len(seedGroups))
+	var wg sync.WaitGroup
+	for i, seeds := range seedGroups {
+		subs[i] = search.newSubSearch()
+		wg.Add(1)
+		go func(sub *Search, seeds []peer.Peer) {
+			defer wg.Done()
+			// sub-search fatal errors are in their results, which are merged below
+			_ = s.Search(sub, seeds)
+		}(subs[i], seeds)
+	}
+	wg.Wait()
+	search.merge(subs)
+	return search.Result.FatalErr
+}
+
+// SeedGroups derives up to nGroups disjoint groups of (at most) k seeds each from the routing
+// table. The first group has the peers closest to the key, and the others have peers sampled
+// (approximately) uniformly from the ID space so their searches start from elsewhere in the key
+// space.
+func SeedGroups(rt routing.Table, key cid.ID, k, nGroups uint, rng *rand.Rand) [][]peer.Peer {
+	closest := rt.Peak(key, k)
+	groups := [][]peer.Peer{closest}
+	if nGroups <= 1 {
+		return groups
+	}
+	used := make(map[string]struct{})
+	for _, p := range closest {
+		used[p.ID().String()] = struct{}{}
+	}
+	group := make([]peer.Peer, 0, k)
+	for _, p := range rt.Sample(k*(nGroups-1), rng) {
+		if _, in := used[p.ID().String()]; in {
+			continue
+		}
+		used[p.ID().String()] = struct{}{}
+		group = append(group, p)
+		if uint(len(group)) == k {
+			groups = append(groups, group)
+			group = make([]peer.Peer, 0, k)
+		}
+	}
+	if len(group) > 0 && uint(len(groups)) < nGroups {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func (s *searcher) searchWork(ctx context.Context, search *Search, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for !search.Finished() {
+		if ctx.Err() != nil {
+			// overall timeout has passed, so leave result as is
+			search.mu.Lock()
+			search.timedOut = true
+			search.mu.Unlock()
+			return
+		}
 
 		// get next peer to query
 		search.mu.Lock()
@@ -81,12 +181,20 @@ func (s *searcher) searchWork(search *Search, wg *sync.WaitGroup) {
 		search.mu.Unlock()
 
 		// do the query
-		response, err := s.query(next.Connector(), search)
+		response, err := s.query(ctx, next.Connector(), search)
+		if err != nil && ctx.Err() != nil {
+			// query was cut short by the overall timeout, so don't count it against the peer
+			search.mu.Lock()
+			search.timedOut = true
+			search.mu.Unlock()
+			return
+		}
 		if err != nil {
 			// if we had an issue querying, skip to next peer
 			search.mu.Lock()
 			search.Result.Errored[nextIDStr] = err
 			next.Recorder().Record(peer.Response, peer.Error)
+			next.RecordFailure()
 			if search.Errored() {
 				search.Result.FatalErr = ErrTooManyFindErrors
 			}
@@ -95,6 +203,7 @@ func (s *searcher) searchWork(search *Search, wg *sync.WaitGroup) {
 		}
 		search.mu.Lock()
 		next.Recorder().Record(peer.Response, peer.Success)
+		next.RecordSuccess()
 		search.mu.Unlock()
 
 		// process the heap's response
@@ -121,19 +230,28 @@ func (s *searcher) searchWork(search *Search, wg *sync.WaitGroup) {
 		if _, in := search.Result.Responded[nextIDStr]; !in {
 			search.Result.Responded[nextIDStr] = next
 		}
+		if response.HasValue {
+			search.Result.Holders[nextIDStr] = next
+		}
 		search.mu.Unlock()
 	}
 }
 
-func (s *searcher) query(pConn api.Connector, search *Search) (*api.FindResponse, error) {
-	ctx, cancel, err := client.NewSignedTimeoutContext(s.signer, search.Request,
+func (s *searcher) query(ctx context.Context, pConn api.Connector, search *Search) (
+	*api.FindResponse, error) {
+	// bound the query by the clock, in addition to the signed context's wall clock timeout
+	ctx, clockCancel := clock.WithTimeout(ctx, s.clock, search.Params.Timeout)
+	defer clockCancel()
+	ctx, cancel, err := client.NewSignedParentTimeoutContext(ctx, s.signer, search.Request,
 		search.Params.Timeout)
 	if err != nil {
 		return nil, err
 	}
 
+	start := time.Now()
 	rp, err := s.querier.Query(ctx, pConn, search.Request)
 	cancel()
+	s.metrics.observeFindQuery(start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -183,6 +301,11 @@ func (frp *responseProcessor) Process(rp *api.FindResponse, result *Result) erro
 		return nil
 	}
 
+	if rp.HasValue {
+		// response to presence query from peer storing value but without any closer peers
+		return nil
+	}
+
 	// invalid response
 	return errors.New("FindResponse contains neither value nor peer addresses")
 }
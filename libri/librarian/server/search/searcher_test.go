@@ -5,14 +5,17 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"errors"
 
+	"github.com/drausin/libri/libri/common/clock"
 	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -20,10 +23,24 @@ import (
 
 func TestNewDefaultSearcher(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
-	s := NewDefaultSearcher(client.NewSigner(ecid.NewPseudoRandom(rng).Key()))
+	s := NewDefaultSearcher(client.NewSigner(ecid.NewPseudoRandom(rng).Key()), clock.New())
 	assert.NotNil(t, s.(*searcher).signer)
 	assert.NotNil(t, s.(*searcher).querier)
 	assert.NotNil(t, s.(*searcher).rp)
+	assert.NotNil(t, s.(*searcher).clock)
+
+	// check nil clock defaults to wall clock
+	s = NewDefaultSearcher(client.NewSigner(ecid.NewPseudoRandom(rng).Key()), nil)
+	assert.NotNil(t, s.(*searcher).clock)
+}
+
+func TestNewDefaultSearcherWithMetrics(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	f, m := &TestFromer{}, NewMetrics()
+	s := NewDefaultSearcherWithMetrics(client.NewSigner(ecid.NewPseudoRandom(rng).Key()), nil, f,
+		m)
+	assert.Equal(t, f, s.(*searcher).rp.(*responseProcessor).fromer)
+	assert.Equal(t, m, s.(*searcher).metrics)
 }
 
 func TestSearcher_Search_ok(t *testing.T) {
@@ -79,6 +96,62 @@ func TestSearcher_Search_ok(t *testing.T) {
 	}
 }
 
+func TestSearcher_Search_locate(t *testing.T) {
+	n, nClosestResponses := 32, uint(8)
+	rng := rand.New(rand.NewSource(int64(n)))
+	peers, peersMap, selfPeerIdxs, selfID := NewTestPeers(rng, n)
+	key := cid.NewPseudoRandom(rng)
+	holders := map[string]bool{
+		peers[1].ID().String(): true,
+		peers[2].ID().String(): true,
+	}
+	searcher := NewSearcher(
+		&client.TestNoOpSigner{},
+		&presenceFindQuerier{holders: holders},
+		&responseProcessor{fromer: &TestFromer{Peers: peersMap}},
+	)
+	search := NewLocateSearch(selfID, key, &Parameters{
+		NClosestResponses: nClosestResponses,
+		NMaxErrors:        DefaultNMaxErrors,
+		Concurrency:       DefaultConcurrency,
+		Timeout:           DefaultQueryTimeout,
+	})
+	seeds := NewTestSeeds(peers, selfPeerIdxs)
+
+	// check search continues to closest peers and records the responding peers holding the value
+	err := searcher.Search(search, seeds)
+	assert.Nil(t, err)
+	assert.True(t, search.FoundClosestPeers())
+	assert.False(t, search.FoundValue())
+	for idStr := range search.Result.Holders {
+		assert.True(t, holders[idStr])
+		_, responded := search.Result.Responded[idStr]
+		assert.True(t, responded)
+	}
+	for idStr := range search.Result.Responded {
+		_, isHolder := search.Result.Holders[idStr]
+		assert.Equal(t, holders[idStr], isHolder)
+	}
+}
+
+// presenceFindQuerier responds to Find queries like TestFindQuerier but also says whether the
+// queried peer holds the value.
+type presenceFindQuerier struct {
+	holders map[string]bool
+}
+
+func (c *presenceFindQuerier) Query(ctx context.Context, pConn api.Connector,
+	rq *api.FindRequest, opts ...grpc.CallOption) (*api.FindResponse, error) {
+	tc := pConn.(*peer.TestConnector)
+	return &api.FindResponse{
+		Metadata: &api.ResponseMetadata{
+			RequestId: rq.Metadata.RequestId,
+		},
+		Peers:    tc.Addresses,
+		HasValue: rq.Presence && c.holders[cid.FromBytes(tc.APISelf.PeerId).String()],
+	}, nil
+}
+
 func TestSearcher_Search_queryErr(t *testing.T) {
 	searcherImpl, search, selfPeerIdxs, peers := newTestSearch()
 	seeds := NewTestSeeds(peers, selfPeerIdxs)
@@ -133,6 +206,129 @@ func TestSearcher_Search_rpErr(t *testing.T) {
 	assert.Equal(t, 0, len(search.Result.Responded))
 }
 
+func TestSearcher_Search_overallTimeout(t *testing.T) {
+	n, nClosestResponses := 32, uint(8)
+	rng := rand.New(rand.NewSource(int64(n)))
+	peers, peersMap, selfPeerIdxs, selfID := NewTestPeers(rng, n)
+	searcherImpl := NewTestSearcher(peersMap)
+	searcherImpl.(*searcher).querier = &slowQuerier{
+		delay: 20 * time.Millisecond,
+		inner: searcherImpl.(*searcher).querier,
+	}
+	search := NewSearch(selfID, cid.NewPseudoRandom(rng), &Parameters{
+		NClosestResponses: nClosestResponses,
+		NMaxErrors:        DefaultNMaxErrors,
+		Concurrency:       uint(1),
+		Timeout:           time.Second,
+		OverallTimeout:    50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	err := searcherImpl.Search(search, NewTestSeeds(peers, selfPeerIdxs))
+
+	// check search ends at overall timeout with partial result, before per-query timeout
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < search.Params.Timeout)
+	assert.True(t, search.TimedOut())
+	assert.True(t, search.Finished())
+	assert.False(t, search.FoundClosestPeers())
+	assert.False(t, search.Errored())
+	assert.True(t, len(search.Result.Responded) > 0)
+	assert.True(t, uint(len(search.Result.Responded)) < nClosestResponses)
+	assert.Equal(t, 0, len(search.Result.Errored))
+}
+
+func TestSearcher_SearchMulti_ok(t *testing.T) {
+	n, nClosestResponses := 32, uint(8)
+	rng := rand.New(rand.NewSource(int64(n)))
+	peers, peersMap, selfPeerIdxs, selfID := NewTestPeers(rng, n)
+	key := cid.NewPseudoRandom(rng)
+	searcher := NewTestSearcher(peersMap)
+
+	for nGroups := 1; nGroups <= 3; nGroups++ {
+		search := NewSearch(selfID, key, &Parameters{
+			NClosestResponses: nClosestResponses,
+			NMaxErrors:        DefaultNMaxErrors,
+			Concurrency:       uint(2),
+			Timeout:           DefaultQueryTimeout,
+		})
+
+		// split seeds into disjoint groups
+		seeds := NewTestSeeds(peers, selfPeerIdxs)
+		seedGroups := make([][]peer.Peer, nGroups)
+		for i, seed := range seeds {
+			seedGroups[i%nGroups] = append(seedGroups[i%nGroups], seed)
+		}
+
+		err := searcher.SearchMulti(search, seedGroups)
+
+		// check merged result has the usual number of closest peers
+		assert.Nil(t, err)
+		assert.True(t, search.FoundClosestPeers())
+		assert.False(t, search.Errored())
+		assert.Equal(t, int(nClosestResponses), search.Result.Closest.Len())
+		assert.True(t, search.Result.Closest.Len() <= len(search.Result.Responded))
+
+		// check merged closest peers are the closest of all the peers
+		farthestCloseDist := search.Result.Closest.PeakDistance()
+		nCloser := 0
+		for _, p := range peers {
+			if key.Distance(p.ID()).Cmp(farthestCloseDist) <= 0 {
+				nCloser++
+			}
+		}
+		assert.Equal(t, int(nClosestResponses), nCloser)
+	}
+}
+
+func TestSearcher_SearchMulti_queryErr(t *testing.T) {
+	searcherImpl, search, selfPeerIdxs, peers := newTestSearch()
+	seeds := NewTestSeeds(peers, selfPeerIdxs)
+	seedGroups := [][]peer.Peer{seeds[:len(seeds)/2], seeds[len(seeds)/2:]}
+
+	// all queries return errors as if they'd timed out
+	searcherImpl.(*searcher).querier = &timeoutQuerier{}
+
+	err := searcherImpl.SearchMulti(search, seedGroups)
+
+	// check fatal error when every sub-search has one
+	assert.Equal(t, ErrTooManyFindErrors, err)
+	assert.True(t, search.Errored())
+	assert.False(t, search.FoundClosestPeers())
+	assert.Equal(t, 0, search.Result.Closest.Len())
+	assert.Equal(t, 0, len(search.Result.Responded))
+}
+
+func TestSeedGroups(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, nAdded := routing.NewTestWithPeers(rng, 64)
+	key := cid.NewPseudoRandom(rng)
+	k := uint(3)
+
+	// check single group is just closest peers
+	groups := SeedGroups(rt, key, k, 1, rng)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, rt.Peak(key, k), groups[0])
+
+	// check multiple groups are disjoint and of bounded size
+	nGroups := uint(4)
+	groups = SeedGroups(rt, key, k, nGroups, rng)
+	assert.True(t, len(groups) > 1)
+	assert.True(t, uint(len(groups)) <= nGroups)
+	assert.Equal(t, rt.Peak(key, k), groups[0])
+	seen := make(map[string]struct{})
+	for _, group := range groups {
+		assert.NotEmpty(t, group)
+		assert.True(t, uint(len(group)) <= k)
+		for _, p := range group {
+			_, in := seen[p.ID().String()]
+			assert.False(t, in)
+			seen[p.ID().String()] = struct{}{}
+		}
+	}
+	assert.True(t, len(seen) <= nAdded)
+}
+
 func newTestSearch() (Searcher, *Search, []int, []peer.Peer) {
 	n, nClosestResponses := 32, uint(8)
 	rng := rand.New(rand.NewSource(int64(n)))
@@ -170,15 +366,39 @@ func TestSearcher_query_ok(t *testing.T) {
 		signer:  &client.TestNoOpSigner{},
 		querier: &noOpQuerier{},
 		rp:      nil,
+		clock:   clock.New(),
 	}
 	connClient := api.NewConnector(nil) // won't actually be uses since we're mocking the finder
 
-	rp, err := s.query(connClient, search)
+	rp, err := s.query(context.Background(), connClient, search)
 	assert.Nil(t, err)
 	assert.NotNil(t, rp.Metadata.RequestId)
 	assert.Nil(t, rp.Value)
 }
 
+func TestSearcher_query_clockTimeout(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	peerID, key := ecid.NewPseudoRandom(rng), cid.NewPseudoRandom(rng)
+	search := NewSearch(peerID, key, &Parameters{Timeout: time.Hour})
+	fake := clock.NewFake(time.Unix(0, 0))
+	s := &searcher{
+		signer:  &client.TestNoOpSigner{},
+		querier: &slowQuerier{delay: time.Hour, inner: &noOpQuerier{}},
+		clock:   fake,
+	}
+	connClient := api.NewConnector(nil) // won't actually be used since we're mocking the finder
+
+	// check query times out once the fake clock passes the query timeout
+	errs := make(chan error, 1)
+	go func() {
+		_, err := s.query(context.Background(), connClient, search)
+		errs <- err
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(search.Params.Timeout)
+	assert.Equal(t, context.Canceled, <-errs)
+}
+
 // timeoutQuerier returns an error simulating a request timeout
 type timeoutQuerier struct{}
 
@@ -187,6 +407,22 @@ func (f *timeoutQuerier) Query(ctx context.Context, pConn api.Connector, fr *api
 	return nil, errors.New("simulated timeout error")
 }
 
+// slowQuerier delays each query to another querier, ending early if its context does
+type slowQuerier struct {
+	delay time.Duration
+	inner client.FindQuerier
+}
+
+func (f *slowQuerier) Query(ctx context.Context, pConn api.Connector, fr *api.FindRequest,
+	opts ...grpc.CallOption) (*api.FindResponse, error) {
+	select {
+	case <-time.After(f.delay):
+		return f.inner.Query(ctx, pConn, fr, opts...)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // diffRequestIDFinder returns a response with a different request ID
 type diffRequestIDQuerier struct {
 	rng *rand.Rand
@@ -211,8 +447,9 @@ func TestSearcher_query_err(t *testing.T) {
 		signer: &client.TestNoOpSigner{},
 		// use querier that simulates a timeout
 		querier: &timeoutQuerier{},
+		clock:   clock.New(),
 	}
-	rp1, err := s1.query(connClient, search)
+	rp1, err := s1.query(context.Background(), connClient, search)
 	assert.Nil(t, rp1)
 	assert.NotNil(t, err)
 
@@ -222,15 +459,17 @@ func TestSearcher_query_err(t *testing.T) {
 		querier: &diffRequestIDQuerier{
 			rng: rng,
 		},
+		clock: clock.New(),
 	}
-	rp2, err := s2.query(connClient, search)
+	rp2, err := s2.query(context.Background(), connClient, search)
 	assert.Nil(t, rp2)
 	assert.NotNil(t, err)
 
 	s3 := &searcher{
 		signer: &client.TestErrSigner{},
+		clock:  clock.New(),
 	}
-	rp3, err := s3.query(connClient, search)
+	rp3, err := s3.query(context.Background(), connClient, search)
 	assert.Nil(t, rp3)
 	assert.NotNil(t, err)
 }
@@ -304,6 +543,23 @@ func TestResponseProcessor_Process_Addresses(t *testing.T) {
 	assert.Equal(t, nAddresses2, result.Closest.Len())
 }
 
+func TestResponseProcessor_Process_HasValue(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	key := cid.NewPseudoRandom(rng)
+	rp := NewResponseProcessor(peer.NewFromer())
+	result := NewInitialResult(key, NewDefaultParameters())
+
+	// check presence response without any peers is still valid
+	response := &api.FindResponse{
+		Peers:    nil,
+		HasValue: true,
+	}
+	err := rp.Process(response, result)
+	assert.Nil(t, err)
+	assert.Nil(t, result.Value)
+	assert.Zero(t, result.Unqueried.Len())
+}
+
 func TestResponseProcessor_Process_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(int64(0)))
 	key := cid.NewPseudoRandom(rng)
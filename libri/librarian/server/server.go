@@ -4,11 +4,16 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"sync"
+	"time"
 
+	"github.com/drausin/libri/libri/common/clock"
 	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
+	clogging "github.com/drausin/libri/libri/common/logging"
 	"github.com/drausin/libri/libri/common/storage"
 	"github.com/drausin/libri/libri/common/subscribe"
 	"github.com/drausin/libri/libri/librarian/api"
@@ -18,9 +23,13 @@ import (
 	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/drausin/libri/libri/librarian/server/search"
 	"github.com/drausin/libri/libri/librarian/server/store"
+	"github.com/golang/protobuf/proto"
 	"github.com/willf/bloom"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 )
 
@@ -41,6 +50,9 @@ type Librarian struct {
 	// executes searches for peers and keys
 	searcher search.Searcher
 
+	// caches the closest peers found by recent searches to seed repeated searches
+	searchCache search.Cache
+
 	// executes stores for key/value
 	storer store.Storer
 
@@ -65,6 +77,19 @@ type Librarian struct {
 	// SL for p2p stored documents
 	documentSL storage.DocumentSL
 
+	// pins stored documents the librarian is responsible for so they aren't evicted, or nil when
+	// the total size of stored documents is unbounded
+	documentPins storage.DocumentPinner
+
+	// deletes expired documents in the background
+	expirySweeper ExpirySweeper
+
+	// refreshes routing table buckets without recent lookups in the background
+	bucketRefresher BucketRefresher
+
+	// re-stores under-replicated documents in the background
+	replicationMaintainer ReplicationMaintainer
+
 	// ensures keys are valid
 	kc storage.Checker
 
@@ -86,21 +111,87 @@ type Librarian struct {
 	// health server
 	health *health.Server
 
+	// sets the health status from whether the routing table has enough peers
+	healthReporter HealthReporter
+
+	// Prometheus metrics
+	metrics *metrics
+
+	// logs a sample of handled RPC requests
+	accessLogger *accessLogger
+
+	// rejects Store requests from peers sending them too quickly
+	storeLimiter *storeLimiter
+
+	// rejects Store requests for new documents once the DB is full
+	storageQuota *StorageQuota
+
+	// maximum size of documents accepted by Store requests, or zero for no maximum
+	maxDocBytes uint64
+
+	// TLS credentials of the gRPC server, or nil to listen in plaintext
+	creds credentials.TransportCredentials
+
 	// receives graceful stop signal
 	stop chan struct{}
+
+	// guards closing stop against starting background work, so nothing starts once closed
+	stopMu sync.Mutex
+
+	// waits for background bootstrap retries to finish
+	wg sync.WaitGroup
 }
 
 var newPublicationsSlack = 16
 
-// NewLibrarian creates a new librarian instance.
+// errMissingPutStreamRequest indicates when the first chunk of a PutStream is missing its request.
+var errMissingPutStreamRequest = grpc.Errorf(codes.InvalidArgument,
+	"first put stream chunk missing request")
+
+// NewLibrarian creates a new librarian instance logging to the given logger, which may be
+// pre-configured (e.g., to write JSON to a rotated file) when embedding a librarian. Entries below
+// the configured LogLevel are dropped. It returns the config's Validate error, if any.
 func NewLibrarian(config *Config, logger *zap.Logger) (*Librarian, error) {
-	rdb, err := db.NewRocksDB(config.DbDir)
+	if err := config.Validate(); err != nil {
+		logger.Error("invalid config", zap.Error(err))
+		return nil, err
+	}
+	logger = clogging.WithLevel(logger, config.LogLevel)
+	var creds, peerCreds credentials.TransportCredentials
+	if config.tlsEnabled() {
+		var err error
+		creds, err = newServerCredentials(config.TLSCertFile, config.TLSKeyFile,
+			config.TLSClientCAFile)
+		if err != nil {
+			logger.Error("unable to load TLS credentials", zap.Error(err))
+			return nil, err
+		}
+		peerCreds, err = newPeerCredentials(config.TLSCertFile, config.TLSKeyFile,
+			config.TLSPeerCAFile)
+		if err != nil {
+			logger.Error("unable to load peer TLS credentials", zap.Error(err))
+			return nil, err
+		}
+	}
+	// peers may send documents as large as ours, so allow messages as large as we receive
+	fromer := peer.NewSecureFromer(peerCreds, api.WithMaxMessageBytes(int(config.MaxMessageBytes)))
+	rdb, err := db.NewKVDB(config.DBBackend, config.DbDir)
 	if err != nil {
-		logger.Error("unable to init RocksDB", zap.Error(err))
+		logger.Error("unable to init DB", zap.String("db_backend", config.DBBackend),
+			zap.String("db_dir", config.DbDir), zap.Error(err))
 		return nil, err
 	}
 	serverSL := storage.NewServerSL(rdb)
-	documentSL := storage.NewDocumentSLD(rdb)
+	var documentSL storage.DocumentSLD = storage.NewDocumentSLD(rdb)
+	var documentPins storage.DocumentPinner
+	if config.MaxStoredDocumentBytes > 0 {
+		boundedSL, err := storage.NewBoundedDocumentSLD(documentSL, config.MaxStoredDocumentBytes)
+		if err != nil {
+			logger.Error("unable to init bounded document storage", zap.Error(err))
+			return nil, err
+		}
+		documentSL, documentPins = boundedSL, boundedSL
+	}
 
 	// get peer ID and immediately save it so subsequent restarts have it
 	peerID, err := loadOrCreatePeerID(logger, serverSL)
@@ -108,13 +199,25 @@ func NewLibrarian(config *Config, logger *zap.Logger) (*Librarian, error) {
 		return nil, err
 	}
 
-	rt, err := loadOrCreateRoutingTable(logger, serverSL, peerID, config.Routing)
+	rt, err := loadOrCreateRoutingTable(logger, serverSL, peerID, config.Routing, fromer)
 	if err != nil {
 		return nil, err
 	}
 
 	signer := client.NewSigner(peerID.Key())
-	searcher := search.NewDefaultSearcher(signer)
+	metrics := newMetrics(rt)
+	clk := config.Clock
+	if clk == nil {
+		// hand-built configs may not have a clock
+		clk = clock.New()
+	}
+	introducer := introduce.NewIntroducer(signer, client.NewIntroduceQuerier(),
+		introduce.NewResponseProcessor(fromer, peerID.ID()))
+	searcher := search.NewDefaultSearcherWithMetrics(signer, clk, fromer, metrics.search)
+	searchCache, err := search.NewCache(config.Search.CacheSize, config.Search.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
 	newPubs := make(chan *subscribe.KeyedPub, newPublicationsSlack)
 
 	recentPubs, err := subscribe.NewRecentPublications(config.SubscribeTo.RecentCacheSize)
@@ -122,31 +225,66 @@ func NewLibrarian(config *Config, logger *zap.Logger) (*Librarian, error) {
 		return nil, err
 	}
 	clientBalancer := routing.NewClientBalancer(rt)
+	subscribeFrom := subscribe.NewFromWithMetrics(config.SubscribeFrom, logger, newPubs,
+		metrics.subscribe)
 	subscribeTo := subscribe.NewTo(config.SubscribeTo, logger, peerID, clientBalancer, signer,
 		recentPubs, newPubs)
+	accessLogger := newAccessLogger(logger, config.AccessLogLevel, config.AccessLogSampleRate)
+	replayCache, err := client.NewReplayCache(config.RequestReplayCacheSize,
+		config.RequestReplayWindow)
+	if err != nil {
+		return nil, err
+	}
+	storeLimiter, err := newStoreLimiter(config.StoreRequestRate, config.StoreRequestBurst)
+	if err != nil {
+		return nil, err
+	}
+	expirySweeper := NewExpirySweeper(storage.NewDocumentIterator(rdb), documentSL,
+		config.ExpirySweepInterval, logger)
+	expirySweeper.Start()
+	bucketRefresher := NewBucketRefresher(peerID, rt, searcher, config.Search,
+		config.BucketRefreshInterval, logger)
+	storer := store.NewStorerWithMetrics(signer, searcher, client.NewStoreQuerier(),
+		metrics.store)
+	replicationMaintainer := NewReplicationMaintainer(peerID,
+		storage.NewDocumentIterator(rdb), documentSL, documentPins, rt, storer, signer,
+		config.Search, config.Store, config.ReplicationCheckInterval, config.ReplicationMaxStores, logger)
+	healthServer := health.NewServer()
 
 	return &Librarian{
-		selfID:        peerID,
-		config:        config,
-		apiSelf:       api.FromAddress(peerID.ID(), config.PublicName, config.PublicAddr),
-		introducer:    introduce.NewDefaultIntroducer(signer, peerID.ID()),
-		searcher:      searcher,
-		storer:        store.NewStorer(signer, searcher, client.NewStoreQuerier()),
-		subscribeFrom: subscribe.NewFrom(config.SubscribeFrom, logger, newPubs),
-		subscribeTo:   subscribeTo,
-		RecentPubs:    recentPubs,
-		rqv:           NewRequestVerifier(),
-		db:            rdb,
-		serverSL:      serverSL,
-		documentSL:    documentSL,
-		kc:            storage.NewExactLengthChecker(storage.EntriesKeyLength),
-		kvc:           storage.NewHashKeyValueChecker(),
-		fromer:        peer.NewFromer(),
-		signer:        signer,
-		rt:            rt,
-		logger:        logger,
-		health:        health.NewServer(),
-		stop:          make(chan struct{}),
+		selfID:                peerID,
+		config:                config,
+		apiSelf:               api.FromAddress(peerID.ID(), config.PublicName, config.PublicAddr),
+		introducer:            introducer,
+		searcher:              searcher,
+		searchCache:           searchCache,
+		storer:                storer,
+		subscribeFrom:         subscribeFrom,
+		subscribeTo:           subscribeTo,
+		RecentPubs:            recentPubs,
+		rqv:                   NewRequestVerifierWithCache(replayCache, config.SigningSchemes...),
+		db:                    rdb,
+		serverSL:              serverSL,
+		documentSL:            documentSL,
+		documentPins:          documentPins,
+		expirySweeper:         expirySweeper,
+		bucketRefresher:       bucketRefresher,
+		replicationMaintainer: replicationMaintainer,
+		kc:                    storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		kvc:                   storage.NewHashKeyValueChecker(),
+		fromer:                fromer,
+		signer:                signer,
+		rt:                    rt,
+		logger:                logger,
+		health:                healthServer,
+		healthReporter:        NewHealthReporter(healthServer, rt, config.MinHealthyPeers, logger),
+		metrics:               metrics,
+		accessLogger:          accessLogger,
+		storeLimiter:          storeLimiter,
+		storageQuota:          NewStorageQuota(config.DataDirQuota, rdb, documentSL),
+		maxDocBytes:           config.MaxDocumentBytes,
+		creds:                 creds,
+		stop:                  make(chan struct{}),
 	}, nil
 }
 
@@ -190,7 +328,8 @@ func (l *Librarian) Introduce(ctx context.Context, rq *api.IntroduceRequest) (
 	}, nil
 }
 
-// Find returns either the value at a given target or the peers closest to it.
+// Find returns either the value at a given target or the peers closest to it. Presence requests
+// always get the closest peers along with whether the value is stored here, but never the value.
 func (l *Librarian) Find(ctx context.Context, rq *api.FindRequest) (*api.FindResponse, error) {
 	keyStr := fmt.Sprintf("%064x", rq.Key)
 	l.logger.Debug("received find request", zap.String("key", keyStr))
@@ -209,7 +348,7 @@ func (l *Librarian) Find(ctx context.Context, rq *api.FindRequest) (*api.FindRes
 
 	// we have the value, so return it
 	l.logger.Debug("found value", zap.String("key", keyStr))
-	if value != nil {
+	if value != nil && !rq.Presence {
 		return &api.FindResponse{
 			Metadata: l.NewResponseMetadata(rq.Metadata),
 			Value:    value,
@@ -226,10 +365,14 @@ func (l *Librarian) Find(ctx context.Context, rq *api.FindRequest) (*api.FindRes
 	return &api.FindResponse{
 		Metadata: l.NewResponseMetadata(rq.Metadata),
 		Peers:    peer.ToAPIs(closest),
+		HasValue: rq.Presence && value != nil,
 	}, nil
 }
 
-// Store stores the value.
+// Store stores the value. Peers sending Store requests faster than the configured rate (beyond
+// the burst) are rejected with a ResourceExhausted error, as are new documents once the storage
+// quota has been reached. Documents larger than the configured maximum size are rejected with an
+// InvalidArgument error.
 func (l *Librarian) Store(ctx context.Context, rq *api.StoreRequest) (
 	*api.StoreResponse, error) {
 	keyStr := fmt.Sprintf("%064x", rq.Key)
@@ -239,11 +382,32 @@ func (l *Librarian) Store(ctx context.Context, rq *api.StoreRequest) (
 	if err != nil {
 		return nil, err
 	}
+	if !l.storeLimiter.allow(requesterID) {
+		l.record(requesterID, peer.Request, peer.Error)
+		return nil, errStoreRateExceeded
+	}
+	if l.maxDocBytes > 0 && uint64(proto.Size(rq.Value)) > l.maxDocBytes {
+		return nil, ErrDocumentTooLarge
+	}
+	release, err := l.storageQuota.Reserve(cid.FromBytes(rq.Key), uint64(proto.Size(rq.Value)))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	l.record(requesterID, peer.Request, peer.Success)
+	if api.IsExpired(rq.Value, time.Now()) {
+		// don't replicate expired documents
+		return nil, api.ErrExpiredRPC
+	}
 
 	if err := l.documentSL.Store(cid.FromBytes(rq.Key), rq.Value); err != nil {
 		return nil, err
 	}
+	if l.documentPins != nil {
+		// peers store documents with the librarians closest to them, so keep it until
+		// replication maintenance finds another is responsible for it instead
+		l.documentPins.Pin(cid.FromBytes(rq.Key))
+	}
 	if err := l.subscribeTo.Send(api.GetPublication(rq.Key, rq.Value)); err != nil {
 		return nil, err
 	}
@@ -259,7 +423,9 @@ func (l *Librarian) Store(ctx context.Context, rq *api.StoreRequest) (
 }
 
 // Get returns the value for a given key, if it exists. This endpoint handles the internals of
-// searching for the key.
+// searching for the key. Since documents are content-addressed, callers that already hold the value
+// can set ExistsOnly to confirm it exists without transferring it, in which case the search asks
+// peers whether they store the value rather than for the value itself.
 func (l *Librarian) Get(ctx context.Context, rq *api.GetRequest) (*api.GetResponse, error) {
 	keyStr := fmt.Sprintf("%064x", rq.Key)
 	l.logger.Debug("received get request", zap.String("key", keyStr))
@@ -268,21 +434,55 @@ func (l *Librarian) Get(ctx context.Context, rq *api.GetRequest) (*api.GetRespon
 	if err != nil {
 		return nil, err
 	}
+	searchParams, err := l.getSearchParams(rq.SearchConcurrency)
+	if err != nil {
+		l.record(requesterID, peer.Request, peer.Error)
+		return nil, err
+	}
 	l.record(requesterID, peer.Request, peer.Success)
 
 	key := cid.FromBytes(rq.Key)
-	s := search.NewSearch(l.selfID, key, l.config.Search)
-	seeds := l.rt.Peak(key, s.Params.Concurrency)
-	err = l.searcher.Search(s, seeds)
+	s := search.NewSearch(l.selfID, key, searchParams)
+	if rq.ExistsOnly {
+		s = search.NewExistsSearch(l.selfID, key, searchParams)
+	}
+
+	// use request ID as unique source of entropy for sampling any extra seed groups
+	seed := int64(binary.BigEndian.Uint64(rq.Metadata.RequestId[:8]))
+	seedGroups := search.SeedGroups(l.rt, key, s.Params.Concurrency, s.Params.NSeedGroups,
+		rand.New(rand.NewSource(seed)))
+	if cached, in := l.searchCache.Get(key); in {
+		// also start from the peers found closest by a recent search for the same key, keeping
+		// the other groups disjoint paths through the routing table
+		seedGroups = append(seedGroups, cached)
+	}
+	err = l.searcher.SearchMulti(s, seedGroups)
 	if err != nil {
 		return nil, err
 	}
+	if s.FoundValue() || s.FoundHolder() || s.FoundClosestPeers() {
+		l.searchCache.Add(key, s.Result.Closest.Peers())
+	}
 
 	// add found peers to routing table
 	for _, p := range s.Result.Closest.Peers() {
 		l.rt.Push(p)
 	}
+	l.rt.MarkRefreshed(key)
 
+	if s.FoundValue() && api.IsExpired(s.Result.Value, time.Now()) {
+		l.logger.Info("got expired value", zap.String("key", key.String()))
+		return nil, api.ErrExpiredRPC
+	}
+	if rq.ExistsOnly && (s.FoundValue() || s.FoundHolder()) {
+		// confirm the value exists without returning it, including when peers that predate
+		// presence queries respond with the value
+		l.logger.Info("got value existence", zap.String("key", key.String()))
+		return &api.GetResponse{
+			Metadata: l.NewResponseMetadata(rq.Metadata),
+			Exists:   true,
+		}, nil
+	}
 	if s.FoundValue() {
 		// return the value found by the search
 		l.logger.Info("got value", zap.String("key", key.String()))
@@ -299,6 +499,15 @@ func (l *Librarian) Get(ctx context.Context, rq *api.GetRequest) (*api.GetRespon
 			Value:    nil,
 		}, nil
 	}
+	if s.TimedOut() {
+		// return the partial result, flagging that the value may exist but wasn't found in time
+		l.logger.Info("timed out getting value", zap.String("key", key.String()))
+		return &api.GetResponse{
+			Metadata: l.NewResponseMetadata(rq.Metadata),
+			Value:    nil,
+			TimedOut: true,
+		}, nil
+	}
 	if s.Errored() {
 		return nil, errors.New("search for key errored")
 	}
@@ -309,6 +518,96 @@ func (l *Librarian) Get(ctx context.Context, rq *api.GetRequest) (*api.GetRespon
 	return nil, errors.New("unexpected search result")
 }
 
+// Locate returns the peers storing the value for a given key without retrieving it. This endpoint
+// handles the internals of searching for the key's closest peers and asking whether they store it.
+// A key stored nowhere gives an empty list of peers rather than an error.
+func (l *Librarian) Locate(ctx context.Context, rq *api.LocateRequest) (*api.LocateResponse,
+	error) {
+	keyStr := fmt.Sprintf("%064x", rq.Key)
+	l.logger.Debug("received locate request", zap.String("key", keyStr))
+
+	requesterID, err := l.checkRequestAndKey(ctx, rq, rq.Metadata, rq.Key)
+	if err != nil {
+		return nil, err
+	}
+	l.record(requesterID, peer.Request, peer.Success)
+
+	key := cid.FromBytes(rq.Key)
+	s := search.NewLocateSearch(l.selfID, key, l.config.Search)
+
+	// use request ID as unique source of entropy for sampling any extra seed groups
+	seed := int64(binary.BigEndian.Uint64(rq.Metadata.RequestId[:8]))
+	seedGroups := search.SeedGroups(l.rt, key, s.Params.Concurrency, s.Params.NSeedGroups,
+		rand.New(rand.NewSource(seed)))
+	err = l.searcher.SearchMulti(s, seedGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	// add found peers to routing table
+	for _, p := range s.Result.Closest.Peers() {
+		l.rt.Push(p)
+	}
+	l.rt.MarkRefreshed(key)
+
+	if !s.FoundClosestPeers() {
+		if s.TimedOut() {
+			return nil, errors.New("search for key timed out")
+		}
+		if s.Errored() {
+			return nil, errors.New("search for key errored")
+		}
+		if s.Exhausted() {
+			return nil, errors.New("search for key exhausted")
+		}
+		return nil, errors.New("unexpected search result")
+	}
+
+	holders := make([]peer.Peer, 0, len(s.Result.Holders))
+	for _, p := range s.Result.Holders {
+		holders = append(holders, p)
+	}
+	apiHolders := peer.ToAPIs(holders)
+	value, err := l.documentSL.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	if value != nil {
+		apiHolders = append(apiHolders, l.apiSelf)
+	}
+	l.logger.Info("located value", zap.String("key", key.String()),
+		zap.Int("n_peers", len(apiHolders)))
+	return &api.LocateResponse{
+		Metadata: l.NewResponseMetadata(rq.Metadata),
+		Peers:    apiHolders,
+	}, nil
+}
+
+// Info returns the librarian's public ID and routing table stats for introspecting a running
+// node. It exposes only public peer IDs and counts.
+func (l *Librarian) Info(ctx context.Context, rq *api.InfoRequest) (*api.InfoResponse, error) {
+	l.logger.Debug("received info request")
+
+	requesterID, err := l.checkRequest(ctx, rq, rq.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	l.record(requesterID, peer.Request, peer.Success)
+
+	bucketSizes := l.rt.BucketSizes()
+	bucketPeers := make([]uint32, len(bucketSizes))
+	for i, size := range bucketSizes {
+		bucketPeers[i] = uint32(size)
+	}
+	return &api.InfoResponse{
+		Metadata:    l.NewResponseMetadata(rq.Metadata),
+		PeerId:      l.selfID.ID().Bytes(),
+		NumPeers:    uint32(l.rt.NumPeers()),
+		NumBuckets:  uint32(len(bucketSizes)),
+		BucketPeers: bucketPeers,
+	}, nil
+}
+
 // Put stores a given key and value. This endpoint handles the internals of finding the right
 // peers to store the value in and then sending them store requests.
 func (l *Librarian) Put(ctx context.Context, rq *api.PutRequest) (*api.PutResponse, error) {
@@ -320,6 +619,80 @@ func (l *Librarian) Put(ctx context.Context, rq *api.PutRequest) (*api.PutRespon
 		return nil, err
 	}
 	l.record(requesterID, peer.Request, peer.Success)
+	return l.put(rq)
+}
+
+// PutStream stores a given key and a value streamed in chunks, which may together exceed the max
+// gRPC message size, as in Put. The request in the first chunk is verified before the value is
+// received, and values larger than the configured maximum document size are rejected with an
+// InvalidArgument error.
+func (l *Librarian) PutStream(stream api.Librarian_PutStreamServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	rq := first.Request
+	if rq == nil || rq.Metadata == nil {
+		return errMissingPutStreamRequest
+	}
+	keyStr := fmt.Sprintf("%064x", rq.Key)
+	l.logger.Debug("received put stream request", zap.String("key", keyStr))
+
+	requesterID, err := l.checkRequestAndKey(stream.Context(), rq, rq.Metadata, rq.Key)
+	if err != nil {
+		return err
+	}
+	valueBytes, err := l.recvPutValue(stream, first.ValueChunk)
+	if err != nil {
+		l.record(requesterID, peer.Request, peer.Error)
+		return err
+	}
+	if err = l.kvc.Check(rq.Key, valueBytes); err != nil {
+		l.record(requesterID, peer.Request, peer.Error)
+		return err
+	}
+	rq.Value = &api.Document{}
+	if err = proto.Unmarshal(valueBytes, rq.Value); err != nil {
+		l.record(requesterID, peer.Request, peer.Error)
+		return err
+	}
+	l.record(requesterID, peer.Request, peer.Success)
+
+	rp, err := l.put(rq)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(rp)
+}
+
+// recvPutValue appends the value chunks received from the stream to the given value bytes until
+// the client closes it.
+func (l *Librarian) recvPutValue(stream api.Librarian_PutStreamServer, valueBytes []byte) (
+	[]byte, error) {
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return valueBytes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		valueBytes = append(valueBytes, chunk.ValueChunk...)
+		if l.maxDocBytes > 0 && uint64(len(valueBytes)) > l.maxDocBytes {
+			return nil, ErrDocumentTooLarge
+		}
+	}
+}
+
+// put stores the value of a verified Put request in the right peers.
+func (l *Librarian) put(rq *api.PutRequest) (*api.PutResponse, error) {
+	if api.IsExpired(rq.Value, time.Now()) {
+		return nil, api.ErrExpiredRPC
+	}
+	storeParams, err := l.putStoreParams(rq.NReplicas)
+	if err != nil {
+		return nil, err
+	}
 
 	key := cid.FromBytes(rq.Key)
 	s := store.NewStore(
@@ -327,7 +700,7 @@ func (l *Librarian) Put(ctx context.Context, rq *api.PutRequest) (*api.PutRespon
 		key,
 		rq.Value,
 		l.config.Search,
-		l.config.Store,
+		storeParams,
 	)
 	seeds := l.rt.Peak(key, s.Search.Params.Concurrency)
 	err = l.storer.Store(s, seeds)
@@ -338,15 +711,17 @@ func (l *Librarian) Put(ctx context.Context, rq *api.PutRequest) (*api.PutRespon
 	for _, p := range s.Result.Responded {
 		l.rt.Push(p)
 	}
+	l.rt.MarkRefreshed(key)
 	if s.Stored() {
 		l.logger.Info("put value",
 			zap.String("key", key.String()),
 			zap.String("operation", api.PutOperation_STORED.String()),
 		)
 		return &api.PutResponse{
-			Metadata:  l.NewResponseMetadata(rq.Metadata),
-			Operation: api.PutOperation_STORED,
-			NReplicas: uint32(len(s.Result.Responded)),
+			Metadata:       l.NewResponseMetadata(rq.Metadata),
+			Operation:      api.PutOperation_STORED,
+			NReplicas:      uint32(len(s.Result.Responded)),
+			ReplicaPeerIds: peerIDs(s.Result.Responded),
 		}, nil
 	}
 	if s.Exists() {
@@ -355,9 +730,40 @@ func (l *Librarian) Put(ctx context.Context, rq *api.PutRequest) (*api.PutRespon
 			zap.String("operation", api.PutOperation_LEFT_EXISTING.String()),
 		)
 		return &api.PutResponse{
-			Metadata:  l.NewResponseMetadata(rq.Metadata),
-			Operation: api.PutOperation_LEFT_EXISTING,
-			NReplicas: uint32(len(s.Result.Responded)),
+			Metadata:       l.NewResponseMetadata(rq.Metadata),
+			Operation:      api.PutOperation_LEFT_EXISTING,
+			NReplicas:      uint32(len(s.Result.Responded)),
+			ReplicaPeerIds: peerIDs(s.Result.Responded),
+		}, nil
+	}
+	if achieved := s.Result.ReplicationAchieved(); rq.MinNReplicas > 0 &&
+		achieved >= uint(rq.MinNReplicas) {
+		// store didn't finish successfully, but stored enough replicas for the requester
+		l.logger.Info("put value",
+			zap.String("key", key.String()),
+			zap.String("operation", api.PutOperation_STORED.String()),
+			zap.Uint("n_replicas", achieved),
+		)
+		return &api.PutResponse{
+			Metadata:       l.NewResponseMetadata(rq.Metadata),
+			Operation:      api.PutOperation_STORED,
+			NReplicas:      uint32(achieved),
+			ReplicaPeerIds: peerIDs(s.Result.Responded),
+		}, nil
+	}
+	if s.TimedOut() {
+		// return the partial result, flagging that fewer replicas than requested may be stored
+		achieved := s.Result.ReplicationAchieved()
+		l.logger.Info("timed out putting value",
+			zap.String("key", key.String()),
+			zap.Uint("n_replicas", achieved),
+		)
+		return &api.PutResponse{
+			Metadata:       l.NewResponseMetadata(rq.Metadata),
+			Operation:      api.PutOperation_STORED,
+			NReplicas:      uint32(achieved),
+			ReplicaPeerIds: peerIDs(s.Result.Responded),
+			TimedOut:       true,
 		}, nil
 	}
 	if s.Errored() {
@@ -370,6 +776,15 @@ func (l *Librarian) Put(ctx context.Context, rq *api.PutRequest) (*api.PutRespon
 	return nil, fmt.Errorf("unexpected store result: %v", s.Result)
 }
 
+// peerIDs returns the ID bytes of each peer.
+func peerIDs(peers []peer.Peer) [][]byte {
+	ids := make([][]byte, len(peers))
+	for i, p := range peers {
+		ids[i] = p.ID().Bytes()
+	}
+	return ids
+}
+
 func debugLogSearchResult(message string, s *search.Search, logger *zap.Logger) {
 	logger.Debug(message,
 		zap.Bool("finished", s.Finished()),
@@ -413,6 +828,7 @@ func (l *Librarian) Subscribe(rq *api.SubscribeRequest, from api.Librarian_Subsc
 	if err != nil {
 		return err
 	}
+	l.metrics.observeSubscription(authorFilter, readerFilter)
 	pubs, done, err := l.subscribeFrom.New()
 	if err != nil {
 		return err
@@ -3,11 +3,14 @@ package server
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"math/rand"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/common/db"
 	"github.com/drausin/libri/libri/common/ecid"
@@ -23,7 +26,10 @@ import (
 	"github.com/drausin/libri/libri/librarian/server/store"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -46,6 +52,44 @@ func TestNewLibrarian(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestNewLibrarian_logLevel(t *testing.T) {
+	config := newTestConfig().WithLogLevel(zapcore.WarnLevel)
+	l, err := NewLibrarian(config, clogging.NewDevLogger(zapcore.DebugLevel))
+	assert.Nil(t, err)
+	go func() { <-l.stop }() // dummy stop signal acceptor
+
+	// check injected logger respects configured log level
+	assert.False(t, l.logger.Core().Enabled(zapcore.InfoLevel))
+	assert.True(t, l.logger.Core().Enabled(zapcore.WarnLevel))
+	assert.Nil(t, l.CloseAndRemove())
+}
+
+func TestNewLibrarian_invalidConfig(t *testing.T) {
+	config := newTestConfig()
+	config.SubscribeTo.FPRate = 2.0
+	l, err := NewLibrarian(config, clogging.NewDevInfoLogger())
+	assert.Equal(t, ErrOutOfBoundsFPRate, err)
+	assert.Nil(t, l)
+}
+
+func TestNewLibrarian_tls(t *testing.T) {
+	config := newTestConfig()
+	certFile, keyFile := writeTestCertKey(t, config.DataDir)
+	config.WithTLS(certFile, keyFile).WithTLSClientCAFile(certFile)
+	l, err := NewLibrarian(config, clogging.NewDevInfoLogger())
+	assert.Nil(t, err)
+	go func() { <-l.stop }() // dummy stop signal acceptor
+	assert.NotNil(t, l.creds)
+	assert.Nil(t, l.CloseAndRemove())
+
+	// check bad cert file gives error
+	config = newTestConfig()
+	config.WithTLS(filepath.Join(config.DataDir, "missing.pem"), keyFile)
+	l, err = NewLibrarian(config, clogging.NewDevInfoLogger())
+	assert.NotNil(t, err)
+	assert.Nil(t, l)
+}
+
 func newTestLibrarian() *Librarian {
 	config := newTestConfig()
 	l, err := NewLibrarian(config, clogging.NewDevInfoLogger())
@@ -274,6 +318,52 @@ func TestLibrarian_Find_present(t *testing.T) {
 	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
 }
 
+func TestLibrarian_Find_presence(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	rt, peerID, nAdded := routing.NewTestWithPeers(rng, 64)
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+
+	l := &Librarian{
+		selfID:     peerID,
+		rt:         rt,
+		documentSL: storage.NewDocumentSLD(kvdb),
+		kc:         storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		rqv:        &alwaysRequestVerifier{},
+		logger:     clogging.NewDevInfoLogger(),
+	}
+	value, key := api.NewTestDocument(rng)
+	err = l.documentSL.Store(key, value)
+	assert.Nil(t, err)
+
+	// check presence request for stored key gets closest peers and presence but not the value
+	numClosest := uint32(routing.DefaultMaxActivePeers)
+	rq := &api.FindRequest{
+		Metadata: newTestRequestMetadata(rng, l.selfID),
+		Key:      key.Bytes(),
+		NumPeers: numClosest,
+		Presence: true,
+	}
+	rp, err := l.Find(nil, rq)
+	assert.Nil(t, err)
+	checkPeersResponse(t, rq, rp, nAdded, numClosest)
+	assert.True(t, rp.HasValue)
+
+	// check presence request for missing key gets closest peers and no presence
+	rq = &api.FindRequest{
+		Metadata: newTestRequestMetadata(rng, l.selfID),
+		Key:      cid.NewPseudoRandom(rng).Bytes(),
+		NumPeers: numClosest,
+		Presence: true,
+	}
+	rp, err = l.Find(nil, rq)
+	assert.Nil(t, err)
+	checkPeersResponse(t, rq, rp, nAdded, numClosest)
+	assert.False(t, rp.HasValue)
+}
+
 func TestLibrarian_Find_missing(t *testing.T) {
 	rng := rand.New(rand.NewSource(int64(0)))
 	rt, peerID, nAdded := routing.NewTestWithPeers(rng, 64)
@@ -361,6 +451,190 @@ func TestLibrarian_Store_ok(t *testing.T) {
 	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
 }
 
+func TestLibrarian_Store_pins(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, _ := routing.NewTestWithPeers(rng, 64)
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	documentSL, err := storage.NewBoundedDocumentSLD(storage.NewDocumentSLD(kvdb), 1)
+	assert.Nil(t, err)
+	l := &Librarian{
+		selfID:       peerID,
+		rt:           rt,
+		documentSL:   documentSL,
+		documentPins: documentSL,
+		subscribeTo:  &fixedTo{},
+		kc:           storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		kvc:          storage.NewHashKeyValueChecker(),
+		rqv:          &alwaysRequestVerifier{},
+		logger:       clogging.NewDevInfoLogger(),
+	}
+
+	// check stored documents are pinned, so aren't evicted despite being over budget
+	docs, keys := make([]*api.Document, 2), make([]cid.ID, 2)
+	for i := range docs {
+		docs[i], keys[i] = api.NewTestDocument(rng)
+		rq := &api.StoreRequest{
+			Metadata: newTestRequestMetadata(rng, l.selfID),
+			Key:      keys[i].Bytes(),
+			Value:    docs[i],
+		}
+		_, err = l.Store(nil, rq)
+		assert.Nil(t, err)
+	}
+	for i, key := range keys {
+		stored, err := l.documentSL.Load(key)
+		assert.Nil(t, err)
+		assert.Equal(t, docs[i], stored)
+	}
+}
+
+func TestLibrarian_Store_expired(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, _ := routing.NewTestWithPeers(rng, 64)
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	l := &Librarian{
+		selfID:     peerID,
+		rt:         rt,
+		documentSL: storage.NewDocumentSLD(kvdb),
+		kc:         storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		kvc:        storage.NewHashKeyValueChecker(),
+		rqv:        &alwaysRequestVerifier{},
+		logger:     clogging.NewDevInfoLogger(),
+	}
+	value, key := newExpiredTestDocument(rng)
+	rq := client.NewStoreRequest(ecid.NewPseudoRandom(rng), key, value)
+
+	// check expired document isn't stored
+	rp, err := l.Store(nil, rq)
+	assert.Nil(t, rp)
+	assert.Equal(t, api.ErrExpiredRPC, err)
+	stored, err := l.documentSL.Load(key)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestLibrarian_Store_rateLimited(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, _ := routing.NewTestWithPeers(rng, 64)
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	burst := uint(4)
+	storeLimiter, err := newStoreLimiter(1, burst)
+	assert.Nil(t, err)
+	storeLimiter.now = func() time.Time { return time.Unix(0, 0) } // no refills
+	l := &Librarian{
+		selfID:       peerID,
+		rt:           rt,
+		documentSL:   storage.NewDocumentSLD(kvdb),
+		subscribeTo:  &fixedTo{},
+		kc:           storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		kvc:          storage.NewHashKeyValueChecker(),
+		rqv:          &alwaysRequestVerifier{},
+		storeLimiter: storeLimiter,
+		logger:       clogging.NewDevInfoLogger(),
+	}
+	requester := ecid.NewPseudoRandom(rng)
+
+	// check requests up to the burst are stored
+	for c := uint(0); c < burst; c++ {
+		value, key := api.NewTestDocument(rng)
+		rp, err := l.Store(nil, client.NewStoreRequest(requester, key, value))
+		assert.Nil(t, err)
+		assert.NotNil(t, rp)
+	}
+
+	// check request beyond the burst is rejected and not stored
+	value, key := api.NewTestDocument(rng)
+	rp, err := l.Store(nil, client.NewStoreRequest(requester, key, value))
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+	assert.Nil(t, rp)
+	stored, err := l.documentSL.Load(key)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+
+	// check requests from other peers are still stored
+	rp, err = l.Store(nil, client.NewStoreRequest(ecid.NewPseudoRandom(rng), key, value))
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+}
+
+func TestLibrarian_Store_quotaExceeded(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, _ := routing.NewTestWithPeers(rng, 64)
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	documentSL := storage.NewDocumentSLD(kvdb)
+	l := &Librarian{
+		selfID:       peerID,
+		rt:           rt,
+		documentSL:   documentSL,
+		subscribeTo:  &fixedTo{},
+		kc:           storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		kvc:          storage.NewHashKeyValueChecker(),
+		rqv:          &alwaysRequestVerifier{},
+		storageQuota: NewStorageQuota(1024, &fixedSizer{size: 1024}, documentSL),
+		logger:       clogging.NewDevInfoLogger(),
+	}
+	value, key := api.NewTestDocument(rng)
+	rq := client.NewStoreRequest(ecid.NewPseudoRandom(rng), key, value)
+
+	// check new document isn't stored once quota is reached
+	rp, err := l.Store(nil, rq)
+	assert.Equal(t, ErrStorageQuotaExceeded, err)
+	assert.Nil(t, rp)
+	stored, err := l.documentSL.Load(key)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestLibrarian_Store_maxDocumentBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, _ := routing.NewTestWithPeers(rng, 64)
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	value1, key1 := api.NewTestDocument(rng)
+	value2, key2 := api.NewTestDocument(rng)
+	maxDocBytes := uint64(proto.Size(value1))
+	l := &Librarian{
+		selfID:      peerID,
+		rt:          rt,
+		documentSL:  storage.NewDocumentSLD(kvdb),
+		subscribeTo: &fixedTo{},
+		kc:          storage.NewExactLengthChecker(storage.EntriesKeyLength),
+		kvc:         storage.NewHashKeyValueChecker(),
+		rqv:         &alwaysRequestVerifier{},
+		maxDocBytes: maxDocBytes,
+		logger:      clogging.NewDevInfoLogger(),
+	}
+
+	// check document of exactly the max size is stored
+	rp, err := l.Store(nil, client.NewStoreRequest(ecid.NewPseudoRandom(rng), key1, value1))
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+
+	// check document just over the max size is rejected and not stored
+	l.maxDocBytes = uint64(proto.Size(value2)) - 1
+	rp, err = l.Store(nil, client.NewStoreRequest(ecid.NewPseudoRandom(rng), key2, value2))
+	assert.Equal(t, ErrDocumentTooLarge, err)
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	assert.Nil(t, rp)
+	stored, err := l.documentSL.Load(key2)
+	assert.Nil(t, err)
+	assert.Nil(t, stored)
+}
+
 func newTestRequestMetadata(rng *rand.Rand, peerID ecid.ID) *api.RequestMetadata {
 	return &api.RequestMetadata{
 		RequestId: cid.NewPseudoRandom(rng).Bytes(),
@@ -413,11 +687,16 @@ func TestLibrarian_Store_storeError(t *testing.T) {
 }
 
 type fixedSearcher struct {
-	result *search.Result
-	err    error
+	result     *search.Result
+	err        error
+	seedGroups [][]peer.Peer
+	params     *search.Parameters
+	request    *api.FindRequest
 }
 
 func (s *fixedSearcher) Search(search *search.Search, seeds []peer.Peer) error {
+	s.params = search.Params
+	s.request = search.Request
 	if s.err != nil {
 		return s.err
 	}
@@ -425,6 +704,11 @@ func (s *fixedSearcher) Search(search *search.Search, seeds []peer.Peer) error {
 	return nil
 }
 
+func (s *fixedSearcher) SearchMulti(search *search.Search, seedGroups [][]peer.Peer) error {
+	s.seedGroups = seedGroups
+	return s.Search(search, nil)
+}
+
 func TestLibrarian_Get_FoundValue(t *testing.T) {
 	rng := rand.New(rand.NewSource(int64(0)))
 	value, key := api.NewTestDocument(rng)
@@ -446,6 +730,69 @@ func TestLibrarian_Get_FoundValue(t *testing.T) {
 	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
 }
 
+func TestLibrarian_Get_existsOnly(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := api.NewTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+	searchParams := search.NewDefaultParameters()
+
+	// check found value is confirmed without returning it
+	foundValueResult := search.NewInitialResult(key, searchParams)
+	foundValueResult.Value = value
+	l := newGetLibrarian(rng, foundValueResult, nil)
+	rq := client.NewGetRequest(peerID, key)
+	rq.ExistsOnly = true
+	rp, err := l.Get(nil, rq)
+	assert.Nil(t, err)
+	assert.True(t, rp.Exists)
+	assert.Nil(t, rp.Value)
+	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+	assert.True(t, l.searcher.(*fixedSearcher).request.Presence)
+
+	// check found holder is confirmed
+	foundHolderResult := search.NewInitialResult(key, searchParams)
+	holder := peer.NewTestPeer(rng, 0)
+	foundHolderResult.Holders[holder.ID().String()] = holder
+	l = newGetLibrarian(rng, foundHolderResult, nil)
+	rq = client.NewGetRequest(peerID, key)
+	rq.ExistsOnly = true
+	rp, err = l.Get(nil, rq)
+	assert.Nil(t, err)
+	assert.True(t, rp.Exists)
+	assert.Nil(t, rp.Value)
+
+	// check missing value isn't confirmed
+	foundClosestPeersResult := search.NewInitialResult(key, searchParams)
+	dummyClosest := peer.NewTestPeers(rng, int(searchParams.NClosestResponses))
+	err = foundClosestPeersResult.Closest.SafePushMany(dummyClosest)
+	assert.Nil(t, err)
+	l = newGetLibrarian(rng, foundClosestPeersResult, nil)
+	rq = client.NewGetRequest(peerID, key)
+	rq.ExistsOnly = true
+	rp, err = l.Get(nil, rq)
+	assert.Nil(t, err)
+	assert.False(t, rp.Exists)
+	assert.Nil(t, rp.Value)
+}
+
+func TestLibrarian_Get_expired(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := newExpiredTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+
+	// create mock search result where an expired value has been found
+	searchParams := search.NewDefaultParameters()
+	foundValueResult := search.NewInitialResult(key, searchParams)
+	foundValueResult.Value = value
+
+	l := newGetLibrarian(rng, foundValueResult, nil)
+	rq := client.NewGetRequest(peerID, key)
+
+	rp, err := l.Get(nil, rq)
+	assert.Nil(t, rp)
+	assert.Equal(t, api.ErrExpiredRPC, err)
+}
+
 func TestLibrarian_Get_FoundClosestPeers(t *testing.T) {
 	rng := rand.New(rand.NewSource(int64(0)))
 	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
@@ -467,6 +814,66 @@ func TestLibrarian_Get_FoundClosestPeers(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, rp.Value)
 	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+	assert.Equal(t, 1, l.searchCache.Len())
+}
+
+func TestLibrarian_Get_searchCache(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+	searchParams := search.NewDefaultParameters()
+	foundClosestPeersResult := search.NewInitialResult(key, searchParams)
+	dummyClosest := peer.NewTestPeers(rng, int(searchParams.NClosestResponses))
+	err := foundClosestPeersResult.Closest.SafePushMany(dummyClosest)
+	assert.Nil(t, err)
+	l := newGetLibrarian(rng, foundClosestPeersResult, nil)
+	searcher := l.searcher.(*fixedSearcher)
+
+	// check first search is seeded from routing table
+	rtSeeds := l.rt.Peak(key, searchParams.Concurrency)
+	_, err = l.Get(nil, client.NewGetRequest(peerID, key))
+	assert.Nil(t, err)
+	assert.Equal(t, rtSeeds, searcher.seedGroups[0])
+
+	// check repeated search is also seeded from closest peers found by the first, in addition to
+	// the routing table groups
+	_, err = l.Get(nil, client.NewGetRequest(peerID, key))
+	assert.Nil(t, err)
+	nGroups := len(searcher.seedGroups)
+	assert.True(t, nGroups > 1)
+	assert.Equal(t, l.rt.Peak(key, searchParams.Concurrency), searcher.seedGroups[0])
+	assert.Equal(t, foundClosestPeersResult.Closest.Peers(), searcher.seedGroups[nGroups-1])
+}
+
+func TestLibrarian_Get_searchConcurrency(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+	searchParams := search.NewDefaultParameters()
+	foundClosestPeersResult := search.NewInitialResult(key, searchParams)
+	dummyClosest := peer.NewTestPeers(rng, int(searchParams.NClosestResponses))
+	err := foundClosestPeersResult.Closest.SafePushMany(dummyClosest)
+	assert.Nil(t, err)
+	l := newGetLibrarian(rng, foundClosestPeersResult, nil)
+	searcher := l.searcher.(*fixedSearcher)
+
+	// check zero search concurrency uses configured default
+	_, err = l.Get(nil, client.NewGetRequest(peerID, key))
+	assert.Nil(t, err)
+	assert.Equal(t, l.config.Search.Concurrency, searcher.params.Concurrency)
+
+	// check requested search concurrency is used
+	rq := client.NewGetRequest(peerID, key)
+	rq.SearchConcurrency = 8
+	_, err = l.Get(nil, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(8), searcher.params.Concurrency)
+	assert.Equal(t, search.DefaultConcurrency, l.config.Search.Concurrency)
+
+	// check too high search concurrency errors
+	rq = client.NewGetRequest(peerID, key)
+	rq.SearchConcurrency = api.MaxSearchConcurrency + 1
+	rp, err := l.Get(nil, rq)
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	assert.Nil(t, rp)
 }
 
 func TestLibrarian_Get_Errored(t *testing.T) {
@@ -533,9 +940,182 @@ func TestLibrarian_Get_checkRequestError(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestLibrarian_Locate_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+
+	// create mock search result where some of the closest peers hold the value
+	searchParams := search.NewDefaultParameters()
+	result := search.NewInitialResult(key, searchParams)
+	closest := peer.NewTestPeers(rng, int(searchParams.NClosestResponses))
+	err = result.Closest.SafePushMany(closest)
+	assert.Nil(t, err)
+	for _, p := range closest[:2] {
+		result.Holders[p.ID().String()] = p
+	}
+
+	l := newGetLibrarian(rng, result, nil)
+	l.documentSL = storage.NewDocumentSLD(kvdb)
+	l.apiSelf = api.FromAddress(l.selfID.ID(), "self", peer.NewTestPublicAddr(0))
+
+	// check response has just the peers holding the value
+	rq := client.NewLocateRequest(peerID, key)
+	rp, err := l.Locate(nil, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+	assert.Len(t, rp.Peers, 2)
+	holderIDs := map[string]bool{
+		closest[0].ID().String(): true,
+		closest[1].ID().String(): true,
+	}
+	for _, pa := range rp.Peers {
+		assert.True(t, holderIDs[cid.FromBytes(pa.PeerId).String()])
+	}
+
+	// check response also includes self when it holds the value
+	value, _ := api.NewTestDocument(rng)
+	err = l.documentSL.Store(key, value)
+	assert.Nil(t, err)
+	rq = client.NewLocateRequest(peerID, key)
+	rp, err = l.Locate(nil, rq)
+	assert.Nil(t, err)
+	assert.Len(t, rp.Peers, 3)
+	assert.Contains(t, rp.Peers, l.apiSelf)
+}
+
+func TestLibrarian_Locate_missing(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	kvdb, cleanup, err := db.NewTempDirRocksDB()
+	defer cleanup()
+	defer kvdb.Close()
+	assert.Nil(t, err)
+	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+
+	// create mock search result where none of the closest peers hold the value
+	searchParams := search.NewDefaultParameters()
+	result := search.NewInitialResult(key, searchParams)
+	closest := peer.NewTestPeers(rng, int(searchParams.NClosestResponses))
+	err = result.Closest.SafePushMany(closest)
+	assert.Nil(t, err)
+
+	l := newGetLibrarian(rng, result, nil)
+	l.documentSL = storage.NewDocumentSLD(kvdb)
+
+	// check key stored nowhere gives empty list of peers rather than an error
+	rq := client.NewLocateRequest(peerID, key)
+	rp, err := l.Locate(nil, rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+	assert.Empty(t, rp.Peers)
+}
+
+func TestLibrarian_Locate_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+
+	// check search error bubbles up
+	l := newGetLibrarian(rng, nil, errors.New("some unexpected search error"))
+	rp, err := l.Locate(nil, client.NewLocateRequest(peerID, key))
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+
+	// check errored search gives error
+	erroredResult := search.NewInitialResult(key, search.NewDefaultParameters())
+	erroredResult.FatalErr = errors.New("some fatal error")
+	l = newGetLibrarian(rng, erroredResult, nil)
+	rp, err = l.Locate(nil, client.NewLocateRequest(peerID, key))
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+
+	// check bad request gives error
+	rq := client.NewLocateRequest(peerID, key)
+	rq.Metadata.PubKey = []byte("corrupted pub key")
+	rp, err = l.Locate(nil, rq)
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+}
+
+func TestLibrarian_Info_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, nAdded := routing.NewTestWithPeers(rng, 64)
+	l := &Librarian{
+		selfID: peerID,
+		rt:     rt,
+		rqv:    &alwaysRequestVerifier{},
+		logger: clogging.NewDevInfoLogger(),
+	}
+	rq := client.NewInfoRequest(ecid.NewPseudoRandom(rng))
+
+	rp, err := l.Info(nil, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+	assert.Equal(t, peerID.ID().Bytes(), rp.PeerId)
+	assert.Equal(t, uint32(nAdded), rp.NumPeers)
+	assert.Equal(t, uint32(rt.NumBuckets()), rp.NumBuckets)
+	assert.Len(t, rp.BucketPeers, rt.NumBuckets())
+	nPeers := uint32(0)
+	for _, n := range rp.BucketPeers {
+		nPeers += n
+	}
+	assert.Equal(t, rp.NumPeers, nPeers)
+}
+
+func TestLibrarian_Info_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, peerID, _ := routing.NewTestWithPeers(rng, 8)
+	l := &Librarian{
+		selfID: peerID,
+		rt:     rt,
+		rqv:    &alwaysRequestVerifier{},
+		logger: clogging.NewDevInfoLogger(),
+	}
+
+	// check bad request gives error
+	rq := client.NewInfoRequest(ecid.NewPseudoRandom(rng))
+	rq.Metadata.PubKey = []byte("corrupted pub key")
+	rp, err := l.Info(nil, rq)
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+}
+
+func TestLibrarian_Get_timedOut(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	key, peerID := cid.NewPseudoRandom(rng), ecid.NewPseudoRandom(rng)
+
+	// create librarian whose searches time out before any peer responds
+	l := newGetLibrarian(rng, nil, nil)
+	l.searcher = search.NewSearcher(client.NewSigner(l.selfID.Key()), &blockingFindQuerier{}, nil)
+	l.config.Search.OverallTimeout = 10 * time.Millisecond
+	rq := client.NewGetRequest(peerID, key)
+
+	// check Get returns the partial (empty) result flagged as timed out
+	rp, err := l.Get(nil, rq)
+	assert.Nil(t, err)
+	assert.Nil(t, rp.Value)
+	assert.True(t, rp.TimedOut)
+	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+}
+
+type blockingFindQuerier struct{}
+
+func (q *blockingFindQuerier) Query(
+	ctx context.Context, pConn api.Connector, rq *api.FindRequest, opts ...grpc.CallOption,
+) (*api.FindResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
 func newGetLibrarian(rng *rand.Rand, searchResult *search.Result, searchErr error) *Librarian {
 	n := 8
 	rt, peerID, _ := routing.NewTestWithPeers(rng, n)
+	searchCache, err := search.NewCache(search.DefaultCacheSize, search.DefaultCacheTTL)
+	if err != nil {
+		panic(err)
+	}
 	return &Librarian{
 		selfID: peerID,
 		config: NewDefaultConfig(),
@@ -545,17 +1125,20 @@ func newGetLibrarian(rng *rand.Rand, searchResult *search.Result, searchErr erro
 			result: searchResult,
 			err:    searchErr,
 		},
-		rqv:    &alwaysRequestVerifier{},
-		logger: clogging.NewDevInfoLogger(),
+		searchCache: searchCache,
+		rqv:         &alwaysRequestVerifier{},
+		logger:      clogging.NewDevInfoLogger(),
 	}
 }
 
 type fixedStorer struct {
 	result *store.Result
 	err    error
+	params *store.Parameters
 }
 
 func (s *fixedStorer) Store(store *store.Store, seeds []peer.Peer) error {
+	s.params = store.Params
 	if s.err != nil {
 		return s.err
 	}
@@ -582,10 +1165,72 @@ func TestLibrarian_Put_Stored(t *testing.T) {
 	rp, err := l.Put(nil, rq)
 	assert.Nil(t, err)
 	assert.Equal(t, uint32(nReplicas), rp.NReplicas)
+	assert.Len(t, rp.ReplicaPeerIds, int(nReplicas))
+	for i, p := range addedResult.Responded {
+		assert.Equal(t, p.ID().Bytes(), rp.ReplicaPeerIds[i])
+	}
 	assert.Equal(t, api.PutOperation_STORED, rp.Operation)
 	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
 }
 
+func TestLibrarian_Put_expired(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := newExpiredTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+	l := newPutLibrarian(rng, nil, nil)
+	rq := client.NewPutRequest(peerID, key, value)
+
+	// check expired document isn't stored
+	rp, err := l.Put(nil, rq)
+	assert.Nil(t, rp)
+	assert.Equal(t, api.ErrExpiredRPC, err)
+}
+
+func TestLibrarian_Put_nReplicas(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := api.NewTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+	searchParams := search.NewDefaultParameters()
+	addedResult := store.NewInitialResult(search.NewInitialResult(key, searchParams))
+	addedResult.Responded = peer.NewTestPeers(rng, 2)
+	l := newPutLibrarian(rng, addedResult, nil)
+
+	// check requested number of replicas overrides default
+	rq := client.NewPutRequest(peerID, key, value)
+	rq.NReplicas = 2
+	rp, err := l.Put(nil, rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+	assert.Equal(t, uint(2), l.storer.(*fixedStorer).params.NReplicas)
+	assert.Equal(t, store.DefaultNReplicas, l.config.Store.NReplicas)
+
+	// check more replicas than reachable peers triggers error
+	rq = client.NewPutRequest(peerID, key, value)
+	rq.NReplicas = uint32(l.rt.NumPeers() + 1)
+	rp, err = l.Put(nil, rq)
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	assert.Nil(t, rp)
+
+	// check more replicas than configured max triggers error
+	l.config.Store.MaxNReplicas = 2
+	rq = client.NewPutRequest(peerID, key, value)
+	rq.NReplicas = 3
+	rp, err = l.Put(nil, rq)
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	assert.Nil(t, rp)
+	l.config.Store.MaxNReplicas = store.DefaultMaxNReplicas
+
+	// check adaptive number of replicas used when none requested
+	l.config.Store.TargetReplicaFraction = 0.25
+	l.config.Store.MinNReplicas = 1
+	rq = client.NewPutRequest(peerID, key, value)
+	rp, err = l.Put(nil, rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+	expected := uint(float32(l.rt.NumPeers())*0.25 + 0.5)
+	assert.Equal(t, expected, l.storer.(*fixedStorer).params.NReplicas)
+}
+
 func TestLibrarian_Put_Exists(t *testing.T) {
 	rng := rand.New(rand.NewSource(int64(0)))
 	value, key := api.NewTestDocument(rng)
@@ -628,6 +1273,64 @@ func TestLibrarian_Put_Errored(t *testing.T) {
 	assert.Nil(t, rp)
 }
 
+func TestLibrarian_Put_partial(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := api.NewTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+
+	// create mock store result that errored after storing some replicas
+	searchParams := search.NewDefaultParameters()
+	erroredResult := store.NewInitialResult(search.NewInitialResult(key, searchParams))
+	erroredResult.Responded = peer.NewTestPeers(rng, 2)
+	for c := uint(0); c < store.DefaultNMaxErrors; c++ {
+		erroredResult.Errors = append(erroredResult.Errors, errors.New("some store error"))
+	}
+	l := newPutLibrarian(rng, erroredResult, nil)
+
+	// check partial replication is an error without a minimum
+	rq := client.NewPutRequest(peerID, key, value)
+	rp, err := l.Put(nil, rq)
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+
+	// check partial replication is an error when below minimum
+	rq.MinNReplicas = 3
+	rp, err = l.Put(nil, rq)
+	assert.NotNil(t, err)
+	assert.Nil(t, rp)
+
+	// check partial replication is stored when at least minimum
+	rq.MinNReplicas = 2
+	rp, err = l.Put(nil, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, api.PutOperation_STORED, rp.Operation)
+	assert.Equal(t, uint32(2), rp.NReplicas)
+	assert.Len(t, rp.ReplicaPeerIds, 2)
+	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+}
+
+func TestLibrarian_Put_timedOut(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := api.NewTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+
+	// create librarian whose stores time out while searching for the closest peers
+	l := newPutLibrarian(rng, nil, nil)
+	signer := client.NewSigner(l.selfID.Key())
+	searcher := search.NewSearcher(signer, &blockingFindQuerier{}, nil)
+	l.storer = store.NewStorer(signer, searcher, client.NewStoreQuerier())
+	l.config.Search.OverallTimeout = 10 * time.Millisecond
+	rq := client.NewPutRequest(peerID, key, value)
+
+	// check Put returns the partial result flagged as timed out
+	rp, err := l.Put(nil, rq)
+	assert.Nil(t, err)
+	assert.Equal(t, api.PutOperation_STORED, rp.Operation)
+	assert.Equal(t, uint32(0), rp.NReplicas)
+	assert.True(t, rp.TimedOut)
+	assert.Equal(t, rq.Metadata.RequestId, rp.Metadata.RequestId)
+}
+
 func TestLibrarian_Put_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(int64(0)))
 	value, key := api.NewTestDocument(rng)
@@ -657,6 +1360,83 @@ func TestLibrarian_Put_checkRequestError(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestLibrarian_PutStream_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := api.NewTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+	searchParams := search.NewDefaultParameters()
+	addedResult := store.NewInitialResult(search.NewInitialResult(key, searchParams))
+	addedResult.Responded = peer.NewTestPeers(rng, 3)
+	l := newPutStreamLibrarian(rng, addedResult)
+	rq := client.NewPutRequest(peerID, key, value)
+	chunks, err := api.NewPutChunks(rq, 64)
+	assert.Nil(t, err)
+	stream := &fixedPutStreamServer{chunks: chunks}
+
+	err = l.PutStream(stream)
+	assert.Nil(t, err)
+	assert.Equal(t, api.PutOperation_STORED, stream.rp.Operation)
+	assert.Equal(t, uint32(3), stream.rp.NReplicas)
+	assert.Equal(t, rq.Metadata.RequestId, stream.rp.Metadata.RequestId)
+}
+
+func TestLibrarian_PutStream_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(int64(0)))
+	value, key := api.NewTestDocument(rng)
+	peerID := ecid.NewPseudoRandom(rng)
+	searchParams := search.NewDefaultParameters()
+	addedResult := store.NewInitialResult(search.NewInitialResult(key, searchParams))
+	addedResult.Responded = peer.NewTestPeers(rng, 3)
+	newChunks := func() []*api.PutChunk {
+		chunks, err := api.NewPutChunks(client.NewPutRequest(peerID, key, value), 64)
+		assert.Nil(t, err)
+		return chunks
+	}
+
+	// check first Recv error bubbles up
+	l := newPutStreamLibrarian(rng, addedResult)
+	stream := &fixedPutStreamServer{recvErr: errors.New("some Recv error")}
+	assert.NotNil(t, l.PutStream(stream))
+
+	// check missing request gives error
+	stream = &fixedPutStreamServer{chunks: newChunks()[1:]}
+	assert.Equal(t, errMissingPutStreamRequest, l.PutStream(stream))
+
+	// check bad request gives error
+	chunks := newChunks()
+	chunks[0].Request.Metadata.PubKey = []byte("corrupted pub key")
+	stream = &fixedPutStreamServer{chunks: chunks}
+	assert.NotNil(t, l.PutStream(stream))
+
+	// check replayed request gives error
+	chunks = newChunks()
+	assert.Nil(t, l.PutStream(&fixedPutStreamServer{chunks: chunks}))
+	assert.Equal(t, errReplayedRequest, l.PutStream(&fixedPutStreamServer{chunks: chunks}))
+
+	// check subsequent Recv error bubbles up
+	stream = &fixedPutStreamServer{chunks: newChunks(), recvErr: errors.New("some Recv error"),
+		recvErrAfter: 2}
+	assert.NotNil(t, l.PutStream(stream))
+
+	// check value larger than max document size gives error
+	l.maxDocBytes = 64
+	stream = &fixedPutStreamServer{chunks: newChunks()}
+	assert.Equal(t, ErrDocumentTooLarge, l.PutStream(stream))
+	l.maxDocBytes = 0
+
+	// check value not matching key gives error
+	chunks = newChunks()
+	chunks[1].ValueChunk = api.RandBytes(rng, len(chunks[1].ValueChunk))
+	stream = &fixedPutStreamServer{chunks: chunks}
+	assert.NotNil(t, l.PutStream(stream))
+
+	// check Put error bubbles up
+	l.storer = &fixedStorer{err: errors.New("some store error")}
+	stream = &fixedPutStreamServer{chunks: newChunks()}
+	assert.NotNil(t, l.PutStream(stream))
+	assert.Nil(t, stream.rp)
+}
+
 func TestLibrarian_Subscribe_ok(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	nPubs := 64
@@ -750,7 +1530,7 @@ func TestLibrarian_Subscribe_err(t *testing.T) {
 	// check author filter error bubbles up
 	sub2, err := subscribe.NewFPSubscription(1.0, rng)
 	assert.Nil(t, err)
-	sub2.AuthorPublicKeys.Encoded = nil // will trigger error
+	sub2.AuthorPublicKeys.Bits = nil // will trigger error
 	rq2 := client.NewSubscribeRequest(ecid.NewPseudoRandom(rng), sub2)
 	l2 := &Librarian{rqv: &alwaysRequestVerifier{}}
 	err = l2.Subscribe(rq2, from)
@@ -759,7 +1539,7 @@ func TestLibrarian_Subscribe_err(t *testing.T) {
 	// check reader filter error bubbles up
 	sub3, err := subscribe.NewFPSubscription(1.0, rng)
 	assert.Nil(t, err)
-	sub3.ReaderPublicKeys.Encoded = nil // will trigger error
+	sub3.ReaderPublicKeys.Bits = nil // will trigger error
 	rq3 := client.NewSubscribeRequest(ecid.NewPseudoRandom(rng), sub3)
 	l3 := &Librarian{rqv: &alwaysRequestVerifier{}}
 	err = l3.Subscribe(rq3, from)
@@ -826,6 +1606,15 @@ func (f *fixedFrom) New() (chan *subscribe.KeyedPub, chan struct{}, error) {
 	return f.new, f.done, f.err
 }
 
+func (f *fixedFrom) NewWithDelivery(
+	params *subscribe.DeliveryParameters,
+) (*subscribe.Delivery, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &subscribe.Delivery{Pubs: f.new, Done: f.done}, nil
+}
+
 func (f *fixedFrom) Fanout() {}
 
 type fixedTo struct {
@@ -896,3 +1685,69 @@ func newPutLibrarian(rng *rand.Rand, storeResult *store.Result, searchErr error)
 		logger: clogging.NewDevInfoLogger(),
 	}
 }
+
+func newPutStreamLibrarian(rng *rand.Rand, storeResult *store.Result) *Librarian {
+	l := newPutLibrarian(rng, storeResult, nil)
+	seen, err := client.NewReplayCache(DefaultRequestReplayCacheSize, DefaultRequestReplayWindow)
+	if err != nil {
+		panic(err)
+	}
+	l.rqv = &replayRequestVerifier{seen: seen}
+	return l
+}
+
+// replayRequestVerifier accepts each request ID once, as the real RequestVerifier does for
+// requests with valid signatures.
+type replayRequestVerifier struct {
+	seen *client.ReplayCache
+}
+
+func (rv *replayRequestVerifier) Verify(
+	ctx context.Context, msg proto.Message, meta *api.RequestMetadata,
+) error {
+	if err := rv.seen.Add(meta.RequestId); err != nil {
+		return errReplayedRequest
+	}
+	return nil
+}
+
+type fixedPutStreamServer struct {
+	grpc.ServerStream
+	chunks       []*api.PutChunk
+	recvErr      error
+	recvErrAfter int
+	nRecvs       int
+	rp           *api.PutResponse
+}
+
+func (f *fixedPutStreamServer) Recv() (*api.PutChunk, error) {
+	defer func() { f.nRecvs++ }()
+	if f.recvErr != nil && f.nRecvs >= f.recvErrAfter {
+		return nil, f.recvErr
+	}
+	if f.nRecvs >= len(f.chunks) {
+		return nil, io.EOF
+	}
+	return f.chunks[f.nRecvs], nil
+}
+
+func (f *fixedPutStreamServer) SendAndClose(rp *api.PutResponse) error {
+	f.rp = rp
+	return nil
+}
+
+func (f *fixedPutStreamServer) Context() context.Context {
+	return context.Background()
+}
+
+// newExpiredTestDocument creates a test entry document that expired long ago.
+func newExpiredTestDocument(rng *rand.Rand) (*api.Document, cid.ID) {
+	value, _ := api.NewTestDocument(rng)
+	entry := value.Contents.(*api.Document_Entry).Entry
+	entry.ExpiryTime = entry.CreatedTime + 1
+	key, err := api.GetKey(value)
+	if err != nil {
+		panic(err)
+	}
+	return value, key
+}
@@ -2,15 +2,20 @@ package server
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"errors"
 
 	"github.com/drausin/libri/libri/common/ecid"
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/server/peer"
 	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/golang/protobuf/proto"
 	"go.uber.org/zap"
+	"golang.org/x/net/context"
 )
 
 // logger keys
@@ -23,6 +28,17 @@ const (
 
 	// NumBuckets is a number of routing table buckets.
 	NumBuckets = "numBuckets"
+
+	// NumStalePeers is a number of peers that failed a liveness recheck.
+	NumStalePeers = "numStalePeers"
+)
+
+const (
+	// peerRecheckTimeout is the timeout for pinging a loaded peer to check that it's still live.
+	peerRecheckTimeout = 5 * time.Second
+
+	// peerRecheckConcurrency is the maximum number of loaded peers pinged at once.
+	peerRecheckConcurrency = 16
 )
 
 var (
@@ -66,8 +82,8 @@ func savePeerID(ns storage.NamespaceStorer, peerID ecid.ID) error {
 }
 
 func loadOrCreateRoutingTable(logger *zap.Logger, nl storage.NamespaceLoader, selfID cid.ID,
-	params *routing.Parameters) (routing.Table, error) {
-	rt, err := routing.Load(nl, params)
+	params *routing.Parameters, f peer.Fromer) (routing.Table, error) {
+	rt, err := routing.Load(nl, params, f)
 	if err != nil {
 		logger.Error("error loading routing table", zap.Error(err))
 		return nil, err
@@ -89,5 +105,58 @@ func loadOrCreateRoutingTable(logger *zap.Logger, nl storage.NamespaceLoader, se
 	}
 
 	defer logger.Info("created new routing table")
-	return routing.NewEmpty(selfID, params), nil
+	return routing.NewTable(selfID, params)
+}
+
+// recheckPeers removes peers (e.g., loaded from a previous run) from the routing table that fail
+// a liveness recheck via the ping function, returning the number removed.
+func recheckPeers(logger *zap.Logger, rt routing.Table, ping func(p peer.Peer) error) int {
+	peers := rt.Peers()
+	stale := make(chan peer.Peer, len(peers))
+	sem := make(chan struct{}, peerRecheckConcurrency)
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p peer.Peer) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := ping(p); err != nil {
+				logger.Debug("dropping stale peer",
+					zap.Stringer(LoggerPeerID, p.ID()),
+					zap.Error(err),
+				)
+				stale <- p
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(stale)
+
+	nStale := 0
+	for p := range stale {
+		if rt.Remove(p.ID()) {
+			nStale++
+		}
+		if err := p.Connector().Disconnect(); err != nil {
+			logger.Debug("error disconnecting from stale peer", zap.Error(err))
+		}
+	}
+	logger.Info("rechecked routing table peers",
+		zap.Int(NumPeers, rt.NumPeers()),
+		zap.Int(NumStalePeers, nStale),
+	)
+	return nStale
+}
+
+// pingPeer pings a peer, returning an error if it doesn't respond within the recheck timeout.
+func pingPeer(p peer.Peer) error {
+	lc, err := p.Connector().Connect()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), peerRecheckTimeout)
+	defer cancel()
+	_, err = lc.Ping(ctx, &api.PingRequest{})
+	return err
 }
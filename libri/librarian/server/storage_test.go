@@ -2,6 +2,7 @@ package server
 
 import (
 	"math/rand"
+	"sync"
 	"testing"
 
 	"errors"
@@ -10,6 +11,7 @@ import (
 	"github.com/drausin/libri/libri/common/id"
 	clogging "github.com/drausin/libri/libri/common/logging"
 	"github.com/drausin/libri/libri/common/storage"
+	"github.com/drausin/libri/libri/librarian/server/peer"
 	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
@@ -68,14 +70,14 @@ func TestLoadOrCreateRoutingTable_ok(t *testing.T) {
 		loadBytes: bytes,
 	}
 	rt1, err := loadOrCreateRoutingTable(clogging.NewDevInfoLogger(), fullLoader, selfID1,
-		routing.NewDefaultParameters())
+		routing.NewDefaultParameters(), peer.NewFromer())
 	assert.Equal(t, selfID1, rt1.SelfID())
 	assert.Nil(t, err)
 
 	// create new RT
 	selfID2 := id.NewPseudoRandom(rng)
 	rt2, err := loadOrCreateRoutingTable(clogging.NewDevInfoLogger(), &fixedStorerLoader{}, selfID2,
-		routing.NewDefaultParameters())
+		routing.NewDefaultParameters(), peer.NewFromer())
 	assert.Equal(t, selfID2, rt2.SelfID())
 	assert.Nil(t, err)
 }
@@ -89,7 +91,7 @@ func TestLoadOrCreateRoutingTable_loadErr(t *testing.T) {
 	}
 
 	rt1, err := loadOrCreateRoutingTable(clogging.NewDevInfoLogger(), errLoader, selfID,
-		routing.NewDefaultParameters())
+		routing.NewDefaultParameters(), peer.NewFromer())
 	assert.Nil(t, rt1)
 	assert.NotNil(t, err)
 }
@@ -111,11 +113,47 @@ func TestLoadOrCreateRoutingTable_selfIDErr(t *testing.T) {
 	// error with conflicting/different selfID
 	selfID2 := id.NewPseudoRandom(rng)
 	rt1, err := loadOrCreateRoutingTable(clogging.NewDevInfoLogger(), fullLoader, selfID2,
-		routing.NewDefaultParameters())
+		routing.NewDefaultParameters(), peer.NewFromer())
 	assert.Nil(t, rt1)
 	assert.NotNil(t, err)
 }
 
+func TestRecheckPeers(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	rt, _, nAdded := routing.NewTestWithPeers(rng, 64)
+
+	// make every other peer stale
+	stale, nExpectedStale := make(map[string]bool), 0
+	for i, p := range rt.Peers() {
+		stale[p.ID().String()] = i%2 == 0
+		if i%2 == 0 {
+			nExpectedStale++
+		}
+	}
+	var mu sync.Mutex
+	ping := func(p peer.Peer) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stale[p.ID().String()] {
+			return errors.New("some ping error")
+		}
+		return nil
+	}
+
+	// check only stale peers are removed
+	nStale := recheckPeers(clogging.NewDevInfoLogger(), rt, ping)
+	assert.Equal(t, nExpectedStale, nStale)
+	assert.Equal(t, nAdded-nStale, rt.NumPeers())
+	for _, p := range rt.Peers() {
+		assert.False(t, stale[p.ID().String()])
+	}
+
+	// check nothing removed when all peers are live
+	nStale = recheckPeers(clogging.NewDevInfoLogger(), rt, ping)
+	assert.Zero(t, nStale)
+	assert.Equal(t, nAdded-nExpectedStale, rt.NumPeers())
+}
+
 type fixedStorerLoader struct {
 	loadBytes []byte
 	loadErr   error
@@ -129,3 +167,13 @@ func (l *fixedStorerLoader) Load(key []byte) ([]byte, error) {
 func (l *fixedStorerLoader) Store(key []byte, value []byte) error {
 	return l.storeErr
 }
+
+func (l *fixedStorerLoader) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return nil
+}
+
+func (l *fixedStorerLoader) IterateRange(
+	start []byte, end []byte, fn func(key, value []byte) error,
+) error {
+	return nil
+}
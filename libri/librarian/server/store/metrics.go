@@ -0,0 +1,60 @@
+package store
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus metrics of a storer, so each librarian in a process can record
+// its stores separately.
+type Metrics struct {
+	storeDuration      prometheus.Histogram
+	storeQueryDuration *prometheus.HistogramVec
+}
+
+// NewMetrics creates a new *Metrics instance.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		storeDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "libri",
+			Subsystem: "store",
+			Name:      "duration_seconds",
+			Help:      "Duration of stores of a value, including the initial search.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
+		storeQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "libri",
+			Subsystem: "store",
+			Name:      "store_query_duration_seconds",
+			Help:      "Duration of Store queries to peers during stores.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+		}, []string{"outcome"}),
+	}
+}
+
+// Register registers the store metrics with the given registerer.
+func (m *Metrics) Register(r prometheus.Registerer) {
+	r.MustRegister(m.storeDuration, m.storeQueryDuration)
+}
+
+// observeStore records the duration of a store. It is a no-op for nil metrics.
+func (m *Metrics) observeStore(start time.Time) {
+	if m == nil {
+		return
+	}
+	m.storeDuration.Observe(time.Since(start).Seconds())
+}
+
+// observeStoreQuery records the duration and outcome of a Store query. It is a no-op for nil
+// metrics.
+func (m *Metrics) observeStoreQuery(start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.storeQueryDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"math/big"
+	"net"
+	"sort"
+
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/search"
+)
+
+// Placement is how the peers to store replicas with are chosen from those the store's search
+// finds closest to the key.
+type Placement int
+
+const (
+	// ClosestPlacement stores replicas with the peers closest to the key.
+	ClosestPlacement Placement = iota
+
+	// SubnetPlacement spreads replicas across peers in different subnets, storing with the
+	// closest peer in each subnet before the second closest in any, so a single rack or network
+	// failure is less likely to lose every replica. It trades closeness to the key for this
+	// independence, since replicas may be stored with peers farther than the closest NReplicas.
+	SubnetPlacement
+)
+
+const (
+	// ipv4SubnetPrefixLen is the number of leading bits of an IPv4 address defining its subnet.
+	ipv4SubnetPrefixLen = 24
+
+	// ipv6SubnetPrefixLen is the number of leading bits of an IPv6 address defining its subnet.
+	ipv6SubnetPrefixLen = 48
+
+	// diverseSearchFactor is how many times NReplicas closest peers a store searches for when
+	// spreading replicas, so there are farther peers in other groups to choose from.
+	diverseSearchFactor = 2
+)
+
+// String returns the name of the placement.
+func (p Placement) String() string {
+	switch p {
+	case ClosestPlacement:
+		return "closest"
+	case SubnetPlacement:
+		return "subnet"
+	default:
+		return "unknown"
+	}
+}
+
+// groupFunc returns the function giving the group of a peer that replicas are spread across, or
+// nil if the placement doesn't spread replicas.
+func (p Placement) groupFunc() func(peer.Peer) string {
+	switch p {
+	case SubnetPlacement:
+		return subnet
+	default:
+		return nil
+	}
+}
+
+// subnet returns the address prefix of the peer's subnet.
+func subnet(p peer.Peer) string {
+	ip := p.Connector().Address().IP
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(ipv4SubnetPrefixLen, 8*net.IPv4len)).String()
+	}
+	return ip.Mask(net.CIDRMask(ipv6SubnetPrefixLen, 8*net.IPv6len)).String()
+}
+
+// place returns the closest peers from a search in the order replicas should be stored with
+// them according to the placement.
+func place(closest search.FarthestPeers, placement Placement) []peer.Peer {
+	group := placement.groupFunc()
+	if group == nil {
+		return closest.Peers()
+	}
+	byDist := &peersByDistance{
+		peers:     append([]peer.Peer{}, closest.Peers()...),
+		distances: make([]*big.Int, closest.Len()),
+	}
+	for i, p := range byDist.peers {
+		byDist.distances[i] = closest.Distance(p)
+	}
+	sort.Sort(byDist)
+	return diversify(byDist.peers, group)
+}
+
+// diversify orders peers, already sorted from closest to farthest, round-robin across their
+// groups: the closest peer in each group (with groups ordered by their closest peer), then the
+// second closest in each group, and so on.
+func diversify(peers []peer.Peer, group func(peer.Peer) string) []peer.Peer {
+	groups := make(map[string][]peer.Peer)
+	groupOrder := make([]string, 0)
+	for _, p := range peers {
+		g := group(p)
+		if _, in := groups[g]; !in {
+			groupOrder = append(groupOrder, g)
+		}
+		groups[g] = append(groups[g], p)
+	}
+	diverse := make([]peer.Peer, 0, len(peers))
+	for i := 0; len(diverse) < len(peers); i++ {
+		for _, g := range groupOrder {
+			if i < len(groups[g]) {
+				diverse = append(diverse, groups[g][i])
+			}
+		}
+	}
+	return diverse
+}
+
+// peersByDistance sorts peers from closest to farthest.
+type peersByDistance struct {
+	peers     []peer.Peer
+	distances []*big.Int
+}
+
+func (pd *peersByDistance) Len() int {
+	return len(pd.peers)
+}
+
+func (pd *peersByDistance) Less(i, j int) bool {
+	return pd.distances[i].Cmp(pd.distances[j]) < 0
+}
+
+func (pd *peersByDistance) Swap(i, j int) {
+	pd.peers[i], pd.peers[j] = pd.peers[j], pd.peers[i]
+	pd.distances[i], pd.distances[j] = pd.distances[j], pd.distances[i]
+}
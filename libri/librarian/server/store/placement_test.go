@@ -0,0 +1,126 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/drausin/libri/libri/common/ecid"
+	cid "github.com/drausin/libri/libri/common/id"
+	"github.com/drausin/libri/libri/librarian/api"
+	"github.com/drausin/libri/libri/librarian/server/peer"
+	ssearch "github.com/drausin/libri/libri/librarian/server/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlacement_String(t *testing.T) {
+	assert.Equal(t, "closest", ClosestPlacement.String())
+	assert.Equal(t, "subnet", SubnetPlacement.String())
+	assert.Equal(t, "unknown", Placement(-1).String())
+}
+
+func TestDiversify(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	racks := []string{"a", "a", "a", "b", "b", "c", "a", "c", "b"}
+	peers := peer.NewTestPeers(rng, len(racks))
+	rackOf := make(map[string]string)
+	for i, p := range peers {
+		rackOf[p.ID().String()] = racks[i]
+	}
+	rack := func(p peer.Peer) string { return rackOf[p.ID().String()] }
+
+	// check closest peer in each rack comes before second closest in any, and so on
+	expected := []peer.Peer{
+		peers[0], peers[3], peers[5], // closest in racks a, b, c
+		peers[1], peers[4], peers[7], // second closest in racks a, b, c
+		peers[2], peers[8], // third closest in racks a, b
+		peers[6], // fourth closest in rack a
+	}
+	assert.Equal(t, expected, diversify(peers, rack))
+
+	// check single rack keeps order
+	oneRack := func(p peer.Peer) string { return "a" }
+	assert.Equal(t, peers, diversify(peers, oneRack))
+
+	assert.Empty(t, diversify([]peer.Peer{}, rack))
+}
+
+func TestSubnet(t *testing.T) {
+	cases := []struct {
+		addr     string
+		expected string
+	}{
+		{"10.0.1.2:20100", "10.0.1.0"},
+		{"10.0.1.3:20101", "10.0.1.0"},
+		{"10.0.2.2:20100", "10.0.2.0"},
+		{"[2001:db8:1:2::1]:20100", "2001:db8:1::"},
+		{"[2001:db8:1:3::1]:20100", "2001:db8:1::"},
+		{"[2001:db8:2:2::1]:20100", "2001:db8:2::"},
+	}
+	rng := rand.New(rand.NewSource(0))
+	for i, c := range cases {
+		p := newRackPeer(rng, c.addr)
+		assert.Equal(t, c.expected, subnet(p), "case %d", i)
+	}
+}
+
+func TestPlace(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	key := cid.NewPseudoRandom(rng)
+	nRacks, nPeersPerRack := 4, 4
+	sr := ssearch.NewInitialResult(key, &ssearch.Parameters{
+		NClosestResponses: uint(nRacks * nPeersPerRack),
+	})
+	for rack := 0; rack < nRacks; rack++ {
+		for i := 0; i < nPeersPerRack; i++ {
+			addr := fmt.Sprintf("10.0.%d.%d:20100", rack, i+1)
+			assert.Nil(t, sr.Closest.SafePush(newRackPeer(rng, addr)))
+		}
+	}
+
+	// check closest placement leaves closest peers as they are
+	assert.Equal(t, sr.Closest.Peers(), place(sr.Closest, ClosestPlacement))
+
+	// check subnet placement visits every rack before visiting any twice, each time with the
+	// closest peer remaining in the rack
+	placed := place(sr.Closest, SubnetPlacement)
+	assert.Equal(t, nRacks*nPeersPerRack, len(placed))
+	seen := make(map[string]peer.Peer)
+	for i, p := range placed {
+		rack := subnet(p)
+		prev, in := seen[rack]
+		if i < nRacks {
+			assert.False(t, in, "peer %d", i)
+		} else {
+			assert.True(t, in, "peer %d", i)
+			assert.True(t, sr.Closest.Distance(prev).Cmp(sr.Closest.Distance(p)) < 0,
+				"peer %d", i)
+		}
+		seen[rack] = p
+	}
+	assert.Equal(t, nRacks, len(seen))
+}
+
+func TestNewStore_placement(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	value, key := api.NewTestDocument(rng)
+	params := &Parameters{NReplicas: 3, NMaxErrors: 2}
+
+	store := NewStore(peerID, key, value, &ssearch.Parameters{}, params)
+	assert.Equal(t, uint(5), store.Search.Params.NClosestResponses)
+
+	// check spreading replicas searches for more peers to choose from
+	params.Placement = SubnetPlacement
+	store = NewStore(peerID, key, value, &ssearch.Parameters{}, params)
+	assert.Equal(t, uint(8), store.Search.Params.NClosestResponses)
+}
+
+func newRackPeer(rng *rand.Rand, addr string) peer.Peer {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	return peer.New(cid.NewPseudoRandom(rng), addr, api.NewConnector(tcpAddr))
+}
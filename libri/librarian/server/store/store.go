@@ -1,6 +1,7 @@
 package store
 
 import (
+	"math"
 	"sync"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
 	"github.com/drausin/libri/libri/librarian/server/search"
 	"github.com/drausin/libri/libri/common/id"
 )
@@ -25,6 +27,23 @@ var (
 
 	// DefaultQueryTimeout is the timeout for each query to a peer.
 	DefaultQueryTimeout = 5 * time.Second
+
+	// DefaultMinNReplicas is the minimum number of replicas when adapting to the network size.
+	DefaultMinNReplicas = uint(2)
+
+	// DefaultMaxNReplicas is the maximum number of replicas when adapting to the network size.
+	DefaultMaxNReplicas = uint(16)
+
+	// DefaultOverallTimeout is the default timeout for an entire store, including its search, where
+	// zero means no timeout.
+	DefaultOverallTimeout = time.Duration(0)
+
+	// DefaultTargetReplicaFraction is the default fraction of routing table peers to store
+	// replicas with, where zero means using the fixed NReplicas instead.
+	DefaultTargetReplicaFraction = float32(0)
+
+	// DefaultPlacement is the default replica placement, which stores with the closest peers.
+	DefaultPlacement = ClosestPlacement
 )
 
 // Parameters defines the parameters of the store.
@@ -32,6 +51,17 @@ type Parameters struct {
 	// NReplicas is the number of replicas to store
 	NReplicas uint
 
+	// MinNReplicas is the minimum number of replicas AdaptiveNReplicas gives.
+	MinNReplicas uint
+
+	// MaxNReplicas is the maximum number of replicas AdaptiveNReplicas gives and a Put request
+	// may ask for.
+	MaxNReplicas uint
+
+	// TargetReplicaFraction, if non-zero, is the fraction of routing table peers to store
+	// replicas with, used instead of NReplicas.
+	TargetReplicaFraction float32
+
 	// maximum number of errors tolerated when querying peers during the store
 	NMaxErrors uint
 
@@ -40,16 +70,55 @@ type Parameters struct {
 
 	// timeout for queries to individual peers
 	Timeout time.Duration
+
+	// timeout for the entire store, including its search, after which it ends with its partial
+	// result; zero means no timeout
+	OverallTimeout time.Duration
+
+	// Placement is how the peers to store replicas with are chosen from the closest peers
+	// found by the search.
+	Placement Placement
 }
 
 // NewDefaultParameters creates an instance with default parameters.
 func NewDefaultParameters() *Parameters {
 	return &Parameters{
-		NReplicas:   DefaultNReplicas,
-		NMaxErrors:  DefaultNMaxErrors,
-		Concurrency: DefaultConcurrency,
-		Timeout:     DefaultQueryTimeout,
+		NReplicas:             DefaultNReplicas,
+		MinNReplicas:          DefaultMinNReplicas,
+		MaxNReplicas:          DefaultMaxNReplicas,
+		TargetReplicaFraction: DefaultTargetReplicaFraction,
+		NMaxErrors:            DefaultNMaxErrors,
+		Concurrency:           DefaultConcurrency,
+		Timeout:               DefaultQueryTimeout,
+		OverallTimeout:        DefaultOverallTimeout,
+		Placement:             DefaultPlacement,
+	}
+}
+
+// AdaptiveNReplicas returns the number of replicas to store given the current population of the
+// routing table. If params.TargetReplicaFraction is zero, it just returns params.NReplicas.
+// Otherwise, it scales that fraction of routing table peers to between params.MinNReplicas and
+// params.MaxNReplicas, though never more than the number of peers in the table (or less than
+// one), so small networks aren't over-replicated and large networks aren't under-replicated.
+func AdaptiveNReplicas(rt routing.Table, params *Parameters) uint {
+	if params.TargetReplicaFraction <= 0 {
+		return params.NReplicas
 	}
+	nPeers := uint(rt.NumPeers())
+	nReplicas := uint(math.Floor(float64(params.TargetReplicaFraction)*float64(nPeers) + 0.5))
+	if nReplicas < params.MinNReplicas {
+		nReplicas = params.MinNReplicas
+	}
+	if nReplicas > params.MaxNReplicas {
+		nReplicas = params.MaxNReplicas
+	}
+	if nReplicas > nPeers {
+		nReplicas = nPeers
+	}
+	if nReplicas < 1 {
+		nReplicas = 1
+	}
+	return nReplicas
 }
 
 // Result holds the store's (intermediate) result: the number of peers that have successfully
@@ -82,6 +151,12 @@ func NewInitialResult(sr *search.Result) *Result {
 	}
 }
 
+// ReplicationAchieved returns the number of peers that have stored the value, which may be
+// non-zero even when the store errored or timed out.
+func (r *Result) ReplicationAchieved() uint {
+	return uint(len(r.Responded))
+}
+
 // NewFatalResult creates a new Result object with a fatal error.
 func NewFatalResult(fatalErr error) *Result {
 	return &Result{
@@ -103,6 +178,13 @@ type Store struct {
 	// Params defining the store part of the operation
 	Params *Parameters
 
+	// ReplicateExisting is whether to keep storing the value even when the search finds it
+	// already exists, as when topping up the replication of an existing value.
+	ReplicateExisting bool
+
+	// whether the store's overall timeout passed before it finished
+	timedOut bool
+
 	// mutex used to synchronizes reads and writes to this instance
 	mu sync.Mutex
 }
@@ -119,6 +201,16 @@ func NewStore(
 	// closest peers found during search
 	updatedSearchParams := *searchParams // by value to avoid change original search params
 	updatedSearchParams.NClosestResponses = storeParams.NReplicas + storeParams.NMaxErrors
+	if storeParams.Placement.groupFunc() != nil {
+		// spreading replicas across groups needs farther peers to choose from
+		updatedSearchParams.NClosestResponses = diverseSearchFactor*storeParams.NReplicas +
+			storeParams.NMaxErrors
+	}
+	if storeParams.OverallTimeout > 0 && (updatedSearchParams.OverallTimeout == 0 ||
+		updatedSearchParams.OverallTimeout > storeParams.OverallTimeout) {
+		// search is part of the store, so can't outlast it
+		updatedSearchParams.OverallTimeout = storeParams.OverallTimeout
+	}
 	return &Store{
 		Request: client.NewStoreRequest(peerID, key, value),
 		Search:  search.NewSearch(peerID, key, &updatedSearchParams),
@@ -146,11 +238,21 @@ func (s *Store) Exhausted() bool {
 	return len(s.Result.Unqueried) == 0
 }
 
+// TimedOut returns whether the store's (or its search's) overall timeout passed before it
+// otherwise finished, in which case its result is partial. This operation is concurrency safe.
+func (s *Store) TimedOut() bool {
+	s.mu.Lock()
+	timedOut := s.timedOut
+	s.mu.Unlock()
+	return timedOut || s.Search.TimedOut()
+}
+
 // Finished returns whether the store operation has finished.
 func (s *Store) Finished() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.Stored() || s.Errored() || s.Exists() || s.Exhausted()
+	return s.Stored() || s.Errored() || (s.Exists() && !s.ReplicateExisting) || s.Exhausted() ||
+		s.timedOut
 }
 
 func (s *Store) moreUnqueried() bool {
@@ -8,6 +8,7 @@ import (
 	cid "github.com/drausin/libri/libri/common/id"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/server/peer"
+	"github.com/drausin/libri/libri/librarian/server/routing"
 	ssearch "github.com/drausin/libri/libri/librarian/server/search"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,6 +20,30 @@ func TestNewDefaultParameters(t *testing.T) {
 	assert.NotZero(t, p.Timeout)
 }
 
+func TestAdaptiveNReplicas(t *testing.T) {
+	params := NewDefaultParameters()
+
+	// check fixed NReplicas used when no target fraction
+	assert.Equal(t, params.NReplicas, AdaptiveNReplicas(&fixedSizeTable{nPeers: 64}, params))
+
+	params.TargetReplicaFraction = 0.1
+	cases := []struct {
+		nPeers   int
+		expected uint
+	}{
+		{0, 1},                      // empty table still gives one replica
+		{1, 1},                      // tiny networks are capped by number of peers
+		{8, params.MinNReplicas},    // small networks get at least the min
+		{64, 6},                     // medium networks scale with number of peers
+		{100, 10},                   // medium networks scale with number of peers
+		{1024, params.MaxNReplicas}, // large networks are capped by the max
+	}
+	for _, c := range cases {
+		rt := &fixedSizeTable{nPeers: c.nPeers}
+		assert.Equal(t, c.expected, AdaptiveNReplicas(rt, params), "nPeers: %d", c.nPeers)
+	}
+}
+
 func TestStore_Stored(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	peerID := ecid.NewPseudoRandom(rng)
@@ -47,6 +72,27 @@ func TestStore_Stored(t *testing.T) {
 	assert.True(t, store.Finished())
 }
 
+func TestStore_Finished_replicateExisting(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	peerID := ecid.NewPseudoRandom(rng)
+	value, key := api.NewTestDocument(rng)
+	store := NewStore(peerID, key, value, &ssearch.Parameters{}, &Parameters{
+		NReplicas:  3,
+		NMaxErrors: 3,
+	})
+	store.Result = NewInitialResult(store.Search.Result)
+	store.Result.Unqueried = []peer.Peer{nil} // just needs to be non-zero length
+	store.Result.Search.Value = value
+
+	// finished once search finds value already exists
+	assert.True(t, store.Exists())
+	assert.True(t, store.Finished())
+
+	// unless replicating existing value
+	store.ReplicateExisting = true
+	assert.False(t, store.Finished())
+}
+
 func TestStore_Errored(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	peerID, key := ecid.NewPseudoRandom(rng), cid.NewPseudoRandom(rng)
@@ -82,10 +128,15 @@ func TestStore_Errored(t *testing.T) {
 	assert.False(t, s.Errored())
 	assert.False(t, s.Finished())
 
+	// some peers stored the value before we got too many errors
+	s.Result.Responded = append(s.Result.Responded, nil, nil)
+
 	// push over the edge
 	s.Result.Errors = append(s.Result.Errors, errors.New("3"))
 	assert.True(t, s.Errored())
 	assert.True(t, s.Finished())
+	assert.False(t, s.Stored())
+	assert.Equal(t, uint(2), s.Result.ReplicationAchieved())
 
 	// or, if we receive a fatal error
 	s.Result.Errors = []error{}
@@ -93,3 +144,13 @@ func TestStore_Errored(t *testing.T) {
 	assert.True(t, s.Errored())
 	assert.True(t, s.Finished())
 }
+
+// fixedSizeTable is a routing.Table simulating a table with a fixed number of peers.
+type fixedSizeTable struct {
+	routing.Table
+	nPeers int
+}
+
+func (rt *fixedSizeTable) NumPeers() int {
+	return rt.nPeers
+}
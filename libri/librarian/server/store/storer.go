@@ -3,12 +3,15 @@ package store
 import (
 	"bytes"
 	"sync"
+	"time"
 
+	"github.com/drausin/libri/libri/common/clock"
 	"github.com/drausin/libri/libri/common/ecid"
 	"github.com/drausin/libri/libri/librarian/api"
 	"github.com/drausin/libri/libri/librarian/client"
 	"github.com/drausin/libri/libri/librarian/server/peer"
 	"github.com/drausin/libri/libri/librarian/server/search"
+	"golang.org/x/net/context"
 )
 
 // Storer executes store operations.
@@ -26,14 +29,26 @@ type storer struct {
 
 	// issues store queries to the peers
 	querier client.StoreQuerier
+
+	// records store and query durations, if non-nil
+	metrics *Metrics
 }
 
 // NewStorer creates a new Storer instance with given Searcher and StoreQuerier instances.
 func NewStorer(signer client.Signer, searcher search.Searcher, q client.StoreQuerier) Storer {
+	return NewStorerWithMetrics(signer, searcher, q, nil)
+}
+
+// NewStorerWithMetrics creates a new Storer like NewStorer that also records its stores in the
+// given metrics.
+func NewStorerWithMetrics(
+	signer client.Signer, searcher search.Searcher, q client.StoreQuerier, m *Metrics,
+) Storer {
 	return &storer{
 		signer:   signer,
 		searcher: searcher,
 		querier:  q,
+		metrics:  m,
 	}
 }
 
@@ -42,33 +57,48 @@ func NewDefaultStorer(peerID ecid.ID) Storer {
 	signer := client.NewSigner(peerID.Key())
 	return NewStorer(
 		signer,
-		search.NewDefaultSearcher(signer),
+		search.NewDefaultSearcher(signer, clock.New()),
 		client.NewStoreQuerier(),
 	)
 }
 
 func (s *storer) Store(store *Store, seeds []peer.Peer) error {
+	defer s.metrics.observeStore(time.Now())
+
+	// bound the whole store, independent of each query's timeout
+	ctx, cancel := context.Background(), func() {}
+	if store.Params.OverallTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, store.Params.OverallTimeout)
+	}
+	defer cancel()
+
 	if err := s.searcher.Search(store.Search, seeds); err != nil {
 		store.Result = NewFatalResult(err)
 		return err
 	}
 	store.Result = NewInitialResult(store.Search.Result)
+	store.Result.Unqueried = place(store.Search.Result.Closest, store.Params.Placement)
 
 	var wg sync.WaitGroup
 	for c := uint(0); c < store.Params.Concurrency; c++ {
 		wg.Add(1)
-		go s.storeWork(store, &wg)
+		go s.storeWork(ctx, store, &wg)
 	}
 	wg.Wait()
 
 	return store.Result.FatalErr
 }
 
-func (s *storer) storeWork(store *Store, wg *sync.WaitGroup) {
+func (s *storer) storeWork(ctx context.Context, store *Store, wg *sync.WaitGroup) {
 	defer wg.Done()
 	// work is finished when either the store is finished or we have no more unqueried peers
 	// (but the final, remaining queried peers may not have responded yet)
 	for !store.Finished() && store.safeMoreUnqueried() {
+		if ctx.Err() != nil {
+			// overall timeout has passed, so leave result as is
+			store.wrapLock(func() { store.timedOut = true })
+			return
+		}
 
 		// get next peer to query
 		store.mu.Lock()
@@ -83,15 +113,25 @@ func (s *storer) storeWork(store *Store, wg *sync.WaitGroup) {
 		store.mu.Unlock()
 
 		// do the query
-		if _, err := s.query(next.Connector(), store); err != nil {
+		_, err := s.query(ctx, next.Connector(), store)
+		if err != nil && ctx.Err() != nil {
+			// query was cut short by the overall timeout, so don't count it against the peer
+			store.wrapLock(func() { store.timedOut = true })
+			return
+		}
+		if err != nil {
 			// if we had an issue querying, skip to next peer
 			store.wrapLock(func() {
 				store.Result.Errors = append(store.Result.Errors, err)
 				next.Recorder().Record(peer.Response, peer.Error)
+				next.RecordFailure()
 			})
 			continue
 		}
-		store.wrapLock(func() { next.Recorder().Record(peer.Response, peer.Success) })
+		store.wrapLock(func() {
+			next.Recorder().Record(peer.Response, peer.Success)
+			next.RecordSuccess()
+		})
 
 		// add to slice of responded peers
 		store.wrapLock(func() {
@@ -100,15 +140,18 @@ func (s *storer) storeWork(store *Store, wg *sync.WaitGroup) {
 	}
 }
 
-func (s *storer) query(pConn api.Connector, store *Store) (*api.StoreResponse, error) {
-	ctx, cancel, err := client.NewSignedTimeoutContext(s.signer, store.Request,
+func (s *storer) query(ctx context.Context, pConn api.Connector, store *Store) (
+	*api.StoreResponse, error) {
+	ctx, cancel, err := client.NewSignedParentTimeoutContext(ctx, s.signer, store.Request,
 		store.Params.Timeout)
 	if err != nil {
 		return nil, err
 	}
 
+	start := time.Now()
 	rp, err := s.querier.Query(ctx, pConn, store.Request)
 	cancel()
+	s.metrics.observeStoreQuery(start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package store
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"errors"
 
@@ -95,6 +96,46 @@ func TestStorer_Store_ok(t *testing.T) {
 	}
 }
 
+func TestStorer_Store_overallTimeout(t *testing.T) {
+	n, nReplicas := 32, uint(8)
+	rng := rand.New(rand.NewSource(int64(n)))
+	peers, peersMap, selfPeerIdxs, selfID := ssearch.NewTestPeers(rng, n)
+	value, key := api.NewTestDocument(rng)
+	storerImpl := NewTestStorer(selfID, peersMap)
+	storerImpl.(*storer).querier = &slowQuerier{
+		delay: 20 * time.Millisecond,
+		inner: storerImpl.(*storer).querier,
+	}
+	searchParams := &ssearch.Parameters{
+		NMaxErrors:  ssearch.DefaultNMaxErrors,
+		Concurrency: uint(1),
+		Timeout:     time.Second,
+	}
+	storeParams := &Parameters{
+		NReplicas:      nReplicas,
+		NMaxErrors:     DefaultNMaxErrors,
+		Concurrency:    uint(1),
+		Timeout:        time.Second,
+		OverallTimeout: 50 * time.Millisecond,
+	}
+	store := NewStore(selfID, key, value, searchParams, storeParams)
+	assert.Equal(t, storeParams.OverallTimeout, store.Search.Params.OverallTimeout)
+
+	start := time.Now()
+	err := storerImpl.Store(store, ssearch.NewTestSeeds(peers, selfPeerIdxs))
+
+	// check store ends at overall timeout with partial result, before per-query timeout
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < storeParams.Timeout)
+	assert.True(t, store.TimedOut())
+	assert.True(t, store.Finished())
+	assert.False(t, store.Stored())
+	assert.False(t, store.Errored())
+	assert.True(t, len(store.Result.Responded) > 0)
+	assert.True(t, uint(len(store.Result.Responded)) < nReplicas)
+	assert.Equal(t, 0, len(store.Result.Errors))
+}
+
 type fixedSearcher struct {
 	fixed *ssearch.Result
 }
@@ -104,6 +145,10 @@ func (s *fixedSearcher) Search(search *ssearch.Search, seeds []peer.Peer) error
 	return nil
 }
 
+func (s *fixedSearcher) SearchMulti(search *ssearch.Search, seedGroups [][]peer.Peer) error {
+	return s.Search(search, nil)
+}
+
 func TestStorer_Store_queryErr(t *testing.T) {
 	storerImpl, store, selfPeerIdxs, peers, _ := newTestStore()
 	seeds := ssearch.NewTestSeeds(peers, selfPeerIdxs)
@@ -158,6 +203,10 @@ func (es *errSearcher) Search(search *ssearch.Search, seeds []peer.Peer) error {
 	return errors.New("some search error")
 }
 
+func (es *errSearcher) SearchMulti(search *ssearch.Search, seedGroups [][]peer.Peer) error {
+	return es.Search(search, nil)
+}
+
 func TestStorer_Store_err(t *testing.T) {
 	s := &storer{
 		searcher: &errSearcher{},
@@ -166,6 +215,7 @@ func TestStorer_Store_err(t *testing.T) {
 	// check that Store() surfaces searcher error
 	store := &Store{
 		Result: &Result{},
+		Params: &Parameters{},
 	}
 	assert.NotNil(t, s.Store(store, nil))
 }
@@ -178,6 +228,22 @@ func (f *timeoutQuerier) Query(ctx context.Context, pConn api.Connector, fr *api
 	return nil, errors.New("simulated timeout error")
 }
 
+// slowQuerier delays each query to another querier, ending early if its context does
+type slowQuerier struct {
+	delay time.Duration
+	inner client.StoreQuerier
+}
+
+func (f *slowQuerier) Query(ctx context.Context, pConn api.Connector, rq *api.StoreRequest,
+	opts ...grpc.CallOption) (*api.StoreResponse, error) {
+	select {
+	case <-time.After(f.delay):
+		return f.inner.Query(ctx, pConn, rq, opts...)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // diffRequestIDFinder returns a response with a different request ID
 type diffRequestIDQuerier struct {
 	rng    *rand.Rand
@@ -207,7 +273,7 @@ func TestStorer_query_err(t *testing.T) {
 		// use querier that simulates a timeout
 		querier: &timeoutQuerier{},
 	}
-	rp1, err := s1.query(clientConn, store)
+	rp1, err := s1.query(context.Background(), clientConn, store)
 	assert.Nil(t, rp1)
 	assert.NotNil(t, err)
 
@@ -219,7 +285,7 @@ func TestStorer_query_err(t *testing.T) {
 			peerID: selfID,
 		},
 	}
-	rp2, err := s2.query(clientConn, store)
+	rp2, err := s2.query(context.Background(), clientConn, store)
 	assert.Nil(t, rp2)
 	assert.NotNil(t, err)
 
@@ -227,7 +293,7 @@ func TestStorer_query_err(t *testing.T) {
 		// use signer that returns an error
 		signer: &client.TestErrSigner{},
 	}
-	rp3, err := s3.query(clientConn, store)
+	rp3, err := s3.query(context.Background(), clientConn, store)
 	assert.Nil(t, rp3)
 	assert.NotNil(t, err)
 }
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// newServerCredentials returns the TLS transport credentials for the librarian's gRPC server
+// from the given PEM-encoded certificate and key files. If clientCAFile is non-empty, clients
+// must present a certificate signed by one of the CAs it contains.
+func newServerCredentials(certFile, keyFile, clientCAFile string) (
+	credentials.TransportCredentials, error) {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load TLS cert %s and key %s: %v", certFile, keyFile,
+			err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		clientCAs, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// newPeerCredentials returns the TLS transport credentials the librarian dials its peers with,
+// presenting the given PEM-encoded certificate and key as its client certificate. If peerCAFile is
+// non-empty, peers must present a certificate signed by one of the CAs it contains; otherwise the
+// system's CAs are used.
+func newPeerCredentials(certFile, keyFile, peerCAFile string) (
+	credentials.TransportCredentials, error) {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load TLS cert %s and key %s: %v", certFile, keyFile,
+			err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if peerCAFile != "" {
+		rootCAs, err := loadCertPool(peerCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// NewClientCredentials returns the TLS transport credentials clients (e.g., authors) dial
+// librarians with. If caFile is non-empty, librarians must present a certificate signed by one of
+// the CAs it contains; otherwise the system's CAs are used. If certFile and keyFile are non-empty,
+// their PEM-encoded certificate and key are presented as the client certificate, for librarians
+// requiring one.
+func NewClientCredentials(caFile, certFile, keyFile string) (
+	credentials.TransportCredentials, error) {
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load TLS cert %s and key %s: %v", certFile,
+				keyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		rootCAs, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read TLS CA %s: %v", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("unable to parse TLS CA %s: no PEM certificates found", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewServerCredentials_ok(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-tls")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	certFile, keyFile := writeTestCertKey(t, dir)
+
+	// without client cert verification
+	creds, err := newServerCredentials(certFile, keyFile, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+
+	// with client cert verification, using the self-signed cert as the CA
+	creds, err = newServerCredentials(certFile, keyFile, certFile)
+	assert.Nil(t, err)
+	assert.NotNil(t, creds)
+}
+
+func TestNewServerCredentials_err(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-tls")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	certFile, keyFile := writeTestCertKey(t, dir)
+	missingFile := filepath.Join(dir, "missing.pem")
+	notPEMFile := filepath.Join(dir, "not.pem")
+	err = ioutil.WriteFile(notPEMFile, []byte("not a PEM file"), 0600)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		certFile, keyFile, clientCAFile string
+	}{
+		{missingFile, keyFile, ""},       // missing cert
+		{certFile, missingFile, ""},      // missing key
+		{notPEMFile, keyFile, ""},        // cert doesn't parse
+		{keyFile, certFile, ""},          // cert and key swapped
+		{certFile, keyFile, missingFile}, // missing client CA
+		{certFile, keyFile, notPEMFile},  // client CA doesn't parse
+	}
+	for i, c := range cases {
+		creds, err := newServerCredentials(c.certFile, c.keyFile, c.clientCAFile)
+		assert.NotNil(t, err, "case %d", i)
+		assert.Nil(t, creds, "case %d", i)
+	}
+}
+
+func TestNewPeerCredentials_ok(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-tls")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	certFile, keyFile := writeTestCertKey(t, dir)
+
+	// with system CAs
+	creds, err := newPeerCredentials(certFile, keyFile, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+
+	// with peer cert verification, using the self-signed cert as the CA
+	creds, err = newPeerCredentials(certFile, keyFile, certFile)
+	assert.Nil(t, err)
+	assert.NotNil(t, creds)
+}
+
+func TestNewPeerCredentials_err(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-tls")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	certFile, keyFile := writeTestCertKey(t, dir)
+	missingFile := filepath.Join(dir, "missing.pem")
+
+	cases := []struct {
+		certFile, keyFile, peerCAFile string
+	}{
+		{missingFile, keyFile, ""},       // missing cert
+		{certFile, keyFile, missingFile}, // missing peer CA
+	}
+	for i, c := range cases {
+		creds, err := newPeerCredentials(c.certFile, c.keyFile, c.peerCAFile)
+		assert.NotNil(t, err, "case %d", i)
+		assert.Nil(t, creds, "case %d", i)
+	}
+}
+
+func TestNewClientCredentials_ok(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-tls")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	certFile, keyFile := writeTestCertKey(t, dir)
+
+	// with system CAs and no client cert
+	creds, err := NewClientCredentials("", "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+
+	// with librarian cert verification, using the self-signed cert as the CA, and client cert
+	creds, err = NewClientCredentials(certFile, certFile, keyFile)
+	assert.Nil(t, err)
+	assert.NotNil(t, creds)
+}
+
+func TestNewClientCredentials_err(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-tls")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.RemoveAll(dir)) }()
+	certFile, keyFile := writeTestCertKey(t, dir)
+	missingFile := filepath.Join(dir, "missing.pem")
+
+	cases := []struct {
+		caFile, certFile, keyFile string
+	}{
+		{missingFile, "", ""},          // missing CA
+		{"", certFile, ""},             // cert without key
+		{"", missingFile, keyFile},     // missing cert
+		{certFile, certFile, certFile}, // cert as key
+	}
+	for i, c := range cases {
+		creds, err := NewClientCredentials(c.caFile, c.certFile, c.keyFile)
+		assert.NotNil(t, err, "case %d", i)
+		assert.Nil(t, creds, "case %d", i)
+	}
+}
+
+// writeTestCertKey writes a self-signed certificate for localhost and its key to PEM files in
+// the given directory, returning their paths.
+func writeTestCertKey(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	assert.Nil(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+	return certFile, keyFile
+}